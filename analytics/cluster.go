@@ -0,0 +1,286 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+	"rag/vectorindex"
+)
+
+// Default clustering parameters
+const (
+	DefaultThreshold = 0.85 // Cosine similarity of a query to a theme it joins
+	DefaultMaxThemes = 20
+	DefaultLowScore  = 2.5 // Average feedback score at or below which a theme is reported as low-rated
+	maxExamples      = 5
+)
+
+// ClusterOptions configures clustering. Zero values use the defaults.
+type ClusterOptions struct {
+	Threshold float64
+	MaxThemes int     // Themes reported per list
+	LowScore  float64 // Average score of low-rated themes
+	Since     time.Time
+}
+
+// Theme is a group of similar questions
+type Theme struct {
+	Question string   `json:"question"` // Question closest to the center of the theme
+	Queries  int      `json:"queries"`
+	Examples []string `json:"examples"` // Distinct questions, most recent first
+	Stores   []string `json:"stores"`
+	Rated    int      `json:"rated"`              // Queries with feedback
+	AvgScore *float64 `json:"avgScore,omitempty"` // Average feedback score, nil without feedback
+
+	centroid []float64
+	members  []*Entry
+}
+
+// Report lists the most common and the lowest rated question themes
+type Report struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Queries     int       `json:"queries"`
+	Themes      int       `json:"themes"`
+	Common      []*Theme  `json:"common"`   // Most asked first
+	LowRated    []*Theme  `json:"lowRated"` // Lowest average score first
+}
+
+// Cluster embeds the logged queries that have no embedding yet and groups all queries
+// asked since opts.Since into themes. Each query joins the most similar theme when its
+// similarity to the theme center reaches the threshold, and starts a new theme otherwise.
+func Cluster(ctx context.Context, l *Log, embedder vectorindex.Embedder, opts ClusterOptions) (*Report, error) {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.MaxThemes <= 0 {
+		opts.MaxThemes = DefaultMaxThemes
+	}
+	if opts.LowScore <= 0 {
+		opts.LowScore = DefaultLowScore
+	}
+
+	var entries []*Entry
+	var missing []*Entry
+	for _, entry := range l.Entries() {
+		if entry.AskedAt.Before(opts.Since) {
+			continue
+		}
+		entries = append(entries, &entry)
+		if entry.Vector == nil {
+			missing = append(missing, entries[len(entries)-1])
+		}
+	}
+
+	if len(missing) > 0 {
+		texts := make([]string, len(missing))
+		for i, entry := range missing {
+			texts[i] = entry.Query
+		}
+		vectors, err := embedder.Embed(ctx, texts, filesearch.TaskClustering)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed queries: %w", err)
+		}
+		byID := make(map[string][]float32, len(missing))
+		for i, entry := range missing {
+			entry.Vector = vectors[i]
+			byID[entry.ID] = vectors[i]
+		}
+		if err := l.setVectors(byID); err != nil {
+			return nil, err
+		}
+	}
+
+	var themes []*Theme
+	for _, entry := range entries {
+		vector := normalize(entry.Vector)
+		var best *Theme
+		bestScore := opts.Threshold
+		for _, theme := range themes {
+			if score := dot(vector, unit(theme.centroid)); score >= bestScore {
+				best, bestScore = theme, score
+			}
+		}
+		if best == nil {
+			best = &Theme{centroid: make([]float64, len(vector))}
+			themes = append(themes, best)
+		}
+		best.add(entry, vector)
+	}
+	for _, theme := range themes {
+		theme.summarize()
+	}
+
+	report := &Report{
+		GeneratedAt: time.Now().UTC(),
+		Queries:     len(entries),
+		Themes:      len(themes),
+	}
+
+	common := append([]*Theme(nil), themes...)
+	sort.SliceStable(common, func(i, j int) bool {
+		return common[i].Queries > common[j].Queries
+	})
+	report.Common = common[:min(len(common), opts.MaxThemes)]
+
+	report.LowRated = []*Theme{}
+	for _, theme := range themes {
+		if theme.AvgScore != nil && *theme.AvgScore <= opts.LowScore {
+			report.LowRated = append(report.LowRated, theme)
+		}
+	}
+	sort.SliceStable(report.LowRated, func(i, j int) bool {
+		return *report.LowRated[i].AvgScore < *report.LowRated[j].AvgScore
+	})
+	report.LowRated = report.LowRated[:min(len(report.LowRated), opts.MaxThemes)]
+
+	return report, nil
+}
+
+// add makes a query a member of the theme
+func (t *Theme) add(entry *Entry, vector []float64) {
+	for i, v := range vector {
+		t.centroid[i] += v
+	}
+	t.members = append(t.members, entry)
+}
+
+// summarize sets the reported fields from the members
+func (t *Theme) summarize() {
+	center := unit(t.centroid)
+	bestScore := math.Inf(-1)
+	seen := make(map[string]bool)
+	stores := make(map[string]bool)
+	var total int
+
+	t.Queries = len(t.members)
+	t.Examples = []string{}
+	t.Stores = []string{}
+	for i := len(t.members) - 1; i >= 0; i-- {
+		entry := t.members[i]
+		if score := dot(normalize(entry.Vector), center); score > bestScore {
+			t.Question, bestScore = entry.Query, score
+		}
+		if !seen[entry.Query] && len(t.Examples) < maxExamples {
+			seen[entry.Query] = true
+			t.Examples = append(t.Examples, entry.Query)
+		}
+		if !stores[entry.Store] {
+			stores[entry.Store] = true
+			t.Stores = append(t.Stores, entry.Store)
+		}
+		if entry.Score > 0 {
+			t.Rated++
+			total += entry.Score
+		}
+	}
+	sort.Strings(t.Stores)
+	if t.Rated > 0 {
+		avg := float64(total) / float64(t.Rated)
+		t.AvgScore = &avg
+	}
+}
+
+func normalize(v []float32) []float64 {
+	out := make([]float64, len(v))
+	for i, x := range v {
+		out[i] = float64(x)
+	}
+	return unit(out)
+}
+
+func unit(v []float64) []float64 {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	out := make([]float64, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+func dot(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// Job clusters the query log periodically and keeps the latest report
+type Job struct {
+	log      *Log
+	embedder vectorindex.Embedder
+	opts     ClusterOptions
+	window   time.Duration
+
+	mu     sync.Mutex
+	report *Report
+}
+
+// NewJob creates a clustering job over the queries asked in the last window, all queries when 0.
+// The embedder is wrapped in a vectorindex.Batcher unless it is one.
+func NewJob(l *Log, embedder vectorindex.Embedder, opts ClusterOptions, window time.Duration) *Job {
+	if _, ok := embedder.(*vectorindex.Batcher); !ok {
+		embedder = vectorindex.NewBatcher(embedder, vectorindex.BatcherOptions{})
+	}
+	return &Job{log: l, embedder: embedder, opts: opts, window: window}
+}
+
+// Run clusters the queries now and keeps the report
+func (j *Job) Run(ctx context.Context) (*Report, error) {
+	opts := j.opts
+	if j.window > 0 {
+		opts.Since = time.Now().Add(-j.window)
+	}
+	report, err := Cluster(ctx, j.log, j.embedder, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	j.report = report
+	j.mu.Unlock()
+	return report, nil
+}
+
+// Report returns the latest report, nil before the first run
+func (j *Job) Report() *Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.report
+}
+
+// Schedule runs the job now and then at every interval until ctx is done
+func (j *Job) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if report, err := j.Run(ctx); err != nil {
+			log.Printf("Warning: Query clustering failed: %v", err)
+		} else {
+			log.Printf("Query clustering: %d queries in %d themes, %d low-rated", report.Queries, report.Themes, len(report.LowRated))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
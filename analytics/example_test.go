@@ -0,0 +1,71 @@
+package analytics_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"rag/analytics"
+)
+
+// topicEmbedder embeds a question by the topics it mentions
+type topicEmbedder struct{}
+
+func (topicEmbedder) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		text = strings.ToLower(text)
+		vectors[i] = []float32{0.1, 0.1}
+		if strings.Contains(text, "minimumloon") {
+			vectors[i][0] = 1
+		}
+		if strings.Contains(text, "vakantie") {
+			vectors[i][1] = 1
+		}
+	}
+	return vectors, nil
+}
+
+func ExampleCluster() {
+	queryLog, err := analytics.OpenLog("", 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, q := range []struct {
+		query string
+		score int
+	}{
+		{"Wat is het minimumloon in PC 302?", 4},
+		{"Minimumloon voor een student van 17?", 5},
+		{"Hoeveel is het minimumloon?", 0},
+		{"Hoeveel vakantiedagen krijg ik?", 1},
+		{"Vakantiegeld berekenen", 2},
+	} {
+		id, err := queryLog.Record("cao-documents", "", q.query, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if q.score > 0 {
+			if err := queryLog.SetScore(id, q.score); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	report, err := analytics.Cluster(context.Background(), queryLog, topicEmbedder{}, analytics.ClusterOptions{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, theme := range report.Common {
+		fmt.Printf("%d queries, %d rated, average %.1f\n", theme.Queries, theme.Rated, *theme.AvgScore)
+	}
+	for _, theme := range report.LowRated {
+		fmt.Println("low-rated:", theme.Examples)
+	}
+	// Output:
+	// 3 queries, 2 rated, average 4.5
+	// 2 queries, 2 rated, average 1.5
+	// low-rated: [Vakantiegeld berekenen Hoeveel vakantiedagen krijg ik?]
+}
@@ -0,0 +1,171 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Handler provides HTTP handlers for query feedback and the analytics API
+type Handler struct {
+	log     *Log
+	job     *Job
+	evalDir string
+}
+
+// NewHandler creates a handler for the log and its clustering job. Sessions converted
+// to eval cases are saved in evalDir.
+func NewHandler(l *Log, job *Job, evalDir string) *Handler {
+	return &Handler{log: l, job: job, evalDir: evalDir}
+}
+
+// FeedbackRequest rates an answer by the queryId returned with it
+type FeedbackRequest struct {
+	QueryID string `json:"queryId"`
+	Score   int    `json:"score"`
+}
+
+// Feedback handles POST requests rating an answer
+// POST /feedback
+// Body: {"queryId": "...", "score": 4}
+func (h *Handler) Feedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := h.log.SetScore(req.QueryID, req.Score); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Themes handles GET requests for the latest clustering report, clustering now when the job
+// has not run yet
+// GET /admin/analytics/themes
+func (h *Handler) Themes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := h.job.Report()
+	if report == nil {
+		var err error
+		if report, err = h.job.Run(r.Context()); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// Query handles GET requests for a logged query, with the provenance of its answer when
+// the handler recorded it, to audit an answer after the fact
+// GET /admin/analytics/queries/{id}
+func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entry, err := h.log.Entry(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	entry.Vector = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// Sessions handles GET requests listing the logged chat sessions, most recent first
+// GET /admin/analytics/sessions
+func (h *Handler) Sessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessions := h.log.Sessions()
+	if sessions == nil {
+		sessions = []*Session{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// EvalRequest names the eval case a session is converted into
+type EvalRequest struct {
+	Name        string `json:"name"`
+	AcceptScore int    `json:"acceptScore,omitempty"` // Lowest score of an accepted answer, DefaultAcceptScore when 0
+}
+
+// ExportEval handles POST requests converting a chat session into an eval case, saved in the
+// eval directory so it is replayed by the next eval run
+// POST /admin/analytics/sessions/{id}/eval
+// Body: {"name": "minimumloon-student"}
+func (h *Handler) ExportEval(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EvalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	c, err := h.log.EvalCase(r.PathValue("id"), strings.TrimSpace(req.Name), req.AcceptScore)
+	if errors.Is(err, ErrSessionNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	path, err := c.Save(h.evalDir)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, os.ErrExist) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"path": path,
+		"case": c,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
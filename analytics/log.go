@@ -0,0 +1,209 @@
+// Package analytics keeps a log of answered queries and their feedback, and clusters the
+// queries into question themes to guide corpus and prompt improvements.
+package analytics
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+)
+
+// Feedback scores
+const (
+	MinScore = 1
+	MaxScore = 5
+)
+
+// DefaultMaxEntries is the number of queries kept in the log; older ones are dropped first
+const DefaultMaxEntries = 10000
+
+// ErrNotFound is returned when rating a query that is not in the log
+var ErrNotFound = errors.New("query not found")
+
+// Entry is an answered query
+type Entry struct {
+	ID      string    `json:"id"`
+	Store   string    `json:"store"`
+	Session string    `json:"session,omitempty"` // Chat session the query was asked in
+	Query   string    `json:"query"`
+	Answer  string    `json:"answer,omitempty"`
+	AskedAt time.Time `json:"askedAt"`
+	Score   int       `json:"score,omitempty"`  // Feedback score from MinScore to MaxScore, 0 when not rated
+	Vector  []float32 `json:"vector,omitempty"` // Embedding, set by the first clustering run
+
+	Provenance *filesearch.Provenance `json:"provenance,omitempty"` // Set when answers carry their provenance
+}
+
+// Log keeps the most recent answered queries
+type Log struct {
+	path       string
+	maxEntries int
+
+	mu      sync.Mutex
+	entries []*Entry // in order of asking
+	byID    map[string]*Entry
+}
+
+var _ filesearch.QueryLogger = (*Log)(nil)
+
+// OpenLog creates a query log keeping up to maxEntries queries, DefaultMaxEntries when 0.
+// When path is not empty, the log is loaded from and saved to that JSON file so it survives restarts.
+func OpenLog(path string, maxEntries int) (*Log, error) {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	l := &Log{
+		path:       path,
+		maxEntries: maxEntries,
+		byID:       make(map[string]*Entry),
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read query log: %w", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &l.entries); err != nil {
+				return nil, fmt.Errorf("failed to decode query log: %w", err)
+			}
+		}
+	}
+	for _, entry := range l.entries {
+		l.byID[entry.ID] = entry
+	}
+
+	return l, nil
+}
+
+// Record adds an answered query to the log and returns its ID
+func (l *Log) Record(store, session, query, answer string) (string, error) {
+	return l.add(&Entry{
+		Store:   store,
+		Session: session,
+		Query:   query,
+		Answer:  answer,
+	})
+}
+
+// add assigns an ID to an entry and adds it to the log
+func (l *Log) add(entry *Entry) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate query ID: %w", err)
+	}
+	entry.ID = hex.EncodeToString(b)
+	entry.AskedAt = time.Now().UTC()
+	if entry.Provenance != nil {
+		entry.AskedAt = entry.Provenance.AskedAt
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+	l.byID[entry.ID] = entry
+	if drop := len(l.entries) - l.maxEntries; drop > 0 {
+		for _, old := range l.entries[:drop] {
+			delete(l.byID, old.ID)
+		}
+		l.entries = append([]*Entry(nil), l.entries[drop:]...)
+	}
+
+	return entry.ID, l.saveLocked()
+}
+
+// LogQuery records an answered query with its provenance, logging failures instead of failing
+// the answer. It implements filesearch.QueryLogger.
+func (l *Log) LogQuery(r *http.Request, req *filesearch.QueryRequest, resp *filesearch.QueryResponse) string {
+	id, err := l.add(&Entry{
+		Store:      req.StoreName,
+		Session:    req.SessionID,
+		Query:      req.Query,
+		Answer:     resp.Answer,
+		Provenance: resp.Provenance,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to log query: %v", err)
+	}
+	return id
+}
+
+// Entry returns a copy of a logged query
+func (l *Log) Entry(id string) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+// SetScore records the feedback score of a query, replacing an earlier one
+func (l *Log) SetScore(id string, score int) error {
+	if score < MinScore || score > MaxScore {
+		return fmt.Errorf("score must be between %d and %d", MinScore, MaxScore)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.byID[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	entry.Score = score
+	return l.saveLocked()
+}
+
+// Entries returns a copy of the logged queries, oldest first
+func (l *Log) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]Entry, len(l.entries))
+	for i, entry := range l.entries {
+		entries[i] = *entry
+	}
+	return entries
+}
+
+// setVectors stores the embeddings of queries by ID, so later runs only embed new queries
+func (l *Log) setVectors(vectors map[string][]float32) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, vector := range vectors {
+		if entry, ok := l.byID[id]; ok {
+			entry.Vector = vector
+		}
+	}
+	return l.saveLocked()
+}
+
+func (l *Log) saveLocked() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(l.entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode query log: %w", err)
+	}
+	if err := os.WriteFile(l.path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write query log: %w", err)
+	}
+	return os.Rename(l.path+".tmp", l.path)
+}
@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"rag/eval"
+)
+
+// DefaultAcceptScore is the feedback score from which an answer is accepted into an eval case
+const DefaultAcceptScore = 4
+
+// ErrSessionNotFound is returned when converting a session that has no logged queries
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session summarizes the logged queries of a chat session
+type Session struct {
+	ID            string    `json:"id"`
+	Store         string    `json:"store"`
+	Queries       int       `json:"queries"`
+	Rated         int       `json:"rated"`
+	FirstQuestion string    `json:"firstQuestion"`
+	StartedAt     time.Time `json:"startedAt"`
+	EndedAt       time.Time `json:"endedAt"`
+}
+
+// Sessions returns the logged chat sessions, most recent first
+func (l *Log) Sessions() []*Session {
+	byID := make(map[string]*Session)
+	var sessions []*Session
+	for _, entry := range l.Entries() {
+		if entry.Session == "" {
+			continue
+		}
+		s, ok := byID[entry.Session]
+		if !ok {
+			s = &Session{ID: entry.Session, Store: entry.Store, FirstQuestion: entry.Query, StartedAt: entry.AskedAt}
+			byID[entry.Session] = s
+			sessions = append(sessions, s)
+		}
+		s.Queries++
+		if entry.Score > 0 {
+			s.Rated++
+		}
+		s.EndedAt = entry.AskedAt
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].EndedAt.After(sessions[j].EndedAt)
+	})
+	return sessions
+}
+
+// EvalCase converts a chat session into an eval case named name. Every question of the session
+// becomes a turn; answers rated acceptScore or higher (DefaultAcceptScore when 0) become its
+// accepted answers, and questions without one are only replayed for context.
+func (l *Log) EvalCase(sessionID, name string, acceptScore int) (*eval.Case, error) {
+	if acceptScore <= 0 {
+		acceptScore = DefaultAcceptScore
+	}
+
+	c := &eval.Case{Name: name, Source: "session " + sessionID}
+	for _, entry := range l.Entries() {
+		if entry.Session != sessionID {
+			continue
+		}
+		if c.Store == "" {
+			c.Store = entry.Store
+		}
+		turn := eval.Turn{Question: entry.Query}
+		if entry.Score >= acceptScore {
+			turn.Answer = entry.Answer
+		}
+		c.Turns = append(c.Turns, turn)
+	}
+	if len(c.Turns) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+
+	now := time.Now().UTC()
+	c.CreatedAt = &now
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
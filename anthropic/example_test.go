@@ -0,0 +1,68 @@
+package anthropic_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"rag/anthropic"
+	"rag/filesearch"
+	"rag/vectorindex"
+)
+
+// staticRetriever always returns the same chunks
+type staticRetriever []*vectorindex.Match
+
+func (r staticRetriever) Retrieve(ctx context.Context, query string, topK int) ([]*vectorindex.Match, error) {
+	return r, nil
+}
+
+func Example() {
+	// A stand-in for the Messages API citing the first document
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"content": [
+				{"type": "text", "text": "The minimum hourly wage is 14,05 EUR.", "citations": [
+					{"type": "char_location", "cited_text": "Het minimumuurloon bedraagt 14,05 EUR", "document_index": 0, "document_title": "100-2022-011302.pdf p.2"}
+				]}
+			],
+			"usage": {"input_tokens": 420, "output_tokens": 12}
+		}`)
+	}))
+	defer api.Close()
+
+	provider, err := anthropic.New(anthropic.Config{APIKey: "test", BaseURL: api.URL}, staticRetriever{
+		{Chunk: &vectorindex.Chunk{
+			ID:       "100-2022-011302.pdf#3",
+			Document: "100-2022-011302.pdf",
+			Page:     2,
+			Text:     "Het minimumuurloon bedraagt 14,05 EUR voor werklieden van 18 jaar en ouder.",
+		}},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	resp, err := provider.PromptWithRetrieval(context.Background(), "What is the minimum hourly wage?", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Text())
+	for _, c := range resp.Citations {
+		fmt.Printf("[%d:%d] %s (%s)\n", c.StartIndex, c.EndIndex, c.Sources[0].Title, c.Sources[0].URI)
+	}
+	fmt.Println(resp.Usage.TotalTokens)
+
+	// The local index cannot apply metadata filters
+	_, err = provider.PromptWithRetrieval(context.Background(), "What is the minimum hourly wage?", "", &filesearch.RetrievalOptions{
+		MetadataFilter: `jc_number="100"`,
+	})
+	fmt.Println(err)
+	// Output:
+	// The minimum hourly wage is 14,05 EUR.
+	// [0:37] 100-2022-011302.pdf p.2 (100-2022-011302.pdf#3)
+	// 432
+	// failed to retrieve: metadata filters are not supported by the local index
+}
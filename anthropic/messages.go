@@ -0,0 +1,123 @@
+package anthropic
+
+import (
+	"rag/filesearch"
+	"rag/vectorindex"
+)
+
+// messagesRequest is the body of a Messages API request
+type messagesRequest struct {
+	Model     string     `json:"model"`
+	MaxTokens int        `json:"max_tokens"`
+	System    string     `json:"system,omitempty"`
+	Messages  []*message `json:"messages"`
+}
+
+type message struct {
+	Role    string          `json:"role"`
+	Content []*contentBlock `json:"content"`
+}
+
+// contentBlock is a text or document block of a request message
+type contentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    *documentSource `json:"source,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	Citations *citationConfig `json:"citations,omitempty"`
+}
+
+type documentSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type citationConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// messagesResponse is the body of a Messages API response
+type messagesResponse struct {
+	Content []*responseBlock `json:"content"`
+	Usage   *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type responseBlock struct {
+	Type      string      `json:"type"`
+	Text      string      `json:"text"`
+	Citations []*citation `json:"citations"`
+}
+
+// citation is a passage of a request document supporting a text block
+type citation struct {
+	Type          string `json:"type"` // "char_location" for plain text documents
+	CitedText     string `json:"cited_text"`
+	DocumentIndex int    `json:"document_index"`
+	DocumentTitle string `json:"document_title"`
+}
+
+// newRequest passes every retrieved chunk as a citable document followed by the prompt
+func newRequest(cfg Config, prompt string, matches []*vectorindex.Match) *messagesRequest {
+	content := make([]*contentBlock, 0, len(matches)+1)
+	for _, m := range matches {
+		content = append(content, &contentBlock{
+			Type:      "document",
+			Source:    &documentSource{Type: "text", MediaType: "text/plain", Data: m.Text},
+			Title:     m.Title(),
+			Citations: &citationConfig{Enabled: true},
+		})
+	}
+	content = append(content, &contentBlock{Type: "text", Text: prompt})
+
+	return &messagesRequest{
+		Model:     cfg.Model,
+		MaxTokens: cfg.MaxTokens,
+		System:    systemPrompt,
+		Messages:  []*message{{Role: "user", Content: content}},
+	}
+}
+
+// parseResponse converts a Messages API response into a PromptResponse. Citations span the
+// text block they support; every retrieved chunk is reported as grounding.
+func parseResponse(resp *messagesResponse, matches []*vectorindex.Match) *filesearch.PromptResponse {
+	result := &filesearch.PromptResponse{
+		Parts: make([]string, 0, len(resp.Content)),
+	}
+
+	offset := 0
+	for _, block := range resp.Content {
+		if block.Type != "text" {
+			continue
+		}
+		result.Parts = append(result.Parts, block.Text)
+
+		if len(block.Citations) > 0 {
+			c := &filesearch.Citation{StartIndex: offset, EndIndex: offset + len(block.Text)}
+			for _, cited := range block.Citations {
+				source := &filesearch.Source{Title: cited.DocumentTitle}
+				if cited.DocumentIndex >= 0 && cited.DocumentIndex < len(matches) {
+					source.URI = matches[cited.DocumentIndex].ID
+				}
+				c.Sources = append(c.Sources, source)
+			}
+			result.Citations = append(result.Citations, c)
+		}
+		offset += len(block.Text)
+	}
+
+	result.GroundingSupport = vectorindex.GroundingSupport(matches)
+
+	if u := resp.Usage; u != nil {
+		result.Usage = &filesearch.TokenUsage{
+			PromptTokens:   u.InputTokens,
+			ResponseTokens: u.OutputTokens,
+			TotalTokens:    u.InputTokens + u.OutputTokens,
+		}
+	}
+
+	return result
+}
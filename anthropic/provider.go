@@ -0,0 +1,172 @@
+// Package anthropic answers queries with Anthropic's Messages API, grounded in chunks
+// retrieved from the local vector index and cited with the API's document citations.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"rag/filesearch"
+	"rag/vectorindex"
+)
+
+// Defaults for the provider configuration
+const (
+	DefaultModel     = "claude-sonnet-4-5"
+	DefaultMaxTokens = 2048
+	DefaultTopK      = 8
+	DefaultBaseURL   = "https://api.anthropic.com"
+)
+
+// apiVersion is the Messages API version the request and response types follow
+const apiVersion = "2023-06-01"
+
+const systemPrompt = `You answer questions about Belgian collective labour agreements (CAO's) using only the provided documents.
+Cite the passages your answer is based on. When the documents do not answer the question, say so instead of guessing.
+Answer in the language of the question.`
+
+// Retriever returns the chunks most relevant to a query, see vectorindex.Retriever
+type Retriever = vectorindex.MatchRetriever
+
+// Config holds the configuration for the provider
+type Config struct {
+	APIKey     string
+	Model      string // Defaults to DefaultModel
+	MaxTokens  int    // Defaults to DefaultMaxTokens
+	TopK       int    // Chunks retrieved when the query does not set it, defaults to DefaultTopK
+	BaseURL    string // Defaults to DefaultBaseURL
+	HTTPClient *http.Client
+}
+
+// Provider implements filesearch.Provider with the Messages API
+type Provider struct {
+	cfg       Config
+	retriever Retriever
+}
+
+var (
+	_ filesearch.Provider       = (*Provider)(nil)
+	_ filesearch.ChunkRetriever = (*Provider)(nil)
+)
+
+// New creates a provider answering from the chunks the retriever finds
+func New(cfg Config, retriever Retriever) (*Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+	if retriever == nil {
+		return nil, fmt.Errorf("retriever is required")
+	}
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = DefaultMaxTokens
+	}
+	if cfg.TopK <= 0 {
+		cfg.TopK = DefaultTopK
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Provider{cfg: cfg, retriever: retriever}, nil
+}
+
+// APIError is an error response of the Messages API
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("anthropic: %d %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Unavailable reports whether the API is overloaded, rate limited or failing, see filesearch.IsUnavailable
+func (e *APIError) Unavailable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// PromptWithRetrieval retrieves the chunks most relevant to the prompt from the local index
+// and answers from them. The store name is not used: the local index holds a single store.
+// Metadata filters are not supported by the local index and fail, so a filter scoping
+// retrieval, e.g. to a tenant, is never silently ignored.
+func (p *Provider) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	matches, err := vectorindex.RetrieveMatches(ctx, p.retriever, prompt, p.cfg.TopK, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.send(ctx, newRequest(p.cfg, prompt, matches))
+	if err != nil {
+		return nil, vectorindex.GenerationError(matches, err)
+	}
+
+	return parseResponse(resp, matches), nil
+}
+
+// send posts a request to the Messages API
+func (p *Provider) send(ctx context.Context, req *messagesRequest) (*messagesResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.cfg.BaseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.cfg.APIKey)
+	httpReq.Header.Set("anthropic-version", apiVersion)
+
+	httpResp, err := p.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &errResp)
+		return nil, &APIError{
+			StatusCode: httpResp.StatusCode,
+			Type:       errResp.Error.Type,
+			Message:    errResp.Error.Message,
+		}
+	}
+
+	var resp messagesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &resp, nil
+}
+
+// RetrieveChunks returns the chunks of the local index most similar to the query, with their
+// scores, without answering it. Metadata filters are not supported and fail.
+func (p *Provider) RetrieveChunks(ctx context.Context, query string, storeName string, opts *filesearch.RetrievalOptions) ([]*filesearch.RetrievedChunk, error) {
+	matches, err := vectorindex.RetrieveMatches(ctx, p.retriever, query, p.cfg.TopK, opts)
+	if err != nil {
+		return nil, err
+	}
+	return vectorindex.RetrievedChunks(matches), nil
+}
@@ -0,0 +1,49 @@
+package apikeys_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rag/apikeys"
+	"rag/auth"
+)
+
+func Example() {
+	dir, err := os.MkdirTemp("", "apikeys")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := apikeys.Open(filepath.Join(dir, "keys.db"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	secret, key, err := store.Issue("hr-portal", []auth.Role{auth.RoleReader}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, role, ok := store.Lookup(secret)
+	fmt.Println(role, ok)
+
+	// Rotation invalidates the old secret
+	rotated, _, err := store.Rotate(key.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, _, ok = store.Lookup(secret)
+	fmt.Println("old secret valid:", ok)
+
+	// Revoked keys stop working
+	store.Revoke(key.ID)
+	_, _, ok = store.Lookup(rotated)
+	fmt.Println("rotated secret valid after revoke:", ok)
+	// Output:
+	// reader true
+	// old secret valid: false
+	// rotated secret valid after revoke: false
+}
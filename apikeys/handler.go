@@ -0,0 +1,111 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"rag/auth"
+)
+
+// IssueRequest represents a request to issue a key
+type IssueRequest struct {
+	Name      string      `json:"name"`
+	Scopes    []auth.Role `json:"scopes"`
+	ExpiresIn string      `json:"expiresIn,omitempty"` // Go duration, e.g. "720h"; empty never expires
+}
+
+// KeyResponse returns a key, with its plaintext secret only after issuance or rotation
+type KeyResponse struct {
+	Key    *Key   `json:"key,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Handler provides the HTTP admin API for API key management
+type Handler struct {
+	store *Store
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(store *Store) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+// List handles GET requests listing all keys
+// GET /admin/keys
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.store.List()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, KeyResponse{Error: err.Error()})
+		return
+	}
+	if keys == nil {
+		keys = []*Key{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+// Issue handles POST requests issuing a key
+// POST /admin/keys
+// Body: {"name": "hr-portal", "scopes": ["reader"], "expiresIn": "2160h"}
+func (h *Handler) Issue(w http.ResponseWriter, r *http.Request) {
+	var req IssueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, KeyResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.ExpiresIn); err != nil || ttl <= 0 {
+			writeJSON(w, http.StatusBadRequest, KeyResponse{Error: "expiresIn must be a positive duration"})
+			return
+		}
+	}
+
+	secret, key, err := h.store.Issue(req.Name, req.Scopes, ttl)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, KeyResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, KeyResponse{Key: key, Secret: secret})
+}
+
+// Rotate handles POST requests replacing the secret of a key
+// POST /admin/keys/{id}/rotate
+func (h *Handler) Rotate(w http.ResponseWriter, r *http.Request) {
+	secret, key, err := h.store.Rotate(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, statusFor(err), KeyResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, KeyResponse{Key: key, Secret: secret})
+}
+
+// Revoke handles DELETE requests revoking a key
+// DELETE /admin/keys/{id}
+func (h *Handler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Revoke(r.PathValue("id")); err != nil {
+		writeJSON(w, statusFor(err), KeyResponse{Error: err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func statusFor(err error) int {
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,289 @@
+// Package apikeys issues, rotates and revokes server API keys. Keys are stored hashed in SQLite;
+// the plaintext is only returned when a key is issued or rotated.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rag/auth"
+
+	_ "modernc.org/sqlite"
+)
+
+// keyPrefix marks server API keys so they are recognizable in logs and secret scanners
+const keyPrefix = "cao_"
+
+// ErrNotFound is returned for unknown key IDs
+var ErrNotFound = errors.New("api key not found")
+
+const schema = `CREATE TABLE IF NOT EXISTS api_keys (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL,
+	hash         TEXT NOT NULL UNIQUE,
+	scopes       TEXT NOT NULL,
+	created_at   INTEGER NOT NULL,
+	expires_at   INTEGER,
+	revoked_at   INTEGER,
+	last_used_at INTEGER
+)`
+
+// Key is a managed API key. The secret itself is never stored.
+type Key struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	Prefix     string      `json:"prefix"` // First characters of the key, to recognize it
+	Scopes     []auth.Role `json:"scopes"` // Roles the key grants; the highest one applies
+	CreatedAt  time.Time   `json:"createdAt"`
+	ExpiresAt  *time.Time  `json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time  `json:"revokedAt,omitempty"`
+	LastUsedAt *time.Time  `json:"lastUsedAt,omitempty"`
+}
+
+// Active reports whether the key is neither revoked nor expired at now
+func (k *Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Role returns the highest role among the key scopes
+func (k *Key) Role() auth.Role {
+	var best auth.Role
+	for _, scope := range k.Scopes {
+		if best == "" || scope.Allows(best) {
+			best = scope
+		}
+	}
+	return best
+}
+
+// Store keeps API keys in a SQLite database
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// Open opens, or creates, the key database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key database: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create key table: %w", err)
+	}
+
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Issue creates a key with the given scopes. ttl of zero never expires.
+// The returned plaintext key cannot be retrieved later.
+func (s *Store) Issue(name string, scopes []auth.Role, ttl time.Duration) (string, *Key, error) {
+	if name == "" {
+		return "", nil, fmt.Errorf("key name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if _, err := auth.ParseRole(string(scope)); err != nil {
+			return "", nil, err
+		}
+		names = append(names, string(scope))
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := s.now().UTC().Truncate(time.Second)
+	key := &Key{
+		ID:        id,
+		Name:      name,
+		Prefix:    secret[:len(keyPrefix)+6],
+		Scopes:    scopes,
+		CreatedAt: now,
+	}
+	var expires *int64
+	if ttl > 0 {
+		at := now.Add(ttl)
+		key.ExpiresAt = &at
+		expires = unix(&at)
+	}
+
+	_, err = s.db.Exec(`INSERT INTO api_keys (id, name, prefix, hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.Name, key.Prefix, hash(secret), strings.Join(names, ","), now.Unix(), expires)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to store key: %w", err)
+	}
+
+	return secret, key, nil
+}
+
+// List returns all keys, including revoked and expired ones, newest first
+func (s *Store) List() ([]*Key, error) {
+	rows, err := s.db.Query(`SELECT id, name, prefix, scopes, created_at, expires_at, revoked_at, last_used_at
+		FROM api_keys ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*Key
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Get returns a key by ID
+func (s *Store) Get(id string) (*Key, error) {
+	row := s.db.QueryRow(`SELECT id, name, prefix, scopes, created_at, expires_at, revoked_at, last_used_at
+		FROM api_keys WHERE id = ?`, id)
+	key, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return key, err
+}
+
+// Rotate replaces the secret of an active key, keeping its ID, name, scopes and expiry.
+// The old secret stops working immediately.
+func (s *Store) Rotate(id string) (string, *Key, error) {
+	key, err := s.Get(id)
+	if err != nil {
+		return "", nil, err
+	}
+	if !key.Active(s.now()) {
+		return "", nil, fmt.Errorf("api key %s is revoked or expired", id)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	key.Prefix = secret[:len(keyPrefix)+6]
+
+	if _, err := s.db.Exec(`UPDATE api_keys SET hash = ?, prefix = ? WHERE id = ?`, hash(secret), key.Prefix, id); err != nil {
+		return "", nil, fmt.Errorf("failed to rotate key: %w", err)
+	}
+	return secret, key, nil
+}
+
+// Revoke disables a key. Revoked keys are kept for auditing.
+func (s *Store) Revoke(id string) error {
+	res, err := s.db.Exec(`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, s.now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Lookup resolves a plaintext key to its subject and role. It implements auth.KeyStore.
+func (s *Store) Lookup(secret string) (string, auth.Role, bool) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return "", "", false
+	}
+
+	row := s.db.QueryRow(`SELECT id, name, prefix, scopes, created_at, expires_at, revoked_at, last_used_at
+		FROM api_keys WHERE hash = ?`, hash(secret))
+	key, err := scanKey(row)
+	if err != nil {
+		return "", "", false
+	}
+	now := s.now()
+	if !key.Active(now) {
+		return "", "", false
+	}
+
+	s.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now.Unix(), key.ID)
+	return "apikey:" + key.ID, key.Role(), true
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKey(row scanner) (*Key, error) {
+	var (
+		key                          Key
+		scopes                       string
+		created                      int64
+		expires, revoked, lastUsedAt sql.NullInt64
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &scopes, &created, &expires, &revoked, &lastUsedAt); err != nil {
+		return nil, err
+	}
+
+	for _, scope := range strings.Split(scopes, ",") {
+		key.Scopes = append(key.Scopes, auth.Role(scope))
+	}
+	key.CreatedAt = time.Unix(created, 0).UTC()
+	key.ExpiresAt = fromUnix(expires)
+	key.RevokedAt = fromUnix(revoked)
+	key.LastUsedAt = fromUnix(lastUsedAt)
+	return &key, nil
+}
+
+func newSecret() (string, error) {
+	random, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	return keyPrefix + random, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hash returns the stored form of a key. Keys are long random strings, so a fast hash suffices.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func unix(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	u := t.Unix()
+	return &u
+}
+
+func fromUnix(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0).UTC()
+	return &t
+}
@@ -0,0 +1,38 @@
+package auth_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	"rag/auth"
+)
+
+func ExampleAuthenticator_Require() {
+	roles, err := auth.LoadRoleStore("", []byte("roles-secret"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	roles.SetKey("ops-key", auth.RoleAdmin)
+
+	secret := []byte("secret")
+	authenticator := auth.NewAuthenticator(roles, auth.WithJWTSecret(secret))
+	usage := authenticator.Require(auth.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
+		fmt.Fprintf(w, "usage report for %s", identity.Role)
+	})
+
+	token, _ := auth.SignJWT(&auth.Claims{Subject: "jan@example.com", Role: "reader"}, secret)
+	for _, credential := range []string{"ops-key", token, "unknown"} {
+		req := httptest.NewRequest("GET", "/admin/usage", nil)
+		req.Header.Set("Authorization", "Bearer "+credential)
+		rec := httptest.NewRecorder()
+		usage(rec, req)
+		fmt.Println(rec.Code)
+	}
+	// Output:
+	// 200
+	// 403
+	// 401
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// RoleRequest assigns a role. Either Subject or Key is set; a key is stored by its digest.
+type RoleRequest struct {
+	Subject string `json:"subject,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Role    Role   `json:"role"`
+}
+
+// Handler provides the HTTP admin API for role management
+type Handler struct {
+	roles *RoleStore
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(roles *RoleStore) *Handler {
+	return &Handler{
+		roles: roles,
+	}
+}
+
+// ListRoles handles GET requests listing all role assignments
+// GET /admin/roles
+func (h *Handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"roles": h.roles.List(),
+	})
+}
+
+// SetRole handles POST requests assigning a role
+// POST /admin/roles
+// Body: {"key": "api-key", "role": "ingester"} or {"subject": "jwt-subject", "role": "reader"}
+func (h *Handler) SetRole(w http.ResponseWriter, r *http.Request) {
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	subject := req.Subject
+	var err error
+	switch {
+	case req.Key != "":
+		subject, err = h.roles.SetKey(req.Key, req.Role)
+	case subject != "":
+		err = h.roles.Set(subject, req.Role)
+	default:
+		writeError(w, http.StatusBadRequest, "Subject or key is required")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&Assignment{Subject: subject, Role: req.Role})
+}
+
+// DeleteRole handles DELETE requests revoking the role of a subject
+// DELETE /admin/roles/{subject}
+func (h *Handler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := h.roles.Delete(r.PathValue("subject")); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
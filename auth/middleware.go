@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrUnauthenticated is returned when a request carries no valid credentials
+var ErrUnauthenticated = errors.New("a valid API key or token is required")
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	Subject string `json:"subject"` // RoleStore.KeyID of the API key, or the JWT subject
+	Role    Role   `json:"role"`
+}
+
+// KeyStore resolves managed API keys to a subject and role
+type KeyStore interface {
+	Lookup(key string) (subject string, role Role, ok bool)
+}
+
+// Authenticator resolves the identity and role of requests
+type Authenticator struct {
+	roles  *RoleStore
+	keys   KeyStore
+	secret []byte
+	known  func(key string) bool
+	now    func() time.Time
+}
+
+// Option configures optional Authenticator behavior
+type Option func(*Authenticator)
+
+// WithJWTSecret accepts HS256 JWTs signed with secret. The role comes from the "role" claim,
+// or from the role store for the token subject.
+func WithJWTSecret(secret []byte) Option {
+	return func(a *Authenticator) {
+		a.secret = secret
+	}
+}
+
+// WithKnownKeys accepts API keys without a role assignment as readers when known reports them valid,
+// e.g. the keys of configured tenants
+func WithKnownKeys(known func(key string) bool) Option {
+	return func(a *Authenticator) {
+		a.known = known
+	}
+}
+
+// WithKeyStore accepts the managed API keys of a key store, with the role of their scopes
+func WithKeyStore(keys KeyStore) Option {
+	return func(a *Authenticator) {
+		a.keys = keys
+	}
+}
+
+// NewAuthenticator creates an authenticator using the role store for API keys and JWT subjects
+func NewAuthenticator(roles *RoleStore, opts ...Option) *Authenticator {
+	a := &Authenticator{
+		roles: roles,
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authenticate returns the identity of a request. Credentials are sent as "X-API-Key: <key>"
+// or "Authorization: Bearer <key or JWT>".
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	credential := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); credential == "" && strings.HasPrefix(auth, "Bearer ") {
+		credential = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if credential == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	// JWTs have three dot-separated segments; API keys have none
+	if a.secret != nil && strings.Count(credential, ".") == 2 {
+		claims, err := ParseJWT(credential, a.secret, a.now())
+		if err != nil {
+			return nil, err
+		}
+		if claims.Role != "" {
+			role, err := ParseRole(claims.Role)
+			if err != nil {
+				return nil, err
+			}
+			return &Identity{Subject: claims.Subject, Role: role}, nil
+		}
+		if role, ok := a.roles.Get(claims.Subject); ok {
+			return &Identity{Subject: claims.Subject, Role: role}, nil
+		}
+		return nil, ErrUnauthenticated
+	}
+
+	if a.keys != nil {
+		if subject, role, ok := a.keys.Lookup(credential); ok {
+			return &Identity{Subject: subject, Role: role}, nil
+		}
+	}
+
+	subject, role, ok := a.roles.Key(credential)
+	if ok {
+		return &Identity{Subject: subject, Role: role}, nil
+	}
+	if a.known != nil && a.known(credential) {
+		return &Identity{Subject: subject, Role: RoleReader}, nil
+	}
+	return nil, ErrUnauthenticated
+}
+
+type contextKey struct{}
+
+// IdentityFromContext returns the identity of a request passed through Require
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok
+}
+
+// Require only lets requests through whose role grants the access of role
+func (a *Authenticator) Require(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if !identity.Role.Allows(role) {
+			writeError(w, http.StatusForbidden, "This endpoint requires the "+string(role)+" role")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, identity)))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
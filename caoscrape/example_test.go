@@ -42,7 +42,7 @@ func Example() {
 	}
 }
 
-func ExampleSearchAll() {
+func Example_searchAll() {
 	// Create a new client
 	client := caoscrape.NewClient()
 
@@ -2,10 +2,18 @@ package caoscrape
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"rag/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -57,18 +65,54 @@ type SearchResult struct {
 // Client handles requests to the CAO search API
 type Client struct {
 	httpClient *http.Client
+	logger     *slog.Logger
+	tracer     trace.Tracer
 }
 
-// NewClient creates a new CAO scraper client
+// NewClient creates a new CAO scraper client logging to the default logger and tracing with
+// the global tracer provider
 func NewClient() *Client {
 	return &Client{
 		httpClient: &http.Client{},
+		logger:     slog.Default(),
+		tracer:     telemetry.Tracer(nil),
 	}
 }
 
+// WithTracerProvider returns a copy of the client recording its requests as spans of tp
+func (c *Client) WithTracerProvider(tp trace.TracerProvider) *Client {
+	clone := *c
+	clone.tracer = telemetry.Tracer(tp)
+	return &clone
+}
+
+// WithLogger returns a copy of the client logging to logger
+func (c *Client) WithLogger(logger *slog.Logger) *Client {
+	clone := *c
+	clone.logger = logger
+	return &clone
+}
+
 // Search searches for documents by JC number
 // jc parameter can be nil to search all documents
 func (c *Client) Search(jc *int) ([]string, error) {
+	return c.SearchContext(context.Background(), jc)
+}
+
+// SearchContext is Search as part of the trace in ctx
+func (c *Client) SearchContext(ctx context.Context, jc *int) ([]string, error) {
+	var attrs []attribute.KeyValue
+	if jc != nil {
+		attrs = append(attrs, telemetry.JCKey.Int(*jc))
+	}
+	ctx, span := c.tracer.Start(ctx, "caoscrape.search", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	urls, err := c.search(ctx, jc)
+	span.SetAttributes(telemetry.DocumentsKey.Int(len(urls)))
+	telemetry.End(span, err)
+	return urls, err
+}
+
+func (c *Client) search(ctx context.Context, jc *int) ([]string, error) {
 	// Build search request
 	req := SearchRequest{
 		Lang:           "nl",
@@ -120,7 +164,7 @@ func (c *Client) Search(jc *int) ([]string, error) {
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", searchURL, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", searchURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -129,13 +173,20 @@ func (c *Client) Search(jc *int) ([]string, error) {
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	// Execute request
+	logger := c.logger
+	if jc != nil {
+		logger = logger.With("jc", *jc)
+	}
+	start := time.Now()
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
+		logger.Debug("Search failed", "duration", time.Since(start), "error", err)
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		logger.Debug("Search failed", "duration", time.Since(start), "status", resp.StatusCode)
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
@@ -160,13 +211,27 @@ func (c *Client) Search(jc *int) ([]string, error) {
 		}
 	}
 
+	logger.Debug("Searched documents", "documents", len(documentURLs), "duration", time.Since(start))
 	return documentURLs, nil
 }
 
 // DownloadDocument downloads a document from the given URL and returns it as an io.Reader
 func (c *Client) DownloadDocument(url string) (io.Reader, error) {
+	return c.DownloadDocumentContext(context.Background(), url)
+}
+
+// DownloadDocumentContext is DownloadDocument as part of the trace in ctx
+func (c *Client) DownloadDocumentContext(ctx context.Context, url string) (io.Reader, error) {
+	ctx, span := c.tracer.Start(ctx, "caoscrape.download", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", url)))
+	reader, err := c.download(ctx, url)
+	telemetry.End(span, err)
+	return reader, err
+}
+
+func (c *Client) download(ctx context.Context, url string) (io.Reader, error) {
 	// Create request with proper headers
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -180,13 +245,17 @@ func (c *Client) DownloadDocument(url string) (io.Reader, error) {
 	req.Header.Set("Origin", "https://public-search.werk.belgie.be")
 	req.Header.Set("Connection", "keep-alive")
 
+	logger := c.logger.With("url", url)
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		logger.Debug("Download failed", "duration", time.Since(start), "error", err)
 		return nil, fmt.Errorf("failed to download document: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		logger.Debug("Download failed", "duration", time.Since(start), "status", resp.StatusCode)
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
@@ -197,5 +266,6 @@ func (c *Client) DownloadDocument(url string) (io.Reader, error) {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
+	logger.Debug("Downloaded document", "bytes", len(data), "duration", time.Since(start))
 	return bytes.NewReader(data), nil
 }
@@ -0,0 +1,47 @@
+package chunkfeedback_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rag/chunkfeedback"
+)
+
+func Example() {
+	dir, err := os.MkdirTemp("", "chunkfeedback")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := chunkfeedback.Open(filepath.Join(dir, "feedback.db"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	old := "Het minimumuurloon bedraagt 12,10 EUR."
+	f, err := store.Mark("302-2019-013347.pdf", old, chunkfeedback.Misleading, "Superseded by the 2024 wage table")
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.Mark("302-2024-003311.pdf", "Het minimumuurloon bedraagt 14,05 EUR.", chunkfeedback.Authoritative, "")
+
+	// Chunks are matched by document and text, whatever their whitespace
+	fmt.Println(store.Boost("302-2019-013347.pdf", "Het minimumuurloon\nbedraagt 12,10 EUR."))
+	fmt.Println(store.Boost("302-2024-003311.pdf", "Het minimumuurloon bedraagt 14,05 EUR."))
+	fmt.Println(store.Boost("302-2024-003311.pdf", "De eindejaarspremie bedraagt één maandloon."))
+
+	// Cleared feedback no longer applies
+	if err := store.Clear(f.ID); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(store.Boost("302-2019-013347.pdf", old))
+	// Output:
+	// -0.2
+	// 0.1
+	// 0
+	// 0
+}
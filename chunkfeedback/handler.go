@@ -0,0 +1,83 @@
+package chunkfeedback
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// MarkRequest represents a request to mark a chunk, as listed by /debug/retrieve
+type MarkRequest struct {
+	FileName string `json:"fileName"`
+	Text     string `json:"text"`
+	Verdict  string `json:"verdict"` // Authoritative or Misleading
+	Note     string `json:"note,omitempty"`
+}
+
+// Handler provides the HTTP admin API for chunk feedback
+type Handler struct {
+	store *Store
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(store *Store) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+// List handles GET requests listing the marked chunks, most recent first
+// GET /admin/chunks/feedback
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	feedback, err := h.store.List()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if feedback == nil {
+		feedback = []*Feedback{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"feedback": feedback})
+}
+
+// Mark handles POST requests marking a chunk authoritative or misleading; the local retrieval
+// backends rank it accordingly from the next query on
+// POST /admin/chunks/feedback
+// Body: {"fileName": "302-2024-003311.pdf", "text": "...", "verdict": "misleading", "note": "Outdated wage table"}
+func (h *Handler) Mark(w http.ResponseWriter, r *http.Request) {
+	var req MarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	f, err := h.store.Mark(req.FileName, req.Text, req.Verdict, req.Note)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, f)
+}
+
+// Clear handles DELETE requests removing the feedback on a chunk
+// DELETE /admin/chunks/feedback/{id}
+func (h *Handler) Clear(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Clear(r.PathValue("id")); err != nil {
+		writeJSON(w, statusFor(err), map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func statusFor(err error) int {
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,223 @@
+// Package chunkfeedback keeps the feedback of admins on retrieved chunks in SQLite: a chunk
+// marked authoritative is ranked higher by the local retrieval backends on later queries, one
+// marked misleading lower, so a chunk that keeps steering answers wrong can be pushed out of
+// the context without rebuilding the index. Chunks are identified by filesearch.ChunkID, as
+// listed by /debug/retrieve.
+package chunkfeedback
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+
+	_ "modernc.org/sqlite"
+)
+
+// Verdicts on a chunk
+const (
+	Authoritative = "authoritative"
+	Misleading    = "misleading"
+)
+
+// Boosts added to the cosine similarity of marked chunks
+const (
+	AuthoritativeBoost = 0.1
+	MisleadingBoost    = -0.2
+)
+
+// refreshInterval is how long feedback is cached, so replicas sharing a database see each
+// other's changes
+const refreshInterval = time.Minute
+
+// ErrNotFound is returned when a chunk has no feedback
+var ErrNotFound = errors.New("chunk feedback not found")
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS chunk_feedback (
+	id         TEXT PRIMARY KEY,
+	file_name  TEXT NOT NULL,
+	text       TEXT NOT NULL,
+	verdict    TEXT NOT NULL,
+	note       TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+)`,
+}
+
+// Feedback is the verdict of an admin on a chunk
+type Feedback struct {
+	ID        string    `json:"id"` // See filesearch.ChunkID
+	FileName  string    `json:"fileName"`
+	Text      string    `json:"text"`
+	Verdict   string    `json:"verdict"`        // Authoritative or Misleading
+	Note      string    `json:"note,omitempty"` // Why the chunk was marked
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Boost returns what the verdict adds to the similarity of the chunk
+func (f *Feedback) Boost() float64 {
+	if f.Verdict == Authoritative {
+		return AuthoritativeBoost
+	}
+	return MisleadingBoost
+}
+
+// Store keeps chunk feedback in a SQLite database
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+
+	mu       sync.RWMutex
+	boosts   map[string]map[string]float64 // file name -> chunk ID -> boost
+	loadedAt time.Time
+}
+
+var _ filesearch.ChunkBoosts = (*Store)(nil)
+
+// Open opens, or creates, the feedback database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chunk feedback database: %w", err)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create chunk feedback tables: %w", err)
+		}
+	}
+
+	s := &Store{db: db, now: time.Now}
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Mark records a verdict on a chunk of a document, replacing an earlier one
+func (s *Store) Mark(fileName string, text string, verdict string, note string) (*Feedback, error) {
+	if fileName == "" || strings.TrimSpace(text) == "" {
+		return nil, errors.New("fileName and text of the chunk are required")
+	}
+	if verdict != Authoritative && verdict != Misleading {
+		return nil, fmt.Errorf("verdict must be %s or %s", Authoritative, Misleading)
+	}
+
+	f := &Feedback{
+		ID:        filesearch.ChunkID(fileName, text),
+		FileName:  fileName,
+		Text:      text,
+		Verdict:   verdict,
+		Note:      note,
+		CreatedAt: s.now().UTC().Truncate(time.Second),
+	}
+	_, err := s.db.Exec(`INSERT INTO chunk_feedback (id, file_name, text, verdict, note, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET verdict = excluded.verdict, note = excluded.note, created_at = excluded.created_at`,
+		f.ID, f.FileName, f.Text, f.Verdict, f.Note, f.CreatedAt.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save chunk feedback: %w", err)
+	}
+	return f, s.load()
+}
+
+// Clear removes the feedback on a chunk
+func (s *Store) Clear(id string) error {
+	res, err := s.db.Exec(`DELETE FROM chunk_feedback WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to clear chunk feedback: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return s.load()
+}
+
+// List returns the feedback on every chunk, most recent first
+func (s *Store) List() ([]*Feedback, error) {
+	rows, err := s.db.Query(`SELECT id, file_name, text, verdict, note, created_at FROM chunk_feedback ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk feedback: %w", err)
+	}
+	defer rows.Close()
+
+	var feedback []*Feedback
+	for rows.Next() {
+		var f Feedback
+		var created int64
+		if err := rows.Scan(&f.ID, &f.FileName, &f.Text, &f.Verdict, &f.Note, &created); err != nil {
+			return nil, fmt.Errorf("failed to list chunk feedback: %w", err)
+		}
+		f.CreatedAt = time.Unix(created, 0).UTC()
+		feedback = append(feedback, &f)
+	}
+	return feedback, rows.Err()
+}
+
+// Boost returns what the feedback on a chunk adds to its similarity, zero without feedback.
+// It implements filesearch.ChunkBoosts.
+func (s *Store) Boost(fileName string, text string) float64 {
+	if s.claimRefresh() {
+		if err := s.load(); err != nil {
+			log.Printf("Warning: failed to reload chunk feedback: %v", err)
+		}
+	}
+
+	s.mu.RLock()
+	chunks := s.boosts[fileName]
+	s.mu.RUnlock()
+	if len(chunks) == 0 {
+		return 0
+	}
+	return chunks[filesearch.ChunkID(fileName, text)]
+}
+
+// claimRefresh reports whether the cache is stale and the caller is to reload it; concurrent
+// callers do not all reload, and a failed reload is retried after refreshInterval
+func (s *Store) claimRefresh() bool {
+	s.mu.RLock()
+	stale := s.now().Sub(s.loadedAt) > refreshInterval
+	s.mu.RUnlock()
+	if !stale {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.now().Sub(s.loadedAt) <= refreshInterval {
+		return false
+	}
+	s.loadedAt = s.now()
+	return true
+}
+
+// load caches the boosts of every marked chunk
+func (s *Store) load() error {
+	feedback, err := s.List()
+	if err != nil {
+		return err
+	}
+	boosts := make(map[string]map[string]float64)
+	for _, f := range feedback {
+		if boosts[f.FileName] == nil {
+			boosts[f.FileName] = make(map[string]float64)
+		}
+		boosts[f.FileName][f.ID] = f.Boost()
+	}
+
+	s.mu.Lock()
+	s.boosts = boosts
+	s.loadedAt = s.now()
+	s.mu.Unlock()
+	return nil
+}
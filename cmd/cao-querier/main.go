@@ -1,49 +1,108 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"rag/filesearch"
+	"rag/logging"
+	"rag/providers"
 	"strings"
 
 	"google.golang.org/genai"
 )
 
 func main() {
+	logging.Setup()
+
 	// Check if query is provided
 	if len(os.Args) < 2 {
 		fmt.Fprintf(os.Stderr, "Usage: %s \"your question here\"\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -chat\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -file document.pdf \"your question here\"\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nExample:\n")
 		fmt.Fprintf(os.Stderr, "  %s \"Wat is het minimumloon als je 17 jaar bent?\"\n", os.Args[0])
 		os.Exit(1)
 	}
 
-	// Get query from command line arguments (join all args in case user didn't quote)
-	query := strings.Join(os.Args[1:], " ")
+	// Get query from command line arguments (join all args in case user didn't quote);
+	// -chat asks follow-up questions read from stdin instead, -file asks about a single document
+	args := os.Args[1:]
+	var file string
+	if args[0] == "-file" {
+		if len(args) < 3 {
+			logging.Fatal("-file requires a document and a question")
+		}
+		file, args = args[1], args[2:]
+	}
+	query := strings.Join(args, " ")
+	chat := query == "-chat"
 
 	ctx := context.Background()
+
+	// Answer on-prem from the local vector index with PROVIDER=ollama
+	if os.Getenv("PROVIDER") == "ollama" {
+		if chat || file != "" {
+			logging.Fatal("-chat and -file are not available with PROVIDER=ollama")
+		}
+		queryLocal(ctx, query)
+		return
+	}
+
 	apiKey := os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
-		log.Fatal("GEMINI_API_KEY environment variable not set")
+		logging.Fatal("GEMINI_API_KEY environment variable not set")
+	}
+
+	// Answer with the profile of the store when STORE_PROFILES is set
+	var profiles *filesearch.Profiles
+	if path := os.Getenv("STORE_PROFILES"); path != "" {
+		var err error
+		if profiles, err = filesearch.LoadProfiles(path); err != nil {
+			logging.Fatal("Invalid STORE_PROFILES", "error", err)
+		}
+	}
+
+	// Refuse answers that break the citation policy when CITATION_POLICY is set
+	var policy *filesearch.CitationPolicy
+	if setting := os.Getenv("CITATION_POLICY"); setting != "" {
+		var err error
+		if policy, err = filesearch.ParseCitationPolicy(setting); err != nil {
+			logging.Fatal("Invalid CITATION_POLICY", "error", err)
+		}
+		policy.Refusal = os.Getenv("CITATION_REFUSAL")
 	}
 
 	// Create the file search service
 	service, err := filesearch.NewService(ctx, &filesearch.Config{
-		APIKey:    apiKey,
-		ModelName: "gemini-2.5-flash",
-		Backend:   genai.BackendGeminiAPI,
+		APIKey:         apiKey,
+		ModelName:      "gemini-2.5-flash",
+		Backend:        genai.BackendGeminiAPI,
+		Profiles:       profiles,
+		CitationPolicy: policy,
 	})
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	if file != "" {
+		queryAttachment(ctx, service, file, query)
+		return
 	}
 
 	// Get the store
 	storeName := "cao-documents"
 	store, err := service.GetStoreByName(ctx, storeName)
 	if err != nil {
-		log.Fatalf("Store '%s' not found. Please run cao-uploader first to upload documents.\n", storeName)
+		logging.Fatal("Store not found, run cao-uploader first to upload documents", "store", storeName)
+	}
+
+	if chat {
+		chatLoop(ctx, service.NewChat(store.Name, nil))
+		return
 	}
 
 	// Query the documents
@@ -51,41 +110,75 @@ func main() {
 
 	resp, err := service.Prompt(ctx, query, store.Name)
 	if err != nil {
-		log.Fatalf("Failed to query: %v", err)
+		logging.Fatal("Failed to query", "store", store.Name, "error", err)
 	}
 
-	// Print response
+	// Print the answer with numbered sources, marking the sentences each one supports
 	fmt.Println("=== Answer ===")
-	for _, part := range resp.Parts {
-		fmt.Println(part)
+	filesearch.MarkSources(resp)
+	fmt.Println(filesearch.RenderMarkdown(resp))
+}
+
+// chatLoop answers the questions read from stdin, one per line, as a conversation
+func chatLoop(ctx context.Context, chat *filesearch.ChatSession) {
+	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Print("> ")
+	for scanner.Scan() {
+		question := strings.TrimSpace(scanner.Text())
+		if question != "" {
+			resp, err := chat.Send(ctx, question)
+			if err != nil {
+				slog.Error("Failed to query", "error", err)
+			} else {
+				filesearch.MarkSources(resp)
+				fmt.Printf("\n%s\n\n", filesearch.RenderMarkdown(resp))
+			}
+		}
+		fmt.Print("> ")
 	}
+	fmt.Println()
+}
 
-	// Print grounding metadata
-	if resp.GroundingSupport != nil && len(resp.GroundingSupport.GroundingChunks) > 0 {
-		fmt.Printf("\n=== Sources (%d) ===\n", len(resp.GroundingSupport.GroundingChunks))
+// queryAttachment answers the query about a single document, uploaded with the Files API
+// instead of searched in the store
+func queryAttachment(ctx context.Context, service *filesearch.Service, path string, query string) {
+	f, err := os.Open(path)
+	if err != nil {
+		logging.Fatal("Failed to open document", "error", err)
+	}
+	defer f.Close()
 
-		// Deduplicate sources by filename
-		seenFiles := make(map[string]bool)
-		sourceCount := 0
+	attachment, err := service.UploadAttachment(ctx, f, filepath.Base(path), "")
+	if err != nil {
+		logging.Fatal("Failed to upload document", "document", path, "error", err)
+	}
+	defer service.DeleteAttachment(ctx, attachment.Name)
 
-		for _, chunk := range resp.GroundingSupport.GroundingChunks {
-			if chunk.File != nil && !seenFiles[chunk.File.FileName] {
-				sourceCount++
-				seenFiles[chunk.File.FileName] = true
-				fmt.Printf("%d. %s\n", sourceCount, chunk.File.FileName)
-			}
-		}
+	fmt.Printf("Querying %s: %s\n\n", attachment.DisplayName, query)
+
+	resp, err := service.PromptWithAttachment(ctx, query, attachment, nil)
+	if err != nil {
+		logging.Fatal("Failed to query", "document", attachment.Name, "error", err)
 	}
 
-	// Print citations if available
-	if len(resp.Citations) > 0 {
-		fmt.Printf("\n=== Citations (%d) ===\n", len(resp.Citations))
-		for i, citation := range resp.Citations {
-			fmt.Printf("%d. Characters %d-%d", i+1, citation.StartIndex, citation.EndIndex)
-			if len(citation.Sources) > 0 {
-				fmt.Printf(" - %s", citation.Sources[0].Title)
-			}
-			fmt.Println()
-		}
+	fmt.Println("=== Answer ===")
+	fmt.Println(resp.Text())
+}
+
+// queryLocal answers the query with Ollama from the vector index built by cao index build
+func queryLocal(ctx context.Context, query string) {
+	provider, err := providers.New(providers.ConfigFromEnv())
+	if err != nil {
+		logging.Fatal("Failed to create provider", "error", err)
+	}
+
+	fmt.Printf("Querying: %s\n\n", query)
+
+	resp, err := provider.PromptWithRetrieval(ctx, query, "", nil)
+	if err != nil {
+		logging.Fatal("Failed to query", "error", err)
 	}
+
+	fmt.Println("=== Answer ===")
+	fmt.Println(filesearch.RenderMarkdown(resp))
 }
@@ -2,19 +2,58 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"rag/analytics"
+	"rag/apikeys"
+	"rag/auth"
+	"rag/caoscrape"
+	"rag/chunkfeedback"
+	"rag/conversations"
+	"rag/doccache"
+	"rag/entities"
 	"rag/filesearch"
+	"rag/fulltext"
+	"rag/ingest"
+	"rag/kv"
+	"rag/langdetect"
+	"rag/leader"
+	"rag/localstore"
+	"rag/logging"
+	"rag/metrics"
+	"rag/monitor"
+	"rag/normalize"
+	"rag/preview"
+	"rag/prompts"
+	"rag/providers"
+	"rag/recovery"
+	"rag/retention"
+	"rag/storage"
+	"rag/telemetry"
+	"rag/usage"
+	"rag/wages"
+	"strconv"
+	"strings"
+	"time"
 
 	"google.golang.org/genai"
 )
 
 func main() {
-	// Get configuration from environment
+	logging.Setup()
+	instance := logging.WithInstance()
+
+	// Get configuration from environment; PROVIDER=ollama runs on-prem without Gemini
+	providerConfig := providers.ConfigFromEnv()
+	if err := providerConfig.Validate(); err != nil {
+		logging.Fatal("Invalid PROVIDER", "error", err)
+	}
+	onPrem := providerConfig.OnPrem()
+	backend := loadBackend()
 	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("GEMINI_API_KEY environment variable not set")
+	if apiKey == "" && !onPrem && backend != genai.BackendVertexAI {
+		logging.Fatal("GEMINI_API_KEY environment variable not set")
 	}
 
 	port := os.Getenv("PORT")
@@ -24,23 +63,527 @@ func main() {
 
 	// Create the file search service
 	ctx := context.Background()
-	service, err := filesearch.NewService(ctx, &filesearch.Config{
-		APIKey:    apiKey,
-		ModelName: "gemini-2.5-flash",
-		Backend:   genai.BackendGeminiAPI,
-	})
+	state := loadStateStore()
+
+	// Trace requests and the API calls they make when an OTLP endpoint is configured
+	tracerProvider, shutdownTracing, err := telemetry.Setup(ctx, "cao-server")
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+
+	// Replicas sharing STATE_STORE run background jobs on the elected replica only
+	background := func(name string, job func(ctx context.Context)) {
+		if state == nil {
+			go job(ctx)
+			return
+		}
+		go leader.New(state, name, instance, 0).Run(ctx, job)
+	}
+	// Document previews, original files and store renames read local copies first and fall back
+	// to the source URL, downloaded once into DOCUMENT_CACHE_DIR when set
+	fetchSource := preview.LocalOrSourceFetcher(os.Getenv("DOCUMENTS_DIR"), loadDocumentCache(ctx))
+
+	// Versioned system instructions per store, published and rolled back through /admin/prompts
+	var promptStore *prompts.Store
+	var templates filesearch.PromptTemplates
+	if path := os.Getenv("PROMPTS_DB"); path != "" {
+		promptStore, err = prompts.Open(path)
+		if err != nil {
+			logging.Fatal("Failed to open PROMPTS_DB", "error", err)
+		}
+		defer promptStore.Close()
+		templates = promptStore
+	}
+
+	var service *filesearch.Service
+	if !onPrem {
+		maxConcurrent, queueTimeout := loadConcurrency()
+		service, err = filesearch.NewService(ctx, &filesearch.Config{
+			APIKey:                   apiKey,
+			ModelName:                "gemini-2.5-flash",
+			Backend:                  backend,
+			Profiles:                 loadProfiles(),
+			Prompts:                  templates,
+			CitationPolicy:           loadCitationPolicy(),
+			ContextCacheTTL:          loadContextCacheTTL(),
+			ResponseCache:            loadResponseCache(state),
+			Retry:                    loadRetryPolicy(),
+			RequestsPerMinute:        loadPerMinute("GEMINI_REQUESTS_PER_MINUTE"),
+			UploadsPerMinute:         loadPerMinute("GEMINI_UPLOADS_PER_MINUTE"),
+			SharedLimits:             state,
+			MaxConcurrentGenerations: maxConcurrent,
+			GenerationQueueTimeout:   queueTimeout,
+			TracerProvider:           tracerProvider,
+			Originals:                filesearch.DocumentFetcher(fetchSource),
+		})
+		if err != nil {
+			logging.Fatal("Failed to create service", "error", err)
+		}
+	}
+
+	// Register the tools the model may call while answering
+	tools := filesearch.NewToolRegistry()
+	if err := filesearch.RegisterDateTools(tools); err != nil {
+		logging.Fatal("Failed to register date tools", "error", err)
+	}
+
+	// Wage tables are optional; without them the model answers wage questions from the documents only
+	var wageHandler *wages.Handler
+	if path := os.Getenv("WAGE_TABLES"); path != "" {
+		calculator, err := wages.LoadTables(path)
+		if err != nil {
+			logging.Fatal("Failed to load WAGE_TABLES", "error", err)
+		}
+		if err := wages.RegisterTool(tools, calculator); err != nil {
+			logging.Fatal("Failed to register wage tool", "error", err)
+		}
+		wageHandler = wages.NewHandler(calculator)
+	}
+
+	// Stop calling the backend during outages; optionally keep answering from recent answers
+	handlerOpts := []filesearch.HandlerOption{
+		filesearch.WithTools(tools),
+		filesearch.WithCircuitBreaker(filesearch.NewCircuitBreaker(0, 0)),
+	}
+	if os.Getenv("CACHED_FALLBACK") != "" {
+		cache := filesearch.NewAnswerCache(0, 0)
+		if state != nil {
+			cache = filesearch.NewSharedAnswerCache(state, 0, 0)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithCachedFallback(cache))
+	}
+	if os.Getenv("DECOMPOSE_QUESTIONS") != "" {
+		handlerOpts = append(handlerOpts, filesearch.WithDecomposition())
+	}
+	if os.Getenv("ANSWER_PROVENANCE") != "" {
+		handlerOpts = append(handlerOpts, filesearch.WithProvenance())
+	}
+
+	// Keep File Search resource names out of the responses
+	if policy := os.Getenv("GROUNDING_URIS"); policy != "" {
+		if err := filesearch.CheckURIPolicy(policy); err != nil {
+			logging.Fatal("Invalid GROUNDING_URIS", "error", err)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithURIPolicy(policy))
+	}
+
+	// Refuse questions outside the scope of the documents
+	if path := os.Getenv("QUERY_SCOPE"); path != "" {
+		scope, err := filesearch.LoadScope(path)
+		if err != nil {
+			logging.Fatal("Failed to load QUERY_SCOPE", "error", err)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithScope(scope))
+	}
+
+	// Render answers for the surfaces that ask, e.g. Slack or Telegram
+	if path := os.Getenv("CHANNEL_TEMPLATES"); path != "" {
+		channels, err := filesearch.LoadChannelTemplates(path)
+		if err != nil {
+			logging.Fatal("Failed to load CHANNEL_TEMPLATES", "error", err)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithChannels(channels))
+	}
+
+	// Limit the tokens a single conversation may use, counted in STATE_STORE when replicas
+	// share one
+	if budget := loadSessionBudget(); budget != nil {
+		sessions := state
+		if sessions == nil {
+			sessions = kv.NewMemory()
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithSessionBudget(budget, sessions))
+	}
+
+	// Keep the history of chat sessions server-side instead of trusting the one clients send
+	var chats *conversations.DB
+	if location := os.Getenv("CONVERSATIONS_DB"); location != "" {
+		chats, err = conversations.Open(location)
+		if err != nil {
+			logging.Fatal("Failed to open CONVERSATIONS_DB", "error", err)
+		}
+		defer chats.Close()
+		handlerOpts = append(handlerOpts, filesearch.WithConversations(conversations.NewRecorder(chats), func(r *http.Request) string {
+			identity, _ := auth.IdentityFromContext(r.Context())
+			if identity == nil {
+				return ""
+			}
+			return identity.Subject
+		}))
+	}
+
+	// Fix the spelling of legal terms and joint committee numbers before retrieval
+	if path := os.Getenv("QUERY_CORRECTIONS"); path != "" {
+		normalizer, err := normalize.Load(path)
+		if err != nil {
+			logging.Fatal("Failed to load QUERY_CORRECTIONS", "error", err)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithQueryNormalizer(normalizer))
+	} else if os.Getenv("NORMALIZE_QUERIES") != "" {
+		handlerOpts = append(handlerOpts, filesearch.WithQueryNormalizer(normalize.New(nil)))
+	}
+
+	// Close every answer with the legal disclaimer and the version dates of the cited documents
+	if disclaimer, versions := os.Getenv("LEGAL_DISCLAIMER"), os.Getenv("LEGAL_NOTICE_VERSIONS") != ""; disclaimer != "" || versions {
+		handlerOpts = append(handlerOpts, filesearch.WithLegalNotice(&filesearch.LegalNotice{
+			Disclaimer:    disclaimer,
+			VersionDates:  versions,
+			VersionsLabel: os.Getenv("LEGAL_NOTICE_VERSIONS_LABEL"),
+		}))
+	}
+
+	// Rank chunks admins marked authoritative or misleading up or down in the local backends
+	var chunkFeedback *chunkfeedback.Store
+	if path := os.Getenv("CHUNK_FEEDBACK_DB"); path != "" {
+		chunkFeedback, err = chunkfeedback.Open(path)
+		if err != nil {
+			logging.Fatal("Failed to open CHUNK_FEEDBACK_DB", "error", err)
+		}
+		defer chunkFeedback.Close()
+		providerConfig.Boosts = chunkFeedback
+	}
+
+	// Answer with another model from the local vector index; Gemini embeds the queries,
+	// except on-prem where Ollama embeds and answers
+	providerConfig.Service = service
+	answerer, err := providers.New(providerConfig)
+	if err != nil {
+		logging.Fatal("Failed to create provider", "error", err)
+	}
+	gemini := providerConfig.Name == "" || providerConfig.Name == providers.Gemini
+	// A local store replaces File Search altogether: stores and documents are listed and
+	// uploaded through it too
+	var searcher filesearch.FileSearcher = service
+	if local, ok := answerer.(*localstore.Service); ok {
+		searcher = local
+	} else if !gemini {
+		handlerOpts = append(handlerOpts, filesearch.WithProvider(answerer))
+	}
+
+	// Route questions that mention a sector to its store or documents
+	if path := os.Getenv("ENTITY_INDEX"); path != "" {
+		index, err := entities.LoadIndex(path)
+		if err != nil {
+			logging.Fatal("Failed to load ENTITY_INDEX", "error", err)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithRouter(index))
+	}
+
+	// Answer each question in its own language from the documents in that language
+	if stores := os.Getenv("LANGUAGE_STORES"); stores != "" {
+		routing := &filesearch.LanguageRouting{Stores: make(map[string]string)}
+		for _, pair := range strings.Split(stores, ",") {
+			lang, store, ok := strings.Cut(pair, "=")
+			if !ok {
+				logging.Fatal("Invalid LANGUAGE_STORES entry, expected lang=store", "entry", pair)
+			}
+			routing.Stores[strings.TrimSpace(lang)] = strings.TrimSpace(store)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithLanguageRouting(routing))
+	} else if filter := os.Getenv("LANGUAGE_FILTER"); filter != "" {
+		// The languages of the documents, e.g. nl,fr; other values filter on the default ones
+		routing := &filesearch.LanguageRouting{FilterKey: filesearch.MetadataLanguage}
+		for _, lang := range strings.Split(filter, ",") {
+			if lang = strings.TrimSpace(lang); langdetect.Name(lang) != "" {
+				routing.Languages = append(routing.Languages, lang)
+			}
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithLanguageRouting(routing))
+	}
+
+	// Build the keyword index over locally extracted documents, also used to find cited pages and articles
+	var searchHandler *fulltext.Handler
+	if dir := os.Getenv("DOCUMENTS_DIR"); dir != "" {
+		index, err := fulltext.BuildFromDir(dir)
+		if err != nil {
+			logging.Fatal("Failed to index DOCUMENTS_DIR", "error", err)
+		}
+		slog.Info("Indexed documents for keyword search", "documents", index.Len(), "dir", dir)
+		searchHandler = fulltext.NewHandler(index)
+		handlerOpts = append(handlerOpts, filesearch.WithPageLocator(index), filesearch.WithArticleLocator(index))
+	}
+
+	// Failed ingestions recorded by cao ingest and pipelines, and ingestion jobs started over HTTP
+	var deadLetters *ingest.DeadLetters
+	if dir := os.Getenv("DEAD_LETTER_DIR"); dir != "" {
+		deadLetters, err = ingest.OpenDeadLetters(dir)
+		if err != nil {
+			logging.Fatal("Failed to open DEAD_LETTER_DIR", "error", err)
+		}
+	}
+	var jobs *ingest.Jobs
+	if os.Getenv("INGEST_JOBS") != "" {
+		if service == nil || !gemini {
+			logging.Fatal("INGEST_JOBS requires Gemini File Search stores")
+		}
+		jobs = ingest.NewJobs(service, &ingest.JobOptions{
+			DeadLetters: deadLetters,
+			Quality:     &ingest.QualityOptions{},
+			Fetch:       caoscrape.NewClient().WithTracerProvider(tracerProvider).DownloadDocument,
+		})
+		// Store renames upload every document again, so they run as jobs too
+		handlerOpts = append(handlerOpts, filesearch.WithStoreRenamer(func(ctx context.Context, storeName string, displayName string) (string, error) {
+			return jobs.Rename(ctx, storeName, displayName).ID, nil
+		}))
+	}
+	jobsHandler := ingest.NewHandler(deadLetters, jobs)
+
+	// Enforce store retention policies in the background
+	if path := os.Getenv("RETENTION_POLICIES"); path != "" {
+		cfg, err := retention.LoadConfig(path)
+		if err != nil {
+			logging.Fatal("Failed to load RETENTION_POLICIES", "error", err)
+		}
+		interval := 24 * time.Hour
+		if v := os.Getenv("RETENTION_INTERVAL"); v != "" {
+			interval, err = time.ParseDuration(v)
+			if err != nil {
+				logging.Fatal("Invalid RETENTION_INTERVAL", "error", err)
+			}
+		}
+		if service == nil {
+			logging.Fatal("RETENTION_POLICIES requires Gemini File Search stores")
+		}
+		background("retention", func(ctx context.Context) {
+			retention.Schedule(ctx, service, cfg, interval)
+		})
+	}
+
+	// Ask canary questions periodically and alert when a store answers slowly or ungrounded
+	if path := os.Getenv("CANARIES"); path != "" {
+		suite, err := monitor.LoadCanaries(path)
+		if err != nil {
+			logging.Fatal("Failed to load CANARIES", "error", err)
+		}
+		interval := time.Hour
+		if v := os.Getenv("CANARY_INTERVAL"); v != "" {
+			interval, err = time.ParseDuration(v)
+			if err != nil {
+				logging.Fatal("Invalid CANARY_INTERVAL", "error", err)
+			}
+		}
+		var notifier monitor.CanaryNotifier = monitor.LogCanaryNotifier
+		if url := os.Getenv("CANARY_WEBHOOK"); url != "" {
+			webhook := monitor.CanaryWebhook(url, nil)
+			notifier = monitor.CanaryNotifierFunc(func(ctx context.Context, result *monitor.CanaryResult) error {
+				monitor.LogCanaryNotifier(ctx, result)
+				return webhook.NotifyCanary(ctx, result)
+			})
+		}
+		canaries := monitor.NewCanaryMonitor(suite, os.Getenv("CANARY_LOG"), notifier)
+		background("canaries", func(ctx context.Context) {
+			canaries.Schedule(ctx, answerer, interval)
+		})
+	}
+
+	// Account queries and tokens per tenant and enforce their quotas
+	var tracker *usage.Tracker
+	if path := os.Getenv("TENANTS"); path != "" {
+		cfg, err := usage.LoadConfig(path)
+		if err != nil {
+			logging.Fatal("Failed to load TENANTS", "error", err)
+		}
+		if state != nil {
+			tracker = usage.NewSharedTracker(cfg, state)
+		} else if tracker, err = usage.NewTracker(cfg, os.Getenv("USAGE_FILE")); err != nil {
+			logging.Fatal("Failed to load USAGE_FILE", "error", err)
+		} else {
+			background("usage", func(ctx context.Context) {
+				tracker.Persist(ctx, 30*time.Second)
+			})
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithUsageRecorder(tracker.RecordQueryUsage))
+	}
+
+	// Log answered queries with their feedback and cluster them into question themes
+	var analyticsHandler *analytics.Handler
+	if path := os.Getenv("QUERY_LOG"); path != "" {
+		queryLog, err := analytics.OpenLog(path, 0)
+		if err != nil {
+			logging.Fatal("Failed to open QUERY_LOG", "error", err)
+		}
+		interval, window := 24*time.Hour, 30*24*time.Hour
+		if v := os.Getenv("ANALYTICS_INTERVAL"); v != "" {
+			if interval, err = time.ParseDuration(v); err != nil {
+				logging.Fatal("Invalid ANALYTICS_INTERVAL", "error", err)
+			}
+		}
+		if v := os.Getenv("ANALYTICS_WINDOW"); v != "" {
+			if window, err = time.ParseDuration(v); err != nil {
+				logging.Fatal("Invalid ANALYTICS_WINDOW", "error", err)
+			}
+		}
+		job := analytics.NewJob(queryLog, answerer, analytics.ClusterOptions{}, window)
+		go job.Schedule(ctx, interval)
+		analyticsHandler = analytics.NewHandler(queryLog, job, evalDir())
+		handlerOpts = append(handlerOpts, filesearch.WithQueryLogger(queryLog))
+	}
+
+	// Role-based access control: readers query, ingesters also manage ingestion, admins manage everything
+	var authenticator *auth.Authenticator
+	var roles *auth.RoleStore
+	var keys *apikeys.Store
+	if path := os.Getenv("API_KEYS_DB"); path != "" {
+		keys, err = apikeys.Open(path)
+		if err != nil {
+			logging.Fatal("Failed to open API_KEYS_DB", "error", err)
+		}
+		defer keys.Close()
+	}
+	if path, secret := os.Getenv("ROLES_FILE"), os.Getenv("JWT_SECRET"); path != "" || secret != "" || keys != nil {
+		// Keys are kept as HMAC digests keyed with ROLES_SECRET, or else JWT_SECRET
+		rolesSecret := os.Getenv("ROLES_SECRET")
+		if rolesSecret == "" {
+			rolesSecret = secret
+		}
+		if rolesSecret == "" && path != "" {
+			slog.Warn("ROLES_FILE without ROLES_SECRET stores API keys as unkeyed digests")
+		}
+		roles, err = auth.LoadRoleStore(path, []byte(rolesSecret))
+		if err != nil {
+			logging.Fatal("Failed to load ROLES_FILE", "error", err)
+		}
+		if key := os.Getenv("ADMIN_KEY"); key != "" {
+			if _, err := roles.SetKey(key, auth.RoleAdmin); err != nil {
+				logging.Fatal("Failed to assign ADMIN_KEY", "error", err)
+			}
+		}
+
+		authOpts := []auth.Option{}
+		if secret != "" {
+			authOpts = append(authOpts, auth.WithJWTSecret([]byte(secret)))
+		}
+		if keys != nil {
+			authOpts = append(authOpts, auth.WithKeyStore(keys))
+		}
+		if tracker != nil {
+			authOpts = append(authOpts, auth.WithKnownKeys(func(key string) bool {
+				_, ok := tracker.TenantForKey(key)
+				return ok
+			}))
+		}
+		authenticator = auth.NewAuthenticator(roles, authOpts...)
+	}
+	protect := func(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+		if authenticator == nil {
+			return next
+		}
+		return authenticator.Require(role, next)
 	}
 
+	// Only let callers retrieve the documents whose access label their role may see
+	if path := os.Getenv("ACCESS_POLICY"); path != "" {
+		if authenticator == nil || service == nil || !gemini {
+			logging.Fatal("ACCESS_POLICY requires authentication and Gemini File Search stores")
+		}
+		policy, err := filesearch.LoadAccessPolicy(path)
+		if err != nil {
+			logging.Fatal("Failed to load ACCESS_POLICY", "error", err)
+		}
+		handlerOpts = append(handlerOpts, filesearch.WithAccessPolicy(policy, func(r *http.Request) string {
+			identity, _ := auth.IdentityFromContext(r.Context())
+			if identity == nil {
+				return ""
+			}
+			return string(identity.Role)
+		}))
+	}
+
+	handlerOpts = append(handlerOpts, filesearch.WithSourceFetcher(filesearch.SourceFetcher(fetchSource)))
+
 	// Create handler
-	handler := filesearch.NewHandler(service)
+	handler := filesearch.NewHandler(searcher, handlerOpts...)
 
 	// Register routes
-	http.HandleFunc("/query", handler.Query)
-	http.HandleFunc("/stores", handler.ListStoresHandler)
-	http.HandleFunc("/documents", handler.ListDocumentsHandler)
-	http.HandleFunc("/download", handler.DownloadDocumentHandler)
+	query := handler.Query
+	if tracker != nil {
+		query = tracker.Middleware(usage.KindQuery, query)
+		http.HandleFunc("/admin/usage", protect(auth.RoleAdmin, tracker.ReportHandler))
+	}
+	http.HandleFunc("/query", protect(auth.RoleReader, metrics.QueryMiddleware(query)))
+	http.HandleFunc("POST /retrieve", protect(auth.RoleReader, handler.RetrieveHandler))
+	if service != nil {
+		previews := preview.NewHandler(service, preview.NewGenerator(fetchSource, 0), preview.WithAccess(handler.Accessible))
+
+		http.HandleFunc("/stores", protect(auth.RoleReader, handler.ListStoresHandler))
+		http.HandleFunc("/documents", protect(auth.RoleReader, handler.ListDocumentsHandler))
+		http.HandleFunc("GET /stores/{name}/facets", protect(auth.RoleReader, handler.FacetsHandler))
+		http.HandleFunc("GET /stores/{name}/feed", protect(auth.RoleReader, handler.FeedHandler))
+		http.HandleFunc("GET /stores/{name}/stats", protect(auth.RoleReader, handler.StoreStatsHandler))
+		if authenticator != nil {
+			// Only with access control: they replace stores and delete documents in bulk
+			http.HandleFunc("PATCH /stores/{name}", protect(auth.RoleAdmin, handler.UpdateStoreHandler))
+			http.HandleFunc("POST /stores/{name}/documents/delete", protect(auth.RoleAdmin, handler.DeleteDocumentsWhereHandler))
+		}
+		http.HandleFunc("GET /stores/{name}/documents/{id}/preview", protect(auth.RoleReader, previews.Preview))
+		http.HandleFunc("/download", protect(auth.RoleReader, handler.DownloadDocumentHandler))
+		http.HandleFunc("GET /documents/{id}/source", protect(auth.RoleReader, handler.DocumentSourceHandler))
+		http.HandleFunc("POST /attachments", protect(auth.RoleReader, handler.UploadAttachmentHandler))
+		http.HandleFunc("POST /attachments/query", protect(auth.RoleReader, handler.AttachmentQueryHandler))
+		http.HandleFunc("DELETE /attachments/{id}", protect(auth.RoleReader, handler.DeleteAttachmentHandler))
+	}
+	if searchHandler != nil && os.Getenv("ACCESS_POLICY") != "" {
+		// The keyword index holds the local copies, which carry no access labels
+		slog.Warn("Keyword search is disabled with ACCESS_POLICY")
+	} else if searchHandler != nil {
+		http.HandleFunc("/search", protect(auth.RoleReader, searchHandler.Search))
+	}
+	if wageHandler != nil {
+		http.HandleFunc("/wages", protect(auth.RoleReader, wageHandler.Lookup))
+	}
+	if deadLetters != nil {
+		http.HandleFunc("/admin/jobs/failed", protect(auth.RoleIngester, jobsHandler.FailedJobs))
+	}
+	if jobs != nil {
+		http.HandleFunc("POST /jobs", protect(auth.RoleIngester, jobsHandler.StartJob))
+		http.HandleFunc("GET /jobs/{id}", protect(auth.RoleIngester, jobsHandler.Job))
+		http.HandleFunc("GET /jobs/{id}/events", protect(auth.RoleIngester, jobsHandler.JobEvents))
+	}
+	if analyticsHandler != nil {
+		http.HandleFunc("/feedback", protect(auth.RoleReader, analyticsHandler.Feedback))
+		http.HandleFunc("/admin/analytics/themes", protect(auth.RoleAdmin, analyticsHandler.Themes))
+		http.HandleFunc("GET /admin/analytics/sessions", protect(auth.RoleAdmin, analyticsHandler.Sessions))
+		http.HandleFunc("GET /admin/analytics/queries/{id}", protect(auth.RoleAdmin, analyticsHandler.Query))
+		http.HandleFunc("POST /admin/analytics/sessions/{id}/eval", protect(auth.RoleAdmin, analyticsHandler.ExportEval))
+	}
+	if authenticator != nil {
+		// Only with access control: it exposes raw document text regardless of the answer guards
+		http.HandleFunc("POST /debug/retrieve", protect(auth.RoleAdmin, handler.DebugRetrieve))
+	}
+	if roles != nil {
+		rolesHandler := auth.NewHandler(roles)
+		http.HandleFunc("GET /admin/roles", protect(auth.RoleAdmin, rolesHandler.ListRoles))
+		http.HandleFunc("POST /admin/roles", protect(auth.RoleAdmin, rolesHandler.SetRole))
+		http.HandleFunc("DELETE /admin/roles/{subject}", protect(auth.RoleAdmin, rolesHandler.DeleteRole))
+	}
+	if promptStore != nil && authenticator != nil {
+		// Only with access control: published prompts change every answer
+		promptsHandler := prompts.NewHandler(promptStore)
+		http.HandleFunc("GET /admin/prompts", protect(auth.RoleAdmin, promptsHandler.List))
+		http.HandleFunc("GET /admin/prompts/{store}", protect(auth.RoleAdmin, promptsHandler.Versions))
+		http.HandleFunc("POST /admin/prompts/{store}", protect(auth.RoleAdmin, promptsHandler.Publish))
+		http.HandleFunc("POST /admin/prompts/{store}/rollback", protect(auth.RoleAdmin, promptsHandler.Rollback))
+	}
+	if chunkFeedback != nil && authenticator != nil {
+		// Only with access control: marked chunks change the ranking for everyone
+		feedbackHandler := chunkfeedback.NewHandler(chunkFeedback)
+		http.HandleFunc("GET /admin/chunks/feedback", protect(auth.RoleAdmin, feedbackHandler.List))
+		http.HandleFunc("POST /admin/chunks/feedback", protect(auth.RoleAdmin, feedbackHandler.Mark))
+		http.HandleFunc("DELETE /admin/chunks/feedback/{id}", protect(auth.RoleAdmin, feedbackHandler.Clear))
+	}
+	if chats != nil && authenticator != nil {
+		// Only with access control: it exposes and deletes the sessions of every user
+		chatsHandler := conversations.NewHandler(chats)
+		http.HandleFunc("GET /admin/conversations/{id}", protect(auth.RoleAdmin, chatsHandler.Session))
+		http.HandleFunc("DELETE /admin/conversations/{id}", protect(auth.RoleAdmin, chatsHandler.Delete))
+	}
+	if keys != nil {
+		keysHandler := apikeys.NewHandler(keys)
+		http.HandleFunc("GET /admin/keys", protect(auth.RoleAdmin, keysHandler.List))
+		http.HandleFunc("POST /admin/keys", protect(auth.RoleAdmin, keysHandler.Issue))
+		http.HandleFunc("POST /admin/keys/{id}/rotate", protect(auth.RoleAdmin, keysHandler.Rotate))
+		http.HandleFunc("DELETE /admin/keys/{id}", protect(auth.RoleAdmin, keysHandler.Revoke))
+	}
 
 	// Health check endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +591,9 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
+	// Prometheus metrics
+	http.Handle("GET /metrics", metrics.Default)
+
 	// Serve pages
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -60,11 +606,213 @@ func main() {
 		http.ServeFile(w, r, "cmd/cao-server/templates/chat.html")
 	})
 
+	// Answer panics with a 500 error instead of crashing, and report them to Sentry when SENTRY_DSN is set
+	var reporter recovery.Reporter
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		reporter, err = recovery.Sentry(dsn, nil)
+		if err != nil {
+			logging.Fatal("Invalid SENTRY_DSN", "error", err)
+		}
+	}
+
 	// Start server
 	addr := ":" + port
-	log.Printf("Starting CAO Query Server on %s", addr)
-	log.Printf("Visit http://localhost%s for the chat interface", addr)
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		log.Fatal(err)
+	slog.Info("Starting CAO Query Server", "addr", addr, "url", "http://localhost"+addr)
+	if err := http.ListenAndServe(addr, telemetry.Middleware(recovery.Middleware(http.DefaultServeMux, reporter), tracerProvider)); err != nil {
+		shutdownTracing(context.Background())
+		logging.Fatal("Server stopped", "error", err)
+	}
+}
+
+// evalDir returns the directory eval cases are saved in, EVAL_DIR or "evals"
+func evalDir() string {
+	if dir := os.Getenv("EVAL_DIR"); dir != "" {
+		return dir
+	}
+	return "evals"
+}
+
+// loadProfiles loads the per-store answer profiles named by STORE_PROFILES, if any
+func loadProfiles() *filesearch.Profiles {
+	path := os.Getenv("STORE_PROFILES")
+	if path == "" {
+		return nil
+	}
+	profiles, err := filesearch.LoadProfiles(path)
+	if err != nil {
+		logging.Fatal("Failed to load STORE_PROFILES", "error", err)
+	}
+	slog.Info("Loaded store profiles", "stores", len(profiles.Stores), "path", path)
+	return profiles
+}
+
+// loadCitationPolicy parses the citation policy set by CITATION_POLICY and CITATION_REFUSAL, if any
+func loadCitationPolicy() *filesearch.CitationPolicy {
+	setting := os.Getenv("CITATION_POLICY")
+	if setting == "" {
+		return nil
+	}
+	policy, err := filesearch.ParseCitationPolicy(setting)
+	if err != nil {
+		logging.Fatal("Invalid CITATION_POLICY", "error", err)
+	}
+	policy.Refusal = os.Getenv("CITATION_REFUSAL")
+	return policy
+}
+
+// loadPerMinute parses a rate limit set by an environment variable, zero when unset
+func loadPerMinute(name string) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		logging.Fatal("Invalid rate limit", "name", name, "value", v)
+	}
+	return n
+}
+
+// loadRetryPolicy parses the retry policy for transient Gemini errors set by GEMINI_RETRY, if any
+func loadRetryPolicy() *filesearch.RetryPolicy {
+	setting := os.Getenv("GEMINI_RETRY")
+	if setting == "" {
+		return nil
+	}
+	policy, err := filesearch.ParseRetryPolicy(setting)
+	if err != nil {
+		logging.Fatal("Invalid GEMINI_RETRY", "error", err)
+	}
+	return policy
+}
+
+// loadContextCacheTTL parses the context cache lifetime set by CONTEXT_CACHE_TTL, zero when unset
+func loadContextCacheTTL() time.Duration {
+	v := os.Getenv("CONTEXT_CACHE_TTL")
+	if v == "" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl < 0 {
+		logging.Fatal("Invalid CONTEXT_CACHE_TTL", "value", v)
+	}
+	return ttl
+}
+
+// loadResponseCache returns the cache of answers to identical questions enabled by
+// RESPONSE_CACHE_TTL, kept in STATE_STORE when replicas share one and otherwise in process
+// for up to RESPONSE_CACHE_SIZE answers; nil when unset
+func loadResponseCache(state kv.Store) filesearch.ResponseCache {
+	v := os.Getenv("RESPONSE_CACHE_TTL")
+	if v == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		logging.Fatal("Invalid RESPONSE_CACHE_TTL", "value", v)
+	}
+	if state != nil {
+		return filesearch.NewSharedResponseCache(state, ttl)
+	}
+
+	size := 0
+	if v := os.Getenv("RESPONSE_CACHE_SIZE"); v != "" {
+		if size, err = strconv.Atoi(v); err != nil || size <= 0 {
+			logging.Fatal("Invalid RESPONSE_CACHE_SIZE", "value", v)
+		}
+	}
+	return filesearch.NewMemoryResponseCache(size, ttl)
+}
+
+// loadSessionBudget parses the token budget of a chat session set by SESSION_TOKEN_BUDGET,
+// with SESSION_BUDGET_TTL and SESSION_BUDGET_MESSAGE; nil when unset
+func loadSessionBudget() *filesearch.SessionBudget {
+	v := os.Getenv("SESSION_TOKEN_BUDGET")
+	if v == "" {
+		return nil
+	}
+	tokens, err := strconv.Atoi(v)
+	if err != nil || tokens <= 0 {
+		logging.Fatal("Invalid SESSION_TOKEN_BUDGET", "value", v)
+	}
+
+	budget := &filesearch.SessionBudget{Tokens: tokens, Message: os.Getenv("SESSION_BUDGET_MESSAGE")}
+	if v := os.Getenv("SESSION_BUDGET_TTL"); v != "" {
+		if budget.TTL, err = time.ParseDuration(v); err != nil || budget.TTL <= 0 {
+			logging.Fatal("Invalid SESSION_BUDGET_TTL", "value", v)
+		}
+	}
+	return budget
+}
+
+// loadConcurrency parses the limit on concurrent Gemini answers set by GEMINI_MAX_CONCURRENT,
+// zero when unset, and how long further answers queue for a slot set by GEMINI_QUEUE_TIMEOUT
+func loadConcurrency() (int, time.Duration) {
+	v := os.Getenv("GEMINI_MAX_CONCURRENT")
+	if v == "" {
+		return 0, 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		logging.Fatal("Invalid GEMINI_MAX_CONCURRENT", "value", v)
+	}
+
+	timeout := 30 * time.Second
+	if v := os.Getenv("GEMINI_QUEUE_TIMEOUT"); v != "" {
+		if timeout, err = time.ParseDuration(v); err != nil || timeout < 0 {
+			logging.Fatal("Invalid GEMINI_QUEUE_TIMEOUT", "value", v)
+		}
+	}
+	return n, timeout
+}
+
+// loadDocumentCache returns the document cache in DOCUMENT_CACHE_DIR, or under documents/ in
+// STORAGE, or the CAO portal client downloading every time when neither is set
+func loadDocumentCache(ctx context.Context) doccache.Downloader {
+	if dir := os.Getenv("DOCUMENT_CACHE_DIR"); dir != "" {
+		return doccache.New(storage.Dir(dir), caoscrape.NewClient())
+	}
+	if store := loadStorage(ctx); store != nil {
+		return doccache.New(storage.Sub(store, "documents"), caoscrape.NewClient())
+	}
+	return caoscrape.NewClient()
+}
+
+// loadStorage opens the shared storage named by STORAGE, nil when it is unset
+func loadStorage(ctx context.Context) storage.Storage {
+	location := os.Getenv("STORAGE")
+	if location == "" {
+		return nil
+	}
+	store, err := storage.Open(ctx, location)
+	if err != nil {
+		logging.Fatal("Failed to open STORAGE", "error", err)
+	}
+	return store
+}
+
+// loadBackend returns Vertex AI when GOOGLE_GENAI_USE_VERTEXAI is set, and the Gemini API
+// otherwise. Vertex AI authenticates with Application Default Credentials in
+// GOOGLE_CLOUD_PROJECT and GOOGLE_CLOUD_LOCATION, unless GEMINI_API_KEY selects express mode.
+func loadBackend() genai.Backend {
+	switch strings.ToLower(os.Getenv("GOOGLE_GENAI_USE_VERTEXAI")) {
+	case "", "0", "false":
+		return genai.BackendGeminiAPI
+	default:
+		return genai.BackendVertexAI
+	}
+}
+
+// loadStateStore opens the store named by STATE_STORE, which replicas behind a load balancer
+// share rate limits, quotas and cached answers through; nil keeps them per instance
+func loadStateStore() kv.Store {
+	location := os.Getenv("STATE_STORE")
+	if location == "" {
+		return nil
+	}
+	store, err := kv.Open(location)
+	if err != nil {
+		logging.Fatal("Failed to open STATE_STORE", "error", err)
 	}
+	return store
 }
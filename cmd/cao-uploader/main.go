@@ -1,22 +1,42 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"rag/caoscrape"
+	"rag/doccache"
 	"rag/filesearch"
+	"rag/localstore"
+	"rag/logging"
+	"rag/storage"
+	"rag/validity"
+	"strconv"
+	"time"
 
 	"google.golang.org/genai"
 )
 
 func main() {
+	logging.Setup()
+
 	ctx := context.Background()
 	apiKey := os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
-		log.Fatal("GEMINI_API_KEY environment variable not set")
+		logging.Fatal("GEMINI_API_KEY environment variable not set")
+	}
+
+	// Retry uploads failing with rate limiting or server errors when GEMINI_RETRY is set
+	var retry *filesearch.RetryPolicy
+	if setting := os.Getenv("GEMINI_RETRY"); setting != "" {
+		var err error
+		if retry, err = filesearch.ParseRetryPolicy(setting); err != nil {
+			logging.Fatal("Invalid GEMINI_RETRY", "error", err)
+		}
 	}
 
 	// Create the file search service
@@ -24,45 +44,70 @@ func main() {
 		APIKey:    apiKey,
 		ModelName: "gemini-2.5-flash",
 		Backend:   genai.BackendGeminiAPI,
+		Retry:     retry,
+		// Throttle uploads client-side when GEMINI_UPLOADS_PER_MINUTE is set
+		UploadsPerMinute: uploadsPerMinute(),
 	})
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	// Upload into a local SQLite store, embedded with Gemini, instead of File Search when
+	// LOCAL_STORE is set
+	var stores storeAPI = service
+	var local *localstore.Service
+	if path := os.Getenv("LOCAL_STORE"); path != "" {
+		if local, err = localstore.Open(localstore.Config{Path: path, Embedder: service}); err != nil {
+			logging.Fatal("Failed to open LOCAL_STORE", "error", err)
+		}
+		defer local.Close()
+		stores = local
 	}
 
 	// Get or create store
 	storeName := "cao-documents"
 	var store *filesearch.Store
 
-	fmt.Println("Checking if File Search Store exists...")
-	store, err = service.GetStoreByName(ctx, storeName)
+	store, err = stores.GetStoreByName(ctx, storeName)
 	if err != nil {
-		fmt.Println("Creating File Search Store...")
-		store, err = service.CreateStore(ctx, storeName)
+		store, err = stores.CreateStore(ctx, storeName)
 		if err != nil {
-			log.Fatalf("Failed to create store: %v", err)
+			logging.Fatal("Failed to create store", "store", storeName, "error", err)
 		}
-		fmt.Printf("Store created: %s\n", store.DisplayName)
+		slog.Info("Store created", "store", store.DisplayName)
 	} else {
-		fmt.Printf("Store already exists: %s\n", store.DisplayName)
+		slog.Info("Store already exists", "store", store.DisplayName)
 	}
 
-	// Create CAO scraper client
+	// Create CAO scraper client; downloads go through the document cache in DOCUMENT_CACHE_DIR,
+	// or under documents/ in STORAGE, when set, so previews and document sources don't
+	// download them again
 	scraper := caoscrape.NewClient()
+	var source doccache.Downloader = scraper
+	if dir := os.Getenv("DOCUMENT_CACHE_DIR"); dir != "" {
+		source = doccache.New(storage.Dir(dir), scraper)
+	} else if location := os.Getenv("STORAGE"); location != "" {
+		store, err := storage.Open(ctx, location)
+		if err != nil {
+			logging.Fatal("Failed to open STORAGE", "error", err)
+		}
+		source = doccache.New(storage.Sub(store, "documents"), scraper)
+	}
 
 	// Search for documents with specific JC number
 	jc := 3180200
-	fmt.Printf("\nSearching for documents with JC number %d...\n", jc)
+	start := time.Now()
 	urls, err := scraper.Search(&jc)
 	if err != nil {
-		log.Fatalf("Failed to search: %v", err)
+		logging.Fatal("Failed to search", "jc", jc, "error", err)
 	}
 
-	fmt.Printf("Found %d documents\n", len(urls))
+	slog.Info("Found documents", "jc", jc, "documents", len(urls), "duration", time.Since(start))
 
 	// Get existing documents to avoid re-uploading
-	existingDocs, err := service.ListDocuments(ctx, store.Name)
+	existingDocs, err := stores.ListDocuments(ctx, store.Name)
 	if err != nil {
-		log.Printf("Warning: Failed to list existing documents: %v", err)
+		slog.Warn("Failed to list existing documents", "store", store.Name, "error", err)
 		existingDocs = []*filesearch.Document{}
 	}
 
@@ -84,30 +129,97 @@ func main() {
 
 		// Check if already uploaded
 		if existingFiles[fileName] {
-			fmt.Printf("Skipping %s (already uploaded)\n", fileName)
+			slog.Debug("Skipping document, already uploaded", "document", fileName)
 			skippedCount++
 			continue
 		}
 
-		fmt.Printf("Downloading and uploading %s...\n", fileName)
-
-		// Download document
-		reader, err := scraper.DownloadDocument(url)
+		start := time.Now()
+		reader, err := source.DownloadDocument(url)
 		if err != nil {
-			log.Printf("Warning: Failed to download %s: %v", url, err)
+			slog.Warn("Failed to download document", "document", fileName, "url", url, "error", err)
 			continue
 		}
 
-		// Upload to file search store with source URL
-		_, err = service.UploadDocumentWithURL(ctx, reader, fileName, store.Name, url)
+		// Upload to the file search store with the source URL, the JC number, the period the
+		// agreement is in force, the access label from ACCESS_LABEL and the content hash, unless
+		// a document with the same content was uploaded under another name
+		var doc *filesearch.Document
+		uploaded := true
+		if local != nil {
+			doc, uploaded, err = uploadLocal(ctx, local, reader, url, fileName, store.Name, jc, existingDocs)
+		} else {
+			doc, uploaded, err = service.UploadIfNew(ctx, reader, fileName, store.Name, &filesearch.UploadOptions{
+				Metadata:       map[string]any{"jc_number": jc, "source_url": url},
+				CustomMetadata: filesearch.AccessMetadata(os.Getenv("ACCESS_LABEL")),
+				Inspect:        validity.Metadata,
+				Existing:       existingDocs,
+			})
+		}
 		if err != nil {
-			log.Printf("Warning: Failed to upload %s: %v", fileName, err)
+			slog.Warn("Failed to upload document", "document", fileName, "url", url, "error", err)
+			continue
+		}
+		if !uploaded {
+			slog.Info("Skipping document, same content already uploaded", "document", fileName, "existing", doc.DisplayName)
+			skippedCount++
 			continue
 		}
 
+		slog.Info("Uploaded document", "document", fileName, "url", url, "duration", time.Since(start))
+		existingDocs = append(existingDocs, doc)
 		uploadedCount++
 	}
 
-	fmt.Printf("\nUpload complete: %d new documents uploaded, %d documents skipped\n", uploadedCount, skippedCount)
+	slog.Info("Upload complete", "uploaded", uploadedCount, "skipped", skippedCount)
 	fmt.Printf("\nUse 'cao-querier \"your question\"' to query the uploaded documents\n")
 }
+
+// storeAPI is what the uploader needs from File Search or a local store
+type storeAPI interface {
+	GetStoreByName(ctx context.Context, displayName string) (*filesearch.Store, error)
+	CreateStore(ctx context.Context, displayName string) (*filesearch.Store, error)
+	ListDocuments(ctx context.Context, storeName string) ([]*filesearch.Document, error)
+}
+
+// uploadLocal uploads a document to the local store with the metadata File Search uploads get:
+// the source URL, the JC number, the period in force and the content hash. Like
+// Service.UploadIfNew, it returns the existing document with the same content instead.
+func uploadLocal(ctx context.Context, local *localstore.Service, reader io.Reader, url, fileName, storeName string, jc int, existing []*filesearch.Document) (*filesearch.Document, bool, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read document: %w", err)
+	}
+	hash := filesearch.ContentHash(data)
+	if doc := filesearch.FindByContentHash(existing, hash); doc != nil {
+		return doc, false, nil
+	}
+
+	metadata := map[string]string{"source_url": url, "jc_number": strconv.Itoa(jc), filesearch.MetadataContentHash: hash}
+	for _, cm := range validity.Metadata(data) {
+		switch {
+		case cm.StringValue != "":
+			metadata[cm.Key] = cm.StringValue
+		case cm.NumericValue != nil:
+			metadata[cm.Key] = strconv.FormatFloat(float64(*cm.NumericValue), 'f', -1, 32)
+		}
+	}
+	doc, err := local.UploadDocumentWithMetadata(ctx, bytes.NewReader(data), fileName, storeName, metadata)
+	if err != nil {
+		return nil, false, err
+	}
+	return doc, true, nil
+}
+
+// uploadsPerMinute parses the upload rate limit set by GEMINI_UPLOADS_PER_MINUTE, zero when unset
+func uploadsPerMinute() int {
+	v := os.Getenv("GEMINI_UPLOADS_PER_MINUTE")
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		logging.Fatal("Invalid GEMINI_UPLOADS_PER_MINUTE", "value", v)
+	}
+	return n
+}
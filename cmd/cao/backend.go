@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"rag/filesearch"
+	"rag/logging"
+	"rag/ollama"
+	"rag/providers"
+
+	"google.golang.org/genai"
+)
+
+// onPrem reports whether PROVIDER=ollama selects the on-prem backend: documents are
+// embedded into the local vector index and questions answered by a local Ollama server
+func onPrem() bool {
+	cfg := providers.ConfigFromEnv()
+	if err := cfg.Validate(); err != nil {
+		logging.Fatal("Invalid PROVIDER", "error", err)
+	}
+	return cfg.OnPrem()
+}
+
+// requireGemini exits when a command that only works with Gemini File Search runs on-prem
+func requireGemini(command string) {
+	if onPrem() {
+		logging.Fatal("Command manages Gemini File Search stores and is not available with PROVIDER=ollama", "command", command)
+	}
+}
+
+// ollamaClient returns the Ollama client configured by OLLAMA_URL, OLLAMA_MODEL and
+// OLLAMA_EMBED_MODEL; a non-empty embeddingModel overrides the latter
+func ollamaClient(embeddingModel string) *ollama.Client {
+	if embeddingModel == "" {
+		embeddingModel = os.Getenv("OLLAMA_EMBED_MODEL")
+	}
+	return ollama.New(ollama.Config{
+		BaseURL:        os.Getenv("OLLAMA_URL"),
+		Model:          os.Getenv("OLLAMA_MODEL"),
+		EmbeddingModel: embeddingModel,
+	})
+}
+
+// vectorIndexFile returns the local vector index file from the environment, or the default
+func vectorIndexFile() string {
+	if path := os.Getenv("VECTOR_INDEX"); path != "" {
+		return path
+	}
+	return "index.jsonl"
+}
+
+// documentsDir returns the directory local documents are kept in from the environment, or the default
+func documentsDir() string {
+	if dir := os.Getenv("DOCUMENTS_DIR"); dir != "" {
+		return dir
+	}
+	return "documents"
+}
+
+// newProvider returns the provider questions are answered with, selected by PROVIDER: Gemini
+// File Search, Claude or Ollama over the local vector index
+func newProvider(ctx context.Context) filesearch.Provider {
+	cfg := providers.ConfigFromEnv()
+	if !onPrem() {
+		service, err := filesearch.NewService(ctx, &filesearch.Config{
+			APIKey:            apiKey(),
+			Backend:           genai.BackendGeminiAPI,
+			Profiles:          storeProfiles(),
+			CitationPolicy:    citationPolicy(),
+			Retry:             retryPolicy(),
+			RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+			UploadsPerMinute:  rateLimit("GEMINI_UPLOADS_PER_MINUTE"),
+		})
+		if err != nil {
+			logging.Fatal("Failed to create service", "error", err)
+		}
+		cfg.Service = service
+	}
+
+	provider, err := providers.New(cfg)
+	if err != nil {
+		logging.Fatal("Failed to create provider", "error", err)
+	}
+	return provider
+}
+
+// storeProfiles loads the per-store answer profiles named by STORE_PROFILES, if any
+func storeProfiles() *filesearch.Profiles {
+	path := os.Getenv("STORE_PROFILES")
+	if path == "" {
+		return nil
+	}
+	profiles, err := filesearch.LoadProfiles(path)
+	if err != nil {
+		logging.Fatal("Failed to load STORE_PROFILES", "error", err)
+	}
+	return profiles
+}
+
+// citationPolicy parses the citation policy set by CITATION_POLICY and CITATION_REFUSAL, if any
+func citationPolicy() *filesearch.CitationPolicy {
+	setting := os.Getenv("CITATION_POLICY")
+	if setting == "" {
+		return nil
+	}
+	policy, err := filesearch.ParseCitationPolicy(setting)
+	if err != nil {
+		logging.Fatal("Invalid CITATION_POLICY", "error", err)
+	}
+	policy.Refusal = os.Getenv("CITATION_REFUSAL")
+	return policy
+}
+
+// rateLimit parses a rate limit set by an environment variable, zero when unset
+func rateLimit(name string) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		logging.Fatal("Invalid rate limit", "name", name, "value", v)
+	}
+	return n
+}
+
+// retryPolicy parses the retry policy for transient Gemini errors set by GEMINI_RETRY, if any
+func retryPolicy() *filesearch.RetryPolicy {
+	setting := os.Getenv("GEMINI_RETRY")
+	if setting == "" {
+		return nil
+	}
+	policy, err := filesearch.ParseRetryPolicy(setting)
+	if err != nil {
+		logging.Fatal("Invalid GEMINI_RETRY", "error", err)
+	}
+	return policy
+}
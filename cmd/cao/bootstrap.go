@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"rag/corpus"
+	"rag/filesearch"
+	"rag/logging"
+
+	"google.golang.org/genai"
+)
+
+func runBootstrap(args []string) {
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	requireGemini("bootstrap")
+
+	def, err := corpus.Load(flags.Arg(0))
+	if err != nil {
+		logging.Fatal("Failed to load corpus definition", "error", err)
+	}
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey(),
+		Backend:           genai.BackendGeminiAPI,
+		Retry:             retryPolicy(),
+		RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+		UploadsPerMinute:  rateLimit("GEMINI_UPLOADS_PER_MINUTE"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	report, err := corpus.Bootstrap(ctx, service, nil, def)
+	for _, store := range report.Stores {
+		state := "existing"
+		if store.Created {
+			state = "created"
+		}
+		fmt.Printf("%s (%s): %d uploaded, %d skipped, %d failed\n", store.Name, state, store.Uploaded, store.Skipped, store.Failed)
+	}
+	if err != nil {
+		logging.Fatal("Failed to bootstrap corpus", "error", err)
+	}
+	if def.Profiles != "" {
+		fmt.Printf("Store profiles written to %s, set STORE_PROFILES to use them\n", def.Profiles)
+	}
+}
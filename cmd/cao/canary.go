@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"rag/logging"
+	"rag/monitor"
+)
+
+func runCanary(args []string) {
+	if len(args) < 1 || args[0] != "run" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("canary run", flag.ExitOnError)
+	path := flags.String("canaries", "canaries.yaml", "YAML file with the canary questions")
+	logPath := flags.String("log", "", "JSON lines file the results are appended to")
+	flags.Parse(args[1:])
+
+	suite, err := monitor.LoadCanaries(*path)
+	if err != nil {
+		logging.Fatal("Failed to load canaries", "error", err)
+	}
+
+	ctx := context.Background()
+	results, err := monitor.NewCanaryMonitor(suite, *logPath, nil).Check(ctx, newProvider(ctx))
+	if err != nil {
+		slog.Warn("Canary check incomplete", "error", err)
+	}
+
+	degraded := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STORE\tLATENCY\tSOURCES\tSTATUS\tQUESTION")
+	for _, r := range results {
+		status := "ok"
+		if r.Degraded() {
+			status = strings.Join(r.Failures, "; ")
+			degraded++
+		}
+		fmt.Fprintf(tw, "%s\t%dms\t%d\t%s\t%s\n", r.Canary.Store, r.LatencyMS, r.Sources, status, r.Canary.Question)
+	}
+	tw.Flush()
+
+	fmt.Printf("\nCanaries: %d of %d degraded\n", degraded, len(results))
+	if degraded > 0 {
+		os.Exit(1)
+	}
+}
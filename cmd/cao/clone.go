@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"rag/caoscrape"
+	"rag/filesearch"
+	"rag/logging"
+	"rag/preview"
+
+	"google.golang.org/genai"
+)
+
+func runClone(args []string) {
+	flags := flag.NewFlagSet("clone", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	requireGemini("clone")
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey(),
+		Backend:           genai.BackendGeminiAPI,
+		Originals:         filesearch.DocumentFetcher(preview.LocalOrSourceFetcher(documentsDir(), caoscrape.NewClient())),
+		Retry:             retryPolicy(),
+		RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+		UploadsPerMinute:  rateLimit("GEMINI_UPLOADS_PER_MINUTE"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	src, err := service.GetStoreByName(ctx, flags.Arg(0))
+	if err != nil {
+		logging.Fatal("Failed to find store", "store", flags.Arg(0), "error", err)
+	}
+	dst, err := service.CloneStore(ctx, src.Name, flags.Arg(1))
+	if err != nil {
+		logging.Fatal("Failed to clone store", "store", src.Name, "error", err)
+	}
+	fmt.Printf("Cloned %s into %s (%s)\n", src.DisplayName, dst.DisplayName, dst.Name)
+}
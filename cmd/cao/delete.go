@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"rag/filesearch"
+	"rag/logging"
+
+	"google.golang.org/genai"
+)
+
+func runDelete(args []string) {
+	flags := flag.NewFlagSet("delete", flag.ExitOnError)
+	storeName := flags.String("store", "cao-documents", "Store to delete the documents from")
+	dryRun := flags.Bool("dry-run", false, "Only list the documents matching the filter")
+	confirm := flags.Int("confirm", -1, "Number of matching documents to delete without asking, as listed by a dry run")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	filter := flags.Arg(0)
+	if _, err := filesearch.ParseFilter(filter); err != nil {
+		logging.Fatal("Invalid filter", "error", err)
+	}
+	requireGemini("delete")
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey(),
+		Backend:           genai.BackendGeminiAPI,
+		Retry:             retryPolicy(),
+		RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	store, err := service.GetStoreByName(ctx, *storeName)
+	if err != nil {
+		logging.Fatal("Store not found", "store", *storeName, "error", err)
+	}
+
+	if *dryRun || *confirm < 0 {
+		// List the matches first and ask for their count
+		report, err := service.DeleteDocumentsWhere(ctx, store.Name, filter, &filesearch.DeleteOptions{DryRun: true})
+		if err != nil {
+			logging.Fatal("Failed to list documents", "error", err)
+		}
+		for _, doc := range report.Documents {
+			fmt.Println(doc.DisplayName)
+		}
+		fmt.Printf("\n%d documents match %s\n", len(report.Documents), filter)
+		if *dryRun || len(report.Documents) == 0 {
+			return
+		}
+
+		fmt.Printf("Type %d to delete them: ", len(report.Documents))
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		n, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil || n != len(report.Documents) {
+			fmt.Println("Nothing deleted")
+			os.Exit(1)
+		}
+		*confirm = n
+	}
+
+	report, err := service.DeleteDocumentsWhere(ctx, store.Name, filter, &filesearch.DeleteOptions{Confirm: *confirm})
+	if err != nil {
+		logging.Fatal("Failed to delete documents", "error", err)
+	}
+	fmt.Printf("Delete complete: %d deleted, %d failed\n", report.Deleted, report.Failed)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
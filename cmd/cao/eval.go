@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"rag/caoscrape"
+	"rag/eval"
+	"rag/filesearch"
+	"rag/logging"
+	"rag/preview"
+
+	"google.golang.org/genai"
+)
+
+// evalDir returns the eval case directory from the environment, or the default
+func evalDir() string {
+	if dir := os.Getenv("EVAL_DIR"); dir != "" {
+		return dir
+	}
+	return "evals"
+}
+
+func runEval(args []string) {
+	if len(args) < 1 {
+		usage()
+	}
+	switch args[0] {
+	case "run":
+		runEvalCases(args[1:])
+	case "generate":
+		runEvalGenerate(args[1:])
+	default:
+		usage()
+	}
+}
+
+func runEvalCases(args []string) {
+	flags := flag.NewFlagSet("eval run", flag.ExitOnError)
+	dir := flags.String("dir", evalDir(), "Directory with the eval cases")
+	threshold := flags.Float64("threshold", eval.DefaultThreshold, "Share of the terms of an accepted answer a new answer must contain")
+	verbose := flags.Bool("v", false, "Print the answers of failed turns")
+	flags.Parse(args)
+
+	cases, err := eval.LoadCases(*dir)
+	if err != nil {
+		logging.Fatal("Failed to load eval cases", "error", err)
+	}
+	if len(cases) == 0 {
+		fmt.Printf("No eval cases in %s\n", *dir)
+		return
+	}
+
+	ctx := context.Background()
+	provider := newProvider(ctx)
+	failed := 0
+	for _, c := range cases {
+		result, err := eval.Run(ctx, provider, c, *threshold)
+		if err != nil {
+			slog.Warn("Eval case failed to run", "case", c.Name, "error", err)
+			failed++
+			continue
+		}
+
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s %s\n", status, c.Name)
+		for i, turn := range result.Turns {
+			if turn.Accepted == "" {
+				continue
+			}
+			fmt.Printf("  turn %d: %.0f%% of the accepted answer", i+1, turn.Coverage*100)
+			if len(turn.Missing) > 0 {
+				fmt.Printf(", missing %s", strings.Join(turn.Missing, ", "))
+			}
+			fmt.Println()
+			if *verbose && !turn.Passed {
+				fmt.Printf("    question: %s\n    accepted: %s\n    answer:   %s\n", turn.Question, turn.Accepted, turn.Answer)
+			}
+		}
+	}
+
+	fmt.Printf("\nEval complete: %d cases, %d failed\n", len(cases), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// documentResult counts the generated cases of a document that passed
+type documentResult struct {
+	document string
+	passed   int
+	total    int
+}
+
+func runEvalGenerate(args []string) {
+	flags := flag.NewFlagSet("eval generate", flag.ExitOnError)
+	storeName := flags.String("store", "cao-documents", "Store whose documents the questions are generated from")
+	n := flags.Int("n", 3, "Questions per document")
+	limit := flags.Int("limit", 0, "Documents to generate questions from (0 for all)")
+	dir := flags.String("dir", filepath.Join(evalDir(), "candidates"), "Directory the candidate cases are written to")
+	check := flags.Bool("check", false, "Replay the candidates and list the documents answered poorly first")
+	threshold := flags.Float64("threshold", eval.DefaultThreshold, "Share of the terms of an accepted answer a new answer must contain")
+	flags.Parse(args)
+	requireGemini("eval generate")
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey(),
+		Backend:           genai.BackendGeminiAPI,
+		Originals:         filesearch.DocumentFetcher(preview.LocalOrSourceFetcher(documentsDir(), caoscrape.NewClient())),
+		Retry:             retryPolicy(),
+		RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+	store, err := service.GetStoreByName(ctx, *storeName)
+	if err != nil {
+		logging.Fatal("Failed to find store", "store", *storeName, "error", err)
+	}
+	docs, err := service.ListDocuments(ctx, store.Name)
+	if err != nil {
+		logging.Fatal("Failed to list documents", "store", store.Name, "error", err)
+	}
+	if *limit > 0 && len(docs) > *limit {
+		docs = docs[:*limit]
+	}
+
+	var provider filesearch.Provider
+	if *check {
+		provider = newProvider(ctx)
+	}
+	var results []*documentResult
+	generated := 0
+	for _, doc := range docs {
+		cases, err := eval.Generate(ctx, service, store.DisplayName, doc, *n)
+		if err != nil {
+			slog.Warn("Failed to generate questions", "document", doc.DisplayName, "error", err)
+			continue
+		}
+
+		result := &documentResult{document: doc.DisplayName}
+		for _, c := range cases {
+			if _, err := c.Save(*dir); err != nil {
+				slog.Warn("Failed to save candidate", "case", c.Name, "error", err)
+				continue
+			}
+			generated++
+			if provider == nil {
+				continue
+			}
+			run, err := eval.Run(ctx, provider, c, *threshold)
+			if err != nil {
+				slog.Warn("Eval case failed to run", "case", c.Name, "error", err)
+				continue
+			}
+			result.total++
+			if run.Passed {
+				result.passed++
+			}
+		}
+		results = append(results, result)
+	}
+	fmt.Printf("Generated %d candidate cases from %d documents in %s; review them before moving them to %s\n", generated, len(docs), *dir, evalDir())
+	if provider == nil {
+		return
+	}
+
+	// Documents answered poorly first
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].passed*results[j].total < results[j].passed*results[i].total
+	})
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOCUMENT\tPASSED")
+	for _, r := range results {
+		if r.total > 0 {
+			fmt.Fprintf(tw, "%s\t%d/%d\n", r.document, r.passed, r.total)
+		}
+	}
+	tw.Flush()
+}
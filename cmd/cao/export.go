@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"rag/caoscrape"
+	"rag/filesearch"
+	"rag/logging"
+	"rag/preview"
+
+	"google.golang.org/genai"
+)
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "Manifest file to write, stdout when empty")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	requireGemini("export")
+
+	ctx := context.Background()
+	service := migrationService(ctx)
+	store, err := service.GetStoreByName(ctx, flags.Arg(0))
+	if err != nil {
+		logging.Fatal("Failed to find store", "store", flags.Arg(0), "error", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			logging.Fatal("Failed to create manifest", "file", *output, "error", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := service.ExportStore(ctx, store.Name, w); err != nil {
+		logging.Fatal("Failed to export store", "store", store.Name, "error", err)
+	}
+}
+
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	requireGemini("import")
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		logging.Fatal("Failed to open manifest", "file", flags.Arg(0), "error", err)
+	}
+	manifest, err := filesearch.ReadManifest(f)
+	f.Close()
+	if err != nil {
+		logging.Fatal("Failed to read manifest", "file", flags.Arg(0), "error", err)
+	}
+
+	ctx := context.Background()
+	service := migrationService(ctx)
+	store, err := service.GetStoreByName(ctx, flags.Arg(1))
+	if err != nil {
+		store, err = service.CreateStore(ctx, flags.Arg(1))
+		if err != nil {
+			logging.Fatal("Failed to create store", "store", flags.Arg(1), "error", err)
+		}
+	}
+
+	report, err := service.ImportStore(ctx, manifest, store.Name)
+	if err != nil {
+		logging.Fatal("Failed to import store", "store", store.Name, "error", err)
+	}
+	printMigration(report)
+}
+
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	requireGemini("migrate")
+
+	ctx := context.Background()
+	service := migrationService(ctx)
+	src, err := service.GetStoreByName(ctx, flags.Arg(0))
+	if err != nil {
+		logging.Fatal("Failed to find store", "store", flags.Arg(0), "error", err)
+	}
+	dst, err := service.GetStoreByName(ctx, flags.Arg(1))
+	if err != nil {
+		dst, err = service.CreateStore(ctx, flags.Arg(1))
+		if err != nil {
+			logging.Fatal("Failed to create store", "store", flags.Arg(1), "error", err)
+		}
+	}
+
+	report, err := service.MigrateStore(ctx, src.Name, dst.Name)
+	if err != nil {
+		logging.Fatal("Failed to migrate store", "store", src.Name, "error", err)
+	}
+	printMigration(report)
+}
+
+// migrationService creates a service uploading documents again from their local copy or
+// source URL
+func migrationService(ctx context.Context) *filesearch.Service {
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey(),
+		Backend:           genai.BackendGeminiAPI,
+		Originals:         filesearch.DocumentFetcher(preview.LocalOrSourceFetcher(documentsDir(), caoscrape.NewClient())),
+		Retry:             retryPolicy(),
+		RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+		UploadsPerMinute:  rateLimit("GEMINI_UPLOADS_PER_MINUTE"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+	return service
+}
+
+// printMigration prints the documents an import uploaded, and exits non-zero when some failed
+func printMigration(report *filesearch.SyncReport) {
+	for _, change := range report.Changes {
+		if change.Error != "" {
+			fmt.Printf("failed   %s: %s\n", change.Document, change.Error)
+		} else {
+			fmt.Printf("uploaded %s\n", change.Document)
+		}
+	}
+	fmt.Printf("\nImport complete: %d uploaded, %d already present, %d failed\n",
+		report.Count(filesearch.SyncUpload), report.Unchanged, len(report.Failed()))
+	if len(report.Failed()) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"rag/filesearch"
+	"rag/ingest"
+	"rag/logging"
+	"rag/vectorindex"
+
+	"google.golang.org/genai"
+)
+
+func runIndex(args []string) {
+	if len(args) < 1 || args[0] != "build" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("index build", flag.ExitOnError)
+	dir := flags.String("dir", "documents", "Directory with cached PDF documents")
+	out := flags.String("out", "index.jsonl", "Index file to write; documents already in it are skipped")
+	workers := flags.Int("workers", vectorindex.DefaultWorkers, "Documents processed in parallel")
+	batch := flags.Int("batch", vectorindex.DefaultBatchSize, "Chunks per embedding request")
+	chunkSize := flags.Int("chunk-size", vectorindex.DefaultChunkSize, "Words per chunk")
+	overlap := flags.Int("overlap", vectorindex.DefaultOverlap, "Words shared by consecutive chunks")
+	batchChars := flags.Int("batch-chars", vectorindex.DefaultMaxBatchChars, "Characters per embedding request")
+	rpm := flags.Int("rpm", 0, "Maximum embedding requests per minute, 0 for unlimited")
+	retries := flags.Int("retries", vectorindex.DefaultMaxRetries, "Retries of an embedding request failing with an outage error")
+	model := flags.String("model", "", "Embedding model (default gemini-embedding-001, or nomic-embed-text with PROVIDER=ollama)")
+	flags.Parse(args[1:])
+
+	// Stop cleanly on Ctrl-C; completed documents are kept and skipped on the next run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Embed with Gemini, or on-prem with Ollama when PROVIDER=ollama
+	var embedder vectorindex.Embedder
+	if onPrem() {
+		embedder = ollamaClient(*model)
+	} else {
+		service, err := filesearch.NewService(ctx, &filesearch.Config{
+			APIKey:         apiKey(),
+			EmbeddingModel: *model,
+			Backend:        genai.BackendGeminiAPI,
+		})
+		if err != nil {
+			logging.Fatal("Failed to create service", "error", err)
+		}
+		embedder = service
+	}
+
+	slog.Info("Building index", "index", *out, "dir", *dir)
+	report, err := vectorindex.Build(ctx, vectorindex.BuildOptions{
+		Dir:       *dir,
+		Output:    *out,
+		Workers:   *workers,
+		BatchSize: *batch,
+		ChunkSize: *chunkSize,
+		Overlap:   *overlap,
+	}, vectorindex.NewBatcher(embedder, vectorindex.BatcherOptions{
+		MaxBatchSize:  *batch,
+		MaxBatchChars: *batchChars,
+		Quota:         ingest.Quota{PerMinute: *rpm},
+		MaxRetries:    *retries,
+	}))
+	if report != nil {
+		fmt.Printf("\nIndex build: %d indexed (%d chunks), %d skipped, %d failed\n",
+			report.Indexed, report.Chunks, report.Skipped, report.Failed)
+		fmt.Printf("Embedding: %s\n", report.Embedding)
+	}
+	if err != nil {
+		logging.Fatal("Failed to build index", "error", err)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rag/caoscrape"
+	"rag/filesearch"
+	"rag/ingest"
+	"rag/logging"
+	"rag/vectorindex"
+
+	"google.golang.org/genai"
+)
+
+func runIngest(args []string) {
+	flags := flag.NewFlagSet("ingest", flag.ExitOnError)
+	storeName := flags.String("store", "cao-documents", "Store to upload to, created when missing")
+	format := flags.String("format", ingest.FormatAuto, "Input format: auto, tar or ndjson")
+	perMinute := flags.Int("per-minute", 0, "Maximum uploads per minute, 0 for unlimited")
+	perDay := flags.Int("per-day", 0, "Maximum uploads per 24 hours, 0 for unlimited")
+	dlq := flags.String("dead-letters", deadLetterDir(), "Directory where failed documents are recorded")
+	label := flags.String("label", "", "Access label of the documents, e.g. public or hr-only")
+	flagged := flags.String("flag", "", "Comma-separated quality issues to upload flagged rather than reject: empty, scanned, encrypted, duplicate")
+	flags.Parse(args)
+
+	quality := ingest.QualityOptions{}
+	if *flagged != "" {
+		quality.Flag = strings.Split(*flagged, ",")
+	}
+	if err := quality.Validate(); err != nil {
+		logging.Fatal("Invalid -flag", "error", err)
+	}
+
+	ctx := context.Background()
+	if onPrem() {
+		ingestLocal(ctx, *format, ingest.NewChecker(quality))
+		return
+	}
+
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey(),
+		Backend:           genai.BackendGeminiAPI,
+		Retry:             retryPolicy(),
+		RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+		UploadsPerMinute:  rateLimit("GEMINI_UPLOADS_PER_MINUTE"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	store, err := service.GetStoreByName(ctx, *storeName)
+	if err != nil {
+		store, err = service.CreateStore(ctx, *storeName)
+		if err != nil {
+			logging.Fatal("Failed to create store", "store", *storeName, "error", err)
+		}
+	}
+
+	stream, err := ingest.NewStream(os.Stdin, *format, caoscrape.NewClient().DownloadDocument)
+	if err != nil {
+		logging.Fatal("Failed to read input", "error", err)
+	}
+
+	deadLetters, err := ingest.OpenDeadLetters(*dlq)
+	if err != nil {
+		logging.Fatal("Failed to open dead letters", "error", err)
+	}
+
+	report, err := ingest.Upload(ctx, service, store.Name, stream, &ingest.Options{
+		Scheduler:   ingest.NewScheduler(ingest.Quota{PerMinute: *perMinute, PerDay: *perDay}),
+		DeadLetters: deadLetters,
+		AccessLabel: *label,
+		Quality:     ingest.NewChecker(quality),
+	})
+	if report != nil {
+		fmt.Printf("\nIngest complete: %d uploaded, %d skipped, %d failed, %d rejected\n", report.Uploaded, report.Skipped, report.Failed, report.Rejected)
+		printQualityIssues(report.Issues)
+	}
+	if err != nil {
+		logging.Fatal("Failed to ingest", "error", err)
+	}
+
+	checkWatchesAfterSync(ctx, service, report.Uploaded)
+}
+
+// ingestLocal keeps the streamed documents passing the quality checks in the documents
+// directory and embeds the new ones into the local vector index with Ollama
+func ingestLocal(ctx context.Context, format string, quality *ingest.Checker) {
+	dir := documentsDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logging.Fatal("Failed to create documents directory", "error", err)
+	}
+
+	stream, err := ingest.NewStream(os.Stdin, format, caoscrape.NewClient().DownloadDocument)
+	if err != nil {
+		logging.Fatal("Failed to read input", "error", err)
+	}
+
+	index, err := vectorindex.Load(vectorIndexFile())
+	if err != nil {
+		logging.Fatal("Failed to load vector index", "error", err)
+	}
+	for name, hash := range index.Hashes() {
+		quality.Add(name, hash)
+	}
+
+	failed := 0
+	var issues []*ingest.QualityIssue
+	for {
+		entry, err := stream.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var entryErr *ingest.EntryError
+			var recErr *ingest.RecordError
+			if errors.As(err, &entryErr) || errors.As(err, &recErr) {
+				slog.Warn("Skipping invalid record", "error", err)
+				failed++
+				continue
+			}
+			logging.Fatal("Failed to read input", "error", err)
+		}
+
+		data, err := io.ReadAll(entry.Body)
+		if err == nil {
+			if issue := quality.Check(entry.Name, data); issue != nil {
+				issues = append(issues, issue)
+				if issue.Rejected {
+					continue
+				}
+			}
+			err = os.WriteFile(filepath.Join(dir, entry.Name), data, 0o644)
+		}
+		if err != nil {
+			slog.Warn("Failed to store document", "document", entry.Name, "error", err)
+			failed++
+		}
+	}
+
+	report, err := vectorindex.Build(ctx, vectorindex.BuildOptions{Dir: dir, Output: vectorIndexFile()}, ollamaClient(""))
+	if report != nil {
+		fmt.Printf("\nIngest complete: %d indexed, %d skipped, %d failed\n", report.Indexed, report.Skipped, report.Failed+failed)
+		printQualityIssues(issues)
+	}
+	if err != nil {
+		logging.Fatal("Failed to ingest", "error", err)
+	}
+
+	checkWatchesAfterSync(ctx, newProvider(ctx), report.Indexed)
+}
+
+// printQualityIssues lists the documents rejected or flagged by the quality checks
+func printQualityIssues(issues []*ingest.QualityIssue) {
+	for _, issue := range issues {
+		action := "flagged"
+		if issue.Rejected {
+			action = "rejected"
+		}
+		fmt.Printf("  %s %s (%s): %s\n", action, issue.Name, issue.Issue, issue.Reason)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"rag/caoscrape"
+	"rag/filesearch"
+	"rag/ingest"
+	"rag/logging"
+
+	"google.golang.org/genai"
+)
+
+// deadLetterDir returns the dead-letter directory from the environment, or the default
+func deadLetterDir() string {
+	if dir := os.Getenv("DEAD_LETTER_DIR"); dir != "" {
+		return dir
+	}
+	return "dead-letters"
+}
+
+func runJobs(args []string) {
+	if len(args) < 1 {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("jobs "+args[0], flag.ExitOnError)
+	dir := flags.String("dead-letters", deadLetterDir(), "Directory where failed documents are recorded")
+	flags.Parse(args[1:])
+
+	deadLetters, err := ingest.OpenDeadLetters(*dir)
+	if err != nil {
+		logging.Fatal("Failed to open dead letters", "error", err)
+	}
+
+	switch args[0] {
+	case "failed":
+		failures, err := deadLetters.List()
+		if err != nil {
+			logging.Fatal("Failed to list failed jobs", "error", err)
+		}
+		if len(failures) == 0 {
+			fmt.Println("No failed jobs")
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTAGE\tDOCUMENT\tATTEMPTS\tFAILED AT\tERROR")
+		for _, f := range failures {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+				f.ID, f.Stage, f.Name, f.Attempts, f.FailedAt.Format(time.DateTime), f.Error)
+		}
+		tw.Flush()
+
+	case "retry":
+		requireGemini("jobs retry")
+		ctx := context.Background()
+		service, err := filesearch.NewService(ctx, &filesearch.Config{
+			APIKey:            apiKey(),
+			Backend:           genai.BackendGeminiAPI,
+			Retry:             retryPolicy(),
+			RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+			UploadsPerMinute:  rateLimit("GEMINI_UPLOADS_PER_MINUTE"),
+		})
+		if err != nil {
+			logging.Fatal("Failed to create service", "error", err)
+		}
+
+		report, err := deadLetters.Retry(ctx, service, caoscrape.NewClient().DownloadDocument)
+		if report != nil {
+			fmt.Printf("\nRetry complete: %d uploaded, %d still failing, %d rejected skipped\n", report.Uploaded, report.Failed, report.Skipped)
+		}
+		if err != nil {
+			logging.Fatal("Failed to retry", "error", err)
+		}
+
+	default:
+		usage()
+	}
+}
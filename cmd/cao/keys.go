@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rag/apikeys"
+	"rag/auth"
+	"rag/logging"
+)
+
+// keysDatabase returns the API key database from the environment, or the default
+func keysDatabase() string {
+	if path := os.Getenv("API_KEYS_DB"); path != "" {
+		return path
+	}
+	return "keys.db"
+}
+
+func runKeys(args []string) {
+	if len(args) < 1 {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	db := flags.String("db", keysDatabase(), "API key database")
+	name := flags.String("name", "", "issue: name of the key holder")
+	scopes := flags.String("scopes", string(auth.RoleReader), "issue: comma-separated roles (reader, ingester, admin)")
+	expires := flags.Duration("expires", 0, "issue: validity, e.g. 2160h; 0 never expires")
+	flags.Parse(args[1:])
+
+	store, err := apikeys.Open(*db)
+	if err != nil {
+		logging.Fatal("Failed to open API key database", "error", err)
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "issue":
+		var roles []auth.Role
+		for _, scope := range strings.Split(*scopes, ",") {
+			roles = append(roles, auth.Role(strings.TrimSpace(scope)))
+		}
+		secret, key, err := store.Issue(*name, roles, *expires)
+		if err != nil {
+			logging.Fatal("Failed to issue key", "error", err)
+		}
+		fmt.Printf("Issued key %s for %s\n\n%s\n\nStore it now; it cannot be shown again.\n", key.ID, key.Name, secret)
+
+	case "list":
+		keys, err := store.List()
+		if err != nil {
+			logging.Fatal("Failed to list keys", "error", err)
+		}
+
+		now := time.Now()
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tEXPIRES\tSTATUS")
+		for _, key := range keys {
+			expiry := "never"
+			if key.ExpiresAt != nil {
+				expiry = key.ExpiresAt.Format(time.DateOnly)
+			}
+			status := "active"
+			switch {
+			case key.RevokedAt != nil:
+				status = "revoked"
+			case !key.Active(now):
+				status = "expired"
+			}
+			scopes := make([]string, 0, len(key.Scopes))
+			for _, scope := range key.Scopes {
+				scopes = append(scopes, string(scope))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s…\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, strings.Join(scopes, ","), expiry, status)
+		}
+		tw.Flush()
+
+	case "rotate":
+		if flags.NArg() != 1 {
+			usage()
+		}
+		secret, key, err := store.Rotate(flags.Arg(0))
+		if err != nil {
+			logging.Fatal("Failed to rotate key", "error", err)
+		}
+		fmt.Printf("Rotated key %s for %s\n\n%s\n\nThe previous secret no longer works.\n", key.ID, key.Name, secret)
+
+	case "revoke":
+		if flags.NArg() != 1 {
+			usage()
+		}
+		if err := store.Revoke(flags.Arg(0)); err != nil {
+			logging.Fatal("Failed to revoke key", "error", err)
+		}
+		fmt.Printf("Revoked key %s\n", flags.Arg(0))
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"rag/loadtest"
+	"rag/logging"
+)
+
+func runLoadtest(args []string) {
+	flags := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	url := flags.String("url", "", "Server base URL, e.g. http://localhost:8080; empty uses -fake")
+	fake := flags.Duration("fake", 800*time.Millisecond, "Model latency of the in-process fake backend used without -url")
+	store := flags.String("store", loadtest.FakeStore, "Store to query")
+	key := flags.String("key", os.Getenv("CAO_API_KEY"), "API key sent as X-API-Key")
+	sessions := flags.Int("sessions", 20, "Concurrent chat sessions")
+	duration := flags.Duration("duration", time.Minute, "Test duration")
+	think := flags.Duration("think", 3*time.Second, "Average think time between questions")
+	history := flags.Int("history", 6, "History messages sent with each query")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	target := *url
+	if target == "" {
+		server, err := loadtest.NewFakeServer(ctx, *fake)
+		if err != nil {
+			logging.Fatal("Failed to start fake server", "error", err)
+		}
+		defer server.Close()
+		target = server.URL
+		slog.Info("Using in-process server with a fake backend", "modelLatency", *fake)
+	}
+
+	slog.Info("Running load test", "sessions", *sessions, "target", target, "duration", *duration)
+	report, err := loadtest.Run(ctx, &loadtest.Config{
+		URL:          target,
+		StoreName:    *store,
+		APIKey:       *key,
+		Sessions:     *sessions,
+		Duration:     *duration,
+		ThinkTime:    *think,
+		HistoryDepth: *history,
+	}, nil)
+	if err != nil {
+		logging.Fatal("Load test failed", "error", err)
+	}
+	fmt.Print(report)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"rag/logging"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [arguments]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\nCommands:\n")
+	fmt.Fprintf(os.Stderr, "  pipeline ingest <spec.yaml>               Ingest the documents of a pipeline\n")
+	fmt.Fprintf(os.Stderr, "  pipeline query <spec.yaml> \"question\"     Query the index of a pipeline\n")
+	fmt.Fprintf(os.Stderr, "  bootstrap <corpus.yaml>                   Create the stores, documents and profiles of a corpus\n")
+	fmt.Fprintf(os.Stderr, "  ingest [-store name] [-format f] < input  Upload documents streamed on stdin (tar or NDJSON)\n")
+	fmt.Fprintf(os.Stderr, "  sync [-store name] [-delete] <dir>        Mirror a directory into a store by content hash\n")
+	fmt.Fprintf(os.Stderr, "  delete [-store name] [-dry-run] \"filter\"   Delete the documents matching a metadata filter\n")
+	fmt.Fprintf(os.Stderr, "  jobs failed                               List documents that failed to ingest\n")
+	fmt.Fprintf(os.Stderr, "  jobs retry                                Retry the documents that failed to ingest\n")
+	fmt.Fprintf(os.Stderr, "  keys issue -name n [-scopes r] [-expires d] Issue a server API key\n")
+	fmt.Fprintf(os.Stderr, "  keys list                                 List server API keys\n")
+	fmt.Fprintf(os.Stderr, "  keys rotate|revoke <id>                   Rotate or revoke a server API key\n")
+	fmt.Fprintf(os.Stderr, "  retention [-config f] [-dry-run]          Delete documents beyond the store retention policies\n")
+	fmt.Fprintf(os.Stderr, "  clone <store> <new-store>                 Copy a store into a new store for experiments\n")
+	fmt.Fprintf(os.Stderr, "  export [-o file] <store>                  Write the document manifest of a store\n")
+	fmt.Fprintf(os.Stderr, "  import <manifest.json> <store>            Upload the documents of a manifest into a store\n")
+	fmt.Fprintf(os.Stderr, "  migrate <store> <new-store>               Upload the documents of a store into another store\n")
+	fmt.Fprintf(os.Stderr, "  loadtest [flags]                          Simulate concurrent chat sessions and report latency\n")
+	fmt.Fprintf(os.Stderr, "  watch add [-store name] \"question\"        Watch a question for answer changes\n")
+	fmt.Fprintf(os.Stderr, "  watch list|check                          List watched questions or re-ask them\n")
+	fmt.Fprintf(os.Stderr, "  watch remove <id>                         Stop watching a question\n")
+	fmt.Fprintf(os.Stderr, "  canary run [-canaries f] [-log f]         Ask the canary questions and report degraded stores\n")
+	fmt.Fprintf(os.Stderr, "  index build [flags]                       Build a local vector index from cached documents\n")
+	fmt.Fprintf(os.Stderr, "  eval run [-dir d] [-threshold t] [-v]     Replay eval cases and check the accepted answers\n")
+	fmt.Fprintf(os.Stderr, "  eval generate [-store name] [-n n] [-check] Generate candidate eval cases from the documents\n")
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	logging.Setup()
+
+	switch os.Args[1] {
+	case "pipeline":
+		runPipeline(os.Args[2:])
+	case "bootstrap":
+		runBootstrap(os.Args[2:])
+	case "ingest":
+		runIngest(os.Args[2:])
+	case "sync":
+		runSync(os.Args[2:])
+	case "delete":
+		runDelete(os.Args[2:])
+	case "jobs":
+		runJobs(os.Args[2:])
+	case "keys":
+		runKeys(os.Args[2:])
+	case "retention":
+		runRetention(os.Args[2:])
+	case "clone":
+		runClone(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "loadtest":
+		runLoadtest(os.Args[2:])
+	case "index":
+		runIndex(os.Args[2:])
+	case "watch":
+		runWatch(os.Args[2:])
+	case "canary":
+		runCanary(os.Args[2:])
+	case "eval":
+		runEval(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+// apiKey returns the Gemini API key from the environment or exits
+func apiKey() string {
+	key := os.Getenv("GEMINI_API_KEY")
+	if key == "" {
+		logging.Fatal("GEMINI_API_KEY environment variable not set")
+	}
+	return key
+}
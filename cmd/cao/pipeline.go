@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"rag/logging"
+	"rag/pipeline"
+)
+
+func runPipeline(args []string) {
+	if len(args) < 2 {
+		usage()
+	}
+
+	spec, err := pipeline.LoadSpec(args[1])
+	if err != nil {
+		logging.Fatal("Failed to load pipeline", "error", err)
+	}
+
+	ctx := context.Background()
+	// Pipelines on the local backend run on-prem without a Gemini API key
+	key := ""
+	if spec.Index.Backend != "local" {
+		key = apiKey()
+	}
+	runner, err := pipeline.NewRunner(ctx, spec, key,
+		pipeline.WithProfiles(storeProfiles()),
+		pipeline.WithCitationPolicy(citationPolicy()),
+		pipeline.WithRetryPolicy(retryPolicy()),
+	)
+	if err != nil {
+		logging.Fatal("Failed to create pipeline", "error", err)
+	}
+
+	switch args[0] {
+	case "ingest":
+		slog.Info("Ingesting pipeline", "pipeline", spec.Name, "store", spec.Index.Store)
+		report, err := runner.Ingest(ctx)
+		if err != nil {
+			logging.Fatal("Failed to ingest", "pipeline", spec.Name, "error", err)
+		}
+		fmt.Printf("\nIngest complete: %d uploaded, %d skipped, %d failed, %d rejected\n", report.Uploaded, report.Skipped, report.Failed, report.Rejected)
+		printQualityIssues(report.Issues)
+
+		provider, err := runner.Provider()
+		if err != nil {
+			logging.Fatal("Failed to create provider", "pipeline", spec.Name, "error", err)
+		}
+		checkWatchesAfterSync(ctx, provider, report.Uploaded)
+
+	case "query":
+		if len(args) < 3 {
+			usage()
+		}
+		question := strings.Join(args[2:], " ")
+
+		resp, err := runner.Query(ctx, question)
+		if errors.Is(err, pipeline.ErrQueryRejected) {
+			fmt.Println(err)
+			return
+		}
+		if err != nil {
+			logging.Fatal("Failed to query", "pipeline", spec.Name, "error", err)
+		}
+
+		fmt.Println("=== Answer ===")
+		for _, part := range resp.Parts {
+			fmt.Println(part)
+		}
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"rag/filesearch"
+	"rag/logging"
+	"rag/retention"
+
+	"google.golang.org/genai"
+)
+
+func runRetention(args []string) {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	config := flags.String("config", "retention.yaml", "Retention policies per store")
+	dryRun := flags.Bool("dry-run", false, "Only list the documents that would be deleted")
+	flags.Parse(args)
+	requireGemini("retention")
+
+	cfg, err := retention.LoadConfig(*config)
+	if err != nil {
+		logging.Fatal("Failed to load retention policies", "error", err)
+	}
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:  apiKey(),
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	report, err := retention.Enforce(ctx, service, cfg, *dryRun)
+	if report != nil {
+		for _, e := range report.Evictions {
+			fmt.Printf("%-14s %s\n", e.Reason, e.Document.DisplayName)
+		}
+		if *dryRun {
+			fmt.Printf("\nDry run: %d documents would be deleted\n", len(report.Evictions))
+		} else {
+			fmt.Printf("\nRetention complete: %d deleted, %d failed\n", report.Deleted, report.Failed)
+		}
+	}
+	if err != nil {
+		logging.Fatal("Failed to enforce retention", "error", err)
+	}
+}
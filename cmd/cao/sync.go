@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"rag/filesearch"
+	"rag/logging"
+	"rag/validity"
+
+	"google.golang.org/genai"
+)
+
+func runSync(args []string) {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	storeName := flags.String("store", "cao-documents", "Store to mirror the directory into, created when missing")
+	pattern := flags.String("pattern", "*.pdf", "File names to upload, e.g. *.md")
+	remove := flags.Bool("delete", false, "Delete the documents of the store missing from the directory")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	requireGemini("sync")
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey(),
+		Backend:           genai.BackendGeminiAPI,
+		Retry:             retryPolicy(),
+		RequestsPerMinute: rateLimit("GEMINI_REQUESTS_PER_MINUTE"),
+		UploadsPerMinute:  rateLimit("GEMINI_UPLOADS_PER_MINUTE"),
+	})
+	if err != nil {
+		logging.Fatal("Failed to create service", "error", err)
+	}
+
+	store, err := service.GetStoreByName(ctx, *storeName)
+	if err != nil {
+		store, err = service.CreateStore(ctx, *storeName)
+		if err != nil {
+			logging.Fatal("Failed to create store", "store", *storeName, "error", err)
+		}
+	}
+
+	report, err := service.SyncDirectory(ctx, flags.Arg(0), store.Name, &filesearch.SyncOptions{
+		Pattern: *pattern,
+		Delete:  *remove,
+		Inspect: validity.Metadata,
+	})
+	if err != nil {
+		logging.Fatal("Failed to sync directory", "dir", flags.Arg(0), "error", err)
+	}
+	for _, change := range report.Changes {
+		if change.Error != "" {
+			fmt.Printf("%-8s %s: %s\n", change.Action, change.Document, change.Error)
+		} else {
+			fmt.Printf("%-8s %s\n", change.Action, change.Document)
+		}
+	}
+	fmt.Printf("\nSync complete: %d uploaded, %d replaced, %d deleted, %d unchanged, %d failed\n",
+		report.Count(filesearch.SyncUpload), report.Count(filesearch.SyncReplace), report.Count(filesearch.SyncDelete),
+		report.Unchanged, len(report.Failed()))
+
+	checkWatchesAfterSync(ctx, service, report.Count(filesearch.SyncUpload)+report.Count(filesearch.SyncReplace))
+	if len(report.Failed()) > 0 {
+		os.Exit(1)
+	}
+}
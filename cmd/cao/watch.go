@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"rag/filesearch"
+	"rag/logging"
+	"rag/monitor"
+)
+
+// watchesFile returns the watched questions file from the environment, or the default
+func watchesFile() string {
+	if path := os.Getenv("WATCHES_FILE"); path != "" {
+		return path
+	}
+	return "watches.json"
+}
+
+// watchNotifier logs changes and also posts them to WATCH_WEBHOOK when set
+func watchNotifier() monitor.Notifier {
+	url := os.Getenv("WATCH_WEBHOOK")
+	if url == "" {
+		return monitor.LogNotifier
+	}
+	webhook := monitor.Webhook(url, nil)
+	return monitor.NotifierFunc(func(ctx context.Context, change *monitor.Change) error {
+		monitor.LogNotifier(ctx, change)
+		return webhook.Notify(ctx, change)
+	})
+}
+
+func runWatch(args []string) {
+	if len(args) < 1 {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("watch "+args[0], flag.ExitOnError)
+	path := flags.String("watches", watchesFile(), "JSON file with the watched questions")
+	storeName := flags.String("store", "cao-documents", "Store the question is asked on")
+	flags.Parse(args[1:])
+
+	registry, err := monitor.LoadRegistry(*path)
+	if err != nil {
+		logging.Fatal("Failed to load watches", "error", err)
+	}
+
+	switch args[0] {
+	case "add":
+		question := strings.Join(flags.Args(), " ")
+		w, err := registry.Add(*storeName, question)
+		if err != nil {
+			logging.Fatal("Failed to add watch", "error", err)
+		}
+		fmt.Printf("Watching %s: %s\n", w.ID, w.Question)
+
+	case "list":
+		watches := registry.List()
+		if len(watches) == 0 {
+			fmt.Println("No watched questions")
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTORE\tCHECKED\tCHANGED\tQUESTION")
+		for _, w := range watches {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", w.ID, w.Store, formatTime(w.CheckedAt), formatTime(w.ChangedAt), w.Question)
+		}
+		tw.Flush()
+
+	case "remove":
+		if flags.NArg() != 1 {
+			usage()
+		}
+		if err := registry.Remove(flags.Arg(0)); err != nil {
+			logging.Fatal("Failed to remove watch", "error", err)
+		}
+
+	case "check":
+		ctx := context.Background()
+		checkWatches(ctx, newProvider(ctx), registry)
+
+	default:
+		usage()
+	}
+}
+
+// checkWatches re-asks the watched questions and reports the changed answers
+func checkWatches(ctx context.Context, provider filesearch.Provider, registry *monitor.Registry) {
+	changes, err := registry.Check(ctx, provider, watchNotifier())
+	if err != nil {
+		slog.Warn("Watch check incomplete", "error", err)
+	}
+	fmt.Printf("\nWatch check complete: %d questions, %d changed\n", len(registry.List()), len(changes))
+}
+
+// checkWatchesAfterSync re-asks the watched questions after documents were uploaded,
+// when a watches file exists
+func checkWatchesAfterSync(ctx context.Context, provider filesearch.Provider, uploaded int) {
+	path := watchesFile()
+	if uploaded == 0 {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	registry, err := monitor.LoadRegistry(path)
+	if err != nil {
+		slog.Warn("Failed to load watches", "path", path, "error", err)
+		return
+	}
+	checkWatches(ctx, provider, registry)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
@@ -0,0 +1,82 @@
+package conversations_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rag/conversations"
+	"rag/filesearch"
+	"rag/filesearchtest"
+)
+
+func Example() {
+	dir, err := os.MkdirTemp("", "conversations")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := conversations.Open(filepath.Join(dir, "conversations.db"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	fake.AddDocument(store.Name, "302-2023-004512.pdf", "Een werkman in de horeca heeft recht op 20 vakantiedagen.", nil)
+	fake.Answer = func(prompt string, chunks []*filesearch.RetrievedChunk) string {
+		// The follow-up is answered with the first question in its history
+		if strings.Contains(prompt, "Previous conversation:\nuser: Hoeveel vakantiedagen") {
+			return "Ook een werkman in deeltijd heeft recht op vakantiedagen, in verhouding tot zijn arbeidsduur."
+		}
+		return chunks[0].Text
+	}
+	subject := func(r *http.Request) string { return r.Header.Get("X-Subject") }
+	handler := filesearch.NewHandler(fake, filesearch.WithConversations(conversations.NewRecorder(db), subject))
+
+	ask := func(caller string, body string) *filesearch.QueryResponse {
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+		req.Header.Set("X-Subject", caller)
+		rec := httptest.NewRecorder()
+		handler.Query(rec, req)
+		var resp filesearch.QueryResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			log.Fatal(err)
+		}
+		return &resp
+	}
+
+	// The first question starts a session; the follow-up only sends its ID
+	first := ask("hr-portal", `{"query": "Hoeveel vakantiedagen heeft een werkman in de horeca?", "storeName": "cao-documents"}`)
+	ask("hr-portal", `{"query": "En in deeltijd?", "storeName": "cao-documents", "sessionId": "`+first.SessionID+`"}`)
+
+	// Another caller cannot continue the session, and a made-up ID gets a new session
+	fmt.Println(ask("intranet", `{"query": "En in deeltijd?", "storeName": "cao-documents", "sessionId": "`+first.SessionID+`"}`).Error)
+	chosen := ask("intranet", `{"query": "Hoeveel vakantiedagen in de horeca?", "storeName": "cao-documents", "sessionId": "my-session"}`)
+	fmt.Println(chosen.SessionID != "my-session" && chosen.SessionID != first.SessionID)
+
+	session, err := db.Session(context.Background(), first.SessionID, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(session.Owner)
+	for _, m := range session.Messages {
+		fmt.Printf("%s: %s %d\n", m.Role, m.Content, len(m.Sources))
+	}
+	// Output:
+	// chat session belongs to another caller
+	// true
+	// hr-portal
+	// user: Hoeveel vakantiedagen heeft een werkman in de horeca? 0
+	// assistant: Een werkman in de horeca heeft recht op 20 vakantiedagen. 1
+	// user: En in deeltijd? 0
+	// assistant: Ook een werkman in deeltijd heeft recht op vakantiedagen, in verhouding tot zijn arbeidsduur. 1
+}
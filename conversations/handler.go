@@ -0,0 +1,53 @@
+package conversations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Handler provides the HTTP admin API for chat sessions
+type Handler struct {
+	store Store
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(store Store) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+// Session handles GET requests for a chat session with all its messages, oldest first
+// GET /admin/conversations/{id}
+func (h *Handler) Session(w http.ResponseWriter, r *http.Request) {
+	session, err := h.store.Session(r.Context(), r.PathValue("id"), 0)
+	if err != nil {
+		writeJSON(w, statusFor(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
+
+// Delete handles DELETE requests removing a chat session, e.g. on request of the user
+// DELETE /admin/conversations/{id}
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeJSON(w, statusFor(err), map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func statusFor(err error) int {
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
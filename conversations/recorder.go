@@ -0,0 +1,54 @@
+package conversations
+
+import (
+	"context"
+	"errors"
+
+	"rag/filesearch"
+)
+
+// Recorder keeps the chat sessions answered by a filesearch.Handler in a Store, see
+// filesearch.WithConversations
+type Recorder struct {
+	store Store
+}
+
+var _ filesearch.ConversationStore = (*Recorder)(nil)
+
+// NewRecorder creates a recorder keeping sessions in store
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{
+		store: store,
+	}
+}
+
+// LoadConversation returns the last maxMessages messages of a session of owner and its
+// summary, filesearch.ErrUnknownSession for an unknown session, or filesearch.ErrSessionOwner
+// for the session of another owner
+func (r *Recorder) LoadConversation(ctx context.Context, sessionID string, owner string, maxMessages int) ([]filesearch.HistoryMessage, *filesearch.ConversationSummary, error) {
+	session, err := r.store.Session(ctx, sessionID, maxMessages)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, filesearch.ErrUnknownSession
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.Owner != owner {
+		return nil, nil, filesearch.ErrSessionOwner
+	}
+
+	history := make([]filesearch.HistoryMessage, 0, len(session.Messages))
+	for _, m := range session.Messages {
+		history = append(history, filesearch.HistoryMessage{Role: m.Role, Content: m.Content})
+	}
+	return history, session.Summary, nil
+}
+
+// SaveTurn appends a query and its answer, with the cited sources, to the session of the
+// query, creating it for owner, and keeps the updated summary
+func (r *Recorder) SaveTurn(ctx context.Context, owner string, req *filesearch.QueryRequest, resp *filesearch.QueryResponse) error {
+	return r.store.Append(ctx, req.SessionID, owner, req.StoreName, resp.Summary,
+		&Message{Role: "user", Content: req.Query},
+		&Message{Role: "assistant", Content: resp.Answer, Sources: resp.Sources, QueryID: resp.QueryID},
+	)
+}
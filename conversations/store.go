@@ -0,0 +1,285 @@
+// Package conversations keeps chat sessions server-side: the questions and answers of every
+// session, the sources the answers cite and the running summary, so clients send the ID of
+// their session instead of its history. Sessions are kept in SQLite, or in Postgres when
+// replicas share them.
+package conversations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"rag/filesearch"
+
+	_ "modernc.org/sqlite"
+)
+
+// Dialects of the SQL databases sessions are kept in
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// ErrNotFound is returned for unknown sessions
+var ErrNotFound = errors.New("conversation not found")
+
+// ErrOwner is returned when appending to the session of another owner
+var ErrOwner = errors.New("conversation belongs to another owner")
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS conversation_sessions (
+	id         TEXT PRIMARY KEY,
+	owner      TEXT NOT NULL,
+	store_name TEXT NOT NULL,
+	summary    TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	updated_at BIGINT NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS conversation_messages (
+	session_id TEXT NOT NULL,
+	seq        INTEGER NOT NULL,
+	role       TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	sources    TEXT NOT NULL,
+	query_id   TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	PRIMARY KEY (session_id, seq)
+)`,
+}
+
+// Message is a question or an answer in a session
+type Message struct {
+	Role      string                       `json:"role"` // "user" or "assistant"
+	Content   string                       `json:"content"`
+	Sources   []*filesearch.SourceDocument `json:"sources,omitempty"` // Documents cited by an answer
+	QueryID   string                       `json:"queryId,omitempty"` // Logged answer, see analytics
+	CreatedAt time.Time                    `json:"createdAt"`
+}
+
+// Session is a chat session with its messages, oldest first
+type Session struct {
+	ID        string                          `json:"id"`
+	Owner     string                          `json:"owner,omitempty"` // Subject that started the session, empty for anonymous callers
+	StoreName string                          `json:"storeName"`       // Store the first question was asked to, by display name
+	Summary   *filesearch.ConversationSummary `json:"summary,omitempty"`
+	Messages  []*Message                      `json:"messages"`
+	CreatedAt time.Time                       `json:"createdAt"`
+	UpdatedAt time.Time                       `json:"updatedAt"`
+}
+
+// Store keeps chat sessions and their messages
+type Store interface {
+	// Session returns a session with its last maxMessages messages, all of them when
+	// maxMessages is zero, or ErrNotFound
+	Session(ctx context.Context, id string, maxMessages int) (*Session, error)
+	// Append appends messages to a session of owner, creating it for storeName, and replaces
+	// its summary; it returns ErrOwner for the session of another owner
+	Append(ctx context.Context, id string, owner string, storeName string, summary *filesearch.ConversationSummary, messages ...*Message) error
+	// Delete removes a session and its messages, or returns ErrNotFound
+	Delete(ctx context.Context, id string) error
+	// Close releases the store
+	Close() error
+}
+
+// DB keeps chat sessions in a SQLite or Postgres database
+type DB struct {
+	db      *sql.DB
+	dialect string
+	now     func() time.Time
+}
+
+var _ Store = (*DB)(nil)
+
+// Open opens, or creates, the session database at location: a postgres:// URL, which needs a
+// Postgres driver registered as "postgres" linked into the binary, e.g. github.com/lib/pq, or
+// else the path of a SQLite database
+func Open(location string) (*DB, error) {
+	dialect := SQLite
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		if !slices.Contains(sql.Drivers(), "postgres") {
+			return nil, fmt.Errorf("no Postgres driver linked for conversation database %s", u.Redacted())
+		}
+		dialect = Postgres
+	}
+
+	db, err := sql.Open(dialect, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open conversation database: %w", err)
+	}
+	store, err := New(db, dialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// New keeps chat sessions in an open database of a dialect, SQLite or Postgres, creating the
+// tables when missing
+func New(db *sql.DB, dialect string) (*DB, error) {
+	if dialect != SQLite && dialect != Postgres {
+		return nil, fmt.Errorf("unsupported conversation database dialect %q, expected %s or %s", dialect, SQLite, Postgres)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("failed to create conversation tables: %w", err)
+		}
+	}
+	return &DB{db: db, dialect: dialect, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *DB) Close() error {
+	return s.db.Close()
+}
+
+// Session returns a session with its last maxMessages messages, all of them when maxMessages
+// is zero, or ErrNotFound
+func (s *DB) Session(ctx context.Context, id string, maxMessages int) (*Session, error) {
+	session := &Session{ID: id, Messages: make([]*Message, 0)}
+	var summary string
+	var created, updated int64
+	err := s.db.QueryRowContext(ctx, s.bind(`SELECT owner, store_name, summary, created_at, updated_at FROM conversation_sessions WHERE id = ?`), id).
+		Scan(&session.Owner, &session.StoreName, &summary, &created, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	session.CreatedAt = time.Unix(created, 0).UTC()
+	session.UpdatedAt = time.Unix(updated, 0).UTC()
+	if summary != "" {
+		if err := json.Unmarshal([]byte(summary), &session.Summary); err != nil {
+			return nil, fmt.Errorf("failed to decode conversation summary: %w", err)
+		}
+	}
+
+	// The last messages, newest first, reversed below
+	query := `SELECT role, content, sources, query_id, created_at FROM conversation_messages WHERE session_id = ? ORDER BY seq DESC`
+	args := []any{id}
+	if maxMessages > 0 {
+		query += ` LIMIT ?`
+		args = append(args, maxMessages)
+	}
+	rows, err := s.db.QueryContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m Message
+		var sources string
+		if err := rows.Scan(&m.Role, &m.Content, &sources, &m.QueryID, &created); err != nil {
+			return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+		}
+		if sources != "" {
+			if err := json.Unmarshal([]byte(sources), &m.Sources); err != nil {
+				return nil, fmt.Errorf("failed to decode message sources: %w", err)
+			}
+		}
+		m.CreatedAt = time.Unix(created, 0).UTC()
+		session.Messages = append(session.Messages, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
+	}
+	slices.Reverse(session.Messages)
+	return session, nil
+}
+
+// Append appends messages to a session of owner, creating it for storeName, and replaces its
+// summary; it returns ErrOwner for the session of another owner
+func (s *DB) Append(ctx context.Context, id string, owner string, storeName string, summary *filesearch.ConversationSummary, messages ...*Message) error {
+	var encoded []byte
+	if !summary.IsEmpty() {
+		var err error
+		if encoded, err = json.Marshal(summary); err != nil {
+			return fmt.Errorf("failed to encode conversation summary: %w", err)
+		}
+	}
+	now := s.now().UTC().Truncate(time.Second)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, s.bind(`INSERT INTO conversation_sessions (id, owner, store_name, summary, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET summary = excluded.summary, updated_at = excluded.updated_at
+		WHERE conversation_sessions.owner = excluded.owner`),
+		id, owner, storeName, string(encoded), now.Unix(), now.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOwner
+	}
+
+	var seq int
+	if err := tx.QueryRowContext(ctx, s.bind(`SELECT COALESCE(MAX(seq), 0) FROM conversation_messages WHERE session_id = ?`), id).Scan(&seq); err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	for _, m := range messages {
+		var sources []byte
+		if len(m.Sources) > 0 {
+			if sources, err = json.Marshal(m.Sources); err != nil {
+				return fmt.Errorf("failed to encode message sources: %w", err)
+			}
+		}
+		seq++
+		_, err = tx.ExecContext(ctx, s.bind(`INSERT INTO conversation_messages (session_id, seq, role, content, sources, query_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+			id, seq, m.Role, m.Content, string(sources), m.QueryID, now.Unix())
+		if err != nil {
+			return fmt.Errorf("failed to save conversation message: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete removes a session and its messages, or returns ErrNotFound
+func (s *DB) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, s.bind(`DELETE FROM conversation_sessions WHERE id = ?`), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, s.bind(`DELETE FROM conversation_messages WHERE session_id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	return tx.Commit()
+}
+
+// bind rewrites the ? placeholders of a query to $1, $2, ... for Postgres
+func (s *DB) bind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,260 @@
+package corpus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+
+	"rag/caoscrape"
+	"rag/entities"
+	"rag/filesearch"
+	"rag/validity"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Report summarizes a bootstrap run
+type Report struct {
+	Stores []*StoreReport
+}
+
+// StoreReport summarizes the bootstrap of a single store
+type StoreReport struct {
+	Name     string
+	Created  bool // The store did not exist yet
+	Uploaded int
+	Skipped  int // Already in the store
+	Failed   int
+}
+
+// document is a document of a source, waiting to be uploaded
+type document struct {
+	name      string
+	path      string // Local file, for directory sources
+	sourceURL string
+	metadata  map[string]any
+	fetch     func(string) (io.Reader, error) // Downloads sourceURL, nil for a plain GET
+}
+
+// Bootstrap creates the stores of a definition that do not exist yet, uploads the documents
+// they lack and writes the profiles of the stores to the profiles file, keeping the profiles
+// of other stores in it, and the stores of JC shards to the entity index. Documents already in a store are skipped by display name, so running
+// it again only adds what is new. A nil scraper uses a default CAO portal client.
+func Bootstrap(ctx context.Context, service *filesearch.Service, scraper *caoscrape.Client, def *Definition) (*Report, error) {
+	if scraper == nil {
+		scraper = caoscrape.NewClient()
+	}
+
+	report := &Report{}
+	for _, spec := range def.Stores {
+		sr, err := bootstrapStore(ctx, service, scraper, spec)
+		if sr != nil {
+			report.Stores = append(report.Stores, sr)
+		}
+		if err != nil {
+			return report, fmt.Errorf("store %s: %w", spec.Name, err)
+		}
+	}
+
+	if def.Profiles != "" {
+		if err := writeProfiles(def.Profiles, def.Stores); err != nil {
+			return report, err
+		}
+	}
+	if def.Entities != "" {
+		if err := writeEntities(def.Entities, def.Stores); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// bootstrapStore creates a store when it is missing and uploads its new documents
+func bootstrapStore(ctx context.Context, service *filesearch.Service, scraper *caoscrape.Client, spec *Store) (*StoreReport, error) {
+	sr := &StoreReport{Name: spec.Name}
+	store, err := service.GetStoreByName(ctx, spec.Name)
+	if err != nil {
+		if store, err = service.CreateStore(ctx, spec.Name); err != nil {
+			return nil, err
+		}
+		sr.Created = true
+	}
+
+	existing := make(map[string]bool)
+	if !sr.Created {
+		docs, err := service.ListDocuments(ctx, store.Name)
+		if err != nil {
+			return sr, err
+		}
+		for _, doc := range docs {
+			existing[doc.DisplayName] = true
+		}
+	}
+
+	for _, source := range spec.Sources {
+		docs, err := collect(scraper, source)
+		if err != nil {
+			return sr, err
+		}
+		for _, doc := range docs {
+			if existing[doc.name] {
+				sr.Skipped++
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return sr, err
+			}
+
+			metadata := maps.Clone(spec.Metadata)
+			if metadata == nil {
+				metadata = make(map[string]any)
+			}
+			maps.Copy(metadata, source.Metadata)
+			maps.Copy(metadata, doc.metadata)
+			opts := &filesearch.UploadOptions{
+				DisplayName:    doc.name,
+				Metadata:       metadata,
+				CustomMetadata: filesearch.AccessMetadata(spec.AccessLabel),
+				Inspect:        validity.Metadata,
+				Fetch:          doc.fetch,
+			}
+
+			if doc.path != "" {
+				_, err = service.UploadFile(ctx, doc.path, store.Name, opts)
+			} else {
+				_, err = service.UploadFromURL(ctx, doc.sourceURL, store.Name, opts)
+			}
+			if err != nil {
+				log.Printf("Warning: Failed to upload %s to %s: %v", doc.name, spec.Name, err)
+				sr.Failed++
+				continue
+			}
+			existing[doc.name] = true
+			sr.Uploaded++
+		}
+	}
+	return sr, nil
+}
+
+// collect lists the documents of a source
+func collect(scraper *caoscrape.Client, source *Source) ([]*document, error) {
+	var docs []*document
+	switch {
+	case len(source.JC) > 0:
+		for _, jc := range source.JC {
+			urls, err := scraper.Search(&jc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to search JC %d: %w", jc, err)
+			}
+			for _, url := range urls {
+				docs = append(docs, &document{
+					name:      path.Base(url),
+					sourceURL: url,
+					metadata:  map[string]any{"jc_number": jc},
+					fetch:     scraper.DownloadDocument,
+				})
+			}
+		}
+
+	case source.Directory != "":
+		paths, err := filepath.Glob(filepath.Join(source.Directory, source.Pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid source pattern: %w", err)
+		}
+		for _, p := range paths {
+			docs = append(docs, &document{name: filepath.Base(p), path: p})
+		}
+
+	default:
+		for _, url := range source.URLs {
+			docs = append(docs, &document{name: path.Base(url), sourceURL: url})
+		}
+	}
+	return docs, nil
+}
+
+// writeProfiles sets the profiles of the stores in the profiles file, keeping the profiles of
+// other stores and removing those of stores that no longer declare one
+func writeProfiles(file string, stores []*Store) error {
+	profiles := &filesearch.Profiles{}
+	if _, err := os.Stat(file); err == nil {
+		if profiles, err = filesearch.LoadProfiles(file); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read store profiles: %w", err)
+	}
+	if profiles.Stores == nil {
+		profiles.Stores = make(map[string]*filesearch.Profile)
+	}
+
+	for _, store := range stores {
+		if store.Profile != nil {
+			profiles.Stores[store.Name] = store.Profile
+		} else {
+			delete(profiles.Stores, store.Name)
+		}
+	}
+
+	data, err := yaml.Marshal(profiles)
+	if err != nil {
+		return fmt.Errorf("failed to encode store profiles: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write store profiles: %w", err)
+	}
+	return nil
+}
+
+// writeEntities routes the joint committees of JC shards to their store in the entity index
+// file, keeping the other entities in it. Committees new to the file are named after the
+// default index, so questions mentioning them by name are routed too.
+func writeEntities(file string, stores []*Store) error {
+	var list []*entities.Entity
+	if _, err := os.Stat(file); err == nil {
+		index, err := entities.LoadIndex(file)
+		if err != nil {
+			return err
+		}
+		list = index.Entities()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read entity index: %w", err)
+	}
+
+	byJC := make(map[int]*entities.Entity, len(list))
+	for _, e := range list {
+		byJC[e.JC] = e
+	}
+	defaults := entities.DefaultIndex()
+	for _, store := range stores {
+		if store.JC == 0 {
+			continue
+		}
+		if e, ok := byJC[store.JC]; ok {
+			e.Store = store.Name
+			continue
+		}
+		e := &entities.Entity{JC: store.JC, Store: store.Name}
+		if known, ok := defaults.Lookup(store.JC); ok {
+			e.Name, e.Aliases = known.Name, known.Aliases
+		}
+		list = append(list, e)
+		byJC[e.JC] = e
+	}
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode entity index: %w", err)
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write entity index: %w", err)
+	}
+	return nil
+}
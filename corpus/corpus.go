@@ -0,0 +1,192 @@
+// Package corpus sets up a complete environment from a declarative corpus definition: the
+// stores, the documents that feed them with their metadata, and their answer profiles.
+// Bootstrapping is idempotent, so a definition can be applied again after editing it or to
+// recreate an environment from scratch.
+package corpus
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"rag/entities"
+	"rag/filesearch"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition declares the stores of a corpus
+type Definition struct {
+	Profiles string   `yaml:"profiles"` // File the store profiles are written to, for STORE_PROFILES
+	Entities string   `yaml:"entities"` // File the entity index routing questions to JC shards is written to, for ENTITY_INDEX
+	Stores   []*Store `yaml:"stores"`
+}
+
+// Store declares a store, where its documents come from and how it answers
+type Store struct {
+	Name        string              `yaml:"name"`        // Display name
+	Metadata    map[string]any      `yaml:"metadata"`    // Recorded with every document, e.g. sector: horeca
+	AccessLabel string              `yaml:"accessLabel"` // Access label of the documents, see ACCESS_POLICY
+	Profile     *filesearch.Profile `yaml:"profile"`     // Answer profile, written to the profiles file
+	ShardByJC   bool                `yaml:"shardByJC"`   // Split into one store per joint committee, see entities.ShardStore
+	Sources     []*Source           `yaml:"sources"`
+
+	JC int `yaml:"-"` // Joint committee of a store split off by ShardByJC
+}
+
+// Source is one origin of documents; exactly one of JC, Directory and URLs is set
+type Source struct {
+	JC        []int          `yaml:"jc"`        // CAO portal joint committees, recorded as jc_number
+	Directory string         `yaml:"directory"` // Folder to read documents from
+	Pattern   string         `yaml:"pattern"`   // directory: glob for file names (default "*.pdf")
+	URLs      []string       `yaml:"urls"`      // Documents to download
+	Metadata  map[string]any `yaml:"metadata"`  // Recorded with the documents of the source, over the store metadata
+}
+
+// Load reads and validates a corpus definition from a YAML file
+func Load(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus definition: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse parses and validates a corpus definition from YAML
+func Parse(data []byte) (*Definition, error) {
+	var def Definition
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse corpus definition: %w", err)
+	}
+
+	for _, store := range def.Stores {
+		for _, source := range store.Sources {
+			if source != nil && source.Directory != "" && source.Pattern == "" {
+				source.Pattern = "*.pdf"
+			}
+		}
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	def.shard()
+	// The shards must not clash with the names of other stores
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &def, nil
+}
+
+// shard replaces every store split by JC with one store per joint committee, in order of
+// first mention. Parse shards the definition after validating it.
+func (d *Definition) shard() {
+	var stores []*Store
+	for _, store := range d.Stores {
+		if !store.ShardByJC {
+			stores = append(stores, store)
+			continue
+		}
+
+		shards := make(map[int]*Store)
+		add := func(jc int, source *Source) {
+			shard, ok := shards[jc]
+			if !ok {
+				shard = &Store{
+					Name:        entities.ShardStore(store.Name, jc),
+					Metadata:    store.Metadata,
+					AccessLabel: store.AccessLabel,
+					Profile:     store.Profile,
+					JC:          jc,
+				}
+				shards[jc] = shard
+				stores = append(stores, shard)
+			}
+			shard.Sources = append(shard.Sources, source)
+		}
+		for _, source := range store.Sources {
+			if len(source.JC) == 0 {
+				jc, _ := metadataJC(source.Metadata, store.Metadata)
+				add(jc, source)
+				continue
+			}
+			for _, jc := range source.JC {
+				single := *source
+				single.JC = []int{jc}
+				add(jc, &single)
+			}
+		}
+	}
+	d.Stores = stores
+}
+
+// metadataJC returns the joint committee recorded as jc_number in the first metadata setting it
+func metadataJC(metadata ...map[string]any) (int, bool) {
+	for _, m := range metadata {
+		if v, ok := m["jc_number"]; ok {
+			jc, ok := v.(int)
+			return jc, ok
+		}
+	}
+	return 0, false
+}
+
+// Validate checks that every store is named once and every source has a single origin
+func (d *Definition) Validate() error {
+	if len(d.Stores) == 0 {
+		return fmt.Errorf("no stores defined")
+	}
+
+	names := make(map[string]bool)
+	for i, store := range d.Stores {
+		if store == nil || store.Name == "" {
+			return fmt.Errorf("stores[%d]: name is required", i)
+		}
+		if names[store.Name] {
+			return fmt.Errorf("stores[%d]: store %q is defined twice", i, store.Name)
+		}
+		names[store.Name] = true
+
+		if _, err := filesearch.MetadataValues(store.Metadata); err != nil {
+			return fmt.Errorf("stores[%d]: %w", i, err)
+		}
+		if store.Profile != nil && store.Profile.Temperature != nil && (*store.Profile.Temperature < 0 || *store.Profile.Temperature > 2) {
+			return fmt.Errorf("stores[%d]: profile temperature must be between 0 and 2", i)
+		}
+
+		for j, source := range store.Sources {
+			origins := 0
+			if source != nil {
+				for _, set := range []bool{len(source.JC) > 0, source.Directory != "", len(source.URLs) > 0} {
+					if set {
+						origins++
+					}
+				}
+			}
+			if origins != 1 {
+				return fmt.Errorf("stores[%d].sources[%d]: exactly one of jc, directory and urls is required", i, j)
+			}
+			if _, err := filesearch.MetadataValues(source.Metadata); err != nil {
+				return fmt.Errorf("stores[%d].sources[%d]: %w", i, j, err)
+			}
+			if _, ok := metadataJC(source.Metadata, store.Metadata); store.ShardByJC && len(source.JC) == 0 && !ok {
+				return fmt.Errorf("stores[%d].sources[%d]: shardByJC requires jc or a numeric jc_number metadata", i, j)
+			}
+		}
+	}
+
+	if d.Entities != "" && !slices.ContainsFunc(d.Stores, func(store *Store) bool { return store.ShardByJC || store.JC != 0 }) {
+		return fmt.Errorf("entities requires a store with shardByJC")
+	}
+
+	if d.Profiles == "" {
+		for i, store := range d.Stores {
+			if store.Profile != nil {
+				return fmt.Errorf("stores[%d]: profile requires a profiles file", i)
+			}
+		}
+	}
+
+	return nil
+}
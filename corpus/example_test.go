@@ -0,0 +1,126 @@
+package corpus_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rag/corpus"
+	"rag/filesearch"
+	"rag/vcr"
+)
+
+func ExampleParse() {
+	_, err := corpus.Parse([]byte(`
+stores:
+  - name: cao-horeca
+    sources:
+      - jc: [3020000]
+        directory: documents/horeca
+`))
+	fmt.Println(err)
+	// Output:
+	// stores[0].sources[0]: exactly one of jc, directory and urls is required
+}
+
+func ExampleParse_shardByJC() {
+	def, err := corpus.Parse([]byte(`
+entities: entities.json
+stores:
+  - name: cao-documents
+    shardByJC: true
+    sources:
+      - jc: [3020000, 1240000]
+      - directory: documents/horeca
+        metadata:
+          jc_number: 3020000
+`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, store := range def.Stores {
+		fmt.Printf("%s: %d sources\n", store.Name, len(store.Sources))
+	}
+
+	_, err = corpus.Parse([]byte(`
+stores:
+  - name: cao-documents
+    shardByJC: true
+    sources:
+      - urls: [https://example.org/cao-302-2024.pdf]
+`))
+	fmt.Println(err)
+	// Output:
+	// cao-documents-3020000: 2 sources
+	// cao-documents-1240000: 1 sources
+	// stores[0].sources[0]: shardByJC requires jc or a numeric jc_number metadata
+}
+
+func ExampleBootstrap() {
+	// The store exists and holds the 2023 agreement; the 2024 agreement is new
+	rec, err := vcr.New("testdata/bootstrap.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir, err := os.MkdirTemp("", "corpus")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"302-2023-004512.pdf", "302-2024-001207.pdf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("%PDF-1.4 "+name[:14]), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	profiles := filepath.Join(dir, "profiles.yaml")
+	def, err := corpus.Parse([]byte(`profiles: ` + profiles + `
+stores:
+  - name: cao-documents
+    metadata:
+      sector: horeca
+    profile:
+      language: nl
+    sources:
+      - directory: ` + dir + `
+        metadata:
+          jc_number: 3020000
+`))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := corpus.Bootstrap(ctx, service, nil, def)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, store := range report.Stores {
+		fmt.Printf("%s: %d uploaded, %d skipped\n", store.Name, store.Uploaded, store.Skipped)
+	}
+
+	loaded, err := filesearch.LoadProfiles(profiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(loaded.Get("cao-documents").Language)
+	// Output:
+	// cao-documents: 1 uploaded, 1 skipped
+	// nl
+}
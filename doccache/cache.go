@@ -0,0 +1,134 @@
+// Package doccache keeps downloaded documents by source URL, so the uploader, previews and
+// the document source endpoint download every document once and keep serving it when the
+// source site is slow or down.
+package doccache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"sync"
+
+	"rag/filesearch"
+	"rag/storage"
+)
+
+// Downloader downloads documents by URL; *caoscrape.Client implements it
+type Downloader interface {
+	DownloadDocument(url string) (io.Reader, error)
+}
+
+// Cache is a read-through document cache in a storage. Documents are stored under a hash of
+// their source URL and never expire; delete them from the storage to refresh them.
+type Cache struct {
+	store  storage.Storage
+	source Downloader
+
+	mu      sync.Mutex
+	pending map[string]*download // Downloads in flight by URL
+}
+
+// download is a download shared by the callers asking for the same URL at the same time
+type download struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+var _ Downloader = (*Cache)(nil)
+
+// New returns a cache keeping documents in store, e.g. a storage.Dir, and downloading the
+// ones it lacks from source
+func New(store storage.Storage, source Downloader) *Cache {
+	return &Cache{store: store, source: source, pending: make(map[string]*download)}
+}
+
+// Get returns the document at a source URL from the cache, downloading and storing it when it
+// is not cached yet. Concurrent calls for the same URL share one download.
+func (c *Cache) Get(ctx context.Context, sourceURL string) ([]byte, error) {
+	key := c.key(sourceURL)
+	data, err := c.store.Get(ctx, key)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("failed to read cached document: %w", err)
+	}
+
+	c.mu.Lock()
+	d, ok := c.pending[sourceURL]
+	if !ok {
+		d = &download{done: make(chan struct{})}
+		c.pending[sourceURL] = d
+		go c.fetch(sourceURL, key, d)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return d.data, d.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch downloads a document into the cache and completes its pending download
+func (c *Cache) fetch(sourceURL string, key string, d *download) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, sourceURL)
+		c.mu.Unlock()
+		close(d.done)
+	}()
+
+	reader, err := c.source.DownloadDocument(sourceURL)
+	if err != nil {
+		d.err = err
+		return
+	}
+	if d.data, err = io.ReadAll(reader); err != nil {
+		d.err = fmt.Errorf("failed to download document: %w", err)
+		return
+	}
+	// A failed write only costs a later download again
+	if err := c.store.Put(context.Background(), key, d.data); err != nil {
+		log.Printf("Warning: failed to cache %s: %v", sourceURL, err)
+	}
+}
+
+// DownloadDocument implements Downloader, so the cache can stand in for the scraper, e.g. as
+// filesearch.UploadOptions.Fetch
+func (c *Cache) DownloadDocument(sourceURL string) (io.Reader, error) {
+	data, err := c.Get(context.Background(), sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// Document returns the original file of a document from its source_url metadata. Its
+// signature matches filesearch.SourceFetcher and preview.Fetcher.
+func (c *Cache) Document(ctx context.Context, doc *filesearch.Document) ([]byte, error) {
+	sourceURL := doc.CustomMetadata["source_url"]
+	if sourceURL == "" {
+		return nil, fmt.Errorf("document %q has no source URL", doc.DisplayName)
+	}
+	return c.Get(ctx, sourceURL)
+}
+
+// key returns the storage key of a source URL, keeping its extension
+func (c *Cache) key(sourceURL string) string {
+	sum := sha256.Sum256([]byte(sourceURL))
+	name := hex.EncodeToString(sum[:])
+	if u, err := url.Parse(sourceURL); err == nil {
+		name += path.Ext(u.Path)
+	}
+	return name
+}
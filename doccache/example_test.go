@@ -0,0 +1,51 @@
+package doccache_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"rag/doccache"
+	"rag/filesearch"
+	"rag/storage"
+)
+
+// countingSource serves a fixed document and counts the downloads
+type countingSource struct {
+	downloads int
+}
+
+func (s *countingSource) DownloadDocument(url string) (io.Reader, error) {
+	s.downloads++
+	return strings.NewReader("%PDF-1.7 " + url), nil
+}
+
+func ExampleCache() {
+	dir, err := os.MkdirTemp("", "documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := &countingSource{}
+	cache := doccache.New(storage.Dir(dir), source)
+
+	// The uploader downloads the document, the source endpoint later reads it from the cache
+	url := "https://public-search.werk.belgie.be/website-download-service/joint-work-convention/302/302-2023-004512.pdf"
+	if _, err := cache.DownloadDocument(url); err != nil {
+		log.Fatal(err)
+	}
+	data, err := cache.Document(context.Background(), &filesearch.Document{
+		DisplayName:    "302-2023-004512.pdf",
+		CustomMetadata: map[string]string{"source_url": url},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(data[:8]), source.downloads)
+	// Output:
+	// %PDF-1.7 1
+}
@@ -0,0 +1,32 @@
+package entities
+
+// defaultEntities lists frequently consulted joint committees with their common names.
+// They carry no store or filter; deployments add those in their own index file.
+var defaultEntities = []*Entity{
+	{JC: 1000000, Name: "Aanvullend paritair comité voor de werklieden", Aliases: []string{"PC 100"}},
+	{JC: 1110000, Name: "Metaal-, machine- en elektrische bouw", Aliases: []string{"metaalbouw"}},
+	{JC: 1180000, Name: "Voedingsnijverheid", Aliases: []string{"voeding"}},
+	{JC: 1190000, Name: "Handel in voedingswaren", Aliases: []string{"voedingshandel"}},
+	{JC: 1240000, Name: "Bouwbedrijf", Aliases: []string{"bouw", "bouwsector", "construction"}},
+	{JC: 1400000, Name: "Vervoer en logistiek", Aliases: []string{"transport", "logistiek"}},
+	{JC: 1490400, Name: "Metaalhandel"},
+	{JC: 2000000, Name: "Aanvullend paritair comité voor de bedienden", Aliases: []string{"APCB", "PC 200"}},
+	{JC: 2010000, Name: "Zelfstandige kleinhandel", Aliases: []string{"kleinhandel"}},
+	{JC: 2020000, Name: "Bedienden uit de kleinhandel in voedingswaren"},
+	{JC: 3020000, Name: "Hotelbedrijf", Aliases: []string{"horeca", "hotel", "restaurant", "café"}},
+	{JC: 3110000, Name: "Grote kleinhandelszaken", Aliases: []string{"warenhuizen"}},
+	{JC: 3140000, Name: "Haarzorg en schoonheidszorg", Aliases: []string{"kappers", "kapsalon"}},
+	{JC: 3180100, Name: "Diensten voor gezins- en bejaardenhulp van de Franse Gemeenschap"},
+	{JC: 3180200, Name: "Diensten voor gezins- en bejaardenhulp van de Vlaamse Gemeenschap", Aliases: []string{"gezinszorg", "thuiszorg"}},
+	{JC: 3190000, Name: "Opvoedings- en huisvestingsinrichtingen"},
+	{JC: 3220000, Name: "Uitzendarbeid", Aliases: []string{"interim", "uitzendkrachten"}},
+	{JC: 3220100, Name: "Dienstencheques", Aliases: []string{"dienstencheque", "dienstencheques"}},
+	{JC: 3300000, Name: "Gezondheidsinrichtingen en -diensten", Aliases: []string{"ziekenhuizen", "zorgsector"}},
+	{JC: 3370000, Name: "Aanvullend paritair comité voor de non-profitsector", Aliases: []string{"non-profit"}},
+}
+
+// DefaultIndex returns an index of frequently consulted joint committees, useful for recognizing
+// sectors in questions. It does not route anywhere until stores or filters are configured.
+func DefaultIndex() *Index {
+	return NewIndex(defaultEntities)
+}
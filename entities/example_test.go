@@ -0,0 +1,50 @@
+package entities_test
+
+import (
+	"fmt"
+
+	"rag/entities"
+)
+
+func Example() {
+	idx := entities.NewIndex([]*entities.Entity{
+		{JC: 3020000, Name: "Hotelbedrijf", Aliases: []string{"horeca"}, MetadataFilter: "jc_number = 3020000"},
+		{JC: 3180200, Name: "Gezinszorg Vlaamse Gemeenschap", Store: "cao-documents"},
+	})
+
+	for _, query := range []string{
+		"Wat is het minimumloon in de horeca?",
+		"Hoeveel verlof krijg ik in PC 318.02?",
+		"Hoeveel vakantiedagen heb je recht op?",
+	} {
+		route := idx.Route(query)
+		if route == nil {
+			fmt.Println("no route")
+			continue
+		}
+		fmt.Printf("%s: store=%q filter=%q\n", route.Entities[0].Label(), route.Store, route.MetadataFilter)
+	}
+	// Output:
+	// PC 302: store="" filter="(jc_number = 3020000)"
+	// PC 318.02: store="cao-documents" filter=""
+	// no route
+}
+
+func ExampleIndex_RouteJC() {
+	// A corpus split into one store per joint committee, see corpus.Store.ShardByJC
+	idx := entities.NewIndex([]*entities.Entity{
+		{JC: 3020000, Name: "Hotelbedrijf", Aliases: []string{"horeca"}, Store: entities.ShardStore("cao-documents", 3020000)},
+		{JC: 1240000, Name: "Bouwbedrijf", Aliases: []string{"bouw"}, Store: entities.ShardStore("cao-documents", 1240000)},
+	})
+
+	route := idx.Route("Verschilt de eindejaarspremie tussen horeca en bouw?")
+	fmt.Println(route.Store, route.ExtraStores)
+
+	// A question mentioning no sector is answered from the sectors of the user profile
+	fmt.Println(idx.Route("Hoeveel vakantiedagen heb je recht op?") == nil)
+	fmt.Println(idx.RouteJC([]int{1240000}).Store)
+	// Output:
+	// cao-documents-3020000 [cao-documents-1240000]
+	// true
+	// cao-documents-1240000
+}
@@ -0,0 +1,236 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"rag/filesearch"
+)
+
+// Entity is a joint committee (paritair comité) with the names users know it by
+// and where its documents live
+type Entity struct {
+	JC             int      `json:"jc"`                       // Joint committee number in 7-digit form, e.g. 3020000
+	Name           string   `json:"name"`                     // Sector name, e.g. "Hotelbedrijf"
+	Aliases        []string `json:"aliases,omitempty"`        // Other names, e.g. "horeca"
+	Store          string   `json:"store,omitempty"`          // Store display name holding the documents of this sector
+	MetadataFilter string   `json:"metadataFilter,omitempty"` // Filter selecting the documents of this sector within a store
+}
+
+// Label returns the committee in its usual written form, e.g. "PC 302" or "PC 318.02"
+func (e *Entity) Label() string {
+	if sub := (e.JC / 100) % 100; sub != 0 {
+		return fmt.Sprintf("PC %d.%02d", e.JC/10000, sub)
+	}
+	return fmt.Sprintf("PC %d", e.JC/10000)
+}
+
+// Route tells where a query should be answered from
+type Route struct {
+	Store          string    // Store display name, empty to keep the requested store
+	ExtraStores    []string  // Stores of the other sectors, searched together with Store
+	MetadataFilter string    // Metadata filter to apply, empty for none
+	Entities       []*Entity // Entities mentioned in the query
+}
+
+// ShardStore names the store holding the documents of one joint committee when a corpus is
+// split into one store per committee, e.g. "cao-documents-3020000"
+func ShardStore(store string, jc int) string {
+	return fmt.Sprintf("%s-%d", store, jc)
+}
+
+// Index maps JC numbers, sector names and aliases to entities
+type Index struct {
+	entities []*Entity
+	byJC     map[int]*Entity
+	names    []namePattern
+}
+
+type namePattern struct {
+	re     *regexp.Regexp
+	entity *Entity
+}
+
+// jcPattern matches committee references such as "PC 302", "pc302", "CP 318.02",
+// "paritair comité 124" and 7-digit numbers like "1240000"
+var jcPattern = regexp.MustCompile(`(?i)\b(?:pc|cp|jc|paritair\s+comit[eé]|commission\s+paritaire)\s*(?:nr\.?\s*)?(\d{3})(?:[./](\d{1,2}))?\b|\b(\d{3})(\d{2})00\b`)
+
+// NewIndex creates an index over the given entities
+func NewIndex(entities []*Entity) *Index {
+	idx := &Index{
+		entities: entities,
+		byJC:     make(map[int]*Entity, len(entities)),
+	}
+
+	for _, e := range entities {
+		idx.byJC[e.JC] = e
+		for _, name := range append([]string{e.Name}, e.Aliases...) {
+			if name == "" {
+				continue
+			}
+			re := regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(` + regexp.QuoteMeta(name) + `)(?:[^\p{L}\p{N}]|$)`)
+			idx.names = append(idx.names, namePattern{re: re, entity: e})
+		}
+	}
+
+	return idx
+}
+
+// LoadIndex reads entities from a JSON file
+func LoadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entity index: %w", err)
+	}
+
+	var entities []*Entity
+	if err := json.Unmarshal(data, &entities); err != nil {
+		return nil, fmt.Errorf("failed to parse entity index: %w", err)
+	}
+
+	return NewIndex(entities), nil
+}
+
+// Entities returns all entities in the index
+func (idx *Index) Entities() []*Entity {
+	return idx.entities
+}
+
+// Lookup returns the entity for a JC number
+func (idx *Index) Lookup(jc int) (*Entity, bool) {
+	e, ok := idx.byJC[jc]
+	return e, ok
+}
+
+// Match returns the entities mentioned in the query, in order of first mention
+func (idx *Index) Match(query string) []*Entity {
+	type hit struct {
+		pos    int
+		entity *Entity
+	}
+	var hits []hit
+
+	for _, m := range jcPattern.FindAllStringSubmatchIndex(query, -1) {
+		var jc int
+		if m[2] >= 0 {
+			main, _ := strconv.Atoi(query[m[2]:m[3]])
+			sub := 0
+			if m[4] >= 0 {
+				sub, _ = strconv.Atoi(query[m[4]:m[5]])
+			}
+			jc = main*10000 + sub*100
+		} else {
+			main, _ := strconv.Atoi(query[m[6]:m[7]])
+			sub, _ := strconv.Atoi(query[m[8]:m[9]])
+			jc = main*10000 + sub*100
+		}
+		if e, ok := idx.byJC[jc]; ok {
+			hits = append(hits, hit{pos: m[0], entity: e})
+		}
+	}
+
+	for _, np := range idx.names {
+		if loc := np.re.FindStringSubmatchIndex(query); loc != nil {
+			hits = append(hits, hit{pos: loc[2], entity: np.entity})
+		}
+	}
+
+	// Order by position and drop duplicates
+	for i := 1; i < len(hits); i++ {
+		for j := i; j > 0 && hits[j].pos < hits[j-1].pos; j-- {
+			hits[j], hits[j-1] = hits[j-1], hits[j]
+		}
+	}
+	seen := make(map[int]bool)
+	matched := make([]*Entity, 0, len(hits))
+	for _, h := range hits {
+		if !seen[h.entity.JC] {
+			seen[h.entity.JC] = true
+			matched = append(matched, h.entity)
+		}
+	}
+
+	return matched
+}
+
+// Route returns where the query should be answered from, or nil when it mentions no routable sector.
+// A query mentioning several sectors is routed to the stores of all of them, unless only some
+// have a store of their own; their filters are combined.
+func (idx *Index) Route(query string) *Route {
+	return route(idx.Match(query))
+}
+
+// RouteJC returns where a question mentioning no sector should be answered from for a user
+// working in the given joint committees, e.g. from the user profile, or nil when none of them
+// is routable
+func (idx *Index) RouteJC(jc []int) *Route {
+	var matched []*Entity
+	for _, n := range jc {
+		if e, ok := idx.byJC[n]; ok && !slices.Contains(matched, e) {
+			matched = append(matched, e)
+		}
+	}
+	return route(matched)
+}
+
+func route(matched []*Entity) *Route {
+	if len(matched) == 0 {
+		return nil
+	}
+
+	route := &Route{Entities: matched}
+	var filters []string
+	for i, e := range matched {
+		switch {
+		case i == 0:
+			route.Store = e.Store
+		case e.Store == route.Store || slices.Contains(route.ExtraStores, e.Store):
+		case e.Store == "" || route.Store == "":
+			// The documents of one sector are in the requested store, those of another are not
+			return nil
+		default:
+			route.ExtraStores = append(route.ExtraStores, e.Store)
+		}
+		if e.MetadataFilter != "" {
+			filters = append(filters, "("+e.MetadataFilter+")")
+		}
+	}
+	if len(filters) == len(matched) {
+		route.MetadataFilter = strings.Join(filters, " OR ")
+	}
+
+	if route.Store == "" && route.MetadataFilter == "" {
+		return nil
+	}
+	return route
+}
+
+var _ filesearch.SectorRouter = (*Index)(nil)
+
+// RouteQuery implements filesearch.Router
+func (idx *Index) RouteQuery(query string) (string, *filesearch.RetrievalOptions, bool) {
+	return idx.Route(query).retrieval()
+}
+
+// RouteSectors implements filesearch.SectorRouter
+func (idx *Index) RouteSectors(jc []int) (string, *filesearch.RetrievalOptions, bool) {
+	return idx.RouteJC(jc).retrieval()
+}
+
+// retrieval returns the store and retrieval options of a route, ok=false for a nil route
+func (r *Route) retrieval() (string, *filesearch.RetrievalOptions, bool) {
+	if r == nil {
+		return "", nil, false
+	}
+
+	var opts *filesearch.RetrievalOptions
+	if r.MetadataFilter != "" || len(r.ExtraStores) > 0 {
+		opts = &filesearch.RetrievalOptions{MetadataFilter: r.MetadataFilter, ExtraStores: r.ExtraStores}
+	}
+	return r.Store, opts, true
+}
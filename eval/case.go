@@ -0,0 +1,113 @@
+// Package eval keeps conversations with accepted answers as evaluation cases and replays
+// them as regression tests: each question is asked again with the earlier turns as history,
+// and the new answer must still cover its accepted answer.
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Turn is a question of a case and the answer accepted for it. Turns without an accepted
+// answer are asked for context but not checked.
+type Turn struct {
+	Question string `yaml:"question" json:"question"`
+	Answer   string `yaml:"answer,omitempty" json:"answer,omitempty"`
+}
+
+// Case is a question sequence asked on a store
+type Case struct {
+	Name      string     `yaml:"name" json:"name"`
+	Store     string     `yaml:"store" json:"store"`
+	Source    string     `yaml:"source,omitempty" json:"source,omitempty"` // e.g. the chat session the case was taken from
+	CreatedAt *time.Time `yaml:"createdAt,omitempty" json:"createdAt,omitempty"`
+	Turns     []Turn     `yaml:"turns" json:"turns"`
+}
+
+// Validate checks that the case can be saved and replayed
+func (c *Case) Validate() error {
+	switch {
+	case c.Name == "":
+		return errors.New("case name is required")
+	case !validName.MatchString(c.Name):
+		return fmt.Errorf("case name %q may only contain letters, digits, '-' and '_'", c.Name)
+	case c.Store == "":
+		return fmt.Errorf("case %s: store is required", c.Name)
+	case len(c.Turns) == 0:
+		return fmt.Errorf("case %s: no turns", c.Name)
+	}
+
+	checked := 0
+	for i, turn := range c.Turns {
+		if strings.TrimSpace(turn.Question) == "" {
+			return fmt.Errorf("case %s: turn %d has no question", c.Name, i+1)
+		}
+		if turn.Answer != "" {
+			checked++
+		}
+	}
+	if checked == 0 {
+		return fmt.Errorf("case %s: no accepted answers", c.Name)
+	}
+	return nil
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Save writes the case to <dir>/<name>.yaml, refusing to overwrite an existing case
+func (c *Case) Save(dir string) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode case: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create case directory: %w", err)
+	}
+
+	path := filepath.Join(dir, c.Name+".yaml")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write case: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write case: %w", err)
+	}
+	return path, f.Close()
+}
+
+// LoadCases reads every *.yaml case in a directory, in name order
+func LoadCases(dir string) ([]*Case, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	cases := make([]*Case, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read case: %w", err)
+		}
+		var c Case
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("failed to parse case %s: %w", path, err)
+		}
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		cases = append(cases, &c)
+	}
+	return cases, nil
+}
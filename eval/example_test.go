@@ -0,0 +1,76 @@
+package eval_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"rag/eval"
+	"rag/filesearch"
+)
+
+// wageProvider answers from a fixed wage table
+type wageProvider struct{}
+
+func (wageProvider) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	answer := "Het minimumuurloon bedraagt 14,42 EUR."
+	if strings.Contains(prompt, "17 jaar") {
+		answer = "Voor 17 jaar geldt 80% van het minimumuurloon."
+	}
+	return &filesearch.PromptResponse{Parts: []string{answer}}, nil
+}
+
+func ExampleRun() {
+	c := &eval.Case{
+		Name:  "minimumloon-jongeren",
+		Store: "cao-documents",
+		Turns: []eval.Turn{
+			{Question: "Wat is het minimumuurloon?", Answer: "Het minimumuurloon bedraagt 14,05 EUR."},
+			{Question: "En als je 17 jaar bent?", Answer: "Voor 17 jaar geldt 80% van het minimumuurloon."},
+		},
+	}
+
+	result, err := eval.Run(context.Background(), wageProvider{}, c, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, turn := range result.Turns {
+		fmt.Printf("turn %d: %.2f %v %v\n", i+1, turn.Coverage, turn.Missing, turn.Passed)
+	}
+	fmt.Println(result.Passed)
+	// Output:
+	// turn 1: 0.80 [14,05] false
+	// turn 2: 1.00 [] true
+	// false
+}
+
+// wageGenerator generates questions from a fixed wage agreement
+type wageGenerator struct{}
+
+func (wageGenerator) GenerateQuestions(ctx context.Context, doc *filesearch.Document, n int) ([]*filesearch.SyntheticQuestion, error) {
+	return []*filesearch.SyntheticQuestion{
+		{Question: "Wat is het minimumuurloon?", Answer: "Het minimumuurloon bedraagt 14,05 EUR.", Document: doc.DisplayName, Page: 2},
+		{Question: "Hoeveel bedraagt het loon op 17 jaar?", Answer: "Voor 17 jaar geldt 80% van het minimumuurloon.", Document: doc.DisplayName},
+	}, nil
+}
+
+func ExampleGenerate() {
+	doc := &filesearch.Document{DisplayName: "302-2024-003311.pdf"}
+	cases, err := eval.Generate(context.Background(), wageGenerator{}, "cao-documents", doc, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Replaying the candidates shows which questions the assistant answers poorly
+	for _, c := range cases {
+		result, err := eval.Run(context.Background(), wageProvider{}, c, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(c.Name, "|", c.Source, "|", result.Passed)
+	}
+	// Output:
+	// 302-2024-003311-pdf-q1 | generated from 302-2024-003311.pdf, p. 2 | false
+	// 302-2024-003311-pdf-q2 | generated from 302-2024-003311.pdf | true
+}
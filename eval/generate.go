@@ -0,0 +1,49 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"rag/filesearch"
+)
+
+// Generator generates questions a document answers, see filesearch.Service.GenerateQuestions
+type Generator interface {
+	GenerateQuestions(ctx context.Context, doc *filesearch.Document, n int) ([]*filesearch.SyntheticQuestion, error)
+}
+
+// Generate returns candidate cases for a document of a store: one single-turn case for each
+// of up to n questions the model generated from the document, with the answer the document
+// gives as the accepted answer and the page it is on as the source. Candidates are reviewed
+// before they join the eval cases, since the model may misread the document; replaying them
+// right away shows which documents the assistant answers poorly.
+func Generate(ctx context.Context, gen Generator, store string, doc *filesearch.Document, n int) ([]*Case, error) {
+	questions, err := gen.GenerateQuestions(ctx, doc, n)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	prefix := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(doc.DisplayName), "-"), "-")
+	cases := make([]*Case, 0, len(questions))
+	for i, q := range questions {
+		source := "generated from " + q.Document
+		if q.Page > 0 {
+			source += fmt.Sprintf(", p. %d", q.Page)
+		}
+		cases = append(cases, &Case{
+			Name:      fmt.Sprintf("%s-q%d", prefix, i+1),
+			Store:     store,
+			Source:    source,
+			CreatedAt: &now,
+			Turns:     []Turn{{Question: q.Question, Answer: q.Answer}},
+		})
+	}
+	return cases, nil
+}
+
+// invalidNameChars matches what may not appear in a case name, see Case.Validate
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
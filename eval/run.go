@@ -0,0 +1,142 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"rag/filesearch"
+)
+
+// DefaultThreshold is the share of the terms of an accepted answer a new answer must contain
+const DefaultThreshold = 0.6
+
+// TurnResult is the outcome of replaying a turn
+type TurnResult struct {
+	Question string
+	Accepted string
+	Answer   string
+	Coverage float64  // Share of the terms of the accepted answer found in the answer, 1 when not checked
+	Missing  []string // Numbers of the accepted answer missing from the answer
+	Passed   bool
+}
+
+// Result is the outcome of replaying a case
+type Result struct {
+	Case   string
+	Turns  []*TurnResult
+	Passed bool
+}
+
+// storeResolver resolves store display names to store names, see filesearch.Service
+type storeResolver interface {
+	GetStoreByName(ctx context.Context, displayName string) (*filesearch.Store, error)
+}
+
+// Run replays a case: every question is asked with the earlier turns as history, using the
+// accepted answers as the assistant turns where there are any. A turn passes when its answer
+// contains every number and at least threshold of the terms of its accepted answer,
+// DefaultThreshold when 0: amounts and dates must not change, wording may.
+func Run(ctx context.Context, provider filesearch.Provider, c *Case, threshold float64) (*Result, error) {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	storeName := c.Store
+	if resolver, ok := provider.(storeResolver); ok {
+		store, err := resolver.GetStoreByName(ctx, c.Store)
+		if err != nil {
+			return nil, err
+		}
+		storeName = store.Name
+	}
+
+	result := &Result{Case: c.Name, Passed: true}
+	var history []filesearch.HistoryMessage
+	for _, turn := range c.Turns {
+		prompt := filesearch.NewMemory(nil, history, 0).BuildPrompt(turn.Question)
+		resp, err := provider.PromptWithRetrieval(ctx, prompt, storeName, nil)
+		if err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.Name, err)
+		}
+
+		tr := &TurnResult{
+			Question: turn.Question,
+			Accepted: turn.Answer,
+			Answer:   resp.Text(),
+			Coverage: 1,
+			Passed:   true,
+		}
+		if turn.Answer != "" {
+			tr.Coverage = Coverage(turn.Answer, tr.Answer)
+			tr.Missing = missingNumbers(turn.Answer, tr.Answer)
+			tr.Passed = tr.Coverage >= threshold && len(tr.Missing) == 0
+		}
+		result.Turns = append(result.Turns, tr)
+		result.Passed = result.Passed && tr.Passed
+
+		assistant := turn.Answer
+		if assistant == "" {
+			assistant = tr.Answer
+		}
+		history = append(history,
+			filesearch.HistoryMessage{Role: "user", Content: turn.Question},
+			filesearch.HistoryMessage{Role: "assistant", Content: assistant},
+		)
+	}
+	return result, nil
+}
+
+// Coverage returns the share of the distinct terms of the accepted answer that the answer
+// contains. Terms are words of three or more letters and numbers such as "14,05".
+func Coverage(accepted, answer string) float64 {
+	want := terms(accepted)
+	if len(want) == 0 {
+		return 1
+	}
+	have := terms(answer)
+
+	found := 0
+	for term := range want {
+		if have[term] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(want))
+}
+
+// missingNumbers returns the numbers of the accepted answer the answer does not contain, in order
+func missingNumbers(accepted, answer string) []string {
+	have := terms(answer)
+	var missing []string
+	for _, term := range termList(accepted) {
+		if strings.ContainsFunc(term, unicode.IsDigit) && !have[term] && !slices.Contains(missing, term) {
+			missing = append(missing, term)
+		}
+	}
+	return missing
+}
+
+func terms(text string) map[string]bool {
+	out := make(map[string]bool)
+	for _, term := range termList(text) {
+		out[term] = true
+	}
+	return out
+}
+
+func termList(text string) []string {
+	var out []string
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ',' && r != '.'
+	})
+	for _, field := range fields {
+		field = strings.Trim(field, ",.")
+		if len([]rune(field)) >= 3 || strings.ContainsFunc(field, unicode.IsDigit) {
+			out = append(out, field)
+		}
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package filesearch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+	"gopkg.in/yaml.v3"
+)
+
+// MetadataAccessLabel is the custom metadata key holding the access label of a document,
+// e.g. "public" or "hr-only"
+const MetadataAccessLabel = "access_label"
+
+// AllLabels grants access to every document, labelled or not
+const AllLabels = "*"
+
+// ErrNoAccess is returned for callers whose role may not retrieve any document
+var ErrNoAccess = errors.New("no documents are accessible with this role")
+
+// AccessMetadata returns the custom metadata labelling a document, nil without a label
+func AccessMetadata(label string) []*genai.CustomMetadata {
+	if label == "" {
+		return nil
+	}
+	return []*genai.CustomMetadata{{Key: MetadataAccessLabel, StringValue: label}}
+}
+
+// AccessPolicy maps caller roles to the access labels of the documents they may retrieve.
+// Documents without a label are only retrieved by roles granted AllLabels.
+type AccessPolicy struct {
+	Roles map[string][]string `yaml:"roles"` // Role -> labels, e.g. "reader": ["public"]
+}
+
+// LoadAccessPolicy reads an access policy from a YAML file
+func LoadAccessPolicy(path string) (*AccessPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access policy: %w", err)
+	}
+	var policy AccessPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse access policy: %w", err)
+	}
+	for role, labels := range policy.Roles {
+		for _, label := range labels {
+			if label == "" || strings.ContainsAny(label, `"\`) {
+				return nil, fmt.Errorf("role %s: invalid access label %q", role, label)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// Filter returns the metadata filter restricting retrieval to the documents a role may see,
+// or an empty string when it may see all of them
+func (p *AccessPolicy) Filter(role string) (string, error) {
+	labels := p.Roles[role]
+	if slices.Contains(labels, AllLabels) {
+		return "", nil
+	}
+	if len(labels) == 0 {
+		return "", ErrNoAccess
+	}
+
+	terms := make([]string, 0, len(labels))
+	for _, label := range labels {
+		terms = append(terms, fmt.Sprintf("%s = %q", MetadataAccessLabel, label))
+	}
+	return strings.Join(terms, " OR "), nil
+}
+
+// Allows reports whether a role may see a document
+func (p *AccessPolicy) Allows(role string, doc *Document) bool {
+	labels := p.Roles[role]
+	if slices.Contains(labels, AllLabels) {
+		return true
+	}
+	label, ok := doc.CustomMetadata[MetadataAccessLabel]
+	return ok && slices.Contains(labels, label)
+}
+
+// RoleResolver returns the role of the caller of a request, e.g. from its authenticated identity
+type RoleResolver func(r *http.Request) string
+
+// WithAccessPolicy only lets callers retrieve, list and download the documents their role may
+// see according to the policy. Access labels are only known to Gemini File Search.
+func WithAccessPolicy(policy *AccessPolicy, role RoleResolver) HandlerOption {
+	return func(h *Handler) {
+		h.access = policy
+		h.role = role
+	}
+}
+
+// accessFilter returns the metadata filter for the caller of a request, empty without an
+// access policy or when the caller may see every document
+func (h *Handler) accessFilter(r *http.Request) (string, error) {
+	if h.access == nil {
+		return "", nil
+	}
+	return h.access.Filter(h.role(r))
+}
+
+// accessible reports whether the caller of a request may see a document
+func (h *Handler) accessible(r *http.Request, doc *Document) bool {
+	return h.access == nil || h.access.Allows(h.role(r), doc)
+}
+
+// Accessible reports whether the caller of a request may see a document, for the handlers of
+// other packages serving documents, such as previews
+func (h *Handler) Accessible(r *http.Request, doc *Document) bool {
+	return h.accessible(r, doc)
+}
+
+// restrict returns a copy of a compare side that only retrieves from documents matching filter
+func (cs *CompareSide) restrict(filter string) *CompareSide {
+	if cs == nil || filter == "" {
+		return cs
+	}
+	restricted := *cs
+	restricted.Label = cs.name()
+	if restricted.MetadataFilter == "" {
+		restricted.MetadataFilter = filter
+	} else {
+		restricted.MetadataFilter = fmt.Sprintf("(%s) AND (%s)", cs.MetadataFilter, filter)
+	}
+	return &restricted
+}
@@ -0,0 +1,291 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// MaxAttachmentBytes is the size limit of a file uploaded with UploadAttachmentHandler
+const MaxAttachmentBytes = 50 << 20
+
+// attachmentPoll is the interval between checks whether an uploaded file has been processed
+const attachmentPoll = 2 * time.Second
+
+// Attachment is a single file uploaded with the Files API, to ask questions about without
+// indexing it in a store. Gemini deletes attachments 48 hours after the upload.
+type Attachment struct {
+	Name        string    `json:"name"` // Resource name, e.g. "files/abc123"
+	DisplayName string    `json:"displayName,omitempty"`
+	MIMEType    string    `json:"mimeType"`
+	URI         string    `json:"uri"`
+	ExpireTime  time.Time `json:"expireTime,omitzero"`
+}
+
+func newAttachment(file *genai.File) *Attachment {
+	return &Attachment{
+		Name:        file.Name,
+		DisplayName: file.DisplayName,
+		MIMEType:    file.MIMEType,
+		URI:         file.URI,
+		ExpireTime:  file.ExpirationTime,
+	}
+}
+
+// UploadAttachment uploads a file with the Files API and waits until it can be prompted with.
+// An empty MIME type is derived from the file name, defaulting to PDF.
+func (s *Service) UploadAttachment(ctx context.Context, reader io.Reader, fileName string, mimeType string) (*Attachment, error) {
+	mimeType = mimeTypeOf(fileName, mimeType)
+
+	file, err := s.client.Files.Upload(ctx, reader, &genai.UploadFileConfig{
+		DisplayName: fileName,
+		MIMEType:    mimeType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload attachment: %w", err)
+	}
+
+	// Large documents are processed before they can be used
+	for file.State == genai.FileStateProcessing {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(attachmentPoll):
+		}
+		if file, err = s.client.Files.Get(ctx, file.Name, nil); err != nil {
+			return nil, fmt.Errorf("failed to get attachment: %w", err)
+		}
+	}
+	if file.State == genai.FileStateFailed {
+		reason := "unknown error"
+		if file.Error != nil && file.Error.Message != "" {
+			reason = file.Error.Message
+		}
+		return nil, fmt.Errorf("failed to process attachment %s: %s", fileName, reason)
+	}
+
+	return newAttachment(file), nil
+}
+
+// GetAttachment returns an uploaded attachment by resource name
+func (s *Service) GetAttachment(ctx context.Context, name string) (*Attachment, error) {
+	file, err := s.client.Files.Get(ctx, name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return newAttachment(file), nil
+}
+
+// DeleteAttachment deletes an uploaded attachment by resource name
+func (s *Service) DeleteAttachment(ctx context.Context, name string) error {
+	if _, err := s.client.Files.Delete(ctx, name, nil); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
+
+// PromptWithAttachment answers a prompt about a single attachment, sent with the prompt instead
+// of retrieved from a store. history may be nil. No store profile or citation policy applies,
+// as there is no store and the answer is not grounded in retrieved chunks; interceptors and
+// response hooks do run.
+func (s *Service) PromptWithAttachment(ctx context.Context, prompt string, attachment *Attachment, history []HistoryMessage) (*PromptResponse, error) {
+	req := &PromptRequest{Prompt: prompt, History: history, Attachment: attachment}
+	if resp, err := s.intercept(ctx, req); resp != nil || err != nil {
+		return resp, err
+	}
+
+	model, config, _, err := s.answerConfig(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	contents := historyContents(req.History, req.Prompt)
+	question := contents[len(contents)-1]
+	question.Parts = append([]*genai.Part{
+		genai.NewPartFromURI(req.Attachment.URI, req.Attachment.MIMEType),
+	}, question.Parts...)
+
+	resp, err := s.generate(ctx, model, contents, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+	return s.finish(nil, stamp(s.parseResponse(resp), model, config))
+}
+
+// AttachmentQueryRequest asks a question about an uploaded attachment
+type AttachmentQueryRequest struct {
+	Attachment string           `json:"attachment"` // Resource name returned by the upload
+	Query      string           `json:"query"`
+	History    []HistoryMessage `json:"history,omitempty"`
+}
+
+// Validate checks the request fields and returns a *FieldError for the first invalid one
+func (req *AttachmentQueryRequest) Validate() error {
+	if !strings.HasPrefix(req.Attachment, "files/") {
+		return &FieldError{Field: "attachment", Message: `must be a resource name starting with "files/"`}
+	}
+	if err := checkText("attachment", req.Attachment, MaxStoreNameLength); err != nil {
+		return err
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return &FieldError{Field: "query", Message: "is required"}
+	}
+	if err := checkText("query", req.Query, MaxQueryLength); err != nil {
+		return err
+	}
+	if len(req.History) > MaxHistoryMessages {
+		return &FieldError{Field: "history", Message: fmt.Sprintf("exceeds %d messages", MaxHistoryMessages)}
+	}
+	for i, msg := range req.History {
+		field := fmt.Sprintf("history[%d]", i)
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return &FieldError{Field: field + ".role", Message: `must be "user" or "assistant"`}
+		}
+		if err := checkText(field+".content", msg.Content, MaxMessageLength); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadAttachmentHandler handles multipart uploads of a single file to ask questions about
+// POST /attachments
+// Form field "file" holds the document; the attachment is returned as JSON.
+func (h *Handler) UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.service == nil {
+		unsupported(w, "Attachments")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxAttachmentBytes+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid upload: " + err.Error(),
+			"field": "file",
+		})
+		return
+	}
+	defer file.Close()
+	if header.Size > MaxAttachmentBytes {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Attachment exceeds %d bytes", MaxAttachmentBytes),
+			"field": "file",
+		})
+		return
+	}
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "application/octet-stream" {
+		mimeType = ""
+	}
+	attachment, err := h.service.UploadAttachment(r.Context(), file, filepath.Base(header.Filename), mimeType)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// AttachmentQueryHandler handles questions about an uploaded attachment
+// POST /attachments/query
+// Body: {"attachment": "files/abc123", "query": "your question", "history": [...]}
+func (h *Handler) AttachmentQueryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.service == nil {
+		unsupported(w, "Attachments")
+		return
+	}
+
+	var req AttachmentQueryRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&req)
+	if err != nil {
+		err = &FieldError{Message: "invalid JSON: " + err.Error()}
+	} else {
+		err = req.Validate()
+	}
+	if err != nil {
+		response := QueryResponse{Error: "Invalid request: " + err.Error()}
+		var fe *FieldError
+		if errors.As(err, &fe) {
+			response.Field = fe.Field
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	attachment, err := h.service.GetAttachment(r.Context(), req.Attachment)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Error: "Attachment not found: " + err.Error(),
+			Field: "attachment",
+		})
+		return
+	}
+
+	resp, err := h.service.PromptWithAttachment(r.Context(), req.Query, attachment, req.History)
+	if overloaded(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Error: "Failed to execute query: " + err.Error(),
+		})
+		return
+	}
+	if h.usage != nil && resp.Usage != nil {
+		h.usage(r, resp.Usage)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, QueryResponse{
+		Answer:  resp.Text(),
+		Sources: []*SourceDocument{{FileName: attachment.DisplayName, URI: attachment.URI}},
+		Usage:   resp.Usage,
+	})
+}
+
+// DeleteAttachmentHandler handles requests to delete an attachment before it expires
+// DELETE /attachments/{id}
+func (h *Handler) DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if h.service == nil {
+		unsupported(w, "Attachments")
+		return
+	}
+	if err := h.service.DeleteAttachment(r.Context(), "files/"+r.PathValue("id")); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package filesearch
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// benchResponse builds a model response shaped like a typical grounded answer
+func benchResponse() *genai.GenerateContentResponse {
+	parts := make([]*genai.Part, 0, 8)
+	for i := 0; i < 8; i++ {
+		parts = append(parts, &genai.Part{Text: strings.Repeat("Het minimumuurloon bedraagt 14,05 EUR. ", 5)})
+	}
+
+	chunks := make([]*genai.GroundingChunk, 0, 10)
+	for i := 0; i < 10; i++ {
+		chunks = append(chunks, &genai.GroundingChunk{
+			RetrievedContext: &genai.GroundingChunkRetrievedContext{
+				Title: fmt.Sprintf("100-2022-%06d.pdf", i),
+				URI:   fmt.Sprintf("fileSearchStores/cao-documents/documents/%d", i),
+				Text:  strings.Repeat("Artikel 3. Het minimumuurloon bedraagt 14,05 EUR. ", 20),
+			},
+		})
+	}
+
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{
+			Content:           &genai.Content{Role: genai.RoleModel, Parts: parts},
+			GroundingMetadata: &genai.GroundingMetadata{GroundingChunks: chunks},
+		}},
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 400, TotalTokenCount: 450},
+	}
+}
+
+func benchHistory() []HistoryMessage {
+	history := make([]HistoryMessage, 0, 20)
+	for i := 0; i < 10; i++ {
+		history = append(history,
+			HistoryMessage{Role: "user", Content: "Wat is het minimumloon als je 17 jaar bent?"},
+			HistoryMessage{Role: "assistant", Content: strings.Repeat("Het minimumloon bedraagt 14,05 EUR per uur. ", 10)},
+		)
+	}
+	return history
+}
+
+func BenchmarkParseResponse(b *testing.B) {
+	s := &Service{}
+	resp := benchResponse()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		s.parseResponse(resp)
+	}
+}
+
+func BenchmarkBuildPrompt(b *testing.B) {
+	summary := &ConversationSummary{
+		Sector:         "bouw",
+		JointCommittee: "PC 124",
+		ContractType:   "arbeider",
+		Facts:          []string{"Werkt sinds 2019", "Voltijds"},
+		PriorAnswers:   []string{"Minimumuurloon 14,05 EUR", "Opzeg 9 weken"},
+	}
+	mem := NewMemory(summary, benchHistory(), DefaultMaxTurns)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		mem.BuildPrompt("Hoeveel vakantiedagen heb ik?")
+	}
+}
+
+func BenchmarkText(b *testing.B) {
+	resp := (&Service{}).parseResponse(benchResponse())
+
+	b.ReportAllocs()
+	for b.Loop() {
+		resp.Text()
+	}
+}
+
+func BenchmarkEncodeQueryResponse(b *testing.B) {
+	resp := (&Service{}).parseResponse(benchResponse())
+	response := &QueryResponse{
+		Answer:           resp.Text(),
+		Sources:          collectSources(resp.GroundingSupport),
+		GroundingSupport: resp.GroundingSupport,
+		Usage:            resp.Usage,
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		writeJSON(io.Discard, response)
+	}
+}
+
+func BenchmarkCollectSources(b *testing.B) {
+	resp := (&Service{}).parseResponse(benchResponse())
+
+	b.ReportAllocs()
+	for b.Loop() {
+		collectSources(resp.GroundingSupport)
+	}
+}
@@ -0,0 +1,22 @@
+package filesearch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ChunkBoosts adjusts the ranking of the chunks retrieved by the local backends with the
+// feedback of admins on them, see chunkfeedback.Store. File Search ranks its chunks itself.
+type ChunkBoosts interface {
+	// Boost returns what to add to the similarity of a chunk of a document: positive for
+	// authoritative chunks, negative for misleading ones, zero without feedback
+	Boost(fileName string, text string) float64
+}
+
+// ChunkID identifies a chunk by its document and text, so feedback on a chunk holds in every
+// backend and across rebuilds of an index that leave the chunk unchanged
+func ChunkID(fileName string, text string) string {
+	sum := sha256.Sum256([]byte(fileName + "\x00" + strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,101 @@
+package filesearch
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"rag/kv"
+)
+
+// DefaultBudgetMessage answers the queries of a session that used up its token budget when
+// the budget sets no message
+const DefaultBudgetMessage = "This conversation has reached its length limit. Please start a new conversation to ask further questions."
+
+// DefaultBudgetTTL is how long the token usage of a session is kept when the budget sets no TTL
+const DefaultBudgetTTL = 24 * time.Hour
+
+// SessionBudget limits the tokens one chat session may use, so a single conversation cannot
+// consume a disproportionate share of the cost. Usage is counted per QueryRequest.SessionID;
+// queries without a session ID are not limited.
+type SessionBudget struct {
+	Tokens  int           `yaml:"tokens"`  // Tokens a session may use, prompts and answers together
+	TTL     time.Duration `yaml:"ttl"`     // The usage of a session is forgotten this long after its first query
+	Message string        `yaml:"message"` // Answer once the budget is used up, defaults to DefaultBudgetMessage
+}
+
+// SessionLimit tells in a query response that the session used up its token budget. The
+// client continues the conversation, without its history, under NewSessionID.
+type SessionLimit struct {
+	Used         int    `json:"used"`
+	Budget       int    `json:"budget"`
+	NewSessionID string `json:"newSessionId"`
+}
+
+// WithSessionBudget limits the tokens of every chat session to a budget, counting their usage
+// in store, e.g. the Redis store replicas share. A session over its budget gets the budget
+// message instead of an answer. Queries are answered when the usage cannot be read.
+func WithSessionBudget(budget *SessionBudget, store kv.Store) HandlerOption {
+	return func(h *Handler) {
+		h.budget = budget
+		h.sessions = store
+	}
+}
+
+// sessionKey is the key of the token usage of a session
+func sessionKey(sessionID string) string {
+	return "session-tokens:" + sessionID
+}
+
+// sessionUsage returns the tokens a session used, and whether it used up its budget
+func (h *Handler) sessionUsage(ctx context.Context, req *QueryRequest) (int, bool) {
+	if h.budget == nil || req.SessionID == "" {
+		return 0, false
+	}
+	used, err := h.sessions.Incr(ctx, sessionKey(req.SessionID), 0, h.budgetTTL())
+	if err != nil {
+		log.Printf("Warning: answering without session budget check: %v", err)
+		return 0, false
+	}
+	return int(used), used >= int64(h.budget.Tokens)
+}
+
+// chargeSession adds the tokens of an answer to the usage of its session
+func (h *Handler) chargeSession(ctx context.Context, req *QueryRequest, usage *TokenUsage) {
+	if h.budget == nil || req.SessionID == "" || usage == nil {
+		return
+	}
+	if _, err := h.sessions.Incr(ctx, sessionKey(req.SessionID), int64(usage.TotalTokens), h.budgetTTL()); err != nil {
+		log.Printf("Warning: failed to record session token usage: %v", err)
+	}
+}
+
+// budgetTTL returns how long the usage of a session is kept
+func (h *Handler) budgetTTL() time.Duration {
+	if h.budget.TTL > 0 {
+		return h.budget.TTL
+	}
+	return DefaultBudgetTTL
+}
+
+// limitSession answers a query of a session that used up its budget
+func (h *Handler) limitSession(w http.ResponseWriter, r *http.Request, req *QueryRequest, used int) {
+	response := QueryResponse{
+		Answer: h.budget.Message,
+		SessionLimit: &SessionLimit{
+			Used:         used,
+			Budget:       h.budget.Tokens,
+			NewSessionID: newSessionID(),
+		},
+	}
+	if response.Answer == "" {
+		response.Answer = DefaultBudgetMessage
+	}
+	if h.queries != nil {
+		response.QueryID = h.queries.LogQuery(r, req, &response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, response)
+}
@@ -0,0 +1,148 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ErrDeleteUnconfirmed is returned by DeleteDocumentsWhere when a filter matches another
+// number of documents than the caller confirmed
+var ErrDeleteUnconfirmed = errors.New("number of documents to delete not confirmed")
+
+// DeleteOptions controls DeleteDocumentsWhere
+type DeleteOptions struct {
+	DryRun  bool // Only list the matching documents
+	Confirm int  // Number of documents the caller expects to delete, as listed by a dry run
+}
+
+// DeleteReport lists the documents matching a filter and how many were deleted
+type DeleteReport struct {
+	Filter    string      `json:"filter"`
+	DryRun    bool        `json:"dryRun,omitempty"`
+	Documents []*Document `json:"documents"` // Matching documents, deleted unless DryRun
+	Deleted   int         `json:"deleted"`
+	Failed    int         `json:"failed"`
+}
+
+// DeleteDocumentsWhere deletes the documents of a store whose custom metadata matches a
+// filter, e.g. jc_number=1240000 AND year<2018, see ParseFilter. Nothing is deleted unless
+// opts.Confirm equals the number of matching documents, so a typo in the filter cannot empty a
+// store: a dry run lists the matches and their count, which the caller then confirms. The
+// report lists the matches either way; documents failing to delete are logged and counted.
+func (s *Service) DeleteDocumentsWhere(ctx context.Context, storeName string, filter string, opts *DeleteOptions) (*DeleteReport, error) {
+	if opts == nil {
+		opts = &DeleteOptions{}
+	}
+	if s.federation(storeName) != nil {
+		return nil, fmt.Errorf("cannot delete from virtual store %s, delete from the stores it federates", storeName)
+	}
+	match, err := ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := s.ListDocuments(ctx, storeName)
+	if err != nil {
+		return nil, err
+	}
+	report := &DeleteReport{Filter: filter, DryRun: opts.DryRun, Documents: make([]*Document, 0)}
+	for _, doc := range documents {
+		if match.Match(doc.CustomMetadata) {
+			report.Documents = append(report.Documents, doc)
+		}
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+	if opts.Confirm != len(report.Documents) {
+		return report, fmt.Errorf("%w: filter matches %d documents, %d confirmed", ErrDeleteUnconfirmed, len(report.Documents), opts.Confirm)
+	}
+
+	for _, doc := range report.Documents {
+		if err := s.DeleteDocument(ctx, doc.Name); err != nil {
+			log.Printf("Warning: Failed to delete %s: %v", doc.DisplayName, err)
+			report.Failed++
+			continue
+		}
+		report.Deleted++
+	}
+	return report, nil
+}
+
+// DeleteWhereRequest represents a request to delete the documents of a store matching a
+// metadata filter
+type DeleteWhereRequest struct {
+	Filter  string `json:"filter"`
+	DryRun  bool   `json:"dryRun,omitempty"`
+	Confirm int    `json:"confirm,omitempty"` // Number of matching documents, as listed by a dry run
+}
+
+// DeleteDocumentsWhereHandler handles POST requests deleting the documents of a store, by
+// display name, whose metadata matches a filter, see Service.DeleteDocumentsWhere. A request
+// whose confirm differs from the number of matches deletes nothing and answers 409 with the
+// matches.
+// POST /stores/{name}/documents/delete
+// Body: {"filter": "jc_number=1240000 AND year<2018", "confirm": 12}
+func (h *Handler) DeleteDocumentsWhereHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.service == nil {
+		unsupported(w, "Deleting documents by filter")
+		return
+	}
+
+	var req DeleteWhereRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	req.Filter = strings.TrimSpace(req.Filter)
+	if _, err := ParseFilter(req.Filter); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	store, err := h.service.GetStoreByName(r.Context(), r.PathValue("name"))
+	if err != nil || h.service.federation(store.Name) != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Store not found: " + r.PathValue("name"),
+		})
+		return
+	}
+
+	report, err := h.service.DeleteDocumentsWhere(r.Context(), store.Name, req.Filter, &DeleteOptions{
+		DryRun:  req.DryRun,
+		Confirm: req.Confirm,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case errors.Is(err, ErrDeleteUnconfirmed):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{
+			"error":  err.Error(),
+			"report": report,
+		})
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to delete documents: " + err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,257 @@
+package filesearch
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Citation styles of a channel template
+const (
+	CitationsFootnotes = "footnotes" // Numbered source list after the answer
+	CitationsInline    = "inline"    // Sources named on one line after the answer
+	CitationsNone      = "none"      // No sources, e.g. when the client shows them itself
+)
+
+// ChannelTemplate shapes the answers for one surface, e.g. Slack: their length, markup and
+// citation style. Queries select it with "channel"; the rendered answer is returned in
+// QueryResponse.Rendered.
+type ChannelTemplate struct {
+	Format      string `yaml:"format"`      // "markdown", "html", "slack" (mrkdwn), "telegram" (Telegram HTML) or "text"
+	Citations   string `yaml:"citations"`   // CitationsFootnotes, CitationsInline or CitationsNone, footnotes when empty
+	MaxLength   int    `yaml:"maxLength"`   // Characters of the answer, asked of the model and then enforced; zero for no limit
+	MaxSources  int    `yaml:"maxSources"`  // Sources listed, zero for all
+	Instruction string `yaml:"instruction"` // Added to the question, e.g. "Use short bullet points."
+}
+
+// DefaultChannels are the channel templates of a handler without WithChannels. Slack and
+// Telegram answers stay below the message limits of 4000 and 4096 characters.
+var DefaultChannels = map[string]*ChannelTemplate{
+	"web":      {Format: "html", Citations: CitationsFootnotes},
+	"slack":    {Format: "slack", Citations: CitationsFootnotes, MaxLength: 2500, MaxSources: 5},
+	"telegram": {Format: "telegram", Citations: CitationsInline, MaxLength: 3000, MaxSources: 3},
+	"cli":      {Format: "text", Citations: CitationsFootnotes},
+}
+
+// ChannelTemplates maps channel names to their template
+type ChannelTemplates struct {
+	Channels map[string]*ChannelTemplate `yaml:"channels"`
+}
+
+// LoadChannelTemplates reads channel templates from a YAML file. They are added to
+// DefaultChannels, replacing the defaults of the channels they name.
+func LoadChannelTemplates(path string) (map[string]*ChannelTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channel templates: %w", err)
+	}
+
+	var templates ChannelTemplates
+	if err := yaml.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse channel templates: %w", err)
+	}
+	channels := make(map[string]*ChannelTemplate, len(DefaultChannels)+len(templates.Channels))
+	for name, t := range DefaultChannels {
+		channels[name] = t
+	}
+	for name, t := range templates.Channels {
+		switch {
+		case t == nil:
+			return nil, fmt.Errorf("empty template for channel %q", name)
+		case t.Format != "markdown" && t.Format != "html" && t.Format != "slack" && t.Format != "telegram" && t.Format != "text":
+			return nil, fmt.Errorf("invalid format %q for channel %q", t.Format, name)
+		case t.Citations != "" && t.Citations != CitationsFootnotes && t.Citations != CitationsInline && t.Citations != CitationsNone:
+			return nil, fmt.Errorf("invalid citation style %q for channel %q", t.Citations, name)
+		case t.MaxLength < 0 || t.MaxSources < 0:
+			return nil, fmt.Errorf("limits for channel %q must not be negative", name)
+		}
+		channels[name] = t
+	}
+	return channels, nil
+}
+
+// WithChannels replaces the channel templates queries can select, see LoadChannelTemplates
+func WithChannels(channels map[string]*ChannelTemplate) HandlerOption {
+	return func(h *Handler) {
+		h.channels = channels
+	}
+}
+
+// channel returns the template of a channel, nil when there is none
+func (h *Handler) channel(name string) *ChannelTemplate {
+	if h.channels == nil {
+		return DefaultChannels[name]
+	}
+	return h.channels[name]
+}
+
+// instruction returns what is added to the question to fit the answer to the channel
+func (t *ChannelTemplate) instruction() string {
+	var sb strings.Builder
+	if t.MaxLength > 0 {
+		fmt.Fprintf(&sb, "\n\nKeep the answer under %d characters.", t.MaxLength)
+	}
+	if t.Instruction != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(strings.TrimSpace(t.Instruction))
+	}
+	return sb.String()
+}
+
+// Render renders an answer with its sources for the channel
+func (t *ChannelTemplate) Render(resp *PromptResponse) string {
+	text := truncateAnswer(strings.TrimSpace(resp.Text()), t.MaxLength)
+	footnotes := Footnotes(resp)
+	if t.MaxSources > 0 && len(footnotes) > t.MaxSources {
+		footnotes = footnotes[:t.MaxSources]
+	}
+	if t.Citations == CitationsNone {
+		footnotes = nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(t.body(text))
+	if len(footnotes) == 0 {
+		return sb.String()
+	}
+
+	if t.Citations == CitationsInline {
+		names := make([]string, len(footnotes))
+		for i, fn := range footnotes {
+			names[i] = t.link(fn.FileName, fn.Link)
+		}
+		sb.WriteString(t.separator())
+		sb.WriteString(t.emphasis("Sources:") + " " + strings.Join(names, ", "))
+		return sb.String()
+	}
+
+	sb.WriteString(t.separator())
+	sb.WriteString(t.emphasis("Sources"))
+	sb.WriteString("\n")
+	if t.Format == "html" {
+		sb.WriteString(`<ol class="footnotes">` + "\n")
+	}
+	for _, fn := range footnotes {
+		label := fn.FileName
+		if fn.Page > 0 {
+			label = fmt.Sprintf("%s, p. %d", label, fn.Page)
+		}
+		label = t.link(label, fn.Link)
+		if len(fn.Articles) > 0 {
+			label += ", " + t.escape(strings.Join(fn.Articles, ", "))
+		}
+		if fn.Corpus != "" {
+			label = t.escape(fn.Corpus) + ": " + label
+		}
+		if t.Format == "html" {
+			fmt.Fprintf(&sb, `<li id="fn-%d">%s</li>`+"\n", fn.Number, label)
+		} else {
+			fmt.Fprintf(&sb, "[%d] %s\n", fn.Number, label)
+		}
+	}
+	if t.Format == "html" {
+		sb.WriteString("</ol>\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// boldMarkdown matches the bold markup models use in their answers
+var boldMarkdown = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+
+// body converts the Markdown of an answer to the markup of the channel
+func (t *ChannelTemplate) body(text string) string {
+	switch t.Format {
+	case "html":
+		var sb strings.Builder
+		for _, paragraph := range strings.Split(text, "\n\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				sb.WriteString("<p>")
+				sb.WriteString(boldMarkdown.ReplaceAllString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>"), "<b>$1</b>"))
+				sb.WriteString("</p>\n")
+			}
+		}
+		return strings.TrimSuffix(sb.String(), "\n")
+	case "slack":
+		return boldMarkdown.ReplaceAllString(t.escape(text), "*$1*")
+	case "telegram":
+		return boldMarkdown.ReplaceAllString(t.escape(text), "<b>$1</b>")
+	case "text":
+		return boldMarkdown.ReplaceAllString(text, "$1")
+	}
+	return text
+}
+
+// escape escapes text for the markup of the channel
+func (t *ChannelTemplate) escape(text string) string {
+	switch t.Format {
+	case "html", "telegram":
+		return html.EscapeString(text)
+	case "slack":
+		return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	}
+	return text
+}
+
+// link links a label to a URL in the markup of the channel; labels without URL are escaped
+func (t *ChannelTemplate) link(label string, url string) string {
+	if url == "" {
+		return t.escape(label)
+	}
+	switch t.Format {
+	case "html", "telegram":
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(label))
+	case "slack":
+		return fmt.Sprintf("<%s|%s>", url, strings.ReplaceAll(t.escape(label), "|", "/"))
+	case "text":
+		return label + " <" + url + ">"
+	}
+	return fmt.Sprintf("[%s](%s)", label, url)
+}
+
+// emphasis marks a heading in the markup of the channel
+func (t *ChannelTemplate) emphasis(text string) string {
+	switch t.Format {
+	case "markdown":
+		return "**" + text + "**"
+	case "slack":
+		return "*" + text + "*"
+	case "html", "telegram":
+		return "<b>" + text + "</b>"
+	}
+	return text
+}
+
+// separator returns what separates the answer from its sources
+func (t *ChannelTemplate) separator() string {
+	if t.Format == "html" {
+		return "\n"
+	}
+	return "\n\n"
+}
+
+// truncateAnswer shortens an answer longer than maxLength characters at the last word that
+// fits, marking the cut with an ellipsis. Bold markup cut in half is dropped.
+func truncateAnswer(text string, maxLength int) string {
+	runes := []rune(text)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return text
+	}
+	cut := maxLength - 1
+	for i := cut; i > maxLength/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	truncated := strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace)
+	if strings.Count(truncated, "**")%2 == 1 {
+		i := strings.LastIndex(truncated, "**")
+		truncated = truncated[:i] + truncated[i+2:]
+	}
+	return truncated + "…"
+}
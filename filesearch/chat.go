@@ -0,0 +1,149 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+)
+
+// Defaults for the history a chat session keeps
+const (
+	DefaultChatMessages = 20    // Messages kept verbatim
+	DefaultChatChars    = 32000 // Characters of history sent with a message
+)
+
+// ChatOptions configures a chat session
+type ChatOptions struct {
+	Retrieval   *RetrievalOptions // Optional retrieval options for every message
+	History     []HistoryMessage  // Earlier turns to continue from, e.g. sent by a client
+	MaxMessages int               // History messages kept, DefaultChatMessages when zero
+	MaxChars    int               // Characters of history kept, DefaultChatChars when zero
+}
+
+// ChatSession is a conversation with a store. It keeps the earlier turns and sends them as
+// user and model contents with every message, dropping the oldest exchanges once the history
+// grows beyond its limits. A session may be used from several goroutines, but messages are
+// answered one at a time.
+type ChatSession struct {
+	service   *Service
+	storeName string
+	retrieval *RetrievalOptions
+	maxMsgs   int
+	maxChars  int
+
+	mu      sync.Mutex
+	history []HistoryMessage
+}
+
+// NewChat starts a chat session with a store. opts may be nil.
+func (s *Service) NewChat(storeName string, opts *ChatOptions) *ChatSession {
+	if opts == nil {
+		opts = &ChatOptions{}
+	}
+	c := &ChatSession{
+		service:   s,
+		storeName: storeName,
+		retrieval: opts.Retrieval,
+		maxMsgs:   opts.MaxMessages,
+		maxChars:  opts.MaxChars,
+		history:   append([]HistoryMessage{}, opts.History...),
+	}
+	if c.maxMsgs <= 0 {
+		c.maxMsgs = DefaultChatMessages
+	}
+	if c.maxChars <= 0 {
+		c.maxChars = DefaultChatChars
+	}
+	c.truncate()
+	return c
+}
+
+// Send answers a message in the context of the conversation and adds the exchange to the
+// history. Failed messages are not added.
+func (c *ChatSession) Send(ctx context.Context, msg string) (*PromptResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp, err := c.service.answer(ctx, &PromptRequest{
+		StoreName: c.storeName,
+		Prompt:    msg,
+		History:   c.history,
+		Retrieval: c.retrieval,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.history = append(c.history,
+		HistoryMessage{Role: "user", Content: msg},
+		HistoryMessage{Role: "assistant", Content: resp.Text()},
+	)
+	c.truncate()
+	return resp, nil
+}
+
+// History returns a copy of the turns the session keeps
+func (c *ChatSession) History() []HistoryMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]HistoryMessage{}, c.history...)
+}
+
+// truncate drops the oldest messages until the history fits the limits. The history always
+// starts with a user message, as the model expects.
+func (c *ChatSession) truncate() {
+	chars := 0
+	for _, msg := range c.history {
+		chars += utf8.RuneCountInString(msg.Content)
+	}
+
+	drop := 0
+	for drop < len(c.history) && (len(c.history)-drop > c.maxMsgs || chars > c.maxChars) {
+		chars -= utf8.RuneCountInString(c.history[drop].Content)
+		drop++
+	}
+	for drop < len(c.history) && c.history[drop].Role != "user" {
+		drop++
+	}
+	c.history = append(c.history[:0], c.history[drop:]...)
+}
+
+// answer answers a prompt from a store after running the interceptors, with the store profile,
+// citation policy and hooks applied. Answers are cached by the intercepted request.
+func (s *Service) answer(ctx context.Context, req *PromptRequest) (*PromptResponse, error) {
+	if resp, err := s.intercept(ctx, req); resp != nil || err != nil {
+		return resp, err
+	}
+	cached, cacheKey := s.cachedResponse(ctx, req)
+	if cached != nil {
+		return cached, nil
+	}
+
+	model, config, profile, err := s.answerConfig(ctx, req.StoreName, fileSearchTool(req.StoreName, req.Retrieval))
+	if err != nil {
+		return nil, err
+	}
+
+	contents := historyContents(req.History, req.Prompt)
+	parsed, err := s.withCitationPolicy(profile, config, func(config *genai.GenerateContentConfig) (*PromptResponse, error) {
+		resp, err := s.generate(ctx, model, contents, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate content: %w", err)
+		}
+		parsed := stamp(s.parseResponse(resp), model, config)
+		scopeChunks(ctx, parsed)
+		return parsed, checkAnswer(resp, parsed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	finished, err := s.finish(profile, parsed)
+	if err != nil {
+		return nil, err
+	}
+	s.cacheResponse(ctx, cacheKey, finished)
+	return finished, nil
+}
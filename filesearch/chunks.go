@@ -0,0 +1,81 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const chunksInstruction = `Answer the question using only the numbered sources below it. When the sources do not answer the question, say so instead of guessing.`
+
+// PromptWithChunks answers a prompt from chunks the caller retrieved, e.g. from a local vector
+// store, instead of from a File Search store. The chunks are sent numbered below the prompt and
+// returned as the grounding of the answer. storeName selects the store profile, by resource or
+// display name; interceptors, the citation policy and response hooks apply as for
+// PromptWithRetrieval.
+func (s *Service) PromptWithChunks(ctx context.Context, prompt string, storeName string, chunks []*RetrievedChunk) (*PromptResponse, error) {
+	req := &PromptRequest{StoreName: storeName, Prompt: prompt}
+	if resp, err := s.intercept(ctx, req); resp != nil || err != nil {
+		return resp, err
+	}
+
+	model, config, profile, err := s.answerConfig(ctx, req.StoreName)
+	if err != nil {
+		return nil, err
+	}
+	if config.SystemInstruction == nil {
+		config.SystemInstruction = genai.NewContentFromText(chunksInstruction, genai.RoleUser)
+	}
+
+	contents := genai.Text(chunksPrompt(req.Prompt, chunks))
+	parsed, err := s.withCitationPolicy(profile, config, func(config *genai.GenerateContentConfig) (*PromptResponse, error) {
+		resp, err := s.generate(ctx, model, contents, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate content: %w", err)
+		}
+		parsed := stamp(s.parseResponse(resp), model, config)
+		parsed.GroundingSupport = chunksGrounding(chunks)
+		return parsed, checkAnswer(resp, parsed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.finish(profile, parsed)
+}
+
+// chunksPrompt appends the chunks to a prompt as numbered sources
+func chunksPrompt(prompt string, chunks []*RetrievedChunk) string {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\nSources:\n")
+	for i, chunk := range chunks {
+		title := chunk.FileName
+		if chunk.Page > 0 {
+			title = fmt.Sprintf("%s p.%d", chunk.FileName, chunk.Page)
+		}
+		fmt.Fprintf(&sb, "\n[%d] %s\n%s\n", i+1, title, chunk.Text)
+	}
+	return sb.String()
+}
+
+// chunksGrounding reports chunks as the file grounding of an answer, nil when there are none
+func chunksGrounding(chunks []*RetrievedChunk) *GroundingSupport {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	gs := &GroundingSupport{GroundingChunks: make([]*GroundingChunk, len(chunks))}
+	for i, chunk := range chunks {
+		gs.GroundingChunks[i] = &GroundingChunk{File: &FileGroundingChunk{
+			FileName: chunk.FileName,
+			URI:      chunk.URI,
+			Text:     chunk.Text,
+			Page:     chunk.Page,
+			Article:  chunk.Article,
+			Corpus:   chunk.Corpus,
+		}}
+	}
+	return gs
+}
@@ -0,0 +1,68 @@
+package filesearch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"google.golang.org/genai"
+)
+
+// DocumentFetcher returns the original content of a document, e.g. from a local copy or its
+// source URL, see preview.LocalOrSourceFetcher
+type DocumentFetcher func(ctx context.Context, doc *Document) ([]byte, error)
+
+// CloneStore copies every document of a store, with its custom metadata, into a new store
+// named dstDisplayName, so experiments can run against an isolated copy. The documents are
+// uploaded again from their originals, see Config.Originals, and chunked anew. When a document
+// cannot be copied the new store is deleted again.
+func (s *Service) CloneStore(ctx context.Context, src string, dstDisplayName string) (*Store, error) {
+	if s.originals == nil {
+		return nil, errors.New("cloning a store requires Config.Originals")
+	}
+	if _, err := s.GetStoreByName(ctx, dstDisplayName); err == nil {
+		return nil, fmt.Errorf("store %q already exists", dstDisplayName)
+	}
+
+	docs, err := s.listStoreDocuments(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := s.CreateStore(ctx, dstDisplayName)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if err := s.cloneDocument(ctx, doc, dst.Name); err != nil {
+			if delErr := s.DeleteStore(ctx, dst.Name, true); delErr != nil {
+				log.Printf("Warning: failed to delete incomplete clone %s: %v", dst.Name, delErr)
+			}
+			return nil, fmt.Errorf("failed to clone %s: %w", doc.DisplayName, err)
+		}
+	}
+	log.Printf("Cloned %d documents into %s", len(docs), dst.Name)
+	return dst, nil
+}
+
+// cloneDocument uploads the original of a document to a store with the same metadata
+func (s *Service) cloneDocument(ctx context.Context, doc *genai.Document, storeName string) error {
+	metadata := make(map[string]string)
+	for _, cm := range doc.CustomMetadata {
+		metadata[cm.Key] = metadataValue(cm)
+	}
+	data, err := s.originals(ctx, &Document{
+		Name:           doc.Name,
+		DisplayName:    doc.DisplayName,
+		CustomMetadata: metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The metadata already holds the source URL, if any
+	_, err = s.UploadDocumentWithMetadata(ctx, bytes.NewReader(data), doc.DisplayName, storeName, "", doc.CustomMetadata)
+	return err
+}
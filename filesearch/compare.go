@@ -0,0 +1,203 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+// ModeCompare is the query mode that compares two documents or document subsets
+const ModeCompare = "compare"
+
+// CompareSide selects one side of a comparison: a single document by display name,
+// a metadata filter such as `jc_number = 3020000 AND year = 2021`, or both
+type CompareSide struct {
+	Label          string `json:"label,omitempty"` // Shown in the comparison, defaults to the document or filter
+	Document       string `json:"document,omitempty"`
+	MetadataFilter string `json:"metadataFilter,omitempty"`
+}
+
+// name returns the label used for the side in prompts and results
+func (cs *CompareSide) name() string {
+	switch {
+	case cs.Label != "":
+		return cs.Label
+	case cs.Document != "":
+		return cs.Document
+	default:
+		return cs.MetadataFilter
+	}
+}
+
+// Difference is one aspect on which the two sides differ
+type Difference struct {
+	Aspect string `json:"aspect"`
+	Left   string `json:"left"`
+	Right  string `json:"right"`
+}
+
+// ComparedSide holds what was retrieved for one side, with the sources it was taken from
+type ComparedSide struct {
+	Label            string            `json:"label"`
+	Answer           string            `json:"answer"`
+	Sources          []*SourceDocument `json:"sources"`
+	GroundingSupport *GroundingSupport `json:"groundingSupport,omitempty"`
+}
+
+// Comparison is the structured result of a compare query
+type Comparison struct {
+	Left         *ComparedSide `json:"left"`
+	Right        *ComparedSide `json:"right"`
+	Differences  []*Difference `json:"differences"`
+	Similarities []string      `json:"similarities,omitempty"`
+	Summary      string        `json:"summary"`
+	Usage        *TokenUsage   `json:"usage,omitempty"`
+}
+
+// comparisonSchema constrains the comparison output
+var comparisonSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"differences": {Type: genai.TypeArray, Items: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"aspect": {Type: genai.TypeString},
+				"left":   {Type: genai.TypeString},
+				"right":  {Type: genai.TypeString},
+			},
+			Required: []string{"aspect", "left", "right"},
+		}},
+		"similarities": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+		"summary":      {Type: genai.TypeString},
+	},
+	Required: []string{"differences", "summary"},
+}
+
+const compareInstruction = `You compare two sets of provisions from Belgian collective labour agreements (CAO's) on the same question.
+List every concrete difference (amounts, durations, conditions, scope, dates) as an aspect with what each side says, citing the article when the extract mentions it.
+Only use the two extracts. When a side does not address an aspect, say so instead of guessing. Answer in the language of the question.`
+
+// Compare answers a question from two documents or document subsets separately and returns
+// a structured comparison of the differences, with the sources of each side
+func (s *Service) Compare(ctx context.Context, question string, storeName string, left, right *CompareSide) (*Comparison, error) {
+	sides := []*CompareSide{left, right}
+	results := make([]*PromptResponse, len(sides))
+	errs := make([]error, len(sides))
+
+	// Retrieve both sides concurrently; each is grounded only in its own documents
+	var wg sync.WaitGroup
+	for i, side := range sides {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = s.retrieveSide(ctx, question, storeName, side)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve %s: %w", sides[i].name(), err)
+		}
+	}
+
+	comparison := &Comparison{
+		Left:  compared(left, results[0]),
+		Right: compared(right, results[1]),
+		Usage: results[0].Usage.Add(results[1].Usage),
+	}
+
+	input := fmt.Sprintf("Question: %s\n\n%s says:\n%s\n\n%s says:\n%s",
+		question, comparison.Left.Label, comparison.Left.Answer, comparison.Right.Label, comparison.Right.Answer)
+
+	model, _, profile, err := s.answerConfig(ctx, storeName)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.generate(ctx, model,
+		genai.Text(input),
+		&genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText(compareInstruction, genai.RoleUser),
+			ResponseMIMEType:  "application/json",
+			ResponseSchema:    comparisonSchema,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare: %w", err)
+	}
+	var result struct {
+		Differences  []*Difference `json:"differences"`
+		Similarities []string      `json:"similarities"`
+		Summary      string        `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(resp.Text()), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode comparison: %w", err)
+	}
+	comparison.Differences = result.Differences
+	comparison.Similarities = result.Similarities
+	comparison.Summary = result.Summary
+	if profile != nil && profile.Disclaimer != "" {
+		comparison.Summary += "\n\n" + strings.TrimSpace(profile.Disclaimer)
+	}
+	if um := resp.UsageMetadata; um != nil {
+		comparison.Usage = comparison.Usage.Add(&TokenUsage{
+			PromptTokens:   int(um.PromptTokenCount),
+			ResponseTokens: int(um.CandidatesTokenCount),
+			TotalTokens:    int(um.TotalTokenCount),
+		})
+	}
+
+	return comparison, nil
+}
+
+// retrieveSide answers the question from the documents of one side only
+func (s *Service) retrieveSide(ctx context.Context, question string, storeName string, side *CompareSide) (*PromptResponse, error) {
+	filter := side.MetadataFilter
+	if side.Document != "" {
+		// File Search cannot filter on the document name, so filter on its content hash
+		hash, err := s.documentsFilter(ctx, storeName, "document", []string{side.Document})
+		if err != nil {
+			return nil, err
+		}
+		filter = andFilter(filter, hash)
+	}
+	var opts *RetrievalOptions
+	if filter != "" {
+		opts = &RetrievalOptions{MetadataFilter: filter}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Summarize what the documents say about the following question, citing articles. ")
+	if side.Document != "" {
+		// Documents uploaded without their hash are only kept apart by the prompt and the chunks dropped below
+		fmt.Fprintf(&sb, "Only use the document %q. ", side.Document)
+	}
+	sb.WriteString("If the documents do not address it, say so.\n\nQuestion: ")
+	sb.WriteString(question)
+
+	resp, err := s.PromptWithRetrieval(ctx, sb.String(), storeName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if side.Document != "" && resp.GroundingSupport != nil {
+		resp.GroundingSupport.keepChunks(func(chunk *GroundingChunk) bool {
+			return chunk.File != nil && chunk.File.FileName == side.Document
+		})
+	}
+
+	return resp, nil
+}
+
+// compared builds the result for one side from its retrieval response
+func compared(side *CompareSide, resp *PromptResponse) *ComparedSide {
+	return &ComparedSide{
+		Label:            side.name(),
+		Answer:           resp.Text(),
+		Sources:          collectSources(resp.GroundingSupport),
+		GroundingSupport: resp.GroundingSupport,
+	}
+}
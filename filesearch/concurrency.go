@@ -0,0 +1,77 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrOverloaded is returned when a generation call waited longer than the queue timeout for
+// one of the MaxConcurrentGenerations slots. It counts as unavailable, so the handler answers
+// 503 with Retry-After or serves a cached answer.
+var ErrOverloaded error = overloadedError{}
+
+type overloadedError struct{}
+
+func (overloadedError) Error() string     { return "too many concurrent generation requests" }
+func (overloadedError) Unavailable() bool { return true }
+
+// generationLimiter bounds the number of generation calls in flight. Callers beyond the limit
+// queue in arrival order until a slot frees up, their context is done or the timeout passes.
+type generationLimiter struct {
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+// newGenerationLimiter returns a limiter for max concurrent calls, nil when max is not positive
+func newGenerationLimiter(max int, timeout time.Duration) *generationLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &generationLimiter{slots: make(chan struct{}, max), timeout: timeout}
+}
+
+// acquire takes a slot, returning the function that releases it. A nil limiter never blocks.
+func (l *generationLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	release := func() { <-l.slots }
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	var expired <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-expired:
+		return nil, ErrOverloaded
+	}
+}
+
+// overloaded answers 503 with Retry-After when err is ErrOverloaded, reporting whether it did
+func overloaded(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, ErrOverloaded) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "30")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(QueryResponse{
+		Error: "Service unavailable: " + err.Error(),
+	})
+	return true
+}
@@ -0,0 +1,153 @@
+package filesearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rag/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genai"
+)
+
+// MinCachedInstruction is the length in bytes of the shortest system instruction served from
+// a context cache. Gemini only caches contents of at least 1024 tokens; shorter
+// instructions are sent with every prompt.
+const MinCachedInstruction = 4000
+
+// contextCacheMargin is how long before it expires a context cache is replaced, so prompts
+// never refer to an expired cache
+const contextCacheMargin = time.Minute
+
+// contextCache is a Gemini context cache holding the static part of a prompt
+type contextCache struct {
+	name    string // Resource name, empty when the contents could not be cached
+	expires time.Time
+}
+
+// generate calls the model, retrying transient failures with the retry policy and serving the system instruction and tools from a context cache when
+// context caching is enabled and the instruction is long enough, so they are billed at the
+// cached rate instead of in full with every prompt. Calls beyond MaxConcurrentGenerations
+// wait for a slot.
+func (s *Service) generate(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	ctx, span := s.tracer.Start(ctx, "gemini.generate", trace.WithAttributes(
+		telemetry.ModelKey.String(model),
+		telemetry.StoreKey.String(strings.Join(toolStores(config), ",")),
+	))
+	resp, err := s.generateContent(ctx, model, contents, config)
+	if err == nil && resp.UsageMetadata != nil {
+		span.SetAttributes(
+			telemetry.InputTokensKey.Int(int(resp.UsageMetadata.PromptTokenCount)),
+			telemetry.OutputTokensKey.Int(int(resp.UsageMetadata.CandidatesTokenCount)),
+		)
+	}
+	telemetry.End(span, err)
+	return resp, err
+}
+
+// generateContent is generate without the span
+func (s *Service) generateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	if name := s.contextCache(ctx, model, config); name != "" {
+		// Requests using a cache may not repeat what it holds
+		cached := *config
+		cached.SystemInstruction, cached.Tools, cached.ToolConfig = nil, nil, nil
+		cached.CachedContent = name
+		config = &cached
+	}
+	release, err := s.generations.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return withRetry(ctx, s.retry, func() (*genai.GenerateContentResponse, error) {
+		return s.client.Models.GenerateContent(ctx, model, contents, config)
+	})
+}
+
+// contextCache returns the context cache holding the system instruction and tools of a config,
+// creating it when there is none yet or it is about to expire. It returns an empty name when
+// the config is not worth caching or caching fails; failures are not retried until the TTL
+// has passed.
+func (s *Service) contextCache(ctx context.Context, model string, config *genai.GenerateContentConfig) string {
+	if s.cacheTTL <= 0 || config.SystemInstruction == nil || textLength(config.SystemInstruction) < MinCachedInstruction {
+		return ""
+	}
+	key, err := contextCacheKey(model, config)
+	if err != nil {
+		return ""
+	}
+
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	now := time.Now()
+	if cache, ok := s.contextCaches[key]; ok && now.Before(cache.expires) {
+		return cache.name
+	}
+	for k, cache := range s.contextCaches {
+		if !now.Before(cache.expires) {
+			delete(s.contextCaches, k)
+		}
+	}
+
+	created, err := s.client.Caches.Create(ctx, model, &genai.CreateCachedContentConfig{
+		DisplayName:       "rag-" + key[:12],
+		TTL:               s.cacheTTL,
+		SystemInstruction: config.SystemInstruction,
+		Tools:             config.Tools,
+		ToolConfig:        config.ToolConfig,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to create context cache, sending the instruction uncached: %v", err)
+		s.contextCaches[key] = &contextCache{expires: now.Add(s.cacheTTL)}
+		return ""
+	}
+
+	s.contextCaches[key] = &contextCache{name: created.Name, expires: now.Add(s.cacheTTL - contextCacheMargin)}
+	return created.Name
+}
+
+// Close deletes the context caches created by the service. Caches left behind expire after
+// the TTL set in Config.ContextCacheTTL.
+func (s *Service) Close(ctx context.Context) error {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	var errs []error
+	for key, cache := range s.contextCaches {
+		if cache.name != "" && time.Now().Before(cache.expires) {
+			if _, err := s.client.Caches.Delete(ctx, cache.name, nil); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete context cache %s: %w", cache.name, err))
+			}
+		}
+		delete(s.contextCaches, key)
+	}
+	return errors.Join(errs...)
+}
+
+// contextCacheKey identifies the cached part of a config for a model
+func contextCacheKey(model string, config *genai.GenerateContentConfig) (string, error) {
+	data, err := json.Marshal([]any{model, config.SystemInstruction, config.Tools, config.ToolConfig})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// textLength returns the number of bytes of text in a content
+func textLength(content *genai.Content) int {
+	n := 0
+	for _, part := range content.Parts {
+		n += len(part.Text)
+	}
+	return n
+}
@@ -0,0 +1,94 @@
+package filesearch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// Errors of a ConversationStore loading a session
+var (
+	ErrUnknownSession = errors.New("unknown chat session")
+	ErrSessionOwner   = errors.New("chat session belongs to another caller")
+)
+
+// ConversationStore keeps chat sessions server-side, see package conversations
+type ConversationStore interface {
+	// LoadConversation returns the last maxMessages messages of a session of owner and its
+	// running summary, ErrUnknownSession for an unknown session, or ErrSessionOwner for the
+	// session of another owner
+	LoadConversation(ctx context.Context, sessionID string, owner string, maxMessages int) ([]HistoryMessage, *ConversationSummary, error)
+	// SaveTurn appends a query and its answer, with the sources it cites and the updated
+	// summary, to the session of the query, creating it for owner
+	SaveTurn(ctx context.Context, owner string, req *QueryRequest, resp *QueryResponse) error
+}
+
+// SubjectResolver returns the authenticated subject of the caller of a request, e.g. the ID of
+// its API key or its JWT subject, empty for anonymous callers
+type SubjectResolver func(r *http.Request) string
+
+// WithConversations keeps the history and summary of every chat session in store, replacing
+// those sent by clients: a query continues the session of its sessionId, or starts a new one
+// whose ID the response returns, and the history and summary it sends are ignored. Sessions
+// belong to the subject that started them; queries of other subjects are refused, and IDs the
+// store does not know are replaced by new ones, so clients cannot choose them. Answers in
+// compare mode, served during outages or refused before answering are not recorded.
+func WithConversations(store ConversationStore, subject SubjectResolver) HandlerOption {
+	return func(h *Handler) {
+		h.conversations = store
+		h.subject = subject
+	}
+}
+
+// loadConversation replaces the history and summary of a query with those of its session,
+// starting a new session for a query without one or with an unknown one
+func (h *Handler) loadConversation(r *http.Request, req *QueryRequest) error {
+	if h.conversations == nil {
+		return nil
+	}
+	req.History, req.Summary = nil, nil
+	if req.SessionID == "" {
+		req.SessionID = newSessionID()
+		return nil
+	}
+
+	history, summary, err := h.conversations.LoadConversation(r.Context(), req.SessionID, h.owner(r), DefaultMaxTurns)
+	if errors.Is(err, ErrUnknownSession) {
+		req.SessionID = newSessionID()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	req.History, req.Summary = history, summary
+	return nil
+}
+
+// saveTurn records an answered query in its session; the answer stands when that fails
+func (h *Handler) saveTurn(r *http.Request, req *QueryRequest, resp *QueryResponse) {
+	if h.conversations == nil {
+		return
+	}
+	resp.SessionID = req.SessionID
+	if err := h.conversations.SaveTurn(r.Context(), h.owner(r), req, resp); err != nil {
+		log.Printf("Warning: failed to save conversation turn: %v", err)
+	}
+}
+
+// owner returns the subject the sessions of the caller of a request belong to
+func (h *Handler) owner(r *http.Request) string {
+	if h.subject == nil {
+		return ""
+	}
+	return h.subject(r)
+}
+
+// newSessionID returns a random chat session ID
+func newSessionID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
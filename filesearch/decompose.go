@@ -0,0 +1,178 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+// MaxSubQuestions is the most parts a compound question is split into
+const MaxSubQuestions = 4
+
+// SubAnswer is the answer to one part of a compound question, with its own citations
+type SubAnswer struct {
+	Question string
+	Response *PromptResponse
+}
+
+// PartAnswer is the answer to one part of a compound question in a query response
+type PartAnswer struct {
+	Question string            `json:"question"`
+	Answer   string            `json:"answer"`
+	Sources  []*SourceDocument `json:"sources"`
+}
+
+// WithDecomposition answers compound questions ("What is the minimum wage at 17 and how many
+// vacation days do I get?") part by part, each with its own retrieval, see Service.Decompose.
+// Follow-up questions with a conversation history are answered as a whole.
+func WithDecomposition() HandlerOption {
+	return func(h *Handler) {
+		h.decompose = true
+	}
+}
+
+// compoundMarker matches what joins the parts of a compound question: a conjunction in one of
+// the languages of the documents, a semicolon, or a question mark followed by more text
+var compoundMarker = regexp.MustCompile(`(?i)\b(and|also|as well as|en|ook|et|aussi|ainsi que|und|auch)\b|;|\?\s*\S`)
+
+// decomposeSchema constrains the decomposition output
+var decomposeSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"questions": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+	},
+	Required: []string{"questions"},
+}
+
+const decomposeInstruction = `You split questions about Belgian collective labour agreements (CAO's) into independent sub-questions.
+When the question asks about several distinct topics, return one self-contained sub-question per topic, repeating the context each needs (sector, age, job).
+When it asks about a single topic, return the question unchanged as the only sub-question. Keep the language of the question.`
+
+// Decompose splits a compound question into independent sub-questions, at most
+// MaxSubQuestions. A question asking about a single topic is returned as the only one; the
+// model is only asked when the question joins several clauses.
+func (s *Service) Decompose(ctx context.Context, question string) ([]string, error) {
+	if !compoundMarker.MatchString(strings.TrimSpace(question)) {
+		return []string{question}, nil
+	}
+
+	resp, err := s.generate(ctx, s.modelName,
+		genai.Text(question),
+		&genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText(decomposeInstruction, genai.RoleUser),
+			ResponseMIMEType:  "application/json",
+			ResponseSchema:    decomposeSchema,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompose question: %w", err)
+	}
+	var result struct {
+		Questions []string `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(resp.Text()), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode sub-questions: %w", err)
+	}
+
+	var questions []string
+	for _, q := range result.Questions {
+		if q = strings.TrimSpace(q); q != "" && len(questions) < MaxSubQuestions {
+			questions = append(questions, q)
+		}
+	}
+	if len(questions) == 0 {
+		return []string{question}, nil
+	}
+	return questions, nil
+}
+
+// PromptDecomposed answers a question like PromptWithRetrieval, but answers the parts of a
+// compound question separately, each with its own retrieval, and combines their answers.
+// The SubAnswers of the response hold the answer and citations of every part.
+func (s *Service) PromptDecomposed(ctx context.Context, question string, storeName string, opts *RetrievalOptions) (*PromptResponse, error) {
+	questions, err := s.Decompose(ctx, question)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 1 {
+		return s.PromptWithRetrieval(ctx, question, storeName, opts)
+	}
+	return answerParts(questions, func(q string) (*PromptResponse, error) {
+		return s.PromptWithRetrieval(ctx, q, storeName, opts)
+	})
+}
+
+// answerParts answers sub-questions concurrently and combines the answers under their question
+func answerParts(questions []string, answer func(question string) (*PromptResponse, error)) (*PromptResponse, error) {
+	results := make([]*PromptResponse, len(questions))
+	errs := make([]error, len(questions))
+
+	var wg sync.WaitGroup
+	for i, q := range questions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = answer(q)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to answer %q: %w", questions[i], err)
+		}
+	}
+
+	combined := &PromptResponse{GroundingSupport: &GroundingSupport{}, Refused: true}
+	offset := 0
+	for i, resp := range results {
+		heading := fmt.Sprintf("**%s**\n\n", questions[i])
+		if i > 0 {
+			heading = "\n\n" + heading
+		}
+		combined.Parts = append(combined.Parts, heading)
+		offset += len(heading)
+
+		// Citations index the combined text
+		for _, c := range resp.Citations {
+			shifted := *c
+			shifted.StartIndex += offset
+			shifted.EndIndex += offset
+			combined.Citations = append(combined.Citations, &shifted)
+		}
+		if resp.GroundingSupport != nil {
+			combined.GroundingSupport.appendGrounding(resp.GroundingSupport, offset)
+		}
+		combined.Parts = append(combined.Parts, resp.Parts...)
+		offset += len(resp.Text())
+
+		combined.ToolCalls = append(combined.ToolCalls, resp.ToolCalls...)
+		combined.Usage = combined.Usage.Add(resp.Usage)
+		combined.Refused = combined.Refused && resp.Refused
+		if combined.PolicyViolation == "" {
+			combined.PolicyViolation = resp.PolicyViolation
+		}
+		if combined.Model == "" {
+			combined.Model, combined.PromptVersion, combined.PromptTemplate = resp.Model, resp.PromptVersion, resp.PromptTemplate
+		}
+		combined.SubAnswers = append(combined.SubAnswers, &SubAnswer{Question: questions[i], Response: resp})
+	}
+	return combined, nil
+}
+
+// partAnswers returns the answers to the parts of a decomposed question for a query response
+func partAnswers(subs []*SubAnswer) []*PartAnswer {
+	var parts []*PartAnswer
+	for _, sub := range subs {
+		parts = append(parts, &PartAnswer{
+			Question: sub.Question,
+			Answer:   sub.Response.Text(),
+			Sources:  collectSources(sub.Response.GroundingSupport),
+		})
+	}
+	return parts
+}
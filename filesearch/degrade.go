@@ -0,0 +1,149 @@
+package filesearch
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// StatusPartial flags a response that lists the retrieved documents instead of an answer
+const StatusPartial = "partial"
+
+// maxSnippetLength is the number of characters of a chunk shown in a partial response
+const maxSnippetLength = 300
+
+// GenerationError reports that retrieval succeeded but no answer could be generated, e.g.
+// because the model stopped for safety reasons or failed after searching. Partial holds the
+// retrieved chunks as grounding, without answer text, so callers can still show the most
+// relevant documents.
+type GenerationError struct {
+	Reason  string          // Why generation failed, e.g. the finish reason "SAFETY"
+	Partial *PromptResponse // Retrieved chunks, in rank order
+	Err     error           // Underlying error, nil when the model returned no answer
+}
+
+func (e *GenerationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("no answer generated from %d retrieved chunks: %v", e.chunks(), e.Err)
+	}
+	return fmt.Sprintf("no answer generated from %d retrieved chunks: %s", e.chunks(), e.Reason)
+}
+
+func (e *GenerationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *GenerationError) chunks() int {
+	if e.Partial == nil || e.Partial.GroundingSupport == nil {
+		return 0
+	}
+	return len(e.Partial.GroundingSupport.GroundingChunks)
+}
+
+// hasFileChunks reports whether any document chunk was retrieved
+func hasFileChunks(gs *GroundingSupport) bool {
+	if gs == nil {
+		return false
+	}
+	for _, chunk := range gs.GroundingChunks {
+		if chunk.File != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAnswer returns a *GenerationError when the model returned no answer text although
+// File Search retrieved chunks
+func checkAnswer(resp *genai.GenerateContentResponse, parsed *PromptResponse) error {
+	if len(parsed.Parts) > 0 || !hasFileChunks(parsed.GroundingSupport) {
+		return nil
+	}
+
+	reason := "the model returned no answer"
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != "" {
+		reason = string(resp.Candidates[0].FinishReason)
+	}
+	return &GenerationError{Reason: reason, Partial: parsed}
+}
+
+// degrade answers with the documents retrieved for a query when generation failed after
+// retrieval, and reports whether it did
+func (h *Handler) degrade(w http.ResponseWriter, r *http.Request, storeName string, err error) bool {
+	var genErr *GenerationError
+	if !errors.As(err, &genErr) {
+		return false
+	}
+	partial := genErr.Partial
+	if partial == nil || !hasFileChunks(partial.GroundingSupport) {
+		return false
+	}
+
+	h.linkPages(r.Context(), storeName, partial.GroundingSupport)
+	h.linkArticles(partial.GroundingSupport)
+	h.redactURIs(r.Context(), storeName, partial.GroundingSupport)
+	if h.usage != nil && partial.Usage != nil {
+		h.usage(r, partial.Usage)
+	}
+
+	response := QueryResponse{
+		Status:           StatusPartial,
+		Sources:          collectSources(partial.GroundingSupport),
+		GroundingSupport: partial.GroundingSupport,
+		Retrieved:        snippets(partial.GroundingSupport),
+		ToolCalls:        partial.ToolCalls,
+		Usage:            partial.Usage,
+	}
+	response.Answer = DocumentList(genErr.Reason, response.Sources)
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, response)
+	return true
+}
+
+// DocumentList explains that no answer could be generated and lists the documents found instead
+func DocumentList(reason string, sources []*SourceDocument) string {
+	var sb strings.Builder
+	sb.WriteString("No answer could be generated")
+	if reason != "" {
+		sb.WriteString(" (" + reason + ")")
+	}
+	sb.WriteString(". These documents are the most relevant to your question:\n")
+	for _, source := range sources {
+		sb.WriteString("\n- " + source.FileName)
+		if source.Page > 0 {
+			fmt.Fprintf(&sb, ", p. %d", source.Page)
+		}
+		if len(source.Articles) > 0 {
+			sb.WriteString(", " + strings.Join(source.Articles, ", "))
+		}
+	}
+	return sb.String()
+}
+
+// snippets returns the retrieved document chunks in rank order, shortened for display
+func snippets(gs *GroundingSupport) []*RetrievedChunk {
+	var chunks []*RetrievedChunk
+	for _, chunk := range gs.GroundingChunks {
+		if chunk.File == nil {
+			continue
+		}
+		text := chunk.File.Text
+		if runes := []rune(text); len(runes) > maxSnippetLength {
+			text = strings.TrimSpace(string(runes[:maxSnippetLength])) + "…"
+		}
+		chunks = append(chunks, &RetrievedChunk{
+			Rank:     len(chunks) + 1,
+			FileName: chunk.File.FileName,
+			URI:      chunk.File.URI,
+			Page:     chunk.File.Page,
+			Article:  chunk.File.Article,
+			Text:     text,
+			Corpus:   chunk.File.Corpus,
+		})
+	}
+	return chunks
+}
@@ -0,0 +1,65 @@
+package filesearch
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// DocumentListTTL is how long CachedDocuments serves the listing of a store. Uploads and
+// deletions through the Service drop the listing of their store at once; changes made by other
+// processes show within the TTL.
+const DocumentListTTL = 5 * time.Minute
+
+// documentList is a cached listing of the documents of a store
+type documentList struct {
+	docs    []*Document
+	expires time.Time
+}
+
+// CachedDocuments returns the documents of a store like ListDocuments, from a listing at most
+// DocumentListTTL old, for lookups made on every request such as the metadata of retrieved
+// chunks. The documents are shared between callers and must not be changed.
+func (s *Service) CachedDocuments(ctx context.Context, storeName string) ([]*Document, error) {
+	now := time.Now()
+	s.listsMu.Lock()
+	list, ok := s.lists[storeName]
+	generation := s.listsGen
+	s.listsMu.Unlock()
+	if ok && now.Before(list.expires) {
+		return list.docs, nil
+	}
+
+	docs, err := s.ListDocuments(ctx, storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	s.listsMu.Lock()
+	defer s.listsMu.Unlock()
+
+	// Keep the listing only when no document changed while listing
+	if s.listsGen == generation {
+		if s.lists == nil {
+			s.lists = make(map[string]*documentList)
+		}
+		s.lists[storeName] = &documentList{docs: docs, expires: now.Add(DocumentListTTL)}
+	}
+	return docs, nil
+}
+
+// forgetDocuments drops the cached listing of a store, by store or document resource name,
+// and those of the virtual stores, which may federate it
+func (s *Service) forgetDocuments(name string) {
+	storeName, _, _ := strings.Cut(name, "/documents/")
+
+	s.listsMu.Lock()
+	defer s.listsMu.Unlock()
+
+	s.listsGen++
+	for cached := range s.lists {
+		if cached == storeName || s.federation(cached) != nil {
+			delete(s.lists, cached)
+		}
+	}
+}
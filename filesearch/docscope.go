@@ -0,0 +1,87 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// MaxScopedDocuments is the number of documents an answer may be restricted to with
+// QueryOptions.Documents
+const MaxScopedDocuments = 20
+
+// scopeDocuments restricts the file search of an answer to documents of the store by display
+// name, e.g. to ask what a single agreement says. File Search cannot filter on document names,
+// so when every document was uploaded with its content hash, see UploadOptions.Hash, the search
+// is filtered on those hashes. The model is told to use only those documents either way, and
+// scopeChunks drops the chunks of other documents from the answer.
+func (s *Service) scopeDocuments(ctx context.Context, storeName string, documents []string, config *genai.GenerateContentConfig) error {
+	filter, err := s.documentsFilter(ctx, storeName, "options.documents", documents)
+	if err != nil {
+		return err
+	}
+	if filter != "" {
+		for _, tool := range config.Tools {
+			if fs := tool.FileSearch; fs != nil {
+				fs.MetadataFilter = andFilter(fs.MetadataFilter, filter)
+			}
+		}
+	}
+
+	quoted := make([]string, len(documents))
+	for i, name := range documents {
+		quoted[i] = strconv.Quote(name)
+	}
+	addInstruction(config, fmt.Sprintf("Only use the documents %s. If they do not address the question, say so.", strings.Join(quoted, ", ")))
+	return nil
+}
+
+// documentsFilter returns a metadata filter matching the content hashes of documents of the
+// store by display name, or an empty filter when one of them was uploaded without its hash.
+// Unknown documents fail with a *FieldError for field.
+func (s *Service) documentsFilter(ctx context.Context, storeName string, field string, documents []string) (string, error) {
+	docs, err := s.ListDocuments(ctx, storeName)
+	if err != nil {
+		return "", err
+	}
+	clauses := make([]string, 0, len(documents))
+	for _, name := range documents {
+		i := slices.IndexFunc(docs, func(doc *Document) bool { return doc.DisplayName == name })
+		if i < 0 {
+			return "", &FieldError{Field: field, Message: "unknown document " + strconv.Quote(name)}
+		}
+		if hash := docs[i].CustomMetadata[MetadataContentHash]; hash != "" {
+			clauses = append(clauses, fmt.Sprintf("%s = %q", MetadataContentHash, hash))
+		}
+	}
+	if len(clauses) < len(documents) {
+		return "", nil
+	}
+	return strings.Join(clauses, " OR "), nil
+}
+
+// andFilter combines two metadata filters, either of which may be empty
+func andFilter(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return fmt.Sprintf("(%s) AND (%s)", a, b)
+}
+
+// scopeChunks drops the grounding chunks of documents outside QueryOptions.Documents
+func scopeChunks(ctx context.Context, resp *PromptResponse) {
+	opts, _ := ctx.Value(queryOptionsKey{}).(*QueryOptions)
+	if opts == nil || len(opts.Documents) == 0 || resp.GroundingSupport == nil {
+		return
+	}
+	resp.GroundingSupport.keepChunks(func(chunk *GroundingChunk) bool {
+		return chunk.File == nil || slices.Contains(opts.Documents, chunk.File.FileName)
+	})
+}
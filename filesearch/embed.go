@@ -0,0 +1,49 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+
+	"rag/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genai"
+)
+
+// Embedding task types
+const (
+	TaskRetrievalDocument = "RETRIEVAL_DOCUMENT"
+	TaskRetrievalQuery    = "RETRIEVAL_QUERY"
+	TaskClustering        = "CLUSTERING"
+)
+
+// Embed returns an embedding per text, in order, using the configured embedding model.
+// taskType is TaskRetrievalDocument for indexed chunks, TaskRetrievalQuery for questions and
+// TaskClustering for grouping similar texts.
+func (s *Service) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	contents := make([]*genai.Content, 0, len(texts))
+	for _, text := range texts {
+		contents = append(contents, genai.NewContentFromText(text, genai.RoleUser))
+	}
+
+	ctx, span := s.tracer.Start(ctx, "gemini.embed", trace.WithAttributes(
+		telemetry.ModelKey.String(s.embeddingModel),
+		telemetry.DocumentsKey.Int(len(texts)),
+	))
+	resp, err := s.client.Models.EmbedContent(ctx, s.embeddingModel, contents, &genai.EmbedContentConfig{
+		TaskType: taskType,
+	})
+	telemetry.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed content: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+
+	vectors := make([][]float32, 0, len(resp.Embeddings))
+	for _, e := range resp.Embeddings {
+		vectors = append(vectors, e.Values)
+	}
+	return vectors, nil
+}
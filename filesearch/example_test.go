@@ -2,13 +2,24 @@ package filesearch_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"rag/filesearch"
+	"rag/filesearchtest"
+	"rag/kv"
+	"rag/vcr"
 
+	"cloud.google.com/go/auth"
 	"google.golang.org/genai"
 )
 
@@ -81,3 +92,1942 @@ func Example() {
 		}
 	}
 }
+
+func ExampleService_PromptWithTools() {
+	ctx := context.Background()
+
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey: os.Getenv("GEMINI_API_KEY"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Register date arithmetic and a custom tool
+	tools := filesearch.NewToolRegistry()
+	if err := filesearch.RegisterDateTools(tools); err != nil {
+		log.Fatal(err)
+	}
+	err = tools.Register(&filesearch.Tool{
+		Name:        "current_date",
+		Description: "Returns today's date",
+		Parameters:  map[string]any{"type": "object"},
+		Func: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			return map[string]any{"date": time.Now().Format(time.DateOnly)}, nil
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := service.PromptWithTools(ctx, "Ik ben in dienst sinds 2019-03-01. Hoeveel anciënniteit heb ik vandaag?", store.Name, tools, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, call := range resp.ToolCalls {
+		fmt.Printf("Called %s(%v) = %v\n", call.Name, call.Args, call.Result)
+	}
+	for _, part := range resp.Parts {
+		fmt.Println(part)
+	}
+}
+
+// The documents retrieved while calling tools are listed as sources, while the markers follow
+// the spans of the final answer only.
+func ExampleService_PromptWithTools_sources() {
+	rec, err := vcr.New("testdata/tools.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tools := filesearch.NewToolRegistry()
+	if err := filesearch.RegisterDateTools(tools); err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptWithTools(ctx, "Ik ben in dienst sinds 2019-03-01. Welke opzeggingstermijn geldt vandaag, 2026-10-16?", "fileSearchStores/cao-documents-x1y2z3", tools, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, call := range resp.ToolCalls {
+		fmt.Println("Called", call.Name, "=", call.Result["years"], "years")
+	}
+	filesearch.MarkSources(resp)
+	fmt.Println(resp.Text())
+	for _, fn := range filesearch.Footnotes(resp) {
+		fmt.Printf("[%d] %s\n", fn.Number, fn.FileName)
+	}
+	// Output:
+	// Called days_between = 7 years
+	// U bent 7 jaar in dienst, dus uw opzeggingstermijn bedraagt 9 weken.[2]
+	// [1] 302-2019-013347.pdf
+	// [2] 302-2024-003311.pdf
+}
+
+func ExampleRenderMarkdown() {
+	resp := &filesearch.PromptResponse{
+		Parts: []string{"Het minimumuurloon bedraagt 14,05 EUR."},
+		GroundingSupport: &filesearch.GroundingSupport{
+			GroundingChunks: []*filesearch.GroundingChunk{
+				{File: &filesearch.FileGroundingChunk{FileName: "100-2022-011302.pdf", Page: 2, Link: "https://example.org/100-2022-011302.pdf#page=2"}},
+				{File: &filesearch.FileGroundingChunk{FileName: "100-2022-011302.pdf", Page: 3}},
+				{File: &filesearch.FileGroundingChunk{FileName: "100-2023-014786.pdf"}},
+				{File: &filesearch.FileGroundingChunk{FileName: "100-2022-011302.pdf"}},
+			},
+		},
+	}
+
+	fmt.Println(filesearch.RenderMarkdown(resp))
+	// Output:
+	// Het minimumuurloon bedraagt 14,05 EUR.
+	//
+	// **Sources**
+	//
+	// [1] [100-2022-011302.pdf, p. 2](https://example.org/100-2022-011302.pdf#page=2) (support 75%)
+	// [2] 100-2023-014786.pdf (support 25%)
+}
+
+func ExampleMarkSources() {
+	rec, err := vcr.New("testdata/markers.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := service.Prompt(ctx, "Wat verdient een werkman van 18 jaar, en krijgt hij een eindejaarspremie?", "fileSearchStores/cao-documents-x1y2z3")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filesearch.MarkSources(resp)
+	fmt.Println(filesearch.RenderMarkdown(resp))
+	// Output:
+	// Werklieden van 18 jaar verdienen minstens 14,05 EUR per uur.[1] Vanaf 21 jaar krijgen ze 15,12 EUR[1], en de eindejaarspremie bedraagt één maandloon.[2]
+	//
+	// **Sources**
+	//
+	// [1] [302-2024-003311.pdf](fileSearchStores/cao-documents-x1y2z3/documents/302-2024-003311-pdf-r7t2) (support 67%)
+	// [2] [302-2019-013347.pdf](fileSearchStores/cao-documents-x1y2z3/documents/302-2019-013347-pdf-c3d4) (support 33%)
+}
+
+func ExampleGroundingSupport_LowConfidence() {
+	rec, err := vcr.New("testdata/confidence.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := service.Prompt(ctx, "Wat verdient een werkman van 18 jaar, en krijgt hij een eindejaarspremie?", "fileSearchStores/cao-documents-x1y2z3")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, seg := range resp.GroundingSupport.Segments {
+		fmt.Printf("%d-%d %.2f %s\n", seg.StartIndex, seg.EndIndex, seg.Confidence(), seg.Text)
+	}
+	for _, seg := range resp.GroundingSupport.LowConfidence(filesearch.MinSegmentConfidence) {
+		fmt.Println("weakly supported:", seg.Text)
+	}
+	// Output:
+	// 0-60 0.93 Werklieden van 18 jaar verdienen minstens 14,05 EUR per uur.
+	// 61-95 0.88 Vanaf 21 jaar krijgen ze 15,12 EUR
+	// 100-145 0.32 de eindejaarspremie bedraagt één maandloon.
+	// weakly supported: de eindejaarspremie bedraagt één maandloon.
+}
+
+func ExampleChannelTemplate_Render() {
+	resp := &filesearch.PromptResponse{
+		Parts: []string{"Het minimumuurloon bedraagt **14,05 EUR** voor werklieden van 18 jaar & ouder."},
+		GroundingSupport: &filesearch.GroundingSupport{
+			GroundingChunks: []*filesearch.GroundingChunk{
+				{File: &filesearch.FileGroundingChunk{FileName: "100-2022-011302.pdf", Page: 2, Link: "https://example.org/100-2022-011302.pdf#page=2"}},
+				{File: &filesearch.FileGroundingChunk{FileName: "100-2023-014786.pdf"}},
+			},
+		},
+	}
+
+	fmt.Println(filesearch.DefaultChannels["slack"].Render(resp))
+	fmt.Println()
+	fmt.Println(filesearch.DefaultChannels["telegram"].Render(resp))
+	fmt.Println()
+	short := &filesearch.ChannelTemplate{Format: "text", Citations: filesearch.CitationsNone, MaxLength: 40}
+	fmt.Println(short.Render(resp))
+	// Output:
+	// Het minimumuurloon bedraagt *14,05 EUR* voor werklieden van 18 jaar &amp; ouder.
+	//
+	// *Sources*
+	// [1] <https://example.org/100-2022-011302.pdf#page=2|100-2022-011302.pdf, p. 2>
+	// [2] 100-2023-014786.pdf
+	//
+	// Het minimumuurloon bedraagt <b>14,05 EUR</b> voor werklieden van 18 jaar &amp; ouder.
+	//
+	// <b>Sources:</b> <a href="https://example.org/100-2022-011302.pdf#page=2">100-2022-011302.pdf</a>, 100-2023-014786.pdf
+	//
+	// Het minimumuurloon bedraagt 14,05…
+}
+
+// Recorded interactions make the service deterministic in tests. Set VCR_MODE=record
+// and a real API key to refresh the fixture.
+func Example_replay() {
+	rec, err := vcr.New("testdata/prompt.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptWithRetrieval(ctx, "Wat is het minimumuurloon voor een werkman van 18 jaar?",
+		store.Name, &filesearch.RetrievalOptions{TopK: 5})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(resp.Text())
+	fmt.Println(resp.GroundingSupport.GroundingChunks[0].File.FileName, resp.Usage.TotalTokens)
+	// Output:
+	// Het minimumuurloon voor werklieden van 18 jaar en ouder bedraagt 14,05 EUR.
+	// 100-2022-011302.pdf 435
+}
+
+// Every API request of the service waits for its turn, so a bulk job stays within the quota
+// instead of failing with 429 Too Many Requests.
+func ExampleConfig_requestsPerMinute() {
+	rec, err := vcr.New("testdata/prompt.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:            apiKey,
+		HTTPClient:        rec.Client(),
+		RequestsPerMinute: 600, // One request every 100ms
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	start := time.Now()
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = service.PromptWithRetrieval(ctx, "Wat is het minimumuurloon voor een werkman van 18 jaar?",
+		store.Name, &filesearch.RetrievalOptions{TopK: 5})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(time.Since(start) >= 100*time.Millisecond)
+	// Output:
+	// true
+}
+
+// A question asked again is answered from the response cache, without calling the model
+// or billing tokens.
+func ExampleConfig_responseCache() {
+	rec, err := vcr.New("testdata/prompt.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:        apiKey,
+		HTTPClient:    rec.Client(),
+		ResponseCache: filesearch.NewMemoryResponseCache(100, 10*time.Minute),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for range 2 {
+		resp, err := service.PromptWithRetrieval(ctx, "Wat is het minimumuurloon voor een werkman van 18 jaar?",
+			store.Name, &filesearch.RetrievalOptions{TopK: 5})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(resp.Cached, resp.Usage != nil)
+	}
+	// Output:
+	// false true
+	// true false
+}
+
+// slowTransport delays every API request, like a model taking its time to answer
+type slowTransport struct {
+	base  http.RoundTripper
+	delay time.Duration
+}
+
+func (t slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(t.delay)
+	return t.base.RoundTrip(req)
+}
+
+// Only one answer is generated at a time; a second question gives up after waiting 50ms for
+// the first to finish, instead of piling on the quota.
+func ExampleConfig_maxConcurrentGenerations() {
+	rec, err := vcr.New("testdata/prompt.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:                   "replay",
+		HTTPClient:               &http.Client{Transport: slowTransport{rec, 200 * time.Millisecond}},
+		MaxConcurrentGenerations: 1,
+		GenerationQueueTimeout:   50 * time.Millisecond,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := service.PromptWithRetrieval(ctx, "Wat is het minimumuurloon voor een werkman van 18 jaar?",
+				store.Name, &filesearch.RetrievalOptions{TopK: 5})
+			errs <- err
+		}()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			fmt.Println(errors.Is(err, filesearch.ErrOverloaded), filesearch.IsUnavailable(err))
+		}
+	}
+	// Output:
+	// true true
+}
+
+func ExampleService_PromptWithOptions() {
+	rec, err := vcr.New("testdata/options.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptWithOptions(ctx, "Wat is het minimumuurloon voor een werkman van 18 jaar?", filesearch.QueryOptions{
+		StoreName:         store.Name,
+		Temperature:       genai.Ptr[float32](0.1),
+		MaxOutputTokens:   256,
+		SystemInstruction: "Antwoord in één zin.",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(resp.Text())
+	// Output:
+	// Werklieden van 18 jaar verdienen minstens 14,05 EUR per uur.
+}
+
+// A question answered from a single agreement of the store, e.g. to compare what each one says.
+func ExampleService_PromptWithOptions_documents() {
+	rec, err := vcr.New("testdata/scope_documents.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp, err := service.PromptWithOptions(ctx, "Hoeveel bedraagt de eindejaarspremie?", filesearch.QueryOptions{
+		StoreName: "fileSearchStores/cao-documents-x1y2z3",
+		Documents: []string{"302-2019-013347.pdf"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(resp.Text())
+	for _, chunk := range resp.GroundingSupport.GroundingChunks {
+		fmt.Println("Source:", chunk.File.FileName)
+	}
+	// Output:
+	// Volgens cao 302-2019-013347 bedraagt de eindejaarspremie een volledig maandloon voor wie het hele jaar in dienst was.
+	// Source: 302-2019-013347.pdf
+}
+
+// Two agreements compared on one question. Each side is only searched in its own document,
+// filtered on the content hash it was uploaded with.
+func ExampleService_Compare() {
+	rec, err := vcr.New("testdata/compare.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	comparison, err := service.Compare(ctx, "Hoeveel bedraagt de eindejaarspremie?", "fileSearchStores/cao-documents-x1y2z3",
+		&filesearch.CompareSide{Document: "302-2019-013347.pdf"},
+		&filesearch.CompareSide{Document: "302-2024-003311.pdf"},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, side := range []*filesearch.ComparedSide{comparison.Left, comparison.Right} {
+		fmt.Printf("%s: %d source(s) from %s\n", side.Label, len(side.Sources), side.Sources[0].FileName)
+	}
+	for _, d := range comparison.Differences {
+		fmt.Printf("%s: %s / %s\n", d.Aspect, d.Left, d.Right)
+	}
+	fmt.Println(comparison.Summary)
+	// Output:
+	// 302-2019-013347.pdf: 1 source(s) from 302-2019-013347.pdf
+	// 302-2024-003311.pdf: 1 source(s) from 302-2024-003311.pdf
+	// Bedrag: Het brutomaandloon van december (art. 5) / 8,33 % van het brutojaarloon (art. 4)
+	// De cao van 2024 berekent de eindejaarspremie op het jaarloon in plaats van op het loon van december.
+}
+
+func ExampleService_PromptWithHistory() {
+	rec, err := vcr.New("testdata/history.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptWithHistory(ctx, "En voor 17 jaar?", store.Name, []filesearch.HistoryMessage{
+		{Role: "user", Content: "Wat is het minimumuurloon voor een werkman van 18 jaar?"},
+		{Role: "assistant", Content: "Het minimumuurloon voor werklieden van 18 jaar en ouder bedraagt 14,05 EUR."},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(resp.Text())
+	// Output:
+	// Jongeren van 17 jaar hebben recht op 94% van het minimumuurloon, of 13,21 EUR.
+}
+
+func ExampleService_PromptWithAttachment() {
+	rec, err := vcr.New("testdata/attachment.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// A payslip the user just received, asked about without indexing it in a store
+	payslip := strings.NewReader("%PDF-1.4\n% loonbrief september 2026\n")
+	attachment, err := service.UploadAttachment(ctx, payslip, "loonbrief-2026-09.pdf", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptWithAttachment(ctx, "Wat is mijn brutoloon?", attachment, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(attachment.Name)
+	fmt.Println(resp.Text())
+	// Output:
+	// files/k7m2p9qx4a
+	// Uw brutoloon voor september 2026 bedraagt 2.841,50 EUR, voor 163 gewerkte uren aan 17,43 EUR per uur.
+}
+
+func ExampleNewService_contextCache() {
+	rec, err := vcr.New("testdata/contextcache.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+
+	// A long store profile is cached once and reused by every prompt on the store
+	instruction := strings.Repeat("Je beantwoordt vragen over Belgische cao's en citeert altijd het artikel waarop het antwoord steunt. ", 50)
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+		Profiles: &filesearch.Profiles{Stores: map[string]*filesearch.Profile{
+			"cao-documents": {SystemInstruction: instruction},
+		}},
+		ContextCacheTTL: time.Hour,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer service.Close(ctx)
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, age := range []int{18, 17} {
+		resp, err := service.Prompt(ctx, fmt.Sprintf("Wat is het minimumuurloon voor een werkman van %d jaar?", age), store.Name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d cached tokens: %s\n", resp.Usage.CachedTokens, resp.Text())
+	}
+	// Output:
+	// 1187 cached tokens: Het minimumuurloon voor werklieden van 18 jaar en ouder bedraagt 14,05 EUR.
+	// 1187 cached tokens: Jongeren van 17 jaar hebben recht op 94% van het minimumuurloon, of 13,21 EUR.
+}
+
+// A migration interrupted after the first document resumes with the documents the new store
+// lacks, uploaded again from their originals with their typed metadata.
+func ExampleService_MigrateStore() {
+	rec, err := vcr.New("testdata/migrate.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+		Originals: func(ctx context.Context, doc *filesearch.Document) ([]byte, error) {
+			return []byte("Originele inhoud van " + doc.DisplayName), nil
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := service.MigrateStore(ctx, "fileSearchStores/cao-documents-x1y2z3", "fileSearchStores/cao-documents-v2-a9b8")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, change := range report.Changes {
+		fmt.Println(change.Action, change.Document)
+	}
+	fmt.Println(report.Unchanged, "already migrated")
+	// Output:
+	// upload 302-2024-003311.pdf
+	// 1 already migrated
+}
+
+func ExampleDocumentLanguage() {
+	for _, text := range []string{
+		"De werkgever betaalt een eindejaarspremie aan de arbeiders die een jaar in dienst zijn.",
+		"L'employeur paie une prime de fin d'année aux ouvriers qui sont en service depuis un an.",
+		"De werkgever betaalt een premie. L'employeur paie une prime.",
+	} {
+		fmt.Printf("%q\n", filesearch.DocumentLanguage([]byte(text), "text/plain"))
+	}
+	// Output:
+	// "nl"
+	// "fr"
+	// ""
+}
+
+func ExampleService_StoreStats() {
+	rec, err := vcr.New("testdata/stats.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stats, err := service.StoreStats(ctx, "fileSearchStores/cao-documents-x1y2z3")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(stats.DisplayName, stats.Documents, "documents,", stats.Pending, "pending,", stats.Failed, "failed")
+	fmt.Println(stats.SizeBytes, "bytes, updated", stats.UpdateTime.Format(time.DateOnly))
+	// Output:
+	// cao-documents 416 documents, 3 pending, 1 failed
+	// 187342016 bytes, updated 2026-10-14
+}
+
+// A virtual store answers from the sector agreements and the legislation together, labeling
+// every source with its corpus.
+func ExampleFederatedStore() {
+	rec, err := vcr.New("testdata/federation.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+		Profiles: &filesearch.Profiles{Stores: map[string]*filesearch.Profile{
+			"arbeidsrecht": {
+				SystemInstruction: "Cite the sector agreement before the law.",
+				Federation: []*filesearch.FederatedStore{
+					{Store: "cao-documents", Label: "Sector-cao"},
+					{Store: "wetgeving", Label: "Wetgeving"},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "arbeidsrecht")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.Prompt(ctx, "Hoe lang is de opzeggingstermijn na 5 jaar dienst?", store.Name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(resp.Text())
+	for _, fn := range filesearch.Footnotes(resp) {
+		fmt.Printf("[%d] %s: %s\n", fn.Number, fn.Corpus, fn.FileName)
+	}
+	// Output:
+	// Na 5 jaar dienst bedraagt de opzeggingstermijn 15 weken; de sector-cao voorziet geen afwijking.
+	// [1] Wetgeving: wet-arbeidsovereenkomsten.pdf
+	// [2] Sector-cao: 100-2022-011302.pdf
+}
+
+func ExampleService_Use() {
+	rec, err := vcr.New("testdata/prompt.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     "replay",
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Name the documents the answer is based on
+	service.Use(func(resp *filesearch.PromptResponse) error {
+		for _, source := range filesearch.Footnotes(resp) {
+			resp.Parts = append(resp.Parts, "\nBron: "+source.FileName)
+		}
+		return nil
+	})
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptWithRetrieval(ctx, "Wat is het minimumuurloon voor een werkman van 18 jaar?",
+		store.Name, &filesearch.RetrievalOptions{TopK: 5})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(resp.Text())
+	// Output:
+	// Het minimumuurloon voor werklieden van 18 jaar en ouder bedraagt 14,05 EUR.
+	// Bron: 100-2022-011302.pdf
+}
+
+func ExampleChatSession() {
+	rec, err := vcr.New("testdata/chat.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Keep only the last exchange
+	chat := service.NewChat(store.Name, &filesearch.ChatOptions{MaxMessages: 2})
+	for _, question := range []string{"Wat is het minimumuurloon voor een werkman van 18 jaar?", "En voor 17 jaar?"} {
+		resp, err := chat.Send(ctx, question)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(resp.Text())
+	}
+	fmt.Println(len(chat.History()), chat.History()[0].Content)
+	// Output:
+	// Het minimumuurloon voor werklieden van 18 jaar en ouder bedraagt 14,05 EUR.
+	// Jongeren van 17 jaar hebben recht op 94% van het minimumuurloon, of 13,21 EUR.
+	// 2 En voor 17 jaar?
+}
+
+func ExampleService_Intercept() {
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, &filesearch.Config{APIKey: "replay"})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Scope retrieval to the documents of the tenant, and refuse questions about salaries
+	// of named persons without calling the model
+	service.Intercept(
+		func(ctx context.Context, req *filesearch.PromptRequest) (*filesearch.PromptResponse, error) {
+			req.Retrieval = req.Retrieval.WithFilter(`tenant = "hr-antwerpen"`)
+			return nil, nil
+		},
+		func(ctx context.Context, req *filesearch.PromptRequest) (*filesearch.PromptResponse, error) {
+			if strings.Contains(strings.ToLower(req.Prompt), "verdient") {
+				return &filesearch.PromptResponse{Parts: []string{"Ik beantwoord geen vragen over individuele lonen."}}, nil
+			}
+			return nil, nil
+		},
+	)
+
+	resp, err := service.PromptWithRetrieval(ctx, "Hoeveel verdient Jan Peeters?", "fileSearchStores/cao-documents-x1y2z3", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(resp.Text())
+	// Output:
+	// Ik beantwoord geen vragen over individuele lonen.
+}
+
+func ExampleAnswerCache() {
+	cache := filesearch.NewAnswerCache(100, 0.8)
+	cache.Put("cao-documents", "Wat is het minimumloon in de horeca?", &filesearch.QueryResponse{
+		Answer: "Het minimumuurloon bedraagt 14,05 EUR.",
+	})
+
+	for _, query := range []string{
+		"wat is het minimumloon in de Horeca",
+		"Hoeveel vakantiedagen heb ik?",
+	} {
+		if cached, ok := cache.Get("cao-documents", query); ok {
+			fmt.Printf("%s (cached for %q)\n", cached.Answer, cached.Cached.Query)
+		} else {
+			fmt.Println("no cached answer")
+		}
+	}
+	// Output:
+	// Het minimumuurloon bedraagt 14,05 EUR. (cached for "Wat is het minimumloon in de horeca?")
+	// no cached answer
+}
+
+// localIndex stands in for a provider answering from a local index
+type localIndex struct{}
+
+func (localIndex) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	return nil, fmt.Errorf("not used")
+}
+
+func (localIndex) RetrieveChunks(ctx context.Context, query string, storeName string, opts *filesearch.RetrievalOptions) ([]*filesearch.RetrievedChunk, error) {
+	score := 0.83
+	return []*filesearch.RetrievedChunk{{
+		Rank:     1,
+		FileName: "100-2022-011302.pdf",
+		Page:     2,
+		Text:     "Het minimumuurloon bedraagt 14,05 EUR.",
+		Score:    &score,
+	}}, nil
+}
+
+func ExampleService_Retrieve() {
+	rec, err := vcr.New("testdata/retrieve.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	chunks, err := service.Retrieve(ctx, "Wat is het minimumuurloon?", store.Name, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		fmt.Printf("%d. %s (jc %s): %s\n", chunk.Rank, chunk.FileName, chunk.Metadata["jc_number"], chunk.Text)
+	}
+	// Output:
+	// 1. 302-2023-004512.pdf (jc 3020000): Het minimumuurloon in het paritair comité 302 bedraagt 15,12 EUR.
+	// 2. 100-2022-011302.pdf (jc 1000000): Het minimumuurloon bedraagt 14,05 EUR voor werklieden van 18 jaar en ouder.
+}
+
+func ExampleService_PromptDecomposed() {
+	rec, err := vcr.New("testdata/decompose.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptDecomposed(ctx, "Wat is het minimumuurloon op 17 jaar en hoeveel vakantiedagen krijg ik?", store.Name, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Every part is answered from its own documents
+	for _, sub := range resp.SubAnswers {
+		fmt.Printf("%s\n%s [%s]\n", sub.Question, sub.Response.Text(), filesearch.Footnotes(sub.Response)[0].FileName)
+	}
+	// Output:
+	// Wat is het minimumuurloon voor een werkman van 17 jaar?
+	// Jongeren van 17 jaar hebben recht op 94% van het minimumuurloon, of 13,21 EUR. [100-2022-011302.pdf]
+	// Hoeveel vakantiedagen krijgt een werkman van 17 jaar?
+	// Een werkman met een volledig refertejaar heeft recht op 20 vakantiedagen. [302-2023-004512.pdf]
+}
+
+func ExampleHandler_DebugRetrieve() {
+	handler := filesearch.NewHandler(nil, filesearch.WithProvider(localIndex{}))
+
+	body := `{"query": "Wat is het minimumloon?", "storeName": "cao-documents", "asOf": "2023-06-01"}`
+	rec := httptest.NewRecorder()
+	handler.DebugRetrieve(rec, httptest.NewRequest(http.MethodPost, "/debug/retrieve", strings.NewReader(body)))
+
+	var debug filesearch.RetrievalDebug
+	if err := json.Unmarshal(rec.Body.Bytes(), &debug); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(debug.Store, debug.MetadataFilter)
+	for _, chunk := range debug.Chunks {
+		fmt.Printf("%d. %s p.%d (%.2f): %s\n", chunk.Rank, chunk.FileName, chunk.Page, *chunk.Score, chunk.Text)
+	}
+	// Output:
+	// cao-documents valid_from <= 19509 AND valid_until >= 19509
+	// 1. 100-2022-011302.pdf p.2 (0.83): Het minimumuurloon bedraagt 14,05 EUR.
+}
+
+func ExampleLoadProfiles() {
+	dir, err := os.MkdirTemp("", "profiles")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "profiles.yaml")
+	err = os.WriteFile(path, []byte(`stores:
+  cao-documents:
+    language: nl
+    disclaimer: Dit antwoord is informatief en niet juridisch bindend.
+    temperature: 0.2
+    allowedModels: [gemini-2.5-pro]
+`), 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	profiles, err := filesearch.LoadProfiles(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	profile := profiles.Get("cao-documents")
+	fmt.Println(profile.Language, *profile.Temperature, profile.AllowedModels)
+	fmt.Println(profiles.Get("cao-documents-fr") == nil)
+	// Output:
+	// nl 0.2 [gemini-2.5-pro]
+	// true
+}
+
+func ExampleWithScope() {
+	dir, err := os.MkdirTemp("", "scope")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scope.yaml")
+	err = os.WriteFile(path, []byte(`description: Belgian collective labour agreements
+topics:
+  - name: tax
+    description: personal income tax, tax returns and withholding tax
+    keywords: [belasting, belastingen, bedrijfsvoorheffing, impôt, impôts, précompte professionnel]
+    refusal: Vragen over belastingen vallen buiten de cao's.
+    resources:
+      - title: FOD Financiën
+        url: https://financien.belgium.be/nl/particulieren
+`), 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scope, err := filesearch.LoadScope(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	handler := filesearch.NewHandler(nil, filesearch.WithProvider(localIndex{}), filesearch.WithScope(scope))
+	body := `{"query": "Hoeveel belasting betaal ik op mijn eindejaarspremie?", "storeName": "cao-documents"}`
+	rec := httptest.NewRecorder()
+	handler.Query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+
+	var resp filesearch.QueryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(resp.OutOfScope.Topic)
+	fmt.Println(resp.Answer)
+	// Output:
+	// tax
+	// Vragen over belastingen vallen buiten de cao's.
+	//
+	// - FOD Financiën: https://financien.belgium.be/nl/particulieren
+}
+
+func ExampleLegalNotice() {
+	doc := &filesearch.Document{
+		DisplayName:    "302-2023-004512.pdf",
+		CustomMetadata: map[string]string{filesearch.MetadataValidFrom: "19358"},
+	}
+	notice := &filesearch.LegalNotice{
+		Disclaimer:   "This answer is informative and not legally binding.",
+		VersionDates: true,
+	}
+
+	fmt.Println(notice.Text([]*filesearch.SourceDocument{
+		{FileName: doc.DisplayName, Version: filesearch.DocumentVersion(doc)},
+	}))
+	// Output:
+	// ---
+	// Document versions: 302-2023-004512.pdf (2023-01-01)
+	//
+	// This answer is informative and not legally binding.
+}
+
+// overloadedModel retrieves chunks but fails to answer from them
+type overloadedModel struct{}
+
+func (overloadedModel) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	return nil, &filesearch.GenerationError{
+		Reason: "model overloaded",
+		Partial: &filesearch.PromptResponse{GroundingSupport: &filesearch.GroundingSupport{
+			GroundingChunks: []*filesearch.GroundingChunk{{File: &filesearch.FileGroundingChunk{
+				FileName: "100-2022-011302.pdf",
+				Text:     "Het minimumuurloon bedraagt 14,05 EUR.",
+			}}},
+		}},
+	}
+}
+
+func ExampleGenerationError() {
+	handler := filesearch.NewHandler(nil, filesearch.WithProvider(overloadedModel{}))
+
+	body := `{"query": "Wat is het minimumloon?", "storeName": "cao-documents"}`
+	rec := httptest.NewRecorder()
+	handler.Query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+
+	var resp filesearch.QueryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(rec.Code, resp.Status)
+	fmt.Println(resp.Answer)
+	fmt.Println(resp.Retrieved[0].Text)
+	// Output:
+	// 200 partial
+	// No answer could be generated (model overloaded). These documents are the most relevant to your question:
+	//
+	// - 100-2022-011302.pdf
+	// Het minimumuurloon bedraagt 14,05 EUR.
+}
+
+func ExampleParseCitationPolicy() {
+	policy, err := filesearch.ParseCitationPolicy("require-citations,min-sources=2,refuse-if-ungrounded,retries=1")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resp := &filesearch.PromptResponse{GroundingSupport: &filesearch.GroundingSupport{
+		GroundingChunks: []*filesearch.GroundingChunk{
+			{File: &filesearch.FileGroundingChunk{FileName: "302-2023-004512.pdf"}},
+			{File: &filesearch.FileGroundingChunk{FileName: "302-2023-004512.pdf"}},
+		},
+	}}
+	fmt.Println(policy.Retries, policy.Check(resp))
+	// Output:
+	// 1 the answer cites 1 of the required 2 documents
+}
+
+func ExampleParseRetryPolicy() {
+	policy, err := filesearch.ParseRetryPolicy("attempts=5,delay=2s,max-delay=10s")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for n := 1; n < policy.MaxAttempts; n++ {
+		fmt.Println(policy.Delay(n))
+	}
+	// Output:
+	// 2s
+	// 4s
+	// 8s
+	// 10s
+}
+
+func ExampleParseFilter() {
+	filter, err := filesearch.ParseFilter(`jc_number=1240000 AND (year<2018 OR status="withdrawn")`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	docs := []*filesearch.Document{
+		{DisplayName: "124-2016-000812.pdf", CustomMetadata: map[string]string{"jc_number": "1240000", "year": "2016"}},
+		{DisplayName: "124-2021-004410.pdf", CustomMetadata: map[string]string{"jc_number": "1240000", "year": "2021"}},
+		{DisplayName: "124-2022-001937.pdf", CustomMetadata: map[string]string{"jc_number": "1240000", "year": "2022", "status": "withdrawn"}},
+		{DisplayName: "302-2015-003311.pdf", CustomMetadata: map[string]string{"jc_number": "3020000", "year": "2015"}},
+	}
+	for _, doc := range docs {
+		fmt.Println(doc.DisplayName, filter.Match(doc.CustomMetadata))
+	}
+	// Output:
+	// 124-2016-000812.pdf true
+	// 124-2021-004410.pdf false
+	// 124-2022-001937.pdf true
+	// 302-2015-003311.pdf false
+}
+
+func ExampleRetryPolicy() {
+	// The first answer is rate limited with 429 Too Many Requests
+	rec, err := vcr.New("testdata/retry.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+		Retry:      &filesearch.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.Prompt(ctx, "Wat is het minimumuurloon voor een werkman van 18 jaar?", store.Name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(resp.Text())
+	// Output:
+	// Het minimumuurloon voor werklieden van 18 jaar en ouder bedraagt 14,05 EUR.
+}
+
+func ExampleDirectorySource() {
+	dir, err := os.MkdirTemp("", "sync")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "302-2023-004512.pdf"), []byte("%PDF-1.7"), 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	source := &filesearch.DirectorySource{Dir: dir}
+	files, err := source.Documents(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range files {
+		fmt.Println(file.Name, file.Hash[:12])
+	}
+	// Output:
+	// 302-2023-004512.pdf 86edbaa24831
+}
+
+func ExampleService_SyncDirectory() {
+	rec, err := vcr.New("testdata/sync.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir, err := os.MkdirTemp("", "sync")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"302-2023-004512.pdf":      "%PDF-1.4\n% cao 302 minimumlonen\n",
+		"2024/302-2024-003311.pdf": "%PDF-1.4\n% cao 302 eindejaarspremie\n",
+		"2024/notes.txt":           "not a PDF",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// The agreement of 2019 is no longer in the directory, so it is deleted from the store
+	report, err := service.SyncDirectory(ctx, dir, "fileSearchStores/cao-documents-x1y2z3", &filesearch.SyncOptions{Delete: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, change := range report.Changes {
+		fmt.Println(change.Action, change.Document)
+	}
+	fmt.Println("unchanged:", report.Unchanged)
+	// Output:
+	// upload 2024/302-2024-003311.pdf
+	// delete 302-2019-013347.pdf
+	// unchanged: 1
+}
+
+// wordyModel answers every question with an answer of 3000 tokens
+type wordyModel struct{}
+
+func (wordyModel) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	return &filesearch.PromptResponse{
+		Parts: []string{"Het minimumuurloon bedraagt 14,05 EUR."},
+		Usage: &filesearch.TokenUsage{PromptTokens: 2000, ResponseTokens: 1000, TotalTokens: 3000},
+	}, nil
+}
+
+// The third question of the session exceeds its budget of 5000 tokens; a new session starts
+// with a budget of its own.
+func ExampleWithSessionBudget() {
+	handler := filesearch.NewHandler(nil,
+		filesearch.WithProvider(wordyModel{}),
+		filesearch.WithSessionBudget(&filesearch.SessionBudget{Tokens: 5000}, kv.NewMemory()))
+
+	ask := func(sessionID string) *filesearch.QueryResponse {
+		body := `{"query": "Wat is het minimumuurloon?", "storeName": "cao-documents", "sessionId": "` + sessionID + `"}`
+		rec := httptest.NewRecorder()
+		handler.Query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+		var resp filesearch.QueryResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			log.Fatal(err)
+		}
+		return &resp
+	}
+
+	var resp *filesearch.QueryResponse
+	for range 3 {
+		resp = ask("chat-1")
+		fmt.Println(resp.Answer)
+	}
+	limit := resp.SessionLimit
+	fmt.Println(limit.Used, limit.Budget)
+	fmt.Println(ask(limit.NewSessionID).Answer)
+	// Output:
+	// Het minimumuurloon bedraagt 14,05 EUR.
+	// Het minimumuurloon bedraagt 14,05 EUR.
+	// This conversation has reached its length limit. Please start a new conversation to ask further questions.
+	// 6000 5000
+	// Het minimumuurloon bedraagt 14,05 EUR.
+}
+
+// filterRecorder answers every question and reports the metadata filter it retrieved with
+type filterRecorder struct{}
+
+func (filterRecorder) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	filter := "none"
+	if opts != nil {
+		filter = opts.MetadataFilter
+	}
+	return &filesearch.PromptResponse{Parts: []string{"filter: " + filter}}, nil
+}
+
+func ExampleWithAccessPolicy() {
+	policy := &filesearch.AccessPolicy{Roles: map[string][]string{
+		"reader": {"public"},
+		"hr":     {"public", "hr-only"},
+		"admin":  {filesearch.AllLabels},
+	}}
+	handler := filesearch.NewHandler(nil,
+		filesearch.WithProvider(filterRecorder{}),
+		filesearch.WithAccessPolicy(policy, func(r *http.Request) string { return r.Header.Get("X-Role") }),
+	)
+
+	for _, role := range []string{"reader", "hr", "admin", "guest"} {
+		body := `{"query": "Wat is de regeling voor ontslag?", "storeName": "cao-documents"}`
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+		req.Header.Set("X-Role", role)
+		rec := httptest.NewRecorder()
+		handler.Query(rec, req)
+
+		var resp filesearch.QueryResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(role, rec.Code, resp.Answer+resp.Error)
+	}
+	// Output:
+	// reader 200 filter: access_label = "public"
+	// hr 200 filter: access_label = "public" OR access_label = "hr-only"
+	// admin 200 filter: none
+	// guest 403 no documents are accessible with this role
+}
+
+// Questions are answered from the documents in their language. Documents are only in Dutch
+// and French, so an English question is answered from the Dutch ones.
+func ExampleWithLanguageRouting() {
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	fake.AddDocument(store.Name, "302-2023-004512-nl.pdf", "Een werkman heeft recht op 20 vakantiedagen.",
+		map[string]string{filesearch.MetadataLanguage: "nl"})
+	fake.AddDocument(store.Name, "302-2023-004512-fr.pdf", "Un ouvrier a droit à 20 jours de congé.",
+		map[string]string{filesearch.MetadataLanguage: "fr"})
+	fake.Answer = func(prompt string, chunks []*filesearch.RetrievedChunk) string {
+		names := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			names[i] = chunk.FileName
+		}
+		return strings.Join(names, ", ")
+	}
+
+	handler := filesearch.NewHandler(fake, filesearch.WithLanguageRouting(&filesearch.LanguageRouting{
+		FilterKey:   filesearch.MetadataLanguage,
+		DefaultLang: "nl",
+	}))
+	for _, query := range []string{
+		"Ai-je droit à 20 jours de congé ?",
+		"How many vakantiedagen does a werkman get?",
+	} {
+		body, _ := json.Marshal(filesearch.QueryRequest{Query: query, StoreName: "cao-documents"})
+		rec := httptest.NewRecorder()
+		handler.Query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(string(body))))
+
+		var resp filesearch.QueryResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(resp.Answer)
+	}
+	// Output:
+	// 302-2023-004512-fr.pdf
+	// 302-2023-004512-nl.pdf
+}
+
+func ExampleBuildFacets() {
+	facets := filesearch.BuildFacets([]*filesearch.Document{
+		{DisplayName: "302-2023-004512.pdf", CustomMetadata: map[string]string{
+			filesearch.MetadataJC: "3020000", filesearch.MetadataYear: "2023", filesearch.MetadataLanguage: "nl",
+		}},
+		// No year in the metadata: taken from the deposit number
+		{DisplayName: "302-2021-015127.pdf", CustomMetadata: map[string]string{
+			filesearch.MetadataJC: "3020000", filesearch.MetadataLanguage: "fr",
+		}},
+		// The metadata takes precedence over the deposit number
+		{DisplayName: "100-2021-011302.pdf", CustomMetadata: map[string]string{
+			filesearch.MetadataJC: "1000000", filesearch.MetadataYear: "2022",
+		}},
+		// Neither: not counted for the year
+		{DisplayName: "reglement.pdf"},
+	})
+
+	fmt.Println("total:", facets.Total)
+	for _, facet := range []struct {
+		name   string
+		counts []*filesearch.FacetCount
+	}{{"jc", facets.JC}, {"year", facets.Year}, {"language", facets.Language}} {
+		fmt.Printf("%s:", facet.name)
+		for _, c := range facet.counts {
+			fmt.Printf(" %s=%d", c.Value, c.Count)
+		}
+		fmt.Println()
+	}
+	// Output:
+	// total: 4
+	// jc: 3020000=2 1000000=1
+	// year: 2021=1 2022=1 2023=1
+	// language: fr=1 nl=1
+}
+
+func ExampleHandler_FacetsHandler() {
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	fake.AddDocument(store.Name, "302-2023-004512.pdf", "Vakantiedagen in de horeca",
+		map[string]string{filesearch.MetadataJC: "3020000", filesearch.MetadataAccessLabel: "public"})
+	fake.AddDocument(store.Name, "302-2024-003311.pdf", "Ontslagregeling voor kaderleden",
+		map[string]string{filesearch.MetadataJC: "3020000", filesearch.MetadataAccessLabel: "hr-only"})
+
+	policy := &filesearch.AccessPolicy{Roles: map[string][]string{
+		"reader": {"public"},
+		"hr":     {"public", "hr-only"},
+	}}
+	handler := filesearch.NewHandler(fake,
+		filesearch.WithAccessPolicy(policy, func(r *http.Request) string { return r.Header.Get("X-Role") }),
+	)
+
+	// Facets only count the documents the role may see
+	for _, role := range []string{"reader", "hr"} {
+		req := httptest.NewRequest(http.MethodGet, "/stores/cao-documents/facets", nil)
+		req.SetPathValue("name", "cao-documents")
+		req.Header.Set("X-Role", role)
+		rec := httptest.NewRecorder()
+		handler.FacetsHandler(rec, req)
+
+		var facets filesearch.Facets
+		if err := json.Unmarshal(rec.Body.Bytes(), &facets); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(role, facets.Total)
+	}
+	// Output:
+	// reader 1
+	// hr 2
+}
+
+func ExampleService_ListDocuments() {
+	// The store holds more documents than fit in one page
+	rec, err := vcr.New("testdata/documents.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	docs, err := service.ListDocuments(ctx, "fileSearchStores/cao-documents-x1y2z3")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, doc := range docs {
+		fmt.Println(doc.DisplayName, doc.CustomMetadata["jc"])
+	}
+	// Output:
+	// 100-2022-011302.pdf 100
+	// 302-2023-004518.pdf 302
+	// 124-2024-001207.pdf 124
+}
+
+// Repeated lookups are served from one listing of the store until a document is deleted.
+func ExampleService_CachedDocuments() {
+	rec, err := vcr.New("testdata/doclist.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	const store = "fileSearchStores/cao-documents-x1y2z3"
+	for range 3 {
+		docs, err := service.CachedDocuments(ctx, store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(len(docs), "documents")
+	}
+	if err := service.DeleteDocument(ctx, store+"/documents/a1"); err != nil {
+		log.Fatal(err)
+	}
+	docs, err := service.CachedDocuments(ctx, store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(docs), "document after deleting one")
+	// Output:
+	// 2 documents
+	// 2 documents
+	// 2 documents
+	// 1 document after deleting one
+}
+
+func ExampleService_UploadFromURL() {
+	rec, err := vcr.New("testdata/upload.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The source site only serves documents to requests that look like a browser, see caoscrape
+	fetch := func(sourceURL string) (io.Reader, error) {
+		return strings.NewReader("%PDF-1.4\n% cao 302 minimumlonen\n"), nil
+	}
+	doc, err := service.UploadFromURL(ctx,
+		"https://public-search.werk.belgie.be/website-download-service/joint-work-convention/302/302-2024-001207.pdf",
+		"fileSearchStores/cao-documents-x1y2z3",
+		&filesearch.UploadOptions{
+			CustomMetadata: []*genai.CustomMetadata{{Key: "jc", StringValue: "302"}},
+			Fetch:          fetch,
+		})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(doc.DisplayName)
+	// Output:
+	// 302-2024-001207.pdf
+}
+
+// A re-published agreement with the same content is not uploaded again under its new name
+func ExampleService_UploadIfNew() {
+	rec, err := vcr.New("testdata/dedupe.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	minimumWages := "%PDF-1.4\n% cao 302 minimumlonen\n"
+	existing := []*filesearch.Document{{
+		Name:           "fileSearchStores/cao-documents-x1y2z3/documents/302-2024-001207-pdf-k2m9",
+		DisplayName:    "302-2024-001207.pdf",
+		CustomMetadata: map[string]string{filesearch.MetadataContentHash: filesearch.ContentHash([]byte(minimumWages))},
+	}}
+	documents := []struct{ name, content string }{
+		{"302-2024-001207-bis.pdf", minimumWages},
+		{"302-2024-003311.pdf", "%PDF-1.4\n% cao 302 eindejaarspremie\n"},
+	}
+	for _, d := range documents {
+		doc, uploaded, err := service.UploadIfNew(ctx, strings.NewReader(d.content), d.name,
+			"fileSearchStores/cao-documents-x1y2z3", &filesearch.UploadOptions{Existing: existing})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if uploaded {
+			existing = append(existing, doc)
+		}
+		fmt.Println(d.name, uploaded, doc.DisplayName)
+	}
+	// Output:
+	// 302-2024-001207-bis.pdf false 302-2024-001207.pdf
+	// 302-2024-003311.pdf true 302-2024-003311.pdf
+}
+
+// Documents are indexed after the upload returns, so a query right after it could miss them
+func ExampleService_WaitForDocumentProcessing() {
+	rec, err := vcr.New("testdata/processing.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	doc, err := service.UploadDocumentWithMetadata(ctx, strings.NewReader("%PDF-1.4\n% cao 302 minimumlonen\n"),
+		"302-2024-001207.pdf", "fileSearchStores/cao-documents-x1y2z3", "",
+		[]*genai.CustomMetadata{{Key: "jc", StringValue: "302"}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(doc.State)
+
+	doc, err = service.WaitForDocumentProcessing(ctx, doc, time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(doc.State, doc.Name)
+	// Output:
+	// STATE_PENDING
+	// STATE_ACTIVE fileSearchStores/cao-documents-x1y2z3/documents/302-2024-001207-pdf-p4q8
+}
+
+func ExampleMetadataValues() {
+	metadata, err := filesearch.MetadataValues(map[string]any{
+		"jc_number":      3020000,
+		"signature_date": time.Date(2023, time.March, 15, 0, 0, 0, 0, time.UTC),
+		"deposit_number": "2023-004512",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, cm := range metadata {
+		if cm.NumericValue != nil {
+			fmt.Printf("%s %.0f\n", cm.Key, *cm.NumericValue)
+		} else {
+			fmt.Println(cm.Key, cm.StringValue)
+		}
+	}
+	// Output:
+	// deposit_number 2023-004512
+	// jc_number 3020000
+	// signature_date 19431
+}
+
+func ExampleWithProvenance() {
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	fake.AddDocument(store.Name, "302-2023-004512.pdf", "Een werkman heeft recht op 20 vakantiedagen.",
+		map[string]string{filesearch.MetadataValidFrom: "19539"})
+
+	handler := filesearch.NewHandler(fake, filesearch.WithProvenance())
+	body := `{"query": "Hoeveel vakantiedagen heeft een werkman?", "storeName": "cao-documents"}`
+	rec := httptest.NewRecorder()
+	handler.Query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+
+	var resp filesearch.QueryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		log.Fatal(err)
+	}
+	p := resp.Provenance
+	for _, chunk := range p.Chunks {
+		fmt.Println(chunk.Index, chunk.Document, chunk.Hash[:12])
+	}
+	for _, doc := range p.Documents {
+		fmt.Println(doc.Name, doc.Version)
+	}
+	// Output:
+	// 0 302-2023-004512.pdf d365e99aa548
+	// fileSearchStores/store-1/documents/document-1 2023-07-01
+}
+
+func ExampleWithURIPolicy() {
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	fake.AddDocument(store.Name, "302-2023-004512.pdf", "Een werkman heeft recht op 20 vakantiedagen.",
+		map[string]string{"source_url": "https://www.werk.belgie.be/cao/302/302-2023-004512.pdf"})
+	fake.AddDocument(store.Name, "302-2024-003311.pdf", "Een bediende heeft recht op 20 vakantiedagen.", nil)
+
+	handler := filesearch.NewHandler(fake, filesearch.WithURIPolicy(filesearch.URIPolicySource))
+	body := `{"query": "Hoeveel vakantiedagen?", "storeName": "cao-documents"}`
+	rec := httptest.NewRecorder()
+	handler.Query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+
+	var resp filesearch.QueryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		log.Fatal(err)
+	}
+	for _, source := range resp.Sources {
+		fmt.Println(source.FileName, source.URI)
+	}
+	// Output:
+	// 302-2023-004512.pdf https://www.werk.belgie.be/cao/302/302-2023-004512.pdf
+	// 302-2024-003311.pdf doc-e64cd7f48a63d779
+}
+
+func ExampleHandler_FeedHandler() {
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	documents := []struct{ name, updated, jc string }{
+		{"302-2023-004512.pdf", "2026-09-01 08:00:00 +0000 UTC", "3020000"},
+		{"200-2024-001122.pdf", "2026-10-14 15:30:00 +0000 UTC", "2000000"},
+	}
+	for _, d := range documents {
+		doc, err := fake.AddDocument(store.Name, d.name, "", map[string]string{filesearch.MetadataJC: d.jc})
+		if err != nil {
+			log.Fatal(err)
+		}
+		doc.CreateTime, doc.UpdateTime = d.updated, d.updated
+	}
+
+	handler := filesearch.NewHandler(fake)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stores/{name}/feed", handler.FeedHandler)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://cao.example.be/stores/cao-documents/feed?format=rss", nil))
+
+	fmt.Println(rec.Header().Get("Content-Type"))
+	fmt.Println(rec.Body.String())
+	// Output:
+	// application/rss+xml; charset=utf-8
+	// <?xml version="1.0" encoding="UTF-8"?>
+	// <rss version="2.0">
+	//   <channel>
+	//     <title>cao-documents</title>
+	//     <link>https://cao.example.be/stores/cao-documents/feed?format=rss</link>
+	//     <description>Documents added to or updated in cao-documents</description>
+	//     <lastBuildDate>Wed, 14 Oct 2026 15:30:00 +0000</lastBuildDate>
+	//     <item>
+	//       <title>200-2024-001122.pdf</title>
+	//       <link>https://cao.example.be/documents/document-2/source?storeName=fileSearchStores%2Fstore-1</link>
+	//       <guid isPermaLink="false">https://cao.example.be/documents/document-2/source?storeName=fileSearchStores%2Fstore-1</guid>
+	//       <pubDate>Wed, 14 Oct 2026 15:30:00 +0000</pubDate>
+	//       <description>JC 2000000, version 2026-10-14</description>
+	//     </item>
+	//     <item>
+	//       <title>302-2023-004512.pdf</title>
+	//       <link>https://cao.example.be/documents/document-1/source?storeName=fileSearchStores%2Fstore-1</link>
+	//       <guid isPermaLink="false">https://cao.example.be/documents/document-1/source?storeName=fileSearchStores%2Fstore-1</guid>
+	//       <pubDate>Tue, 01 Sep 2026 08:00:00 +0000</pubDate>
+	//       <description>JC 3020000, version 2026-09-01</description>
+	//     </item>
+	//   </channel>
+	// </rss>
+}
+
+func ExampleSchemaFor() {
+	type MinimumWage struct {
+		MinimumWage   float64 `json:"minimum_wage" description:"Hourly minimum wage"`
+		Currency      string  `json:"currency"`
+		EffectiveDate string  `json:"effective_date,omitempty" description:"YYYY-MM-DD"`
+	}
+	schema, err := filesearch.SchemaFor[MinimumWage]()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(schema))
+	// Output:
+	// {"properties":{"currency":{"type":"string"},"effective_date":{"description":"YYYY-MM-DD","type":"string"},"minimum_wage":{"description":"Hourly minimum wage","type":"number"}},"required":["minimum_wage","currency"],"type":"object"}
+}
+
+func ExampleDecodeStructured() {
+	rec, err := vcr.New("testdata/structured.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	ctx := context.Background()
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		apiKey = "replay"
+	}
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     apiKey,
+		HTTPClient: rec.Client(),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	type MinimumWage struct {
+		MinimumWage   float64 `json:"minimum_wage" description:"Hourly minimum wage"`
+		Currency      string  `json:"currency"`
+		EffectiveDate string  `json:"effective_date" description:"YYYY-MM-DD"`
+	}
+	schema, err := filesearch.SchemaFor[MinimumWage]()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := service.GetStoreByName(ctx, "cao-documents")
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := service.PromptWithOptions(ctx, "Wat is het minimumuurloon in PC 302?", filesearch.QueryOptions{
+		StoreName:      store.Name,
+		ResponseSchema: schema,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	wage, err := filesearch.DecodeStructured[MinimumWage](resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The answer is still grounded in the documents
+	fmt.Println(wage.MinimumWage, wage.Currency, wage.EffectiveDate, filesearch.Footnotes(resp)[0].FileName)
+	// Output:
+	// 15.12 EUR 2023-07-01 302-2023-004512.pdf
+}
+
+// replayToken authorizes replayed requests, which are never sent
+type replayToken struct{}
+
+func (replayToken) Token(context.Context) (*auth.Token, error) {
+	return &auth.Token{Value: "replay"}, nil
+}
+
+// On Vertex AI the service is authorized with Application Default Credentials in a project
+// and location instead of an API key. Set VCR_MODE=record and GOOGLE_CLOUD_PROJECT, with
+// credentials from "gcloud auth application-default login", to refresh the fixture.
+func ExampleConfig_vertexAI() {
+	rec, err := vcr.New("testdata/vertex.json", vcr.ModeFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rec.Save()
+
+	cfg := &filesearch.Config{
+		Backend:    genai.BackendVertexAI,
+		Project:    os.Getenv("GOOGLE_CLOUD_PROJECT"),
+		Location:   "europe-west4",
+		HTTPClient: rec.Client(),
+	}
+	if vcr.ModeFromEnv() == vcr.ModeReplay {
+		cfg.Project = "cao-rag"
+		cfg.Credentials = auth.NewCredentials(&auth.CredentialsOptions{TokenProvider: replayToken{}})
+	}
+
+	ctx := context.Background()
+	service, err := filesearch.NewService(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	vectors, err := service.Embed(ctx, []string{"Wat is het minimumuurloon voor een werkman van 18 jaar?"}, filesearch.TaskRetrievalQuery)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(vectors), len(vectors[0]))
+	// Output:
+	// 1 3
+}
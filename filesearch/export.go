@@ -0,0 +1,183 @@
+package filesearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// ManifestVersion is the format version of the store manifests written by ExportStore
+const ManifestVersion = 1
+
+// StoreManifest lists the documents of a store with their custom metadata, so the store can
+// be rebuilt from the originals of the documents, in the same project or another one
+type StoreManifest struct {
+	Version     int                 `json:"version"`
+	Store       string              `json:"store"` // Resource name of the exported store
+	DisplayName string              `json:"displayName"`
+	ExportedAt  time.Time           `json:"exportedAt"`
+	Documents   []*ManifestDocument `json:"documents"`
+}
+
+// ManifestDocument is a document listed in a store manifest
+type ManifestDocument struct {
+	Name        string                  `json:"name"` // Resource name in the exported store
+	DisplayName string                  `json:"displayName"`
+	SourceURL   string                  `json:"sourceUrl,omitempty"`
+	MIMEType    string                  `json:"mimeType,omitempty"`
+	SizeBytes   int64                   `json:"sizeBytes,omitempty"`
+	Metadata    []*genai.CustomMetadata `json:"metadata,omitempty"` // Typed, so numeric filters keep working after an import
+}
+
+// ExportStore writes the manifest of a store as JSON: the names, custom metadata and source
+// URLs of its documents. The manifest holds no content; ImportStore uploads the documents again
+// from their originals.
+func (s *Service) ExportStore(ctx context.Context, storeName string, w io.Writer) error {
+	manifest, err := s.exportManifest(ctx, storeName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
+
+// exportManifest lists the documents of a store as a manifest
+func (s *Service) exportManifest(ctx context.Context, storeName string) (*StoreManifest, error) {
+	store, err := s.client.FileSearchStores.Get(ctx, storeName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	docs, err := s.listStoreDocuments(ctx, storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &StoreManifest{
+		Version:     ManifestVersion,
+		Store:       store.Name,
+		DisplayName: store.DisplayName,
+		ExportedAt:  time.Now().UTC(),
+		Documents:   make([]*ManifestDocument, 0, len(docs)),
+	}
+	for _, doc := range docs {
+		md := &ManifestDocument{
+			Name:        doc.Name,
+			DisplayName: doc.DisplayName,
+			MIMEType:    doc.MIMEType,
+			SizeBytes:   doc.SizeBytes,
+			Metadata:    doc.CustomMetadata,
+		}
+		for _, cm := range doc.CustomMetadata {
+			if cm.Key == "source_url" {
+				md.SourceURL = cm.StringValue
+			}
+		}
+		manifest.Documents = append(manifest.Documents, md)
+	}
+	return manifest, nil
+}
+
+// listStoreDocuments lists the documents of a store as returned by the API, with typed metadata
+func (s *Service) listStoreDocuments(ctx context.Context, storeName string) ([]*genai.Document, error) {
+	var docs []*genai.Document
+	page, err := s.client.FileSearchStores.Documents.List(ctx, storeName, nil)
+	for ; err == nil; page, err = page.Next(ctx) {
+		docs = append(docs, page.Items...)
+	}
+	if !errors.Is(err, genai.ErrPageDone) {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	return docs, nil
+}
+
+// ReadManifest reads a store manifest written by ExportStore
+func ReadManifest(r io.Reader) (*StoreManifest, error) {
+	var manifest StoreManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse store manifest: %w", err)
+	}
+	if manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("unsupported store manifest version %d", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// ImportStore uploads the documents of a manifest into a store, with their custom metadata,
+// from their originals, see Config.Originals. Documents the store already holds by display
+// name are counted as unchanged, so an interrupted import can be run again. Failed documents
+// are recorded in the report and do not stop the import.
+func (s *Service) ImportStore(ctx context.Context, manifest *StoreManifest, storeName string) (*SyncReport, error) {
+	if s.originals == nil {
+		return nil, errors.New("importing a store requires Config.Originals")
+	}
+	existing, err := s.ListDocuments(ctx, storeName)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(existing))
+	for _, doc := range existing {
+		present[doc.DisplayName] = true
+	}
+
+	report := &SyncReport{}
+	for _, md := range manifest.Documents {
+		if present[md.DisplayName] {
+			report.Unchanged++
+			continue
+		}
+		change := &SyncChange{Document: md.DisplayName, Action: SyncUpload}
+		report.Changes = append(report.Changes, change)
+		if err := s.importDocument(ctx, md, storeName); err != nil {
+			change.Error = err.Error()
+			continue
+		}
+		present[md.DisplayName] = true
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		log.Printf("Warning: import into %s failed for %d documents", storeName, len(failed))
+	}
+	return report, nil
+}
+
+// importDocument uploads the original of a manifest document to a store
+func (s *Service) importDocument(ctx context.Context, md *ManifestDocument, storeName string) error {
+	metadata := make(map[string]string, len(md.Metadata))
+	for _, cm := range md.Metadata {
+		metadata[cm.Key] = metadataValue(cm)
+	}
+	data, err := s.originals(ctx, &Document{
+		Name:           md.Name,
+		DisplayName:    md.DisplayName,
+		CustomMetadata: metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.upload(ctx, bytes.NewReader(data), storeName, &genai.UploadToFileSearchStoreConfig{
+		DisplayName:    md.DisplayName,
+		MIMEType:       mimeTypeOf(md.DisplayName, md.MIMEType),
+		CustomMetadata: md.Metadata,
+	})
+	return err
+}
+
+// MigrateStore uploads the documents of store src into store dst, both by resource name, see
+// ImportStore, so a store can be rebuilt with the current chunking of File Search. Unlike
+// CloneStore, dst must exist and is kept when documents fail, so the migration can be resumed.
+func (s *Service) MigrateStore(ctx context.Context, src string, dst string) (*SyncReport, error) {
+	manifest, err := s.exportManifest(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return s.ImportStore(ctx, manifest, dst)
+}
@@ -0,0 +1,95 @@
+package filesearch
+
+import (
+	"context"
+	"regexp"
+	"sort"
+)
+
+// Metadata keys used to build facets
+const (
+	MetadataJC       = "jc_number"
+	MetadataYear     = "year"
+	MetadataTheme    = "theme"
+	MetadataLanguage = "lang"
+)
+
+// depositYearPattern extracts the year from deposit-number file names such as "100-2021-015127.pdf"
+var depositYearPattern = regexp.MustCompile(`^\d+-(\d{4})-\d+`)
+
+// FacetCount is the number of documents sharing a metadata value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets holds document counts per metadata value for a store
+type Facets struct {
+	Total    int           `json:"total"`
+	JC       []*FacetCount `json:"jc"`
+	Year     []*FacetCount `json:"year"`
+	Theme    []*FacetCount `json:"theme"`
+	Language []*FacetCount `json:"language"`
+}
+
+// Facets returns document counts per JC, year, theme and language for a store
+func (s *Service) Facets(ctx context.Context, storeName string) (*Facets, error) {
+	docs, err := s.ListDocuments(ctx, storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildFacets(docs), nil
+}
+
+// BuildFacets counts documents per metadata value. Documents without a year in their
+// metadata fall back to the year in their deposit-number file name.
+func BuildFacets(docs []*Document) *Facets {
+	jc := make(map[string]int)
+	year := make(map[string]int)
+	theme := make(map[string]int)
+	lang := make(map[string]int)
+
+	for _, doc := range docs {
+		count(jc, doc.CustomMetadata[MetadataJC])
+		count(theme, doc.CustomMetadata[MetadataTheme])
+		count(lang, doc.CustomMetadata[MetadataLanguage])
+
+		y := doc.CustomMetadata[MetadataYear]
+		if y == "" {
+			if m := depositYearPattern.FindStringSubmatch(doc.DisplayName); m != nil {
+				y = m[1]
+			}
+		}
+		count(year, y)
+	}
+
+	return &Facets{
+		Total:    len(docs),
+		JC:       sortedCounts(jc),
+		Year:     sortedCounts(year),
+		Theme:    sortedCounts(theme),
+		Language: sortedCounts(lang),
+	}
+}
+
+func count(counts map[string]int, value string) {
+	if value != "" {
+		counts[value]++
+	}
+}
+
+// sortedCounts orders facet values by descending count, then by value
+func sortedCounts(counts map[string]int) []*FacetCount {
+	result := make([]*FacetCount, 0, len(counts))
+	for value, n := range counts {
+		result = append(result, &FacetCount{Value: value, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
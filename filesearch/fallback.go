@@ -0,0 +1,330 @@
+package filesearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"rag/kv"
+
+	"google.golang.org/genai"
+)
+
+// sharedAnswerTTL is how long answers shared by replicas are kept
+const sharedAnswerTTL = 7 * 24 * time.Hour
+
+// sharedTimeout bounds the calls to a shared store on the path of a query
+const sharedTimeout = 2 * time.Second
+
+// CircuitBreaker stops sending queries to the backend after repeated outage errors and
+// lets a single trial query through once the cooldown has passed
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker opens the circuit after threshold consecutive outage errors and keeps it
+// open for cooldown. Zero values default to 5 errors and 30 seconds.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// WithCircuitBreaker answers 503 instead of calling the backend while the circuit is open
+func WithCircuitBreaker(breaker *CircuitBreaker) HandlerOption {
+	return func(h *Handler) {
+		h.breaker = breaker
+	}
+}
+
+// Allow reports whether a query may be sent to the backend
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	// Open: after the cooldown, let one trial query through
+	if !b.trial && time.Since(b.openedAt) >= b.cooldown {
+		b.trial = true
+		return true
+	}
+	return false
+}
+
+// Record updates the breaker with the outcome of a backend call. Only outage errors count;
+// errors caused by the request itself leave the breaker unchanged.
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case err == nil:
+		b.failures = 0
+		b.trial = false
+	case errors.Is(err, ErrOverloaded):
+		// Queued out locally; the backend was not called
+		b.trial = false
+	case IsUnavailable(err):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = time.Now()
+			b.trial = false
+		}
+	default:
+		b.trial = false
+	}
+}
+
+// Open reports whether the circuit is open
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures >= b.threshold
+}
+
+// IsUnavailable reports whether an error means the backend is unavailable rather than
+// rejecting the request: server errors, rate limiting, timeouts and network failures.
+// Errors of other providers report it with an Unavailable() bool method.
+func IsUnavailable(err error) bool {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= 500 || apiErr.Code == http.StatusTooManyRequests
+	}
+	var providerErr interface{ Unavailable() bool }
+	if errors.As(err, &providerErr) {
+		return providerErr.Unavailable()
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
+
+// CachedAnswer flags a response served from the answer cache during a backend outage
+type CachedAnswer struct {
+	Query      string    `json:"query"`      // Question the cached answer was given for
+	AnsweredAt time.Time `json:"answeredAt"` // When the answer was given
+}
+
+// cacheEntry is a successful response kept for fallback
+type cacheEntry struct {
+	store      string
+	query      string
+	terms      []string
+	response   QueryResponse
+	answeredAt time.Time
+}
+
+// AnswerCache keeps the most recent answers per store and question so they can be served
+// while the backend is unavailable. Questions match when they share most of their terms.
+type AnswerCache struct {
+	maxEntries int
+	similarity float64
+	shared     kv.Store // Optional
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry // store + normalized query -> entry
+}
+
+// NewAnswerCache keeps up to maxEntries answers (default 1000) and matches questions whose
+// term overlap (Jaccard) is at least similarity (default 0.8, 1 for exact matches only)
+func NewAnswerCache(maxEntries int, similarity float64) *AnswerCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	if similarity <= 0 || similarity > 1 {
+		similarity = 0.8
+	}
+	return &AnswerCache{
+		maxEntries: maxEntries,
+		similarity: similarity,
+		entries:    make(map[string]*cacheEntry),
+	}
+}
+
+// NewSharedAnswerCache is NewAnswerCache for the replicas of a server: answers are also kept
+// in store for a week, where every replica finds them when asked exactly the same question.
+// Similar questions only match the answers given by the replica itself.
+func NewSharedAnswerCache(store kv.Store, maxEntries int, similarity float64) *AnswerCache {
+	c := NewAnswerCache(maxEntries, similarity)
+	c.shared = store
+	return c
+}
+
+// sharedAnswer is an answer kept in the shared store
+type sharedAnswer struct {
+	Query      string        `json:"query"`
+	Response   QueryResponse `json:"response"`
+	AnsweredAt time.Time     `json:"answeredAt"`
+}
+
+// WithCachedFallback records answers and serves the closest one, flagged as cached, when the
+// backend is unavailable instead of failing
+func WithCachedFallback(cache *AnswerCache) HandlerOption {
+	return func(h *Handler) {
+		h.fallback = cache
+	}
+}
+
+// Put records the answer to a question
+func (c *AnswerCache) Put(store, query string, response *QueryResponse) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return
+	}
+	key := store + "\x00" + strings.Join(terms, " ")
+
+	answeredAt := time.Now()
+	c.mu.Lock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evictOldestLocked()
+	}
+	c.entries[key] = &cacheEntry{
+		store:      store,
+		query:      query,
+		terms:      terms,
+		response:   *response,
+		answeredAt: answeredAt,
+	}
+	c.mu.Unlock()
+
+	if c.shared != nil {
+		data, err := json.Marshal(&sharedAnswer{Query: query, Response: *response, AnsweredAt: answeredAt})
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+			err = c.shared.Set(ctx, sharedAnswerKey(key), data, sharedAnswerTTL)
+			cancel()
+		}
+		if err != nil {
+			log.Printf("Warning: failed to share cached answer: %v", err)
+		}
+	}
+}
+
+// Get returns the cached answer closest to a question, flagged with the question and date it
+// was answered for
+func (c *AnswerCache) Get(store, query string) (*QueryResponse, bool) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil, false
+	}
+	key := store + "\x00" + strings.Join(terms, " ")
+	if response, ok := c.getLocal(store, key, terms); ok {
+		return response, true
+	}
+	if c.shared == nil {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+	defer cancel()
+	data, err := c.shared.Get(ctx, sharedAnswerKey(key))
+	if err != nil {
+		if !errors.Is(err, kv.ErrNotFound) {
+			log.Printf("Warning: failed to get shared cached answer: %v", err)
+		}
+		return nil, false
+	}
+	var answer sharedAnswer
+	if err := json.Unmarshal(data, &answer); err != nil {
+		log.Printf("Warning: invalid shared cached answer: %v", err)
+		return nil, false
+	}
+	answer.Response.Cached = &CachedAnswer{Query: answer.Query, AnsweredAt: answer.AnsweredAt}
+	return &answer.Response, true
+}
+
+// getLocal returns the closest answer kept by this replica
+func (c *AnswerCache) getLocal(store, key string, terms []string) (*QueryResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var best *cacheEntry
+	bestScore := 0.0
+	if entry, ok := c.entries[key]; ok {
+		best, bestScore = entry, 1
+	} else {
+		for _, entry := range c.entries {
+			if entry.store != store {
+				continue
+			}
+			score := jaccard(terms, entry.terms)
+			if score > bestScore || (score == bestScore && best != nil && entry.answeredAt.After(best.answeredAt)) {
+				best, bestScore = entry, score
+			}
+		}
+	}
+	if best == nil || bestScore < c.similarity {
+		return nil, false
+	}
+
+	response := best.response
+	response.Cached = &CachedAnswer{Query: best.query, AnsweredAt: best.answeredAt}
+	return &response, true
+}
+
+// sharedAnswerKey returns the key of an answer in the shared store, hashed to bound its length
+func sharedAnswerKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "answers:" + hex.EncodeToString(sum[:])
+}
+
+func (c *AnswerCache) evictOldestLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if oldestKey == "" || entry.answeredAt.Before(oldest) {
+			oldestKey, oldest = key, entry.answeredAt
+		}
+	}
+	delete(c.entries, oldestKey)
+}
+
+// queryTerms returns the sorted unique lowercase words of a question
+func queryTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return slices.Compact(words)
+}
+
+// jaccard returns the overlap of two sorted term lists
+func jaccard(a, b []string) float64 {
+	common := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			common++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
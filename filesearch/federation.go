@@ -0,0 +1,132 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// FederatedStore is a store searched as part of a virtual store, e.g. the sector agreements,
+// the NAR agreements or the legislation
+type FederatedStore struct {
+	Store string `yaml:"store"` // Display name of the store
+	Label string `yaml:"label"` // Corpus the citations from the store are labeled with, defaults to the store
+}
+
+// label returns the corpus label of the store
+func (f *FederatedStore) label() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Store
+}
+
+// virtual reports whether the profile is of a virtual store, searching the stores it federates
+func (p *Profile) virtual() bool {
+	return p != nil && len(p.Federation) > 0
+}
+
+// federation returns the profile of a virtual store by display name, nil when the store is
+// not virtual
+func (s *Service) federation(storeName string) *Profile {
+	if p := s.profiles.Get(storeName); p.virtual() {
+		return p
+	}
+	return nil
+}
+
+// federate replaces a virtual store in the file search tools of a config with the stores it
+// federates, so they are searched together
+func (s *Service) federate(ctx context.Context, storeName string, profile *Profile, config *genai.GenerateContentConfig) error {
+	if !profile.virtual() {
+		return nil
+	}
+
+	stores, err := s.ListStores(ctx)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string, len(stores))
+	for _, store := range stores {
+		names[store.DisplayName] = store.Name
+	}
+	members := make([]string, 0, len(profile.Federation))
+	for _, f := range profile.Federation {
+		name, ok := names[f.Store]
+		if !ok {
+			return fmt.Errorf("federated store %q not found", f.Store)
+		}
+		members = append(members, name)
+	}
+
+	for _, tool := range config.Tools {
+		if tool.FileSearch == nil || !slices.Contains(tool.FileSearch.FileSearchStoreNames, storeName) {
+			continue
+		}
+		federated := slices.Clone(members)
+		for _, name := range tool.FileSearch.FileSearchStoreNames {
+			if name != storeName && !slices.Contains(federated, name) {
+				federated = append(federated, name)
+			}
+		}
+		tool.FileSearch.FileSearchStoreNames = federated
+	}
+	return nil
+}
+
+// corpus returns the label of the federated store a chunk was retrieved from by its URI,
+// e.g. "fileSearchStores/nar-x1y2/documents/cao-43-abc", empty when it is not a member
+func (s *Service) corpus(profile *Profile, uri string) string {
+	if !profile.virtual() {
+		return ""
+	}
+	storeName, _, ok := strings.Cut(uri, "/documents/")
+	if !ok {
+		return ""
+	}
+	displayName, ok := s.displayNames.Load(storeName)
+	if !ok {
+		return ""
+	}
+	for _, f := range profile.Federation {
+		if f.Store == displayName {
+			return f.label()
+		}
+	}
+	return ""
+}
+
+// labelCorpora labels the file chunks of an answer from a virtual store with their corpus
+func (s *Service) labelCorpora(profile *Profile, gs *GroundingSupport) {
+	if !profile.virtual() || gs == nil {
+		return
+	}
+	for _, chunk := range gs.GroundingChunks {
+		if chunk.File == nil {
+			continue
+		}
+		if corpus := s.corpus(profile, chunk.File.URI); corpus != "" {
+			chunk.File.Corpus = corpus
+		}
+	}
+}
+
+// listFederatedDocuments lists the documents of the stores a virtual store federates
+func (s *Service) listFederatedDocuments(ctx context.Context, profile *Profile) ([]*Document, error) {
+	documents := make([]*Document, 0)
+	for _, f := range profile.Federation {
+		store, err := s.GetStoreByName(ctx, f.Store)
+		if err != nil {
+			return nil, fmt.Errorf("federated store: %w", err)
+		}
+		docs, err := s.ListDocuments(ctx, store.Name)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, docs...)
+	}
+	return documents, nil
+}
@@ -0,0 +1,266 @@
+package filesearch
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Number of documents listed in a feed, newest first
+const (
+	DefaultFeedEntries = 50
+	MaxFeedEntries     = 500
+)
+
+// documentTimeLayout is the format of Document.CreateTime and Document.UpdateTime
+const documentTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// feedEntry is a document listed in a feed
+type feedEntry struct {
+	id        string
+	title     string
+	link      string
+	summary   string
+	published time.Time
+	updated   time.Time
+}
+
+// FeedHandler handles GET requests for a feed of the documents of a store, newest or last
+// updated first, so changes to the documents can be followed with a feed reader. Only
+// processed documents the caller may see are listed. Atom by default, RSS 2.0 with format=rss.
+// GET /stores/{name}/feed?format=atom|rss&limit=N
+func (h *Handler) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "atom" && format != "rss" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "format must be atom or rss",
+		})
+		return
+	}
+	limit := DefaultFeedEntries
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > MaxFeedEntries {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "limit must be a number from 1 to " + strconv.Itoa(MaxFeedEntries),
+			})
+			return
+		}
+		limit = n
+	}
+
+	store, err := h.searcher.GetStoreByName(r.Context(), r.PathValue("name"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Store not found: " + err.Error(),
+		})
+		return
+	}
+
+	docs, err := h.searcher.ListDocuments(r.Context(), store.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to list documents: " + err.Error(),
+		})
+		return
+	}
+
+	base := baseURL(r)
+	var entries []*feedEntry
+	for _, doc := range docs {
+		if (doc.State != "" && doc.State != DocumentActive) || !h.accessible(r, doc) {
+			continue
+		}
+		entries = append(entries, newFeedEntry(base, store.Name, doc))
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].updated.After(entries[j].updated) })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	self := base + r.URL.RequestURI()
+	if format == "rss" {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		writeXML(w, rssFeedOf(store, self, entries))
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	writeXML(w, atomFeedOf(store, self, entries))
+}
+
+// newFeedEntry lists a document, linked to its source URL or else to its document source
+func newFeedEntry(base string, storeName string, doc *Document) *feedEntry {
+	id := base + "/documents/" + url.PathEscape(path.Base(doc.Name)) + "/source?storeName=" + url.QueryEscape(storeName)
+	entry := &feedEntry{
+		id:        id,
+		title:     doc.DisplayName,
+		link:      id,
+		published: documentTime(doc.CreateTime),
+		updated:   documentTime(doc.UpdateTime),
+	}
+	if sourceURL := doc.CustomMetadata["source_url"]; sourceURL != "" {
+		entry.link = sourceURL
+	}
+	if entry.updated.IsZero() {
+		entry.updated = entry.published
+	}
+
+	if jc := doc.CustomMetadata[MetadataJC]; jc != "" {
+		entry.summary = "JC " + jc
+	}
+	if version := DocumentVersion(doc); version != "" {
+		if entry.summary != "" {
+			entry.summary += ", "
+		}
+		entry.summary += "version " + version
+	}
+	return entry
+}
+
+// documentTime parses a time of a document, zero when it has none
+func documentTime(value string) time.Time {
+	t, err := time.Parse(documentTimeLayout, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// baseURL returns the scheme and host a request was made to
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// writeXML writes a feed as an XML document
+func writeXML(w http.ResponseWriter, feed any) {
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Link    atomLink     `xml:"link"`
+	Author  atomAuthor   `xml:"author"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Updated   string   `xml:"updated"`
+	Published string   `xml:"published,omitempty"`
+	Link      atomLink `xml:"link"`
+	Summary   string   `xml:"summary,omitempty"`
+}
+
+// atomFeedOf builds an Atom feed, updated when its newest entry was
+func atomFeedOf(store *Store, self string, entries []*feedEntry) *atomFeed {
+	feed := &atomFeed{
+		ID:      self,
+		Title:   store.DisplayName,
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: self, Rel: "self"},
+		Author:  atomAuthor{Name: store.DisplayName},
+	}
+	if len(entries) > 0 {
+		feed.Updated = entries[0].updated.Format(time.RFC3339)
+	}
+	for _, e := range entries {
+		entry := &atomEntry{
+			ID:      e.id,
+			Title:   e.title,
+			Updated: e.updated.Format(time.RFC3339),
+			Link:    atomLink{Href: e.link},
+			Summary: e.summary,
+		}
+		if !e.published.IsZero() {
+			entry.Published = e.published.Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssFeedOf builds an RSS 2.0 feed, dated by the update time of the documents
+func rssFeedOf(store *Store, self string, entries []*feedEntry) *rssFeed {
+	feed := &rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       store.DisplayName,
+			Link:        self,
+			Description: "Documents added to or updated in " + store.DisplayName,
+		},
+	}
+	if len(entries) > 0 {
+		feed.Channel.LastBuildDate = entries[0].updated.Format(time.RFC1123Z)
+	}
+	for _, e := range entries {
+		feed.Channel.Items = append(feed.Channel.Items, &rssItem{
+			Title:       e.title,
+			Link:        e.link,
+			GUID:        rssGUID{Value: e.id},
+			PubDate:     e.updated.Format(time.RFC1123Z),
+			Description: e.summary,
+		})
+	}
+	return feed
+}
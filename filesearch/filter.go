@@ -0,0 +1,245 @@
+package filesearch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr is a parsed metadata filter
+type expr interface {
+	match(metadata map[string]string) bool
+}
+
+type and []expr
+
+func (e and) match(metadata map[string]string) bool {
+	for _, sub := range e {
+		if !sub.match(metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+type or []expr
+
+func (e or) match(metadata map[string]string) bool {
+	for _, sub := range e {
+		if sub.match(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+// comparison compares a metadata key with a value, numerically when both are numbers
+type comparison struct {
+	key, op, value string
+}
+
+func (c *comparison) match(metadata map[string]string) bool {
+	actual, ok := metadata[c.key]
+	if !ok {
+		return false
+	}
+
+	cmp := strings.Compare(actual, c.value)
+	a, errA := strconv.ParseFloat(actual, 64)
+	b, errB := strconv.ParseFloat(c.value, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // >=
+		return cmp >= 0
+	}
+}
+
+// MetadataFilter is a parsed metadata filter, matching documents by their custom metadata
+// outside File Search, see ParseFilter
+type MetadataFilter struct {
+	expr expr
+}
+
+// ParseFilter parses the subset of AIP-160 File Search accepts as metadata filter and this
+// package writes: comparisons of a key with a quoted string or a number, e.g.
+// jc_number=1240000 AND year<2018, combined with AND, OR and parentheses
+func ParseFilter(filter string) (*MetadataFilter, error) {
+	e, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &MetadataFilter{expr: e}, nil
+}
+
+// Match reports whether custom metadata, as in Document.CustomMetadata, satisfies the filter.
+// Values are compared numerically when both are numbers; a missing key never matches.
+func (f *MetadataFilter) Match(metadata map[string]string) bool {
+	return f.expr.match(metadata)
+}
+
+// parseFilter parses a metadata filter, see ParseFilter
+func parseFilter(filter string) (expr, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid metadata filter %q: unexpected %q", filter, p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+// tokenize splits a filter into parentheses, operators, keywords, keys and values. Quoted
+// strings are unquoted.
+func tokenize(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			value, err := strconv.QuotedPrefix(filter[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata filter %q: unterminated string", filter)
+			}
+			unquoted, _ := strconv.Unquote(value)
+			tokens = append(tokens, `"`+unquoted)
+			i += len(value)
+		case strings.ContainsRune("=!<>", rune(c)):
+			op := string(c)
+			if i+1 < len(filter) && filter[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("invalid metadata filter %q: unexpected !", filter)
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		default:
+			j := i
+			for j < len(filter) && (unicode.IsLetter(rune(filter[j])) || unicode.IsDigit(rune(filter[j])) || strings.ContainsRune("_.-", rune(filter[j]))) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("invalid metadata filter %q: unexpected %q", filter, c)
+			}
+			tokens = append(tokens, filter[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *parser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) or() (expr, error) {
+	var terms or
+	for {
+		e, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, e)
+		if p.peek() != "OR" {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *parser) and() (expr, error) {
+	var terms and
+	for {
+		e, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, e)
+		if p.peek() != "AND" {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *parser) term() (expr, error) {
+	if p.peek() == "(" {
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("invalid metadata filter: missing )")
+		}
+		return e, nil
+	}
+
+	key, op, value := p.next(), p.next(), p.next()
+	switch {
+	case key == "" || strings.HasPrefix(key, `"`) || key == "(" || key == ")":
+		return nil, fmt.Errorf("invalid metadata filter: expected a key, got %q", key)
+	case op != "=" && op != "!=" && op != "<" && op != "<=" && op != ">" && op != ">=":
+		return nil, fmt.Errorf("invalid metadata filter: expected an operator after %s, got %q", key, op)
+	case value == "" || value == "(" || value == ")":
+		return nil, fmt.Errorf("invalid metadata filter: expected a value after %s %s", key, op)
+	}
+	return &comparison{key: key, op: op, value: strings.TrimPrefix(value, `"`)}, nil
+}
@@ -0,0 +1,50 @@
+package filesearch_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"rag/filesearch"
+)
+
+func FuzzDecodeQueryRequest(f *testing.F) {
+	f.Add(`{"query": "Wat is het minimumloon?", "storeName": "cao-documents"}`)
+	f.Add(`{"query": "q", "storeName": "s", "history": [{"role": "user", "content": "hallo"}, {"role": "assistant", "content": "dag"}]}`)
+	f.Add(`{"query": "q", "storeName": "s", "history": [{"role": "system", "content": 1}]}`)
+	f.Add(`{"query": "q", "storeName": "s", "history": [null, {}]}`)
+	f.Add(`{"query": "q", "storeName": "s", "summary": {"facts": ["a", "b"]}, "format": "html"}`)
+	f.Add(`{"query": "q", "storeName": "s", "options": {"temperature": 0.2, "topP": 0.9, "maxOutputTokens": 512}}`)
+	f.Add(`{"query": "q", "storeName": "s", "options": {"temperature": -1}}`)
+	f.Add(`{"query": "q", "storeName": "s", "extra": true}`)
+	f.Add(`{"query": "q\u0000", "storeName": "s"} {}`)
+	f.Add("{\"query\": \"\xff\xfe\", \"storeName\": \"s\"}")
+	f.Add(`{"query": "` + strings.Repeat("a", filesearch.MaxQueryLength+1) + `", "storeName": "s"}`)
+	f.Add(`[]`)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		req, err := filesearch.DecodeQueryRequest(strings.NewReader(body))
+		if err != nil {
+			var fe *filesearch.FieldError
+			if !errors.As(err, &fe) {
+				t.Fatalf("error is not a *FieldError: %v", err)
+			}
+			return
+		}
+
+		// Accepted requests must be well-formed and within limits
+		if err := req.Validate(); err != nil {
+			t.Fatalf("decoded request does not validate: %v", err)
+		}
+		if !utf8.ValidString(req.Query) || utf8.RuneCountInString(req.Query) > filesearch.MaxQueryLength {
+			t.Fatalf("accepted query %q", req.Query)
+		}
+		for _, msg := range req.History {
+			if msg.Role != "user" && msg.Role != "assistant" {
+				t.Fatalf("accepted role %q", msg.Role)
+			}
+		}
+		filesearch.NewMemory(req.Summary, req.History, 0).BuildPrompt(req.Query)
+	})
+}
@@ -1,8 +1,22 @@
 package filesearch
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"rag/kv"
+	"rag/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HistoryMessage represents a single message in the conversation history
@@ -13,41 +27,150 @@ type HistoryMessage struct {
 
 // QueryRequest represents the incoming query request
 type QueryRequest struct {
-	Query     string            `json:"query"`
-	StoreName string            `json:"storeName"`
-	History   []HistoryMessage  `json:"history,omitempty"` // Optional conversation history
+	Query     string               `json:"query"`
+	StoreName string               `json:"storeName"`
+	SessionID string               `json:"sessionId,omitempty"` // Optional chat session the query belongs to
+	History   []HistoryMessage     `json:"history,omitempty"`   // Optional conversation history
+	Summary   *ConversationSummary `json:"summary,omitempty"`   // Optional running summary returned by a previous query
+	Format    string               `json:"format,omitempty"`    // Optional "markdown" or "html" to also return a rendered answer
+	Markers   bool                 `json:"markers,omitempty"`   // Optional inline source markers like [1] in the answer, see MarkSources
+	Channel   string               `json:"channel,omitempty"`   // Optional surface asking, e.g. "slack", rendering the answer with its template
+	AsOf      string               `json:"asOf,omitempty"`      // Optional date (YYYY-MM-DD) the answer must hold for
+	Sectors   []int                `json:"sectors,omitempty"`   // Optional JC numbers of the user profile, routing questions that mention no sector
+	Model     string               `json:"model,omitempty"`     // Optional model, must be allowed by the store profile
+	Options   *QueryOptions        `json:"options,omitempty"`   // Optional generation parameters
+	NoCache   bool                 `json:"noCache,omitempty"`   // Optional, answer with the model even when the response cache holds an answer
+	Mode      string               `json:"mode,omitempty"`      // Optional "compare" to compare Left and Right
+	Left      *CompareSide         `json:"left,omitempty"`      // compare: first document or metadata filter
+	Right     *CompareSide         `json:"right,omitempty"`     // compare: second document or metadata filter
 }
 
 // SourceDocument represents a source document with its URI
 type SourceDocument struct {
-	FileName string `json:"fileName"`
-	URI      string `json:"uri"`
+	FileName string   `json:"fileName"`
+	URI      string   `json:"uri"`
+	Page     int      `json:"page,omitempty"`     // Page of the first cited chunk
+	Link     string   `json:"link,omitempty"`     // Source URL opened at that page
+	Articles []string `json:"articles,omitempty"` // Cited articles, e.g. "Art. 14 §2"
+	Version  string   `json:"version,omitempty"`  // Version date (YYYY-MM-DD), set with a legal notice
+	Corpus   string   `json:"corpus,omitempty"`   // Label of the federated store of the document, see FederatedStore
 }
 
 // QueryResponse represents the response to a query
 type QueryResponse struct {
-	Answer           string            `json:"answer"`
-	Sources          []*SourceDocument `json:"sources"`
-	Citations        []*Citation       `json:"citations,omitempty"`
-	GroundingSupport *GroundingSupport `json:"groundingSupport,omitempty"`
-	Error            string            `json:"error,omitempty"`
+	Answer           string               `json:"answer"`
+	Rendered         string               `json:"rendered,omitempty"` // Answer with numbered sources in the requested format
+	Footnotes        []*Footnote          `json:"footnotes,omitempty"`
+	Sources          []*SourceDocument    `json:"sources"`
+	Citations        []*Citation          `json:"citations,omitempty"`
+	GroundingSupport *GroundingSupport    `json:"groundingSupport,omitempty"`
+	LowConfidence    []*GroundingSegment  `json:"lowConfidence,omitempty"` // Spans of the answer weakly supported by the sources, see MinSegmentConfidence
+	ToolCalls        []*ToolCall          `json:"toolCalls,omitempty"`
+	Summary          *ConversationSummary `json:"summary,omitempty"` // Updated summary to send with the next query
+	Usage            *TokenUsage          `json:"usage,omitempty"`
+	Comparison       *Comparison          `json:"comparison,omitempty"` // Set in compare mode
+	SubAnswers       []*PartAnswer        `json:"subAnswers,omitempty"` // Answers to the parts of a compound question, with their own sources
+	Cached           *CachedAnswer        `json:"cached,omitempty"`     // Set when served from the cache during an outage
+	FromCache        bool                 `json:"fromCache,omitempty"`  // Served from the response cache, see Config.ResponseCache
+	Status           string               `json:"status,omitempty"`     // StatusPartial when only the retrieved documents could be returned
+	Retrieved        []*RetrievedChunk    `json:"retrieved,omitempty"`  // Snippets of the retrieved documents in a partial response
+	QueryID          string               `json:"queryId,omitempty"`    // Identifies the answer when rating it, set with a query logger
+	Provenance       *Provenance          `json:"provenance,omitempty"` // What the answer was produced from, see WithProvenance
+	Structured       json.RawMessage      `json:"structured,omitempty"` // The answer as JSON, when options.responseSchema was sent
+	Refused          bool                 `json:"refused,omitempty"`    // The answer broke the citation policy and was refused
+	OutOfScope       *OutOfScope          `json:"outOfScope,omitempty"` // The question is outside the scope of the documents, see WithScope
+	PolicyViolation  string               `json:"policyViolation,omitempty"`
+	PromptTemplate   string               `json:"promptTemplate,omitempty"` // Version of the prompt template the answer was written with, see Config.Prompts
+	SessionLimit     *SessionLimit        `json:"sessionLimit,omitempty"`   // The session used up its token budget, see WithSessionBudget
+	SessionID        string               `json:"sessionId,omitempty"`      // Session the answer was recorded in, see WithConversations
+	Error            string               `json:"error,omitempty"`
+	Field            string               `json:"field,omitempty"` // Request field that failed validation
 }
 
 // Handler provides HTTP handlers for the file search service
 type Handler struct {
-	service *Service
+	searcher      FileSearcher
+	service       *Service // The searcher when it is a Service, for the features only Service offers
+	tools         *ToolRegistry
+	router        Router
+	langs         *LanguageRouting
+	pages         PageLocator
+	articles      ArticleLocator
+	breaker       *CircuitBreaker
+	provider      Provider
+	fallback      *AnswerCache
+	usage         UsageRecorder
+	notice        *LegalNotice
+	queries       QueryLogger
+	access        *AccessPolicy
+	role          RoleResolver
+	sources       SourceFetcher
+	normalizer    QueryNormalizer
+	scope         *Scope
+	budget        *SessionBudget
+	sessions      kv.Store // Token usage of the sessions under budget
+	conversations ConversationStore
+	subject       SubjectResolver // Owner of the chat sessions, see WithConversations
+	channels      map[string]*ChannelTemplate
+	uriPolicy     string // See WithURIPolicy
+	renamer       StoreRenamer
+	decompose     bool
+	provenance    bool
+}
+
+// HandlerOption configures optional Handler behavior
+type HandlerOption func(*Handler)
+
+// WithTools lets the model call the registered tools while answering queries
+func WithTools(registry *ToolRegistry) HandlerOption {
+	return func(h *Handler) {
+		h.tools = registry
+	}
+}
+
+// UsageRecorder receives the tokens used to answer a query, e.g. to account them to a tenant
+type UsageRecorder func(r *http.Request, usage *TokenUsage)
+
+// WithUsageRecorder reports the token usage of every answered query
+func WithUsageRecorder(recorder UsageRecorder) HandlerOption {
+	return func(h *Handler) {
+		h.usage = recorder
+	}
+}
+
+// QueryLogger records answered queries, e.g. for analytics, and returns the ID clients
+// refer to when rating the answer
+type QueryLogger interface {
+	LogQuery(r *http.Request, req *QueryRequest, resp *QueryResponse) (id string)
 }
 
-// NewHandler creates a new HTTP handler
-func NewHandler(service *Service) *Handler {
-	return &Handler{
-		service: service,
+// WithQueryLogger records every answered query
+func WithQueryLogger(logger QueryLogger) HandlerOption {
+	return func(h *Handler) {
+		h.queries = logger
 	}
 }
 
+// NewHandler creates a new HTTP handler. Attachments, compare mode, facets, conversation
+// summaries, tools and question decomposition need searcher to be a *Service; with another
+// FileSearcher their endpoints answer 501 and queries are answered with PromptWithRetrieval.
+func NewHandler(searcher FileSearcher, opts ...HandlerOption) *Handler {
+	h := &Handler{}
+	if service, ok := searcher.(*Service); !ok || service != nil {
+		h.searcher = searcher
+		h.service = service
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
 // Query handles POST requests to query documents
 // POST /query
 // Body: {"query": "your question", "storeName": "store-name"}
+// Compare mode adds {"mode": "compare", "left": {"document": "..."}, "right": {"metadataFilter": "..."}}.
+// Malformed requests are rejected with 400 and the offending field, see DecodeQueryRequest.
 func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
 	// Only accept POST requests
 	if r.Method != http.MethodPost {
@@ -55,45 +178,210 @@ func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse request
-	var req QueryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	askedAt := time.Now()
+
+	// Parse and validate request
+	req, err := DecodeQueryRequest(http.MaxBytesReader(w, r.Body, MaxRequestBytes+1))
+	if err != nil {
+		response := QueryResponse{Error: "Invalid request: " + err.Error()}
+		var fe *FieldError
+		if errors.As(err, &fe) {
+			response.Field = fe.Field
+		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(QueryResponse{
-			Error: "Invalid request body: " + err.Error(),
-		})
+		json.NewEncoder(w).Encode(response)
 		return
 	}
+	req.Query = h.normalize(req.Query)
 
-	// Validate request
-	if req.Query == "" {
-		w.WriteHeader(http.StatusBadRequest)
+	// Continue the conversation from the history kept server-side
+	if err := h.loadConversation(r, req); errors.Is(err, ErrSessionOwner) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Error: err.Error(),
+			Field: "sessionId",
+		})
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(QueryResponse{
-			Error: "Query is required",
+			Error: "Failed to load conversation: " + err.Error(),
 		})
 		return
 	}
 
-	if req.StoreName == "" {
+	route := h.routeRequest(req)
+
+	// Fit the answer to the surface that asked, e.g. Slack
+	var channel *ChannelTemplate
+	if req.Channel != "" {
+		if channel = h.channel(req.Channel); channel == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(QueryResponse{
+				Error: "Invalid request: unknown channel " + strconv.Quote(req.Channel),
+				Field: "channel",
+			})
+			return
+		}
+		route.instruction += channel.instruction()
+	}
+
+	// Only retrieve from the documents the caller may see
+	filter, err := h.accessFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(QueryResponse{Error: err.Error()})
+		return
+	}
+	if filter != "" {
+		route.retrieval = route.retrieval.WithFilter(filter)
+		req.Left, req.Right = req.Left.restrict(filter), req.Right.restrict(filter)
+	}
+
+	if req.Model != "" {
+		if h.provider != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(QueryResponse{
+				Error: "Choosing a model is not supported by the configured provider",
+				Field: "model",
+			})
+			return
+		}
+		r = r.WithContext(WithModel(r.Context(), req.Model))
+	}
+	if req.Options != nil {
+		if h.provider != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(QueryResponse{
+				Error: "Generation options are not supported by the configured provider",
+				Field: "options",
+			})
+			return
+		}
+		r = r.WithContext(WithQueryOptions(r.Context(), req.Options))
+	}
+	if req.NoCache {
+		r = r.WithContext(WithoutResponseCache(r.Context()))
+	}
+
+	// Stop conversations that used up their token budget
+	if used, over := h.sessionUsage(r.Context(), req); over {
+		h.limitSession(w, r, req, used)
+		return
+	}
+
+	// Refuse questions the documents do not answer rather than let the model improvise
+	if req.Mode != ModeCompare {
+		if topic := h.outOfScope(r.Context(), req.Query); topic != nil {
+			h.refuse(w, r, req, topic)
+			return
+		}
+	}
+
+	// Get the store by display name to get the actual store name; other providers
+	// resolve store names themselves
+	storeName := route.storeName
+	if h.provider == nil {
+		store, err := h.searcher.GetStoreByName(r.Context(), route.storeName)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(QueryResponse{
+				Error: "Store not found: " + err.Error(),
+			})
+			return
+		}
+		storeName = store.Name
+		if err := h.resolveExtraStores(r.Context(), route); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(QueryResponse{
+				Error: "Store not found: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.Mode == ModeCompare {
+		if h.provider != nil || h.service == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(QueryResponse{
+				Error: "Compare mode is not supported by the configured provider",
+				Field: "mode",
+			})
+			return
+		}
+		h.compare(w, r, req, storeName)
+		return
+	}
+
+	// Don't call the backend while it is known to be down
+	if h.breaker != nil && !h.breaker.Allow() {
+		h.unavailable(w, r, req, storeName, errors.New("the answer service is temporarily unavailable"))
+		return
+	}
+
+	// Execute query with the actual store name (not display name) and conversation memory
+	mem := NewMemory(req.Summary, req.History, DefaultMaxTurns)
+	var resp *PromptResponse
+	prompt := mem.BuildPrompt(req.Query) + route.instruction
+
+	// Answer the parts of a compound question separately; follow-ups depend on their history
+	var questions []string
+	if h.decompose && h.provider == nil && h.service != nil && len(req.History) == 0 && req.Summary == nil && (req.Options == nil || req.Options.ResponseSchema == nil) {
+		if questions, err = h.service.Decompose(r.Context(), req.Query); err != nil {
+			log.Printf("Warning: answering the question as a whole: %v", err)
+		}
+	}
+	switch {
+	case len(questions) > 1:
+		resp, err = answerParts(questions, func(q string) (*PromptResponse, error) {
+			if h.tools != nil {
+				return h.service.PromptWithTools(r.Context(), q+route.instruction, storeName, h.tools, route.retrieval)
+			}
+			return h.service.PromptWithRetrieval(r.Context(), q+route.instruction, storeName, route.retrieval)
+		})
+	case h.provider != nil:
+		resp, err = h.provider.PromptWithRetrieval(r.Context(), prompt, storeName, route.retrieval)
+	case h.service == nil:
+		resp, err = h.searcher.PromptWithRetrieval(r.Context(), prompt, storeName, route.retrieval)
+	case h.tools != nil:
+		resp, err = h.service.PromptWithTools(r.Context(), prompt, storeName, h.tools, route.retrieval)
+	default:
+		// Send the recent turns as conversation contents, and the summary with the question
+		chat := h.service.NewChat(storeName, &ChatOptions{
+			Retrieval:   route.retrieval,
+			History:     mem.Turns,
+			MaxMessages: mem.MaxTurns,
+		})
+		resp, err = chat.Send(r.Context(), NewMemory(mem.Summary, nil, 0).BuildPrompt(req.Query)+route.instruction)
+	}
+	if h.breaker != nil {
+		h.breaker.Record(err)
+	}
+	if err != nil && IsUnavailable(err) && (h.fallback != nil || errors.Is(err, ErrOverloaded)) {
+		h.unavailable(w, r, req, storeName, err)
+		return
+	}
+	if errors.Is(err, ErrModelNotAllowed) {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(QueryResponse{
-			Error: "StoreName is required",
+			Error: "Invalid request: " + err.Error(),
+			Field: "model",
 		})
 		return
 	}
-
-	// Get the store by display name to get the actual store name
-	store, err := h.service.GetStoreByName(r.Context(), req.StoreName)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(QueryResponse{
-			Error: "Store not found: " + err.Error(),
+			Error: "Invalid request: " + err.Error(),
+			Field: fe.Field,
 		})
 		return
 	}
-
-	// Execute query with the actual store name (not display name) and conversation history
-	resp, err := h.service.PromptWithHistory(r.Context(), req.Query, store.Name, req.History)
+	if err != nil && h.degrade(w, r, storeName, err) {
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(QueryResponse{
@@ -102,34 +390,234 @@ func (h *Handler) Query(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Derive page numbers and articles for file citations from the local extraction
+	h.linkPages(r.Context(), storeName, resp.GroundingSupport)
+	h.linkArticles(resp.GroundingSupport)
+	h.redactURIs(r.Context(), storeName, resp.GroundingSupport)
+	if req.Markers {
+		MarkSources(resp)
+	}
+
+	// Extract unique source file names with URIs, and close the answer with the legal notice
+	sources := collectSources(resp.GroundingSupport)
+	if notice := h.legalNotice(r.Context(), storeName, sources); notice != "" {
+		resp.Parts = append(resp.Parts, notice)
+	}
+
 	// Build response
 	response := QueryResponse{
+		SubAnswers:       partAnswers(resp.SubAnswers),
 		Citations:        resp.Citations,
 		GroundingSupport: resp.GroundingSupport,
+		LowConfidence:    resp.GroundingSupport.LowConfidence(MinSegmentConfidence),
+		ToolCalls:        resp.ToolCalls,
+		Usage:            resp.Usage,
+		Refused:          resp.Refused,
+		PolicyViolation:  resp.PolicyViolation,
+		Structured:       resp.Structured,
+		FromCache:        resp.Cached,
+		PromptTemplate:   resp.PromptTemplate,
+	}
+	if h.usage != nil && resp.Usage != nil {
+		h.usage(r, resp.Usage)
 	}
+	h.chargeSession(r.Context(), req, resp.Usage)
+	annotate(r.Context(), storeName, len(sources), resp.Usage)
 
 	// Combine answer parts
-	for _, part := range resp.Parts {
-		response.Answer += part
-	}
-
-	// Extract unique source file names with URIs
-	seenSources := make(map[string]bool)
-	if resp.GroundingSupport != nil {
-		for _, chunk := range resp.GroundingSupport.GroundingChunks {
-			if chunk.File != nil && !seenSources[chunk.File.FileName] {
-				seenSources[chunk.File.FileName] = true
-				response.Sources = append(response.Sources, &SourceDocument{
-					FileName: chunk.File.FileName,
-					URI:      chunk.File.URI,
-				})
+	response.Answer = resp.Text()
+
+	switch req.Format {
+	case "markdown":
+		response.Rendered = RenderMarkdown(resp)
+		response.Footnotes = Footnotes(resp)
+	case "html":
+		response.Rendered = RenderHTML(resp)
+		response.Footnotes = Footnotes(resp)
+	default:
+		if channel != nil {
+			response.Rendered = channel.Render(resp)
+			if channel.Citations != CitationsNone {
+				response.Footnotes = Footnotes(resp)
 			}
 		}
 	}
 
+	// Fold the new exchange into the running summary, keeping the old one if summarizing fails.
+	// Only conversations carry a summary, history or session; one-shot questions skip the extra model call.
+	response.Summary = mem.Summary
+	conversation := req.Summary != nil || len(req.History) > 0 || req.SessionID != ""
+	if conversation && h.provider == nil && h.service != nil {
+		if summary, err := h.service.Summarize(r.Context(), mem, req.Query, response.Answer); err != nil {
+			log.Printf("Warning: failed to update conversation summary: %v", err)
+		} else {
+			response.Summary = summary
+		}
+	}
+
+	response.Sources = sources
+	if h.provenance {
+		response.Provenance = h.buildProvenance(r.Context(), askedAt, storeName, route, resp)
+	}
+
+	// Keep the answer to serve during outages; follow-up questions depend on their history
+	if h.fallback != nil && len(req.History) == 0 {
+		h.fallback.Put(h.cacheStore(r, req), req.Query, &response)
+	}
+
+	// Log the answered query; answers served from the cache are not logged again
+	if h.queries != nil {
+		response.QueryID = h.queries.LogQuery(r, req, &response)
+	}
+	h.saveTurn(r, req, &response)
+
 	// Return response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, response)
+}
+
+// unavailable serves the cached answer closest to the query when the backend is down, or
+// else the documents retrieved before generation failed, or fails with 503 when there are none
+func (h *Handler) unavailable(w http.ResponseWriter, r *http.Request, req *QueryRequest, storeName string, err error) {
+	if h.fallback != nil {
+		if cached, ok := h.fallback.Get(h.cacheStore(r, req), req.Query); ok {
+			log.Printf("Warning: serving cached answer from %s: %v", cached.Cached.AnsweredAt.Format(time.DateTime), err)
+			cached.Summary = req.Summary
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, cached)
+			return
+		}
+	}
+	if h.degrade(w, r, storeName, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "30")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(QueryResponse{
+		Error: "Service unavailable: " + err.Error(),
+	})
+}
+
+// cacheStore returns the store answers to a request are cached under, kept apart per access
+// filter so cached answers are only served to callers that may see their documents
+func (h *Handler) cacheStore(r *http.Request, req *QueryRequest) string {
+	filter, _ := h.accessFilter(r)
+	if filter == "" {
+		return req.StoreName
+	}
+	return req.StoreName + "\x00" + filter
+}
+
+// compare answers a compare-mode query with a structured comparison of the two sides
+func (h *Handler) compare(w http.ResponseWriter, r *http.Request, req *QueryRequest, storeName string) {
+	comparison, err := h.service.Compare(r.Context(), req.Query, storeName, req.Left, req.Right)
+	if errors.Is(err, ErrModelNotAllowed) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Error: "Invalid request: " + err.Error(),
+			Field: "model",
+		})
+		return
+	}
+	if overloaded(w, err) {
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(QueryResponse{
+			Error: "Failed to compare: " + err.Error(),
+		})
+		return
+	}
+
+	// Derive page numbers and articles for the citations of both sides
+	for _, side := range []*ComparedSide{comparison.Left, comparison.Right} {
+		h.linkPages(r.Context(), storeName, side.GroundingSupport)
+		h.linkArticles(side.GroundingSupport)
+		h.redactURIs(r.Context(), storeName, side.GroundingSupport)
+		side.Sources = collectSources(side.GroundingSupport)
+	}
+	if h.usage != nil && comparison.Usage != nil {
+		h.usage(r, comparison.Usage)
+	}
+	h.chargeSession(r.Context(), req, comparison.Usage)
+
+	response := QueryResponse{
+		Answer:     comparison.Summary,
+		Sources:    append(append([]*SourceDocument{}, comparison.Left.Sources...), comparison.Right.Sources...),
+		Usage:      comparison.Usage,
+		Comparison: comparison,
+	}
+	response.Answer += h.legalNotice(r.Context(), storeName, response.Sources)
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, response)
+}
+
+// collectSources returns the unique source files of the grounding chunks, in order of first use,
+// with the articles cited from each
+func collectSources(gs *GroundingSupport) []*SourceDocument {
+	if gs == nil {
+		return nil
+	}
+
+	var sources []*SourceDocument
+	seen := make(map[string]*SourceDocument, len(gs.GroundingChunks))
+	for _, chunk := range gs.GroundingChunks {
+		if chunk.File == nil {
+			continue
+		}
+		source, ok := seen[chunk.File.FileName]
+		if !ok {
+			if sources == nil {
+				sources = make([]*SourceDocument, 0, len(gs.GroundingChunks))
+			}
+			source = &SourceDocument{
+				FileName: chunk.File.FileName,
+				URI:      chunk.File.URI,
+				Page:     chunk.File.Page,
+				Link:     chunk.File.Link,
+				Corpus:   chunk.File.Corpus,
+			}
+			seen[chunk.File.FileName] = source
+			sources = append(sources, source)
+		}
+		source.Articles = appendArticle(source.Articles, chunk.File.Article)
+	}
+	return sources
+}
+
+// appendArticle adds an article to a list unless it is empty or already listed
+func appendArticle(articles []string, article string) []string {
+	if article == "" || slices.Contains(articles, article) {
+		return articles
+	}
+	return append(articles, article)
+}
+
+// encodeBuffers holds reusable buffers for encoding query responses
+var encodeBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// writeJSON encodes v into a pooled buffer and writes it in one call
+func writeJSON(w io.Writer, v any) error {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		// Don't keep very large buffers around
+		if buf.Cap() <= 1<<20 {
+			buf.Reset()
+			encodeBuffers.Put(buf)
+		}
+	}()
+
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
 }
 
 // ListStoresHandler handles GET requests to list all stores
@@ -140,7 +628,7 @@ func (h *Handler) ListStoresHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stores, err := h.service.ListStores(r.Context())
+	stores, err := h.searcher.ListStores(r.Context())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -153,8 +641,15 @@ func (h *Handler) ListStoresHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stores)
 }
 
-// ListDocumentsHandler handles GET requests to list documents in a store
-// GET /stores/{storeName}/documents
+// DocumentPage is a page of the documents in a store
+type DocumentPage struct {
+	Documents     []*Document `json:"documents"`
+	NextPageToken string      `json:"nextPageToken,omitempty"`
+}
+
+// ListDocumentsHandler handles GET requests to list documents in a store. All documents are
+// returned unless pageSize or pageToken is set, then a DocumentPage is returned.
+// GET /documents?storeName=NAME[&pageSize=N&pageToken=TOKEN]
 func (h *Handler) ListDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -170,7 +665,29 @@ func (h *Handler) ListDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	docs, err := h.service.ListDocuments(r.Context(), storeName)
+	pageToken := r.URL.Query().Get("pageToken")
+	pageSize := 0
+	if v := r.URL.Query().Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "pageSize must be a positive number",
+			})
+			return
+		}
+		pageSize = n
+	}
+	paged := pageSize > 0 || pageToken != ""
+
+	var docs []*Document
+	var next string
+	var err error
+	if paged {
+		docs, next, err = h.searcher.ListDocumentsPage(r.Context(), storeName, pageToken, pageSize)
+	} else {
+		docs, err = h.searcher.ListDocuments(r.Context(), storeName)
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -179,8 +696,58 @@ func (h *Handler) ListDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only list the documents the caller may see
+	visible := docs[:0]
+	for _, doc := range docs {
+		if h.accessible(r, doc) {
+			visible = append(visible, doc)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if paged {
+		json.NewEncoder(w).Encode(&DocumentPage{Documents: visible, NextPageToken: next})
+		return
+	}
+	json.NewEncoder(w).Encode(visible)
+}
+
+// FacetsHandler handles GET requests for document counts per JC, year, theme and language
+// GET /stores/{name}/facets
+func (h *Handler) FacetsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	store, err := h.searcher.GetStoreByName(r.Context(), r.PathValue("name"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Store not found: " + err.Error(),
+		})
+		return
+	}
+
+	docs, err := h.searcher.ListDocuments(r.Context(), store.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to compute facets: " + err.Error(),
+		})
+		return
+	}
+
+	// Only count the documents the caller may see
+	visible := docs[:0:0]
+	for _, doc := range docs {
+		if h.accessible(r, doc) {
+			visible = append(visible, doc)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(docs)
+	json.NewEncoder(w).Encode(BuildFacets(visible))
 }
 
 // DownloadDocumentHandler handles GET requests to download a document from its source URL
@@ -203,7 +770,7 @@ func (h *Handler) DownloadDocumentHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// Get all documents in the store
-	docs, err := h.service.ListDocuments(r.Context(), storeName)
+	docs, err := h.searcher.ListDocuments(r.Context(), storeName)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -215,7 +782,7 @@ func (h *Handler) DownloadDocumentHandler(w http.ResponseWriter, r *http.Request
 	// Find the document
 	var sourceURL string
 	for _, doc := range docs {
-		if doc.Name == documentName || doc.DisplayName == documentName {
+		if (doc.Name == documentName || doc.DisplayName == documentName) && h.accessible(r, doc) {
 			if url, ok := doc.CustomMetadata["source_url"]; ok {
 				sourceURL = url
 				break
@@ -234,3 +801,16 @@ func (h *Handler) DownloadDocumentHandler(w http.ResponseWriter, r *http.Request
 	// Redirect to the source URL
 	http.Redirect(w, r, sourceURL, http.StatusTemporaryRedirect)
 }
+
+// annotate adds the store, number of cited documents and token usage of an answer to the span
+// of the request, see telemetry.Middleware
+func annotate(ctx context.Context, storeName string, documents int, usage *TokenUsage) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(telemetry.StoreKey.String(storeName), telemetry.DocumentsKey.Int(documents))
+	if usage != nil {
+		span.SetAttributes(
+			telemetry.InputTokensKey.Int(usage.PromptTokens),
+			telemetry.OutputTokensKey.Int(usage.ResponseTokens),
+		)
+	}
+}
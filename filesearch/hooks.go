@@ -0,0 +1,29 @@
+package filesearch
+
+import "fmt"
+
+// ResponseHook post-processes an answer before it is returned, e.g. to redact personal data,
+// reformat the text or add links. Returning an error fails the prompt.
+type ResponseHook func(resp *PromptResponse) error
+
+// Use appends hooks to the chain run on every answer, in the order they were added, after the
+// profile disclaimer was appended. Add hooks before the service answers its first prompt.
+func (s *Service) Use(hooks ...ResponseHook) {
+	s.hooks = append(s.hooks, hooks...)
+}
+
+// finish completes an answer with the corpus labels and disclaimer of the store profile and
+// runs the hooks
+func (s *Service) finish(profile *Profile, resp *PromptResponse) (*PromptResponse, error) {
+	s.labelCorpora(profile, resp.GroundingSupport)
+	profile.addDisclaimer(resp)
+	if profile != nil {
+		resp.PromptTemplate = profile.template
+	}
+	for i, hook := range s.hooks {
+		if err := hook(resp); err != nil {
+			return nil, fmt.Errorf("response hook %d: %w", i+1, err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,42 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+)
+
+// PromptRequest is a prompt about to be answered from a store or an attachment
+type PromptRequest struct {
+	StoreName  string            // Empty when answering from an attachment
+	Prompt     string            // The message to answer
+	History    []HistoryMessage  // Earlier turns of the conversation, if any
+	Retrieval  *RetrievalOptions // May be nil
+	Attachment *Attachment       // Set when answering from an attachment, see PromptWithAttachment
+}
+
+// Interceptor runs before a prompt is sent to the model. It may change the request, e.g. to
+// rewrite the prompt or scope retrieval to a tenant with a metadata filter, or answer it
+// itself, e.g. from a cache or with a refusal, by returning a response; the model is then
+// not called and later interceptors do not run. Returning an error fails the prompt.
+type Interceptor func(ctx context.Context, req *PromptRequest) (*PromptResponse, error)
+
+// Intercept appends interceptors to the chain run before every prompt, in the order they were
+// added. Add interceptors before the service answers its first prompt.
+func (s *Service) Intercept(interceptors ...Interceptor) {
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+// intercept runs the interceptors on a request and returns the response of the one that
+// answered it, with the response hooks applied, or nil when the model has to answer
+func (s *Service) intercept(ctx context.Context, req *PromptRequest) (*PromptResponse, error) {
+	for i, interceptor := range s.interceptors {
+		resp, err := interceptor(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("interceptor %d: %w", i+1, err)
+		}
+		if resp != nil {
+			return s.finish(nil, resp)
+		}
+	}
+	return nil, nil
+}
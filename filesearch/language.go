@@ -0,0 +1,64 @@
+package filesearch
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+
+	"rag/langdetect"
+	"rag/pdftext"
+
+	"google.golang.org/genai"
+)
+
+// MinLanguageConfidence is the share of the recognized words of a document that must belong
+// to one language before the document is tagged with it. Bilingual documents, such as
+// agreements published in Dutch and French side by side, stay below it and are not tagged.
+const MinLanguageConfidence = 0.6
+
+// DocumentLanguage detects the language of a document from its text: the text layer of a PDF,
+// or the content of a text file. It returns "" when the language cannot be told, e.g. for
+// scanned or bilingual PDFs and other file types.
+func DocumentLanguage(data []byte, mimeType string) string {
+	var text string
+	switch {
+	case mimeType == "application/pdf":
+		doc, err := pdftext.Extract(data)
+		if err != nil {
+			return ""
+		}
+		text = doc.Text()
+	case strings.HasPrefix(mimeType, "text/"):
+		text = string(data)
+	default:
+		return ""
+	}
+
+	lang, confidence := langdetect.Detect(text)
+	if confidence < MinLanguageConfidence {
+		return ""
+	}
+	return lang
+}
+
+// tagLanguage records the language of an uploaded document as MetadataLanguage, unless the
+// metadata already names one, and warns when it differs from the language of the store profile,
+// e.g. a French agreement uploaded to a Dutch store
+func (s *Service) tagLanguage(ctx context.Context, data []byte, storeName string, config *genai.UploadToFileSearchStoreConfig) {
+	for _, cm := range config.CustomMetadata {
+		if cm.Key == MetadataLanguage {
+			return
+		}
+	}
+	lang := DocumentLanguage(data, config.MIMEType)
+	if lang == "" {
+		return
+	}
+	config.CustomMetadata = append(slices.Clip(config.CustomMetadata), &genai.CustomMetadata{Key: MetadataLanguage, StringValue: lang})
+
+	if profile := s.storeProfile(ctx, storeName); profile != nil && profile.Language != "" && profile.Language != lang {
+		log.Printf("Warning: %s is in %s, but store %s is configured for %s",
+			config.DisplayName, langdetect.Name(lang), storeName, langdetect.Name(profile.Language))
+	}
+}
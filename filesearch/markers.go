@@ -0,0 +1,198 @@
+package filesearch
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MarkSources rewrites the answer of a response with inline markers like [1] after every span
+// the grounding supports, numbered as the sources of Footnotes, so readers see which sentence
+// comes from which document. The segments are moved along with the text. Answers without
+// segments, e.g. from other providers, are left as they are.
+func MarkSources(resp *PromptResponse) {
+	gs := resp.GroundingSupport
+	if gs == nil || len(gs.Segments) == 0 || resp.Markers {
+		return
+	}
+
+	numbers := make(map[string]int)
+	for _, fn := range Footnotes(resp) {
+		numbers[fn.FileName] = fn.Number
+	}
+
+	// Markers by the offset they are inserted at, the end of their segment
+	text := resp.Text()
+	markers := make(map[int][]int)
+	for _, seg := range gs.Segments {
+		if seg.StartIndex < 0 || seg.StartIndex >= seg.EndIndex || seg.EndIndex > len(text) ||
+			(seg.EndIndex < len(text) && !utf8.RuneStart(text[seg.EndIndex])) {
+			continue
+		}
+		for _, i := range seg.ChunkIndices {
+			if n := numbers[gs.GroundingChunks[i].sourceName()]; n > 0 && !slices.Contains(markers[seg.EndIndex], n) {
+				markers[seg.EndIndex] = append(markers[seg.EndIndex], n)
+			}
+		}
+	}
+	if len(markers) == 0 {
+		return
+	}
+	offsets := make([]int, 0, len(markers))
+	for offset := range markers {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+
+	var sb strings.Builder
+	inserted := make([]int, len(offsets)) // Bytes inserted up to and including each offset
+	last := 0
+	for i, offset := range offsets {
+		sb.WriteString(text[last:offset])
+		slices.Sort(markers[offset])
+		for _, n := range markers[offset] {
+			fmt.Fprintf(&sb, "[%d]", n)
+		}
+		inserted[i] = sb.Len() - offset
+		last = offset
+	}
+	sb.WriteString(text[last:])
+
+	// A marker at the start of a segment belongs to the span before it, one at the end to the span
+	shift := func(offset int, atOffset bool) int {
+		i := sort.SearchInts(offsets, offset)
+		if i < len(offsets) && offsets[i] == offset && atOffset {
+			i++
+		}
+		if i == 0 {
+			return offset
+		}
+		return offset + inserted[i-1]
+	}
+	segments := make([]*GroundingSegment, len(gs.Segments))
+	for i, seg := range gs.Segments {
+		segments[i] = &GroundingSegment{
+			StartIndex:       shift(seg.StartIndex, true),
+			EndIndex:         shift(seg.EndIndex, false),
+			Text:             seg.Text,
+			ChunkIndices:     seg.ChunkIndices,
+			ConfidenceScores: seg.ConfidenceScores,
+		}
+	}
+
+	marked := *gs
+	marked.Segments = segments
+	resp.GroundingSupport = &marked
+	resp.Parts = []string{sb.String()}
+	resp.Markers = true
+}
+
+// markerPattern matches the inline markers of MarkSources in escaped HTML
+var markerPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// linkMarkers links the inline markers of an HTML answer to the footnotes of the source list
+func linkMarkers(escaped string, footnotes int) string {
+	return markerPattern.ReplaceAllStringFunc(escaped, func(marker string) string {
+		var n int
+		fmt.Sscanf(marker, "[%d]", &n)
+		if n < 1 || n > footnotes {
+			return marker
+		}
+		return fmt.Sprintf(`<sup class="marker"><a href="#fn-%d">%s</a></sup>`, n, marker)
+	})
+}
+
+// sourceName returns the name a chunk is listed under in Footnotes, empty for no source
+func (c *GroundingChunk) sourceName() string {
+	switch {
+	case c.File != nil:
+		return c.File.FileName
+	case c.Web != nil:
+		return c.Web.Title
+	}
+	return ""
+}
+
+// keepChunks keeps the chunks keep returns true for, and the segments supported by them
+func (gs *GroundingSupport) keepChunks(keep func(*GroundingChunk) bool) {
+	index := make([]int, len(gs.GroundingChunks)) // Old index -> new index, -1 when dropped
+	kept := make([]*GroundingChunk, 0, len(gs.GroundingChunks))
+	for i, chunk := range gs.GroundingChunks {
+		index[i] = -1
+		if keep(chunk) {
+			index[i] = len(kept)
+			kept = append(kept, chunk)
+		}
+	}
+	gs.GroundingChunks = kept
+
+	var segments []*GroundingSegment
+	for _, seg := range gs.Segments {
+		remapped := &GroundingSegment{StartIndex: seg.StartIndex, EndIndex: seg.EndIndex, Text: seg.Text}
+		for j, i := range seg.ChunkIndices {
+			if index[i] >= 0 {
+				remapped.ChunkIndices = append(remapped.ChunkIndices, index[i])
+				if j < len(seg.ConfidenceScores) {
+					remapped.ConfidenceScores = append(remapped.ConfidenceScores, seg.ConfidenceScores[j])
+				}
+			}
+		}
+		if len(remapped.ChunkIndices) > 0 {
+			segments = append(segments, remapped)
+		}
+	}
+	gs.Segments = segments
+}
+
+// appendGrounding appends the chunks and segments of next to gs, for an answer whose text
+// continues that of gs with the text of next at textOffset
+func (gs *GroundingSupport) appendGrounding(next *GroundingSupport, textOffset int) {
+	chunkOffset := len(gs.GroundingChunks)
+	for _, seg := range next.Segments {
+		shifted := &GroundingSegment{
+			StartIndex:       seg.StartIndex + textOffset,
+			EndIndex:         seg.EndIndex + textOffset,
+			Text:             seg.Text,
+			ChunkIndices:     make([]int, len(seg.ChunkIndices)),
+			ConfidenceScores: seg.ConfidenceScores,
+		}
+		for i, chunk := range seg.ChunkIndices {
+			shifted.ChunkIndices[i] = chunk + chunkOffset
+		}
+		gs.Segments = append(gs.Segments, shifted)
+	}
+	gs.GroundingChunks = append(gs.GroundingChunks, next.GroundingChunks...)
+	gs.WebSearchQueries = append(gs.WebSearchQueries, next.WebSearchQueries...)
+}
+
+// MinSegmentConfidence is the confidence below which a span of an answer counts as weakly
+// supported: not one of its chunks supports it with at least this confidence
+const MinSegmentConfidence = 0.5
+
+// Confidence returns the highest confidence of the chunks supporting the span, -1 when the
+// model reported no scores
+func (seg *GroundingSegment) Confidence() float32 {
+	if len(seg.ConfidenceScores) == 0 {
+		return -1
+	}
+	return slices.Max(seg.ConfidenceScores)
+}
+
+// LowConfidence returns the spans of the answer with a confidence below threshold, see
+// MinSegmentConfidence, so they can be flagged to readers. Spans without scores are not
+// returned.
+func (gs *GroundingSupport) LowConfidence(threshold float32) []*GroundingSegment {
+	if gs == nil {
+		return nil
+	}
+	var low []*GroundingSegment
+	for _, seg := range gs.Segments {
+		if c := seg.Confidence(); c >= 0 && c < threshold {
+			low = append(low, seg)
+		}
+	}
+	return low
+}
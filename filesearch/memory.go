@@ -0,0 +1,190 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// DefaultMaxTurns is the number of recent history messages kept verbatim in a Memory
+const DefaultMaxTurns = 6
+
+// maxPriorAnswers bounds the number of prior answer digests kept in a summary
+const maxPriorAnswers = 5
+
+// ConversationSummary is a structured digest of the conversation so far.
+// It is carried between requests so that follow-up questions keep their context
+// even after older turns have been truncated.
+type ConversationSummary struct {
+	Sector         string   `json:"sector,omitempty"`         // e.g. "horeca", "bouw"
+	JointCommittee string   `json:"jointCommittee,omitempty"` // e.g. "PC 302"
+	ContractType   string   `json:"contractType,omitempty"`   // e.g. "arbeider", "bediende", "student"
+	Facts          []string `json:"facts,omitempty"`          // Other facts the user told about their situation
+	PriorAnswers   []string `json:"priorAnswers,omitempty"`   // One-line digests of earlier answers
+}
+
+// IsEmpty reports whether the summary holds no information
+func (cs *ConversationSummary) IsEmpty() bool {
+	return cs == nil || (cs.Sector == "" && cs.JointCommittee == "" && cs.ContractType == "" &&
+		len(cs.Facts) == 0 && len(cs.PriorAnswers) == 0)
+}
+
+// String renders the summary as prompt context
+func (cs *ConversationSummary) String() string {
+	if cs.IsEmpty() {
+		return ""
+	}
+
+	var sb strings.Builder
+	cs.writeTo(&sb)
+	return sb.String()
+}
+
+// writeTo renders the summary into sb without intermediate strings
+func (cs *ConversationSummary) writeTo(sb *strings.Builder) {
+	line := func(label, value string) {
+		sb.WriteString("- ")
+		sb.WriteString(label)
+		sb.WriteString(value)
+		sb.WriteByte('\n')
+	}
+
+	if cs.Sector != "" {
+		line("Sector: ", cs.Sector)
+	}
+	if cs.JointCommittee != "" {
+		line("Paritair comité: ", cs.JointCommittee)
+	}
+	if cs.ContractType != "" {
+		line("Contract type: ", cs.ContractType)
+	}
+	for _, fact := range cs.Facts {
+		line("", fact)
+	}
+	if len(cs.PriorAnswers) > 0 {
+		sb.WriteString("Earlier answers:\n")
+		for _, answer := range cs.PriorAnswers {
+			line("", answer)
+		}
+	}
+}
+
+// Memory combines a running conversation summary with a window of recent turns
+type Memory struct {
+	Summary  *ConversationSummary
+	Turns    []HistoryMessage
+	MaxTurns int
+}
+
+// NewMemory creates a memory from a summary and the full history, keeping only the last maxTurns messages.
+// A maxTurns of zero or less uses DefaultMaxTurns.
+func NewMemory(summary *ConversationSummary, history []HistoryMessage, maxTurns int) *Memory {
+	if maxTurns <= 0 {
+		maxTurns = DefaultMaxTurns
+	}
+	if summary == nil {
+		summary = &ConversationSummary{}
+	}
+	if len(history) > maxTurns {
+		history = history[len(history)-maxTurns:]
+	}
+
+	return &Memory{
+		Summary:  summary,
+		Turns:    history,
+		MaxTurns: maxTurns,
+	}
+}
+
+// BuildPrompt returns the prompt for the current question with the summary and recent turns injected
+func (m *Memory) BuildPrompt(question string) string {
+	if m == nil || (m.Summary.IsEmpty() && len(m.Turns) == 0) {
+		return question
+	}
+
+	// Size the builder once: the turns dominate the prompt length
+	size := len(question) + 256
+	for _, msg := range m.Turns {
+		size += len(msg.Role) + len(msg.Content) + 3
+	}
+
+	var sb strings.Builder
+	sb.Grow(size)
+	if !m.Summary.IsEmpty() {
+		sb.WriteString("What we know about the user so far:\n")
+		m.Summary.writeTo(&sb)
+		sb.WriteString("\n")
+	}
+	if len(m.Turns) > 0 {
+		sb.WriteString("Previous conversation:\n")
+		for _, msg := range m.Turns {
+			sb.WriteString(msg.Role)
+			sb.WriteString(": ")
+			sb.WriteString(msg.Content)
+			sb.WriteByte('\n')
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Current question: ")
+	sb.WriteString(question)
+	return sb.String()
+}
+
+// PromptWithMemory sends a prompt to the model with the conversation memory injected and access to the specified store
+func (s *Service) PromptWithMemory(ctx context.Context, prompt string, storeName string, mem *Memory) (*PromptResponse, error) {
+	return s.Prompt(ctx, mem.BuildPrompt(prompt), storeName)
+}
+
+// summarySchema constrains the summarizer output to a ConversationSummary
+var summarySchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"sector":         {Type: genai.TypeString},
+		"jointCommittee": {Type: genai.TypeString},
+		"contractType":   {Type: genai.TypeString},
+		"facts":          {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+		"priorAnswers":   {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+	},
+}
+
+const summarizeInstruction = `You maintain a structured summary of a conversation between an employee and an assistant about Belgian collective labour agreements (CAO's).
+Update the current summary with the new exchange. Keep the user's sector, joint committee (paritair comité) and contract type when they were mentioned.
+Only record facts the user stated about their own situation. Add a one-line digest of the new answer to priorAnswers.
+Leave fields empty when unknown. Never invent information.`
+
+// Summarize updates the memory summary with a new question and answer and returns the updated summary
+func (s *Service) Summarize(ctx context.Context, mem *Memory, question string, answer string) (*ConversationSummary, error) {
+	current, err := json.Marshal(mem.Summary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal summary: %w", err)
+	}
+
+	input := fmt.Sprintf("Current summary:\n%s\n\nUser: %s\nAssistant: %s", current, question, answer)
+
+	resp, err := s.generate(ctx, s.modelName,
+		genai.Text(input),
+		&genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText(summarizeInstruction, genai.RoleUser),
+			ResponseMIMEType:  "application/json",
+			ResponseSchema:    summarySchema,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	var summary ConversationSummary
+	if err := json.Unmarshal([]byte(resp.Text()), &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode summary: %w", err)
+	}
+
+	if len(summary.PriorAnswers) > maxPriorAnswers {
+		summary.PriorAnswers = summary.PriorAnswers[len(summary.PriorAnswers)-maxPriorAnswers:]
+	}
+
+	mem.Summary = &summary
+	return &summary, nil
+}
@@ -0,0 +1,31 @@
+package filesearch
+
+import (
+	"io"
+	"net/http"
+
+	"rag/metrics"
+)
+
+// countedClient returns a copy of client counting its failed requests in
+// metrics.GeminiErrors. A nil client stands for the default client.
+func countedClient(client *http.Client) *http.Client {
+	counted := &http.Client{}
+	if client != nil {
+		*counted = *client
+	}
+	counted.Transport = &metrics.ErrorTransport{Base: counted.Transport, Counter: metrics.GeminiErrors}
+	return counted
+}
+
+// countingReader counts the bytes read from a reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
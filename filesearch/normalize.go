@@ -0,0 +1,24 @@
+package filesearch
+
+// QueryNormalizer rewrites the questions of users before they are routed and retrieved, e.g.
+// fixing misspelled legal terms, see normalize.Normalizer
+type QueryNormalizer interface {
+	Normalize(query string) string
+}
+
+// WithQueryNormalizer normalizes the queries of Query, DebugRetrieve and RetrieveHandler
+// before routing and retrieval, so documents are found however the question was typed. The
+// model is asked the normalized query, and query logs record it.
+func WithQueryNormalizer(normalizer QueryNormalizer) HandlerOption {
+	return func(h *Handler) {
+		h.normalizer = normalizer
+	}
+}
+
+// normalize returns the normalized query, or the query itself without a normalizer
+func (h *Handler) normalize(query string) string {
+	if h.normalizer == nil {
+		return query
+	}
+	return h.normalizer.Normalize(query)
+}
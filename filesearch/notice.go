@@ -0,0 +1,94 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LegalNotice is appended to every answer the handler serves: the version dates of the
+// cited documents, followed by a disclaimer
+type LegalNotice struct {
+	Disclaimer    string // e.g. "This answer is informative and not legally binding."
+	VersionDates  bool   // List the version date of every cited document
+	VersionsLabel string // Introduces the version dates, defaults to "Document versions"
+}
+
+// WithLegalNotice appends a legal notice to every answer
+func WithLegalNotice(notice *LegalNotice) HandlerOption {
+	return func(h *Handler) {
+		h.notice = notice
+	}
+}
+
+// Text returns the notice for an answer citing the sources, empty when there is nothing to add.
+// Sources without a known version date are left out of the version list.
+func (n *LegalNotice) Text(sources []*SourceDocument) string {
+	if n == nil {
+		return ""
+	}
+
+	var paragraphs []string
+	if n.VersionDates {
+		var versions []string
+		for _, source := range sources {
+			if source.Version != "" {
+				versions = append(versions, fmt.Sprintf("%s (%s)", source.FileName, source.Version))
+			}
+		}
+		if len(versions) > 0 {
+			label := n.VersionsLabel
+			if label == "" {
+				label = "Document versions"
+			}
+			paragraphs = append(paragraphs, label+": "+strings.Join(versions, ", "))
+		}
+	}
+	if disclaimer := strings.TrimSpace(n.Disclaimer); disclaimer != "" {
+		paragraphs = append(paragraphs, disclaimer)
+	}
+
+	if len(paragraphs) == 0 {
+		return ""
+	}
+	return "\n\n---\n" + strings.Join(paragraphs, "\n\n")
+}
+
+// DocumentVersion returns the version date of a document as YYYY-MM-DD: the date it is in
+// force from when its validity is recorded, otherwise the date it was last updated
+func DocumentVersion(doc *Document) string {
+	if from, err := strconv.ParseFloat(doc.CustomMetadata[MetadataValidFrom], 64); err == nil {
+		return time.Unix(int64(from)*86400, 0).UTC().Format(time.DateOnly)
+	}
+	if updated, err := time.Parse(documentTimeLayout, doc.UpdateTime); err == nil && !updated.IsZero() {
+		return updated.UTC().Format(time.DateOnly)
+	}
+	return ""
+}
+
+// legalNotice sets the version dates of the sources and returns the legal notice for an answer citing them
+func (h *Handler) legalNotice(ctx context.Context, storeName string, sources []*SourceDocument) string {
+	if h.notice == nil {
+		return ""
+	}
+
+	// Version dates live in the File Search document metadata; list the store once per response
+	if h.notice.VersionDates && len(sources) > 0 && h.provider == nil {
+		docs, err := h.searcher.ListDocuments(ctx, storeName)
+		if err != nil {
+			log.Printf("Warning: failed to list documents for version dates: %v", err)
+		}
+		versions := make(map[string]string, len(docs))
+		for _, doc := range docs {
+			versions[doc.DisplayName] = DocumentVersion(doc)
+		}
+		for _, source := range sources {
+			source.Version = versions[source.FileName]
+		}
+	}
+
+	return h.notice.Text(sources)
+}
@@ -0,0 +1,109 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// MaxInstructionLength is the number of characters of a per-query system instruction
+const MaxInstructionLength = 4000
+
+// QueryOptions tunes the generation of a single answer. Unset fields keep the defaults of
+// the model and the store profile; a system instruction is added to that of the profile.
+type QueryOptions struct {
+	StoreName         string            `json:"-"`
+	Retrieval         *RetrievalOptions `json:"-"`
+	Temperature       *float32          `json:"temperature,omitempty"`       // 0 to 2
+	TopP              *float32          `json:"topP,omitempty"`              // 0 to 1
+	MaxOutputTokens   int32             `json:"maxOutputTokens,omitempty"`   // Zero keeps the model limit
+	SystemInstruction string            `json:"systemInstruction,omitempty"` // Added to the instruction of the store profile
+	ResponseSchema    json.RawMessage   `json:"responseSchema,omitempty"`    // JSON schema of a structured answer, see SchemaFor
+	Documents         []string          `json:"documents,omitempty"`         // Display names of the only documents to answer from, see MaxScopedDocuments
+}
+
+// Validate checks the generation parameters and returns a *FieldError for the first invalid one
+func (o *QueryOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return &FieldError{Field: "options.temperature", Message: "must be between 0 and 2"}
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return &FieldError{Field: "options.topP", Message: "must be between 0 and 1"}
+	}
+	if o.MaxOutputTokens < 0 {
+		return &FieldError{Field: "options.maxOutputTokens", Message: "must not be negative"}
+	}
+	if o.ResponseSchema != nil {
+		if err := checkResponseSchema("options.responseSchema", o.ResponseSchema); err != nil {
+			return err
+		}
+	}
+	if len(o.Documents) > MaxScopedDocuments {
+		return &FieldError{Field: "options.documents", Message: fmt.Sprintf("exceeds %d documents", MaxScopedDocuments)}
+	}
+	for _, name := range o.Documents {
+		if strings.TrimSpace(name) == "" {
+			return &FieldError{Field: "options.documents", Message: "must not contain empty names"}
+		}
+		if err := checkText("options.documents", name, MaxStoreNameLength); err != nil {
+			return err
+		}
+	}
+	return checkText("options.systemInstruction", o.SystemInstruction, MaxInstructionLength)
+}
+
+// apply sets the generation parameters on a config that already holds the store profile
+func (o *QueryOptions) apply(config *genai.GenerateContentConfig) {
+	if o.Temperature != nil {
+		config.Temperature = o.Temperature
+	}
+	if o.TopP != nil {
+		config.TopP = o.TopP
+	}
+	if o.MaxOutputTokens > 0 {
+		config.MaxOutputTokens = o.MaxOutputTokens
+	}
+	if o.SystemInstruction != "" {
+		addInstruction(config, o.SystemInstruction)
+	}
+	if o.ResponseSchema != nil {
+		applyResponseSchema(config, o.ResponseSchema)
+	}
+}
+
+// addInstruction appends an instruction to the system instruction of a config
+func addInstruction(config *genai.GenerateContentConfig, instruction string) {
+	if config.SystemInstruction != nil {
+		var parts []string
+		for _, part := range config.SystemInstruction.Parts {
+			parts = append(parts, part.Text)
+		}
+		instruction = strings.Join(parts, "") + "\n\n" + instruction
+	}
+	config.SystemInstruction = genai.NewContentFromText(instruction, genai.RoleUser)
+}
+
+type queryOptionsKey struct{}
+
+// WithQueryOptions returns a context whose answers are generated with the given options, so
+// they also apply to answers with tools or history
+func WithQueryOptions(ctx context.Context, opts *QueryOptions) context.Context {
+	return context.WithValue(ctx, queryOptionsKey{}, opts)
+}
+
+// PromptWithOptions answers a prompt from opts.StoreName with the generation parameters of opts
+func (s *Service) PromptWithOptions(ctx context.Context, prompt string, opts QueryOptions) (*PromptResponse, error) {
+	if opts.StoreName == "" {
+		return nil, fmt.Errorf("store name is required")
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return s.PromptWithRetrieval(WithQueryOptions(ctx, &opts), prompt, opts.StoreName, opts.Retrieval)
+}
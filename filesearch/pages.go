@@ -0,0 +1,94 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// PageLocator finds the PDF page on which a retrieved chunk of a document starts,
+// using a local extraction of the document text
+type PageLocator interface {
+	LocatePage(document string, text string) (page int, ok bool)
+}
+
+// WithPageLocator adds page numbers and page deep links to file citations
+func WithPageLocator(locator PageLocator) HandlerOption {
+	return func(h *Handler) {
+		h.pages = locator
+	}
+}
+
+// ArticleLocator finds the article or paragraph a retrieved chunk of a document belongs to,
+// using the article headings of a local extraction of the document text
+type ArticleLocator interface {
+	LocateArticle(document string, text string) (article string, ok bool)
+}
+
+// WithArticleLocator adds article references such as "Art. 14 §2" to file citations
+func WithArticleLocator(locator ArticleLocator) HandlerOption {
+	return func(h *Handler) {
+		h.articles = locator
+	}
+}
+
+// PageLink deep-links a PDF URL to a page, e.g. "https://example.org/doc.pdf#page=4"
+func PageLink(sourceURL string, page int) string {
+	if sourceURL == "" || page <= 0 {
+		return sourceURL
+	}
+	if i := strings.Index(sourceURL, "#"); i >= 0 {
+		sourceURL = sourceURL[:i]
+	}
+	return fmt.Sprintf("%s#page=%d", sourceURL, page)
+}
+
+// linkPages sets the page and deep link of every file chunk the locator can place
+func (h *Handler) linkPages(ctx context.Context, storeName string, grounding *GroundingSupport) {
+	if h.pages == nil || grounding == nil {
+		return
+	}
+
+	var sourceURLs map[string]string
+	for _, chunk := range grounding.GroundingChunks {
+		if chunk.File == nil || chunk.File.Text == "" {
+			continue
+		}
+
+		page, ok := h.pages.LocatePage(chunk.File.FileName, chunk.File.Text)
+		if !ok {
+			continue
+		}
+		chunk.File.Page = page
+
+		// Source URLs live in the File Search document metadata; list the store once per response
+		if sourceURLs == nil && h.provider == nil {
+			sourceURLs = make(map[string]string)
+			docs, err := h.searcher.ListDocuments(ctx, storeName)
+			if err != nil {
+				log.Printf("Warning: failed to list documents for page links: %v", err)
+			}
+			for _, doc := range docs {
+				sourceURLs[doc.DisplayName] = doc.CustomMetadata["source_url"]
+			}
+		}
+		chunk.File.Link = PageLink(sourceURLs[chunk.File.FileName], page)
+	}
+}
+
+// linkArticles sets the article of every file chunk the locator can place
+func (h *Handler) linkArticles(grounding *GroundingSupport) {
+	if h.articles == nil || grounding == nil {
+		return
+	}
+
+	for _, chunk := range grounding.GroundingChunks {
+		if chunk.File == nil || chunk.File.Text == "" {
+			continue
+		}
+		if article, ok := h.articles.LocateArticle(chunk.File.FileName, chunk.File.Text); ok {
+			chunk.File.Article = article
+		}
+	}
+}
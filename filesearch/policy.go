@@ -0,0 +1,136 @@
+package filesearch
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// DefaultRefusal is returned instead of an answer that breaks the citation policy
+const DefaultRefusal = "I cannot answer this question reliably from the documents."
+
+const strictInstruction = `Only state what the retrieved documents say and base every statement on them, citing as many of the relevant documents as support the answer.
+If the documents do not answer the question, say that it cannot be answered from the documents instead of answering from general knowledge.`
+
+// CitationPolicy sets how well an answer must be grounded in the documents. An answer that
+// breaks the policy is generated again with a stricter instruction, up to Retries times, and
+// then refused when RefuseIfUngrounded is set.
+type CitationPolicy struct {
+	RequireCitations   bool   `yaml:"requireCitations"`   // The answer must be grounded in at least one document
+	MinSources         int    `yaml:"minSources"`         // Distinct documents the answer must be grounded in
+	RefuseIfUngrounded bool   `yaml:"refuseIfUngrounded"` // Refuse answers still breaking the policy after the retries
+	Retries            int    `yaml:"retries"`            // Retries with a stricter instruction
+	Refusal            string `yaml:"refusal"`            // Defaults to DefaultRefusal
+}
+
+// ParseCitationPolicy parses a comma-separated policy such as
+// "require-citations,min-sources=2,refuse-if-ungrounded,retries=1"
+func ParseCitationPolicy(s string) (*CitationPolicy, error) {
+	policy := &CitationPolicy{}
+	for _, setting := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		var err error
+		switch name {
+		case "":
+		case "require-citations":
+			policy.RequireCitations = true
+		case "refuse-if-ungrounded":
+			policy.RefuseIfUngrounded = true
+		case "min-sources":
+			policy.MinSources, err = strconv.Atoi(value)
+		case "retries":
+			policy.Retries, err = strconv.Atoi(value)
+		default:
+			return nil, fmt.Errorf("unknown citation policy setting %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid citation policy setting %q: %w", setting, err)
+		}
+	}
+	if policy.MinSources < 0 || policy.Retries < 0 {
+		return nil, fmt.Errorf("citation policy values must not be negative")
+	}
+	return policy, nil
+}
+
+// Check returns why a response breaks the policy, or an empty string when it complies
+func (p *CitationPolicy) Check(resp *PromptResponse) string {
+	if p == nil {
+		return ""
+	}
+
+	sources := make(map[string]bool)
+	if resp.GroundingSupport != nil {
+		for _, chunk := range resp.GroundingSupport.GroundingChunks {
+			if chunk.File != nil {
+				sources[chunk.File.FileName] = true
+			}
+		}
+	}
+
+	switch {
+	case p.RequireCitations && len(sources) == 0:
+		return "the answer cites no documents"
+	case len(sources) < p.MinSources:
+		return fmt.Sprintf("the answer cites %d of the required %d documents", len(sources), p.MinSources)
+	}
+	return ""
+}
+
+// citationPolicy returns the policy of the store profile, or else the service policy
+func (s *Service) citationPolicy(profile *Profile) *CitationPolicy {
+	if profile != nil && profile.CitationPolicy != nil {
+		return profile.CitationPolicy
+	}
+	return s.policy
+}
+
+// withCitationPolicy generates an answer and enforces the citation policy on it: answers
+// breaking it are generated again with a stricter instruction and finally refused or returned
+// with the violation. The tokens of every attempt are counted.
+func (s *Service) withCitationPolicy(profile *Profile, config *genai.GenerateContentConfig, generate func(*genai.GenerateContentConfig) (*PromptResponse, error)) (*PromptResponse, error) {
+	policy := s.citationPolicy(profile)
+	resp, err := generate(config)
+	if err != nil || policy == nil {
+		return resp, err
+	}
+
+	usage := resp.Usage
+	violation := policy.Check(resp)
+	for retry := 0; violation != "" && retry < policy.Retries; retry++ {
+		resp, err = generate(strictConfig(config))
+		if err != nil {
+			return nil, err
+		}
+		usage = usage.Add(resp.Usage)
+		violation = policy.Check(resp)
+	}
+	resp.Usage = usage
+
+	if violation == "" {
+		return resp, nil
+	}
+	log.Printf("Warning: answer breaks the citation policy: %s", violation)
+	resp.PolicyViolation = violation
+	if policy.RefuseIfUngrounded {
+		refusal := policy.Refusal
+		if refusal == "" {
+			refusal = DefaultRefusal
+		}
+		resp.Parts = []string{refusal}
+		resp.Citations = nil
+		resp.Structured = nil
+		resp.Refused = true
+	}
+	return resp, nil
+}
+
+// strictConfig returns a copy of the config with the strict instruction added
+func strictConfig(config *genai.GenerateContentConfig) *genai.GenerateContentConfig {
+	strict := *config
+	addInstruction(&strict, strictInstruction)
+	return &strict
+}
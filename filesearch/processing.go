@@ -0,0 +1,111 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rag/telemetry"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genai"
+)
+
+// Processing states of a document. Uploaded documents are pending until they are chunked and
+// embedded; only active documents are retrieved by queries.
+const (
+	DocumentPending = string(genai.DocumentStatePending)
+	DocumentActive  = string(genai.DocumentStateActive)
+	DocumentFailed  = string(genai.DocumentStateFailed)
+)
+
+// Intervals between checks whether an uploaded document has been processed. Small documents
+// take about a second, large ones minutes, so the interval doubles up to the maximum.
+const (
+	documentPoll    = 250 * time.Millisecond
+	maxDocumentPoll = 5 * time.Second
+)
+
+// GetDocument returns a document by resource name
+func (s *Service) GetDocument(ctx context.Context, documentName string) (*Document, error) {
+	doc, err := s.client.FileSearchStores.Documents.Get(ctx, documentName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return newDocument(doc), nil
+}
+
+// WaitForDocumentProcessing waits until an uploaded document is active, so queries right after
+// the upload retrieve it, and returns the document as listed. It follows the upload operation
+// of a document returned by an upload until the operation names the document, then the state
+// of the document. It fails when processing fails or the document is still pending after
+// timeout; a zero timeout waits as long as ctx allows.
+func (s *Service) WaitForDocumentProcessing(ctx context.Context, doc *Document, timeout time.Duration) (*Document, error) {
+	ctx, span := s.tracer.Start(ctx, "filesearch.wait_for_document", trace.WithAttributes(telemetry.DocumentKey.String(doc.DisplayName)))
+	result, err := s.waitForDocument(ctx, doc, timeout)
+	telemetry.End(span, err)
+	return result, err
+}
+
+// waitForDocument is WaitForDocumentProcessing without the span
+func (s *Service) waitForDocument(ctx context.Context, doc *Document, timeout time.Duration) (*Document, error) {
+	if doc.Name == "" && doc.Operation == "" {
+		return nil, fmt.Errorf("document %s has neither a name nor an upload operation", doc.DisplayName)
+	}
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	name := doc.Name
+	delay := documentPoll
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				if parent.Err() == nil {
+					return nil, fmt.Errorf("document %s still processing after %s: %w", doc.DisplayName, timeout, ctx.Err())
+				}
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = min(2*delay, maxDocumentPoll)
+		}
+
+		// The upload operation names the document once the document exists
+		if name == "" {
+			op, err := s.client.Operations.GetUploadToFileSearchStoreOperation(ctx, &genai.UploadToFileSearchStoreOperation{Name: doc.Operation}, nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get upload operation: %w", err)
+			}
+			if op.Error != nil {
+				return nil, fmt.Errorf("failed to process document %s: %s", doc.DisplayName, operationError(op.Error))
+			}
+			if op.Response == nil || op.Response.DocumentName == "" {
+				continue
+			}
+			name = op.Response.DocumentName
+		}
+
+		current, err := s.GetDocument(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		switch current.State {
+		case DocumentActive:
+			return current, nil
+		case DocumentFailed:
+			return nil, fmt.Errorf("failed to process document %s", doc.DisplayName)
+		}
+	}
+}
+
+// operationError returns the message of the error of a failed operation
+func operationError(status map[string]any) string {
+	if message, ok := status["message"].(string); ok && message != "" {
+		return message
+	}
+	return "unknown error"
+}
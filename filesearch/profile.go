@@ -0,0 +1,220 @@
+package filesearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"rag/langdetect"
+
+	"google.golang.org/genai"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrModelNotAllowed is returned when a request chooses a model its store profile does not allow
+var ErrModelNotAllowed = errors.New("model not allowed for this store")
+
+// Profile configures how questions on a store are answered. It is applied by the service
+// to every answer from the store, whichever interface asked the question.
+type Profile struct {
+	SystemInstruction string            `yaml:"systemInstruction"`
+	Language          string            `yaml:"language"`   // Answer language, e.g. "nl"; empty answers in the language of the question
+	Disclaimer        string            `yaml:"disclaimer"` // Appended to every answer
+	Temperature       *float32          `yaml:"temperature"`
+	Model             string            `yaml:"model"`          // Defaults to the service model
+	AllowedModels     []string          `yaml:"allowedModels"`  // Models a request may choose with WithModel
+	CitationPolicy    *CitationPolicy   `yaml:"citationPolicy"` // Overrides the service citation policy
+	Federation        []*FederatedStore `yaml:"federation"`     // Stores searched instead, making this a virtual store
+
+	template string // Version of the prompt template replacing SystemInstruction, see Config.Prompts
+}
+
+// Profiles maps store display names to their profile
+type Profiles struct {
+	Stores map[string]*Profile `yaml:"stores"`
+}
+
+// LoadProfiles reads store profiles from a YAML file
+func LoadProfiles(path string) (*Profiles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store profiles: %w", err)
+	}
+
+	var profiles Profiles
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse store profiles: %w", err)
+	}
+	for store, p := range profiles.Stores {
+		switch {
+		case p == nil:
+			return nil, fmt.Errorf("empty profile for store %q", store)
+		case p.Language != "" && langdetect.Name(p.Language) == "":
+			return nil, fmt.Errorf("unsupported language %q for store %q", p.Language, store)
+		case p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2):
+			return nil, fmt.Errorf("temperature for store %q must be between 0 and 2", store)
+		case p.CitationPolicy != nil && (p.CitationPolicy.MinSources < 0 || p.CitationPolicy.Retries < 0):
+			return nil, fmt.Errorf("citation policy values for store %q must not be negative", store)
+		}
+		for _, f := range p.Federation {
+			switch {
+			case f == nil || f.Store == "":
+				return nil, fmt.Errorf("federated store without a name in virtual store %q", store)
+			case f.Store == store || profiles.Get(f.Store).virtual():
+				return nil, fmt.Errorf("virtual store %q cannot federate virtual store %q", store, f.Store)
+			}
+		}
+	}
+
+	return &profiles, nil
+}
+
+// Get returns the profile of a store, nil when it has none
+func (p *Profiles) Get(store string) *Profile {
+	if p == nil {
+		return nil
+	}
+	return p.Stores[store]
+}
+
+// instruction returns the system instruction including the answer language
+func (p *Profile) instruction() string {
+	instruction := p.SystemInstruction
+	if name := langdetect.Name(p.Language); name != "" {
+		if instruction != "" {
+			instruction += "\n\n"
+		}
+		instruction += fmt.Sprintf("Always answer in %s, whatever the language of the question.", name)
+	}
+	return instruction
+}
+
+// addDisclaimer appends the disclaimer to an answer as its last part
+func (p *Profile) addDisclaimer(resp *PromptResponse) {
+	if p == nil || p.Disclaimer == "" {
+		return
+	}
+	resp.Parts = append(resp.Parts, "\n\n"+strings.TrimSpace(p.Disclaimer))
+}
+
+type modelKey struct{}
+
+// WithModel returns a context asking for answers from another model. The model has to be
+// allowed by the profile of the store, otherwise answering fails with ErrModelNotAllowed.
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// Profile returns the profile of a store by display name, nil when it has none
+func (s *Service) Profile(store string) *Profile {
+	return s.profiles.Get(store)
+}
+
+// storeProfile returns the profile of a store by resource or display name, looking up the
+// display name of a resource name the service has not seen yet
+func (s *Service) storeProfile(ctx context.Context, storeName string) *Profile {
+	if s.profiles == nil || storeName == "" {
+		return nil
+	}
+	if p := s.profiles.Get(storeName); p != nil {
+		return p
+	}
+	return s.profiles.Get(s.displayName(ctx, storeName))
+}
+
+// displayName returns the display name of a store by resource name, looking it up when the
+// service has not seen it yet; empty when the store cannot be found
+func (s *Service) displayName(ctx context.Context, storeName string) string {
+	displayName, ok := s.displayNames.Load(storeName)
+	if !ok {
+		store, err := s.client.FileSearchStores.Get(ctx, storeName, nil)
+		if err != nil {
+			return ""
+		}
+		displayName = store.DisplayName
+		s.displayNames.Store(storeName, displayName)
+	}
+	return displayName.(string)
+}
+
+// PromptTemplates supplies versioned system instructions per store, e.g. a prompts.Store, so
+// a prompt change can be rolled back without a deployment
+type PromptTemplates interface {
+	// Prompt returns the system instruction in use for a store by display name and its version,
+	// both empty when the store has none
+	Prompt(ctx context.Context, store string) (instruction string, version string, err error)
+}
+
+// withTemplate returns the profile of a store with the system instruction of its active prompt
+// template, if it has one; the rest of the profile still applies
+func (s *Service) withTemplate(ctx context.Context, storeName string, profile *Profile) (*Profile, error) {
+	if s.prompts == nil || storeName == "" {
+		return profile, nil
+	}
+	displayName := storeName
+	if s.profiles.Get(storeName) == nil && s.federation(storeName) == nil {
+		if name := s.displayName(ctx, storeName); name != "" {
+			displayName = name
+		}
+	}
+	instruction, version, err := s.prompts.Prompt(ctx, displayName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt template: %w", err)
+	}
+	if version == "" {
+		return profile, nil
+	}
+
+	templated := &Profile{}
+	if profile != nil {
+		*templated = *profile
+	}
+	templated.SystemInstruction = instruction
+	templated.template = version
+	return templated, nil
+}
+
+// answerConfig returns the model and generation config for answering from a store, with the
+// store profile applied
+func (s *Service) answerConfig(ctx context.Context, storeName string, tools ...*genai.Tool) (string, *genai.GenerateContentConfig, *Profile, error) {
+	config := &genai.GenerateContentConfig{Tools: tools}
+	model := s.modelName
+
+	profile, err := s.withTemplate(ctx, storeName, s.storeProfile(ctx, storeName))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if err := s.federate(ctx, storeName, profile, config); err != nil {
+		return "", nil, nil, err
+	}
+	if profile != nil {
+		if instruction := profile.instruction(); instruction != "" {
+			config.SystemInstruction = genai.NewContentFromText(instruction, genai.RoleUser)
+		}
+		config.Temperature = profile.Temperature
+		if profile.Model != "" {
+			model = profile.Model
+		}
+	}
+
+	if opts, _ := ctx.Value(queryOptionsKey{}).(*QueryOptions); opts != nil {
+		opts.apply(config)
+		if len(opts.Documents) > 0 {
+			if err := s.scopeDocuments(ctx, storeName, opts.Documents, config); err != nil {
+				return "", nil, nil, err
+			}
+		}
+	}
+
+	if requested, _ := ctx.Value(modelKey{}).(string); requested != "" && requested != model {
+		if profile == nil || !slices.Contains(profile.AllowedModels, requested) {
+			return "", nil, nil, fmt.Errorf("%w: %s", ErrModelNotAllowed, requested)
+		}
+		model = requested
+	}
+
+	return model, config, profile, nil
+}
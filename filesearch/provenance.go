@@ -0,0 +1,136 @@
+package filesearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Provenance records what an answer was produced from, so an answer given to an employee can
+// later be reconstructed and audited
+type Provenance struct {
+	Model          string                `json:"model,omitempty"`          // Empty when another provider answered
+	PromptVersion  string                `json:"promptVersion,omitempty"`  // Hash of the instructions the question was asked with
+	PromptTemplate string                `json:"promptTemplate,omitempty"` // Version of the prompt template of the store, see Config.Prompts
+	Store          string                `json:"store"`
+	MetadataFilter string                `json:"metadataFilter,omitempty"`
+	Chunks         []*ChunkProvenance    `json:"chunks"`
+	Documents      []*DocumentProvenance `json:"documents"`
+	AskedAt        time.Time             `json:"askedAt"`
+	AnsweredAt     time.Time             `json:"answeredAt"`
+}
+
+// ChunkProvenance identifies a retrieved chunk an answer was grounded in
+type ChunkProvenance struct {
+	Index    int    `json:"index"` // Position in the grounding chunks of the answer, as cited
+	Document string `json:"document"`
+	Page     int    `json:"page,omitempty"`
+	Hash     string `json:"hash"` // SHA-256 of the chunk text
+}
+
+// DocumentProvenance is the version of a document an answer was grounded in
+type DocumentProvenance struct {
+	Name        string `json:"name,omitempty"` // Resource name, empty when the document is no longer listed
+	DisplayName string `json:"displayName"`
+	UpdateTime  string `json:"updateTime,omitempty"`
+	Version     string `json:"version,omitempty"` // See DocumentVersion
+}
+
+// WithProvenance adds the provenance of every answer to the query response, and so to the
+// query log of WithQueryLogger
+func WithProvenance() HandlerOption {
+	return func(h *Handler) {
+		h.provenance = true
+	}
+}
+
+// promptVersion returns a short hash identifying a set of instructions, empty when there are none
+func promptVersion(instructions ...string) string {
+	h := sha256.New()
+	empty := true
+	for _, instruction := range instructions {
+		if instruction != "" {
+			empty = false
+		}
+		h.Write([]byte(instruction))
+		h.Write([]byte{0})
+	}
+	if empty {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// stamp records the model and system instruction a response was generated with, and its
+// structured answer when a response schema was asked for
+func stamp(resp *PromptResponse, model string, config *genai.GenerateContentConfig) *PromptResponse {
+	resp.Model = model
+	resp.Structured = structuredAnswer(resp, config)
+	if config.SystemInstruction != nil {
+		var instruction string
+		for _, part := range config.SystemInstruction.Parts {
+			instruction += part.Text
+		}
+		resp.PromptVersion = promptVersion(instruction)
+	}
+	return resp
+}
+
+// buildProvenance records the provenance of an answer asked at askedAt
+func (h *Handler) buildProvenance(ctx context.Context, askedAt time.Time, storeName string, rt *route, resp *PromptResponse) *Provenance {
+	p := &Provenance{
+		Model:          resp.Model,
+		PromptVersion:  promptVersion(resp.PromptVersion, rt.instruction),
+		PromptTemplate: resp.PromptTemplate,
+		Store:          storeName,
+		Chunks:         []*ChunkProvenance{},
+		Documents:      []*DocumentProvenance{},
+		AskedAt:        askedAt.UTC(),
+		AnsweredAt:     time.Now().UTC(),
+	}
+	if rt.retrieval != nil {
+		p.MetadataFilter = rt.retrieval.MetadataFilter
+	}
+
+	cited := make(map[string]bool)
+	if resp.GroundingSupport != nil {
+		for i, chunk := range resp.GroundingSupport.GroundingChunks {
+			if chunk.File == nil {
+				continue
+			}
+			sum := sha256.Sum256([]byte(chunk.File.Text))
+			p.Chunks = append(p.Chunks, &ChunkProvenance{
+				Index:    i,
+				Document: chunk.File.FileName,
+				Page:     chunk.File.Page,
+				Hash:     hex.EncodeToString(sum[:]),
+			})
+			if !cited[chunk.File.FileName] {
+				cited[chunk.File.FileName] = true
+				p.Documents = append(p.Documents, &DocumentProvenance{DisplayName: chunk.File.FileName})
+			}
+		}
+	}
+
+	// Versions live in the document metadata; other providers don't list documents
+	if len(p.Documents) > 0 && h.provider == nil && h.searcher != nil {
+		docs, err := h.searcher.ListDocuments(ctx, storeName)
+		if err != nil {
+			log.Printf("Warning: failed to list documents for provenance: %v", err)
+		}
+		byName := make(map[string]*Document, len(docs))
+		for _, doc := range docs {
+			byName[doc.DisplayName] = doc
+		}
+		for _, d := range p.Documents {
+			if doc, ok := byName[d.DisplayName]; ok {
+				d.Name, d.UpdateTime, d.Version = doc.Name, doc.UpdateTime, DocumentVersion(doc)
+			}
+		}
+	}
+	return p
+}
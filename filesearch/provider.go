@@ -0,0 +1,39 @@
+package filesearch
+
+import "context"
+
+// Provider answers prompts grounded in the documents of a store. Service implements it with
+// Gemini File Search; other model backends implement it with their own retrieval.
+type Provider interface {
+	PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *RetrievalOptions) (*PromptResponse, error)
+}
+
+// Embedder embeds texts, e.g. to search a local vector index. taskType is one of the Task
+// constants.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error)
+}
+
+// ModelBackend is everything commands need from a model backend to answer questions: it
+// embeds texts, retrieves the chunks relevant to a query and answers prompts grounded in them.
+// Service implements it with Gemini File Search, the default; see the providers package for
+// the others.
+type ModelBackend interface {
+	Provider
+	ChunkRetriever
+	Embedder
+}
+
+var (
+	_ Provider     = (*Service)(nil)
+	_ ModelBackend = (*Service)(nil)
+)
+
+// WithProvider answers queries with another provider instead of Gemini File Search. The
+// store name is passed to the provider as is, and model-callable tools and conversation
+// summaries are not used.
+func WithProvider(provider Provider) HandlerOption {
+	return func(h *Handler) {
+		h.provider = provider
+	}
+}
@@ -0,0 +1,143 @@
+package filesearch
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rag/kv"
+)
+
+// rateLimiter is a token bucket holding a single token, refilled perMinute times a minute,
+// so requests are spread evenly instead of bursting into the quota. With a shared store, the
+// replicas of a server share the limit instead.
+type rateLimiter struct {
+	interval time.Duration
+	shared   kv.Store // Optional
+	key      string   // Prefix of the per-minute counters in shared
+
+	mu   sync.Mutex
+	next time.Time // When the next token is available
+}
+
+// newRateLimiter returns a limiter for perMinute requests, nil when perMinute is not positive.
+// shared may be nil.
+func newRateLimiter(perMinute int, shared kv.Store, key string) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Minute / time.Duration(perMinute), shared: shared, key: key}
+}
+
+// wait blocks until a token is available or ctx is done. A nil limiter never blocks.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	if l.shared != nil {
+		err := l.waitShared(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Warning: shared rate limit unavailable, limiting this instance only: %v", err)
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, start.Sub(now))
+}
+
+// waitShared takes a turn from the counter of the current minute shared by the replicas: the
+// nth request of a minute starts n-1 intervals into it, and requests beyond the limit wait for
+// a later minute
+func (l *rateLimiter) waitShared(ctx context.Context) error {
+	for {
+		now := time.Now()
+		minute := now.Truncate(time.Minute)
+		n, err := l.shared.Incr(ctx, l.key+":"+strconv.FormatInt(minute.Unix(), 10), 1, 2*time.Minute)
+		if err != nil {
+			return err
+		}
+		start := minute.Add(time.Duration(n-1) * l.interval)
+		if start.Before(minute.Add(time.Minute)) {
+			return sleep(ctx, start.Sub(now))
+		}
+		if err := sleep(ctx, minute.Add(time.Minute).Sub(now)); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for delay or until ctx is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limitedTransport paces the API requests of a service. Every request waits for the request
+// limiter; a request starting an upload also waits for the upload limiter, while the requests
+// sending the content of a started upload are not limited.
+type limitedTransport struct {
+	base     http.RoundTripper
+	requests *rateLimiter
+	uploads  *rateLimiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.URL.Path, "/upload/") {
+		if req.Header.Get("X-Goog-Upload-Command") != "start" {
+			return t.base.RoundTrip(req)
+		}
+		if err := t.uploads.wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.requests.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// rateLimitedClient returns a copy of client whose requests respect the limits, or client
+// itself when there are none. A nil client stands for the default client. The limits are
+// shared with the other replicas through shared when it is not nil.
+func rateLimitedClient(client *http.Client, requestsPerMinute, uploadsPerMinute int, shared kv.Store) *http.Client {
+	if requestsPerMinute <= 0 && uploadsPerMinute <= 0 {
+		return client
+	}
+
+	limited := &http.Client{}
+	if client != nil {
+		*limited = *client
+	}
+	base := limited.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	limited.Transport = &limitedTransport{
+		base:     base,
+		requests: newRateLimiter(requestsPerMinute, shared, "ratelimit:requests"),
+		uploads:  newRateLimiter(uploadsPerMinute, shared, "ratelimit:uploads"),
+	}
+	return limited
+}
@@ -0,0 +1,85 @@
+package filesearch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// URI policies of WithURIPolicy
+const (
+	URIPolicySource = "source" // Source URL of the document, or its document ID when it has none
+	URIPolicyID     = "id"     // Stable document ID, see DocumentID
+)
+
+// WithURIPolicy rewrites the URIs of the file chunks and sources of query and retrieve
+// responses, which File Search reports as resource names exposing the project's stores and
+// document IDs: URIPolicySource to the public source URL of the document, URIPolicyID to its
+// stable document ID. Without it the URIs are returned as reported. /debug/retrieve is left
+// as it is, for admins.
+func WithURIPolicy(policy string) HandlerOption {
+	return func(h *Handler) {
+		h.uriPolicy = policy
+	}
+}
+
+// CheckURIPolicy returns an error for an unknown URI policy
+func CheckURIPolicy(policy string) error {
+	switch policy {
+	case URIPolicySource, URIPolicyID:
+		return nil
+	}
+	return fmt.Errorf("unknown URI policy %q, expected %s or %s", policy, URIPolicySource, URIPolicyID)
+}
+
+// DocumentID returns the stable ID of a document by display name. Unlike its resource name it
+// survives uploading the document again and moving it to another store, and
+// /documents/{id}/source serves the document by it.
+func DocumentID(displayName string) string {
+	sum := sha256.Sum256([]byte(displayName))
+	return "doc-" + hex.EncodeToString(sum[:8])
+}
+
+// uriRewriter returns the function rewriting the URI of a document by display name under the
+// URI policy, nil without one
+func (h *Handler) uriRewriter(ctx context.Context, storeName string) func(fileName string) string {
+	if h.uriPolicy == "" {
+		return nil
+	}
+
+	var sourceURLs map[string]string
+	return func(fileName string) string {
+		if h.uriPolicy == URIPolicySource && h.provider == nil {
+			// Source URLs live in the document metadata; list the store once per response
+			if sourceURLs == nil {
+				sourceURLs = make(map[string]string)
+				docs, err := h.searcher.ListDocuments(ctx, storeName)
+				if err != nil {
+					log.Printf("Warning: failed to list documents for source URLs: %v", err)
+				}
+				for _, doc := range docs {
+					sourceURLs[doc.DisplayName] = doc.CustomMetadata["source_url"]
+				}
+			}
+			if url := sourceURLs[fileName]; url != "" {
+				return url
+			}
+		}
+		return DocumentID(fileName)
+	}
+}
+
+// redactURIs rewrites the URIs of the file chunks of an answer under the URI policy
+func (h *Handler) redactURIs(ctx context.Context, storeName string, grounding *GroundingSupport) {
+	rewrite := h.uriRewriter(ctx, storeName)
+	if rewrite == nil || grounding == nil {
+		return
+	}
+	for _, chunk := range grounding.GroundingChunks {
+		if chunk.File != nil {
+			chunk.File.URI = rewrite(chunk.File.FileName)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package filesearch
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Footnote is a numbered source of an answer
+type Footnote struct {
+	Number   int      `json:"number"`
+	FileName string   `json:"fileName"`
+	Link     string   `json:"link,omitempty"` // Page deep link or source URI
+	Page     int      `json:"page,omitempty"`
+	Articles []string `json:"articles,omitempty"` // Cited articles, e.g. "Art. 14 §2"
+	Support  float64  `json:"support"`            // Share of the retrieved chunks that came from this source, 0-1
+	Corpus   string   `json:"corpus,omitempty"`   // Label of the federated store of the source
+}
+
+// Text returns the answer text of the response
+func (r *PromptResponse) Text() string {
+	if len(r.Parts) == 1 {
+		return r.Parts[0]
+	}
+	return strings.Join(r.Parts, "")
+}
+
+// Footnotes returns the deduplicated sources of a response, numbered in order of first appearance
+func Footnotes(resp *PromptResponse) []*Footnote {
+	if resp.GroundingSupport == nil {
+		return nil
+	}
+
+	var footnotes []*Footnote
+	byName := make(map[string]*Footnote)
+	chunks := make(map[string]int)
+	total := 0
+
+	for _, chunk := range resp.GroundingSupport.GroundingChunks {
+		var name, link, article, corpus string
+		var page int
+		switch {
+		case chunk.File != nil:
+			name, link, page, article, corpus = chunk.File.FileName, chunk.File.Link, chunk.File.Page, chunk.File.Article, chunk.File.Corpus
+			if link == "" {
+				link = chunk.File.URI
+			}
+		case chunk.Web != nil:
+			name, link = chunk.Web.Title, chunk.Web.URI
+		default:
+			continue
+		}
+
+		total++
+		chunks[name]++
+		fn, ok := byName[name]
+		if !ok {
+			fn = &Footnote{Number: len(footnotes) + 1, FileName: name, Link: link, Page: page, Corpus: corpus}
+			byName[name] = fn
+			footnotes = append(footnotes, fn)
+		}
+		fn.Articles = appendArticle(fn.Articles, article)
+	}
+
+	for _, fn := range footnotes {
+		fn.Support = float64(chunks[fn.FileName]) / float64(total)
+	}
+	return footnotes
+}
+
+// RenderMarkdown renders the answer followed by a numbered source list
+func RenderMarkdown(resp *PromptResponse) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(resp.Text()))
+
+	footnotes := Footnotes(resp)
+	if len(footnotes) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString("\n\n**Sources**\n\n")
+	for _, fn := range footnotes {
+		label := fn.FileName
+		if fn.Page > 0 {
+			label = fmt.Sprintf("%s, p. %d", label, fn.Page)
+		}
+		if fn.Link != "" {
+			label = fmt.Sprintf("[%s](%s)", label, fn.Link)
+		}
+		if len(fn.Articles) > 0 {
+			label += ", " + strings.Join(fn.Articles, ", ")
+		}
+		if fn.Corpus != "" {
+			label = fn.Corpus + ": " + label
+		}
+		fmt.Fprintf(&sb, "[%d] %s (support %.0f%%)\n", fn.Number, label, fn.Support*100)
+	}
+
+	return sb.String()
+}
+
+// RenderHTML renders the answer as escaped paragraphs followed by a numbered source list. The
+// inline markers of MarkSources link to their source.
+func RenderHTML(resp *PromptResponse) string {
+	footnotes := Footnotes(resp)
+
+	var sb strings.Builder
+	for _, paragraph := range strings.Split(strings.TrimSpace(resp.Text()), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			escaped := strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>")
+			if resp.Markers {
+				escaped = linkMarkers(escaped, len(footnotes))
+			}
+			sb.WriteString("<p>")
+			sb.WriteString(escaped)
+			sb.WriteString("</p>\n")
+		}
+	}
+
+	if len(footnotes) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString(`<ol class="footnotes">` + "\n")
+	for _, fn := range footnotes {
+		label := html.EscapeString(fn.FileName)
+		if fn.Page > 0 {
+			label = fmt.Sprintf("%s, p. %d", label, fn.Page)
+		}
+		if fn.Link != "" {
+			label = fmt.Sprintf(`<a href="%s" target="_blank" rel="noopener noreferrer">%s</a>`, html.EscapeString(fn.Link), label)
+		}
+		if len(fn.Articles) > 0 {
+			label += ", " + html.EscapeString(strings.Join(fn.Articles, ", "))
+		}
+		if fn.Corpus != "" {
+			label = `<span class="corpus">` + html.EscapeString(fn.Corpus) + "</span> " + label
+		}
+		fmt.Fprintf(&sb, `<li id="fn-%d">%s <span class="support">%.0f%%</span></li>`+"\n", fn.Number, label, fn.Support*100)
+	}
+	sb.WriteString("</ol>\n")
+
+	return sb.String()
+}
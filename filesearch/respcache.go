@@ -0,0 +1,235 @@
+package filesearch
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"rag/kv"
+)
+
+// Defaults of a MemoryResponseCache
+const (
+	DefaultResponseCacheSize = 1000
+	DefaultResponseCacheTTL  = time.Hour
+)
+
+// ResponseCache keeps answers to prompts, so a prompt asked again with the same store and
+// options is answered without calling the model, see Config.ResponseCache. Implementations
+// are safe for concurrent use and treat failures as misses.
+type ResponseCache interface {
+	// Get returns the response cached under key
+	Get(ctx context.Context, key string) (*PromptResponse, bool)
+	// Put caches a response under key
+	Put(ctx context.Context, key string, resp *PromptResponse)
+}
+
+// MemoryResponseCache keeps the most recently used responses in process
+type MemoryResponseCache struct {
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List               // Most recently used first
+	entries map[string]*list.Element // key -> element holding a *memoryResponse
+}
+
+type memoryResponse struct {
+	key     string
+	resp    PromptResponse
+	expires time.Time
+}
+
+var _ ResponseCache = (*MemoryResponseCache)(nil)
+
+// NewMemoryResponseCache keeps up to maxEntries responses (default DefaultResponseCacheSize)
+// for ttl (default DefaultResponseCacheTTL), evicting the least recently used first
+func NewMemoryResponseCache(maxEntries int, ttl time.Duration) *MemoryResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultResponseCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+	return &MemoryResponseCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements ResponseCache
+func (c *MemoryResponseCache) Get(ctx context.Context, key string) (*PromptResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryResponse)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	resp := entry.resp
+	resp.Parts = slices.Clone(resp.Parts) // Appending to the answer must not change the cached one
+	return &resp, true
+}
+
+// Put implements ResponseCache
+func (c *MemoryResponseCache) Put(ctx context.Context, key string, resp *PromptResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &memoryResponse{key: key, resp: *resp, expires: c.now().Add(c.ttl)}
+	entry.resp.Parts = slices.Clone(resp.Parts)
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryResponse).key)
+	}
+}
+
+// Len returns the number of cached responses, including expired ones not yet evicted
+func (c *MemoryResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// SharedResponseCache keeps responses in a kv.Store, e.g. Redis, where every replica of a
+// server finds them
+type SharedResponseCache struct {
+	store kv.Store
+	ttl   time.Duration
+}
+
+var _ ResponseCache = (*SharedResponseCache)(nil)
+
+// NewSharedResponseCache keeps responses in store for ttl (default DefaultResponseCacheTTL)
+func NewSharedResponseCache(store kv.Store, ttl time.Duration) *SharedResponseCache {
+	if ttl <= 0 {
+		ttl = DefaultResponseCacheTTL
+	}
+	return &SharedResponseCache{store: store, ttl: ttl}
+}
+
+// Get implements ResponseCache
+func (c *SharedResponseCache) Get(ctx context.Context, key string) (*PromptResponse, bool) {
+	ctx, cancel := context.WithTimeout(ctx, sharedTimeout)
+	defer cancel()
+
+	data, err := c.store.Get(ctx, "responses:"+key)
+	if err != nil {
+		if !errors.Is(err, kv.ErrNotFound) {
+			log.Printf("Warning: failed to get cached response: %v", err)
+		}
+		return nil, false
+	}
+	var resp PromptResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		log.Printf("Warning: invalid cached response: %v", err)
+		return nil, false
+	}
+	return &resp, true
+}
+
+// Put implements ResponseCache
+func (c *SharedResponseCache) Put(ctx context.Context, key string, resp *PromptResponse) {
+	data, err := json.Marshal(resp)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(ctx, sharedTimeout)
+		err = c.store.Set(ctx, "responses:"+key, data, c.ttl)
+		cancel()
+	}
+	if err != nil {
+		log.Printf("Warning: failed to cache response: %v", err)
+	}
+}
+
+type noResponseCacheKey struct{}
+
+// WithoutResponseCache returns a context whose prompts are answered by the model even when
+// the response cache holds an answer; the fresh answer replaces the cached one
+func WithoutResponseCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noResponseCacheKey{}, true)
+}
+
+// responseCacheKey identifies the answer to a request: the store, prompt, history and
+// retrieval options, with the generation options and model asked for in the context and the
+// version of the prompt template of the store, so a rollback is not answered from the cache
+func responseCacheKey(ctx context.Context, req *PromptRequest, template string) (string, error) {
+	options, _ := ctx.Value(queryOptionsKey{}).(*QueryOptions)
+	model, _ := ctx.Value(modelKey{}).(string)
+	data, err := json.Marshal(struct {
+		Store     string
+		Prompt    string
+		History   []HistoryMessage
+		Retrieval *RetrievalOptions
+		Options   *QueryOptions
+		Model     string
+		Template  string
+	}{req.StoreName, req.Prompt, req.History, req.Retrieval, options, model, template})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cachedResponse returns the cached answer to a request, with its key to cache a new answer
+// under. The key is empty when the request cannot be cached.
+func (s *Service) cachedResponse(ctx context.Context, req *PromptRequest) (*PromptResponse, string) {
+	if s.responses == nil {
+		return nil, ""
+	}
+	templated, err := s.withTemplate(ctx, req.StoreName, nil)
+	if err != nil {
+		return nil, ""
+	}
+	var template string
+	if templated != nil {
+		template = templated.template
+	}
+	key, err := responseCacheKey(ctx, req, template)
+	if err != nil {
+		return nil, ""
+	}
+	if bypass, _ := ctx.Value(noResponseCacheKey{}).(bool); bypass {
+		return nil, key
+	}
+	resp, ok := s.responses.Get(ctx, key)
+	if !ok {
+		return nil, key
+	}
+	resp.Cached = true
+	resp.Usage = nil // No tokens were billed for it
+	return resp, key
+}
+
+// cacheResponse caches an answer under key, unless the citation policy refused it
+func (s *Service) cacheResponse(ctx context.Context, key string, resp *PromptResponse) {
+	if key == "" || resp.Refused {
+		return
+	}
+	s.responses.Put(ctx, key, resp)
+}
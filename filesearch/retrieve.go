@@ -0,0 +1,374 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// RetrievedChunk is a chunk retrieved for a query, in rank order
+type RetrievedChunk struct {
+	Rank     int               `json:"rank"` // 1-based
+	FileName string            `json:"fileName"`
+	URI      string            `json:"uri,omitempty"`
+	Page     int               `json:"page,omitempty"`
+	Article  string            `json:"article,omitempty"`
+	Text     string            `json:"text"`
+	Score    *float64          `json:"score,omitempty"`    // Similarity, when the backend reports it, including Boost
+	Boost    float64           `json:"boost,omitempty"`    // Added to the score by feedback on the chunk, see ChunkBoosts
+	ID       string            `json:"id,omitempty"`       // Identifies the chunk in feedback, see ChunkID
+	Metadata map[string]string `json:"metadata,omitempty"` // Custom metadata of the document
+	Corpus   string            `json:"corpus,omitempty"`   // Label of the federated store of the document
+}
+
+// ChunkRetriever retrieves the chunks for a query without answering it. Service implements it;
+// providers answering from their own index may implement it too.
+type ChunkRetriever interface {
+	RetrieveChunks(ctx context.Context, query string, storeName string, opts *RetrievalOptions) ([]*RetrievedChunk, error)
+}
+
+var _ ChunkRetriever = (*Service)(nil)
+
+const retrieveInstruction = `Search the documents for passages relevant to the user's question. Do not answer the question; reply with "OK".`
+
+// RetrieveChunks returns the chunks File Search retrieves for a query. File Search only
+// retrieves while generating, so the model is told to search and not to answer. It reports
+// no similarity scores.
+func (s *Service) RetrieveChunks(ctx context.Context, query string, storeName string, opts *RetrievalOptions) ([]*RetrievedChunk, error) {
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(retrieveInstruction, genai.RoleUser),
+		Tools:             []*genai.Tool{fileSearchTool(storeName, opts)},
+	}
+	profile := s.federation(storeName)
+	if err := s.federate(ctx, storeName, profile, config); err != nil {
+		return nil, err
+	}
+	resp, err := s.generate(ctx, s.modelName, genai.Text(query), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve: %w", err)
+	}
+
+	var chunks []*RetrievedChunk
+	if gs := s.parseResponse(resp).GroundingSupport; gs != nil {
+		for _, chunk := range gs.GroundingChunks {
+			if chunk.File == nil {
+				continue
+			}
+			chunks = append(chunks, &RetrievedChunk{
+				Rank:     len(chunks) + 1,
+				FileName: chunk.File.FileName,
+				URI:      chunk.File.URI,
+				Text:     chunk.File.Text,
+				Corpus:   s.corpus(profile, chunk.File.URI),
+			})
+		}
+	}
+	return chunks, nil
+}
+
+// Retrieve returns the topK chunks File Search retrieves for a query, in rank order, with the
+// custom metadata of their documents, without answering it. Zero topK uses the API default.
+func (s *Service) Retrieve(ctx context.Context, query string, storeName string, topK int) ([]*RetrievedChunk, error) {
+	chunks, err := s.RetrieveChunks(ctx, query, storeName, &RetrievalOptions{TopK: topK})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addMetadata(ctx, storeName, chunks); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// addMetadata sets the custom metadata of the documents of the chunks, see CachedDocuments
+func (s *Service) addMetadata(ctx context.Context, storeName string, chunks []*RetrievedChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	docs, err := s.CachedDocuments(ctx, storeName)
+	if err != nil {
+		return err
+	}
+	metadata := make(map[string]map[string]string, len(docs))
+	for _, doc := range docs {
+		metadata[doc.DisplayName] = doc.CustomMetadata
+	}
+	for _, chunk := range chunks {
+		chunk.Metadata = metadata[chunk.FileName]
+	}
+	return nil
+}
+
+// RetrievalDebug shows how a query was routed and which chunks it retrieved
+type RetrievalDebug struct {
+	Query          string            `json:"query"`
+	Original       string            `json:"original,omitempty"`    // Query as sent, when normalizing changed it
+	Store          string            `json:"store"`                 // Store the query was routed to
+	ExtraStores    []string          `json:"extraStores,omitempty"` // Stores of other sectors searched with it
+	MetadataFilter string            `json:"metadataFilter,omitempty"`
+	TopK           int               `json:"topK,omitempty"`
+	Instruction    string            `json:"instruction,omitempty"` // Appended to the prompt when answering
+	Chunks         []*RetrievedChunk `json:"chunks"`
+	Error          string            `json:"error,omitempty"`
+}
+
+// DebugRetrieve handles POST requests returning the chunks retrieved for a query, with their
+// scores and the filters applied by routing, without answering it. It takes the same body as
+// Query and is meant for diagnosing retrieval independently of the model.
+// POST /debug/retrieve
+func (h *Handler) DebugRetrieve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	req, err := DecodeQueryRequest(http.MaxBytesReader(w, r.Body, MaxRequestBytes+1))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(RetrievalDebug{Error: "Invalid request: " + err.Error()})
+		return
+	}
+	original := req.Query
+	if req.Query = h.normalize(req.Query); req.Query == original {
+		original = ""
+	}
+
+	route := h.routeRequest(req)
+	debug := RetrievalDebug{
+		Query:       req.Query,
+		Original:    original,
+		Store:       route.storeName,
+		Instruction: route.instruction,
+	}
+	if route.retrieval != nil {
+		debug.MetadataFilter = route.retrieval.MetadataFilter
+		debug.TopK = route.retrieval.TopK
+		debug.ExtraStores = route.retrieval.ExtraStores
+	}
+
+	var backend Provider = h.searcher
+	if h.provider != nil {
+		backend = h.provider
+	}
+	retriever, ok := backend.(ChunkRetriever)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(RetrievalDebug{Error: "The configured provider does not support retrieval debugging"})
+		return
+	}
+	storeName := route.storeName
+	if h.provider == nil {
+		store, err := h.searcher.GetStoreByName(r.Context(), route.storeName)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			debug.Error = "Store not found: " + err.Error()
+			json.NewEncoder(w).Encode(debug)
+			return
+		}
+		storeName = store.Name
+		if err := h.resolveExtraStores(r.Context(), route); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			debug.Error = "Store not found: " + err.Error()
+			json.NewEncoder(w).Encode(debug)
+			return
+		}
+	}
+
+	debug.Chunks, err = retriever.RetrieveChunks(r.Context(), req.Query, storeName, route.retrieval)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if IsUnavailable(err) {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		debug.Error = "Failed to retrieve: " + err.Error()
+		json.NewEncoder(w).Encode(debug)
+		return
+	}
+	if debug.Chunks == nil {
+		debug.Chunks = []*RetrievedChunk{}
+	}
+
+	// Place the chunks the way query citations are placed
+	for _, chunk := range debug.Chunks {
+		chunk.ID = ChunkID(chunk.FileName, chunk.Text)
+		if h.pages != nil && chunk.Page == 0 {
+			chunk.Page, _ = h.pages.LocatePage(chunk.FileName, chunk.Text)
+		}
+		if h.articles != nil && chunk.Article == "" {
+			chunk.Article, _ = h.articles.LocateArticle(chunk.FileName, chunk.Text)
+		}
+	}
+
+	writeJSON(w, debug)
+}
+
+// RetrieveRequest is the body of POST /retrieve
+type RetrieveRequest struct {
+	Query          string `json:"query"`
+	StoreName      string `json:"storeName"`
+	TopK           int    `json:"topK,omitempty"`           // Optional number of chunks, at most MaxTopK
+	MetadataFilter string `json:"metadataFilter,omitempty"` // Optional AIP-160 filter on custom metadata
+	AsOf           string `json:"asOf,omitempty"`           // Optional date (YYYY-MM-DD) the documents must be in force on
+}
+
+// Validate checks the request fields and returns a *FieldError for the first invalid one
+func (req *RetrieveRequest) Validate() error {
+	if strings.TrimSpace(req.Query) == "" {
+		return &FieldError{Field: "query", Message: "is required"}
+	}
+	if err := checkText("query", req.Query, MaxQueryLength); err != nil {
+		return err
+	}
+	if req.StoreName == "" {
+		return &FieldError{Field: "storeName", Message: "is required"}
+	}
+	if len(req.StoreName) > MaxStoreNameLength {
+		return &FieldError{Field: "storeName", Message: fmt.Sprintf("exceeds %d bytes", MaxStoreNameLength)}
+	}
+	if err := checkText("storeName", req.StoreName, 0); err != nil {
+		return err
+	}
+	if req.TopK < 0 || req.TopK > MaxTopK {
+		return &FieldError{Field: "topK", Message: fmt.Sprintf("must be between 0 and %d", MaxTopK)}
+	}
+	if err := checkText("metadataFilter", req.MetadataFilter, MaxSummaryLength); err != nil {
+		return err
+	}
+	if req.AsOf != "" {
+		if _, err := time.Parse(time.DateOnly, req.AsOf); err != nil {
+			return &FieldError{Field: "asOf", Message: "must be a date formatted as YYYY-MM-DD"}
+		}
+	}
+	return nil
+}
+
+// options returns the retrieval options of the request
+func (req *RetrieveRequest) options() *RetrievalOptions {
+	opts := &RetrievalOptions{TopK: req.TopK, MetadataFilter: req.MetadataFilter}
+	if req.AsOf != "" {
+		asOf, _ := time.Parse(time.DateOnly, req.AsOf)
+		opts = opts.WithFilter(AsOfFilter(asOf))
+	}
+	return opts
+}
+
+// RetrieveResponse holds the chunks retrieved for a query
+type RetrieveResponse struct {
+	Query  string            `json:"query,omitempty"`
+	Chunks []*RetrievedChunk `json:"chunks"`
+	Error  string            `json:"error,omitempty"`
+	Field  string            `json:"field,omitempty"` // Request field that failed validation
+}
+
+// RetrieveHandler handles POST requests returning the chunks most relevant to a query, in rank
+// order with their scores and document metadata, without generating an answer. It lets other
+// applications use the indexed documents as a search backend.
+// POST /retrieve
+// Body: {"query": "minimumloon", "storeName": "cao-documents", "topK": 10}
+func (h *Handler) RetrieveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	var req RetrieveRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&req)
+	if err != nil {
+		err = &FieldError{Message: "invalid JSON: " + err.Error()}
+	} else {
+		err = req.Validate()
+	}
+	if err != nil {
+		response := RetrieveResponse{Error: "Invalid request: " + err.Error()}
+		var fe *FieldError
+		if errors.As(err, &fe) {
+			response.Field = fe.Field
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	req.Query = h.normalize(req.Query)
+
+	// Only retrieve from the documents the caller may see
+	opts := req.options()
+	filter, err := h.accessFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(RetrieveResponse{Error: err.Error()})
+		return
+	}
+	if filter != "" {
+		opts = opts.WithFilter(filter)
+	}
+
+	var backend Provider = h.searcher
+	if h.provider != nil {
+		backend = h.provider
+	}
+	retriever, ok := backend.(ChunkRetriever)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(RetrieveResponse{Error: "The configured provider does not support retrieval"})
+		return
+	}
+	storeName := req.StoreName
+	if h.provider == nil {
+		store, err := h.searcher.GetStoreByName(r.Context(), req.StoreName)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(RetrieveResponse{Error: "Store not found: " + err.Error()})
+			return
+		}
+		storeName = store.Name
+	}
+
+	chunks, err := retriever.RetrieveChunks(r.Context(), req.Query, storeName, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if IsUnavailable(err) {
+			w.Header().Set("Retry-After", "30")
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(RetrieveResponse{Error: "Failed to retrieve: " + err.Error()})
+		return
+	}
+	if h.provider == nil && h.service != nil {
+		if err := h.service.addMetadata(r.Context(), storeName, chunks); err != nil {
+			log.Printf("Warning: failed to add document metadata to retrieved chunks: %v", err)
+		}
+	}
+
+	// Place the chunks the way query citations are placed
+	rewrite := h.uriRewriter(r.Context(), storeName)
+	for _, chunk := range chunks {
+		chunk.ID = ChunkID(chunk.FileName, chunk.Text)
+		if rewrite != nil {
+			chunk.URI = rewrite(chunk.FileName)
+		}
+		if h.pages != nil && chunk.Page == 0 {
+			chunk.Page, _ = h.pages.LocatePage(chunk.FileName, chunk.Text)
+		}
+		if h.articles != nil && chunk.Article == "" {
+			chunk.Article, _ = h.articles.LocateArticle(chunk.FileName, chunk.Text)
+		}
+	}
+	if chunks == nil {
+		chunks = []*RetrievedChunk{}
+	}
+
+	writeJSON(w, RetrieveResponse{Query: req.Query, Chunks: chunks})
+}
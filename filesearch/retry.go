@@ -0,0 +1,140 @@
+package filesearch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of a retry policy
+const (
+	DefaultRetryAttempts = 4
+	DefaultRetryDelay    = time.Second
+	DefaultRetryMaxDelay = 30 * time.Second
+)
+
+// RetryPolicy retries the API calls creating stores, uploading documents and generating
+// answers when they fail with a transient error: rate limiting, server errors, timeouts and
+// network failures, see IsUnavailable. The delay before a retry doubles with every attempt.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts including the first, DefaultRetryAttempts when zero
+	BaseDelay   time.Duration // Delay before the first retry, DefaultRetryDelay when zero
+	MaxDelay    time.Duration // Longest delay, DefaultRetryMaxDelay when zero
+	Jitter      float64       // Fraction of every delay that is randomized, from 0 to 1
+}
+
+// ParseRetryPolicy parses a comma-separated policy such as
+// "attempts=5,delay=2s,max-delay=1m,jitter=0.2"
+func ParseRetryPolicy(s string) (*RetryPolicy, error) {
+	policy := &RetryPolicy{}
+	for _, setting := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		var err error
+		switch name {
+		case "":
+		case "attempts":
+			policy.MaxAttempts, err = strconv.Atoi(value)
+		case "delay":
+			policy.BaseDelay, err = time.ParseDuration(value)
+		case "max-delay":
+			policy.MaxDelay, err = time.ParseDuration(value)
+		case "jitter":
+			policy.Jitter, err = strconv.ParseFloat(value, 64)
+		default:
+			return nil, fmt.Errorf("unknown retry policy setting %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry policy setting %q: %w", setting, err)
+		}
+	}
+	if policy.MaxAttempts < 0 || policy.BaseDelay < 0 || policy.MaxDelay < 0 {
+		return nil, fmt.Errorf("retry policy values must not be negative")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return nil, fmt.Errorf("retry policy jitter must be between 0 and 1")
+	}
+	return policy, nil
+}
+
+// Delay returns the delay before retry n, counting from 1, with the jitter applied
+func (p *RetryPolicy) Delay(n int) time.Duration {
+	base, maxDelay := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = DefaultRetryDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+
+	delay := base
+	for i := 1; i < n && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p == nil {
+		return 1
+	}
+	if p.MaxAttempts <= 0 {
+		return DefaultRetryAttempts
+	}
+	return p.MaxAttempts
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not transient or the
+// attempts of the policy are used up. A nil policy calls fn once.
+func withRetry[T any](ctx context.Context, policy *RetryPolicy, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= policy.attempts() || !IsUnavailable(err) || ctx.Err() != nil {
+			return result, err
+		}
+
+		delay := policy.Delay(attempt)
+		slog.Debug("Retrying Gemini request", "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			var zero T
+			return zero, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rereadable returns a function returning the content of a reader from the start for every
+// attempt of an upload. Readers that cannot seek are read into memory when retries are enabled.
+func rereadable(reader io.Reader, policy *RetryPolicy) (func() (io.Reader, error), error) {
+	if policy.attempts() == 1 {
+		return func() (io.Reader, error) { return reader, nil }, nil
+	}
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document: %w", err)
+		}
+		return func() (io.Reader, error) {
+			_, err := seeker.Seek(start, io.SeekStart)
+			return seeker, err
+		}, nil
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return func() (io.Reader, error) { return bytes.NewReader(data), nil }, nil
+}
@@ -0,0 +1,178 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"rag/langdetect"
+)
+
+// Router selects the store and retrieval options for a query, e.g. based on the sector it mentions.
+// It returns ok=false to keep the requested store. An empty store name also keeps the requested store.
+type Router interface {
+	RouteQuery(query string) (storeName string, opts *RetrievalOptions, ok bool)
+}
+
+// SectorRouter is a Router that also routes by the sectors of the user profile, given as JC
+// numbers, the questions that mention no sector themselves
+type SectorRouter interface {
+	Router
+	RouteSectors(jc []int) (storeName string, opts *RetrievalOptions, ok bool)
+}
+
+// WithRouter routes queries to a store or document subset before answering
+func WithRouter(router Router) HandlerOption {
+	return func(h *Handler) {
+		h.router = router
+	}
+}
+
+// LanguageRouting answers queries in their own language from the documents in that language
+type LanguageRouting struct {
+	Stores      map[string]string // Language code to store display name, e.g. "fr" -> "cao-documents-fr"
+	FilterKey   string            // Metadata key holding the document language, used when no store is configured
+	Languages   []string          // Languages of the documents FilterKey filters on, defaults to DefaultFilterLanguages
+	DefaultLang string            // Language assumed when detection is inconclusive, empty for no routing
+}
+
+// DefaultFilterLanguages are the languages of the Belgian corpus, the only ones filtered on
+// unless LanguageRouting.Languages is set
+var DefaultFilterLanguages = []string{langdetect.Dutch, langdetect.French}
+
+// holds reports whether queries in a language can be routed to documents in that language:
+// it has a store or, filtering on FilterKey, documents of the store are in that language
+func (l *LanguageRouting) holds(lang string) bool {
+	if _, ok := l.Stores[lang]; ok {
+		return true
+	}
+	if l.FilterKey == "" {
+		return false
+	}
+	languages := l.Languages
+	if len(languages) == 0 {
+		languages = DefaultFilterLanguages
+	}
+	return slices.Contains(languages, lang)
+}
+
+// WithLanguageRouting detects the query language, routes to the matching store or metadata filter
+// and instructs the model to answer in that language
+func WithLanguageRouting(routing *LanguageRouting) HandlerOption {
+	return func(h *Handler) {
+		h.langs = routing
+	}
+}
+
+// route is the outcome of query routing
+type route struct {
+	storeName   string
+	retrieval   *RetrievalOptions
+	instruction string // Appended to the prompt
+}
+
+// route applies the sector router, falling back to the sectors of the user profile, and then
+// language routing
+func (h *Handler) route(storeName string, query string, sectors []int) *route {
+	rt := &route{storeName: storeName}
+
+	if h.router != nil {
+		routed, opts, ok := h.router.RouteQuery(query)
+		if sr, isSector := h.router.(SectorRouter); !ok && isSector && len(sectors) > 0 {
+			routed, opts, ok = sr.RouteSectors(sectors)
+		}
+		if ok {
+			if routed != "" {
+				rt.storeName = routed
+			}
+			rt.retrieval = opts
+		}
+	}
+
+	if h.langs == nil {
+		return rt
+	}
+
+	lang, _ := langdetect.Detect(query)
+	if lang == "" {
+		lang = h.langs.DefaultLang
+	}
+	if lang == "" {
+		return rt
+	}
+
+	// Questions in a language no document is in, e.g. English, are answered from the documents
+	// in the default language, or from all documents
+	corpus := lang
+	if !h.langs.holds(corpus) {
+		corpus = h.langs.DefaultLang
+	}
+	if store, ok := h.langs.Stores[corpus]; ok {
+		rt.storeName = store
+	} else if h.langs.holds(corpus) {
+		rt.retrieval = rt.retrieval.WithFilter(fmt.Sprintf("%s = %q", h.langs.FilterKey, corpus))
+	}
+	// A store profile answering in a fixed language takes precedence over the query language
+	if name := langdetect.Name(lang); name != "" && !h.fixedLanguage(rt.storeName) {
+		rt.instruction = fmt.Sprintf("\n\nAnswer in %s.", name)
+	}
+
+	return rt
+}
+
+// routeRequest routes a query to the store or document subset for its sector and language,
+// and only to agreements in force on the requested date
+func (h *Handler) routeRequest(req *QueryRequest) *route {
+	rt := h.route(req.StoreName, req.Query, req.Sectors)
+	if req.AsOf != "" {
+		asOf, _ := time.Parse(time.DateOnly, req.AsOf) // checked by Validate
+		rt.retrieval = rt.retrieval.WithFilter(AsOfFilter(asOf))
+		rt.instruction += asOfInstruction(asOf)
+	}
+	return rt
+}
+
+// resolveExtraStores replaces the display names of the extra stores a router added with their
+// resource names
+func (h *Handler) resolveExtraStores(ctx context.Context, rt *route) error {
+	if rt.retrieval == nil || len(rt.retrieval.ExtraStores) == 0 {
+		return nil
+	}
+
+	resolved := *rt.retrieval
+	resolved.ExtraStores = make([]string, len(rt.retrieval.ExtraStores))
+	for i, name := range rt.retrieval.ExtraStores {
+		store, err := h.searcher.GetStoreByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		resolved.ExtraStores[i] = store.Name
+	}
+	rt.retrieval = &resolved
+	return nil
+}
+
+// fixedLanguage reports whether the profile of a store sets the answer language
+func (h *Handler) fixedLanguage(storeName string) bool {
+	if h.provider != nil || h.service == nil {
+		return false
+	}
+	profile := h.service.Profile(storeName)
+	return profile != nil && profile.Language != ""
+}
+
+// WithFilter returns a copy of the options with the filter added to any existing one
+func (o *RetrievalOptions) WithFilter(filter string) *RetrievalOptions {
+	if o == nil {
+		return &RetrievalOptions{MetadataFilter: filter}
+	}
+
+	combined := *o
+	if combined.MetadataFilter == "" {
+		combined.MetadataFilter = filter
+	} else {
+		combined.MetadataFilter = fmt.Sprintf("(%s) AND (%s)", combined.MetadataFilter, filter)
+	}
+	return &combined
+}
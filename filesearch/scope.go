@@ -0,0 +1,223 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+
+	"google.golang.org/genai"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultScopeRefusal is returned for questions on a topic outside the scope of the documents
+// when the topic sets no refusal
+const DefaultScopeRefusal = "This question is outside the scope of the collective labour agreements, so I cannot answer it."
+
+// Scope describes the questions the documents do not answer. A question on one of its topics
+// is refused with pointers to official resources instead of an answer the model improvises
+// from general knowledge, see WithScope and Service.ClassifyScope.
+type Scope struct {
+	Description string        `yaml:"description"` // What the documents cover, told to the model
+	Classify    bool          `yaml:"classify"`    // Ask the model about questions no keyword matched
+	Topics      []*ScopeTopic `yaml:"topics"`
+}
+
+// ScopeTopic is a kind of question outside the scope of the documents, e.g. personal tax advice
+type ScopeTopic struct {
+	Name        string      `yaml:"name"`        // Returned in OutOfScope, e.g. "tax"
+	Description string      `yaml:"description"` // What the topic covers, told to the model
+	Keywords    []string    `yaml:"keywords"`    // Words or phrases marking a question on the topic, in any case
+	Refusal     string      `yaml:"refusal"`     // Defaults to DefaultScopeRefusal
+	Resources   []*Resource `yaml:"resources"`   // Where the question can be answered instead
+}
+
+// Resource is an official source of information, e.g. a government website
+type Resource struct {
+	Title string `yaml:"title" json:"title"`
+	URL   string `yaml:"url" json:"url"`
+}
+
+// OutOfScope tells why a question was refused in a query response
+type OutOfScope struct {
+	Topic     string      `json:"topic"`
+	Resources []*Resource `json:"resources,omitempty"`
+}
+
+// LoadScope reads a scope from a YAML file
+func LoadScope(path string) (*Scope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scope: %w", err)
+	}
+
+	var scope Scope
+	if err := yaml.Unmarshal(data, &scope); err != nil {
+		return nil, fmt.Errorf("failed to parse scope: %w", err)
+	}
+	names := make(map[string]bool)
+	for i, topic := range scope.Topics {
+		switch {
+		case topic == nil || topic.Name == "":
+			return nil, fmt.Errorf("topic %d has no name", i+1)
+		case topic.Name == "none" || names[topic.Name]:
+			return nil, fmt.Errorf("invalid or duplicate topic name %q", topic.Name)
+		case len(topic.Keywords) == 0 && (!scope.Classify || topic.Description == ""):
+			return nil, fmt.Errorf("topic %q needs keywords, or a description with classify", topic.Name)
+		}
+		names[topic.Name] = true
+		for _, resource := range topic.Resources {
+			if u, err := url.Parse(resource.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+				return nil, fmt.Errorf("invalid resource URL %q for topic %q", resource.URL, topic.Name)
+			}
+		}
+	}
+
+	return &scope, nil
+}
+
+// Match returns the topic whose keywords appear in a question, or nil when there is none
+func (s *Scope) Match(question string) *ScopeTopic {
+	text := " " + strings.Join(scopeWords(question), " ") + " "
+	for _, topic := range s.Topics {
+		for _, keyword := range topic.Keywords {
+			if words := scopeWords(keyword); len(words) > 0 && strings.Contains(text, " "+strings.Join(words, " ")+" ") {
+				return topic
+			}
+		}
+	}
+	return nil
+}
+
+// scopeWords returns the lowercase words of a text
+func scopeWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// topic returns the topic with a name, or nil
+func (s *Scope) topic(name string) *ScopeTopic {
+	for _, topic := range s.Topics {
+		if topic.Name == name {
+			return topic
+		}
+	}
+	return nil
+}
+
+// instruction tells the model what the documents cover and which topics are out of scope
+func (s *Scope) instruction() string {
+	var sb strings.Builder
+	sb.WriteString("You decide whether a question can be answered from documents")
+	if s.Description != "" {
+		sb.WriteString(" about " + s.Description)
+	}
+	sb.WriteString(". Questions on these topics are outside their scope:\n")
+	for _, topic := range s.Topics {
+		sb.WriteString("- " + topic.Name)
+		if topic.Description != "" {
+			sb.WriteString(": " + topic.Description)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(`Return the topic the question is about, or "none" when it is about none of them, or when it also asks something the documents answer.`)
+	return sb.String()
+}
+
+// ClassifyScope returns the topic outside the scope of the documents a question is about, or
+// nil when the documents may answer it. Keywords are matched first; with Scope.Classify the
+// model is asked about the other questions.
+func (s *Service) ClassifyScope(ctx context.Context, question string, scope *Scope) (*ScopeTopic, error) {
+	if topic := scope.Match(question); topic != nil || !scope.Classify || len(scope.Topics) == 0 {
+		return topic, nil
+	}
+
+	names := []string{"none"}
+	for _, topic := range scope.Topics {
+		names = append(names, topic.Name)
+	}
+	resp, err := s.generate(ctx, s.modelName,
+		genai.Text(question),
+		&genai.GenerateContentConfig{
+			SystemInstruction: genai.NewContentFromText(scope.instruction(), genai.RoleUser),
+			ResponseMIMEType:  "application/json",
+			ResponseSchema: &genai.Schema{
+				Type:       genai.TypeObject,
+				Properties: map[string]*genai.Schema{"topic": {Type: genai.TypeString, Enum: names}},
+				Required:   []string{"topic"},
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify question: %w", err)
+	}
+	var result struct {
+		Topic string `json:"topic"`
+	}
+	if err := json.Unmarshal([]byte(resp.Text()), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode topic: %w", err)
+	}
+	return scope.topic(result.Topic), nil
+}
+
+// Answer returns the refusal of a question on the topic, followed by its resources
+func (t *ScopeTopic) Answer() string {
+	var sb strings.Builder
+	if t.Refusal != "" {
+		sb.WriteString(t.Refusal)
+	} else {
+		sb.WriteString(DefaultScopeRefusal)
+	}
+	if len(t.Resources) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, resource := range t.Resources {
+		sb.WriteString("\n- " + resource.Title + ": " + resource.URL)
+	}
+	return sb.String()
+}
+
+// WithScope refuses questions on topics outside the scope of the documents before anything
+// is retrieved, see Scope. Only Gemini classifies questions with the model; other providers
+// match the keywords. Questions that cannot be classified are answered.
+func WithScope(scope *Scope) HandlerOption {
+	return func(h *Handler) {
+		h.scope = scope
+	}
+}
+
+// outOfScope returns the topic outside the scope of the documents a question is about, or nil
+func (h *Handler) outOfScope(ctx context.Context, question string) *ScopeTopic {
+	if h.scope == nil {
+		return nil
+	}
+	if h.provider != nil || h.service == nil {
+		return h.scope.Match(question)
+	}
+	topic, err := h.service.ClassifyScope(ctx, question, h.scope)
+	if err != nil {
+		log.Printf("Warning: answering the question without scope check: %v", err)
+	}
+	return topic
+}
+
+// refuse answers a question on a topic outside the scope of the documents
+func (h *Handler) refuse(w http.ResponseWriter, r *http.Request, req *QueryRequest, topic *ScopeTopic) {
+	response := QueryResponse{
+		Answer:     topic.Answer(),
+		OutOfScope: &OutOfScope{Topic: topic.Name, Resources: topic.Resources},
+		Summary:    req.Summary,
+	}
+	if h.queries != nil {
+		response.QueryID = h.queries.LogQuery(r, req, &response)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, response)
+}
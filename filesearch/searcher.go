@@ -0,0 +1,31 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// FileSearcher is the part of Service the HTTP handler needs to answer queries and list
+// stores and documents. Service implements it with Gemini File Search; package
+// filesearchtest provides an in-memory implementation to test handlers without an API key.
+type FileSearcher interface {
+	Provider
+	GetStoreByName(ctx context.Context, displayName string) (*Store, error)
+	ListStores(ctx context.Context) ([]*Store, error)
+	ListDocuments(ctx context.Context, storeName string) ([]*Document, error)
+	ListDocumentsPage(ctx context.Context, storeName string, pageToken string, pageSize int) ([]*Document, string, error)
+	UploadDocument(ctx context.Context, reader io.Reader, fileName string, storeName string) (*Document, error)
+}
+
+var _ FileSearcher = (*Service)(nil)
+
+// unsupported answers 501 for features that need a Service rather than another FileSearcher
+func unsupported(w http.ResponseWriter, feature string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotImplemented)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": feature + " is not supported by the configured file searcher",
+	})
+}
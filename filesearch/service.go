@@ -1,51 +1,136 @@
 package filesearch
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rag/kv"
+	"rag/metrics"
+	"rag/telemetry"
+
+	"cloud.google.com/go/auth"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/genai"
 )
 
 // Service provides file search operations using Gemini API
 type Service struct {
-	client    *genai.Client
-	modelName string
+	client         *genai.Client
+	modelName      string
+	embeddingModel string
+	profiles       *Profiles
+	prompts        PromptTemplates
+	policy         *CitationPolicy
+	originals      DocumentFetcher
+	hooks          []ResponseHook
+	interceptors   []Interceptor
+	responses      ResponseCache
+	displayNames   sync.Map // store name -> display name, for profiles and prompt templates
+	retry          *RetryPolicy
+	cacheTTL       time.Duration
+	cacheMu        sync.Mutex
+	contextCaches  map[string]*contextCache // config key -> context cache
+	listsMu        sync.Mutex
+	lists          map[string]*documentList // store name -> cached listing, see CachedDocuments
+	listsGen       uint64                   // Incremented whenever a listing is dropped
+	generations    *generationLimiter
+	tracer         trace.Tracer
 }
 
 // Config holds the configuration for the Service
 type Config struct {
-	APIKey    string
-	ModelName string
-	Backend   genai.Backend
+	APIKey            string // Required by the Gemini API; with Vertex AI, selects express mode instead of a project
+	ModelName         string
+	EmbeddingModel    string // Model used by Embed, defaults to "gemini-embedding-001"
+	Backend           genai.Backend
+	Project           string            // Vertex AI project, defaults to GOOGLE_CLOUD_PROJECT or the project of the credentials
+	Location          string            // Vertex AI location, e.g. "europe-west3"; defaults to GOOGLE_CLOUD_LOCATION or "global"
+	Credentials       *auth.Credentials // Optional Vertex AI credentials, defaults to Application Default Credentials
+	CredentialsFile   string            // Optional service account key or other credentials file, instead of Credentials
+	HTTPClient        *http.Client      // Optional client for API calls, e.g. a vcr recorder in tests
+	Profiles          *Profiles         // Optional per-store answer profiles
+	Prompts           PromptTemplates   // Optional versioned system instructions, replacing those of the profiles
+	CitationPolicy    *CitationPolicy   // Optional policy for stores whose profile sets none
+	Originals         DocumentFetcher   // Optional source of the original documents, required by CloneStore
+	Hooks             []ResponseHook    // Optional post-processing of every answer, see Service.Use
+	Interceptors      []Interceptor     // Optional processing of every prompt, see Service.Intercept
+	RequestsPerMinute int               // Optional client-side limit on API requests per minute, respected by every method; zero is unlimited
+	UploadsPerMinute  int               // Optional limit on uploads per minute, on top of RequestsPerMinute; zero is unlimited
+	SharedLimits      kv.Store          // Optional store the replicas of a server share RequestsPerMinute and UploadsPerMinute through
+	Retry             *RetryPolicy      // Optional retries of calls failing with transient errors such as rate limiting
+	ContextCacheTTL   time.Duration     // Optional lifetime of context caches for long system instructions, zero disables them
+	ResponseCache     ResponseCache     // Optional cache of answers to identical prompts, see WithoutResponseCache
+
+	MaxConcurrentGenerations int           // Optional limit on model calls in flight, further calls queue; zero is unlimited
+	GenerationQueueTimeout   time.Duration // How long a queued call waits before failing with ErrOverloaded; zero waits for the context
+
+	TracerProvider trace.TracerProvider // Optional provider of the spans of API calls, defaults to the global provider
 }
 
-// NewService creates a new file search service
+// NewService creates a new file search service. With genai.BackendVertexAI it is authorized with
+// Config.Credentials, or Application Default Credentials, in Config.Project and Config.Location.
+// File Search stores, documents and attachments are only available in the Gemini API; on
+// Vertex AI their methods fail, while generation and Embed work.
 func NewService(ctx context.Context, cfg *Config) (*Service, error) {
-	if cfg.APIKey == "" {
-		return nil, fmt.Errorf("API key is required")
-	}
-
 	if cfg.ModelName == "" {
 		cfg.ModelName = "gemini-2.5-flash"
 	}
 
+	if cfg.EmbeddingModel == "" {
+		cfg.EmbeddingModel = "gemini-embedding-001"
+	}
+
 	if cfg.Backend.String() == "" {
 		cfg.Backend = genai.BackendGeminiAPI
 	}
 
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  cfg.APIKey,
-		Backend: cfg.Backend,
-	})
+	clientConfig := &genai.ClientConfig{
+		APIKey:     cfg.APIKey,
+		Backend:    cfg.Backend,
+		HTTPClient: countedClient(rateLimitedClient(cfg.HTTPClient, cfg.RequestsPerMinute, cfg.UploadsPerMinute, cfg.SharedLimits)),
+	}
+	switch cfg.Backend {
+	case genai.BackendVertexAI:
+		if err := vertexConfig(ctx, cfg, clientConfig); err != nil {
+			return nil, err
+		}
+	default:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("API key is required")
+		}
+	}
+
+	client, err := genai.NewClient(ctx, clientConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
 	return &Service{
-		client:    client,
-		modelName: cfg.ModelName,
+		client:         client,
+		modelName:      cfg.ModelName,
+		embeddingModel: cfg.EmbeddingModel,
+		profiles:       cfg.Profiles,
+		prompts:        cfg.Prompts,
+		policy:         cfg.CitationPolicy,
+		originals:      cfg.Originals,
+		hooks:          slices.Clone(cfg.Hooks),
+		interceptors:   slices.Clone(cfg.Interceptors),
+		responses:      cfg.ResponseCache,
+		retry:          cfg.Retry,
+		cacheTTL:       cfg.ContextCacheTTL,
+		contextCaches:  make(map[string]*contextCache),
+		generations:    newGenerationLimiter(cfg.MaxConcurrentGenerations, cfg.GenerationQueueTimeout),
+		tracer:         telemetry.Tracer(cfg.TracerProvider),
 	}, nil
 }
 
@@ -64,6 +149,8 @@ type Document struct {
 	CreateTime     string
 	UpdateTime     string
 	CustomMetadata map[string]string
+	State          string // DocumentPending, DocumentActive or DocumentFailed; empty when unknown
+	Operation      string // Upload operation of a document just uploaded, see WaitForDocumentProcessing
 }
 
 // CreateStore creates a new file search store
@@ -72,10 +159,13 @@ func (s *Service) CreateStore(ctx context.Context, displayName string) (*Store,
 		DisplayName: displayName,
 	}
 
-	store, err := s.client.FileSearchStores.Create(ctx, storeConfig)
+	store, err := withRetry(ctx, s.retry, func() (*genai.FileSearchStore, error) {
+		return s.client.FileSearchStores.Create(ctx, storeConfig)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store: %w", err)
 	}
+	s.displayNames.Store(store.Name, store.DisplayName)
 
 	return &Store{
 		Name:        store.Name,
@@ -87,26 +177,42 @@ func (s *Service) CreateStore(ctx context.Context, displayName string) (*Store,
 
 // ListStores lists all file search stores
 func (s *Service) ListStores(ctx context.Context) ([]*Store, error) {
-	storeList, err := s.client.FileSearchStores.List(ctx, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list stores: %w", err)
-	}
+	ctx, span := s.tracer.Start(ctx, "filesearch.list_stores")
+	stores, err := s.listStores(ctx)
+	span.SetAttributes(telemetry.DocumentsKey.Int(len(stores)))
+	telemetry.End(span, err)
+	return stores, err
+}
 
-	stores := make([]*Store, 0, len(storeList.Items))
-	for _, store := range storeList.Items {
-		stores = append(stores, &Store{
-			Name:        store.Name,
-			DisplayName: store.DisplayName,
-			CreateTime:  store.CreateTime.String(),
-			UpdateTime:  store.UpdateTime.String(),
-		})
+// listStores is ListStores without the span
+func (s *Service) listStores(ctx context.Context) ([]*Store, error) {
+	page, err := s.client.FileSearchStores.List(ctx, nil)
+	stores := make([]*Store, 0)
+	for ; err == nil; page, err = page.Next(ctx) {
+		for _, store := range page.Items {
+			s.displayNames.Store(store.Name, store.DisplayName)
+			stores = append(stores, &Store{
+				Name:        store.Name,
+				DisplayName: store.DisplayName,
+				CreateTime:  store.CreateTime.String(),
+				UpdateTime:  store.UpdateTime.String(),
+			})
+		}
+	}
+	if !errors.Is(err, genai.ErrPageDone) {
+		return nil, fmt.Errorf("failed to list stores: %w", err)
 	}
 
 	return stores, nil
 }
 
-// GetStoreByName finds a store by its display name
+// GetStoreByName finds a store by its display name. A virtual store, federating other stores
+// in its profile, is named by its display name.
 func (s *Service) GetStoreByName(ctx context.Context, displayName string) (*Store, error) {
+	if s.federation(displayName) != nil {
+		return &Store{Name: displayName, DisplayName: displayName}, nil
+	}
+
 	stores, err := s.ListStores(ctx)
 	if err != nil {
 		return nil, err
@@ -121,31 +227,107 @@ func (s *Service) GetStoreByName(ctx context.Context, displayName string) (*Stor
 	return nil, fmt.Errorf("store %q not found", displayName)
 }
 
-// ListDocuments lists all documents in a store
-func (s *Service) ListDocuments(ctx context.Context, storeName string) ([]*Document, error) {
-	docList, err := s.client.FileSearchStores.Documents.List(ctx, storeName, nil)
+// DeleteStore deletes a store by resource name. A store that still holds documents is only
+// deleted, together with its documents, when force is set.
+func (s *Service) DeleteStore(ctx context.Context, storeName string, force bool) error {
+	err := s.client.FileSearchStores.Delete(ctx, storeName, &genai.DeleteFileSearchStoreConfig{
+		Force: genai.Ptr(force),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list documents: %w", err)
+		return fmt.Errorf("failed to delete store: %w", err)
 	}
+	s.displayNames.Delete(storeName)
+	s.forgetDocuments(storeName)
+	return nil
+}
 
-	documents := make([]*Document, 0, len(docList.Items))
-	for _, doc := range docList.Items {
-		// Extract custom metadata
-		metadata := make(map[string]string)
-		for _, cm := range doc.CustomMetadata {
-			metadata[cm.Key] = cm.StringValue
+// ListDocuments lists all documents in a store, or in the stores a virtual store federates
+func (s *Service) ListDocuments(ctx context.Context, storeName string) ([]*Document, error) {
+	if profile := s.federation(storeName); profile != nil {
+		return s.listFederatedDocuments(ctx, profile)
+	}
+	documents := make([]*Document, 0)
+	pageToken := ""
+	for {
+		page, next, err := s.ListDocumentsPage(ctx, storeName, pageToken, 0)
+		if err != nil {
+			return nil, err
 		}
+		documents = append(documents, page...)
+		if next == "" {
+			return documents, nil
+		}
+		pageToken = next
+	}
+}
 
-		documents = append(documents, &Document{
-			Name:           doc.Name,
-			DisplayName:    doc.DisplayName,
-			CreateTime:     doc.CreateTime.String(),
-			UpdateTime:     doc.UpdateTime.String(),
-			CustomMetadata: metadata,
-		})
+// ListDocumentsPage returns one page of the documents in a store and the token of the next
+// page, empty on the last one. A page size of zero uses the API default.
+func (s *Service) ListDocumentsPage(ctx context.Context, storeName string, pageToken string, pageSize int) ([]*Document, string, error) {
+	ctx, span := s.tracer.Start(ctx, "filesearch.list_documents", trace.WithAttributes(telemetry.StoreKey.String(storeName)))
+	documents, next, err := s.listDocumentsPage(ctx, storeName, pageToken, pageSize)
+	span.SetAttributes(telemetry.DocumentsKey.Int(len(documents)))
+	telemetry.End(span, err)
+	return documents, next, err
+}
+
+// listDocumentsPage is ListDocumentsPage without the span
+func (s *Service) listDocumentsPage(ctx context.Context, storeName string, pageToken string, pageSize int) ([]*Document, string, error) {
+	page, err := s.client.FileSearchStores.Documents.List(ctx, storeName, &genai.ListDocumentsConfig{
+		PageSize:  int32(pageSize),
+		PageToken: pageToken,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	documents := make([]*Document, 0, len(page.Items))
+	for _, doc := range page.Items {
+		documents = append(documents, newDocument(doc))
+	}
+
+	return documents, page.NextPageToken, nil
+}
+
+// newDocument converts a document of the API
+func newDocument(doc *genai.Document) *Document {
+	// Extract custom metadata
+	metadata := make(map[string]string)
+	for _, cm := range doc.CustomMetadata {
+		metadata[cm.Key] = metadataValue(cm)
+	}
+
+	return &Document{
+		Name:           doc.Name,
+		DisplayName:    doc.DisplayName,
+		CreateTime:     doc.CreateTime.String(),
+		UpdateTime:     doc.UpdateTime.String(),
+		CustomMetadata: metadata,
+		State:          string(doc.State),
+	}
+}
+
+// DeleteDocument deletes a document, and its chunks, by resource name
+func (s *Service) DeleteDocument(ctx context.Context, documentName string) error {
+	err := s.client.FileSearchStores.Documents.Delete(ctx, documentName, &genai.DeleteDocumentConfig{
+		Force: genai.Ptr(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
 	}
+	s.forgetDocuments(documentName)
+	return nil
+}
 
-	return documents, nil
+// metadataValue returns a custom metadata value as a string, whatever its type
+func metadataValue(cm *genai.CustomMetadata) string {
+	switch {
+	case cm.NumericValue != nil:
+		return strconv.FormatFloat(float64(*cm.NumericValue), 'f', -1, 32)
+	case cm.StringListValue != nil:
+		return strings.Join(cm.StringListValue.Values, ",")
+	}
+	return cm.StringValue
 }
 
 // UploadDocument uploads a document to a store using a reader
@@ -155,29 +337,80 @@ func (s *Service) UploadDocument(ctx context.Context, reader io.Reader, fileName
 
 // UploadDocumentWithURL uploads a document with an optional source URL stored in metadata
 func (s *Service) UploadDocumentWithURL(ctx context.Context, reader io.Reader, fileName string, storeName string, sourceURL string) (*Document, error) {
+	return s.UploadDocumentWithMetadata(ctx, reader, fileName, storeName, sourceURL, nil)
+}
+
+// UploadDocumentWithMetadata uploads a document with an optional source URL and additional custom metadata,
+// such as the validity period from ValidityMetadata
+func (s *Service) UploadDocumentWithMetadata(ctx context.Context, reader io.Reader, fileName string, storeName string, sourceURL string, metadata []*genai.CustomMetadata) (*Document, error) {
 	config := &genai.UploadToFileSearchStoreConfig{
-		DisplayName: fileName,
-		MIMEType:    "application/pdf",
+		DisplayName:    fileName,
+		MIMEType:       "application/pdf",
+		CustomMetadata: append([]*genai.CustomMetadata{}, metadata...),
 	}
 
 	// Add source URL as custom metadata if provided
 	if sourceURL != "" {
-		config.CustomMetadata = []*genai.CustomMetadata{
-			{
-				Key:         "source_url",
-				StringValue: sourceURL,
-			},
-		}
+		config.CustomMetadata = append(config.CustomMetadata, &genai.CustomMetadata{
+			Key:         "source_url",
+			StringValue: sourceURL,
+		})
 	}
 
-	_, err := s.client.FileSearchStores.UploadToFileSearchStore(ctx, reader, storeName, config)
+	return s.upload(ctx, reader, storeName, config)
+}
+
+// upload uploads a document to a store, retrying transient failures
+func (s *Service) upload(ctx context.Context, reader io.Reader, storeName string, config *genai.UploadToFileSearchStoreConfig) (*Document, error) {
+	ctx, span := s.tracer.Start(ctx, "filesearch.upload", trace.WithAttributes(
+		telemetry.StoreKey.String(storeName),
+		telemetry.DocumentKey.String(config.DisplayName),
+	))
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		telemetry.End(span, err)
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	s.tagLanguage(ctx, data, storeName, config)
+	content, err := rereadable(bytes.NewReader(data), s.retry)
+	if err != nil {
+		telemetry.End(span, err)
+		return nil, err
+	}
+	var sent int64
+	op, err := withRetry(ctx, s.retry, func() (*genai.UploadToFileSearchStoreOperation, error) {
+		r, err := content()
+		if err != nil {
+			return nil, err
+		}
+		counted := &countingReader{r: r}
+		op, err := s.client.FileSearchStores.UploadToFileSearchStore(ctx, counted, storeName, config)
+		sent = counted.n
+		return op, err
+	})
+	telemetry.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload document: %w", err)
 	}
-
-	return &Document{
-		DisplayName: fileName,
-	}, nil
+	metrics.UploadBytes.Add(float64(sent))
+	s.forgetDocuments(storeName)
+
+	// The document is indexed after the upload returns, see WaitForDocumentProcessing
+	doc := &Document{
+		DisplayName:    config.DisplayName,
+		CustomMetadata: make(map[string]string, len(config.CustomMetadata)),
+		State:          DocumentPending,
+	}
+	for _, cm := range config.CustomMetadata {
+		doc.CustomMetadata[cm.Key] = metadataValue(cm)
+	}
+	if op != nil {
+		doc.Operation = op.Name
+		if op.Response != nil {
+			doc.Name = op.Response.DocumentName
+		}
+	}
+	return doc, nil
 }
 
 // PromptResponse contains the response from a prompt query
@@ -185,6 +418,41 @@ type PromptResponse struct {
 	Parts            []string
 	Citations        []*Citation
 	GroundingSupport *GroundingSupport
+	ToolCalls        []*ToolCall
+	Usage            *TokenUsage
+	PolicyViolation  string          // Why the answer breaks the citation policy, empty when it complies
+	Refused          bool            // The citation policy replaced the answer with a refusal
+	SubAnswers       []*SubAnswer    // Answers to the parts of a decomposed question, see PromptDecomposed
+	Model            string          // Model that wrote the answer, empty when an interceptor answered
+	PromptVersion    string          // Hash of the system instruction the answer was written with
+	Structured       json.RawMessage // The answer as JSON when asked for with a response schema, see DecodeStructured
+	Cached           bool            // Served from the response cache, see Config.ResponseCache
+	Markers          bool            // The answer carries inline source markers, see MarkSources
+	PromptTemplate   string          // Version of the prompt template of the store the answer was written with, see Config.Prompts
+}
+
+// TokenUsage counts the tokens billed for a response
+type TokenUsage struct {
+	PromptTokens   int `json:"promptTokens"`
+	ResponseTokens int `json:"responseTokens"`
+	TotalTokens    int `json:"totalTokens"`
+	CachedTokens   int `json:"cachedTokens,omitempty"` // Prompt tokens served from a context cache
+}
+
+// Add returns the sum of two usages; either may be nil
+func (u *TokenUsage) Add(other *TokenUsage) *TokenUsage {
+	if u == nil {
+		return other
+	}
+	if other == nil {
+		return u
+	}
+	return &TokenUsage{
+		PromptTokens:   u.PromptTokens + other.PromptTokens,
+		ResponseTokens: u.ResponseTokens + other.ResponseTokens,
+		TotalTokens:    u.TotalTokens + other.TotalTokens,
+		CachedTokens:   u.CachedTokens + other.CachedTokens,
+	}
 }
 
 // Citation represents a citation from the file search
@@ -202,10 +470,20 @@ type Source struct {
 
 // GroundingSupport contains grounding metadata from the response
 type GroundingSupport struct {
-	GroundingChunks []*GroundingChunk
+	GroundingChunks  []*GroundingChunk
+	Segments         []*GroundingSegment // Spans of the answer and the chunks supporting them
 	WebSearchQueries []string
 }
 
+// GroundingSegment is a span of the answer text supported by grounding chunks
+type GroundingSegment struct {
+	StartIndex       int       // Byte offset of the span in the answer text
+	EndIndex         int       // Byte offset just after the span
+	Text             string    // Text of the span
+	ChunkIndices     []int     // Indices in GroundingChunks
+	ConfidenceScores []float32 // Confidence, 0 to 1, that each chunk of ChunkIndices supports the span; empty when the model reports none
+}
+
 // GroundingChunk represents a chunk of content used for grounding
 type GroundingChunk struct {
 	Web  *WebGroundingChunk
@@ -222,80 +500,116 @@ type WebGroundingChunk struct {
 type FileGroundingChunk struct {
 	FileName string
 	URI      string
+	Text     string // Retrieved chunk text
+	Page     int    // 1-based PDF page the chunk starts on, zero when unknown
+	Link     string // Source URL deep-linked to the page, e.g. "https://...pdf#page=4"
+	Article  string // Article or paragraph the chunk belongs to, e.g. "Art. 14 §2"
+	Corpus   string // Label of the federated store the chunk was retrieved from, see FederatedStore
 }
 
-// Prompt sends a prompt to the model with access to the specified store (without history)
-func (s *Service) Prompt(ctx context.Context, prompt string, storeName string) (*PromptResponse, error) {
-	tool := &genai.Tool{
-		FileSearch: &genai.FileSearch{
-			FileSearchStoreNames: []string{storeName},
-		},
-	}
-
-	resp, err := s.client.Models.GenerateContent(ctx, s.modelName,
-		genai.Text(prompt),
-		&genai.GenerateContentConfig{
-			Tools: []*genai.Tool{tool},
-		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate content: %w", err)
-	}
-
-	return s.parseResponse(resp), nil
+// RetrievalOptions tunes how the file search tool retrieves chunks from a store
+type RetrievalOptions struct {
+	TopK           int      // Number of chunks to retrieve, zero uses the API default
+	MetadataFilter string   // AIP-160 filter on custom metadata, e.g. `jc_number = 3020000`
+	ExtraStores    []string // Further stores searched together with the store, by resource name; a Router sets display names
 }
 
-// PromptWithHistory sends a prompt to the model with conversation history and access to the specified store
-func (s *Service) PromptWithHistory(ctx context.Context, prompt string, storeName string, history interface{}) (*PromptResponse, error) {
-	tool := &genai.Tool{
-		FileSearch: &genai.FileSearch{
-			FileSearchStoreNames: []string{storeName},
-		},
+// fileSearchTool builds the file search tool for a store with optional retrieval options
+func fileSearchTool(storeName string, opts *RetrievalOptions) *genai.Tool {
+	fs := &genai.FileSearch{
+		FileSearchStoreNames: []string{storeName},
 	}
-
-	// Build the full prompt with conversation history
-	fullPrompt := prompt
-	if history != nil {
-		// History is passed as []HistoryMessage from handler
-		if historySlice, ok := history.([]interface{}); ok && len(historySlice) > 0 {
-			contextStr := "Previous conversation:\n"
-			for _, msg := range historySlice {
-				if msgMap, ok := msg.(map[string]interface{}); ok {
-					role := msgMap["role"]
-					content := msgMap["content"]
-					contextStr += fmt.Sprintf("%s: %s\n", role, content)
-				}
-			}
-			fullPrompt = contextStr + "\nCurrent question: " + prompt
+	if opts != nil {
+		fs.FileSearchStoreNames = append(fs.FileSearchStoreNames, opts.ExtraStores...)
+		if opts.TopK > 0 {
+			fs.TopK = genai.Ptr(int32(opts.TopK))
 		}
+		fs.MetadataFilter = opts.MetadataFilter
 	}
 
-	resp, err := s.client.Models.GenerateContent(ctx, s.modelName,
-		genai.Text(fullPrompt),
-		&genai.GenerateContentConfig{
-			Tools: []*genai.Tool{tool},
-		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate content: %w", err)
+	return &genai.Tool{FileSearch: fs}
+}
+
+// toolStores returns the stores the file search tools of a config search
+func toolStores(config *genai.GenerateContentConfig) []string {
+	var stores []string
+	for _, tool := range config.Tools {
+		if tool.FileSearch != nil {
+			stores = append(stores, tool.FileSearch.FileSearchStoreNames...)
+		}
 	}
+	return stores
+}
+
+// Prompt sends a prompt to the model with access to the specified store (without history)
+func (s *Service) Prompt(ctx context.Context, prompt string, storeName string) (*PromptResponse, error) {
+	return s.PromptWithRetrieval(ctx, prompt, storeName, nil)
+}
 
-	return s.parseResponse(resp), nil
+// PromptWithRetrieval sends a prompt to the model with access to the specified store using the given retrieval options
+func (s *Service) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *RetrievalOptions) (*PromptResponse, error) {
+	return s.answer(ctx, &PromptRequest{StoreName: storeName, Prompt: prompt, Retrieval: opts})
 }
 
-// parseResponse extracts the response data from the Gemini API response
+// PromptWithHistory sends a prompt to the model with conversation history and access to the
+// specified store. The history is sent as earlier user and model turns, so the model can resolve
+// follow-up questions against what was said before.
+func (s *Service) PromptWithHistory(ctx context.Context, prompt string, storeName string, history []HistoryMessage) (*PromptResponse, error) {
+	return s.answer(ctx, &PromptRequest{StoreName: storeName, Prompt: prompt, History: history})
+}
+
+// historyContents converts a conversation and the next prompt to user and model turns.
+// Empty messages are left out.
+func historyContents(history []HistoryMessage, prompt string) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(history)+1)
+	for _, msg := range history {
+		if strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		role := genai.Role(genai.RoleUser)
+		if msg.Role == "assistant" {
+			role = genai.RoleModel
+		}
+		contents = append(contents, genai.NewContentFromText(msg.Content, role))
+	}
+	return append(contents, genai.NewContentFromText(prompt, genai.RoleUser))
+}
+
+// parseResponse extracts the response data from the Gemini API response.
+// It runs for every answer, so slices are sized up front and chunks are allocated in blocks.
 func (s *Service) parseResponse(resp *genai.GenerateContentResponse) *PromptResponse {
+	numParts := 0
+	for _, cand := range resp.Candidates {
+		if cand.Content != nil {
+			numParts += len(cand.Content.Parts)
+		}
+	}
 
 	response := &PromptResponse{
-		Parts:     make([]string, 0),
+		Parts:     make([]string, 0, numParts),
 		Citations: make([]*Citation, 0),
 	}
 
+	if um := resp.UsageMetadata; um != nil {
+		response.Usage = &TokenUsage{
+			PromptTokens:   int(um.PromptTokenCount),
+			ResponseTokens: int(um.CandidatesTokenCount),
+			TotalTokens:    int(um.TotalTokenCount),
+			CachedTokens:   int(um.CachedContentTokenCount),
+		}
+	}
+
+	offset := 0
 	for _, cand := range resp.Candidates {
-		// Extract text parts
-		for _, part := range cand.Content.Parts {
-			if part.Text != "" {
-				response.Parts = append(response.Parts, part.Text)
+		// Extract text parts, noting where each starts in the answer text for the segments
+		var partOffsets []int
+		if cand.Content != nil {
+			for _, part := range cand.Content.Parts {
+				partOffsets = append(partOffsets, offset)
+				if part.Text != "" {
+					response.Parts = append(response.Parts, part.Text)
+					offset += len(part.Text)
+				}
 			}
 		}
 
@@ -322,13 +636,17 @@ func (s *Service) parseResponse(resp *genai.GenerateContentResponse) *PromptResp
 
 		// Extract grounding metadata
 		if cand.GroundingMetadata != nil {
+			chunks := cand.GroundingMetadata.GroundingChunks
 			response.GroundingSupport = &GroundingSupport{
-				GroundingChunks:  make([]*GroundingChunk, 0),
+				GroundingChunks:  make([]*GroundingChunk, 0, len(chunks)),
 				WebSearchQueries: cand.GroundingMetadata.WebSearchQueries,
 			}
 
-			for _, chunk := range cand.GroundingMetadata.GroundingChunks {
-				gc := &GroundingChunk{}
+			// One allocation for all chunks and one for all file chunks
+			values := make([]GroundingChunk, len(chunks))
+			files := make([]FileGroundingChunk, 0, len(chunks))
+			for i, chunk := range chunks {
+				gc := &values[i]
 
 				if chunk.Web != nil {
 					gc.Web = &WebGroundingChunk{
@@ -337,15 +655,40 @@ func (s *Service) parseResponse(resp *genai.GenerateContentResponse) *PromptResp
 					}
 				}
 
-				if chunk.RetrievedContext != nil && chunk.RetrievedContext.URI != "" {
-					gc.File = &FileGroundingChunk{
-						FileName: chunk.RetrievedContext.Title,
-						URI:      chunk.RetrievedContext.URI,
-					}
+				if rc := chunk.RetrievedContext; rc != nil && (rc.URI != "" || rc.Title != "") {
+					files = append(files, FileGroundingChunk{
+						FileName: rc.Title,
+						URI:      rc.URI,
+						Text:     rc.Text,
+					})
+					gc.File = &files[len(files)-1]
 				}
 
 				response.GroundingSupport.GroundingChunks = append(response.GroundingSupport.GroundingChunks, gc)
 			}
+
+			for _, support := range cand.GroundingMetadata.GroundingSupports {
+				seg := support.Segment
+				if seg == nil || int(seg.PartIndex) >= len(partOffsets) {
+					continue
+				}
+				segment := &GroundingSegment{
+					StartIndex:   partOffsets[seg.PartIndex] + int(seg.StartIndex),
+					EndIndex:     partOffsets[seg.PartIndex] + int(seg.EndIndex),
+					Text:         seg.Text,
+					ChunkIndices: make([]int, 0, len(support.GroundingChunkIndices)),
+				}
+				scored := len(support.ConfidenceScores) == len(support.GroundingChunkIndices)
+				for j, i := range support.GroundingChunkIndices {
+					if int(i) < len(chunks) {
+						segment.ChunkIndices = append(segment.ChunkIndices, int(i))
+						if scored {
+							segment.ConfidenceScores = append(segment.ConfidenceScores, support.ConfidenceScores[j])
+						}
+					}
+				}
+				response.GroundingSupport.Segments = append(response.GroundingSupport.Segments, segment)
+			}
 		}
 	}
 
@@ -0,0 +1,105 @@
+package filesearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// SourceFetcher returns the original file of a document, e.g. from a local copy or by
+// downloading its source URL
+type SourceFetcher func(ctx context.Context, doc *Document) ([]byte, error)
+
+// WithSourceFetcher serves the original documents from DocumentSourceHandler instead of
+// redirecting to their source URL, so they open even when the source site is slow or
+// blocks hotlinking
+func WithSourceFetcher(fetch SourceFetcher) HandlerOption {
+	return func(h *Handler) {
+		h.sources = fetch
+	}
+}
+
+// DocumentSourceHandler handles GET requests for the original file of a document. The
+// document is identified by the last segment of its resource name, its display name or its
+// DocumentID.
+// Without a source fetcher the client is redirected to the source URL.
+// GET /documents/{id}/source?storeName=NAME
+func (h *Handler) DocumentSourceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	storeName := r.URL.Query().Get("storeName")
+	if storeName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "storeName query parameter is required",
+		})
+		return
+	}
+
+	docs, err := h.searcher.ListDocuments(r.Context(), storeName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to list documents: " + err.Error(),
+		})
+		return
+	}
+
+	id := r.PathValue("id")
+	var doc *Document
+	for _, d := range docs {
+		if (strings.HasSuffix(d.Name, "/documents/"+id) || d.DisplayName == id || DocumentID(d.DisplayName) == id) && h.accessible(r, d) {
+			doc = d
+			break
+		}
+	}
+	if doc == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Document not found",
+		})
+		return
+	}
+
+	if h.sources == nil {
+		sourceURL := doc.CustomMetadata["source_url"]
+		if sourceURL == "" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Source URL not available",
+			})
+			return
+		}
+		http.Redirect(w, r, sourceURL, http.StatusTemporaryRedirect)
+		return
+	}
+
+	data, err := h.sources(r.Context(), doc)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to fetch document: " + err.Error(),
+		})
+		return
+	}
+
+	fileName := path.Base(doc.DisplayName)
+	contentType := http.DetectContentType(data)
+	if strings.HasPrefix(contentType, "application/octet-stream") || strings.HasPrefix(contentType, "text/plain") {
+		if byExt := mime.TypeByExtension(path.Ext(fileName)); byExt != "" {
+			contentType = byExt
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, fileName, time.Time{}, bytes.NewReader(data))
+}
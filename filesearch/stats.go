@@ -0,0 +1,249 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StoreStats tells how large and how fresh a store is, without listing its documents
+type StoreStats struct {
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+	Documents   int64     `json:"documents"` // Active, pending and failed documents
+	Active      int64     `json:"active"`
+	Pending     int64     `json:"pending"` // Uploaded but not yet processed
+	Failed      int64     `json:"failed"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	UpdateTime  time.Time `json:"updateTime"` // Last change to the store or its documents
+}
+
+// StoreStats returns the document counts, size and last update of a store by resource name,
+// from the counters File Search keeps for the store. The statistics of a virtual store add up
+// those of the stores it federates.
+func (s *Service) StoreStats(ctx context.Context, storeName string) (*StoreStats, error) {
+	if profile := s.federation(storeName); profile != nil {
+		stats := &StoreStats{Name: storeName, DisplayName: storeName}
+		for _, f := range profile.Federation {
+			store, err := s.GetStoreByName(ctx, f.Store)
+			if err != nil {
+				return nil, fmt.Errorf("federated store: %w", err)
+			}
+			member, err := s.StoreStats(ctx, store.Name)
+			if err != nil {
+				return nil, err
+			}
+			stats.Documents += member.Documents
+			stats.Active += member.Active
+			stats.Pending += member.Pending
+			stats.Failed += member.Failed
+			stats.SizeBytes += member.SizeBytes
+			if member.UpdateTime.After(stats.UpdateTime) {
+				stats.UpdateTime = member.UpdateTime
+			}
+		}
+		return stats, nil
+	}
+
+	store, err := s.client.FileSearchStores.Get(ctx, storeName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	s.displayNames.Store(store.Name, store.DisplayName)
+	return &StoreStats{
+		Name:        store.Name,
+		DisplayName: store.DisplayName,
+		Documents:   store.ActiveDocumentsCount + store.PendingDocumentsCount + store.FailedDocumentsCount,
+		Active:      store.ActiveDocumentsCount,
+		Pending:     store.PendingDocumentsCount,
+		Failed:      store.FailedDocumentsCount,
+		SizeBytes:   store.SizeBytes,
+		UpdateTime:  store.UpdateTime.UTC(),
+	}, nil
+}
+
+// StoreUpdate changes the settings of a store; empty fields are kept
+type StoreUpdate struct {
+	DisplayName string `json:"displayName"`
+}
+
+// UpdateStore changes a store by resource name and returns it as updated. File Search cannot
+// rename a store, so a new display name moves the documents into a new store of that name,
+// uploaded again from their originals with their custom metadata, see MigrateStore, and the
+// old store is deleted once every document was moved. The store then has a new resource name.
+// When a document cannot be moved the new store is deleted again and the old one kept, also
+// when ctx was canceled. Renaming takes as long as uploading every document, see
+// WithStoreRenamer to run it in the background.
+func (s *Service) UpdateStore(ctx context.Context, storeName string, update *StoreUpdate) (*Store, error) {
+	current, err := s.client.FileSearchStores.Get(ctx, storeName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	store := &Store{
+		Name:        current.Name,
+		DisplayName: current.DisplayName,
+		CreateTime:  current.CreateTime.String(),
+		UpdateTime:  current.UpdateTime.String(),
+	}
+	if update.DisplayName == "" || update.DisplayName == store.DisplayName {
+		return store, nil
+	}
+	if s.originals == nil {
+		return nil, errors.New("renaming a store requires Config.Originals")
+	}
+	if _, err := s.GetStoreByName(ctx, update.DisplayName); err == nil {
+		return nil, fmt.Errorf("store %q already exists", update.DisplayName)
+	}
+
+	renamed, err := s.CreateStore(ctx, update.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.MigrateStore(ctx, store.Name, renamed.Name)
+	if err == nil && len(report.Failed()) > 0 {
+		failed := report.Failed()[0]
+		err = fmt.Errorf("failed to move %s: %s", failed.Document, failed.Error)
+	}
+	if err != nil {
+		// Clean up even when the migration failed because ctx was canceled
+		if delErr := s.DeleteStore(context.WithoutCancel(ctx), renamed.Name, true); delErr != nil {
+			log.Printf("Warning: failed to delete incomplete store %s: %v", renamed.Name, delErr)
+		}
+		return nil, err
+	}
+	if err := s.DeleteStore(ctx, store.Name, true); err != nil {
+		return nil, fmt.Errorf("renamed store %s to %s, but failed to delete it: %w", store.Name, renamed.Name, err)
+	}
+	log.Printf("Renamed store %s to %q (%s)", store.Name, update.DisplayName, renamed.Name)
+	return renamed, nil
+}
+
+// StoreStatsHandler handles GET requests for the document counts, size and last update of a
+// store, by display name
+// GET /stores/{name}/stats
+func (h *Handler) StoreStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.service == nil {
+		unsupported(w, "Store statistics")
+		return
+	}
+
+	store, err := h.service.GetStoreByName(r.Context(), r.PathValue("name"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Store not found: " + err.Error(),
+		})
+		return
+	}
+
+	stats, err := h.service.StoreStats(r.Context(), store.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to get store statistics: " + err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// StoreRenamer renames a store, by resource name, in the background and returns the ID of
+// the job following the rename
+type StoreRenamer func(ctx context.Context, storeName string, displayName string) (jobID string, err error)
+
+// WithStoreRenamer lets PATCH /stores/{name} rename stores, see Service.UpdateStore, through
+// renamer, e.g. the Rename of an ingest.Jobs
+func WithStoreRenamer(renamer StoreRenamer) HandlerOption {
+	return func(h *Handler) {
+		h.renamer = renamer
+	}
+}
+
+// UpdateStoreHandler handles PATCH requests changing a store, by display name. A store that
+// keeps its name is returned as is; a rename is started in the background through the
+// StoreRenamer and answered with 202 and the ID of its job, see WithStoreRenamer.
+// PATCH /stores/{name}
+// Body: {"displayName": "new-name"}
+func (h *Handler) UpdateStoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.service == nil {
+		unsupported(w, "Updating stores")
+		return
+	}
+
+	var update StoreUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes)).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	update.DisplayName = strings.TrimSpace(update.DisplayName)
+	if len(update.DisplayName) > MaxStoreNameLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("displayName exceeds %d bytes", MaxStoreNameLength),
+		})
+		return
+	}
+
+	store, err := h.service.GetStoreByName(r.Context(), r.PathValue("name"))
+	if err != nil || h.service.federation(store.Name) != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Store not found: " + r.PathValue("name"),
+		})
+		return
+	}
+
+	if update.DisplayName == "" || update.DisplayName == store.DisplayName {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(store)
+		return
+	}
+	if h.renamer == nil || h.service.originals == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Renaming stores requires background jobs and the originals of the documents",
+		})
+		return
+	}
+	if _, err := h.service.GetStoreByName(r.Context(), update.DisplayName); err == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Store %q already exists", update.DisplayName),
+		})
+		return
+	}
+
+	jobID, err := h.renamer(r.Context(), store.Name, update.DisplayName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to rename store: " + err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+jobID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"jobId": jobID,
+	})
+}
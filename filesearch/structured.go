@@ -0,0 +1,146 @@
+package filesearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// MaxResponseSchemaBytes is the size of the largest response schema a query may send
+const MaxResponseSchemaBytes = 8 << 10
+
+// ErrNotStructured is returned when decoding the structured answer of a response generated
+// without a response schema, or whose answer was not valid JSON
+var ErrNotStructured = errors.New("answer is not structured")
+
+// checkResponseSchema checks that a response schema is a JSON object of reasonable size
+func checkResponseSchema(field string, schema json.RawMessage) error {
+	if len(schema) > MaxResponseSchemaBytes {
+		return &FieldError{Field: field, Message: fmt.Sprintf("must be at most %d bytes", MaxResponseSchemaBytes)}
+	}
+	var object map[string]any
+	if err := json.Unmarshal(schema, &object); err != nil || object == nil {
+		return &FieldError{Field: field, Message: "must be a JSON schema object"}
+	}
+	return nil
+}
+
+// applyResponseSchema asks the model for JSON answers following a JSON schema
+func applyResponseSchema(config *genai.GenerateContentConfig, schema json.RawMessage) {
+	config.ResponseMIMEType = "application/json"
+	config.ResponseJsonSchema = schema
+}
+
+// structuredAnswer returns the answer of a response as JSON when it was generated with a
+// response schema, nil otherwise. Code fences the model may wrap it in are removed.
+func structuredAnswer(resp *PromptResponse, config *genai.GenerateContentConfig) json.RawMessage {
+	if config.ResponseMIMEType != "application/json" {
+		return nil
+	}
+	text := strings.TrimSpace(resp.Text())
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "```"), "```")
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(text)); err != nil {
+		return nil
+	}
+	return compact.Bytes()
+}
+
+// DecodeStructured decodes the structured answer of a response, generated with the schema of
+// T as the ResponseSchema of its QueryOptions
+func DecodeStructured[T any](resp *PromptResponse) (*T, error) {
+	if resp.Structured == nil {
+		return nil, ErrNotStructured
+	}
+	var v T
+	if err := json.Unmarshal(resp.Structured, &v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotStructured, err)
+	}
+	return &v, nil
+}
+
+// SchemaFor returns the JSON schema of T, for the ResponseSchema of QueryOptions. Struct
+// fields are named by their json tag and described by their description tag; fields without
+// omitempty are required.
+func SchemaFor[T any]() (json.RawMessage, error) {
+	schema, err := schemaOf(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(schema)
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schemaOf returns the JSON schema of a type
+func schemaOf(t reflect.Type) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := []string{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property, err := schemaOf(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			if description := field.Tag.Get("description"); description != "" {
+				property["description"] = description
+			}
+			properties[name] = property
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
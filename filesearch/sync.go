@@ -0,0 +1,271 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// MetadataContentHash is the custom metadata key holding the content hash of a synced document,
+// or of one uploaded with UploadOptions.Hash
+const MetadataContentHash = "content_hash"
+
+// Sync actions
+const (
+	SyncUpload  = "upload"  // New document
+	SyncReplace = "replace" // Changed document, uploaded again and the old version deleted
+	SyncDelete  = "delete"  // Document no longer in the source
+)
+
+// SourceFile is a document a store is synced to
+type SourceFile struct {
+	Name      string                    // Display name, unique within the source
+	Hash      string                    // Content hash or version; the document is replaced when it changes
+	SourceURL string                    // Optional
+	Metadata  []*genai.CustomMetadata   // Optional, e.g. from ValidityMetadata
+	Open      func() (io.Reader, error) // Opens the content for upload
+}
+
+// DocumentSource lists the documents a store should hold
+type DocumentSource interface {
+	Documents(ctx context.Context) ([]*SourceFile, error)
+}
+
+// DirectorySource syncs the files of a directory matching a pattern, "*.pdf" when empty,
+// hashing their content
+type DirectorySource struct {
+	Dir       string
+	Pattern   string                                    // Matched against the base names of the files
+	Recursive bool                                      // Include subdirectories, naming their files by their path relative to Dir, e.g. "2024/a.pdf"
+	Inspect   func(data []byte) []*genai.CustomMetadata // Derives metadata from the content, e.g. validity.Metadata
+}
+
+// Documents lists and hashes the files of the directory. Hidden subdirectories are skipped.
+func (d *DirectorySource) Documents(ctx context.Context) ([]*SourceFile, error) {
+	pattern := d.Pattern
+	if pattern == "" {
+		pattern = "*.pdf"
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid source pattern: %w", err)
+	}
+	info, err := os.Stat(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", d.Dir)
+	}
+
+	var files []*SourceFile
+	err = filepath.WalkDir(d.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != d.Dir && (!d.Recursive || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matched, _ := filepath.Match(pattern, entry.Name()); !matched || !entry.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(d.Dir, path)
+		if err != nil {
+			return err
+		}
+		file := &SourceFile{
+			Name: filepath.ToSlash(name),
+			Hash: ContentHash(data),
+			Open: func() (io.Reader, error) { return os.Open(path) },
+		}
+		if d.Inspect != nil {
+			file.Metadata = d.Inspect(data)
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// SyncChange is a change made, or attempted, by SyncStore
+type SyncChange struct {
+	Document string `json:"document"`
+	Action   string `json:"action"`
+	Error    string `json:"error,omitempty"` // Set when the change failed
+}
+
+// SyncReport lists the changes made by SyncStore
+type SyncReport struct {
+	Changes   []*SyncChange `json:"changes"`
+	Unchanged int           `json:"unchanged"`
+}
+
+// Count returns the number of successful changes with the given action
+func (r *SyncReport) Count(action string) int {
+	n := 0
+	for _, change := range r.Changes {
+		if change.Action == action && change.Error == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the changes that failed
+func (r *SyncReport) Failed() []*SyncChange {
+	var failed []*SyncChange
+	for _, change := range r.Changes {
+		if change.Error != "" {
+			failed = append(failed, change)
+		}
+	}
+	return failed
+}
+
+// SyncOptions configures SyncDirectory
+type SyncOptions struct {
+	Pattern string                                    // Matched against the base names of the files, "*.pdf" when empty
+	Delete  bool                                      // Delete the documents of the store missing from the directory
+	Inspect func(data []byte) []*genai.CustomMetadata // Derives metadata from the content, e.g. validity.Metadata
+}
+
+// SyncDirectory mirrors a directory and its subdirectories into a store, see SyncStore: new
+// and changed files are uploaded, named by their path relative to dir. Documents of the store
+// missing from the directory are only deleted with SyncOptions.Delete. opts may be nil.
+func (s *Service) SyncDirectory(ctx context.Context, dir string, storeName string, opts *SyncOptions) (*SyncReport, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+	source := &DirectorySource{Dir: dir, Pattern: opts.Pattern, Recursive: true, Inspect: opts.Inspect}
+	return s.sync(ctx, storeName, source, opts.Delete)
+}
+
+// SyncStore makes a store hold exactly the documents of a source. The content hashes stored
+// with the documents of the store serve as its manifest: new documents are uploaded, documents
+// whose hash changed are uploaded again before their old version is deleted, and documents
+// missing from the source are deleted. Documents uploaded without a hash are replaced once.
+// Failed changes are recorded in the report and do not stop the sync.
+func (s *Service) SyncStore(ctx context.Context, storeName string, source DocumentSource) (*SyncReport, error) {
+	return s.sync(ctx, storeName, source, true)
+}
+
+// sync uploads the new and changed documents of a source, and deletes the documents missing
+// from it when deleteMissing is set
+func (s *Service) sync(ctx context.Context, storeName string, source DocumentSource, deleteMissing bool) (*SyncReport, error) {
+	files, err := source.Documents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source documents: %w", err)
+	}
+	existing, err := s.ListDocuments(ctx, storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[string][]*Document)
+	for _, doc := range existing {
+		manifest[doc.DisplayName] = append(manifest[doc.DisplayName], doc)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	report := &SyncReport{}
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		if seen[file.Name] {
+			return nil, fmt.Errorf("source lists document %q twice", file.Name)
+		}
+		seen[file.Name] = true
+
+		docs := manifest[file.Name]
+		if len(docs) == 1 && file.Hash != "" && docs[0].CustomMetadata[MetadataContentHash] == file.Hash {
+			report.Unchanged++
+			continue
+		}
+
+		change := &SyncChange{Document: file.Name, Action: SyncUpload}
+		if len(docs) > 0 {
+			change.Action = SyncReplace
+		}
+		report.Changes = append(report.Changes, change)
+		if err := s.uploadSourceFile(ctx, storeName, file); err != nil {
+			change.Error = err.Error()
+			continue
+		}
+		// Delete the old versions only once the new one is in place
+		for _, doc := range docs {
+			if err := s.DeleteDocument(ctx, doc.Name); err != nil {
+				change.Error = err.Error()
+			}
+		}
+	}
+
+	var removed []string
+	for name := range manifest {
+		if deleteMissing && !seen[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		change := &SyncChange{Document: name, Action: SyncDelete}
+		report.Changes = append(report.Changes, change)
+		for _, doc := range manifest[name] {
+			if err := s.DeleteDocument(ctx, doc.Name); err != nil {
+				change.Error = err.Error()
+			}
+		}
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		log.Printf("Warning: sync of %s failed for %d documents", storeName, len(failed))
+	}
+	return report, nil
+}
+
+// uploadSourceFile uploads a source document with its hash
+func (s *Service) uploadSourceFile(ctx context.Context, storeName string, file *SourceFile) error {
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open document: %w", err)
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	metadata := file.Metadata
+	if file.Hash != "" {
+		metadata = append(append([]*genai.CustomMetadata{}, metadata...), &genai.CustomMetadata{
+			Key:         MetadataContentHash,
+			StringValue: file.Hash,
+		})
+	}
+	if file.SourceURL != "" {
+		metadata = append(append([]*genai.CustomMetadata{}, metadata...), &genai.CustomMetadata{
+			Key:         "source_url",
+			StringValue: file.SourceURL,
+		})
+	}
+	_, err = s.upload(ctx, reader, storeName, &genai.UploadToFileSearchStoreConfig{
+		DisplayName:    file.Name,
+		MIMEType:       mimeTypeOf(file.Name, ""),
+		CustomMetadata: metadata,
+	})
+	return err
+}
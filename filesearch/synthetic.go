@@ -0,0 +1,100 @@
+package filesearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// MaxSyntheticQuestions is the most questions generated from one document
+const MaxSyntheticQuestions = 10
+
+// SyntheticQuestion is a question generated from a document, with the answer the document
+// gives to it, see Service.GenerateQuestions
+type SyntheticQuestion struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Document string `json:"document"`        // Display name of the document
+	Page     int    `json:"page,omitempty"`  // 1-based page of the answer, zero when unknown
+	Quote    string `json:"quote,omitempty"` // Passage of the document the answer is taken from
+}
+
+// syntheticSchema constrains the question generator output
+var syntheticSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"questions": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"question": {Type: genai.TypeString},
+					"answer":   {Type: genai.TypeString},
+					"page":     {Type: genai.TypeInteger},
+					"quote":    {Type: genai.TypeString},
+				},
+				Required: []string{"question", "answer", "quote"},
+			},
+		},
+	},
+	Required: []string{"questions"},
+}
+
+const syntheticInstruction = `You write test questions about a Belgian collective labour agreement (CAO) for an HR assistant that answers from such documents.
+Write up to %d questions an employee or payroll officer could ask that this document answers, each about a different provision: wages, allowances, working time, leave, notice periods, bonuses.
+Ask them the way users do, without naming the document, in the language of the document, and mention the sector or joint committee when the answer depends on it.
+For each question give the answer the document gives, with its amounts, percentages and dates exactly as written, the page it is on, and the passage it is taken from, quoted verbatim.
+Skip provisions whose answer is not stated in the document.`
+
+// GenerateQuestions generates up to n questions a document answers, MaxSyntheticQuestions at
+// most, with the answer the document gives and the passage it comes from. The original of the
+// document is read by the model, see Config.Originals. The questions are candidates to seed
+// evaluation cases with; the model may misread the document, so they need to be reviewed.
+func (s *Service) GenerateQuestions(ctx context.Context, doc *Document, n int) ([]*SyntheticQuestion, error) {
+	if s.originals == nil {
+		return nil, errors.New("generating questions requires Config.Originals")
+	}
+	if n <= 0 || n > MaxSyntheticQuestions {
+		n = MaxSyntheticQuestions
+	}
+
+	data, err := s.originals(ctx, doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", doc.DisplayName, err)
+	}
+	contents := []*genai.Content{genai.NewContentFromParts([]*genai.Part{
+		genai.NewPartFromBytes(data, mimeTypeOf(doc.DisplayName, "")),
+		genai.NewPartFromText("Document: " + doc.DisplayName),
+	}, genai.RoleUser)}
+
+	resp, err := s.generate(ctx, s.modelName, contents, &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(fmt.Sprintf(syntheticInstruction, n), genai.RoleUser),
+		ResponseMIMEType:  "application/json",
+		ResponseSchema:    syntheticSchema,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate questions: %w", err)
+	}
+	var result struct {
+		Questions []*SyntheticQuestion `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(resp.Text()), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode questions: %w", err)
+	}
+
+	var questions []*SyntheticQuestion
+	for _, q := range result.Questions {
+		q.Question = strings.TrimSpace(q.Question)
+		q.Answer = strings.TrimSpace(q.Answer)
+		if q.Question == "" || q.Answer == "" || len(questions) == n {
+			continue
+		}
+		q.Document = doc.DisplayName
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
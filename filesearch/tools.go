@@ -0,0 +1,333 @@
+package filesearch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// DefaultMaxToolRounds is the number of tool-call rounds before the agent loop gives up
+const DefaultMaxToolRounds = 5
+
+// ToolFunc executes a tool call with the arguments chosen by the model and returns a JSON-serializable result
+type ToolFunc func(ctx context.Context, args map[string]any) (map[string]any, error)
+
+// Tool is a Go function the model can call while answering
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any // JSON schema of the arguments object
+	Func        ToolFunc
+}
+
+// ToolCall records a tool invocation made during an agent loop
+type ToolCall struct {
+	Name   string         `json:"name"`
+	Args   map[string]any `json:"args,omitempty"`
+	Result map[string]any `json:"result,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// ToolRegistry holds the tools available to the agent loop
+type ToolRegistry struct {
+	MaxRounds int // Maximum tool-call rounds, zero uses DefaultMaxToolRounds
+
+	mu    sync.RWMutex
+	tools map[string]*Tool
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]*Tool),
+	}
+}
+
+// Register adds a tool to the registry
+func (r *ToolRegistry) Register(tool *Tool) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if tool.Func == nil {
+		return fmt.Errorf("tool %q has no function", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tools[tool.Name]; ok {
+		return fmt.Errorf("tool %q already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	return nil
+}
+
+// Names returns the names of the registered tools in alphabetical order
+func (r *ToolRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// declarations returns the function declarations sent to the model
+func (r *ToolRegistry) declarations() []*genai.FunctionDeclaration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	decls := make([]*genai.FunctionDeclaration, 0, len(names))
+	for _, name := range names {
+		tool := r.tools[name]
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:                 tool.Name,
+			Description:          tool.Description,
+			ParametersJsonSchema: tool.Parameters,
+		})
+	}
+	return decls
+}
+
+// call executes a single function call, reporting failures back to the model instead of aborting
+func (r *ToolRegistry) call(ctx context.Context, fc *genai.FunctionCall) *ToolCall {
+	r.mu.RLock()
+	tool, ok := r.tools[fc.Name]
+	r.mu.RUnlock()
+
+	record := &ToolCall{Name: fc.Name, Args: fc.Args}
+	if !ok {
+		record.Error = fmt.Sprintf("unknown tool %q", fc.Name)
+		return record
+	}
+
+	result, err := tool.Func(ctx, fc.Args)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	record.Result = result
+	return record
+}
+
+// PromptWithTools runs an agent loop: the model can call the registered tools (over multiple rounds)
+// while also retrieving from the specified store, until it produces a final answer. opts may be nil.
+func (s *Service) PromptWithTools(ctx context.Context, prompt string, storeName string, registry *ToolRegistry, opts *RetrievalOptions) (*PromptResponse, error) {
+	req := &PromptRequest{StoreName: storeName, Prompt: prompt, Retrieval: opts}
+	if resp, err := s.intercept(ctx, req); resp != nil || err != nil {
+		return resp, err
+	}
+
+	model, config, profile, err := s.answerConfig(ctx, req.StoreName,
+		fileSearchTool(req.StoreName, req.Retrieval),
+		&genai.Tool{FunctionDeclarations: registry.declarations()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := s.withCitationPolicy(profile, config, func(config *genai.GenerateContentConfig) (*PromptResponse, error) {
+		return s.runTools(ctx, model, config, req.Prompt, registry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.finish(profile, parsed)
+}
+
+// runTools runs the agent loop of PromptWithTools
+func (s *Service) runTools(ctx context.Context, model string, config *genai.GenerateContentConfig, prompt string, registry *ToolRegistry) (*PromptResponse, error) {
+	maxRounds := registry.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = DefaultMaxToolRounds
+	}
+
+	contents := []*genai.Content{genai.NewContentFromText(prompt, genai.RoleUser)}
+	var calls []*ToolCall
+	var grounding *GroundingSupport
+	var usage *TokenUsage
+
+	for round := 0; round <= maxRounds; round++ {
+		resp, err := s.generate(ctx, model, contents, config)
+		if err != nil && hasFileChunks(grounding) {
+			// Earlier rounds already retrieved documents
+			return nil, &GenerationError{
+				Reason:  "generation failed after calling tools",
+				Partial: &PromptResponse{GroundingSupport: grounding, ToolCalls: calls, Usage: usage},
+				Err:     fmt.Errorf("failed to generate content: %w", err),
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate content: %w", err)
+		}
+
+		parsed := s.parseResponse(resp)
+		grounding = mergeGrounding(grounding, parsed.GroundingSupport)
+		usage = usage.Add(parsed.Usage)
+
+		functionCalls := resp.FunctionCalls()
+		if len(functionCalls) == 0 {
+			parsed.GroundingSupport = grounding
+			parsed.ToolCalls = calls
+			parsed.Usage = usage
+			scopeChunks(ctx, parsed)
+			return stamp(parsed, model, config), checkAnswer(resp, parsed)
+		}
+
+		if round == maxRounds {
+			break
+		}
+
+		// Echo the model turn and answer every call in a single function turn
+		contents = append(contents, resp.Candidates[0].Content)
+		parts := make([]*genai.Part, 0, len(functionCalls))
+		for _, fc := range functionCalls {
+			record := registry.call(ctx, fc)
+			calls = append(calls, record)
+
+			response := record.Result
+			if record.Error != "" {
+				response = map[string]any{"error": record.Error}
+			}
+			part := genai.NewPartFromFunctionResponse(fc.Name, response)
+			part.FunctionResponse.ID = fc.ID
+			parts = append(parts, part)
+		}
+		contents = append(contents, genai.NewContentFromParts(parts, genai.RoleUser))
+	}
+
+	return nil, fmt.Errorf("model did not produce an answer within %d tool rounds", maxRounds)
+}
+
+// mergeGrounding adds the grounding of a round to that of the rounds before it. The segments
+// of earlier rounds point into text the answer of the new round does not contain, so only their
+// chunks are kept, for the sources; the segments are those of the new round.
+func mergeGrounding(acc *GroundingSupport, next *GroundingSupport) *GroundingSupport {
+	if acc == nil {
+		return next
+	}
+
+	merged := &GroundingSupport{
+		GroundingChunks:  slices.Clone(acc.GroundingChunks),
+		WebSearchQueries: slices.Clone(acc.WebSearchQueries),
+	}
+	if next != nil {
+		merged.appendGrounding(next, 0)
+	}
+	return merged
+}
+
+// RegisterDateTools registers date arithmetic tools, useful for questions about notice periods,
+// seniority and the validity of agreements
+func RegisterDateTools(r *ToolRegistry) error {
+	dateParam := func(description string) map[string]any {
+		return map[string]any{"type": "string", "description": description + " (YYYY-MM-DD)"}
+	}
+
+	tools := []*Tool{
+		{
+			Name:        "days_between",
+			Description: "Returns the number of days, and the whole months and years, between two dates",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"start": dateParam("Start date"),
+					"end":   dateParam("End date"),
+				},
+				"required": []string{"start", "end"},
+			},
+			Func: daysBetween,
+		},
+		{
+			Name:        "add_to_date",
+			Description: "Adds a number of years, months and days (which may be negative) to a date",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"date":   dateParam("Date to start from"),
+					"years":  map[string]any{"type": "integer"},
+					"months": map[string]any{"type": "integer"},
+					"days":   map[string]any{"type": "integer"},
+				},
+				"required": []string{"date"},
+			},
+			Func: addToDate,
+		},
+	}
+
+	for _, tool := range tools {
+		if err := r.Register(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func daysBetween(_ context.Context, args map[string]any) (map[string]any, error) {
+	start, err := dateArg(args, "start")
+	if err != nil {
+		return nil, err
+	}
+	end, err := dateArg(args, "end")
+	if err != nil {
+		return nil, err
+	}
+
+	months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month())
+	if end.Day() < start.Day() {
+		months--
+	}
+
+	return map[string]any{
+		"days":   int(end.Sub(start).Hours() / 24),
+		"months": months,
+		"years":  months / 12,
+	}, nil
+}
+
+func addToDate(_ context.Context, args map[string]any) (map[string]any, error) {
+	date, err := dateArg(args, "date")
+	if err != nil {
+		return nil, err
+	}
+
+	result := date.AddDate(intArg(args, "years"), intArg(args, "months"), intArg(args, "days"))
+	return map[string]any{
+		"date":    result.Format(time.DateOnly),
+		"weekday": result.Weekday().String(),
+	}, nil
+}
+
+func dateArg(args map[string]any, key string) (time.Time, error) {
+	value, _ := args[key].(string)
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format", key)
+	}
+	return date, nil
+}
+
+// intArg reads an integer argument, which arrives as float64 from JSON
+func intArg(args map[string]any, key string) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,247 @@
+package filesearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// UploadOptions configures UploadFile, UploadFromURL and UploadIfNew
+type UploadOptions struct {
+	DisplayName    string                                    // Defaults to the base name of the path or URL
+	MIMEType       string                                    // Defaults to the type of the file extension, or PDF
+	Metadata       map[string]any                            // Added to the metadata of the document, see MetadataValues
+	CustomMetadata []*genai.CustomMetadata                   // Added to the metadata of the document
+	Inspect        func(data []byte) []*genai.CustomMetadata // Derives metadata from the content, e.g. validity.Metadata
+	Fetch          func(sourceURL string) (io.Reader, error) // Downloads for UploadFromURL, a plain GET when nil
+	Hash           bool                                      // Records the SHA-256 of the content as MetadataContentHash
+	Existing       []*Document                               // Documents UploadIfNew compares with, listed from the store when nil
+}
+
+// UploadFile uploads a local file to a store. opts may be nil.
+func (s *Service) UploadFile(ctx context.Context, filePath string, storeName string, opts *UploadOptions) (*Document, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return s.uploadContent(ctx, data, filepath.Base(filePath), storeName, "", opts)
+}
+
+// UploadFromURL downloads a document and uploads it to a store, recording the URL as the
+// source_url metadata. opts may be nil.
+func (s *Service) UploadFromURL(ctx context.Context, sourceURL string, storeName string, opts *UploadOptions) (*Document, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	var reader io.Reader
+	if opts != nil && opts.Fetch != nil {
+		reader, err = opts.Fetch(sourceURL)
+	} else {
+		reader, err = download(ctx, sourceURL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", sourceURL, err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", sourceURL, err)
+	}
+	return s.uploadContent(ctx, data, path.Base(u.Path), storeName, sourceURL, opts)
+}
+
+// uploadContent uploads data named fileName with the options applied
+func (s *Service) uploadContent(ctx context.Context, data []byte, fileName string, storeName string, sourceURL string, opts *UploadOptions) (*Document, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+	if opts.DisplayName != "" {
+		fileName = opts.DisplayName
+	}
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return nil, fmt.Errorf("cannot derive a display name, set UploadOptions.DisplayName")
+	}
+
+	metadata, err := MetadataValues(opts.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	metadata = append(metadata, opts.CustomMetadata...)
+	if opts.Inspect != nil {
+		metadata = append(metadata, opts.Inspect(data)...)
+	}
+	if sourceURL != "" {
+		metadata = append(metadata, &genai.CustomMetadata{Key: "source_url", StringValue: sourceURL})
+	}
+	if opts.Hash {
+		metadata = append(metadata, &genai.CustomMetadata{Key: MetadataContentHash, StringValue: ContentHash(data)})
+	}
+
+	return s.upload(ctx, bytes.NewReader(data), storeName, &genai.UploadToFileSearchStoreConfig{
+		DisplayName:    fileName,
+		MIMEType:       mimeTypeOf(fileName, opts.MIMEType),
+		CustomMetadata: metadata,
+	})
+}
+
+// UploadIfNew uploads a document unless a document of the store has the same content, whatever
+// its name, so renamed or re-published documents are not uploaded twice. Documents are compared
+// by the SHA-256 of their content, recorded with the upload as MetadataContentHash; documents
+// uploaded without a hash never match. It returns the document holding the content and whether
+// it was uploaded. opts may be nil.
+func (s *Service) UploadIfNew(ctx context.Context, reader io.Reader, fileName string, storeName string, opts *UploadOptions) (*Document, bool, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read document: %w", err)
+	}
+	withHash := UploadOptions{}
+	if opts != nil {
+		withHash = *opts
+	}
+	withHash.Hash = true
+
+	existing := withHash.Existing
+	if existing == nil {
+		if existing, err = s.ListDocuments(ctx, storeName); err != nil {
+			return nil, false, err
+		}
+	}
+	if doc := FindByContentHash(existing, ContentHash(data)); doc != nil {
+		return doc, false, nil
+	}
+
+	doc, err := s.uploadContent(ctx, data, fileName, storeName, "", &withHash)
+	if err != nil {
+		return nil, false, err
+	}
+	return doc, true, nil
+}
+
+// ContentHash returns the SHA-256 of the content of a document, as recorded in
+// MetadataContentHash
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FindByContentHash returns the first document recorded with a content hash, or nil
+func FindByContentHash(docs []*Document, hash string) *Document {
+	for _, doc := range docs {
+		if hash != "" && doc.CustomMetadata[MetadataContentHash] == hash {
+			return doc
+		}
+	}
+	return nil
+}
+
+// MetadataValues converts metadata to custom metadata, ordered by key. Values may be strings,
+// string slices, integers, floats or dates; dates are stored as day numbers like valid_from,
+// so filters such as "jc_number = 3020000 AND signature_date >= 19358" can compare them.
+// Numbers are stored as float32, so integers beyond 2^24 are rejected rather than rounded.
+func MetadataValues(metadata map[string]any) ([]*genai.CustomMetadata, error) {
+	values := make([]*genai.CustomMetadata, 0, len(metadata))
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		cm := &genai.CustomMetadata{Key: key}
+		var err error
+		switch v := metadata[key].(type) {
+		case string:
+			cm.StringValue = v
+		case []string:
+			cm.StringListValue = &genai.StringList{Values: v}
+		case time.Time:
+			cm.NumericValue = genai.Ptr(float32(DayNumber(v)))
+		case int:
+			cm.NumericValue, err = exactValue(key, int64(v))
+		case int32:
+			cm.NumericValue, err = exactValue(key, int64(v))
+		case int64:
+			cm.NumericValue, err = exactValue(key, v)
+		case float32:
+			cm.NumericValue = genai.Ptr(v)
+		case float64:
+			cm.NumericValue = genai.Ptr(float32(v))
+		default:
+			err = fmt.Errorf("metadata %s: unsupported type %T", key, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, cm)
+	}
+	return values, nil
+}
+
+// maxExactInteger is the largest integer a float32 holds exactly
+const maxExactInteger = 1 << 24
+
+// exactValue converts an integer to a numeric metadata value, failing when it would be rounded
+func exactValue(key string, n int64) (*float32, error) {
+	if n > maxExactInteger || n < -maxExactInteger {
+		return nil, fmt.Errorf("metadata %s: %d cannot be stored exactly", key, n)
+	}
+	return genai.Ptr(float32(n)), nil
+}
+
+// documentTypes are the types of document extensions missing from the built-in table of the
+// mime package, used when the system has no mime.types file listing them
+var documentTypes = map[string]string{
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// mimeTypeOf returns mimeType, or else the type of the extension of fileName, defaulting to PDF
+func mimeTypeOf(fileName string, mimeType string) string {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(ext)
+	}
+	if mimeType == "" {
+		mimeType = documentTypes[ext]
+	}
+	if mimeType == "" {
+		mimeType = "application/pdf"
+	}
+	return mimeType
+}
+
+// download fetches a URL with a plain GET request
+func download(ctx context.Context, sourceURL string) (io.Reader, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
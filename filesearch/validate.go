@@ -0,0 +1,217 @@
+package filesearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits enforced on incoming query requests
+const (
+	MaxRequestBytes    = 1 << 20 // Size of the whole request body
+	MaxQueryLength     = 4000    // Characters in the question
+	MaxStoreNameLength = 512     // Bytes in the store name
+	MaxModelLength     = 128     // Characters in the model name
+	MaxSessionIDLength = 128     // Characters in the session ID
+	MaxChannelLength   = 64      // Characters in the channel name
+	MaxHistoryMessages = 100     // Messages in the conversation history
+	MaxMessageLength   = 32000   // Characters in a single history message
+	MaxSummaryItems    = 50      // Facts or prior answers in the summary
+	MaxSummaryLength   = 2000    // Characters in a single summary field
+	MaxTopK            = 100     // Chunks requested from /retrieve
+	MaxSectors         = 20      // JC numbers of the user profile
+)
+
+// FieldError reports a request field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// DecodeQueryRequest reads and validates a query request body. The body must be
+// valid UTF-8 holding a single JSON object with only known fields; anything else is
+// rejected with a *FieldError instead of being silently repaired or ignored.
+func DecodeQueryRequest(r io.Reader) (*QueryRequest, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxRequestBytes+1))
+	if err != nil {
+		return nil, &FieldError{Message: "failed to read request body: " + err.Error()}
+	}
+	if len(data) > MaxRequestBytes {
+		return nil, &FieldError{Message: fmt.Sprintf("request body exceeds %d bytes", MaxRequestBytes)}
+	}
+	// encoding/json replaces invalid UTF-8 with U+FFFD, so check the raw bytes first
+	if !utf8.Valid(data) {
+		return nil, &FieldError{Message: "request body is not valid UTF-8"}
+	}
+
+	var req QueryRequest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, &FieldError{Message: "invalid JSON: " + err.Error()}
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, &FieldError{Message: "invalid JSON: unexpected data after the request object"}
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Validate checks the request fields and returns a *FieldError for the first invalid one
+func (req *QueryRequest) Validate() error {
+	if strings.TrimSpace(req.Query) == "" {
+		return &FieldError{Field: "query", Message: "is required"}
+	}
+	if err := checkText("query", req.Query, MaxQueryLength); err != nil {
+		return err
+	}
+
+	if req.StoreName == "" {
+		return &FieldError{Field: "storeName", Message: "is required"}
+	}
+	if len(req.StoreName) > MaxStoreNameLength {
+		return &FieldError{Field: "storeName", Message: fmt.Sprintf("exceeds %d bytes", MaxStoreNameLength)}
+	}
+	if err := checkText("storeName", req.StoreName, 0); err != nil {
+		return err
+	}
+
+	if req.Format != "" && req.Format != "markdown" && req.Format != "html" {
+		return &FieldError{Field: "format", Message: `must be "markdown" or "html"`}
+	}
+
+	if err := checkText("sessionId", req.SessionID, MaxSessionIDLength); err != nil {
+		return err
+	}
+	if err := checkText("channel", req.Channel, MaxChannelLength); err != nil {
+		return err
+	}
+	if err := checkText("model", req.Model, MaxModelLength); err != nil {
+		return err
+	}
+
+	if err := req.Options.Validate(); err != nil {
+		return err
+	}
+
+	if req.AsOf != "" {
+		if _, err := time.Parse(time.DateOnly, req.AsOf); err != nil {
+			return &FieldError{Field: "asOf", Message: "must be a date formatted as YYYY-MM-DD"}
+		}
+	}
+	if len(req.Sectors) > MaxSectors {
+		return &FieldError{Field: "sectors", Message: fmt.Sprintf("exceeds %d sectors", MaxSectors)}
+	}
+	for _, jc := range req.Sectors {
+		if jc < 1000000 || jc > 9999999 {
+			return &FieldError{Field: "sectors", Message: "must be 7-digit JC numbers, e.g. 3020000"}
+		}
+	}
+
+	switch req.Mode {
+	case "":
+		if req.Left != nil || req.Right != nil {
+			return &FieldError{Field: "mode", Message: `must be "compare" when left or right is set`}
+		}
+	case ModeCompare:
+		if err := checkSide("left", req.Left); err != nil {
+			return err
+		}
+		if err := checkSide("right", req.Right); err != nil {
+			return err
+		}
+	default:
+		return &FieldError{Field: "mode", Message: `must be empty or "compare"`}
+	}
+
+	if len(req.History) > MaxHistoryMessages {
+		return &FieldError{Field: "history", Message: fmt.Sprintf("exceeds %d messages", MaxHistoryMessages)}
+	}
+	for i, msg := range req.History {
+		field := fmt.Sprintf("history[%d]", i)
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return &FieldError{Field: field + ".role", Message: `must be "user" or "assistant"`}
+		}
+		if err := checkText(field+".content", msg.Content, MaxMessageLength); err != nil {
+			return err
+		}
+	}
+
+	if cs := req.Summary; cs != nil {
+		if err := checkText("summary.sector", cs.Sector, MaxSummaryLength); err != nil {
+			return err
+		}
+		if err := checkText("summary.jointCommittee", cs.JointCommittee, MaxSummaryLength); err != nil {
+			return err
+		}
+		if err := checkText("summary.contractType", cs.ContractType, MaxSummaryLength); err != nil {
+			return err
+		}
+		if err := checkList("summary.facts", cs.Facts); err != nil {
+			return err
+		}
+		if err := checkList("summary.priorAnswers", cs.PriorAnswers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkSide(field string, side *CompareSide) error {
+	if side == nil || (side.Document == "" && side.MetadataFilter == "") {
+		return &FieldError{Field: field, Message: "document or metadataFilter is required in compare mode"}
+	}
+	if err := checkText(field+".label", side.Label, MaxSummaryLength); err != nil {
+		return err
+	}
+	if err := checkText(field+".document", side.Document, MaxSummaryLength); err != nil {
+		return err
+	}
+	return checkText(field+".metadataFilter", side.MetadataFilter, MaxSummaryLength)
+}
+
+func checkList(field string, values []string) error {
+	if len(values) > MaxSummaryItems {
+		return &FieldError{Field: field, Message: fmt.Sprintf("exceeds %d items", MaxSummaryItems)}
+	}
+	for i, value := range values {
+		if err := checkText(fmt.Sprintf("%s[%d]", field, i), value, MaxSummaryLength); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkText rejects invalid UTF-8, control characters other than whitespace, and
+// values longer than maxRunes characters (zero means no limit)
+func checkText(field, value string, maxRunes int) error {
+	if !utf8.ValidString(value) {
+		return &FieldError{Field: field, Message: "is not valid UTF-8"}
+	}
+	if maxRunes > 0 && utf8.RuneCountInString(value) > maxRunes {
+		return &FieldError{Field: field, Message: fmt.Sprintf("exceeds %d characters", maxRunes)}
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return &FieldError{Field: field, Message: "contains control characters"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package filesearch
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Metadata keys holding the period a document is in force. Values are day numbers
+// (days since 1970-01-01) so metadata filters can compare them; float32 holds them exactly.
+const (
+	MetadataValidFrom  = "valid_from"
+	MetadataValidUntil = "valid_until"
+)
+
+// Indefinite is the expiry date recorded for agreements of indefinite duration
+var Indefinite = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// DayNumber returns the number of days between 1970-01-01 and the date of t
+func DayNumber(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// ValidityMetadata returns the custom metadata recording that a document is in force from
+// from until until, inclusive. A zero until means an indefinite duration.
+func ValidityMetadata(from, until time.Time) []*genai.CustomMetadata {
+	if until.IsZero() {
+		until = Indefinite
+	}
+	return []*genai.CustomMetadata{
+		{Key: MetadataValidFrom, NumericValue: genai.Ptr(float32(DayNumber(from)))},
+		{Key: MetadataValidUntil, NumericValue: genai.Ptr(float32(DayNumber(until)))},
+	}
+}
+
+// AsOfFilter returns a metadata filter matching the documents in force on the date of t.
+// Documents without validity metadata never match.
+func AsOfFilter(t time.Time) string {
+	day := DayNumber(t)
+	return fmt.Sprintf("%s <= %d AND %s >= %d", MetadataValidFrom, day, MetadataValidUntil, day)
+}
+
+// asOfInstruction tells the model which date the answer must hold for
+func asOfInstruction(t time.Time) string {
+	return fmt.Sprintf("\n\nAnswer as of %s: only apply provisions in force on that date, "+
+		"and say so when the documents found do not cover it.", t.Format(time.DateOnly))
+}
@@ -0,0 +1,110 @@
+package filesearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+	"google.golang.org/genai"
+)
+
+// vertexScope is the OAuth scope of Vertex AI requests
+const vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// vertexConfig completes the client configuration for the Vertex AI backend. An API key selects
+// express mode; otherwise requests are authorized with the configured credentials or
+// Application Default Credentials, in the configured project and location.
+func vertexConfig(ctx context.Context, cfg *Config, cc *genai.ClientConfig) error {
+	if cfg.APIKey != "" {
+		if cfg.Project != "" || cfg.Location != "" || cfg.Credentials != nil || cfg.CredentialsFile != "" {
+			return errors.New("Vertex AI takes either an API key or a project with credentials, not both")
+		}
+		return nil
+	}
+
+	creds := cfg.Credentials
+	if creds == nil {
+		var err error
+		creds, err = credentials.DetectDefault(&credentials.DetectOptions{
+			Scopes:          []string{vertexScope},
+			CredentialsFile: cfg.CredentialsFile,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find Google Cloud credentials: %w", err)
+		}
+	}
+
+	project := cfg.Project
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" {
+		// Service account keys name their project
+		project, _ = creds.ProjectID(ctx)
+	}
+	if project == "" {
+		return errors.New("Vertex AI requires a project, set Config.Project or GOOGLE_CLOUD_PROJECT")
+	}
+
+	cc.Project = project
+	cc.Location = cfg.Location
+	cc.Credentials = creds
+	if cc.HTTPClient != nil {
+		// genai only authorizes the clients it creates itself
+		client, err := authorizedClient(ctx, cc.HTTPClient, creds)
+		if err != nil {
+			return err
+		}
+		cc.HTTPClient = client
+	}
+	return nil
+}
+
+// authorizedClient returns a copy of client adding the credentials' token, and quota project if
+// any, to every request
+func authorizedClient(ctx context.Context, client *http.Client, creds *auth.Credentials) (*http.Client, error) {
+	quotaProject, err := creds.QuotaProjectID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota project ID: %w", err)
+	}
+
+	authorized := *client
+	base := authorized.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	authorized.Transport = &tokenTransport{base: base, tokens: creds, quotaProject: quotaProject}
+	return &authorized, nil
+}
+
+// tokenTransport authorizes requests with OAuth tokens
+type tokenTransport struct {
+	base         http.RoundTripper
+	tokens       auth.TokenProvider
+	quotaProject string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("failed to get Google Cloud token: %w", err)
+	}
+	tokenType := token.Type
+	if tokenType == "" {
+		tokenType = "Bearer"
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", tokenType+" "+token.Value)
+	if t.quotaProject != "" {
+		req.Header.Set("X-Goog-User-Project", t.quotaProject)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,60 @@
+package filesearchtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"rag/filesearch"
+	"rag/filesearchtest"
+)
+
+func ExampleFake() {
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	fake.AddDocument(store.Name, "302-2023-004512.pdf", "Een werkman heeft recht op 20 vakantiedagen.", nil)
+	fake.AddDocument(store.Name, "100-2022-011302.pdf", "Het minimumuurloon bedraagt 14,05 EUR.", nil)
+
+	handler := filesearch.NewHandler(fake)
+	body := `{"query": "Hoeveel bedraagt het minimumuurloon?", "storeName": "cao-documents"}`
+	rec := httptest.NewRecorder()
+	handler.Query(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+
+	var resp filesearch.QueryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(rec.Code, resp.Answer, resp.Sources[0].FileName)
+	// Output:
+	// 200 Het minimumuurloon bedraagt 14,05 EUR. 100-2022-011302.pdf
+}
+
+func ExampleFake_metadataFilter() {
+	fake := filesearchtest.New()
+	store := fake.CreateStore("cao-documents")
+	fake.AddDocument(store.Name, "302-2023-004512.pdf", "Vakantiedagen in de horeca", map[string]string{"jc_number": "3020000"})
+	fake.AddDocument(store.Name, "200-2023-001234.pdf", "Vakantiedagen voor bedienden", map[string]string{"jc_number": "2000000"})
+
+	chunks, err := fake.RetrieveChunks(context.Background(), "vakantiedagen", store.Name, &filesearch.RetrievalOptions{
+		MetadataFilter: "jc_number = 2000000",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		fmt.Println(chunk.Rank, chunk.FileName)
+	}
+
+	// Simulate an outage
+	fake.Err = errors.New("backend down")
+	_, err = fake.ListStores(context.Background())
+	fmt.Println(err)
+	// Output:
+	// 1 200-2023-001234.pdf
+	// backend down
+}
@@ -0,0 +1,301 @@
+// Package filesearchtest provides an in-memory filesearch.FileSearcher, so HTTP handlers and
+// other code built on package filesearch can be tested without a Gemini API key.
+package filesearchtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"rag/filesearch"
+)
+
+// Fake keeps stores and documents in memory and answers prompts from the documents sharing the
+// most words with the prompt. The zero value is not usable; create one with New.
+type Fake struct {
+	// Answer writes the answer to a prompt from the retrieved chunks. Nil answers with the text
+	// of the best chunk, or "No documents found." when nothing matches.
+	Answer func(prompt string, chunks []*filesearch.RetrievedChunk) string
+	// Err, when set, is returned by every call, e.g. to simulate an outage
+	Err error
+
+	mu     sync.Mutex
+	stores []*store
+}
+
+type store struct {
+	*filesearch.Store
+	docs []*document
+}
+
+type document struct {
+	*filesearch.Document
+	text string
+}
+
+var (
+	_ filesearch.FileSearcher   = (*Fake)(nil)
+	_ filesearch.ChunkRetriever = (*Fake)(nil)
+)
+
+// New returns an empty fake
+func New() *Fake {
+	return &Fake{}
+}
+
+// CreateStore adds an empty store and returns it
+func (f *Fake) CreateStore(displayName string) *filesearch.Store {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := timestamp()
+	s := &store{Store: &filesearch.Store{
+		Name:        fmt.Sprintf("fileSearchStores/store-%d", len(f.stores)+1),
+		DisplayName: displayName,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}}
+	f.stores = append(f.stores, s)
+	return s.Store
+}
+
+// AddDocument adds a document with its text and custom metadata to a store, by resource name
+func (f *Fake) AddDocument(storeName string, displayName string, text string, metadata map[string]string) (*filesearch.Document, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.store(storeName)
+	if s == nil {
+		return nil, fmt.Errorf("store %q not found", storeName)
+	}
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	now := timestamp()
+	doc := &document{
+		Document: &filesearch.Document{
+			Name:           fmt.Sprintf("%s/documents/document-%d", storeName, len(s.docs)+1),
+			DisplayName:    displayName,
+			CreateTime:     now,
+			UpdateTime:     now,
+			CustomMetadata: metadata,
+			State:          filesearch.DocumentActive,
+		},
+		text: text,
+	}
+	s.docs = append(s.docs, doc)
+	s.UpdateTime = now
+	return doc.Document, nil
+}
+
+// GetStoreByName implements filesearch.FileSearcher
+func (f *Fake) GetStoreByName(ctx context.Context, displayName string) (*filesearch.Store, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.stores {
+		if s.DisplayName == displayName {
+			return s.Store, nil
+		}
+	}
+	return nil, fmt.Errorf("store %q not found", displayName)
+}
+
+// ListStores implements filesearch.FileSearcher
+func (f *Fake) ListStores(ctx context.Context) ([]*filesearch.Store, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stores := make([]*filesearch.Store, 0, len(f.stores))
+	for _, s := range f.stores {
+		stores = append(stores, s.Store)
+	}
+	return stores, nil
+}
+
+// ListDocuments implements filesearch.FileSearcher
+func (f *Fake) ListDocuments(ctx context.Context, storeName string) ([]*filesearch.Document, error) {
+	docs, _, err := f.ListDocumentsPage(ctx, storeName, "", 0)
+	return docs, err
+}
+
+// ListDocumentsPage implements filesearch.FileSearcher. A zero page size returns all
+// remaining documents; page tokens are offsets.
+func (f *Fake) ListDocumentsPage(ctx context.Context, storeName string, pageToken string, pageSize int) ([]*filesearch.Document, string, error) {
+	if f.Err != nil {
+		return nil, "", f.Err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.store(storeName)
+	if s == nil {
+		return nil, "", fmt.Errorf("failed to list documents: store %q not found", storeName)
+	}
+	start := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 || n > len(s.docs) {
+			return nil, "", fmt.Errorf("failed to list documents: invalid page token %q", pageToken)
+		}
+		start = n
+	}
+	end := len(s.docs)
+	if pageSize > 0 && start+pageSize < end {
+		end = start + pageSize
+	}
+
+	documents := make([]*filesearch.Document, 0, end-start)
+	for _, doc := range s.docs[start:end] {
+		documents = append(documents, doc.Document)
+	}
+	next := ""
+	if end < len(s.docs) {
+		next = strconv.Itoa(end)
+	}
+	return documents, next, nil
+}
+
+// UploadDocument implements filesearch.FileSearcher, reading the content as plain text
+func (f *Fake) UploadDocument(ctx context.Context, reader io.Reader, fileName string, storeName string) (*filesearch.Document, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return f.AddDocument(storeName, fileName, string(content), nil)
+}
+
+// RetrieveChunks implements filesearch.ChunkRetriever. Every matching document is one chunk,
+// scored by the share of the query words it contains.
+func (f *Fake) RetrieveChunks(ctx context.Context, query string, storeName string, opts *filesearch.RetrievalOptions) ([]*filesearch.RetrievedChunk, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	var filter *filesearch.MetadataFilter
+	if opts != nil && opts.MetadataFilter != "" {
+		var err error
+		if filter, err = filesearch.ParseFilter(opts.MetadataFilter); err != nil {
+			return nil, fmt.Errorf("failed to retrieve: %w", err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.store(storeName)
+	if s == nil {
+		return nil, fmt.Errorf("failed to retrieve: store %q not found", storeName)
+	}
+	terms := words(query)
+	var chunks []*filesearch.RetrievedChunk
+	for _, doc := range s.docs {
+		if filter != nil && !filter.Match(doc.CustomMetadata) {
+			continue
+		}
+		contained := words(doc.text)
+		matched := 0
+		for term := range terms {
+			if contained[term] {
+				matched++
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+		score := float64(matched) / float64(len(terms))
+		chunks = append(chunks, &filesearch.RetrievedChunk{
+			FileName: doc.DisplayName,
+			URI:      doc.CustomMetadata["source_url"],
+			Text:     doc.text,
+			Score:    &score,
+			Metadata: doc.CustomMetadata,
+		})
+	}
+
+	slices.SortStableFunc(chunks, func(a, b *filesearch.RetrievedChunk) int {
+		switch {
+		case *a.Score > *b.Score:
+			return -1
+		case *a.Score < *b.Score:
+			return 1
+		}
+		return 0
+	})
+	if opts != nil && opts.TopK > 0 && len(chunks) > opts.TopK {
+		chunks = chunks[:opts.TopK]
+	}
+	for i, chunk := range chunks {
+		chunk.Rank = i + 1
+	}
+	return chunks, nil
+}
+
+// PromptWithRetrieval implements filesearch.Provider, answering with Answer from the
+// retrieved chunks and grounding the answer in them
+func (f *Fake) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	chunks, err := f.RetrieveChunks(ctx, prompt, storeName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer string
+	switch {
+	case f.Answer != nil:
+		answer = f.Answer(prompt, chunks)
+	case len(chunks) > 0:
+		answer = chunks[0].Text
+	default:
+		answer = "No documents found."
+	}
+
+	gs := &filesearch.GroundingSupport{}
+	for _, chunk := range chunks {
+		gs.GroundingChunks = append(gs.GroundingChunks, &filesearch.GroundingChunk{
+			File: &filesearch.FileGroundingChunk{FileName: chunk.FileName, URI: chunk.URI, Text: chunk.Text},
+		})
+	}
+	return &filesearch.PromptResponse{Parts: []string{answer}, GroundingSupport: gs}, nil
+}
+
+// store returns a store by resource name, nil when there is none; f.mu must be held
+func (f *Fake) store(name string) *store {
+	for _, s := range f.stores {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// words returns the lower-cased words of a text, ignoring words shorter than three letters
+func words(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 3 {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// timestamp returns the current time the way Service reports document times
+func timestamp() string {
+	return time.Now().UTC().String()
+}
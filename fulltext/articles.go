@@ -0,0 +1,78 @@
+package fulltext
+
+import (
+	"regexp"
+	"strings"
+)
+
+// articlePattern matches article headings ("Artikel 14", "Article 3bis", "Art. 5 § 2") and
+// paragraph markers ("§ 2.") at the start of a line. References inside sentences such as
+// "overeenkomstig artikel 5" are not headings and are ignored.
+var articlePattern = regexp.MustCompile(`(?im)^[ \t]*(?:(?:artikel|article|art\.)[ \t]*(\d+)(?:er)?[ \t]*(bis|ter|quater)?(?:[ \t]*[,.]?[ \t]*§[ \t]*(\d+))?|§[ \t]*(\d+))\b`)
+
+// articleStart is the offset in the normalized document text where an article or paragraph starts
+type articleStart struct {
+	offset int
+	label  string // e.g. "Art. 14 §2"
+}
+
+// segment is a run of page text, starting at the article heading named by label if set
+type segment struct {
+	text  string
+	label string
+}
+
+// splitArticles splits page text at article headings. current holds the article number
+// in force, so paragraph markers on later pages are attributed to the right article.
+func splitArticles(text string, current *string) []segment {
+	matches := articlePattern.FindAllStringSubmatchIndex(text, -1)
+	segments := make([]segment, 0, len(matches)+1)
+
+	prev := 0
+	label := ""
+	for _, m := range matches {
+		segments = append(segments, segment{text: text[prev:m[0]], label: label})
+		prev = m[0]
+
+		switch {
+		case m[2] >= 0:
+			*current = text[m[2]:m[3]]
+			if m[4] >= 0 {
+				*current += strings.ToLower(text[m[4]:m[5]])
+			}
+			label = "Art. " + *current
+			if m[6] >= 0 {
+				label += " §" + text[m[6]:m[7]]
+			}
+		case *current != "":
+			label = "Art. " + *current + " §" + text[m[8]:m[9]]
+		default:
+			// A paragraph before any article heading cannot be attributed
+			label = ""
+		}
+	}
+	segments = append(segments, segment{text: text[prev:], label: label})
+
+	return segments
+}
+
+// LocateArticle returns the article or paragraph a retrieved text chunk belongs to, such as
+// "Art. 14 §2": the one in force where the chunk starts, or else the first one starting in it.
+func (idx *Index) LocateArticle(document string, text string) (string, bool) {
+	loc, ok := idx.locate(document, text)
+	if !ok {
+		return "", false
+	}
+
+	label := ""
+	for _, a := range loc.flat.articles {
+		if a.offset > loc.start {
+			if label == "" && a.offset < loc.end {
+				label = a.label
+			}
+			break
+		}
+		label = a.label
+	}
+	return label, label != ""
+}
@@ -0,0 +1,69 @@
+package fulltext_test
+
+import (
+	"fmt"
+
+	"rag/fulltext"
+)
+
+func Example() {
+	idx := fulltext.NewIndex()
+	idx.Add(&fulltext.Document{
+		Name: "100-2022-011302.pdf",
+		Pages: []string{
+			"Collectieve arbeidsovereenkomst betreffende het minimumuurloon",
+			"Het minimumuurloon bedraagt 14,05 EUR voor werklieden van 18 jaar en ouder.",
+		},
+	})
+	idx.Add(&fulltext.Document{
+		Name:  "100-2008-006869.pdf",
+		Pages: []string{"Garantie d'un revenu minimum mensuel moyen pour les ouvriers âgés de 21 ans."},
+	})
+
+	for _, hit := range idx.Search(`minimumuurloon "18 jaar"`, 10) {
+		fmt.Printf("%s p.%d: %s\n", hit.Document, hit.Page, hit.Snippet)
+	}
+	for _, hit := range idx.Search("AGES", 10) {
+		fmt.Printf("%s p.%d: %s\n", hit.Document, hit.Page, hit.Snippet)
+	}
+	// Output:
+	// 100-2022-011302.pdf p.2: Het <mark>minimumuurloon</mark> bedraagt 14,05 EUR voor werklieden van <mark>18</mark> <mark>jaar</mark> en ouder.
+	// 100-2008-006869.pdf p.1: Garantie d&#39;un revenu minimum mensuel moyen pour les ouvriers <mark>âgés</mark> de 21 ans.
+}
+
+func ExampleIndex_LocatePage() {
+	idx := fulltext.NewIndex()
+	idx.Add(&fulltext.Document{
+		Name: "100-2022-011302.pdf",
+		Pages: []string{
+			"Collectieve arbeidsovereenkomst betreffende het minimumuurloon",
+			"Artikel 3\nHet minimumuurloon bedraagt 14,05 EUR voor werklieden van 18 jaar en ouder.",
+		},
+	})
+
+	page, ok := idx.LocatePage("100-2022-011302.pdf", "het MINIMUMUURLOON bedraagt 14,05 EUR voor werklieden")
+	fmt.Println(page, ok)
+	// Output: 2 true
+}
+
+func ExampleIndex_LocateArticle() {
+	idx := fulltext.NewIndex()
+	idx.Add(&fulltext.Document{
+		Name: "100-2022-011302.pdf",
+		Pages: []string{
+			"Artikel 1\nDeze overeenkomst is van toepassing op de werkgevers en werklieden.",
+			"Artikel 14\n§ 1. De eindejaarspremie wordt betaald in december.\n§ 2. Overeenkomstig artikel 3 bedraagt de premie 8,33 procent van het brutoloon.",
+		},
+	})
+
+	for _, chunk := range []string{
+		"de premie 8,33 procent van het brutoloon",
+		"werkgevers en werklieden. Artikel 14",
+	} {
+		article, ok := idx.LocateArticle("100-2022-011302.pdf", chunk)
+		fmt.Println(article, ok)
+	}
+	// Output:
+	// Art. 14 §2 true
+	// Art. 1 true
+}
@@ -0,0 +1,70 @@
+package fulltext
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultLimit is the number of hits returned when no limit is given
+const defaultLimit = 20
+
+// SearchResponse represents the response to a keyword search
+type SearchResponse struct {
+	Query string `json:"query"`
+	Hits  []*Hit `json:"hits"`
+}
+
+// Handler provides the HTTP API for keyword search
+type Handler struct {
+	index *Index
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(index *Index) *Handler {
+	return &Handler{
+		index: index,
+	}
+}
+
+// Search handles GET requests for keyword and phrase search
+// GET /search?q=minimumloon+"21+jaar"&limit=10
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "q query parameter is required",
+		})
+		return
+	}
+
+	limit := defaultLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "limit must be a positive number",
+			})
+			return
+		}
+		limit = n
+	}
+
+	hits := h.index.Search(query, limit)
+	if hits == nil {
+		hits = []*Hit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SearchResponse{
+		Query: query,
+		Hits:  hits,
+	})
+}
@@ -0,0 +1,398 @@
+package fulltext
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"rag/pdftext"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// snippetRadius is the number of characters shown on each side of the first match
+	snippetRadius = 80
+	// probeTerms is the number of consecutive terms used to locate a chunk in a document
+	probeTerms = 8
+)
+
+// Document is a locally extracted document
+type Document struct {
+	Name      string   // Display name, e.g. "100-2021-015127.pdf"
+	SourceURL string   // Official source URL, if known
+	Pages     []string // Text per page
+}
+
+// Hit is a page matching a search query
+type Hit struct {
+	Document  string  `json:"document"`
+	SourceURL string  `json:"sourceUrl,omitempty"`
+	Page      int     `json:"page"`    // 1-based
+	Snippet   string  `json:"snippet"` // HTML-escaped text with matches wrapped in <mark>
+	Score     float64 `json:"score"`
+}
+
+// page is an indexed page with its folded text
+type page struct {
+	doc    *Document
+	number int
+	folded []rune // Same length as the original page runes
+}
+
+// Index is an in-memory keyword index over document pages
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*Document
+	pages    []*page
+	postings map[string]map[int]int // term -> page index -> occurrences
+	flat     map[string]*flatText   // document name -> normalized text used to locate chunks
+}
+
+// flatText is the normalized text of a whole document with the offset where each page
+// and each article starts
+type flatText struct {
+	text       string
+	pageStarts []int
+	articles   []articleStart
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*Document),
+		postings: make(map[string]map[int]int),
+		flat:     make(map[string]*flatText),
+	}
+}
+
+// BuildFromDir extracts and indexes every PDF in a directory. Files that cannot be
+// extracted are logged and skipped.
+func BuildFromDir(dir string) (*Index, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pdf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	idx := NewIndex()
+	for _, path := range paths {
+		doc, err := pdftext.ExtractFile(path)
+		if err != nil {
+			log.Printf("Warning: Failed to extract %s: %v", path, err)
+			continue
+		}
+		idx.Add(&Document{
+			Name:  filepath.Base(path),
+			Pages: doc.Pages,
+		})
+	}
+
+	return idx, nil
+}
+
+// Add indexes a document, replacing an earlier document with the same name
+func (idx *Index) Add(doc *Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.docs[doc.Name]; ok {
+		idx.removeLocked(doc.Name)
+	}
+	idx.docs[doc.Name] = doc
+
+	flat := &flatText{pageStarts: make([]int, 0, len(doc.Pages))}
+	var sb strings.Builder
+	var current string // Article number carried over page breaks
+	for i, text := range doc.Pages {
+		p := &page{doc: doc, number: i + 1, folded: fold(text)}
+		pageIndex := len(idx.pages)
+		idx.pages = append(idx.pages, p)
+
+		terms := tokenize(string(p.folded))
+		flat.pageStarts = append(flat.pageStarts, sb.Len())
+		// Write the page one article at a time to record where each article starts
+		for _, segment := range splitArticles(text, &current) {
+			if segment.label != "" {
+				flat.articles = append(flat.articles, articleStart{offset: sb.Len(), label: segment.label})
+			}
+			for _, term := range tokenize(string(fold(segment.text))) {
+				sb.WriteString(term)
+				sb.WriteByte(' ')
+			}
+		}
+
+		for _, term := range terms {
+			if idx.postings[term] == nil {
+				idx.postings[term] = make(map[int]int)
+			}
+			idx.postings[term][pageIndex]++
+		}
+	}
+	flat.text = sb.String()
+	idx.flat[doc.Name] = flat
+}
+
+// removeLocked drops a document's pages from the postings. Page slots are kept so indices stay valid.
+func (idx *Index) removeLocked(name string) {
+	for i, p := range idx.pages {
+		if p == nil || p.doc.Name != name {
+			continue
+		}
+		for _, term := range tokenize(string(p.folded)) {
+			delete(idx.postings[term], i)
+		}
+		idx.pages[i] = nil
+	}
+	delete(idx.docs, name)
+	delete(idx.flat, name)
+}
+
+// LocatePage returns the 1-based page of a document on which a retrieved text chunk starts.
+// The chunk is matched on its normalized terms, so differences in whitespace, case and
+// diacritics between the local extraction and the chunk do not matter.
+func (idx *Index) LocatePage(document string, text string) (int, bool) {
+	loc, ok := idx.locate(document, text)
+	if !ok {
+		return 0, false
+	}
+
+	page := 1
+	for i, pageStart := range loc.flat.pageStarts {
+		if loc.match >= pageStart {
+			page = i + 1
+		}
+	}
+	return page, true
+}
+
+// location is where a chunk was found in the normalized text of a document
+type location struct {
+	flat       *flatText
+	match      int // Offset of the first probe that matched
+	start, end int // Estimated span of the whole chunk
+}
+
+// locate finds a chunk in the normalized text of a document
+func (idx *Index) locate(document string, text string) (*location, bool) {
+	idx.mu.RLock()
+	flat, ok := idx.flat[document]
+	idx.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	terms := tokenize(string(fold(text)))
+	length := 0
+	for _, term := range terms {
+		length += len(term) + 1
+	}
+
+	// Try probes from the start of the chunk onwards; the first terms may have been cut differently
+	skipped := 0
+	for start := 0; start < len(terms); start += probeTerms {
+		end := min(start+probeTerms, len(terms))
+		probe := strings.Join(terms[start:end], " ") + " "
+		offset := strings.Index(" "+flat.text, " "+probe)
+		if offset >= 0 {
+			chunkStart := max(offset-skipped, 0)
+			return &location{flat: flat, match: offset, start: chunkStart, end: chunkStart + length}, true
+		}
+		skipped += len(probe)
+	}
+
+	return nil, false
+}
+
+// Document returns an indexed document by name
+func (idx *Index) Document(name string) (*Document, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	doc, ok := idx.docs[name]
+	return doc, ok
+}
+
+// Len returns the number of indexed documents
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+// phrasePattern splits a query into quoted phrases and loose terms
+var phrasePattern = regexp.MustCompile(`"([^"]+)"|(\S+)`)
+
+// Search returns the pages containing every term and phrase of the query, best matches first.
+// Phrases are written in double quotes. Matching ignores case and diacritics.
+func (idx *Index) Search(query string, limit int) []*Hit {
+	var terms, phrases []string
+	for _, m := range phrasePattern.FindAllStringSubmatch(query, -1) {
+		if m[1] != "" {
+			if phrase := strings.Join(tokenize(string(fold(m[1]))), " "); phrase != "" {
+				phrases = append(phrases, phrase)
+				terms = append(terms, tokenize(phrase)...)
+			}
+			continue
+		}
+		terms = append(terms, tokenize(string(fold(m[2])))...)
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Intersect the postings of all terms, summing occurrences as the score
+	scores := make(map[int]float64)
+	for i, term := range terms {
+		postings := idx.postings[term]
+		if i == 0 {
+			for pageIndex, n := range postings {
+				scores[pageIndex] = float64(n)
+			}
+			continue
+		}
+		for pageIndex := range scores {
+			n, ok := postings[pageIndex]
+			if !ok {
+				delete(scores, pageIndex)
+				continue
+			}
+			scores[pageIndex] += float64(n)
+		}
+	}
+
+	hits := make([]*Hit, 0, len(scores))
+	for pageIndex, score := range scores {
+		p := idx.pages[pageIndex]
+		normalized := strings.Join(tokenize(string(p.folded)), " ")
+		if !containsAll(normalized, phrases) {
+			continue
+		}
+		hits = append(hits, &Hit{
+			Document:  p.doc.Name,
+			SourceURL: p.doc.SourceURL,
+			Page:      p.number,
+			Snippet:   snippet(p, terms),
+			Score:     score,
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Document != hits[j].Document {
+			return hits[i].Document < hits[j].Document
+		}
+		return hits[i].Page < hits[j].Page
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits
+}
+
+func containsAll(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if !strings.Contains(" "+text+" ", " "+phrase+" ") {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet returns an HTML snippet around the first match with all matches highlighted
+func snippet(p *page, terms []string) string {
+	original := []rune(p.doc.Pages[p.number-1])
+	folded := p.folded
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(folded); i++ {
+			if runesEqual(folded[i:i+len(t)], t) && isBoundary(folded, i-1) && isBoundary(folded, i+len(t)) {
+				spans = append(spans, span{i, i + len(t)})
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return ""
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	from := max(spans[0].start-snippetRadius, 0)
+	to := min(spans[0].end+snippetRadius, len(original))
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.start < pos || s.end > to {
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(original[pos:s.start])))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(string(original[s.start:s.end])))
+		sb.WriteString("</mark>")
+		pos = s.end
+	}
+	sb.WriteString(html.EscapeString(string(original[pos:to])))
+	if to < len(original) {
+		sb.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isBoundary(text []rune, i int) bool {
+	return i < 0 || i >= len(text) || !isWordRune(text[i])
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// fold lowercases text and strips diacritics rune by rune, so positions in the folded
+// text match positions in the original
+func fold(text string) []rune {
+	runes := []rune(text)
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		r = unicode.ToLower(r)
+		if r >= unicode.MaxASCII {
+			// Decompose and keep the base letter, e.g. "é" becomes "e"
+			if decomposed := []rune(norm.NFD.String(string(r))); len(decomposed) > 0 {
+				r = decomposed[0]
+			}
+		}
+		folded[i] = r
+	}
+	return folded
+}
+
+// tokenize splits folded text into terms
+func tokenize(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) })
+}
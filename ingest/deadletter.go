@@ -0,0 +1,256 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+	"rag/validity"
+)
+
+// Failure stages
+const (
+	StageDownload = "download"
+	StageUpload   = "upload"
+	StageQuality  = "quality" // Rejected by a quality check; not retried, as the content would be rejected again
+)
+
+// Failure is a document that could not be ingested
+type Failure struct {
+	ID          string    `json:"id"`
+	Stage       string    `json:"stage"` // StageDownload, StageUpload or StageQuality
+	Name        string    `json:"name"`
+	Store       string    `json:"store"`
+	SourceURL   string    `json:"sourceUrl,omitempty"`
+	Path        string    `json:"path,omitempty"` // Local file the document was read from
+	Error       string    `json:"error"`
+	Attempts    int       `json:"attempts"`
+	FailedAt    time.Time `json:"failedAt"`
+	Payload     bool      `json:"payload"`               // Whether the document content was kept for retry
+	AccessLabel string    `json:"accessLabel,omitempty"` // Access label to upload the document with
+}
+
+// DeadLetters persists failed ingestions so they can be inspected and retried.
+// Failures are kept in failed.json in the directory; document contents that cannot
+// be fetched again are kept next to it.
+type DeadLetters struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenDeadLetters opens, or creates, a dead-letter directory
+func OpenDeadLetters(dir string) (*DeadLetters, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "payloads"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	return &DeadLetters{dir: dir}, nil
+}
+
+// failureID identifies a document in a store, so repeated failures update one entry
+func failureID(store, name string) string {
+	sum := sha256.Sum256([]byte(store + "\x00" + name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Record stores a failure. payload, when not nil, is the document content kept for retry.
+// A failure for a document that is already recorded replaces it and counts as another attempt.
+func (d *DeadLetters) Record(f *Failure, payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	failures, err := d.load()
+	if err != nil {
+		return err
+	}
+
+	f.ID = failureID(f.Store, f.Name)
+	f.Attempts = 1
+	if f.FailedAt.IsZero() {
+		f.FailedAt = time.Now()
+	}
+	if prev, ok := failures[f.ID]; ok {
+		f.Attempts = prev.Attempts + 1
+		f.Payload = f.Payload || prev.Payload
+	}
+
+	if payload != nil {
+		if err := os.WriteFile(d.payloadPath(f.ID), payload, 0o644); err != nil {
+			return fmt.Errorf("failed to write payload: %w", err)
+		}
+		f.Payload = true
+	}
+
+	failures[f.ID] = f
+	return d.save(failures)
+}
+
+// List returns the recorded failures, most recent first
+func (d *DeadLetters) List() ([]*Failure, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	failures, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Failure, 0, len(failures))
+	for _, f := range failures {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].FailedAt.After(list[j].FailedAt) })
+	return list, nil
+}
+
+// Remove deletes a failure and its payload
+func (d *DeadLetters) Remove(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	failures, err := d.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := failures[id]; !ok {
+		return fmt.Errorf("failure %q not found", id)
+	}
+
+	delete(failures, id)
+	os.Remove(d.payloadPath(id))
+	return d.save(failures)
+}
+
+func (d *DeadLetters) payloadPath(id string) string {
+	return filepath.Join(d.dir, "payloads", id)
+}
+
+func (d *DeadLetters) load() (map[string]*Failure, error) {
+	failures := make(map[string]*Failure)
+
+	data, err := os.ReadFile(filepath.Join(d.dir, "failed.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return failures, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+
+	var list []*Failure
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letters: %w", err)
+	}
+	for _, f := range list {
+		failures[f.ID] = f
+	}
+	return failures, nil
+}
+
+// save writes the failures atomically so a crash never leaves a truncated file
+func (d *DeadLetters) save(failures map[string]*Failure) error {
+	list := make([]*Failure, 0, len(failures))
+	for _, f := range failures {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letters: %w", err)
+	}
+
+	path := filepath.Join(d.dir, "failed.json")
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write dead letters: %w", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Retry reprocesses every recorded failure. Documents are read from their kept payload,
+// their local path or their source URL, in that order. Failures that succeed are removed;
+// the others are recorded again with the new error. Rejected documents are skipped; remove
+// them once their source is fixed and ingest them again.
+func (d *DeadLetters) Retry(ctx context.Context, service *filesearch.Service, fetch Fetcher) (*Report, error) {
+	failures, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	for _, f := range failures {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if f.Stage == StageQuality {
+			report.Skipped++
+			continue
+		}
+
+		stage, err := d.retry(ctx, service, fetch, f)
+		if err != nil {
+			log.Printf("Warning: Retry of %s failed: %v", f.Name, err)
+			f.Stage = stage
+			f.Error = err.Error()
+			f.FailedAt = time.Now()
+			if err := d.Record(f, nil); err != nil {
+				return report, err
+			}
+			report.Failed++
+			continue
+		}
+
+		if err := d.Remove(f.ID); err != nil {
+			return report, err
+		}
+		report.Uploaded++
+	}
+
+	return report, nil
+}
+
+func (d *DeadLetters) retry(ctx context.Context, service *filesearch.Service, fetch Fetcher, f *Failure) (string, error) {
+	var reader io.Reader
+	switch {
+	case f.Payload:
+		data, err := os.ReadFile(d.payloadPath(f.ID))
+		if err != nil {
+			return StageUpload, fmt.Errorf("failed to read payload: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	case f.Path != "":
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return StageDownload, err
+		}
+		reader = bytes.NewReader(data)
+	case f.SourceURL != "" && fetch != nil:
+		body, err := fetch(f.SourceURL)
+		if err != nil {
+			return StageDownload, err
+		}
+		reader = body
+	default:
+		return f.Stage, fmt.Errorf("document content is not available")
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return StageDownload, err
+	}
+	metadata := append(validity.Metadata(data), filesearch.AccessMetadata(f.AccessLabel)...)
+	if _, err := service.UploadDocumentWithMetadata(ctx, bytes.NewReader(data), f.Name, f.Store, f.SourceURL, metadata); err != nil {
+		return StageUpload, err
+	}
+	return "", nil
+}
@@ -0,0 +1,194 @@
+package ingest_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"rag/filesearch"
+	"rag/ingest"
+)
+
+func ExampleNewStream() {
+	input := `{"name": "a.pdf", "base64": "JVBERi0xLjQ="}
+{"name": "b.pdf", "base64": "JVBERi0xLjc="}
+`
+
+	stream, err := ingest.NewStream(strings.NewReader(input), ingest.FormatAuto, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for {
+		entry, err := stream.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, _ := io.ReadAll(entry.Body)
+		fmt.Printf("%s: %s\n", entry.Name, data)
+	}
+	// Output:
+	// a.pdf: %PDF-1.4
+	// b.pdf: %PDF-1.7
+}
+
+func ExampleScheduler() {
+	sched := ingest.NewScheduler(ingest.Quota{PerMinute: 2, PerDay: 1000})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := sched.Wait(ctx); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// The third upload within a minute has to wait
+	fmt.Println(sched.Delay() > 50*time.Second)
+	// Output: true
+}
+
+func ExampleDeadLetters() {
+	dir, err := os.MkdirTemp("", "dead-letters")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	deadLetters, err := ingest.OpenDeadLetters(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// A second failure of the same document counts as another attempt
+	for i := 0; i < 2; i++ {
+		deadLetters.Record(&ingest.Failure{
+			Stage:     ingest.StageDownload,
+			Name:      "100-2022-011302.pdf",
+			Store:     "fileSearchStores/cao-documents",
+			SourceURL: "https://example.com/100-2022-011302.pdf",
+			Error:     "unexpected status code: 503",
+		}, nil)
+	}
+
+	failures, err := deadLetters.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, f := range failures {
+		fmt.Printf("%s %s: %s (%d attempts)\n", f.Stage, f.Name, f.Error, f.Attempts)
+	}
+	// Output: download 100-2022-011302.pdf: unexpected status code: 503 (2 attempts)
+}
+
+func ExampleChecker() {
+	agreement, err := os.ReadFile("../documents/100-2023-014786.pdf")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	checker := ingest.NewChecker(ingest.QualityOptions{Flag: []string{ingest.IssueDuplicate}})
+	documents := []struct {
+		name string
+		data []byte
+	}{
+		{"100-2023-014786.pdf", agreement},
+		{"100-2023-014786-1.pdf", agreement},
+		{"blank.pdf", nil},
+		{"locked.pdf", []byte("%PDF-1.6\ntrailer << /Encrypt 12 0 R >>\n%%EOF")},
+	}
+	for _, doc := range documents {
+		if issue := checker.Check(doc.name, doc.data); issue != nil {
+			fmt.Printf("%s: %s, rejected %t: %s\n", doc.name, issue.Issue, issue.Rejected, issue.Reason)
+			continue
+		}
+		fmt.Printf("%s: ok\n", doc.name)
+	}
+	// Output:
+	// 100-2023-014786.pdf: ok
+	// 100-2023-014786-1.pdf: duplicate, rejected false: same content as 100-2023-014786.pdf
+	// blank.pdf: empty, rejected true: the document has no content
+	// locked.pdf: encrypted, rejected true: the PDF is password-protected
+}
+
+// offline fails every API call, as if Gemini could not be reached
+type offline struct{}
+
+func (offline) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("offline")
+}
+
+func ExampleHandler_JobEvents() {
+	service, err := filesearch.NewService(context.Background(), &filesearch.Config{
+		APIKey:     "offline",
+		HTTPClient: &http.Client{Transport: offline{}},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	jobs := ingest.NewJobs(service, &ingest.JobOptions{Quality: &ingest.QualityOptions{}})
+	handler := ingest.NewHandler(nil, jobs)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs/{id}/events", handler.JobEvents)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	locked := base64.StdEncoding.EncodeToString([]byte("%PDF-1.6\ntrailer << /Encrypt 12 0 R >>\n%%EOF"))
+	input := `{"name": "locked.pdf", "base64": "` + locked + `"}
+{"name": "../escape.pdf", "base64": "JVBERi0xLjQ="}
+{"name": "100-2022-011302.pdf", "base64": "JVBERi0xLjQKMSAwIG9iago8PD4+CmVuZG9iagolJUVPRg=="}
+`
+	job, err := jobs.Start(context.Background(), "fileSearchStores/cao-documents", strings.NewReader(input), ingest.FormatNDJSON, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Follow the progress of the job until it ends
+	resp, err := http.Get(server.URL + "/jobs/" + job.ID + "/events")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var name string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n, ok := strings.CutPrefix(line, "event: "); ok {
+			name = n
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "document":
+			var event ingest.Event
+			json.Unmarshal([]byte(data), &event)
+			fmt.Printf("%s %q\n", event.Status, event.Document)
+		case "done":
+			var status ingest.JobStatus
+			json.Unmarshal([]byte(data), &status)
+			fmt.Printf("%s: %d documents, %d rejected, %d failed\n", status.State, status.Documents, status.Report.Rejected, status.Report.Failed)
+		}
+	}
+	// Output:
+	// rejected "locked.pdf"
+	// failed ""
+	// failed "100-2022-011302.pdf"
+	// done: 3 documents, 1 rejected, 2 failed
+}
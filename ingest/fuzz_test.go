@@ -0,0 +1,54 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"rag/ingest"
+)
+
+func FuzzNDJSONStream(f *testing.F) {
+	f.Add(`{"name": "a.pdf", "base64": "JVBERi0xLjQ="}`)
+	f.Add("{\"name\": \"a.pdf\", \"base64\": \"JVBERi0xLjQ=\"}\n\n{\"url\": \"https://example.com/b.pdf\"}\n")
+	f.Add(`{"name": "a.pdf", "base64": "not base64!"}`)
+	f.Add(`{"name": "../../etc/passwd", "base64": "AA=="}`)
+	f.Add(`{"name": "a.pdf", "url": "file:///etc/passwd"}`)
+	f.Add(`{"name": "a.pdf"} trailing`)
+	f.Add("{\"name\": \"\xff.pdf\", \"base64\": \"AA==\"}")
+	f.Add(`{"name": 1}` + "\n" + `not json`)
+
+	fetch := func(url string) (io.Reader, error) {
+		return strings.NewReader("%PDF"), nil
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		stream := ingest.NewNDJSONStream(strings.NewReader(input), fetch)
+		ctx := context.Background()
+
+		// Every line yields at most one entry or error, so the stream must end
+		for i := 0; i <= strings.Count(input, "\n")+1; i++ {
+			entry, err := stream.Next(ctx)
+			if err == io.EOF {
+				return
+			}
+			var recErr *ingest.RecordError
+			if errors.As(err, &recErr) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if entry.Name == "" || !utf8.ValidString(entry.Name) || strings.ContainsAny(entry.Name, "/\\") {
+				t.Fatalf("accepted name %q", entry.Name)
+			}
+			if _, err := io.ReadAll(entry.Body); err != nil {
+				t.Fatalf("failed to read %s: %v", entry.Name, err)
+			}
+		}
+		t.Fatalf("stream did not end")
+	})
+}
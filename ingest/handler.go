@@ -0,0 +1,161 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeepAliveInterval is how often an idle event stream sends a comment, so proxies keep the
+// connection open while a document waits for the upload quota
+const KeepAliveInterval = 15 * time.Second
+
+// FailedJobsResponse represents the response listing failed ingestions
+type FailedJobsResponse struct {
+	Failures []*Failure `json:"failures"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// JobResponse represents the response describing an ingestion job
+type JobResponse struct {
+	Job   *JobStatus `json:"job,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// Handler provides the HTTP admin API for ingestion jobs
+type Handler struct {
+	deadLetters *DeadLetters
+	jobs        *Jobs
+}
+
+// NewHandler creates a new HTTP handler. Either may be nil when its endpoints are not served.
+func NewHandler(deadLetters *DeadLetters, jobs *Jobs) *Handler {
+	return &Handler{
+		deadLetters: deadLetters,
+		jobs:        jobs,
+	}
+}
+
+// FailedJobs handles GET requests listing the dead-letter queue
+// GET /admin/jobs/failed
+func (h *Handler) FailedJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	failures, err := h.deadLetters.List()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(FailedJobsResponse{Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(FailedJobsResponse{Failures: failures})
+}
+
+// StartJob handles POST requests starting an ingestion job from the stream in the body, a
+// tar archive or NDJSON records as read by cao ingest. It answers 202 with the job, whose
+// progress GET /jobs/{id} and GET /jobs/{id}/events report.
+// POST /jobs?storeName=NAME&format=auto&label=LABEL
+func (h *Handler) StartJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	storeName, format := query.Get("storeName"), query.Get("format")
+	switch {
+	case storeName == "":
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(JobResponse{Error: "storeName is required"})
+		return
+	case format != "" && format != FormatAuto && format != FormatTar && format != FormatNDJSON:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(JobResponse{Error: fmt.Sprintf("unsupported stream format %q", format)})
+		return
+	}
+
+	store, err := h.jobs.service.GetStoreByName(r.Context(), storeName)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(JobResponse{Error: "Store not found: " + err.Error()})
+		return
+	}
+
+	job, err := h.jobs.Start(r.Context(), store.Name, r.Body, format, query.Get("label"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(JobResponse{Error: err.Error()})
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(JobResponse{Job: job.Status()})
+}
+
+// Job handles GET requests for the status of an ingestion job
+// GET /jobs/{id}
+func (h *Handler) Job(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	job, ok := h.jobs.Get(r.PathValue("id"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(JobResponse{Error: "Job not found"})
+		return
+	}
+	json.NewEncoder(w).Encode(JobResponse{Job: job.Status()})
+}
+
+// JobEvents handles GET requests streaming the progress of an ingestion job as server-sent
+// events: a "document" event with an Event for every document, and a "done" event with the
+// final JobStatus. Document events are numbered, so a client reconnecting with
+// Last-Event-ID only receives the events it missed.
+// GET /jobs/{id}/events
+func (h *Handler) JobEvents(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Get(r.PathValue("id"))
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(JobResponse{Error: "Job not found"})
+		return
+	}
+	from, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	from = max(from, 0)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	for {
+		ctx, cancel := context.WithTimeout(r.Context(), KeepAliveInterval)
+		events, ended, err := job.Events(ctx, from)
+		cancel()
+		switch {
+		case errors.Is(err, context.DeadlineExceeded) && r.Context().Err() == nil:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case err != nil:
+			return
+		}
+
+		for _, event := range events {
+			from++
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "id: %d\nevent: document\ndata: %s\n\n", from, data)
+		}
+		if ended {
+			data, _ := json.Marshal(job.Status())
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+		}
+		if err := rc.Flush(); err != nil || ended {
+			return
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package ingest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+)
+
+// States of a Job
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed" // The stream could not be read to the end
+)
+
+// MaxFinishedJobs is the number of finished jobs kept for their status and events
+const MaxFinishedJobs = 100
+
+// JobOptions configures the jobs of a Jobs runner
+type JobOptions struct {
+	Scheduler   *Scheduler      // Spreads the uploads of all jobs over a quota
+	DeadLetters *DeadLetters    // Records failed and rejected documents
+	Quality     *QualityOptions // Checks the documents of every job with a checker of its own, nil for no checks
+	Fetch       Fetcher         // Downloads the URLs of NDJSON records; without it, such records fail the job
+	Dir         string          // Where request bodies are kept until their job ends, defaults to the temp directory
+}
+
+// Jobs runs ingestions in the background, so a request can start one and follow its
+// progress. It is safe for concurrent use.
+type Jobs struct {
+	service *filesearch.Service
+	opts    JobOptions
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	done []string // IDs of finished jobs, oldest first
+}
+
+// NewJobs creates a runner uploading to the stores of service. opts may be nil.
+func NewJobs(service *filesearch.Service, opts *JobOptions) *Jobs {
+	j := &Jobs{service: service, jobs: make(map[string]*Job)}
+	if opts != nil {
+		j.opts = *opts
+	}
+	return j
+}
+
+// Job is an ingestion or store rename running in the background
+type Job struct {
+	ID        string
+	Store     string // Resource name of the store
+	StartedAt time.Time
+
+	mu         sync.Mutex
+	state      string
+	finishedAt time.Time
+	report     *Report
+	renamedTo  string
+	err        error
+	events     []*Event
+	changed    chan struct{} // Closed and replaced when an event is added or the job ends
+}
+
+// JobStatus is the state of a job at a point in time
+type JobStatus struct {
+	ID         string     `json:"id"`
+	Store      string     `json:"store"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Documents  int        `json:"documents"`           // Documents processed so far
+	Report     *Report    `json:"report,omitempty"`    // Set when an ingestion ended
+	RenamedTo  string     `json:"renamedTo,omitempty"` // Resource name of the store once renamed, see Jobs.Rename
+	Error      string     `json:"error,omitempty"`
+}
+
+// Start reads a stream of the given format (see NewStream) from r into a file and uploads its
+// documents to a store in the background. The job keeps running after the request that
+// started it ends, and ends once the whole stream is read.
+func (j *Jobs) Start(ctx context.Context, storeName string, r io.Reader, format string, accessLabel string) (*Job, error) {
+	switch format {
+	case FormatAuto, FormatTar, FormatNDJSON, "":
+	default:
+		return nil, fmt.Errorf("unsupported stream format %q", format)
+	}
+
+	// Keep the body, as the request ends before the job does
+	spool, err := os.CreateTemp(j.opts.Dir, "ingest-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job input: %w", err)
+	}
+	if _, err := io.Copy(spool, r); err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to read job input: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to read job input: %w", err)
+	}
+
+	job := j.newJob(storeName)
+	go func() {
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+
+		report, err := j.run(context.WithoutCancel(ctx), job, spool, format, accessLabel)
+		job.finish(report, err)
+		j.finished(job)
+	}()
+	return job, nil
+}
+
+// Rename gives a store, by resource name, a new display name in the background, see
+// filesearch.Service.UpdateStore. As every document is uploaded again, this takes as long as
+// ingesting the store. The job reports the resource name of the new store in RenamedTo.
+func (j *Jobs) Rename(ctx context.Context, storeName string, displayName string) *Job {
+	job := j.newJob(storeName)
+	go func() {
+		renamed, err := j.service.UpdateStore(context.WithoutCancel(ctx), storeName, &filesearch.StoreUpdate{DisplayName: displayName})
+		if err == nil {
+			job.mu.Lock()
+			job.renamedTo = renamed.Name
+			job.mu.Unlock()
+		}
+		job.finish(nil, err)
+		j.finished(job)
+	}()
+	return job
+}
+
+// newJob registers a running job on a store
+func (j *Jobs) newJob(storeName string) *Job {
+	id := make([]byte, 8)
+	rand.Read(id)
+	job := &Job{
+		ID:        hex.EncodeToString(id),
+		Store:     storeName,
+		StartedAt: time.Now(),
+		state:     JobRunning,
+		changed:   make(chan struct{}),
+	}
+	j.mu.Lock()
+	j.jobs[job.ID] = job
+	j.mu.Unlock()
+	return job
+}
+
+// run uploads the documents of a job
+func (j *Jobs) run(ctx context.Context, job *Job, r io.Reader, format string, accessLabel string) (*Report, error) {
+	stream, err := NewStream(r, format, j.opts.Fetch)
+	if err != nil {
+		return nil, err
+	}
+	opts := &Options{
+		Scheduler:   j.opts.Scheduler,
+		DeadLetters: j.opts.DeadLetters,
+		AccessLabel: accessLabel,
+		Progress:    job.add,
+	}
+	if j.opts.Quality != nil {
+		opts.Quality = NewChecker(*j.opts.Quality)
+	}
+	return Upload(ctx, j.service, job.Store, stream, opts)
+}
+
+// finished forgets the oldest finished jobs beyond MaxFinishedJobs
+func (j *Jobs) finished(job *Job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.done = append(j.done, job.ID)
+	for len(j.done) > MaxFinishedJobs {
+		delete(j.jobs, j.done[0])
+		j.done = j.done[1:]
+	}
+}
+
+// Get returns a running or recently finished job
+func (j *Jobs) Get(id string) (*Job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	return job, ok
+}
+
+// add records the progress of a document
+func (job *Job) add(event *Event) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.events = append(job.events, event)
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+// finish records the end of the job
+func (job *Job) finish(report *Report, err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	job.state, job.report, job.err, job.finishedAt = JobDone, report, err, time.Now()
+	if err != nil {
+		job.state = JobFailed
+		log.Printf("Warning: Job %s failed: %v", job.ID, err)
+	}
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+// Status returns the state of the job
+func (job *Job) Status() *JobStatus {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	status := &JobStatus{
+		ID:        job.ID,
+		Store:     job.Store,
+		State:     job.state,
+		StartedAt: job.StartedAt,
+		Documents: len(job.events),
+		Report:    job.report,
+		RenamedTo: job.renamedTo,
+	}
+	if job.state != JobRunning {
+		status.FinishedAt = &job.finishedAt
+	}
+	if job.err != nil {
+		status.Error = job.err.Error()
+	}
+	return status
+}
+
+// Events returns the events of the job after the first from, waiting until there is one or
+// the job has ended. It reports whether the job has ended and all its events are returned,
+// and returns the context error when ctx is done first.
+func (job *Job) Events(ctx context.Context, from int) ([]*Event, bool, error) {
+	for {
+		job.mu.Lock()
+		events := job.events[min(from, len(job.events)):]
+		ended := job.state != JobRunning
+		changed := job.changed
+		job.mu.Unlock()
+
+		if len(events) > 0 || ended {
+			return events, ended, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-changed:
+		}
+	}
+}
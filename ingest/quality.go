@@ -0,0 +1,175 @@
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"rag/filesearch"
+	"rag/pdftext"
+)
+
+// Quality issues found by a Checker
+const (
+	IssueEmpty     = "empty"     // No content, or a PDF without text or images
+	IssueScanned   = "scanned"   // A PDF of page images without a text layer, so nothing can be retrieved from it
+	IssueEncrypted = "encrypted" // A password-protected PDF whose text cannot be read
+	IssueDuplicate = "duplicate" // The same content as another document of the store
+)
+
+// DefaultMinCharsPerPage is the text per page below which a PDF counts as having no text
+const DefaultMinCharsPerPage = 20
+
+// MetadataQualityIssue is the custom metadata key of the issue a flagged document was uploaded with
+const MetadataQualityIssue = "quality_issue"
+
+// QualityIssue is a problem found with a document before upload
+type QualityIssue struct {
+	Name     string `json:"name"`
+	Issue    string `json:"issue"`
+	Reason   string `json:"reason"`
+	Rejected bool   `json:"rejected"` // Not uploaded; flagged documents are uploaded with MetadataQualityIssue
+}
+
+func (i *QualityIssue) Error() string {
+	return fmt.Sprintf("%s: %s", i.Name, i.Reason)
+}
+
+// QualityOptions configures a Checker. The zero value rejects every issue.
+type QualityOptions struct {
+	Flag            []string `yaml:"flag"`            // Issues to upload anyway, with the issue recorded as metadata
+	MinCharsPerPage int      `yaml:"minCharsPerPage"` // Defaults to DefaultMinCharsPerPage
+}
+
+// Issues lists the quality issues a Checker finds
+var Issues = []string{IssueEmpty, IssueScanned, IssueEncrypted, IssueDuplicate}
+
+// Validate checks that only known issues are flagged
+func (o QualityOptions) Validate() error {
+	for _, issue := range o.Flag {
+		if !slices.Contains(Issues, issue) {
+			return fmt.Errorf("unknown quality issue %q, expected one of %s", issue, strings.Join(Issues, ", "))
+		}
+	}
+	if o.MinCharsPerPage < 0 {
+		return errors.New("minCharsPerPage must not be negative")
+	}
+	return nil
+}
+
+// Checker checks documents before upload. It remembers the content of the documents it
+// passed, and of those already in the store, to find duplicates under another name.
+type Checker struct {
+	opts QualityOptions
+
+	mu     sync.Mutex
+	hashes map[string]string // content hash -> document name
+}
+
+// NewChecker creates a checker
+func NewChecker(opts QualityOptions) *Checker {
+	if opts.MinCharsPerPage <= 0 {
+		opts.MinCharsPerPage = DefaultMinCharsPerPage
+	}
+	return &Checker{opts: opts, hashes: make(map[string]string)}
+}
+
+// ContentHash returns the hash of a document's content, recorded with uploads as
+// filesearch.MetadataContentHash
+func ContentHash(data []byte) string {
+	return filesearch.ContentHash(data)
+}
+
+// Add records a document already in the store by its content hash
+func (c *Checker) Add(name, hash string) {
+	if hash == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.hashes[hash]; !ok {
+		c.hashes[hash] = name
+	}
+}
+
+// AddDocuments records the documents of a store uploaded with a content hash
+func (c *Checker) AddDocuments(docs []*filesearch.Document) {
+	for _, doc := range docs {
+		c.Add(doc.DisplayName, doc.CustomMetadata[filesearch.MetadataContentHash])
+	}
+}
+
+// Check returns the issue with a document, or nil when it has none. Documents without an
+// issue, or with one that is only flagged, count as uploaded for finding duplicates.
+func (c *Checker) Check(name string, data []byte) *QualityIssue {
+	issue, reason := c.inspect(data)
+	hash := ContentHash(data)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if issue == "" {
+		if other, ok := c.hashes[hash]; ok && other != name {
+			issue, reason = IssueDuplicate, "same content as "+other
+		}
+	}
+	if issue == "" {
+		c.hashes[hash] = name
+		return nil
+	}
+
+	flagged := slices.Contains(c.opts.Flag, issue)
+	if flagged {
+		if _, ok := c.hashes[hash]; !ok {
+			c.hashes[hash] = name
+		}
+	}
+	return &QualityIssue{Name: name, Issue: issue, Reason: reason, Rejected: !flagged}
+}
+
+// inspect returns the issue with the content of a document, other than being a duplicate
+func (c *Checker) inspect(data []byte) (issue, reason string) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return IssueEmpty, "the document has no content"
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", ""
+	}
+
+	encrypted := bytes.Contains(data, []byte("/Encrypt"))
+	doc, err := pdftext.Extract(data)
+	switch {
+	case err != nil && encrypted:
+		return IssueEncrypted, "the PDF is password-protected"
+	case err != nil:
+		// Leave unparseable PDFs to File Search, which reads more of them
+		return "", ""
+	case len(doc.Pages) == 0:
+		return IssueEmpty, "the PDF has no pages"
+	}
+
+	chars := 0
+	for _, page := range doc.Pages {
+		for _, r := range page {
+			if !unicode.IsSpace(r) {
+				chars++
+			}
+		}
+	}
+	if chars >= c.opts.MinCharsPerPage*len(doc.Pages) {
+		return "", ""
+	}
+	switch {
+	case encrypted:
+		return IssueEncrypted, "the PDF is password-protected"
+	case bytes.Contains(data, []byte("/Image")):
+		return IssueScanned, fmt.Sprintf("the PDF has %d pages of images without text, run OCR before uploading", len(doc.Pages))
+	default:
+		return IssueEmpty, "the PDF has no text"
+	}
+}
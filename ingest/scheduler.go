@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Quota is an upload budget. Zero values are unlimited.
+type Quota struct {
+	PerMinute int `yaml:"perMinute"`
+	PerDay    int `yaml:"perDay"`
+}
+
+// Scheduler spreads uploads over time so they stay within a quota.
+// Uploads are counted over sliding windows of one minute and 24 hours.
+type Scheduler struct {
+	quota Quota
+	now   func() time.Time
+
+	mu   sync.Mutex
+	used []time.Time // Upload times within the last 24 hours, oldest first
+}
+
+// NewScheduler creates a scheduler for the given quota
+func NewScheduler(quota Quota) *Scheduler {
+	return &Scheduler{
+		quota: quota,
+		now:   time.Now,
+	}
+}
+
+// Delay returns how long the next upload has to wait, zero when it can start now
+func (s *Scheduler) Delay() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.delayLocked(s.now())
+}
+
+func (s *Scheduler) delayLocked(now time.Time) time.Duration {
+	// Forget uploads that left the daily window
+	dayAgo := now.Add(-24 * time.Hour)
+	for len(s.used) > 0 && !s.used[0].After(dayAgo) {
+		s.used = s.used[1:]
+	}
+
+	var delay time.Duration
+	if q := s.quota.PerDay; q > 0 && len(s.used) >= q {
+		delay = max(delay, s.used[len(s.used)-q].Add(24*time.Hour).Sub(now))
+	}
+	if q := s.quota.PerMinute; q > 0 && len(s.used) >= q {
+		delay = max(delay, s.used[len(s.used)-q].Add(time.Minute).Sub(now))
+	}
+	return max(delay, 0)
+}
+
+// Wait blocks until the quota allows another upload and records it.
+// It returns early with the context error when ctx is cancelled.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		now := s.now()
+		delay := s.delayLocked(now)
+		if delay == 0 {
+			s.used = append(s.used, now)
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+
+		if delay > time.Minute {
+			log.Printf("Upload quota reached, pausing until %s", now.Add(delay).Format(time.DateTime))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
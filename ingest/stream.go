@@ -0,0 +1,461 @@
+// Package ingest reads documents from streams and uploads them to a File Search store.
+package ingest
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"rag/filesearch"
+	"rag/validity"
+
+	"google.golang.org/genai"
+)
+
+// Stream formats
+const (
+	FormatAuto   = "auto"
+	FormatTar    = "tar"
+	FormatNDJSON = "ndjson"
+)
+
+// Entry is a document read from a stream
+type Entry struct {
+	Name      string
+	SourceURL string
+	Body      io.Reader // Valid until the next call to Next
+}
+
+// Stream yields the documents of an input stream one at a time.
+// Next returns io.EOF when the stream is exhausted.
+type Stream interface {
+	Next(ctx context.Context) (*Entry, error)
+}
+
+// Fetcher downloads a document referenced by URL in an NDJSON stream
+type Fetcher func(url string) (io.Reader, error)
+
+// NewStream creates a stream of the given format. FormatAuto detects a tar archive by
+// its header and falls back to NDJSON. fetch may be nil when records carry no URLs without content.
+func NewStream(r io.Reader, format string, fetch Fetcher) (Stream, error) {
+	switch format {
+	case FormatTar:
+		return NewTarStream(r), nil
+	case FormatNDJSON:
+		return NewNDJSONStream(r, fetch), nil
+	case FormatAuto, "":
+		br := bufio.NewReaderSize(r, 512)
+		if isTar(br) {
+			return NewTarStream(br), nil
+		}
+		return NewNDJSONStream(br, fetch), nil
+	}
+
+	return nil, fmt.Errorf("unsupported stream format %q", format)
+}
+
+// isTar reports whether the buffered input starts with a POSIX tar header
+func isTar(br *bufio.Reader) bool {
+	header, _ := br.Peek(512)
+	return len(header) == 512 && bytes.HasPrefix(header[257:], []byte("ustar"))
+}
+
+type tarStream struct {
+	tr *tar.Reader
+}
+
+// NewTarStream reads the regular files of a tar archive. The base name of each file is used as document name.
+func NewTarStream(r io.Reader) Stream {
+	return &tarStream{tr: tar.NewReader(r)}
+}
+
+func (s *tarStream) Next(ctx context.Context) (*Entry, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		header, err := s.tr.Next()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar stream: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		return &Entry{
+			Name: path.Base(header.Name),
+			Body: s.tr,
+		}, nil
+	}
+}
+
+// Record is one line of an NDJSON stream. Either URL or Base64 must be set;
+// when both are, the content is taken from Base64 and URL is kept as source URL.
+type Record struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+}
+
+// Limits enforced on NDJSON records
+const (
+	MaxRecordBytes = 64 << 20 // Size of one line, including the base64 content
+	MaxNameLength  = 255      // Bytes in a document name
+)
+
+// RecordError reports an NDJSON line that is not a valid Record. The stream skips it
+// and continues with the next line.
+type RecordError struct {
+	Line    int
+	Field   string
+	Message string
+}
+
+func (e *RecordError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("record %d: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("record %d: %s: %s", e.Line, e.Field, e.Message)
+}
+
+// Validate checks the record fields and returns a *RecordError for the first invalid one
+func (rec *Record) Validate() error {
+	if !utf8.ValidString(rec.Name) {
+		return &RecordError{Field: "name", Message: "is not valid UTF-8"}
+	}
+	if len(rec.Name) > MaxNameLength {
+		return &RecordError{Field: "name", Message: fmt.Sprintf("exceeds %d bytes", MaxNameLength)}
+	}
+	if rec.Name == "." || rec.Name == ".." || strings.ContainsAny(rec.Name, "/\\") || strings.ContainsFunc(rec.Name, unicode.IsControl) {
+		return &RecordError{Field: "name", Message: "must not contain path separators or control characters"}
+	}
+	if rec.URL != "" {
+		u, err := url.Parse(rec.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &RecordError{Field: "url", Message: "must be an absolute http or https URL"}
+		}
+	}
+	if rec.Name == "" && rec.URL == "" {
+		return &RecordError{Field: "name", Message: "is required"}
+	}
+	if rec.URL == "" && rec.Base64 == "" {
+		return &RecordError{Field: "base64", Message: "url or base64 is required"}
+	}
+	return nil
+}
+
+type ndjsonStream struct {
+	r     *bufio.Reader
+	fetch Fetcher
+	line  int
+}
+
+// NewNDJSONStream reads one Record per line. Invalid lines are reported as *RecordError
+// without ending the stream.
+func NewNDJSONStream(r io.Reader, fetch Fetcher) Stream {
+	return &ndjsonStream{r: bufio.NewReader(r), fetch: fetch}
+}
+
+func (s *ndjsonStream) Next(ctx context.Context) (*Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	line, err := s.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == nil {
+		return nil, io.EOF
+	}
+
+	rec, err := parseRecord(line)
+	if err != nil {
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			recErr.Line = s.line
+		}
+		return nil, err
+	}
+
+	name := rec.Name
+	if name == "" {
+		name = path.Base(rec.URL)
+	}
+
+	entry := &Entry{Name: name, SourceURL: rec.URL}
+	switch {
+	case rec.Base64 != "":
+		data, err := base64.StdEncoding.DecodeString(rec.Base64)
+		if err != nil {
+			return nil, &RecordError{Line: s.line, Field: "base64", Message: err.Error()}
+		}
+		entry.Body = bytes.NewReader(data)
+	default:
+		if s.fetch == nil {
+			return nil, fmt.Errorf("record %d: no fetcher for %s", s.line, rec.URL)
+		}
+		body, err := s.fetch(rec.URL)
+		if err != nil {
+			return nil, &EntryError{Name: name, SourceURL: rec.URL, Err: fmt.Errorf("failed to download %s: %w", rec.URL, err)}
+		}
+		entry.Body = body
+	}
+
+	return entry, nil
+}
+
+// readLine returns the next non-blank line, or nil at the end of the stream
+func (s *ndjsonStream) readLine() ([]byte, error) {
+	for {
+		var line []byte
+		for {
+			chunk, isPrefix, err := s.r.ReadLine()
+			if err == io.EOF && line == nil {
+				return nil, nil
+			}
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if len(line)+len(chunk) > MaxRecordBytes {
+				// Skip the rest of the oversized line so the stream can continue
+				for isPrefix && err == nil {
+					_, isPrefix, err = s.r.ReadLine()
+				}
+				s.line++
+				return nil, &RecordError{Line: s.line, Message: fmt.Sprintf("exceeds %d bytes", MaxRecordBytes)}
+			}
+			line = append(line, chunk...)
+			if !isPrefix || err == io.EOF {
+				break
+			}
+		}
+		s.line++
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, nil
+		}
+	}
+}
+
+// parseRecord strictly decodes and validates one NDJSON line
+func parseRecord(line []byte) (*Record, error) {
+	// encoding/json replaces invalid UTF-8 with U+FFFD, so check the raw bytes first
+	if !utf8.Valid(line) {
+		return nil, &RecordError{Message: "is not valid UTF-8"}
+	}
+
+	var rec Record
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rec); err != nil {
+		return nil, &RecordError{Message: "invalid JSON: " + err.Error()}
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, &RecordError{Message: "invalid JSON: unexpected data after the record"}
+	}
+
+	if err := rec.Validate(); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// EntryError reports a single entry that could not be read; the stream itself can continue
+type EntryError struct {
+	Name      string
+	SourceURL string
+	Err       error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// Report summarizes a stream ingestion
+type Report struct {
+	Uploaded int             `json:"uploaded"`
+	Skipped  int             `json:"skipped"`
+	Failed   int             `json:"failed"`
+	Rejected int             `json:"rejected"`         // Not uploaded because of a quality issue
+	Issues   []*QualityIssue `json:"issues,omitempty"` // Quality issues of rejected and flagged documents
+}
+
+// Outcomes of a document in an Event
+const (
+	StatusUploaded = "uploaded"
+	StatusFlagged  = "flagged" // Uploaded with a quality issue
+	StatusSkipped  = "skipped" // Already in the store
+	StatusRejected = "rejected"
+	StatusFailed   = "failed"
+)
+
+// Event reports what happened to a document of a stream
+type Event struct {
+	Document string    `json:"document,omitempty"` // Empty for invalid NDJSON records
+	Status   string    `json:"status"`
+	Reason   string    `json:"reason,omitempty"` // Why the document was flagged, rejected or failed
+	Time     time.Time `json:"time"`
+}
+
+// Options configures Upload. The zero value uploads without quota or quality checks and only
+// logs failures.
+type Options struct {
+	Scheduler   *Scheduler   // Spreads uploads over a quota
+	DeadLetters *DeadLetters // Records failed and rejected documents
+	AccessLabel string       // Access label of the uploaded documents, see filesearch.AccessPolicy
+	Quality     *Checker     // Rejects or flags empty, scanned, password-protected and duplicate documents
+	Progress    func(*Event) // Called for every document, e.g. to show the progress of a job
+}
+
+// Upload reads every entry of the stream and uploads new documents to the store.
+// Documents already in the store, by display name, are skipped. With a quality checker,
+// documents with an issue are rejected, and recorded in the dead letters with StageQuality,
+// or uploaded flagged; either way the issue is listed in the report. opts may be nil.
+func Upload(ctx context.Context, service *filesearch.Service, storeName string, stream Stream, opts *Options) (*Report, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	existing := make(map[string]bool)
+	docs, err := service.ListDocuments(ctx, storeName)
+	if err != nil {
+		log.Printf("Warning: Failed to list existing documents: %v", err)
+	}
+	for _, doc := range docs {
+		existing[doc.DisplayName] = true
+	}
+	if opts.Quality != nil {
+		opts.Quality.AddDocuments(docs)
+	}
+
+	report := &Report{}
+	for {
+		entry, err := stream.Next(ctx)
+		if err == io.EOF {
+			return report, nil
+		}
+		var entryErr *EntryError
+		if errors.As(err, &entryErr) {
+			log.Printf("Warning: Failed to read %s: %v", entryErr.Name, entryErr.Err)
+			opts.fail(&Failure{
+				Stage:     StageDownload,
+				Name:      entryErr.Name,
+				Store:     storeName,
+				SourceURL: entryErr.SourceURL,
+				Error:     entryErr.Err.Error(),
+			}, nil)
+			opts.progress(entryErr.Name, StatusFailed, entryErr.Err.Error())
+			report.Failed++
+			continue
+		}
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			log.Printf("Warning: Skipping invalid %v", recErr)
+			opts.progress("", StatusFailed, recErr.Error())
+			report.Failed++
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		if existing[entry.Name] {
+			opts.progress(entry.Name, StatusSkipped, "")
+			report.Skipped++
+			continue
+		}
+
+		// Buffer the document so its content can be kept when the upload fails
+		data, err := io.ReadAll(entry.Body)
+		if err != nil {
+			return report, fmt.Errorf("failed to read %s: %w", entry.Name, err)
+		}
+
+		metadata := append(validity.Metadata(data), filesearch.AccessMetadata(opts.AccessLabel)...)
+		status, reason := StatusUploaded, ""
+		if opts.Quality != nil {
+			if issue := opts.Quality.Check(entry.Name, data); issue != nil {
+				report.Issues = append(report.Issues, issue)
+				if issue.Rejected {
+					log.Printf("Warning: Rejected %v", issue)
+					opts.fail(&Failure{
+						Stage:     StageQuality,
+						Name:      entry.Name,
+						Store:     storeName,
+						SourceURL: entry.SourceURL,
+						Error:     issue.Reason,
+					}, nil)
+					opts.progress(entry.Name, StatusRejected, issue.Reason)
+					report.Rejected++
+					continue
+				}
+				log.Printf("Warning: Uploading flagged %v", issue)
+				status, reason = StatusFlagged, issue.Reason
+				metadata = append(metadata, &genai.CustomMetadata{Key: MetadataQualityIssue, StringValue: issue.Issue})
+			}
+		}
+		metadata = append(metadata, &genai.CustomMetadata{Key: filesearch.MetadataContentHash, StringValue: ContentHash(data)})
+
+		if opts.Scheduler != nil {
+			if err := opts.Scheduler.Wait(ctx); err != nil {
+				return report, err
+			}
+		}
+		if _, err := service.UploadDocumentWithMetadata(ctx, bytes.NewReader(data), entry.Name, storeName, entry.SourceURL, metadata); err != nil {
+			log.Printf("Warning: Failed to upload %s: %v", entry.Name, err)
+			opts.fail(&Failure{
+				Stage:     StageUpload,
+				Name:      entry.Name,
+				Store:     storeName,
+				SourceURL: entry.SourceURL,
+				Error:     err.Error(),
+			}, data)
+			opts.progress(entry.Name, StatusFailed, err.Error())
+			report.Failed++
+			continue
+		}
+		existing[entry.Name] = true
+		opts.progress(entry.Name, status, reason)
+		report.Uploaded++
+		log.Printf("Uploaded %s", entry.Name)
+	}
+}
+
+// progress reports the outcome of a document, if anyone listens
+func (o *Options) progress(name, status, reason string) {
+	if o.Progress != nil {
+		o.Progress(&Event{Document: name, Status: status, Reason: reason, Time: time.Now()})
+	}
+}
+
+// fail records a failure in the dead-letter queue, if any
+func (o *Options) fail(f *Failure, payload []byte) {
+	if o.DeadLetters == nil {
+		return
+	}
+	f.AccessLabel = o.AccessLabel
+	if err := o.DeadLetters.Record(f, payload); err != nil {
+		log.Printf("Warning: Failed to record dead letter for %s: %v", f.Name, err)
+	}
+}
@@ -0,0 +1,126 @@
+package kv_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"rag/kv"
+)
+
+func ExampleOpen() {
+	store, err := kv.Open("memory")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for range 3 {
+		n, err := store.Incr(ctx, "ratelimit:requests:1760601600", 1, 2*time.Minute)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(n)
+	}
+
+	_, err = store.Get(ctx, "answers:missing")
+	fmt.Println(errors.Is(err, kv.ErrNotFound))
+	// Output:
+	// 1
+	// 2
+	// 3
+	// true
+}
+
+// serveRedis answers the commands of one connection from a map, like a Redis server would
+func serveRedis(conn net.Conn, values map[string]string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			reader.ReadString('\n') // Bulk length
+			arg, _ := reader.ReadString('\n')
+			args[i] = strings.TrimSuffix(arg, "\r\n")
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if value, ok := values[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+			} else {
+				fmt.Fprint(conn, "$-1\r\n")
+			}
+		case "SET":
+			values[args[1]] = args[2]
+			fmt.Fprint(conn, "+OK\r\n")
+		case "INCRBY":
+			current, _ := strconv.Atoi(values[args[1]])
+			delta, _ := strconv.Atoi(args[2])
+			values[args[1]] = strconv.Itoa(current + delta)
+			fmt.Fprintf(conn, ":%d\r\n", current+delta)
+		case "PEXPIRE":
+			fmt.Fprint(conn, ":1\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
+
+func ExampleNewRedis() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		values := make(map[string]string)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			serveRedis(conn, values)
+		}
+	}()
+
+	store, err := kv.NewRedis("redis://" + listener.Addr().String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Set(ctx, "answers:1f0c", []byte(`{"query":"minimumloon"}`), time.Hour); err != nil {
+		log.Fatal(err)
+	}
+	value, err := store.Get(ctx, "answers:1f0c")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(value))
+
+	n, err := store.Incr(ctx, "usage:hr:2026-10-16:tokens", 1200, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(n)
+
+	_, err = store.Get(ctx, "answers:missing")
+	fmt.Println(errors.Is(err, kv.ErrNotFound))
+	// Output:
+	// {"query":"minimumloon"}
+	// 1200
+	// true
+}
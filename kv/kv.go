@@ -0,0 +1,51 @@
+// Package kv keeps small pieces of server state, such as rate limit and quota counters or
+// cached answers, in process or in Redis. Replicas of a server behind a load balancer share
+// their state through Redis, so they agree on it whichever replica answers a request.
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ErrNotFound is returned when getting a key that is not set or has expired
+var ErrNotFound = errors.New("key not found")
+
+// Store keeps values by key, optionally expiring them
+type Store interface {
+	// Get returns the value of key, or an error wrapping ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set sets the value of key, expiring it after ttl unless ttl is zero
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// Incr adds delta to the integer value of key, starting from zero, and returns the result.
+	// A key created by Incr expires after ttl unless ttl is zero.
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// Acquire sets key to owner for ttl unless another owner holds it, and extends the ttl when
+	// owner holds it already. It reports whether owner holds key.
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Release deletes key if owner holds it
+	Release(ctx context.Context, key, owner string) error
+}
+
+// Open returns the store at a location: "" or "memory" for a store in process, or a
+// redis:// or rediss:// URL (see NewRedis) for a store shared by replicas
+func Open(location string) (Store, error) {
+	if location == "" || location == "memory" {
+		return NewMemory(), nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state store %q: %w", location, err)
+	}
+	switch u.Scheme {
+	case "redis", "rediss":
+		return NewRedis(location)
+	default:
+		return nil, fmt.Errorf("unsupported state store %q, expected memory or a redis:// URL", u.Redacted())
+	}
+}
@@ -0,0 +1,138 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory keeps values in process, for a single server
+type Memory struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	writes  int // Since expired entries were last dropped
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // Zero when the entry never expires
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory returns an empty store in process
+func NewMemory() *Memory {
+	return &Memory{now: time.Now, entries: make(map[string]memoryEntry)}
+}
+
+// Get implements Store
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.liveLocked(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return append([]byte(nil), entry.value...), nil
+}
+
+// Set implements Store
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = memoryEntry{value: append([]byte(nil), value...), expires: m.expiry(ttl)}
+	m.sweepLocked()
+	return nil
+}
+
+// Delete implements Store
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// Incr implements Store
+func (m *Memory) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.liveLocked(key)
+	if !ok {
+		entry = memoryEntry{value: []byte("0"), expires: m.expiry(ttl)}
+	}
+	n, err := strconv.ParseInt(string(entry.value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of %s is not an integer", key)
+	}
+	n += delta
+	entry.value = strconv.AppendInt(nil, n, 10)
+	m.entries[key] = entry
+	m.sweepLocked()
+	return n, nil
+}
+
+// Acquire implements Store
+func (m *Memory) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.liveLocked(key); ok && string(entry.value) != owner {
+		return false, nil
+	}
+	m.entries[key] = memoryEntry{value: []byte(owner), expires: m.expiry(ttl)}
+	m.sweepLocked()
+	return true, nil
+}
+
+// Release implements Store
+func (m *Memory) Release(ctx context.Context, key, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.liveLocked(key); ok && string(entry.value) == owner {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// liveLocked returns the entry of key unless it has expired, dropping expired entries
+func (m *Memory) liveLocked(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
+
+// sweepLocked drops the expired entries every 1000 writes, so keys that are never read again,
+// such as the counters of past rate limit windows, do not pile up
+func (m *Memory) sweepLocked() {
+	m.writes++
+	if m.writes < 1000 {
+		return
+	}
+	m.writes = 0
+	now := m.now()
+	for key, entry := range m.entries {
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func (m *Memory) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return m.now().Add(ttl)
+}
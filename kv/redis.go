@@ -0,0 +1,322 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIdleConns is the number of Redis connections kept open between commands
+const maxIdleConns = 8
+
+// Redis keeps values in a Redis server (or a compatible one such as Valkey), speaking the RESP
+// protocol over a small pool of connections
+type Redis struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config // Nil for plain TCP
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+var _ Store = (*Redis)(nil)
+
+// NewRedis returns the store of a Redis server at a URL of the form
+// redis://[[user]:password@]host[:port][/db], or rediss:// for TLS. No connection is made
+// until the first command.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid Redis URL %q: expected redis:// or rediss://", u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q: no host", u.Redacted())
+	}
+
+	r := &Redis{addr: u.Host, timeout: 5 * time.Second}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+	if u.Scheme == "rediss" {
+		r.tls = &tls.Config{ServerName: u.Hostname()}
+	}
+	return r, nil
+}
+
+// Get implements Store
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected Redis reply to GET: %v", reply)
+	}
+	return value, nil
+}
+
+// Set implements Store
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+// Delete implements Store
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", key)
+	return err
+}
+
+// Incr implements Store. The expiry is set by the command that created the key, so a replica
+// failing in between leaves a key that never expires rather than one expiring early.
+func (r *Redis) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	reply, err := r.do(ctx, "INCRBY", key, strconv.FormatInt(delta, 10))
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected Redis reply to INCRBY: %v", reply)
+	}
+	if n == delta && ttl > 0 {
+		if _, err := r.do(ctx, "PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10)); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// acquireScript takes or extends a lease atomically
+const acquireScript = `local owner = redis.call('GET', KEYS[1])
+if owner == false or owner == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`
+
+// releaseScript deletes a lease held by its owner
+const releaseScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+
+// Acquire implements Store
+func (r *Redis) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	reply, err := r.do(ctx, "EVAL", acquireScript, "1", key, owner, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+// Release implements Store
+func (r *Redis) Release(ctx context.Context, key, owner string) error {
+	_, err := r.do(ctx, "EVAL", releaseScript, "1", key, owner)
+	return err
+}
+
+// Close closes the idle connections
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, conn := range r.idle {
+		conn.Close()
+	}
+	r.idle = nil
+	return nil
+}
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// do sends a command and returns its reply: nil, a string, an int64, []byte or []any
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, r.timeout, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state
+		conn.Close()
+		return nil, fmt.Errorf("redis %s failed: %w", args[0], err)
+	}
+	r.release(conn)
+	return reply, err
+}
+
+// conn returns an idle connection or dials a new one
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if n := len(r.idle); n > 0 {
+		conn := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return conn, nil
+	}
+	r.mu.Unlock()
+
+	dialer := &net.Dialer{Timeout: r.timeout}
+	var netConn net.Conn
+	var err error
+	if r.tls != nil {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: r.tls}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if r.password != "" {
+		args := []string{"AUTH", r.password}
+		if r.username != "" {
+			args = []string{"AUTH", r.username, r.password}
+		}
+		if _, err := conn.do(ctx, r.timeout, args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate to Redis: %w", err)
+		}
+	}
+	if r.db != 0 {
+		if _, err := conn.do(ctx, r.timeout, "SELECT", strconv.Itoa(r.db)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to select Redis database %d: %w", r.db, err)
+		}
+	}
+	return conn, nil
+}
+
+// release keeps a connection for the next command, or closes it when enough are kept
+func (r *Redis) release(conn *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.idle) >= maxIdleConns {
+		conn.Close()
+		return
+	}
+	r.idle = append(r.idle, conn)
+}
+
+// redisConn is a connection to a Redis server
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command as an array of bulk strings and reads its reply
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, args ...string) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.Conn, cmd.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads a RESP reply
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis array length %q", line[1:])
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				var replyErr redisError
+				if !errors.As(err, &replyErr) {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected Redis reply %q", line)
+	}
+}
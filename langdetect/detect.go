@@ -0,0 +1,105 @@
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Supported language codes (ISO 639-1)
+const (
+	Dutch   = "nl"
+	French  = "fr"
+	English = "en"
+	German  = "de"
+)
+
+// minScore is the number of distinguishing stopword hits required before a language is
+// reported, so a single word such as "is" or "die" does not decide it
+const minScore = 2
+
+// stopwords are frequent short words that distinguish the languages of the Belgian corpus
+var stopwords = map[string][]string{
+	Dutch: {
+		"de", "het", "een", "en", "van", "ik", "je", "is", "dat", "op", "te", "voor", "met", "niet", "zijn",
+		"wat", "hoeveel", "mijn", "ben", "heb", "wordt", "word", "als", "bij", "ook", "er", "naar", "welke",
+		"hoe", "werk", "jaar", "loon", "verlof", "uur", "krijg", "moet", "mag", "werkgever", "arbeider", "bediende",
+	},
+	French: {
+		"le", "la", "les", "un", "une", "et", "de", "des", "du", "je", "est", "que", "pour", "dans", "pas",
+		"quel", "quelle", "combien", "mon", "ma", "mes", "suis", "ai", "sont", "avec", "sur", "au", "aux",
+		"comment", "travail", "salaire", "ans", "heures", "congé", "employeur", "ouvrier", "employé", "puis",
+	},
+	English: {
+		"the", "a", "an", "and", "of", "i", "you", "is", "that", "to", "for", "with", "not", "are", "what",
+		"how", "many", "my", "am", "have", "do", "does", "in", "on", "at", "can", "wage", "work", "hours",
+		"leave", "employer", "worker", "old", "years", "much", "which",
+	},
+	German: {
+		"der", "die", "das", "und", "ist", "ich", "nicht", "mit", "für", "ein", "eine", "wie", "viel",
+		"mein", "bin", "habe", "auf", "arbeit", "lohn", "jahre", "stunden", "urlaub", "arbeitgeber", "welche",
+	},
+}
+
+var index = func() map[string][]string {
+	idx := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			idx[w] = append(idx[w], lang)
+		}
+	}
+	return idx
+}()
+
+// Detect returns the most likely language of the text and a confidence between 0 and 1.
+// It returns "" when the text gives no usable signal or two languages score alike.
+func Detect(text string) (string, float64) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	scores := make(map[string]float64)
+	total := 0.0
+	for _, w := range words {
+		// Elided French articles such as "l'ancienneté" and "d'un"
+		if i := strings.IndexByte(w, '\''); i > 0 && i <= 2 {
+			scores[French]++
+			total++
+			continue
+		}
+		// Words shared between languages, such as "de" in Dutch and French, do not tell them apart
+		if langs := index[w]; len(langs) == 1 {
+			scores[langs[0]]++
+			total++
+		}
+	}
+
+	best, bestScore, runnerUp := "", 0.0, 0.0
+	for _, lang := range []string{Dutch, French, English, German} {
+		switch score := scores[lang]; {
+		case score > bestScore:
+			best, bestScore, runnerUp = lang, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if bestScore < minScore || bestScore == runnerUp {
+		return "", 0
+	}
+
+	return best, bestScore / total
+}
+
+// Name returns the English name of a supported language code, for use in prompts
+func Name(code string) string {
+	switch code {
+	case Dutch:
+		return "Dutch"
+	case French:
+		return "French"
+	case English:
+		return "English"
+	case German:
+		return "German"
+	}
+	return ""
+}
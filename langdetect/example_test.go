@@ -0,0 +1,26 @@
+package langdetect_test
+
+import (
+	"fmt"
+
+	"rag/langdetect"
+)
+
+func Example() {
+	for _, q := range []string{
+		"Wat is het minimumloon als je 17 jaar bent?",
+		"Quel est le salaire minimum pour un ouvrier de 17 ans ?",
+		"How many vacation days do I get?",
+		"PC 302",
+		"De cao van PC 302", // "de" is Dutch and French, one word is not enough
+	} {
+		lang, _ := langdetect.Detect(q)
+		fmt.Printf("%q\n", lang)
+	}
+	// Output:
+	// "nl"
+	// "fr"
+	// "en"
+	// ""
+	// ""
+}
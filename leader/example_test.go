@@ -0,0 +1,45 @@
+package leader_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rag/kv"
+	"rag/leader"
+)
+
+func ExampleElection() {
+	// Replicas share a store, e.g. Redis with kv.Open("redis://...")
+	store := kv.NewMemory()
+	retention := func(replica string, started chan<- string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			started <- replica
+			<-ctx.Done()
+		}
+	}
+
+	started := make(chan string)
+	first, stopFirst := context.WithCancel(context.Background())
+	second, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+
+	go leader.New(store, "retention", "cao-server-0", 300*time.Millisecond).Run(first, retention("cao-server-0", started))
+	fmt.Println(<-started)
+	go leader.New(store, "retention", "cao-server-1", 300*time.Millisecond).Run(second, retention("cao-server-1", started))
+
+	select {
+	case replica := <-started:
+		fmt.Println("also", replica)
+	case <-time.After(500 * time.Millisecond):
+		fmt.Println("cao-server-1 waits")
+	}
+
+	// The leader stops and releases its lease; the other replica takes over
+	stopFirst()
+	fmt.Println(<-started)
+	// Output:
+	// cao-server-0
+	// cao-server-1 waits
+	// cao-server-1
+}
@@ -0,0 +1,120 @@
+// Package leader elects one replica of a server to run a background job, such as retention
+// or canaries, so the job runs once across the fleet instead of once per replica. The leader
+// holds a lease in a shared store and renews it while the job runs; when it stops renewing,
+// because it exits or loses the store, another replica takes over once the lease expires.
+package leader
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"rag/kv"
+)
+
+// DefaultTTL is how long a lease lasts without being renewed
+const DefaultTTL = 30 * time.Second
+
+// Election elects the replica running one job
+type Election struct {
+	store kv.Store
+	key   string
+	id    string
+	ttl   time.Duration
+}
+
+// New creates the election of a job named name among the replicas sharing store. id
+// identifies this replica, e.g. logging.InstanceID(). A zero ttl defaults to DefaultTTL.
+func New(store kv.Store, name, id string, ttl time.Duration) *Election {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Election{store: store, key: "leader:" + name, id: id, ttl: ttl}
+}
+
+// Run runs job whenever this replica is the leader, until ctx is done or job returns. The
+// context of job is cancelled when the replica loses the lease, e.g. because it could not renew
+// it in time, and job is started again once the replica is elected again.
+func (e *Election) Run(ctx context.Context, job func(ctx context.Context)) {
+	for {
+		if held, _ := e.acquire(ctx); held {
+			log.Printf("Elected leader of %s as %s", e.key, e.id)
+			if finished := e.lead(ctx, job); finished {
+				return
+			}
+			log.Printf("Warning: lost leadership of %s", e.key)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.ttl / 3):
+		}
+	}
+}
+
+// lead runs job while renewing the lease. It reports whether Run is finished: ctx is done or
+// job returned.
+func (e *Election) lead(ctx context.Context, job func(ctx context.Context)) bool {
+	jobCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		job(jobCtx)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			e.release()
+			return true
+		case <-done:
+			cancel()
+			e.release()
+			return true
+		case <-ticker.C:
+			held, err := e.acquire(ctx)
+			if held {
+				renewed = time.Now()
+				continue
+			}
+			// When the store is unreachable, retry until another replica could take over once
+			// the lease expires
+			if err != nil && ctx.Err() == nil && time.Since(renewed) < e.ttl-e.ttl/3 {
+				continue
+			}
+			stop()
+			return ctx.Err() != nil
+		}
+	}
+}
+
+// acquire takes or renews the lease, reporting whether this replica holds it
+func (e *Election) acquire(ctx context.Context) (bool, error) {
+	callCtx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+
+	held, err := e.store.Acquire(callCtx, e.key, e.id, e.ttl)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Warning: failed to acquire leadership of %s: %v", e.key, err)
+	}
+	return held, err
+}
+
+// release gives up the lease so another replica can take over without waiting for it to expire
+func (e *Election) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+
+	if err := e.store.Release(ctx, e.key, e.id); err != nil {
+		log.Printf("Warning: failed to release leadership of %s: %v", e.key, err)
+	}
+}
@@ -0,0 +1,34 @@
+package loadtest_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"rag/loadtest"
+)
+
+func Example() {
+	ctx := context.Background()
+	server, err := loadtest.NewFakeServer(ctx, 5*time.Millisecond)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer server.Close()
+
+	report, err := loadtest.Run(ctx, &loadtest.Config{
+		URL:          server.URL,
+		StoreName:    loadtest.FakeStore,
+		Sessions:     10,
+		Duration:     300 * time.Millisecond,
+		ThinkTime:    10 * time.Millisecond,
+		HistoryDepth: 4,
+	}, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(report.Requests > 0, report.Errors)
+	// Output: true 0
+}
@@ -0,0 +1,75 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"rag/filesearch"
+)
+
+// FakeStore is the store served by the fake backend
+const FakeStore = "cao-documents"
+
+const fakeStores = `{"fileSearchStores":[{"name":"fileSearchStores/cao-documents-fake","displayName":"cao-documents"}]}`
+
+const fakeAnswer = `{"candidates":[{"content":{"role":"model","parts":[{"text":"Het minimumuurloon bedraagt 14,05 EUR voor werklieden van 18 jaar en ouder."}]},
+"groundingMetadata":{"groundingChunks":[{"retrievedContext":{"title":"100-2022-011302.pdf","uri":"fileSearchStores/cao-documents-fake/documents/100-2022-011302","text":"Het minimumuurloon bedraagt 14,05 EUR."}}]}}],
+"usageMetadata":{"promptTokenCount":400,"candidatesTokenCount":20,"totalTokenCount":420}}`
+
+const fakeSummary = `{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"sector\":\"bouw\",\"priorAnswers\":[\"Minimumuurloon 14,05 EUR\"]}"}]}}]}`
+
+// fakeGemini answers Gemini API calls with canned responses after a simulated latency
+type fakeGemini struct {
+	latency time.Duration
+}
+
+func (f *fakeGemini) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body.Close()
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-time.After(f.latency):
+	}
+
+	var payload string
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/fileSearchStores"):
+		payload = fakeStores
+	case strings.Contains(string(body), `"responseMimeType":"application/json"`):
+		payload = fakeSummary
+	case strings.HasSuffix(req.URL.Path, ":generateContent"):
+		payload = fakeAnswer
+	default:
+		return nil, fmt.Errorf("fake backend: unexpected request %s %s", req.Method, req.URL.Path)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(payload)),
+		Request:    req,
+	}, nil
+}
+
+// NewFakeServer starts the query API in-process on a fake Gemini backend that answers
+// every model call after latency. Close the server when done.
+func NewFakeServer(ctx context.Context, latency time.Duration) (*httptest.Server, error) {
+	service, err := filesearch.NewService(ctx, &filesearch.Config{
+		APIKey:     "fake",
+		HTTPClient: &http.Client{Transport: &fakeGemini{latency: latency}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query", filesearch.NewHandler(service).Query)
+	return httptest.NewServer(mux), nil
+}
@@ -0,0 +1,214 @@
+// Package loadtest simulates concurrent chat sessions against the query API to validate capacity.
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+)
+
+// DefaultQuestions are asked in turn by every session when Config.Questions is empty
+var DefaultQuestions = []string{
+	"Wat is het minimumloon als je 17 jaar bent?",
+	"Hoeveel vakantiedagen heb ik recht op?",
+	"Wat is de opzeggingstermijn na vijf jaar anciënniteit?",
+	"Krijg ik een eindejaarspremie?",
+	"Hoe worden overuren vergoed?",
+}
+
+// Config describes a load test
+type Config struct {
+	URL          string        // Base URL of the server, e.g. "http://localhost:8080"
+	StoreName    string        // Store sent with every query
+	APIKey       string        // Optional API key sent as X-API-Key
+	Sessions     int           // Concurrent chat sessions
+	Duration     time.Duration // How long sessions keep asking questions
+	ThinkTime    time.Duration // Average pause between a reply and the next question
+	HistoryDepth int           // Messages of history sent with each query
+	Questions    []string
+}
+
+// Report summarizes a load test
+type Report struct {
+	Requests   int
+	Errors     int
+	Elapsed    time.Duration
+	Throughput float64 // Requests per second
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	HeapStart  uint64 // Heap in use before the test, in bytes
+	HeapEnd    uint64 // Heap in use after the test and a garbage collection
+}
+
+// HeapGrowth returns the heap growth over the test in bytes, negative when the heap shrank
+func (r *Report) HeapGrowth() int64 {
+	return int64(r.HeapEnd) - int64(r.HeapStart)
+}
+
+// String renders the report for the terminal
+func (r *Report) String() string {
+	return fmt.Sprintf("Requests:   %d (%d errors) in %s\nThroughput: %.1f req/s\nLatency:    p50 %s, p90 %s, p99 %s, max %s\nHeap:       %.1f MB -> %.1f MB (%+.1f MB)\n",
+		r.Requests, r.Errors, r.Elapsed.Round(time.Millisecond), r.Throughput,
+		r.P50.Round(time.Millisecond), r.P90.Round(time.Millisecond), r.P99.Round(time.Millisecond), r.Max.Round(time.Millisecond),
+		float64(r.HeapStart)/1e6, float64(r.HeapEnd)/1e6, float64(r.HeapGrowth())/1e6)
+}
+
+// Run simulates cfg.Sessions chat sessions until cfg.Duration has passed or ctx is cancelled.
+// Heap figures are those of the current process, which includes the server when it runs in-process.
+func Run(ctx context.Context, cfg *Config, client *http.Client) (*Report, error) {
+	if cfg.Sessions <= 0 {
+		return nil, fmt.Errorf("at least one session is required")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	questions := cfg.Questions
+	if len(questions) == 0 {
+		questions = DefaultQuestions
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	report := &Report{HeapStart: heapInUse()}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		wg        sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < cfg.Sessions; i++ {
+		wg.Add(1)
+		go func(session int) {
+			defer wg.Done()
+			s := &chatSession{cfg: cfg, client: client}
+			for turn := 0; ctx.Err() == nil; turn++ {
+				latency, err := s.ask(ctx, questions[(session+turn)%len(questions)])
+				if ctx.Err() != nil {
+					return // Requests cut off by the end of the test are not counted
+				}
+
+				mu.Lock()
+				report.Requests++
+				if err != nil {
+					report.Errors++
+				} else {
+					latencies = append(latencies, latency)
+				}
+				mu.Unlock()
+
+				s.think(ctx)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	report.Elapsed = time.Since(start)
+	report.Throughput = float64(report.Requests) / report.Elapsed.Seconds()
+	report.P50, report.P90, report.P99, report.Max = percentiles(latencies)
+	report.HeapEnd = heapInUse()
+
+	return report, nil
+}
+
+// chatSession keeps the history and summary of one simulated user
+type chatSession struct {
+	cfg     *Config
+	client  *http.Client
+	history []filesearch.HistoryMessage
+	summary *filesearch.ConversationSummary
+}
+
+func (s *chatSession) ask(ctx context.Context, question string) (time.Duration, error) {
+	history := s.history
+	if len(history) > s.cfg.HistoryDepth {
+		history = history[len(history)-s.cfg.HistoryDepth:]
+	}
+
+	body, err := json.Marshal(filesearch.QueryRequest{
+		Query:     question,
+		StoreName: s.cfg.StoreName,
+		History:   history,
+		Summary:   s.summary,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL+"/query", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.APIKey != "" {
+		req.Header.Set("X-API-Key", s.cfg.APIKey)
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var answer filesearch.QueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil && err != io.EOF {
+		return 0, err
+	}
+	latency := time.Since(start)
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status %d: %s", resp.StatusCode, answer.Error)
+	}
+
+	s.history = append(s.history,
+		filesearch.HistoryMessage{Role: "user", Content: question},
+		filesearch.HistoryMessage{Role: "assistant", Content: answer.Answer},
+	)
+	if answer.Summary != nil {
+		s.summary = answer.Summary
+	}
+	return latency, nil
+}
+
+// think pauses for the think time with ±50% jitter, so sessions do not move in lockstep
+func (s *chatSession) think(ctx context.Context) {
+	if s.cfg.ThinkTime <= 0 {
+		return
+	}
+	pause := s.cfg.ThinkTime/2 + rand.N(s.cfg.ThinkTime)
+	select {
+	case <-ctx.Done():
+	case <-time.After(pause):
+	}
+}
+
+func percentiles(latencies []time.Duration) (p50, p90, p99, max time.Duration) {
+	if len(latencies) == 0 {
+		return 0, 0, 0, 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	at := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	return at(0.50), at(0.90), at(0.99), latencies[len(latencies)-1]
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse
+}
@@ -0,0 +1,89 @@
+package localstore_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rag/filesearch"
+	"rag/localstore"
+)
+
+// topicEmbedder embeds a text by how often it mentions each topic, standing in for the Gemini
+// embeddings endpoint
+type topicEmbedder struct{}
+
+var topics = []string{"wage", "holiday", "night"}
+
+func (topicEmbedder) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(topics))
+		for j, topic := range topics {
+			vectors[i][j] = float32(strings.Count(strings.ToLower(text), topic))
+		}
+	}
+	return vectors, nil
+}
+
+// firstSource answers with the best chunk, standing in for Gemini
+type firstSource struct{}
+
+func (firstSource) PromptWithChunks(ctx context.Context, prompt string, storeName string, chunks []*filesearch.RetrievedChunk) (*filesearch.PromptResponse, error) {
+	return &filesearch.PromptResponse{Parts: []string{storeName + ": " + chunks[0].Text}}, nil
+}
+
+func ExampleService() {
+	dir, err := os.MkdirTemp("", "localstore")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	local, err := localstore.Open(localstore.Config{
+		Path:      filepath.Join(dir, "local.db"),
+		Embedder:  topicEmbedder{},
+		Generator: firstSource{},
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer local.Close()
+
+	store, err := local.CreateStore(ctx, "cao-documents")
+	if err != nil {
+		panic(err)
+	}
+	documents := map[string]string{
+		"wages.txt":    "The minimum wage rises by 2% on 1 January.",
+		"holidays.txt": "Workers get one extra holiday after ten years of service.",
+		"nights.txt":   "Night work between 20:00 and 6:00 is paid a night premium.",
+	}
+	for _, name := range []string{"wages.txt", "holidays.txt", "nights.txt"} {
+		if _, err := local.UploadDocument(ctx, strings.NewReader(documents[name]), name, store.Name); err != nil {
+			panic(err)
+		}
+	}
+
+	chunks, err := local.RetrieveChunks(ctx, "Is there a premium for night shifts?", store.Name, &filesearch.RetrievalOptions{TopK: 1})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d. %s %.2f\n", chunks[0].Rank, chunks[0].FileName, *chunks[0].Score)
+
+	resp, err := local.PromptWithRetrieval(ctx, "What happens to the wage in January?", store.Name, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Parts[0])
+
+	_, err = local.RetrieveChunks(ctx, "wage", store.Name, &filesearch.RetrievalOptions{MetadataFilter: "jc_number = 200"})
+	fmt.Println(err)
+	// Output:
+	// 1. nights.txt 1.00
+	// cao-documents: The minimum wage rises by 2% on 1 January.
+	// failed to retrieve: metadata filters are not supported by the local store
+}
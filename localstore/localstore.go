@@ -0,0 +1,443 @@
+// Package localstore keeps stores of documents in a SQLite database as an alternative to
+// Gemini File Search. Uploaded documents are chunked and embedded, typically with the Gemini
+// embeddings endpoint, and questions are answered from the chunks most similar to them,
+// retrieved locally before generation. Retrieval needs no hosted File Search store, so it can
+// be tested offline against a fixed set of documents.
+//
+// Vectors are stored as blobs and compared in Go rather than with the sqlite-vec extension,
+// which the pure Go SQLite driver cannot load; a scan of the chunks of one store is fast enough
+// for the few thousand documents of a deployment.
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"rag/filesearch"
+	"rag/pdftext"
+	"rag/vectorindex"
+
+	_ "modernc.org/sqlite"
+)
+
+// Defaults of Config
+const (
+	DefaultTopK      = 5
+	DefaultBatchSize = 100
+)
+
+// ErrNotFound is returned for unknown stores and documents
+var ErrNotFound = errors.New("not found")
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS stores (
+	name         TEXT PRIMARY KEY,
+	display_name TEXT NOT NULL,
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS documents (
+	name         TEXT PRIMARY KEY,
+	store        TEXT NOT NULL,
+	display_name TEXT NOT NULL,
+	metadata     TEXT NOT NULL,
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS documents_store ON documents (store, created_at)`,
+	`CREATE TABLE IF NOT EXISTS chunks (
+	document TEXT NOT NULL,
+	seq      INTEGER NOT NULL,
+	page     INTEGER NOT NULL,
+	text     TEXT NOT NULL,
+	vector   BLOB NOT NULL,
+	PRIMARY KEY (document, seq)
+)`,
+}
+
+// Generator answers a prompt from retrieved chunks. *filesearch.Service implements it.
+type Generator interface {
+	PromptWithChunks(ctx context.Context, prompt string, storeName string, chunks []*filesearch.RetrievedChunk) (*filesearch.PromptResponse, error)
+}
+
+var _ Generator = (*filesearch.Service)(nil)
+
+// Config configures a local store
+type Config struct {
+	Path      string                 // SQLite database, created when missing
+	Embedder  filesearch.Embedder    // Required; embeds chunks and questions, e.g. a *filesearch.Service
+	Generator Generator              // Answers from retrieved chunks; without one only retrieval works
+	ChunkSize int                    // Words per chunk, defaults to vectorindex.DefaultChunkSize
+	Overlap   int                    // Words shared by consecutive chunks, defaults to vectorindex.DefaultOverlap
+	TopK      int                    // Chunks retrieved when the options set none, defaults to DefaultTopK
+	BatchSize int                    // Chunks per embedding request, defaults to DefaultBatchSize
+	Boosts    filesearch.ChunkBoosts // Optional feedback on chunks, adjusting their scores
+}
+
+// Service implements the store and query API of filesearch.Service on a SQLite database
+type Service struct {
+	db  *sql.DB
+	cfg Config
+	now func() time.Time
+}
+
+var (
+	_ filesearch.FileSearcher = (*Service)(nil)
+	_ filesearch.ModelBackend = (*Service)(nil)
+)
+
+// Open opens, or creates, the local store at cfg.Path
+func Open(cfg Config) (*Service, error) {
+	if cfg.Embedder == nil {
+		return nil, errors.New("local store requires an embedder")
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = vectorindex.DefaultChunkSize
+	}
+	if cfg.Overlap <= 0 {
+		cfg.Overlap = vectorindex.DefaultOverlap
+	}
+	if cfg.TopK <= 0 {
+		cfg.TopK = DefaultTopK
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	db, err := sql.Open("sqlite", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local store: %w", err)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create local store tables: %w", err)
+		}
+	}
+
+	return &Service{db: db, cfg: cfg, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Service) Close() error {
+	return s.db.Close()
+}
+
+// CreateStore creates an empty store
+func (s *Service) CreateStore(ctx context.Context, displayName string) (*filesearch.Store, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UnixNano()
+	store := &filesearch.Store{
+		Name:        "localStores/" + id,
+		DisplayName: displayName,
+		CreateTime:  timestamp(now),
+		UpdateTime:  timestamp(now),
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO stores (name, display_name, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+		store.Name, displayName, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+	return store, nil
+}
+
+// ListStores lists all stores, oldest first
+func (s *Service) ListStores(ctx context.Context) ([]*filesearch.Store, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT name, display_name, created_at, updated_at FROM stores ORDER BY created_at, name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stores: %w", err)
+	}
+	defer rows.Close()
+
+	stores := make([]*filesearch.Store, 0)
+	for rows.Next() {
+		var store filesearch.Store
+		var created, updated int64
+		if err := rows.Scan(&store.Name, &store.DisplayName, &created, &updated); err != nil {
+			return nil, fmt.Errorf("failed to list stores: %w", err)
+		}
+		store.CreateTime, store.UpdateTime = timestamp(created), timestamp(updated)
+		stores = append(stores, &store)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list stores: %w", err)
+	}
+	return stores, nil
+}
+
+// GetStoreByName finds a store by its display name
+func (s *Service) GetStoreByName(ctx context.Context, displayName string) (*filesearch.Store, error) {
+	stores, err := s.ListStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, store := range stores {
+		if store.DisplayName == displayName {
+			return store, nil
+		}
+	}
+	return nil, fmt.Errorf("store %q not found", displayName)
+}
+
+// DeleteStore deletes a store by resource name. A store that still holds documents is only
+// deleted, together with its documents, when force is set.
+func (s *Service) DeleteStore(ctx context.Context, storeName string, force bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete store: %w", err)
+	}
+	defer tx.Rollback()
+
+	var documents int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM documents WHERE store = ?`, storeName).Scan(&documents); err != nil {
+		return fmt.Errorf("failed to delete store: %w", err)
+	}
+	if documents > 0 && !force {
+		return fmt.Errorf("failed to delete store: %s holds %d documents", storeName, documents)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM stores WHERE name = ?`, storeName)
+	if err != nil {
+		return fmt.Errorf("failed to delete store: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to delete store: %w: %s", ErrNotFound, storeName)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM chunks WHERE document IN (SELECT name FROM documents WHERE store = ?)`, storeName); err != nil {
+		return fmt.Errorf("failed to delete store: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM documents WHERE store = ?`, storeName); err != nil {
+		return fmt.Errorf("failed to delete store: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete store: %w", err)
+	}
+	return nil
+}
+
+// ListDocuments lists all documents in a store, oldest first
+func (s *Service) ListDocuments(ctx context.Context, storeName string) ([]*filesearch.Document, error) {
+	docs, _, err := s.ListDocumentsPage(ctx, storeName, "", 0)
+	return docs, err
+}
+
+// ListDocumentsPage returns one page of the documents in a store and the token of the next
+// page, empty on the last one. A page size of zero returns all remaining documents; page
+// tokens are offsets.
+func (s *Service) ListDocumentsPage(ctx context.Context, storeName string, pageToken string, pageSize int) ([]*filesearch.Document, string, error) {
+	if err := s.checkStore(ctx, storeName); err != nil {
+		return nil, "", fmt.Errorf("failed to list documents: %w", err)
+	}
+	offset := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("failed to list documents: invalid page token %q", pageToken)
+		}
+		offset = n
+	}
+	// One row more than the page tells whether there is a next page
+	limit := -1
+	if pageSize > 0 {
+		limit = pageSize + 1
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT name, display_name, metadata, created_at, updated_at FROM documents
+		WHERE store = ? ORDER BY created_at, name LIMIT ? OFFSET ?`,
+		storeName, limit, offset)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list documents: %w", err)
+	}
+	defer rows.Close()
+
+	documents := make([]*filesearch.Document, 0)
+	for rows.Next() {
+		var doc filesearch.Document
+		var metadata string
+		var created, updated int64
+		if err := rows.Scan(&doc.Name, &doc.DisplayName, &metadata, &created, &updated); err != nil {
+			return nil, "", fmt.Errorf("failed to list documents: %w", err)
+		}
+		if err := json.Unmarshal([]byte(metadata), &doc.CustomMetadata); err != nil {
+			return nil, "", fmt.Errorf("invalid metadata of %s: %w", doc.Name, err)
+		}
+		doc.CreateTime, doc.UpdateTime = timestamp(created), timestamp(updated)
+		doc.State = filesearch.DocumentActive // Indexed when uploaded
+		documents = append(documents, &doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	next := ""
+	if pageSize > 0 && len(documents) > pageSize {
+		documents = documents[:pageSize]
+		next = strconv.Itoa(offset + pageSize)
+	}
+	return documents, next, nil
+}
+
+// DeleteDocument deletes a document, and its chunks, by resource name
+func (s *Service) DeleteDocument(ctx context.Context, documentName string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM documents WHERE name = ?`, documentName)
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to delete document: %w: %s", ErrNotFound, documentName)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chunks WHERE document = ?`, documentName); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	return nil
+}
+
+// UploadDocument chunks, embeds and stores a PDF or plain text document
+func (s *Service) UploadDocument(ctx context.Context, reader io.Reader, fileName string, storeName string) (*filesearch.Document, error) {
+	return s.UploadDocumentWithMetadata(ctx, reader, fileName, storeName, nil)
+}
+
+// UploadDocumentWithMetadata uploads a document with custom metadata. A source_url entry
+// becomes the URI of the chunks retrieved from the document, as with File Search.
+func (s *Service) UploadDocumentWithMetadata(ctx context.Context, reader io.Reader, fileName string, storeName string, metadata map[string]string) (*filesearch.Document, error) {
+	if err := s.checkStore(ctx, storeName); err != nil {
+		return nil, fmt.Errorf("failed to upload document: %w", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	pages := []string{string(data)}
+	if bytes.HasPrefix(data, []byte("%PDF")) {
+		doc, err := pdftext.Extract(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", fileName, err)
+		}
+		pages = doc.Pages
+	}
+
+	chunks := vectorindex.ChunkPages(fileName, pages, s.cfg.ChunkSize, s.cfg.Overlap)
+	if err := s.embedChunks(ctx, chunks); err != nil {
+		return nil, fmt.Errorf("failed to embed %s: %w", fileName, err)
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UnixNano()
+	doc := &filesearch.Document{
+		Name:           storeName + "/documents/" + id,
+		DisplayName:    fileName,
+		CreateTime:     timestamp(now),
+		UpdateTime:     timestamp(now),
+		CustomMetadata: metadata,
+		State:          filesearch.DocumentActive,
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store document: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO documents (name, store, display_name, metadata, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		doc.Name, storeName, fileName, string(encoded), now, now); err != nil {
+		return nil, fmt.Errorf("failed to store document: %w", err)
+	}
+	for i, chunk := range chunks {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO chunks (document, seq, page, text, vector) VALUES (?, ?, ?, ?, ?)`,
+			doc.Name, i, chunk.Page, chunk.Text, encodeVector(chunk.Vector)); err != nil {
+			return nil, fmt.Errorf("failed to store chunk: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE stores SET updated_at = ? WHERE name = ?`, now, storeName); err != nil {
+		return nil, fmt.Errorf("failed to store document: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to store document: %w", err)
+	}
+	return doc, nil
+}
+
+// embedChunks sets the vectors of chunks, embedding them in batches
+func (s *Service) embedChunks(ctx context.Context, chunks []*vectorindex.Chunk) error {
+	for start := 0; start < len(chunks); start += s.cfg.BatchSize {
+		batch := chunks[start:min(start+s.cfg.BatchSize, len(chunks))]
+		texts := make([]string, len(batch))
+		for i, chunk := range batch {
+			texts[i] = chunk.Text
+		}
+		vectors, err := s.cfg.Embedder.Embed(ctx, texts, filesearch.TaskRetrievalDocument)
+		if err != nil {
+			return err
+		}
+		if len(vectors) != len(batch) {
+			return fmt.Errorf("expected %d embeddings, got %d", len(batch), len(vectors))
+		}
+		for i, chunk := range batch {
+			chunk.Vector = vectors[i]
+		}
+	}
+	return nil
+}
+
+// checkStore returns an error wrapping ErrNotFound when there is no store by that name
+func (s *Service) checkStore(ctx context.Context, storeName string) error {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM stores WHERE name = ?`, storeName).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("store %w: %s", ErrNotFound, storeName)
+	}
+	return nil
+}
+
+// newID returns a random identifier for a store or document
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// timestamp formats a time stored in nanoseconds the way filesearch.Service reports times
+func timestamp(nanos int64) string {
+	return time.Unix(0, nanos).UTC().String()
+}
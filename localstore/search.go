@@ -0,0 +1,153 @@
+package localstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"rag/filesearch"
+)
+
+// Embed embeds texts with the configured embedder
+func (s *Service) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	return s.cfg.Embedder.Embed(ctx, texts, taskType)
+}
+
+// RetrieveChunks returns the chunks of a store most similar to the query, with their cosine
+// similarity plus the boost of Config.Boosts as score, without answering it. Metadata filters are not supported and fail, so a
+// filter scoping retrieval, e.g. to a tenant, is never silently ignored.
+func (s *Service) RetrieveChunks(ctx context.Context, query string, storeName string, opts *filesearch.RetrievalOptions) ([]*filesearch.RetrievedChunk, error) {
+	topK := s.cfg.TopK
+	if opts != nil {
+		if opts.MetadataFilter != "" {
+			return nil, errors.New("failed to retrieve: metadata filters are not supported by the local store")
+		}
+		if opts.TopK > 0 {
+			topK = opts.TopK
+		}
+	}
+	if err := s.checkStore(ctx, storeName); err != nil {
+		return nil, fmt.Errorf("failed to retrieve: %w", err)
+	}
+
+	vectors, err := s.cfg.Embedder.Embed(ctx, []string{query}, filesearch.TaskRetrievalQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vectors))
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT d.name, d.display_name, d.metadata, c.page, c.text, c.vector
+		FROM chunks c JOIN documents d ON d.name = c.document WHERE d.store = ?`,
+		storeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve: %w", err)
+	}
+	defer rows.Close()
+
+	metadata := make(map[string]map[string]string) // document -> metadata, decoded once
+	var chunks []*filesearch.RetrievedChunk
+	for rows.Next() {
+		var name, rawMetadata string
+		var vector []byte
+		chunk := &filesearch.RetrievedChunk{}
+		if err := rows.Scan(&name, &chunk.FileName, &rawMetadata, &chunk.Page, &chunk.Text, &vector); err != nil {
+			return nil, fmt.Errorf("failed to retrieve: %w", err)
+		}
+		if _, ok := metadata[name]; !ok {
+			var m map[string]string
+			if err := json.Unmarshal([]byte(rawMetadata), &m); err != nil {
+				return nil, fmt.Errorf("invalid metadata of %s: %w", name, err)
+			}
+			metadata[name] = m
+		}
+		score := cosine(vectors[0], decodeVector(vector))
+		if s.cfg.Boosts != nil {
+			chunk.Boost = s.cfg.Boosts.Boost(chunk.FileName, chunk.Text)
+			score += chunk.Boost
+		}
+		chunk.Score = &score
+		chunk.Metadata = metadata[name]
+		chunk.URI = chunk.Metadata["source_url"]
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to retrieve: %w", err)
+	}
+
+	slices.SortStableFunc(chunks, func(a, b *filesearch.RetrievedChunk) int {
+		switch {
+		case *a.Score > *b.Score:
+			return -1
+		case *a.Score < *b.Score:
+			return 1
+		}
+		return 0
+	})
+	if len(chunks) > topK {
+		chunks = chunks[:topK]
+	}
+	for i, chunk := range chunks {
+		chunk.Rank = i + 1
+	}
+	return chunks, nil
+}
+
+// PromptWithRetrieval retrieves the chunks of a store most similar to the prompt and answers
+// from them with the configured generator. The store profile is looked up by display name.
+func (s *Service) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	if s.cfg.Generator == nil {
+		return nil, errors.New("local store has no generator to answer with")
+	}
+	chunks, err := s.RetrieveChunks(ctx, prompt, storeName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var displayName string
+	if err := s.db.QueryRowContext(ctx, `SELECT display_name FROM stores WHERE name = ?`, storeName).Scan(&displayName); err != nil {
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+	return s.cfg.Generator.PromptWithChunks(ctx, prompt, displayName, chunks)
+}
+
+// encodeVector stores a vector as little-endian float32s
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+// decodeVector reads a vector stored by encodeVector
+func decodeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,80 @@
+// Package logging sets up structured logging for the commands. Log records use the same
+// field names everywhere:
+//
+//	jc        joint committee number
+//	url       source URL of a document
+//	document  display name or resource name of a document
+//	store     store display name or resource name
+//	duration  how long an operation took
+//	attempt   attempt number of a retried operation, counting from 1
+//	error     the error of a failed operation
+//	instance  the replica of a server a record comes from, see WithInstance
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup installs the default logger of a command from LOG_FORMAT ("text", the default, or
+// "json" for container environments) and LOG_LEVEL ("debug", "info", the default, "warn" or
+// "error"). Messages of the standard log package, used by the library packages, go through
+// the same handler. Invalid settings stop the command.
+func Setup() {
+	logger, err := New(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+}
+
+// New creates a logger writing to w in the given format at the given level. Empty values
+// select text output at info level.
+func New(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL %q: expected debug, info, warn or error", level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid LOG_FORMAT %q: expected text or json", format)
+	}
+}
+
+// InstanceID identifies the replica a server runs as: INSTANCE_ID, or else the host name,
+// which is unique per container or pod
+func InstanceID() string {
+	if id := os.Getenv("INSTANCE_ID"); id != "" {
+		return id
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return fmt.Sprintf("pid-%d", os.Getpid())
+}
+
+// WithInstance adds the instance ID to every record of the default logger, so the logs of
+// replicas running behind a load balancer can be told apart. It returns the ID.
+func WithInstance() string {
+	id := InstanceID()
+	slog.SetDefault(slog.Default().With("instance", id))
+	return id
+}
+
+// Fatal logs an error with its attributes and stops the command
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,59 @@
+package metrics_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"rag/metrics"
+)
+
+func ExampleRegistry() {
+	registry := metrics.NewRegistry()
+	queries := registry.NewCounter("queries_total", "Queries answered, by HTTP status of the response.", "status")
+	duration := registry.NewHistogram("query_duration_seconds", "Time to answer a query.", []float64{1, 5})
+
+	queries.Inc("200")
+	queries.Inc("200")
+	queries.Inc("503")
+	duration.Observe(0.8)
+	duration.Observe(3.2)
+
+	registry.Write(os.Stdout)
+	// Output:
+	// # HELP queries_total Queries answered, by HTTP status of the response.
+	// # TYPE queries_total counter
+	// queries_total{status="200"} 2
+	// queries_total{status="503"} 1
+	// # HELP query_duration_seconds Time to answer a query.
+	// # TYPE query_duration_seconds histogram
+	// query_duration_seconds_bucket{le="1"} 1
+	// query_duration_seconds_bucket{le="5"} 2
+	// query_duration_seconds_bucket{le="+Inf"} 2
+	// query_duration_seconds_sum 4
+	// query_duration_seconds_count 2
+}
+
+func ExampleErrorTransport() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	errors := registry.NewCounter("gemini_errors_total", "Failed Gemini API calls.", "status")
+	client := &http.Client{Transport: &metrics.ErrorTransport{Counter: errors}}
+
+	for range 2 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	fmt.Println(errors.Value("429"))
+	// Output:
+	// 2
+}
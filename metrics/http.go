@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QueryMiddleware counts the requests of a query handler in queries_total by response
+// status, and observes their duration in query_duration_seconds
+func QueryMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(rw, r)
+		Queries.Inc(strconv.Itoa(rw.status))
+		QueryDuration.Since(start)
+	}
+}
+
+// statusWriter records the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ErrorTransport counts the failed requests of an API client in counter by response status,
+// or "network" when no response arrived. Requests canceled by their caller are not counted.
+type ErrorTransport struct {
+	Base    http.RoundTripper // Defaults to http.DefaultTransport
+	Counter *Counter          // Takes the status as its only label, e.g. GeminiErrors
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	switch {
+	case err != nil && !errors.Is(err, context.Canceled):
+		t.Counter.Inc("network")
+	case err == nil && resp.StatusCode >= http.StatusBadRequest:
+		t.Counter.Inc(strconv.Itoa(resp.StatusCode))
+	}
+	return resp, err
+}
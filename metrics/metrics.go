@@ -0,0 +1,252 @@
+// Package metrics exports counters and histograms in the Prometheus text format, so operators
+// can alert on error rates and latency. The metrics of this module are registered in Default:
+//
+//	queries_total           queries answered, by HTTP status of the response
+//	query_duration_seconds  time to answer a query
+//	upload_bytes_total      bytes of documents uploaded to stores
+//	gemini_errors_total     failed Gemini API calls, by HTTP status
+//
+// Serve them with Default as the handler of /metrics.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms; answers take from
+// a second to tens of seconds
+var DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60}
+
+// The metrics of this module
+var (
+	Default = NewRegistry()
+
+	Queries       = Default.NewCounter("queries_total", "Queries answered, by HTTP status of the response.", "status")
+	QueryDuration = Default.NewHistogram("query_duration_seconds", "Time to answer a query.", DefaultBuckets)
+	UploadBytes   = Default.NewCounter("upload_bytes_total", "Bytes of documents uploaded to stores.")
+	GeminiErrors  = Default.NewCounter("gemini_errors_total", `Failed Gemini API calls, by HTTP status, or "network" when no response arrived.`, "status")
+)
+
+// Registry holds metrics and serves them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text format, in the order they were created
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// series are the values of a metric per combination of label values
+type series[T any] struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]T // Label values joined by labelSeparator -> value
+}
+
+const labelSeparator = "\xff"
+
+func newSeries[T any](name, help string, labels []string) series[T] {
+	return series[T]{name: name, help: help, labels: labels, values: make(map[string]T)}
+}
+
+// key joins label values, failing when their number does not match the labels
+func (s *series[T]) key(values []string) string {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", s.name, len(s.labels), len(values)))
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+// header writes the help and type lines
+func (s *series[T]) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", s.name, strings.ReplaceAll(s.help, "\n", " "), s.name, kind)
+}
+
+// labelPairs formats the labels of a series with extra pairs appended, e.g. `{status="200"}`
+func (s *series[T]) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(s.labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, s.labels[i]+`="`+escape(value)+`"`)
+		}
+	}
+	pairs = append(pairs, extra...)
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sorted returns the keys of the series in order
+func (s *series[T]) sorted() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// escape escapes a label value
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// number formats a sample value
+func number(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Counter is a value that only goes up, e.g. the number of requests
+type Counter struct {
+	series[float64]
+}
+
+// NewCounter creates a counter with the given label names and registers it
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newSeries[float64](name, help, labels)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with the given label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s cannot decrease", c.name))
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += v
+}
+
+// Value returns the counter with the given label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, key := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key), number(c.values[key]))
+	}
+}
+
+// Histogram counts observations, e.g. request durations, in buckets
+type Histogram struct {
+	series[*distribution]
+	buckets []float64
+}
+
+// distribution is the observations of one series of a histogram
+type distribution struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a histogram with the given bucket upper bounds and label names and
+// registers it
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{series: newSeries[*distribution](name, help, labels), buckets: slices.Sorted(slices.Values(buckets))}
+	r.register(h)
+	return h
+}
+
+// Observe adds an observation to the histogram with the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	d, ok := h.values[key]
+	if !ok {
+		d = &distribution{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = d
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		d.counts[i]++
+	}
+	d.count++
+	d.sum += v
+}
+
+// Since observes the seconds elapsed since start
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+	for _, key := range h.sorted() {
+		d := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += d.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, `le="`+number(bound)+`"`), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, `le="+Inf"`), d.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key), number(d.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key), d.count)
+	}
+}
@@ -0,0 +1,276 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCanaryLatency is the latency above which a canary query counts as degraded
+const DefaultCanaryLatency = 30 * time.Second
+
+// Canary is a smoke-test question asked periodically to check that a store still answers
+// quickly and from its documents
+type Canary struct {
+	Store      string   `yaml:"store" json:"store"` // Store display name
+	Question   string   `yaml:"question" json:"question"`
+	Expect     []string `yaml:"expect" json:"expect,omitempty"` // Terms the answer must contain, case-insensitively
+	MinSources int      `yaml:"minSources" json:"-"`            // Documents the answer must be grounded in, at least one
+}
+
+// CanarySuite is the set of canary questions with the limits they are checked against
+type CanarySuite struct {
+	MaxLatency time.Duration `yaml:"maxLatency"` // Defaults to DefaultCanaryLatency
+	Canaries   []*Canary     `yaml:"canaries"`
+}
+
+// LoadCanaries reads a canary suite from a YAML file
+func LoadCanaries(path string) (*CanarySuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read canaries: %w", err)
+	}
+	var suite CanarySuite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse canaries: %w", err)
+	}
+	for i, c := range suite.Canaries {
+		if c == nil || c.Store == "" || strings.TrimSpace(c.Question) == "" {
+			return nil, fmt.Errorf("canaries[%d]: store and question are required", i)
+		}
+		if c.MinSources < 0 {
+			return nil, fmt.Errorf("canaries[%d]: minSources must not be negative", i)
+		}
+	}
+	if suite.MaxLatency <= 0 {
+		suite.MaxLatency = DefaultCanaryLatency
+	}
+	return &suite, nil
+}
+
+// CanaryResult is the outcome of one canary query
+type CanaryResult struct {
+	Canary    *Canary   `json:"canary"`
+	At        time.Time `json:"at"`
+	LatencyMS int64     `json:"latencyMs"`
+	Sources   int       `json:"sources"`            // Distinct documents the answer was grounded in
+	Failures  []string  `json:"failures,omitempty"` // Why the canary is degraded
+	Recovered bool      `json:"recovered,omitempty"`
+}
+
+// Degraded reports whether the canary failed, answered too slowly or answered ungrounded
+func (r *CanaryResult) Degraded() bool {
+	return len(r.Failures) > 0
+}
+
+// CanaryNotifier delivers canaries that became degraded or recovered
+type CanaryNotifier interface {
+	NotifyCanary(ctx context.Context, result *CanaryResult) error
+}
+
+// CanaryNotifierFunc adapts a function to a CanaryNotifier
+type CanaryNotifierFunc func(ctx context.Context, result *CanaryResult) error
+
+func (f CanaryNotifierFunc) NotifyCanary(ctx context.Context, result *CanaryResult) error {
+	return f(ctx, result)
+}
+
+// LogCanaryNotifier logs degraded and recovered canaries
+var LogCanaryNotifier = CanaryNotifierFunc(func(ctx context.Context, result *CanaryResult) error {
+	if result.Recovered {
+		log.Printf("Canary recovered for %q on %s", result.Canary.Question, result.Canary.Store)
+	} else {
+		log.Printf("Canary degraded for %q on %s: %s", result.Canary.Question, result.Canary.Store, strings.Join(result.Failures, "; "))
+	}
+	return nil
+})
+
+// CanaryWebhook posts each degraded or recovered canary as JSON to a URL. A nil client uses
+// http.DefaultClient.
+func CanaryWebhook(url string, client *http.Client) CanaryNotifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return CanaryNotifierFunc(func(ctx context.Context, result *CanaryResult) error {
+		return postJSON(ctx, client, url, result)
+	})
+}
+
+// CanaryMonitor runs a canary suite, records the results and notifies when a canary becomes
+// degraded or recovers. It is safe for concurrent use.
+type CanaryMonitor struct {
+	suite    *CanarySuite
+	logPath  string
+	notifier CanaryNotifier
+
+	mu       sync.Mutex
+	degraded map[string]bool // canary key -> degraded at the last check
+}
+
+// NewCanaryMonitor creates a monitor for a suite. Results are appended as JSON lines to
+// logPath unless it is empty; notifier may be nil.
+func NewCanaryMonitor(suite *CanarySuite, logPath string, notifier CanaryNotifier) *CanaryMonitor {
+	return &CanaryMonitor{
+		suite:    suite,
+		logPath:  logPath,
+		notifier: notifier,
+		degraded: make(map[string]bool),
+	}
+}
+
+// Check asks every canary question once. Canaries that are degraded on the first check,
+// became degraded since the previous one or recovered are notified. Store display names are
+// resolved when the provider is a File Search service, as in Registry.Check.
+func (m *CanaryMonitor) Check(ctx context.Context, provider filesearch.Provider) ([]*CanaryResult, error) {
+	resolver, _ := provider.(storeResolver)
+	stores := make(map[string]string)
+	results := make([]*CanaryResult, 0, len(m.suite.Canaries))
+	var errs []error
+
+	for _, c := range m.suite.Canaries {
+		result := &CanaryResult{Canary: c, At: time.Now()}
+		results = append(results, result)
+
+		storeName, ok := stores[c.Store]
+		if !ok {
+			storeName = c.Store
+			if resolver != nil {
+				store, err := resolver.GetStoreByName(ctx, c.Store)
+				if err != nil {
+					result.Failures = append(result.Failures, err.Error())
+					continue
+				}
+				storeName = store.Name
+			}
+			stores[c.Store] = storeName
+		}
+
+		resp, err := provider.PromptWithRetrieval(ctx, c.Question, storeName, nil)
+		result.LatencyMS = time.Since(result.At).Milliseconds()
+		if err != nil {
+			result.Failures = append(result.Failures, "query failed: "+err.Error())
+			continue
+		}
+		m.grade(result, resp)
+	}
+
+	if err := m.record(results); err != nil {
+		errs = append(errs, err)
+	}
+	for _, result := range m.changed(results) {
+		if m.notifier == nil {
+			break
+		}
+		if err := m.notifier.NotifyCanary(ctx, result); err != nil {
+			errs = append(errs, fmt.Errorf("canary %q: failed to notify: %w", result.Canary.Question, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// grade checks an answer against the limits of the suite
+func (m *CanaryMonitor) grade(result *CanaryResult, resp *filesearch.PromptResponse) {
+	c := result.Canary
+	if latency := time.Duration(result.LatencyMS) * time.Millisecond; latency > m.suite.MaxLatency {
+		result.Failures = append(result.Failures, fmt.Sprintf("answered in %s, above %s", latency, m.suite.MaxLatency))
+	}
+
+	result.Sources = len(sourceNames(resp.GroundingSupport))
+	if minSources := max(c.MinSources, 1); result.Sources < minSources {
+		result.Failures = append(result.Failures, fmt.Sprintf("grounded in %d of the required %d documents", result.Sources, minSources))
+	}
+
+	answer := strings.ToLower(resp.Text())
+	for _, term := range c.Expect {
+		if !strings.Contains(answer, strings.ToLower(term)) {
+			result.Failures = append(result.Failures, fmt.Sprintf("answer does not mention %q", term))
+		}
+	}
+}
+
+// changed returns the results whose health differs from the previous check, and the
+// degraded results of canaries not checked before
+func (m *CanaryMonitor) changed(results []*CanaryResult) []*CanaryResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changed []*CanaryResult
+	for _, result := range results {
+		key := result.Canary.Store + "\x00" + result.Canary.Question
+		was, checked := m.degraded[key]
+		m.degraded[key] = result.Degraded()
+		switch {
+		case result.Degraded() && !was:
+			changed = append(changed, result)
+		case !result.Degraded() && checked && was:
+			result.Recovered = true
+			changed = append(changed, result)
+		}
+	}
+	return changed
+}
+
+// record appends the results to the log
+func (m *CanaryMonitor) record(results []*CanaryResult) error {
+	if m.logPath == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open canary log: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, result := range results {
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("failed to write canary log: %w", err)
+		}
+	}
+	return nil
+}
+
+// Schedule checks the canaries every interval until ctx is cancelled. The first check runs
+// immediately, which also warms up the stores after a restart.
+func (m *CanaryMonitor) Schedule(ctx context.Context, provider filesearch.Provider, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := m.Check(ctx, provider)
+		if err != nil {
+			log.Printf("Warning: Canary check failed: %v", err)
+		}
+		degraded := 0
+		for _, result := range results {
+			if result.Degraded() {
+				degraded++
+			}
+		}
+		if degraded > 0 {
+			log.Printf("Canaries: %d of %d degraded", degraded, len(results))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sentenceEnd splits answers after a sentence or at a line break
+var sentenceEnd = regexp.MustCompile(`(?:[.!?])\s+|\n+`)
+
+// sentences splits an answer into trimmed, non-empty sentences
+func sentences(text string) []string {
+	var out []string
+	prev := 0
+	for _, m := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if s := strings.TrimSpace(text[prev:m[1]]); s != "" {
+			out = append(out, s)
+		}
+		prev = m[1]
+	}
+	if s := strings.TrimSpace(text[prev:]); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// Diff compares two answers sentence by sentence. Removed sentences are prefixed with
+// "- ", added ones with "+ " and unchanged ones with two spaces.
+func Diff(old, new string) string {
+	a, b := sentences(old), sentences(new)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,67 @@
+package monitor_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rag/filesearch"
+	"rag/monitor"
+)
+
+func ExampleDiff() {
+	old := "Het minimumuurloon bedraagt 14,05 EUR. Het geldt vanaf 18 jaar."
+	new := "Het minimumuurloon bedraagt 14,42 EUR. Het geldt vanaf 18 jaar."
+
+	fmt.Print(monitor.Diff(old, new))
+	// Output:
+	// - Het minimumuurloon bedraagt 14,05 EUR.
+	// + Het minimumuurloon bedraagt 14,42 EUR.
+	//   Het geldt vanaf 18 jaar.
+}
+
+// answers is a provider answering from a fixed set of answers, each grounded in one document
+type answers map[string]string
+
+func (a answers) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	return &filesearch.PromptResponse{
+		Parts: []string{a[prompt]},
+		GroundingSupport: &filesearch.GroundingSupport{GroundingChunks: []*filesearch.GroundingChunk{
+			{File: &filesearch.FileGroundingChunk{FileName: "pc302.pdf"}},
+		}},
+	}, nil
+}
+
+func ExampleCanaryMonitor_Check() {
+	path := filepath.Join(os.TempDir(), "canaries-example.yaml")
+	os.WriteFile(path, []byte(`
+canaries:
+  - store: fileSearchStores/cao
+    question: Wat is het minimumuurloon in PC 302?
+    expect: ["14,42"]
+`), 0o644)
+	defer os.Remove(path)
+
+	suite, err := monitor.LoadCanaries(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	notifier := monitor.CanaryNotifierFunc(func(ctx context.Context, r *monitor.CanaryResult) error {
+		fmt.Printf("degraded=%v recovered=%v %v\n", r.Degraded(), r.Recovered, r.Failures)
+		return nil
+	})
+	canaries := monitor.NewCanaryMonitor(suite, "", notifier)
+
+	ctx := context.Background()
+	question := "Wat is het minimumuurloon in PC 302?"
+	canaries.Check(ctx, answers{question: "Het minimumuurloon bedraagt 14,05 EUR."})
+	canaries.Check(ctx, answers{question: "Het minimumuurloon bedraagt 14,05 EUR."})
+	results, _ := canaries.Check(ctx, answers{question: "Het minimumuurloon bedraagt 14,42 EUR."})
+	fmt.Println("sources:", results[0].Sources)
+	// Output:
+	// degraded=true recovered=false [answer does not mention "14,42"]
+	// degraded=false recovered=true []
+	// sources: 1
+}
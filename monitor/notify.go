@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Notifier delivers a change to a watched question
+type Notifier interface {
+	Notify(ctx context.Context, change *Change) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(ctx context.Context, change *Change) error
+
+func (f NotifierFunc) Notify(ctx context.Context, change *Change) error {
+	return f(ctx, change)
+}
+
+// LogNotifier logs changes with their diff
+var LogNotifier = NotifierFunc(func(ctx context.Context, change *Change) error {
+	log.Printf("Answer changed for %q on %s:\n%s", change.Watch.Question, change.Watch.Store, change.Diff)
+	return nil
+})
+
+// Webhook posts each change as JSON to a URL. A nil client uses http.DefaultClient.
+func Webhook(url string, client *http.Client) Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return NotifierFunc(func(ctx context.Context, change *Change) error {
+		return postJSON(ctx, client, url, change)
+	})
+}
+
+// postJSON posts v as JSON to a webhook
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
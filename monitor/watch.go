@@ -0,0 +1,303 @@
+// Package monitor re-asks registered questions after the documents of a store change and
+// reports when their grounded answer changes, turning a store into an alerting tool.
+package monitor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+)
+
+// Watch is a registered question with the answer it had when last checked
+type Watch struct {
+	ID        string     `json:"id"`
+	Question  string     `json:"question"`
+	Store     string     `json:"store"` // Store display name
+	Answer    string     `json:"answer,omitempty"`
+	Sources   []string   `json:"sources,omitempty"`
+	Hash      string     `json:"hash,omitempty"` // Hash of the grounding the answer was based on
+	CreatedAt time.Time  `json:"createdAt"`
+	CheckedAt *time.Time `json:"checkedAt,omitempty"`
+	ChangedAt *time.Time `json:"changedAt,omitempty"`
+}
+
+// Change is a watched question whose grounded answer changed
+type Change struct {
+	Watch          *Watch   `json:"watch"`
+	PreviousAnswer string   `json:"previousAnswer"`
+	Diff           string   `json:"diff"`
+	AddedSources   []string `json:"addedSources,omitempty"`
+	RemovedSources []string `json:"removedSources,omitempty"`
+}
+
+// Registry keeps watched questions, optionally persisted to a JSON file
+type Registry struct {
+	path string
+
+	mu      sync.Mutex
+	watches map[string]*Watch
+}
+
+// LoadRegistry reads watched questions from path. A missing file yields an empty registry;
+// an empty path keeps watches in memory only.
+func LoadRegistry(path string) (*Registry, error) {
+	r := &Registry{
+		path:    path,
+		watches: make(map[string]*Watch),
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watches: %w", err)
+	}
+
+	var watches []*Watch
+	if err := json.Unmarshal(data, &watches); err != nil {
+		return nil, fmt.Errorf("failed to decode watches: %w", err)
+	}
+	for _, w := range watches {
+		r.watches[w.ID] = w
+	}
+
+	return r, nil
+}
+
+// watchID identifies a question on a store, so registering it twice keeps one watch
+func watchID(store, question string) string {
+	sum := sha256.Sum256([]byte(store + "\x00" + strings.TrimSpace(question)))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Add registers a question on a store. Its answer is recorded on the next Check.
+func (r *Registry) Add(store, question string) (*Watch, error) {
+	question = strings.TrimSpace(question)
+	if store == "" || question == "" {
+		return nil, fmt.Errorf("store and question are required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := watchID(store, question)
+	if w, ok := r.watches[id]; ok {
+		return w, nil
+	}
+	w := &Watch{ID: id, Question: question, Store: store, CreatedAt: time.Now()}
+	r.watches[id] = w
+	return w, r.saveLocked()
+}
+
+// Remove unregisters a question
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.watches[id]; !ok {
+		return fmt.Errorf("watch %q not found", id)
+	}
+	delete(r.watches, id)
+	return r.saveLocked()
+}
+
+// List returns all watches, oldest first
+func (r *Registry) List() []*Watch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.listLocked()
+}
+
+func (r *Registry) listLocked() []*Watch {
+	list := make([]*Watch, 0, len(r.watches))
+	for _, w := range r.watches {
+		list = append(list, w)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+func (r *Registry) saveLocked() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.listLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watches: %w", err)
+	}
+	if err := os.WriteFile(r.path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write watches: %w", err)
+	}
+	return os.Rename(r.path+".tmp", r.path)
+}
+
+// storeResolver resolves store display names to store names, see filesearch.Service
+type storeResolver interface {
+	GetStoreByName(ctx context.Context, displayName string) (*filesearch.Store, error)
+}
+
+// Check re-asks every watched question and notifies about the ones whose grounded answer
+// changed. The first check of a question only records its answer. Questions that fail are
+// logged in the returned error and checked again next time. Store display names are
+// resolved when the provider is a File Search service and passed as is otherwise.
+func (r *Registry) Check(ctx context.Context, provider filesearch.Provider, notifier Notifier) ([]*Change, error) {
+	resolver, _ := provider.(storeResolver)
+	stores := make(map[string]string)
+	var changes []*Change
+	var errs []error
+
+	for _, w := range r.List() {
+		storeName, ok := stores[w.Store]
+		if !ok {
+			storeName = w.Store
+			if resolver != nil {
+				store, err := resolver.GetStoreByName(ctx, w.Store)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("watch %s: %w", w.ID, err))
+					continue
+				}
+				storeName = store.Name
+			}
+			stores[w.Store] = storeName
+		}
+
+		resp, err := provider.PromptWithRetrieval(ctx, w.Question, storeName, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("watch %s: %w", w.ID, err))
+			continue
+		}
+
+		change, err := r.update(w.ID, resp)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("watch %s: %w", w.ID, err))
+			continue
+		}
+		if change == nil {
+			continue
+		}
+		changes = append(changes, change)
+
+		if notifier != nil {
+			if err := notifier.Notify(ctx, change); err != nil {
+				errs = append(errs, fmt.Errorf("watch %s: failed to notify: %w", w.ID, err))
+			}
+		}
+	}
+
+	return changes, errors.Join(errs...)
+}
+
+// update records a new answer for a watch and returns the change, if any
+func (r *Registry) update(id string, resp *filesearch.PromptResponse) (*Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.watches[id]
+	if !ok {
+		// Removed while the question was being asked
+		return nil, nil
+	}
+
+	now := time.Now()
+	hash := groundingHash(resp.GroundingSupport)
+	sources := sourceNames(resp.GroundingSupport)
+	answer := resp.Text()
+
+	var change *Change
+	if w.Hash != "" && w.Hash != hash {
+		previous := *w
+		w.ChangedAt = &now
+		change = &Change{
+			PreviousAnswer: previous.Answer,
+			Diff:           Diff(previous.Answer, answer),
+			AddedSources:   missing(sources, previous.Sources),
+			RemovedSources: missing(previous.Sources, sources),
+		}
+	}
+
+	w.Answer = answer
+	w.Sources = sources
+	w.Hash = hash
+	w.CheckedAt = &now
+	if err := r.saveLocked(); err != nil {
+		return nil, err
+	}
+
+	if change != nil {
+		current := *w
+		change.Watch = &current
+	}
+	return change, nil
+}
+
+// groundingHash hashes the retrieved chunks an answer was grounded in. Generated wording
+// varies between runs, so only a change in the underlying documents counts as a change.
+func groundingHash(gs *filesearch.GroundingSupport) string {
+	var chunks []string
+	if gs != nil {
+		for _, chunk := range gs.GroundingChunks {
+			if chunk.File != nil {
+				chunks = append(chunks, chunk.File.FileName+"\x00"+strings.Join(strings.Fields(chunk.File.Text), " "))
+			}
+		}
+	}
+	sort.Strings(chunks)
+
+	h := sha256.New()
+	for _, chunk := range chunks {
+		h.Write([]byte(chunk))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sourceNames returns the sorted unique file names an answer was grounded in
+func sourceNames(gs *filesearch.GroundingSupport) []string {
+	if gs == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, chunk := range gs.GroundingChunks {
+		if chunk.File != nil && !seen[chunk.File.FileName] {
+			seen[chunk.File.FileName] = true
+			names = append(names, chunk.File.FileName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// missing returns the names in a that are not in b
+func missing(a, b []string) []string {
+	var out []string
+	for _, name := range a {
+		if !slices.Contains(b, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
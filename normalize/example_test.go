@@ -0,0 +1,32 @@
+package normalize_test
+
+import (
+	"fmt"
+
+	"rag/normalize"
+)
+
+func ExampleNormalizer() {
+	n := normalize.New(map[string]string{"loonbrief": "loonfiche"})
+	for _, q := range []string{
+		"hoeveel is de eindjaarspremie in pc124?",
+		"hoeveel vakantie geld krijg ik in 1240000",
+		"Mijn werkgever betaalt geen mobiliteitsvergoding",
+		"opzegtermijn bij ontslag in paritair comite nr. 124",
+		"Combien de jours de preavis en CP 200 ?",
+		"prime de fin d annee commision paritaire 140.03",
+		"Quel est le bareme des employes en P.C. 3180200",
+		"waar staat dat op mijn loonbrief",
+	} {
+		fmt.Println(n.Normalize(q))
+	}
+	// Output:
+	// hoeveel is de eindejaarspremie in PC 124?
+	// hoeveel vakantiegeld krijg ik in PC 124
+	// Mijn werkgever betaalt geen mobiliteitsvergoeding
+	// opzeggingstermijn bij ontslag in PC 124
+	// Combien de jours de préavis en CP 200 ?
+	// prime de fin d'année CP 140.03
+	// Quel est le barème des employes en PC 318.02
+	// waar staat dat op mijn loonfiche
+}
@@ -0,0 +1,296 @@
+// Package normalize cleans up questions typed by users before they are routed and answered:
+// joint committee references are written one way, legal terms get their spelling and accents
+// back, and near misses of those terms are corrected. Retrieval then finds the documents that
+// use the official terms, in Dutch or French, however the question spelled them.
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"rag/langdetect"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Words shorter than minFuzzyLength runes are only corrected by the dictionary, as short
+// words are too often another valid word one edit away
+const minFuzzyLength = 7
+
+// jcPattern matches joint committee references: "PC 124", "pc124", "P.C. 124", "PC nr. 124",
+// "CP 318.02", "PC 3180200", "paritair comité 124", "commission paritaire n° 124" and 7-digit
+// numbers like "1240000"
+var jcPattern = regexp.MustCompile(`(?i)(?:\b(p\.?\s?c\.?|c\.?\s?p\.?|jc|paritair\s+comit[eé]+|commission\s+paritaire)\s*(?:(?:nr|no|n°|num(?:mer|éro|ero)?)\.?\s*)?-?\s*(\d{3})(?:[./,](\d{2})|(\d{2})00)?|\b(\d{3})(\d{2})00)\b`)
+
+// Normalizer rewrites questions. It is safe for concurrent use.
+type Normalizer struct {
+	terms      map[string]string // folded word or phrase -> spelling to use
+	maxWords   int               // Words of the longest phrase in terms
+	vocabulary []string          // Folded words of the terms, for fuzzy correction
+	spelled    map[string]string // folded vocabulary word -> its spelling
+}
+
+// New creates a normalizer with the default dictionary and extra corrections, mapping a
+// misspelled word or phrase to its correct spelling
+func New(corrections map[string]string) *Normalizer {
+	n := &Normalizer{
+		terms:   make(map[string]string),
+		spelled: make(map[string]string),
+	}
+	for _, term := range DefaultTerms {
+		n.addTerm(term)
+	}
+	for wrong, right := range DefaultCorrections {
+		n.addCorrection(wrong, right)
+	}
+	for wrong, right := range corrections {
+		n.addTerm(right)
+		n.addCorrection(wrong, right)
+	}
+	return n
+}
+
+// Load creates a normalizer with the corrections in a JSON file, an object mapping
+// misspellings to correct spellings
+func Load(path string) (*Normalizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corrections: %w", err)
+	}
+	var corrections map[string]string
+	if err := json.Unmarshal(data, &corrections); err != nil {
+		return nil, fmt.Errorf("failed to parse corrections: %w", err)
+	}
+	return New(corrections), nil
+}
+
+// addTerm adds a correctly spelled term, so it is restored from any case or accents and its
+// long words are found by fuzzy correction
+func (n *Normalizer) addTerm(term string) {
+	n.addCorrection(term, term)
+	for _, word := range strings.Fields(term) {
+		key := fold(word)
+		if utf8.RuneCountInString(key) < minFuzzyLength {
+			continue
+		}
+		if _, ok := n.spelled[key]; !ok {
+			n.spelled[key] = word
+			n.vocabulary = append(n.vocabulary, key)
+		}
+	}
+}
+
+func (n *Normalizer) addCorrection(wrong, right string) {
+	key := strings.Join(strings.Fields(fold(wrong)), " ")
+	if key == "" {
+		return
+	}
+	n.terms[key] = right
+	n.maxWords = max(n.maxWords, len(strings.Fields(key)))
+}
+
+// Normalize returns the question with its legal terms spelled as in the dictionary and its
+// joint committee references written "PC 124", or "CP 124" in French. Other words are kept.
+func (n *Normalizer) Normalize(query string) string {
+	query = norm.NFC.String(query)
+	query = strings.NewReplacer("’", "'", "‘", "'", "´", "'").Replace(query)
+	query = strings.Join(strings.Fields(query), " ")
+
+	return committees(n.correct(query))
+}
+
+// committees writes joint committee references in their usual form: "CP" for French
+// references, "PC" for Dutch ones, and bare numbers by the language of the question
+func committees(query string) string {
+	bare := "PC"
+	if lang, _ := langdetect.Detect(query); lang == langdetect.French {
+		bare = "CP"
+	}
+	return jcPattern.ReplaceAllStringFunc(query, func(ref string) string {
+		m := jcPattern.FindStringSubmatch(ref)
+		prefix, main, sub := bare, m[2], m[3]+m[4]
+		switch written := fold(strings.NewReplacer(".", "", " ", "").Replace(m[1])); {
+		case written == "":
+			main, sub = m[5], m[6]
+		case written == "cp" || strings.HasPrefix(written, "commission"):
+			prefix = "CP"
+		default:
+			prefix = "PC"
+		}
+		if n, _ := strconv.Atoi(sub); n == 0 {
+			return prefix + " " + main
+		}
+		return prefix + " " + main + "." + sub
+	})
+}
+
+// token is a word of a question with the text before it
+type token struct {
+	space string
+	word  string
+}
+
+// correct replaces the words and phrases of the dictionary and the near misses of its words
+func (n *Normalizer) correct(query string) string {
+	tokens := split(query)
+
+	var sb strings.Builder
+	for i := 0; i < len(tokens); {
+		sb.WriteString(tokens[i].space)
+
+		// The longest phrase of the dictionary starting here
+		matched := 0
+		for words := min(n.maxWords, len(tokens)-i); words > 0 && matched == 0; words-- {
+			parts := make([]string, words)
+			for j := range parts {
+				parts[j] = fold(tokens[i+j].word)
+			}
+			if right, ok := n.terms[strings.Join(parts, " ")]; ok {
+				sb.WriteString(capitalizeLike(right, tokens[i].word))
+				matched = words
+			}
+		}
+		if matched > 0 {
+			i += matched
+			continue
+		}
+
+		sb.WriteString(n.fuzzy(tokens[i].word))
+		i++
+	}
+	return sb.String()
+}
+
+// fuzzy returns the vocabulary word a long word is one edit away from, or two for words of
+// at least 11 runes, or the word itself when there is no single closest word. Inflections,
+// where one word starts with the other, are not corrected.
+func (n *Normalizer) fuzzy(word string) string {
+	key := fold(word)
+	length := utf8.RuneCountInString(key)
+	if length < minFuzzyLength || strings.ContainsFunc(key, unicode.IsDigit) {
+		return word
+	}
+	limit := 1
+	if length >= 11 {
+		limit = 2
+	}
+
+	best, bestDistance, ties := "", limit+1, 0
+	for _, candidate := range n.vocabulary {
+		if candidate == key {
+			return capitalizeLike(n.spelled[candidate], word)
+		}
+		if strings.HasPrefix(key, candidate) || strings.HasPrefix(candidate, key) {
+			continue
+		}
+		d := distance(key, candidate, limit)
+		switch {
+		case d < bestDistance:
+			best, bestDistance, ties = candidate, d, 0
+		case d == bestDistance:
+			ties++
+		}
+	}
+	if best == "" || ties > 0 {
+		return word
+	}
+	return capitalizeLike(n.spelled[best], word)
+}
+
+// split splits a question into words, keeping the text in between. Hyphens and apostrophes
+// within a word are part of it, e.g. "chèques-repas" and "d'année".
+func split(query string) []token {
+	var tokens []token
+	runes := []rune(query)
+	start := 0
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (isWordRune(runes[j]) || (runes[j] == '-' || runes[j] == '\'') && j+1 < len(runes) && isWordRune(runes[j+1])) {
+			j++
+		}
+		tokens = append(tokens, token{space: string(runes[start:i]), word: string(runes[i:j])})
+		start, i = j, j
+	}
+	if start < len(runes) {
+		tokens = append(tokens, token{space: string(runes[start:])})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// fold lowercases text and strips its accents, e.g. "Ancienneté" becomes "anciennete"
+func fold(text string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		if !unicode.Is(unicode.Mn, r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// capitalizeLike capitalizes the first letter of a correction when the original word had it
+func capitalizeLike(correction, original string) string {
+	first, _ := utf8.DecodeRuneInString(original)
+	if !unicode.IsUpper(first) {
+		return correction
+	}
+	r, size := utf8.DecodeRuneInString(correction)
+	return string(unicode.ToUpper(r)) + correction[size:]
+}
+
+// distance returns the optimal string alignment distance between two words, counting a
+// swap of adjacent letters as one edit, or limit+1 once it exceeds limit
+func distance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return min(prev[len(rb)], limit+1)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
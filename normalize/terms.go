@@ -0,0 +1,120 @@
+package normalize
+
+// DefaultTerms are legal terms of the Dutch and French agreements, spelled as the documents
+// spell them. Typed without accents or in another case, they get this spelling back.
+var DefaultTerms = []string{
+	// Dutch
+	"anciënniteit",
+	"arbeidsduur",
+	"arbeidsovereenkomst",
+	"arbeider",
+	"bediende",
+	"brutoloon",
+	"collectieve arbeidsovereenkomst",
+	"ecocheques",
+	"eindejaarspremie",
+	"fietsvergoeding",
+	"functieclassificatie",
+	"indexering",
+	"kilometervergoeding",
+	"klein verlet",
+	"loonschaal",
+	"maaltijdcheques",
+	"minimumloon",
+	"mobiliteitsvergoeding",
+	"nachtarbeid",
+	"nachtpremie",
+	"ontslagvergoeding",
+	"opzeggingstermijn",
+	"ouderschapsverlof",
+	"overuren",
+	"paritair comité",
+	"ploegenpremie",
+	"sectorpensioen",
+	"syndicale premie",
+	"tijdskrediet",
+	"vakantiegeld",
+	"werkgever",
+	"werknemer",
+	"werkloosheid",
+	"woon-werkverkeer",
+	"zondagsarbeid",
+
+	// French
+	"ancienneté",
+	"barème",
+	"chèques-repas",
+	"commission paritaire",
+	"congé",
+	"congé parental",
+	"convention collective de travail",
+	"crédit-temps",
+	"durée du travail",
+	"employé",
+	"employeur",
+	"heures supplémentaires",
+	"indemnité",
+	"indemnité de licenciement",
+	"jours fériés",
+	"licenciement",
+	"pécule de vacances",
+	"préavis",
+	"prime d'équipe",
+	"prime de fin d'année",
+	"rémunération",
+	"salaire minimum",
+	"travail de nuit",
+	"travailleur",
+}
+
+// DefaultCorrections map common misspellings from the chat UI, which accents or
+// fuzzy correction alone do not fix, to the terms of the documents
+var DefaultCorrections = map[string]string{
+	// Dutch: words split or joined wrongly, and the older spellings
+	"eindjaarspremie":                  "eindejaarspremie",
+	"eindejaars premie":                "eindejaarspremie",
+	"einde jaarspremie":                "eindejaarspremie",
+	"einde jaars premie":               "eindejaarspremie",
+	"vakantie geld":                    "vakantiegeld",
+	"maaltijd cheques":                 "maaltijdcheques",
+	"maaltijdcheque's":                 "maaltijdcheques",
+	"eco cheques":                      "ecocheques",
+	"eco-cheques":                      "ecocheques",
+	"ecocheque's":                      "ecocheques",
+	"opzegtermijn":                     "opzeggingstermijn",
+	"opzeg termijn":                    "opzeggingstermijn",
+	"ontslag vergoeding":               "ontslagvergoeding",
+	"over uren":                        "overuren",
+	"minimum loon":                     "minimumloon",
+	"bruto loon":                       "brutoloon",
+	"tijds krediet":                    "tijdskrediet",
+	"ouderschaps verlof":               "ouderschapsverlof",
+	"klein verlof":                     "klein verlet",
+	"ploeg premie":                     "ploegenpremie",
+	"ploegpremie":                      "ploegenpremie",
+	"nacht premie":                     "nachtpremie",
+	"paritair comitee":                 "paritair comité",
+	"paritaire comité":                 "paritair comité",
+	"collectieve arbeids overeenkomst": "collectieve arbeidsovereenkomst",
+	"woon werkverkeer":                 "woon-werkverkeer",
+	"woon werk verkeer":                "woon-werkverkeer",
+
+	// French: missing hyphens, elisions and plurals
+	"prime de fin d annee":  "prime de fin d'année",
+	"prime de fin dannee":   "prime de fin d'année",
+	"prime fin d'annee":     "prime de fin d'année",
+	"pecule de vacance":     "pécule de vacances",
+	"pecule vacances":       "pécule de vacances",
+	"cheque repas":          "chèques-repas",
+	"cheques repas":         "chèques-repas",
+	"cheque-repas":          "chèques-repas",
+	"credit temps":          "crédit-temps",
+	"heure supplementaire":  "heures supplémentaires",
+	"heures supplementaire": "heures supplémentaires",
+	"jour ferie":            "jours fériés",
+	"jours ferie":           "jours fériés",
+	"conge parentale":       "congé parental",
+	"commision paritaire":   "commission paritaire",
+	"comission paritaire":   "commission paritaire",
+	"prime d equipe":        "prime d'équipe",
+}
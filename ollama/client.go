@@ -0,0 +1,139 @@
+// Package ollama embeds texts and answers queries with a local Ollama server. Together with
+// the local vector index it runs the whole pipeline (ingest, retrieve, answer) on-prem.
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"rag/filesearch"
+)
+
+// Defaults for the client configuration
+const (
+	DefaultBaseURL        = "http://localhost:11434"
+	DefaultModel          = "llama3.1"
+	DefaultEmbeddingModel = "nomic-embed-text"
+	DefaultTopK           = 8
+)
+
+// Config holds the configuration for the client
+type Config struct {
+	BaseURL        string // Defaults to DefaultBaseURL
+	Model          string // Chat model, defaults to DefaultModel
+	EmbeddingModel string // Defaults to DefaultEmbeddingModel
+	TopK           int    // Chunks retrieved when the query does not set it, defaults to DefaultTopK
+	HTTPClient     *http.Client
+}
+
+// Client calls the Ollama API
+type Client struct {
+	cfg Config
+}
+
+// New creates a client, filling in the defaults
+func New(cfg Config) *Client {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel
+	}
+	if cfg.EmbeddingModel == "" {
+		cfg.EmbeddingModel = DefaultEmbeddingModel
+	}
+	if cfg.TopK <= 0 {
+		cfg.TopK = DefaultTopK
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Client{cfg: cfg}
+}
+
+// APIError is an error response of the Ollama API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ollama: %d: %s", e.StatusCode, e.Message)
+}
+
+// Unavailable reports whether the server is overloaded or failing, see filesearch.IsUnavailable
+func (e *APIError) Unavailable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// taskPrefixes are the instructions nomic-embed-text expects in front of each text
+var taskPrefixes = map[string]string{
+	filesearch.TaskRetrievalQuery:    "search_query: ",
+	filesearch.TaskRetrievalDocument: "search_document: ",
+	filesearch.TaskClustering:        "clustering: ",
+}
+
+// Embed embeds a batch of texts, returning one vector per text in order. It implements
+// vectorindex.Embedder, so `cao index build` can embed documents locally.
+func (c *Client) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	input := texts
+	if prefix := taskPrefixes[taskType]; prefix != "" && strings.HasPrefix(c.cfg.EmbeddingModel, "nomic-embed-text") {
+		input = make([]string, len(texts))
+		for i, text := range texts {
+			input[i] = prefix + text
+		}
+	}
+
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := c.post(ctx, "/api/embed", map[string]any{"model": c.cfg.EmbeddingModel, "input": input}, &resp); err != nil {
+		return nil, fmt.Errorf("failed to embed: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	return resp.Embeddings, nil
+}
+
+// post sends a JSON request to the API and decodes the response into out
+func (c *Client) post(ctx context.Context, path string, in any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.cfg.BaseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(data, &errResp)
+		return &APIError{StatusCode: resp.StatusCode, Message: errResp.Error}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
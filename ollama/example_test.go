@@ -0,0 +1,65 @@
+package ollama_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"rag/filesearch"
+	"rag/ollama"
+	"rag/vectorindex"
+)
+
+// staticRetriever always returns the same chunks
+type staticRetriever []*vectorindex.Match
+
+func (r staticRetriever) Retrieve(ctx context.Context, query string, topK int) ([]*vectorindex.Match, error) {
+	return r, nil
+}
+
+func ExampleProvider() {
+	// A stand-in for a local Ollama server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"message": {"role": "assistant", "content": "Het minimumuurloon bedraagt 14,05 EUR [1]. Jongeren krijgen een percentage daarvan [1, 2]."},
+			"prompt_eval_count": 380,
+			"eval_count": 25
+		}`)
+	}))
+	defer server.Close()
+
+	client := ollama.New(ollama.Config{BaseURL: server.URL})
+	provider := ollama.NewProvider(client, staticRetriever{
+		{Chunk: &vectorindex.Chunk{ID: "100-2022-011302.pdf#3", Document: "100-2022-011302.pdf", Page: 2,
+			Text: "Het minimumuurloon bedraagt 14,05 EUR voor werklieden van 18 jaar en ouder."}},
+		{Chunk: &vectorindex.Chunk{ID: "100-2022-011302.pdf#4", Document: "100-2022-011302.pdf", Page: 3,
+			Text: "Voor jongere werklieden bedraagt het loon een percentage van het minimumuurloon."}},
+	})
+
+	resp, err := provider.PromptWithRetrieval(context.Background(), "Wat is het minimumuurloon?", "", nil)
+	if err != nil {
+		panic(err)
+	}
+	answer := resp.Text()
+	for _, c := range resp.Citations {
+		fmt.Printf("%q:", answer[c.StartIndex:c.EndIndex])
+		for _, s := range c.Sources {
+			fmt.Printf(" %s", s.Title)
+		}
+		fmt.Println()
+	}
+	fmt.Println(len(filesearch.Footnotes(resp)), resp.Usage.TotalTokens)
+
+	// The local index cannot apply metadata filters
+	_, err = provider.RetrieveChunks(context.Background(), "Wat is het minimumuurloon?", "", &filesearch.RetrievalOptions{
+		MetadataFilter: `jc_number="100"`,
+	})
+	fmt.Println(errors.Is(err, vectorindex.ErrMetadataFilter))
+	// Output:
+	// "Het minimumuurloon bedraagt 14,05 EUR [1]": 100-2022-011302.pdf p.2
+	// "Jongeren krijgen een percentage daarvan [1, 2]": 100-2022-011302.pdf p.2 100-2022-011302.pdf p.3
+	// 1 405
+	// true
+}
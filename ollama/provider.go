@@ -0,0 +1,139 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"rag/filesearch"
+	"rag/vectorindex"
+)
+
+const systemPrompt = `You answer questions about Belgian collective labour agreements (CAO's) using only the numbered sources below the question.
+After every statement, cite the sources it is based on by number, e.g. [1] or [2, 3]. When the sources do not answer the question, say so instead of guessing.
+Answer in the language of the question.`
+
+// markerPattern matches source markers such as "[1]" and "[2, 3]"
+var markerPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// Retriever returns the chunks most relevant to a query, see vectorindex.Retriever
+type Retriever = vectorindex.MatchRetriever
+
+// Provider implements filesearch.Provider with an Ollama chat model answering from the
+// chunks the retriever finds
+type Provider struct {
+	client    *Client
+	retriever Retriever
+}
+
+var (
+	_ filesearch.Provider       = (*Provider)(nil)
+	_ filesearch.ChunkRetriever = (*Provider)(nil)
+	_ filesearch.ModelBackend   = (*Provider)(nil)
+)
+
+// NewProvider creates a provider answering with the client's chat model
+func NewProvider(client *Client, retriever Retriever) *Provider {
+	return &Provider{client: client, retriever: retriever}
+}
+
+// Embed embeds texts with the client's embedding model
+func (p *Provider) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	return p.client.Embed(ctx, texts, taskType)
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatResponse struct {
+	Message         chatMessage `json:"message"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+// PromptWithRetrieval retrieves the chunks most relevant to the prompt from the local index
+// and answers from them. The store name is not used: the local index holds a single store.
+// Metadata filters are not supported by the local index and fail, so a filter scoping
+// retrieval, e.g. to a tenant, is never silently ignored.
+func (p *Provider) PromptWithRetrieval(ctx context.Context, prompt string, storeName string, opts *filesearch.RetrievalOptions) (*filesearch.PromptResponse, error) {
+	matches, err := vectorindex.RetrieveMatches(ctx, p.retriever, prompt, p.client.cfg.TopK, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\nSources:\n")
+	for i, m := range matches {
+		fmt.Fprintf(&sb, "\n[%d] %s\n%s\n", i+1, m.Title(), m.Text)
+	}
+
+	var resp chatResponse
+	err = p.client.post(ctx, "/api/chat", map[string]any{
+		"model": p.client.cfg.Model,
+		"messages": []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: sb.String()},
+		},
+		"stream":  false,
+		"options": map[string]any{"temperature": 0},
+	}, &resp)
+	if err != nil {
+		return nil, vectorindex.GenerationError(matches, err)
+	}
+
+	answer := resp.Message.Content
+	return &filesearch.PromptResponse{
+		Parts:            []string{answer},
+		Citations:        citations(answer, matches),
+		GroundingSupport: vectorindex.GroundingSupport(matches),
+		Usage: &filesearch.TokenUsage{
+			PromptTokens:   resp.PromptEvalCount,
+			ResponseTokens: resp.EvalCount,
+			TotalTokens:    resp.PromptEvalCount + resp.EvalCount,
+		},
+	}, nil
+}
+
+// citations turns the source markers in an answer into citations spanning the text since
+// the previous marker or sentence boundary
+func citations(answer string, matches []*vectorindex.Match) []*filesearch.Citation {
+	var result []*filesearch.Citation
+	start := 0
+	for _, loc := range markerPattern.FindAllStringSubmatchIndex(answer, -1) {
+		// Start at the sentence the marker closes, or right after the previous marker
+		if i := strings.LastIndexAny(strings.TrimRight(answer[start:loc[0]], " .!?\n"), ".!?\n"); i >= 0 {
+			start += i + 1
+		}
+		for start < loc[0] && answer[start] == ' ' {
+			start++
+		}
+
+		c := &filesearch.Citation{StartIndex: start, EndIndex: loc[1]}
+		for _, n := range strings.Split(answer[loc[2]:loc[3]], ",") {
+			i, _ := strconv.Atoi(strings.TrimSpace(n))
+			if i >= 1 && i <= len(matches) {
+				c.Sources = append(c.Sources, &filesearch.Source{Title: matches[i-1].Title(), URI: matches[i-1].ID})
+			}
+		}
+		if len(c.Sources) > 0 {
+			result = append(result, c)
+		}
+		start = loc[1]
+	}
+	return result
+}
+
+// RetrieveChunks returns the chunks of the local index most similar to the query, with their
+// scores, without answering it. Metadata filters are not supported and fail.
+func (p *Provider) RetrieveChunks(ctx context.Context, query string, storeName string, opts *filesearch.RetrievalOptions) ([]*filesearch.RetrievedChunk, error) {
+	matches, err := vectorindex.RetrieveMatches(ctx, p.retriever, query, p.client.cfg.TopK, opts)
+	if err != nil {
+		return nil, err
+	}
+	return vectorindex.RetrievedChunks(matches), nil
+}
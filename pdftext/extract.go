@@ -0,0 +1,103 @@
+package pdftext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Document is the text extracted from a PDF, one string per page
+type Document struct {
+	Pages []string
+}
+
+// Text returns the text of all pages separated by form feeds
+func (d *Document) Text() string {
+	return strings.Join(d.Pages, "\f")
+}
+
+// PageAt returns the 1-based page number containing the byte offset in Text
+func (d *Document) PageAt(offset int) int {
+	end := 0
+	for i, page := range d.Pages {
+		end += len(page) + 1
+		if offset < end {
+			return i + 1
+		}
+	}
+	return len(d.Pages)
+}
+
+// ExtractFile extracts the text of a PDF file
+func ExtractFile(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	return Extract(data)
+}
+
+// ExtractReader extracts the text of a PDF read from r
+func ExtractReader(r io.Reader) (*Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	return Extract(data)
+}
+
+// Extract extracts the text of a PDF. Words are rebuilt from glyph positions because
+// many scanned CAO documents carry an OCR layer without explicit spaces.
+func Extract(data []byte) (doc *Document, err error) {
+	// The PDF parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	doc = &Document{Pages: make([]string, 0, reader.NumPage())}
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			doc.Pages = append(doc.Pages, "")
+			continue
+		}
+		doc.Pages = append(doc.Pages, pageText(page.Content().Text))
+	}
+
+	return doc, nil
+}
+
+// pageText joins positioned glyphs into lines and words
+func pageText(glyphs []pdf.Text) string {
+	var sb strings.Builder
+	var prev *pdf.Text
+
+	for i := range glyphs {
+		g := &glyphs[i]
+		if prev != nil {
+			size := math.Max(g.FontSize, 1)
+			switch {
+			case math.Abs(g.Y-prev.Y) > size*0.5:
+				sb.WriteByte('\n')
+			case g.X-(prev.X+prev.W) > size*0.2:
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(g.S)
+		prev = g
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,125 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rag/pipeline"
+)
+
+func ExampleParseSpec() {
+	spec, err := pipeline.ParseSpec([]byte(`
+name: hr-handbook
+source:
+  type: directory
+  path: handbook
+index:
+  store: hr-handbook
+`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Unset fields get their defaults
+	fmt.Println(spec.Source.Pattern, spec.Index.Backend, spec.Retrieval.Provider, spec.Retrieval.Model)
+
+	// Specs combining unsupported components are rejected
+	for _, yaml := range []string{
+		"source: {type: directory}\nindex: {store: hr-handbook}",
+		"source: {type: cao}\nindex: {backend: local, store: hr-handbook}",
+		"source: {type: cao}\nindex: {backend: local, store: hr-handbook}\nretrieval: {provider: ollama, metadataFilter: 'jc_number = 2000000'}",
+		"source: {type: cao}\ntransforms: [{type: rename}]\nindex: {store: hr-handbook}",
+	} {
+		_, err := pipeline.ParseSpec([]byte(yaml))
+		fmt.Println(err)
+	}
+	// Output:
+	// *.pdf filesearch gemini gemini-2.5-flash
+	// source.path is required for directory sources
+	// the local index backend requires retrieval.provider ollama
+	// retrieval.metadataFilter is not supported by the local index backend
+	// transforms[0]: unsupported transform "rename"
+}
+
+// Questions violating the guards are refused before they reach the index
+func ExampleRunner_Query() {
+	spec, err := pipeline.ParseSpec([]byte(`
+source: {type: cao}
+index: {backend: local, store: cao-documents}
+retrieval: {provider: ollama, model: llama3.1}
+guards:
+  maxQueryLength: 40
+  blocked: ["(?i)belastingaangifte"]
+  refusal: Deze vraag valt buiten de collectieve arbeidsovereenkomsten.
+`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner, err := pipeline.NewRunner(context.Background(), spec, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, question := range []string{
+		"Hoe vul ik mijn belastingaangifte in?",
+		"Wat is het minimumloon in de horeca als je 17 jaar bent?",
+	} {
+		_, err := runner.Query(context.Background(), question)
+		fmt.Println(errors.Is(err, pipeline.ErrQueryRejected), err)
+	}
+	// Output:
+	// true query rejected: Deze vraag valt buiten de collectieve arbeidsovereenkomsten.
+	// true query rejected: Deze vraag valt buiten de collectieve arbeidsovereenkomsten.
+}
+
+// The transforms select and rename the source documents before they are checked and indexed
+func ExampleRunner_Ingest() {
+	dir, err := os.MkdirTemp("", "pipeline")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "source")
+	if err := os.MkdirAll(source, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	// Empty documents, rejected by the quality checks, so nothing is embedded
+	for _, name := range []string{"100-2022-011302.pdf", "draft-100-2024-000001.pdf", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(source, name), nil, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	spec, err := pipeline.ParseSpec([]byte(fmt.Sprintf(`
+source: {type: directory, path: %q}
+transforms:
+  - {type: exclude, pattern: "draft-*"}
+  - {type: prefix, value: "hr-"}
+index: {backend: local, store: hr, path: %q, documents: %q}
+retrieval: {provider: ollama, model: llama3.1}
+`, source, filepath.Join(dir, "hr.jsonl"), filepath.Join(dir, "documents"))))
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner, err := pipeline.NewRunner(context.Background(), spec, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := runner.Ingest(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	names := make([]string, len(report.Issues))
+	for i, issue := range report.Issues {
+		names[i] = issue.Name
+	}
+	fmt.Println(report.Rejected, strings.Join(names, ", "))
+	// Output:
+	// 1 hr-100-2022-011302.pdf
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rag/ingest"
+	"rag/vectorindex"
+)
+
+// ingestLocal copies new documents into the documents directory of the local backend and
+// embeds them into its vector index. Documents failing the quality checks are not copied.
+// Dead letters and quotas only apply to File Search.
+func (r *Runner) ingestLocal(ctx context.Context) (*IngestReport, error) {
+	index, err := vectorindex.Load(r.spec.Index.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.spec.Index.Documents, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create documents directory: %w", err)
+	}
+
+	items, err := r.collect()
+	if err != nil {
+		return nil, err
+	}
+	items = r.transform(items)
+
+	// Indexed documents count for duplicates; flagged documents are indexed without a mark,
+	// as the local index keeps no metadata
+	quality := ingest.NewChecker(r.spec.Index.Quality)
+	for name, hash := range index.Hashes() {
+		quality.Add(name, hash)
+	}
+
+	report := &IngestReport{}
+	for _, it := range items {
+		if _, ok := index.Hash(it.Name); ok {
+			report.Skipped++
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		reader, err := it.open()
+		if err == nil {
+			var data []byte
+			if data, err = io.ReadAll(reader); err == nil {
+				if issue := report.check(quality, it.Name, data); issue != nil && issue.Rejected {
+					continue
+				}
+				err = os.WriteFile(filepath.Join(r.spec.Index.Documents, it.Name), data, 0o644)
+			}
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to read %s: %v", it.Name, err)
+			report.Failed++
+		}
+	}
+
+	// Embed the documents not yet in the index; indexed documents are skipped by hash
+	built, err := vectorindex.Build(ctx, vectorindex.BuildOptions{
+		Dir:    r.spec.Index.Documents,
+		Output: r.spec.Index.Path,
+	}, r.ollama)
+	if built != nil {
+		report.Uploaded = built.Indexed
+		report.Failed += built.Failed
+	}
+	return report, err
+}
@@ -0,0 +1,410 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"rag/caoscrape"
+	"rag/filesearch"
+	"rag/ingest"
+	"rag/ollama"
+	"rag/validity"
+	"rag/vectorindex"
+
+	"google.golang.org/genai"
+)
+
+// ErrQueryRejected is returned when a question is blocked by the pipeline guards
+var ErrQueryRejected = errors.New("query rejected")
+
+// item is a single document flowing through the pipeline
+type item struct {
+	Name      string
+	SourceURL string
+	Path      string // Local file, for directory sources
+	open      func() (io.Reader, error)
+}
+
+// IngestReport summarizes an ingestion run
+type IngestReport struct {
+	Uploaded int
+	Skipped  int
+	Failed   int
+	Rejected int                    // Not uploaded because of a quality issue
+	Issues   []*ingest.QualityIssue // Quality issues of rejected and flagged documents
+}
+
+// Runner executes a pipeline spec
+type Runner struct {
+	spec     *Spec
+	service  *filesearch.Service // File Search backend
+	ollama   *ollama.Client      // Local backend
+	scraper  *caoscrape.Client
+	template *template.Template
+	blocked  []*regexp.Regexp
+}
+
+// RunnerOption configures optional Runner behavior
+type RunnerOption func(*filesearch.Config)
+
+// WithHTTPClient sends the API calls of the runner through client, e.g. a vcr recorder in tests
+func WithHTTPClient(client *http.Client) RunnerOption {
+	return func(cfg *filesearch.Config) {
+		cfg.HTTPClient = client
+	}
+}
+
+// WithProfiles applies per-store answer profiles to the answers of the runner
+func WithProfiles(profiles *filesearch.Profiles) RunnerOption {
+	return func(cfg *filesearch.Config) {
+		cfg.Profiles = profiles
+	}
+}
+
+// WithCitationPolicy enforces a citation policy on the answers of the runner, see filesearch.CitationPolicy
+func WithCitationPolicy(policy *filesearch.CitationPolicy) RunnerOption {
+	return func(cfg *filesearch.Config) {
+		cfg.CitationPolicy = policy
+	}
+}
+
+// WithRetryPolicy retries File Search calls of the runner that fail with transient errors, see filesearch.RetryPolicy
+func WithRetryPolicy(policy *filesearch.RetryPolicy) RunnerOption {
+	return func(cfg *filesearch.Config) {
+		cfg.Retry = policy
+	}
+}
+
+// NewRunner creates a runner for the given spec. The API key is only used by the File Search backend.
+func NewRunner(ctx context.Context, spec *Spec, apiKey string, opts ...RunnerOption) (*Runner, error) {
+	tmpl, err := template.New("prompt").Parse(spec.Prompt.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+
+	blocked := make([]*regexp.Regexp, 0, len(spec.Guards.Blocked))
+	for _, pattern := range spec.Guards.Blocked {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guard pattern %q: %w", pattern, err)
+		}
+		blocked = append(blocked, re)
+	}
+
+	cfg := &filesearch.Config{
+		APIKey:    apiKey,
+		ModelName: spec.Retrieval.Model,
+		Backend:   genai.BackendGeminiAPI,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	r := &Runner{
+		spec:     spec,
+		scraper:  caoscrape.NewClient(),
+		template: tmpl,
+		blocked:  blocked,
+	}
+
+	if spec.Index.Backend == "local" {
+		r.ollama = ollama.New(ollama.Config{
+			BaseURL:        spec.Retrieval.URL,
+			Model:          spec.Retrieval.Model,
+			EmbeddingModel: spec.Retrieval.EmbeddingModel,
+			HTTPClient:     cfg.HTTPClient,
+		})
+		return r, nil
+	}
+
+	service, err := filesearch.NewService(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.service = service
+
+	return r, nil
+}
+
+// Provider returns the provider the runner answers questions with. For the local backend
+// it reads the current vector index.
+func (r *Runner) Provider() (filesearch.Provider, error) {
+	if r.ollama == nil {
+		return r.service, nil
+	}
+
+	index, err := vectorindex.Load(r.spec.Index.Path)
+	if err != nil {
+		return nil, err
+	}
+	return ollama.NewProvider(r.ollama, vectorindex.NewRetriever(index, r.ollama)), nil
+}
+
+// Ingest collects the source documents, applies the transforms and uploads new documents to the index
+func (r *Runner) Ingest(ctx context.Context) (*IngestReport, error) {
+	if r.ollama != nil {
+		return r.ingestLocal(ctx)
+	}
+
+	store, err := r.service.GetStoreByName(ctx, r.spec.Index.Store)
+	if err != nil {
+		store, err = r.service.CreateStore(ctx, r.spec.Index.Store)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	items, err := r.collect()
+	if err != nil {
+		return nil, err
+	}
+	items = r.transform(items)
+
+	existing := make(map[string]bool)
+	docs, err := r.service.ListDocuments(ctx, store.Name)
+	if err != nil {
+		log.Printf("Warning: Failed to list existing documents: %v", err)
+	}
+	for _, doc := range docs {
+		existing[doc.DisplayName] = true
+	}
+	quality := ingest.NewChecker(r.spec.Index.Quality)
+	quality.AddDocuments(docs)
+
+	sched := ingest.NewScheduler(r.spec.Index.Quota)
+	var deadLetters *ingest.DeadLetters
+	if r.spec.Index.DeadLetters != "" {
+		deadLetters, err = ingest.OpenDeadLetters(r.spec.Index.DeadLetters)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fail := func(it *item, stage string, err error) {
+		if deadLetters == nil {
+			return
+		}
+		if err := deadLetters.Record(&ingest.Failure{
+			Stage:       stage,
+			Name:        it.Name,
+			Store:       store.Name,
+			SourceURL:   it.SourceURL,
+			Path:        it.Path,
+			Error:       err.Error(),
+			AccessLabel: r.spec.Index.AccessLabel,
+		}, nil); err != nil {
+			log.Printf("Warning: Failed to record dead letter for %s: %v", it.Name, err)
+		}
+	}
+
+	report := &IngestReport{}
+	for _, it := range items {
+		if existing[it.Name] {
+			report.Skipped++
+			continue
+		}
+
+		reader, err := it.open()
+		if err != nil {
+			log.Printf("Warning: Failed to read %s: %v", it.Name, err)
+			fail(it, ingest.StageDownload, err)
+			report.Failed++
+			continue
+		}
+
+		// Record the period the document is in force so queries can ask for a date
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			log.Printf("Warning: Failed to read %s: %v", it.Name, err)
+			fail(it, ingest.StageDownload, err)
+			report.Failed++
+			continue
+		}
+
+		metadata := append(validity.Metadata(data), filesearch.AccessMetadata(r.spec.Index.AccessLabel)...)
+		if issue := report.check(quality, it.Name, data); issue != nil {
+			if issue.Rejected {
+				fail(it, ingest.StageQuality, errors.New(issue.Reason))
+				continue
+			}
+			metadata = append(metadata, &genai.CustomMetadata{Key: ingest.MetadataQualityIssue, StringValue: issue.Issue})
+		}
+		metadata = append(metadata, &genai.CustomMetadata{Key: filesearch.MetadataContentHash, StringValue: ingest.ContentHash(data)})
+
+		if err := sched.Wait(ctx); err != nil {
+			return report, err
+		}
+		if _, err := r.service.UploadDocumentWithMetadata(ctx, bytes.NewReader(data), it.Name, store.Name, it.SourceURL, metadata); err != nil {
+			log.Printf("Warning: Failed to upload %s: %v", it.Name, err)
+			fail(it, ingest.StageUpload, err)
+			report.Failed++
+			continue
+		}
+		report.Uploaded++
+	}
+
+	return report, nil
+}
+
+// check records the quality issue of a document in the report, nil when it has none
+func (report *IngestReport) check(quality *ingest.Checker, name string, data []byte) *ingest.QualityIssue {
+	issue := quality.Check(name, data)
+	if issue == nil {
+		return nil
+	}
+	report.Issues = append(report.Issues, issue)
+	if issue.Rejected {
+		log.Printf("Warning: Rejected %v", issue)
+		report.Rejected++
+	} else {
+		log.Printf("Warning: Uploading flagged %v", issue)
+	}
+	return issue
+}
+
+// Query checks the question against the guards, renders the prompt template and queries the index
+func (r *Runner) Query(ctx context.Context, question string) (*filesearch.PromptResponse, error) {
+	if err := r.guard(question); err != nil {
+		return nil, err
+	}
+
+	var prompt bytes.Buffer
+	if err := r.template.Execute(&prompt, struct{ Question string }{question}); err != nil {
+		return nil, fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	storeName := r.spec.Index.Store
+	if r.service != nil {
+		store, err := r.service.GetStoreByName(ctx, r.spec.Index.Store)
+		if err != nil {
+			return nil, err
+		}
+		storeName = store.Name
+	}
+
+	provider, err := r.Provider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.PromptWithRetrieval(ctx, prompt.String(), storeName, &filesearch.RetrievalOptions{
+		TopK:           r.spec.Retrieval.TopK,
+		MetadataFilter: r.spec.Retrieval.MetadataFilter,
+	})
+}
+
+// guard returns ErrQueryRejected when the question violates a guard
+func (r *Runner) guard(question string) error {
+	refusal := r.spec.Guards.Refusal
+	if refusal == "" {
+		refusal = "this question cannot be answered"
+	}
+
+	if max := r.spec.Guards.MaxQueryLength; max > 0 && len(question) > max {
+		return fmt.Errorf("%w: %s", ErrQueryRejected, refusal)
+	}
+	for _, re := range r.blocked {
+		if re.MatchString(question) {
+			return fmt.Errorf("%w: %s", ErrQueryRejected, refusal)
+		}
+	}
+
+	return nil
+}
+
+// collect lists the documents of the configured source
+func (r *Runner) collect() ([]*item, error) {
+	switch r.spec.Source.Type {
+	case "cao":
+		return r.collectCAO()
+	case "directory":
+		return r.collectDirectory()
+	}
+
+	return nil, fmt.Errorf("unsupported source type %q", r.spec.Source.Type)
+}
+
+func (r *Runner) collectCAO() ([]*item, error) {
+	var urls []string
+	if len(r.spec.Source.JC) == 0 {
+		found, err := r.scraper.Search(nil)
+		if err != nil {
+			return nil, err
+		}
+		urls = found
+	}
+	for _, jc := range r.spec.Source.JC {
+		found, err := r.scraper.Search(&jc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search JC %d: %w", jc, err)
+		}
+		urls = append(urls, found...)
+	}
+
+	items := make([]*item, 0, len(urls))
+	for _, url := range urls {
+		items = append(items, &item{
+			Name:      filepath.Base(url),
+			SourceURL: url,
+			open:      func() (io.Reader, error) { return r.scraper.DownloadDocument(url) },
+		})
+	}
+
+	return items, nil
+}
+
+func (r *Runner) collectDirectory() ([]*item, error) {
+	paths, err := filepath.Glob(filepath.Join(r.spec.Source.Path, r.spec.Source.Pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid source pattern: %w", err)
+	}
+
+	items := make([]*item, 0, len(paths))
+	for _, path := range paths {
+		items = append(items, &item{
+			Name: filepath.Base(path),
+			Path: path,
+			open: func() (io.Reader, error) {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return nil, err
+				}
+				return bytes.NewReader(data), nil
+			},
+		})
+	}
+
+	return items, nil
+}
+
+// transform applies the transforms in order
+func (r *Runner) transform(items []*item) []*item {
+	for _, t := range r.spec.Transforms {
+		kept := items[:0]
+		for _, it := range items {
+			switch t.Type {
+			case "include", "exclude":
+				matched, _ := filepath.Match(t.Pattern, it.Name)
+				if matched == (t.Type == "exclude") {
+					continue
+				}
+			case "prefix":
+				if !strings.HasPrefix(it.Name, t.Value) {
+					it.Name = t.Value + it.Name
+				}
+			}
+			kept = append(kept, it)
+		}
+		items = kept
+	}
+
+	return items
+}
@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"rag/ingest"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes a complete RAG pipeline: where documents come from, how they are transformed,
+// where they are indexed, and how questions against the index are asked
+type Spec struct {
+	Name       string          `yaml:"name"`
+	Source     SourceSpec      `yaml:"source"`
+	Transforms []TransformSpec `yaml:"transforms"`
+	Index      IndexSpec       `yaml:"index"`
+	Retrieval  RetrievalSpec   `yaml:"retrieval"`
+	Prompt     PromptSpec      `yaml:"prompt"`
+	Guards     GuardSpec       `yaml:"guards"`
+}
+
+// SourceSpec selects the documents that feed the pipeline
+type SourceSpec struct {
+	Type    string `yaml:"type"`    // "cao" or "directory"
+	JC      []int  `yaml:"jc"`      // cao: joint committee numbers, empty searches all documents
+	Path    string `yaml:"path"`    // directory: folder to read documents from
+	Pattern string `yaml:"pattern"` // directory: glob for file names (default "*.pdf")
+}
+
+// TransformSpec is a single step applied to every document before indexing
+type TransformSpec struct {
+	Type    string `yaml:"type"`    // "include", "exclude" or "prefix"
+	Pattern string `yaml:"pattern"` // include/exclude: glob matched against the file name
+	Value   string `yaml:"value"`   // prefix: text prepended to the display name
+}
+
+// IndexSpec selects the index backend and store
+type IndexSpec struct {
+	Backend     string       `yaml:"backend"`     // "filesearch" or "local"
+	Store       string       `yaml:"store"`       // store display name
+	Path        string       `yaml:"path"`        // local: vector index file (default "<store>.jsonl")
+	Documents   string       `yaml:"documents"`   // local: directory the documents are kept in (default "documents/<store>")
+	Quota       ingest.Quota `yaml:"quota"`       // upload budget; uploads pause when it is used up
+	DeadLetters string       `yaml:"deadLetters"` // directory where failed documents are recorded for `cao jobs retry`
+	AccessLabel string       `yaml:"accessLabel"` // filesearch: access label of the documents, see ACCESS_POLICY
+
+	Quality ingest.QualityOptions `yaml:"quality"` // issues to upload flagged rather than reject
+}
+
+// RetrievalSpec holds the retrieval and generation options used at query time
+type RetrievalSpec struct {
+	Provider       string `yaml:"provider"` // "gemini" or "ollama"; ollama requires the local backend
+	Model          string `yaml:"model"`
+	EmbeddingModel string `yaml:"embeddingModel"` // ollama: model embedding documents and questions
+	URL            string `yaml:"url"`            // ollama: server URL (default http://localhost:11434)
+	TopK           int    `yaml:"topK"`
+	MetadataFilter string `yaml:"metadataFilter"`
+}
+
+// PromptSpec holds the prompt template, a Go text/template receiving {{.Question}}
+type PromptSpec struct {
+	Template string `yaml:"template"`
+}
+
+// GuardSpec holds checks applied to questions before they reach the model
+type GuardSpec struct {
+	MaxQueryLength int      `yaml:"maxQueryLength"`
+	Blocked        []string `yaml:"blocked"` // regular expressions that reject a question
+	Refusal        string   `yaml:"refusal"` // message returned for rejected questions
+}
+
+// LoadSpec reads and validates a pipeline spec from a YAML file
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	return ParseSpec(data)
+}
+
+// ParseSpec parses and validates a pipeline spec from YAML
+func ParseSpec(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+
+	spec.applyDefaults()
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+func (s *Spec) applyDefaults() {
+	if s.Source.Type == "directory" && s.Source.Pattern == "" {
+		s.Source.Pattern = "*.pdf"
+	}
+	if s.Index.Backend == "" {
+		s.Index.Backend = "filesearch"
+	}
+	if s.Index.Backend == "local" {
+		if s.Index.Path == "" {
+			s.Index.Path = s.Index.Store + ".jsonl"
+		}
+		if s.Index.Documents == "" {
+			s.Index.Documents = filepath.Join("documents", s.Index.Store)
+		}
+	}
+	if s.Retrieval.Provider == "" {
+		s.Retrieval.Provider = "gemini"
+	}
+	if s.Retrieval.Model == "" && s.Retrieval.Provider == "gemini" {
+		s.Retrieval.Model = "gemini-2.5-flash"
+	}
+	if s.Prompt.Template == "" {
+		s.Prompt.Template = "{{.Question}}"
+	}
+}
+
+// Validate checks that the spec is complete and only uses supported components
+func (s *Spec) Validate() error {
+	switch s.Source.Type {
+	case "cao":
+	case "directory":
+		if s.Source.Path == "" {
+			return fmt.Errorf("source.path is required for directory sources")
+		}
+	default:
+		return fmt.Errorf("unsupported source type %q", s.Source.Type)
+	}
+
+	for i, t := range s.Transforms {
+		switch t.Type {
+		case "include", "exclude":
+			if t.Pattern == "" {
+				return fmt.Errorf("transforms[%d]: pattern is required for %s", i, t.Type)
+			}
+		case "prefix":
+			if t.Value == "" {
+				return fmt.Errorf("transforms[%d]: value is required for prefix", i)
+			}
+		default:
+			return fmt.Errorf("transforms[%d]: unsupported transform %q", i, t.Type)
+		}
+	}
+
+	switch s.Index.Backend {
+	case "filesearch":
+		if s.Retrieval.Provider != "gemini" {
+			return fmt.Errorf("retrieval.provider %q requires the local index backend", s.Retrieval.Provider)
+		}
+	case "local":
+		if s.Retrieval.Provider != "ollama" {
+			return fmt.Errorf("the local index backend requires retrieval.provider ollama")
+		}
+		if s.Retrieval.MetadataFilter != "" {
+			return fmt.Errorf("retrieval.metadataFilter is not supported by the local index backend")
+		}
+		if s.Index.AccessLabel != "" {
+			return fmt.Errorf("index.accessLabel is not supported by the local index backend")
+		}
+	default:
+		return fmt.Errorf("unsupported index backend %q", s.Index.Backend)
+	}
+	if s.Index.Store == "" {
+		return fmt.Errorf("index.store is required")
+	}
+	if s.Index.Quota.PerMinute < 0 || s.Index.Quota.PerDay < 0 {
+		return fmt.Errorf("index.quota must not be negative")
+	}
+	if err := s.Index.Quality.Validate(); err != nil {
+		return fmt.Errorf("index.quality: %w", err)
+	}
+
+	return nil
+}
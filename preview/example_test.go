@@ -0,0 +1,81 @@
+package preview_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"rag/filesearch"
+	"rag/preview"
+)
+
+// pdfPages returns a PDF with a page per line of text
+func pdfPages(lines ...string) []byte {
+	var objects []string
+	kids := make([]string, len(lines))
+	for i, line := range lines {
+		page, content := 4+2*i, 5+2*i
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+		stream := fmt.Sprintf("BT /F1 10 Tf 20 700 Td (%s) Tj ET", line)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", content),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream))
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(lines)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}, objects...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func ExampleGenerator_Preview() {
+	fetches := 0
+	fetch := func(ctx context.Context, doc *filesearch.Document) ([]byte, error) {
+		fetches++
+		return pdfPages(
+			"Collectieve arbeidsovereenkomst van 12 juni 2024",
+			strings.Repeat("loon ", 150),
+		), nil
+	}
+	generator := preview.NewGenerator(fetch, 0)
+
+	doc := &filesearch.Document{
+		Name:           "fileSearchStores/cao/documents/abc",
+		DisplayName:    "124-2024-012345.pdf",
+		CustomMetadata: map[string]string{"source_url": "https://example.org/124-2024-012345.pdf"},
+	}
+	p, err := generator.Preview(context.Background(), doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(p.Document, p.PageCount)
+	fmt.Println(p.Pages[0].Text)
+	// The second page is cut on a word boundary
+	fmt.Println(len([]rune(p.Pages[1].Text)), strings.HasSuffix(p.Pages[1].Text, "loon…"))
+
+	// The preview is cached
+	generator.Preview(context.Background(), doc)
+	fmt.Println("fetches:", fetches)
+	// Output:
+	// 124-2024-012345.pdf 2
+	// Collectieve arbeidsovereenkomst van 12 juni 2024
+	// 600 true
+	// fetches: 1
+}
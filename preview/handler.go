@@ -0,0 +1,109 @@
+package preview
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"rag/filesearch"
+)
+
+// Handler provides the HTTP API for document previews
+type Handler struct {
+	service    *filesearch.Service
+	generator  *Generator
+	accessible func(r *http.Request, doc *filesearch.Document) bool
+}
+
+// HandlerOption configures optional Handler behavior
+type HandlerOption func(*Handler)
+
+// WithAccess only previews the documents the caller of a request may see, e.g. under the
+// access policy of filesearch.Handler.Accessible; others are not found
+func WithAccess(accessible func(r *http.Request, doc *filesearch.Document) bool) HandlerOption {
+	return func(h *Handler) {
+		h.accessible = accessible
+	}
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(service *filesearch.Service, generator *Generator, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		service:   service,
+		generator: generator,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Preview handles GET requests for a document preview. The document is identified by the
+// last segment of its resource name or by its display name. With format=png the first-page
+// thumbnail is returned instead of the text preview.
+// GET /stores/{name}/documents/{id}/preview[?format=png]
+func (h *Handler) Preview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	doc, status, err := h.findDocument(r)
+	if err != nil {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if r.URL.Query().Get("format") == "png" {
+		png, err := h.generator.Thumbnail(r.Context(), doc)
+		if errors.Is(err, ErrRendererUnavailable) {
+			http.Error(w, "Thumbnails are not available", http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to render thumbnail: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		w.Write(png)
+		return
+	}
+
+	p, err := h.generator.Preview(r.Context(), doc)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to generate preview: " + err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *Handler) findDocument(r *http.Request) (*filesearch.Document, int, error) {
+	store, err := h.service.GetStoreByName(r.Context(), r.PathValue("name"))
+	if err != nil {
+		return nil, http.StatusNotFound, errors.New("Store not found: " + err.Error())
+	}
+
+	docs, err := h.service.CachedDocuments(r.Context(), store.Name)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Failed to list documents: " + err.Error())
+	}
+
+	id := r.PathValue("id")
+	for _, doc := range docs {
+		if (strings.HasSuffix(doc.Name, "/documents/"+id) || doc.DisplayName == id) && (h.accessible == nil || h.accessible(r, doc)) {
+			return doc, http.StatusOK, nil
+		}
+	}
+
+	return nil, http.StatusNotFound, errors.New("Document not found")
+}
@@ -0,0 +1,229 @@
+package preview
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"rag/doccache"
+	"rag/filesearch"
+	"rag/pdftext"
+)
+
+const (
+	// DefaultThumbnailWidth is the width in pixels of first-page thumbnails
+	DefaultThumbnailWidth = 200
+	// maxPageText is the number of characters of text kept per page preview
+	maxPageText = 600
+	// maxCachedDocuments is the number of documents whose preview and thumbnail are kept
+	maxCachedDocuments = 500
+)
+
+// ErrRendererUnavailable is returned when thumbnails cannot be rendered because pdftoppm is not installed
+var ErrRendererUnavailable = errors.New("pdftoppm not available")
+
+// PagePreview is the start of the text of a page
+type PagePreview struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+// Preview describes a document for display in source lists
+type Preview struct {
+	Document  string         `json:"document"`
+	SourceURL string         `json:"sourceUrl,omitempty"`
+	PageCount int            `json:"pageCount"`
+	Pages     []*PagePreview `json:"pages"`
+}
+
+// Fetcher returns the PDF bytes of a document
+type Fetcher func(ctx context.Context, doc *filesearch.Document) ([]byte, error)
+
+// LocalOrSourceFetcher reads documents from a local directory by display name and
+// falls back to downloading them from their source URL, with a *caoscrape.Client or
+// through a *doccache.Cache
+func LocalOrSourceFetcher(dir string, source doccache.Downloader) Fetcher {
+	return func(ctx context.Context, doc *filesearch.Document) ([]byte, error) {
+		if dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, filepath.Base(doc.DisplayName)))
+			if err == nil {
+				return data, nil
+			}
+		}
+
+		sourceURL := doc.CustomMetadata["source_url"]
+		if sourceURL == "" {
+			return nil, fmt.Errorf("document %q has no local copy or source URL", doc.DisplayName)
+		}
+		reader, err := source.DownloadDocument(sourceURL)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	}
+}
+
+// entry caches the generated preview and thumbnail of a document
+type entry struct {
+	name      string
+	preview   *Preview
+	thumbnail []byte
+}
+
+// Generator renders and caches document previews
+type Generator struct {
+	fetch Fetcher
+	width int
+
+	mu    sync.Mutex
+	order *list.List               // Most recently used first
+	cache map[string]*list.Element // document name -> element holding an *entry
+}
+
+// NewGenerator creates a preview generator. A width of zero uses DefaultThumbnailWidth.
+// The previews and thumbnails of the most recently used documents are cached.
+func NewGenerator(fetch Fetcher, width int) *Generator {
+	if width <= 0 {
+		width = DefaultThumbnailWidth
+	}
+	return &Generator{
+		fetch: fetch,
+		width: width,
+		order: list.New(),
+		cache: make(map[string]*list.Element),
+	}
+}
+
+// Preview returns the per-page text preview of a document
+func (g *Generator) Preview(ctx context.Context, doc *filesearch.Document) (*Preview, error) {
+	if e, ok := g.cached(doc.Name); ok && e.preview != nil {
+		return e.preview, nil
+	}
+
+	data, err := g.fetch(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	text, err := pdftext.Extract(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Preview{
+		Document:  doc.DisplayName,
+		SourceURL: doc.CustomMetadata["source_url"],
+		PageCount: len(text.Pages),
+		Pages:     make([]*PagePreview, 0, len(text.Pages)),
+	}
+	for i, page := range text.Pages {
+		p.Pages = append(p.Pages, &PagePreview{Page: i + 1, Text: truncate(page, maxPageText)})
+	}
+
+	g.update(doc.Name, func(e *entry) { e.preview = p })
+	return p, nil
+}
+
+// Thumbnail returns a PNG rendering of the first page of a document
+func (g *Generator) Thumbnail(ctx context.Context, doc *filesearch.Document) ([]byte, error) {
+	if e, ok := g.cached(doc.Name); ok && e.thumbnail != nil {
+		return e.thumbnail, nil
+	}
+
+	data, err := g.fetch(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	png, err := renderFirstPage(ctx, data, g.width)
+	if err != nil {
+		return nil, err
+	}
+
+	g.update(doc.Name, func(e *entry) { e.thumbnail = png })
+	return png, nil
+}
+
+// cached returns a copy of the cache entry of a document
+func (g *Generator) cached(name string) (entry, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	elem, ok := g.cache[name]
+	if !ok {
+		return entry{}, false
+	}
+	g.order.MoveToFront(elem)
+	return *elem.Value.(*entry), true
+}
+
+// update changes the cache entry of a document, creating it and evicting the least recently
+// used documents beyond maxCachedDocuments
+func (g *Generator) update(name string, set func(e *entry)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	elem, ok := g.cache[name]
+	if ok {
+		g.order.MoveToFront(elem)
+	} else {
+		elem = g.order.PushFront(&entry{name: name})
+		g.cache[name] = elem
+	}
+	set(elem.Value.(*entry))
+
+	for g.order.Len() > maxCachedDocuments {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.cache, oldest.Value.(*entry).name)
+	}
+}
+
+// renderFirstPage renders the first page to PNG with pdftoppm (poppler-utils)
+func renderFirstPage(ctx context.Context, data []byte, width int) ([]byte, error) {
+	bin, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return nil, ErrRendererUnavailable
+	}
+
+	dir, err := os.MkdirTemp("", "preview")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "document.pdf")
+	if err := os.WriteFile(input, data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to", fmt.Sprint(width), input, filepath.Join(dir, "thumbnail"))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to render thumbnail: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return os.ReadFile(filepath.Join(dir, "thumbnail.png"))
+}
+
+// truncate shortens text to at most n characters on a word boundary
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > n/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,47 @@
+package prompts_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rag/prompts"
+)
+
+func Example() {
+	dir, err := os.MkdirTemp("", "prompts")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := prompts.Open(filepath.Join(dir, "prompts.db"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Publish("cao-documents", "Answer HR questions.", "")
+	store.Publish("cao-documents", "Answer HR questions and cite the article.", "Cite articles")
+	instruction, tag, _ := store.Prompt(context.Background(), "cao-documents")
+	fmt.Println(tag, instruction)
+
+	// A rollback makes the first version active again
+	if _, err := store.Rollback("cao-documents", 1); err != nil {
+		log.Fatal(err)
+	}
+	instruction, tag, _ = store.Prompt(context.Background(), "cao-documents")
+	fmt.Println(tag, instruction)
+
+	versions, _ := store.Versions("cao-documents")
+	for _, v := range versions {
+		fmt.Println(v.Tag(), v.Active)
+	}
+	// Output:
+	// v2 Answer HR questions and cite the article.
+	// v1 Answer HR questions.
+	// v2 false
+	// v1 true
+}
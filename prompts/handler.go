@@ -0,0 +1,109 @@
+package prompts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// PublishRequest represents a request to publish a new version of a store's instruction
+type PublishRequest struct {
+	Instruction string `json:"instruction"`
+	Note        string `json:"note,omitempty"`
+}
+
+// RollbackRequest represents a request to make an earlier version active again
+type RollbackRequest struct {
+	Version int `json:"version"`
+}
+
+// Handler provides the HTTP admin API for prompt versions
+type Handler struct {
+	store *Store
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(store *Store) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+// List handles GET requests listing the active version of every store
+// GET /admin/prompts
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.store.List()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if versions == nil {
+		versions = []*Version{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"prompts": versions})
+}
+
+// Versions handles GET requests listing the versions of a store, newest first
+// GET /admin/prompts/{store}
+func (h *Handler) Versions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.store.Versions(r.PathValue("store"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(versions) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrNotFound.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"versions": versions})
+}
+
+// Publish handles POST requests publishing a new version of a store's instruction, which
+// answers use from then on
+// POST /admin/prompts/{store}
+// Body: {"instruction": "...", "note": "Cite articles"}
+func (h *Handler) Publish(w http.ResponseWriter, r *http.Request) {
+	var req PublishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	v, err := h.store.Publish(r.PathValue("store"), req.Instruction, req.Note)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// Rollback handles POST requests making an earlier version of a store's instruction active
+// POST /admin/prompts/{store}/rollback
+// Body: {"version": 2}
+func (h *Handler) Rollback(w http.ResponseWriter, r *http.Request) {
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	v, err := h.store.Rollback(r.PathValue("store"), req.Version)
+	if err != nil {
+		writeJSON(w, statusFor(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func statusFor(err error) int {
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
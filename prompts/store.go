@@ -0,0 +1,219 @@
+// Package prompts keeps versioned system instructions per store in SQLite. Publishing an
+// instruction adds a version and makes it active; a rollback makes an earlier version active
+// again at once, when a prompt change degrades answers. Answers are tagged with the version
+// they were written with, see filesearch.Config.Prompts.
+package prompts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ErrNotFound is returned for stores without prompt templates and unknown versions
+var ErrNotFound = errors.New("prompt version not found")
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS prompt_versions (
+	store       TEXT NOT NULL,
+	version     INTEGER NOT NULL,
+	instruction TEXT NOT NULL,
+	note        TEXT NOT NULL,
+	created_at  INTEGER NOT NULL,
+	PRIMARY KEY (store, version)
+)`,
+	`CREATE TABLE IF NOT EXISTS prompt_active (
+	store      TEXT PRIMARY KEY,
+	version    INTEGER NOT NULL,
+	changed_at INTEGER NOT NULL
+)`,
+}
+
+// Version is a published system instruction of a store
+type Version struct {
+	Store       string    `json:"store"` // Display name of the store
+	Version     int       `json:"version"`
+	Instruction string    `json:"instruction"`
+	Note        string    `json:"note,omitempty"` // Why the instruction changed
+	CreatedAt   time.Time `json:"createdAt"`
+	Active      bool      `json:"active"`
+}
+
+// Tag returns the version as answers are tagged with it, e.g. "v3"
+func (v *Version) Tag() string {
+	return "v" + strconv.Itoa(v.Version)
+}
+
+// Store keeps prompt versions in a SQLite database
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// Open opens, or creates, the prompt database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prompt database: %w", err)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create prompt tables: %w", err)
+		}
+	}
+
+	return &Store{db: db, now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Publish adds an instruction as the next version of a store and makes it active
+func (s *Store) Publish(store string, instruction string, note string) (*Version, error) {
+	if store == "" {
+		return nil, fmt.Errorf("store is required")
+	}
+	if strings.TrimSpace(instruction) == "" {
+		return nil, fmt.Errorf("instruction is required")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish prompt: %w", err)
+	}
+	defer tx.Rollback()
+
+	var latest int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM prompt_versions WHERE store = ?`, store).Scan(&latest); err != nil {
+		return nil, fmt.Errorf("failed to publish prompt: %w", err)
+	}
+	now := s.now().UTC().Truncate(time.Second)
+	v := &Version{Store: store, Version: latest + 1, Instruction: instruction, Note: note, CreatedAt: now, Active: true}
+	if _, err := tx.Exec(`INSERT INTO prompt_versions (store, version, instruction, note, created_at) VALUES (?, ?, ?, ?, ?)`,
+		v.Store, v.Version, v.Instruction, v.Note, now.Unix()); err != nil {
+		return nil, fmt.Errorf("failed to publish prompt: %w", err)
+	}
+	if err := activate(tx, store, v.Version, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to publish prompt: %w", err)
+	}
+	return v, nil
+}
+
+// Rollback makes an earlier version of a store active again
+func (s *Store) Rollback(store string, version int) (*Version, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back prompt: %w", err)
+	}
+	defer tx.Rollback()
+
+	v, err := scanVersion(tx.QueryRow(`SELECT store, version, instruction, note, created_at FROM prompt_versions
+		WHERE store = ? AND version = ?`, store, version))
+	if err != nil {
+		return nil, err
+	}
+	if err := activate(tx, store, version, s.now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to roll back prompt: %w", err)
+	}
+	v.Active = true
+	return v, nil
+}
+
+// Active returns the version of a store in use, ErrNotFound when it has none
+func (s *Store) Active(store string) (*Version, error) {
+	v, err := scanVersion(s.db.QueryRow(`SELECT v.store, v.version, v.instruction, v.note, v.created_at
+		FROM prompt_active a JOIN prompt_versions v ON v.store = a.store AND v.version = a.version
+		WHERE a.store = ?`, store))
+	if err != nil {
+		return nil, err
+	}
+	v.Active = true
+	return v, nil
+}
+
+// Versions returns the versions of a store, newest first
+func (s *Store) Versions(store string) ([]*Version, error) {
+	return s.list(`SELECT v.store, v.version, v.instruction, v.note, v.created_at, a.version IS NOT NULL
+		FROM prompt_versions v LEFT JOIN prompt_active a ON a.store = v.store AND a.version = v.version
+		WHERE v.store = ? ORDER BY v.version DESC`, store)
+}
+
+// List returns the active version of every store, by store
+func (s *Store) List() ([]*Version, error) {
+	return s.list(`SELECT v.store, v.version, v.instruction, v.note, v.created_at, 1
+		FROM prompt_active a JOIN prompt_versions v ON v.store = a.store AND v.version = a.version
+		ORDER BY v.store`)
+}
+
+// Prompt returns the active instruction of a store and its tag, both empty when the store has
+// none. It implements filesearch.PromptTemplates.
+func (s *Store) Prompt(ctx context.Context, store string) (string, string, error) {
+	v, err := s.Active(store)
+	if errors.Is(err, ErrNotFound) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return v.Instruction, v.Tag(), nil
+}
+
+func (s *Store) list(query string, args ...any) ([]*Version, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*Version
+	for rows.Next() {
+		var v Version
+		var created int64
+		if err := rows.Scan(&v.Store, &v.Version, &v.Instruction, &v.Note, &created, &v.Active); err != nil {
+			return nil, fmt.Errorf("failed to list prompts: %w", err)
+		}
+		v.CreatedAt = time.Unix(created, 0).UTC()
+		versions = append(versions, &v)
+	}
+	return versions, rows.Err()
+}
+
+// activate makes a version of a store the one in use
+func activate(tx *sql.Tx, store string, version int, now time.Time) error {
+	_, err := tx.Exec(`INSERT INTO prompt_active (store, version, changed_at) VALUES (?, ?, ?)
+		ON CONFLICT (store) DO UPDATE SET version = excluded.version, changed_at = excluded.changed_at`,
+		store, version, now.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to activate prompt version: %w", err)
+	}
+	return nil
+}
+
+func scanVersion(row *sql.Row) (*Version, error) {
+	var v Version
+	var created int64
+	err := row.Scan(&v.Store, &v.Version, &v.Instruction, &v.Note, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt: %w", err)
+	}
+	v.CreatedAt = time.Unix(created, 0).UTC()
+	return &v, nil
+}
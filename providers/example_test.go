@@ -0,0 +1,23 @@
+package providers_test
+
+import (
+	"fmt"
+
+	"rag/providers"
+)
+
+func ExampleNew() {
+	// Gemini File Search answers by default, with the service created by the command
+	_, err := providers.New(providers.Config{})
+	fmt.Println(err)
+
+	_, err = providers.New(providers.Config{Name: "openai"})
+	fmt.Println(err)
+
+	_, err = providers.New(providers.Config{Name: providers.Ollama, VectorIndex: "testdata/missing.jsonl"})
+	fmt.Println(err != nil)
+	// Output:
+	// provider gemini requires a Gemini service
+	// unknown provider "openai", expected gemini, local, anthropic or ollama
+	// true
+}
@@ -0,0 +1,147 @@
+// Package providers builds the model backend questions are answered with from its name, so
+// cao-server and the CLIs select Gemini File Search, a local store, Claude or Ollama the same way
+// and a new backend is added in one place.
+package providers
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"rag/anthropic"
+	"rag/filesearch"
+	"rag/localstore"
+	"rag/ollama"
+	"rag/vectorindex"
+)
+
+// Provider names
+const (
+	Gemini    = "gemini"    // Gemini File Search, the default
+	Local     = "local"     // Gemini answering from a local SQLite store instead of File Search
+	Anthropic = "anthropic" // Claude over the local vector index, embedding queries with Gemini
+	Ollama    = "ollama"    // A local Ollama server over the local vector index, on-prem
+)
+
+// DefaultVectorIndex is the local vector index file built by cao index build
+const DefaultVectorIndex = "index.jsonl"
+
+// DefaultLocalStore is the database of the Local provider
+const DefaultLocalStore = "localstore.db"
+
+// Config selects and configures a provider
+type Config struct {
+	Name        string                 // Gemini when empty, Local, Anthropic or Ollama
+	Service     *filesearch.Service    // Required by Gemini and Local, and by Anthropic to embed queries
+	VectorIndex string                 // Index file of Anthropic and Ollama, defaults to DefaultVectorIndex
+	LocalStore  string                 // Database of Local, defaults to DefaultLocalStore
+	Boosts      filesearch.ChunkBoosts // Optional feedback on chunks, applied by Local, Anthropic and Ollama
+	Anthropic   anthropic.Config
+	Ollama      ollama.Config
+}
+
+// ConfigFromEnv reads the configuration from PROVIDER, VECTOR_INDEX, LOCAL_STORE,
+// ANTHROPIC_API_KEY, ANTHROPIC_MODEL, OLLAMA_URL, OLLAMA_MODEL and OLLAMA_EMBED_MODEL. The Gemini service is left
+// for the command to create, unless the provider runs on-prem.
+func ConfigFromEnv() Config {
+	return Config{
+		Name:        os.Getenv("PROVIDER"),
+		VectorIndex: os.Getenv("VECTOR_INDEX"),
+		LocalStore:  os.Getenv("LOCAL_STORE"),
+		Anthropic: anthropic.Config{
+			APIKey: os.Getenv("ANTHROPIC_API_KEY"),
+			Model:  os.Getenv("ANTHROPIC_MODEL"),
+		},
+		Ollama: ollama.Config{
+			BaseURL:        os.Getenv("OLLAMA_URL"),
+			Model:          os.Getenv("OLLAMA_MODEL"),
+			EmbeddingModel: os.Getenv("OLLAMA_EMBED_MODEL"),
+		},
+	}
+}
+
+// OnPrem reports whether the provider runs without Gemini
+func (c *Config) OnPrem() bool {
+	return c.Name == Ollama
+}
+
+// Validate checks the provider name
+func (c *Config) Validate() error {
+	switch c.Name {
+	case "", Gemini, Local, Anthropic, Ollama:
+		return nil
+	default:
+		return fmt.Errorf("unknown provider %q, expected %s, %s, %s or %s", c.Name, Gemini, Local, Anthropic, Ollama)
+	}
+}
+
+// New returns the configured provider
+func New(cfg Config) (filesearch.ModelBackend, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Service == nil && !cfg.OnPrem() {
+		return nil, fmt.Errorf("provider %s requires a Gemini service", cfg.name())
+	}
+
+	switch cfg.Name {
+	case Local:
+		path := cfg.LocalStore
+		if path == "" {
+			path = DefaultLocalStore
+		}
+		local, err := localstore.Open(localstore.Config{Path: path, Embedder: cfg.Service, Generator: cfg.Service, Boosts: cfg.Boosts})
+		if err != nil {
+			return nil, err
+		}
+		return local, nil
+	case Anthropic:
+		index, err := loadIndex(cfg.VectorIndex)
+		if err != nil {
+			return nil, err
+		}
+		claude, err := anthropic.New(cfg.Anthropic, vectorindex.NewRetriever(index, cfg.Service).WithBoosts(cfg.Boosts))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Anthropic provider: %w", err)
+		}
+		return &withEmbedder{Provider: claude, Embedder: cfg.Service}, nil
+	case Ollama:
+		index, err := loadIndex(cfg.VectorIndex)
+		if err != nil {
+			return nil, err
+		}
+		client := ollama.New(cfg.Ollama)
+		return ollama.NewProvider(client, vectorindex.NewRetriever(index, client).WithBoosts(cfg.Boosts)), nil
+	default:
+		return cfg.Service, nil
+	}
+}
+
+func (c *Config) name() string {
+	if c.Name == "" {
+		return Gemini
+	}
+	return c.Name
+}
+
+// withEmbedder adds the embeddings of another backend to a provider that has none
+type withEmbedder struct {
+	*anthropic.Provider
+	filesearch.Embedder
+}
+
+// loadIndex loads a local vector index, which must not be empty
+func loadIndex(path string) (*vectorindex.Index, error) {
+	if path == "" {
+		path = DefaultVectorIndex
+	}
+	index, err := vectorindex.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vector index: %w", err)
+	}
+	if index.Len() == 0 {
+		return nil, fmt.Errorf("vector index %s is empty, build it with cao index build", path)
+	}
+	log.Printf("Loaded vector index %s: %d chunks of %d documents", path, index.Len(), index.Documents())
+	return index, nil
+}
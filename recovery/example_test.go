@@ -0,0 +1,68 @@
+package recovery_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"rag/recovery"
+)
+
+func ExampleMiddleware() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// A handler tripping over an unexpected response
+	var resp *http.Response
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, resp.Status)
+	})
+	reporter := recovery.ReporterFunc(func(ctx context.Context, p *recovery.Panic) error {
+		fmt.Println("reported", p.RequestID, p.Method, p.Path)
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/query", nil)
+	req.Header.Set(recovery.RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	recovery.Middleware(handler, reporter).ServeHTTP(rec, req)
+
+	fmt.Println(rec.Code, rec.Header().Get(recovery.RequestIDHeader))
+	fmt.Print(rec.Body.String())
+	// Output:
+	// reported req-42 POST /query
+	// 500 req-42
+	// {"error":"Internal server error","requestId":"req-42"}
+}
+
+func ExampleSentry() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	sentry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Println(r.URL.Path)
+		fmt.Println(strings.HasSuffix(r.Header.Get("X-Sentry-Auth"), "sentry_key=public"))
+		fmt.Println(strings.Contains(string(body), `"request_id":"req-7"`))
+	}))
+	defer sentry.Close()
+
+	reporter, err := recovery.Sentry(strings.Replace(sentry.URL, "//", "//public@", 1)+"/456", sentry.Client())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("index out of range")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/stores", nil)
+	req.Header.Set(recovery.RequestIDHeader, "req-7")
+	recovery.Middleware(handler, reporter).ServeHTTP(httptest.NewRecorder(), req)
+	// Output:
+	// /api/456/store/
+	// true
+	// true
+}
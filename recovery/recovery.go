@@ -0,0 +1,136 @@
+// Package recovery keeps the server running when a handler panics. Every request gets a
+// request ID; a panic is logged and reported with it, and the client receives a 500 JSON
+// error quoting the ID so the report can be found.
+package recovery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// RequestIDHeader carries the request ID. An ID sent by a proxy is kept, otherwise one is
+// generated; the response always carries it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestID is the longest request ID accepted from a client
+const maxRequestID = 128
+
+// Panic describes a recovered panic
+type Panic struct {
+	RequestID string
+	Method    string
+	Path      string
+	Value     any
+	Stack     []byte
+}
+
+// Error returns the panic value as a message
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Reporter receives recovered panics, e.g. to forward them to an error tracker
+type Reporter interface {
+	ReportPanic(ctx context.Context, p *Panic) error
+}
+
+// ReporterFunc adapts a function to a Reporter
+type ReporterFunc func(ctx context.Context, p *Panic) error
+
+func (f ReporterFunc) ReportPanic(ctx context.Context, p *Panic) error {
+	return f(ctx, p)
+}
+
+type contextKey struct{}
+
+// RequestIDFromContext returns the ID of the request being handled
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware assigns request IDs and recovers panics of next. Recovered panics are logged
+// and passed to reporter, which may be nil. http.ErrAbortHandler is not recovered, so
+// handlers can still abort a response.
+func Middleware(next http.Handler, reporter Reporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestID {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, id))
+
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			p := &Panic{
+				RequestID: id,
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Value:     v,
+				Stack:     debug.Stack(),
+			}
+			slog.Error("Recovered panic", "request_id", id, "method", p.Method, "path", p.Path, "panic", fmt.Sprint(v), "stack", string(p.Stack))
+			if reporter != nil {
+				// The request context may be cancelled once the response is written
+				if err := reporter.ReportPanic(context.WithoutCancel(r.Context()), p); err != nil {
+					slog.Warn("Failed to report panic", "request_id", id, "error", err)
+				}
+			}
+
+			if rw.wroteHeader {
+				// Too late for an error response; end it so the client sees it is incomplete
+				panic(http.ErrAbortHandler)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":     "Internal server error",
+				"requestId": id,
+			})
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// responseWriter records whether the response was started
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
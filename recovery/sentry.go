@@ -0,0 +1,112 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// sentryTimeout bounds how long a panic report may hold up the response
+const sentryTimeout = 5 * time.Second
+
+// sentryEvent is the part of a Sentry event the reporter fills in
+type sentryEvent struct {
+	EventID    string            `json:"event_id"`
+	Timestamp  string            `json:"timestamp"`
+	Level      string            `json:"level"`
+	Platform   string            `json:"platform"`
+	ServerName string            `json:"server_name,omitempty"`
+	Message    string            `json:"message"`
+	Exception  sentryExceptions  `json:"exception"`
+	Request    sentryRequest     `json:"request"`
+	Tags       map[string]string `json:"tags"`
+	Extra      map[string]string `json:"extra"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// Sentry reports panics to the Sentry project of a DSN such as
+// "https://key@o123.ingest.sentry.io/456". A nil client uses http.DefaultClient.
+func Sentry(dsn string, client *http.Client) (Reporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %w", err)
+	}
+	key := u.User.Username()
+	path := strings.Trim(u.Path, "/")
+	prefix, project := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix, project = "/"+path[:i], path[i+1:]
+	}
+	if key == "" || u.Host == "" || project == "" {
+		return nil, errors.New("invalid Sentry DSN: expected https://key@host/project")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project)
+	auth := "Sentry sentry_version=7, sentry_client=rag-recovery/1.0, sentry_key=" + key
+	hostname, _ := os.Hostname()
+
+	return ReporterFunc(func(ctx context.Context, p *Panic) error {
+		id := make([]byte, 16)
+		rand.Read(id)
+		body, err := json.Marshal(&sentryEvent{
+			EventID:    hex.EncodeToString(id),
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+			Level:      "fatal",
+			Platform:   "go",
+			ServerName: hostname,
+			Message:    p.Error(),
+			Exception:  sentryExceptions{Values: []sentryException{{Type: "panic", Value: fmt.Sprint(p.Value)}}},
+			Request:    sentryRequest{Method: p.Method, URL: p.Path},
+			Tags:       map[string]string{"request_id": p.RequestID},
+			Extra:      map[string]string{"stack": string(p.Stack)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode Sentry event: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, sentryTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Sentry-Auth", auth)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("Sentry returned %s", resp.Status)
+		}
+		return nil
+	}), nil
+}
@@ -0,0 +1,39 @@
+package retention_test
+
+import (
+	"fmt"
+	"time"
+
+	"rag/filesearch"
+	"rag/retention"
+)
+
+func ExamplePlan() {
+	created := func(date string) string {
+		t, _ := time.Parse(time.DateOnly, date)
+		return t.String()
+	}
+
+	docs := []*filesearch.Document{
+		{Name: "documents/a2", DisplayName: "100-2022-011302.pdf", CreateTime: created("2025-06-01")},
+		{Name: "documents/a1", DisplayName: "100-2022-011302.pdf", CreateTime: created("2025-01-01")},
+		{Name: "documents/b", DisplayName: "100-2008-006869.pdf", CreateTime: created("2023-03-01")},
+		{Name: "documents/c", DisplayName: "302-2024-001234.pdf", CreateTime: created("2025-05-01")},
+		{Name: "documents/d", DisplayName: "302-2023-004321.pdf", CreateTime: created("2025-04-01")},
+	}
+
+	policy := &retention.Policy{
+		MaxDocuments:   2,
+		MaxAgeDays:     365,
+		KeepLatestOnly: true,
+	}
+
+	now, _ := time.Parse(time.DateOnly, "2025-07-01")
+	for _, e := range retention.Plan(docs, policy, now) {
+		fmt.Printf("%s (%s): %s\n", e.Document.DisplayName, e.Document.Name, e.Reason)
+	}
+	// Output:
+	// 100-2022-011302.pdf (documents/a1): old-version
+	// 100-2008-006869.pdf (documents/b): max-age
+	// 302-2023-004321.pdf (documents/d): max-documents
+}
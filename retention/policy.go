@@ -0,0 +1,198 @@
+// Package retention enforces per-store retention policies so stores fed by continuous
+// sync do not grow without bound.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"rag/filesearch"
+
+	"gopkg.in/yaml.v3"
+)
+
+// timeLayout is the format of Document.CreateTime
+const timeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// Eviction reasons
+const (
+	ReasonOldVersion   = "old-version"
+	ReasonMaxAge       = "max-age"
+	ReasonMaxDocuments = "max-documents"
+)
+
+// Policy limits the documents kept in a store. Zero values disable a limit.
+type Policy struct {
+	MaxDocuments   int  `yaml:"maxDocuments"`   // Newest documents kept
+	MaxAgeDays     int  `yaml:"maxAgeDays"`     // Documents uploaded longer ago are removed
+	KeepLatestOnly bool `yaml:"keepLatestOnly"` // Only the newest upload of each document is kept
+	// VersionKey is the custom metadata key identifying versions of the same document.
+	// Without it, uploads with the same display name are versions of each other.
+	VersionKey string `yaml:"versionKey"`
+}
+
+// Config maps store display names to their policy
+type Config struct {
+	Stores map[string]*Policy `yaml:"stores"`
+}
+
+// LoadConfig reads retention policies from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retention config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse retention config: %w", err)
+	}
+	for store, policy := range cfg.Stores {
+		if policy == nil || policy.MaxDocuments < 0 || policy.MaxAgeDays < 0 {
+			return nil, fmt.Errorf("invalid retention policy for store %q", store)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// Eviction is a document selected for removal
+type Eviction struct {
+	Document *filesearch.Document
+	Reason   string
+}
+
+// Plan returns the documents the policy removes, evaluated at now.
+// Versions are evicted first, then documents past the maximum age, then the oldest
+// documents beyond the maximum count.
+func Plan(docs []*filesearch.Document, policy *Policy, now time.Time) []*Eviction {
+	type dated struct {
+		doc     *filesearch.Document
+		created time.Time
+	}
+
+	// Newest first; documents with an unknown upload time sort last
+	kept := make([]dated, 0, len(docs))
+	for _, doc := range docs {
+		created, _ := time.Parse(timeLayout, doc.CreateTime)
+		kept = append(kept, dated{doc, created})
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].created.After(kept[j].created) })
+
+	var evictions []*Eviction
+	evict := func(keep func(i int, d dated) bool, reason string) {
+		next := kept[:0]
+		for i, d := range kept {
+			if keep(i, d) {
+				next = append(next, d)
+				continue
+			}
+			evictions = append(evictions, &Eviction{Document: d.doc, Reason: reason})
+		}
+		kept = next
+	}
+
+	if policy.KeepLatestOnly {
+		seen := make(map[string]bool)
+		evict(func(_ int, d dated) bool {
+			key := d.doc.DisplayName
+			if policy.VersionKey != "" && d.doc.CustomMetadata[policy.VersionKey] != "" {
+				key = d.doc.CustomMetadata[policy.VersionKey]
+			}
+			if seen[key] {
+				return false
+			}
+			seen[key] = true
+			return true
+		}, ReasonOldVersion)
+	}
+
+	if policy.MaxAgeDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+		evict(func(_ int, d dated) bool {
+			return d.created.IsZero() || d.created.After(cutoff)
+		}, ReasonMaxAge)
+	}
+
+	if policy.MaxDocuments > 0 {
+		evict(func(i int, _ dated) bool {
+			return i < policy.MaxDocuments
+		}, ReasonMaxDocuments)
+	}
+
+	return evictions
+}
+
+// Report summarizes a maintenance run
+type Report struct {
+	Evictions []*Eviction
+	Deleted   int
+	Failed    int
+}
+
+// Enforce applies the configured policies to every store. With dryRun, the evictions
+// are reported but no documents are deleted.
+func Enforce(ctx context.Context, service *filesearch.Service, cfg *Config, dryRun bool) (*Report, error) {
+	report := &Report{}
+
+	stores := make([]string, 0, len(cfg.Stores))
+	for name := range cfg.Stores {
+		stores = append(stores, name)
+	}
+	sort.Strings(stores)
+
+	for _, name := range stores {
+		store, err := service.GetStoreByName(ctx, name)
+		if err != nil {
+			log.Printf("Warning: Skipping retention for %s: %v", name, err)
+			continue
+		}
+
+		docs, err := service.ListDocuments(ctx, store.Name)
+		if err != nil {
+			return report, err
+		}
+
+		evictions := Plan(docs, cfg.Stores[name], time.Now())
+		report.Evictions = append(report.Evictions, evictions...)
+		if dryRun {
+			continue
+		}
+
+		for _, e := range evictions {
+			if err := service.DeleteDocument(ctx, e.Document.Name); err != nil {
+				log.Printf("Warning: Failed to delete %s: %v", e.Document.DisplayName, err)
+				report.Failed++
+				continue
+			}
+			report.Deleted++
+		}
+	}
+
+	return report, nil
+}
+
+// Schedule enforces the policies every interval until ctx is cancelled
+func Schedule(ctx context.Context, service *filesearch.Service, cfg *Config, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := Enforce(ctx, service, cfg, false)
+		if err != nil {
+			log.Printf("Warning: Retention run failed: %v", err)
+		} else if len(report.Evictions) > 0 {
+			log.Printf("Retention: %d documents deleted, %d failed", report.Deleted, report.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
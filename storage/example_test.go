@@ -0,0 +1,100 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+
+	"rag/storage"
+)
+
+func ExampleOpen() {
+	dir, err := os.MkdirTemp("", "storage")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	store, err := storage.Open(ctx, dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Every subsystem keeps its files under its own prefix
+	documents := storage.Sub(store, "documents")
+	if err := documents.Put(ctx, "302-2023-004512.pdf", []byte("%PDF-1.7")); err != nil {
+		log.Fatal(err)
+	}
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(keys)
+
+	_, err = documents.Get(ctx, "missing.pdf")
+	fmt.Println(err)
+	// Output:
+	// [documents/302-2023-004512.pdf]
+	// not found: documents/missing.pdf
+}
+
+// bucket is a minimal S3-compatible server keeping objects in memory
+type bucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/cao/")
+	switch r.Method {
+	case http.MethodPut:
+		b.objects[key], _ = io.ReadAll(r.Body)
+	case http.MethodGet:
+		data, ok := b.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func ExampleNewS3() {
+	server := httptest.NewServer(&bucket{objects: make(map[string][]byte)})
+	defer server.Close()
+
+	store, err := storage.NewS3("cao", &storage.S3Config{
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio-secret",
+		Endpoint:        server.URL,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := store.Put(ctx, "documents/302-2023-004512.pdf", []byte("%PDF-1.7")); err != nil {
+		log.Fatal(err)
+	}
+	data, err := store.Get(ctx, "documents/302-2023-004512.pdf")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(data))
+	// Output:
+	// %PDF-1.7
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+)
+
+// gcsScope is the OAuth scope for reading and writing objects
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// GCSConfig holds the credentials and endpoint of a Cloud Storage bucket
+type GCSConfig struct {
+	Tokens     auth.TokenProvider // Nil uses Application Default Credentials
+	Endpoint   string             // Default "https://storage.googleapis.com"
+	HTTPClient *http.Client       // Optional
+}
+
+// GCS stores blobs as objects in a Google Cloud Storage bucket, through the JSON API
+type GCS struct {
+	bucket   string
+	tokens   auth.TokenProvider
+	endpoint string
+	client   *http.Client
+}
+
+var _ Storage = (*GCS)(nil)
+
+// NewGCS returns the storage of a Cloud Storage bucket. A nil config authenticates with
+// Application Default Credentials, e.g. GOOGLE_APPLICATION_CREDENTIALS or the service account
+// of the instance.
+func NewGCS(ctx context.Context, bucket string, config *GCSConfig) (*GCS, error) {
+	if config == nil {
+		config = &GCSConfig{}
+	}
+	g := &GCS{bucket: bucket, tokens: config.Tokens, endpoint: config.Endpoint, client: config.HTTPClient}
+	if g.tokens == nil {
+		creds, err := credentials.DetectDefault(&credentials.DetectOptions{Scopes: []string{gcsScope}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find Google Cloud credentials: %w", err)
+		}
+		g.tokens = creds
+	}
+	if g.endpoint == "" {
+		g.endpoint = "https://storage.googleapis.com"
+	}
+	g.endpoint = strings.TrimSuffix(g.endpoint, "/")
+	if g.client == nil {
+		g.client = http.DefaultClient
+	}
+	return g, nil
+}
+
+// Get implements Storage
+func (g *GCS) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := g.do(ctx, http.MethodGet, g.objectURL(key)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, gcsError("get", key, resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Put implements Storage
+func (g *GCS) Put(ctx context.Context, key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", g.endpoint, url.PathEscape(g.bucket), url.QueryEscape(key))
+	resp, err := g.do(ctx, http.MethodPost, u, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return gcsError("put", key, resp)
+	}
+	return nil
+}
+
+// Delete implements Storage
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := g.do(ctx, http.MethodDelete, g.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return gcsError("delete", key, resp)
+	}
+	return nil
+}
+
+// List implements Storage
+func (g *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		resp, err := g.do(ctx, http.MethodGet, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", g.endpoint, url.PathEscape(g.bucket), query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = gcsError("list", prefix, resp)
+		} else if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			err = fmt.Errorf("failed to decode Cloud Storage listing: %w", err)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range result.Items {
+			keys = append(keys, item.Name)
+		}
+		if result.NextPageToken == "" {
+			break
+		}
+		token = result.NextPageToken
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func (g *GCS) objectURL(key string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", g.endpoint, url.PathEscape(g.bucket), url.PathEscape(key))
+}
+
+// do sends an authorized request
+func (g *GCS) do(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	token, err := g.tokens.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google Cloud token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Value)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Cloud Storage request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// gcsError describes a failed Cloud Storage request
+func gcsError(op, key string, resp *http.Response) error {
+	var result struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&result) == nil && result.Error.Message != "" {
+		return fmt.Errorf("failed to %s %s: %s", op, key, result.Error.Message)
+	}
+	return fmt.Errorf("failed to %s %s: unexpected status code: %d", op, key, resp.StatusCode)
+}
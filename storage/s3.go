@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3Config holds the credentials and endpoint of an S3 bucket
+type S3Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string       // Optional, for temporary credentials
+	Region          string       // Default "us-east-1"
+	Endpoint        string       // S3-compatible service, e.g. "http://localhost:9000" for MinIO; empty for AWS
+	HTTPClient      *http.Client // Optional
+}
+
+// S3ConfigFromEnv reads the S3 configuration from the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION and AWS_ENDPOINT_URL variables
+func S3ConfigFromEnv() *S3Config {
+	return &S3Config{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          os.Getenv("AWS_REGION"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+	}
+}
+
+// S3 stores blobs as objects in an S3 bucket, signing requests with AWS Signature Version 4.
+// Any S3-compatible service works, including Google Cloud Storage with HMAC keys
+// (Endpoint "https://storage.googleapis.com", Region "auto").
+type S3 struct {
+	bucket string
+	config S3Config
+	base   *url.URL // Bucket URL, path-style for custom endpoints
+	client *http.Client
+}
+
+var _ Storage = (*S3)(nil)
+
+// NewS3 returns the storage of an S3 bucket
+func NewS3(bucket string, config *S3Config) (*S3, error) {
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 storage requires an access key ID and secret access key")
+	}
+	s := &S3{bucket: bucket, config: *config, client: config.HTTPClient}
+	if s.config.Region == "" {
+		s.config.Region = "us-east-1"
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+
+	var err error
+	if s.config.Endpoint == "" {
+		s.base, err = url.Parse(fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", bucket, s.config.Region))
+	} else {
+		s.base, err = url.Parse(strings.TrimSuffix(s.config.Endpoint, "/") + "/" + bucket + "/")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	return s, nil
+}
+
+// Get implements Storage
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error("get", key, resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Put implements Storage
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", key, resp)
+	}
+	return nil
+}
+
+// Delete implements Storage
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", key, resp)
+	}
+	return nil
+}
+
+// List implements Storage
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = s3Error("list", prefix, resp)
+		} else if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+			err = fmt.Errorf("failed to decode S3 listing: %w", err)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// do sends a signed request for an object, or for the bucket when key is empty
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *s.base
+	u.Path += key
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 authorization of a request
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+	}
+
+	var names []string
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	slices.Sort(names)
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	digest := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(digest[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	for _, part := range []string{s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signed, signature))
+	req.Header.Del("Host") // Sent from req.Host
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Error describes a failed S3 request
+func s3Error(op, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	var result struct {
+		Code    string
+		Message string
+	}
+	if xml.Unmarshal(body, &result) == nil && result.Code != "" {
+		return fmt.Errorf("failed to %s %s: %s: %s", op, key, result.Code, result.Message)
+	}
+	return fmt.Errorf("failed to %s %s: unexpected status code: %d", op, key, resp.StatusCode)
+}
@@ -0,0 +1,112 @@
+// Package storage stores blobs by key in a local directory, Amazon S3 (or an S3-compatible
+// service) or Google Cloud Storage. It is configured once, with Open, and shared by the
+// subsystems that keep files, each under its own prefix, instead of each hardcoding a local
+// path.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned when getting a key that is not stored
+var ErrNotFound = errors.New("not found")
+
+// Storage stores blobs by key. Keys are slash-separated paths, e.g. "documents/abc.pdf".
+type Storage interface {
+	// Get returns the blob stored under key, or an error wrapping ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores a blob under key, replacing the one stored before. Readers never see a
+	// partially written blob.
+	Put(ctx context.Context, key string, data []byte) error
+	// Delete removes the blob stored under key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns the keys starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Open returns the storage at a location: a directory path or file:// URL, s3://bucket/prefix
+// (see NewS3) or gs://bucket/prefix (see NewGCS)
+func Open(ctx context.Context, location string) (Storage, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 { // C:\ on Windows
+		return Dir(location), nil
+	}
+
+	if u.Scheme == "file" {
+		return Dir(u.Path), nil
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("storage %q has no bucket", location)
+	}
+
+	var s Storage
+	switch u.Scheme {
+	case "s3":
+		s, err = NewS3(u.Host, S3ConfigFromEnv())
+	case "gs":
+		s, err = NewGCS(ctx, u.Host, nil)
+	default:
+		return nil, fmt.Errorf("unsupported storage %q", location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Sub(s, u.Path), nil
+}
+
+// Sub returns the part of a storage under a prefix, e.g. for one subsystem
+func Sub(s Storage, prefix string) Storage {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return s
+	}
+	if sub, ok := s.(*subStorage); ok {
+		return &subStorage{parent: sub.parent, prefix: path.Join(sub.prefix, prefix)}
+	}
+	return &subStorage{parent: s, prefix: prefix}
+}
+
+type subStorage struct {
+	parent Storage
+	prefix string
+}
+
+func (s *subStorage) key(key string) string {
+	return s.prefix + "/" + key
+}
+
+func (s *subStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.parent.Get(ctx, s.key(key))
+}
+
+func (s *subStorage) Put(ctx context.Context, key string, data []byte) error {
+	return s.parent.Put(ctx, s.key(key), data)
+}
+
+func (s *subStorage) Delete(ctx context.Context, key string) error {
+	return s.parent.Delete(ctx, s.key(key))
+}
+
+func (s *subStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.parent.List(ctx, s.key(prefix))
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, s.prefix+"/")
+	}
+	return keys, nil
+}
+
+// checkKey rejects keys that could escape the storage
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
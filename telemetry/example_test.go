@@ -0,0 +1,48 @@
+package telemetry_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"rag/telemetry"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func ExampleMiddleware() {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tracer := telemetry.Tracer(tp)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Calls made while answering are children of the request span
+		_, span := tracer.Start(r.Context(), "gemini.generate")
+		span.SetAttributes(telemetry.InputTokensKey.Int(1200), telemetry.OutputTokensKey.Int(85))
+		span.End()
+
+		trace.SpanFromContext(r.Context()).SetAttributes(telemetry.StoreKey.String("cao-documents"))
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	rec := httptest.NewRecorder()
+	telemetry.Middleware(handler, tp).ServeHTTP(rec, httptest.NewRequest("POST", "/api/query", nil))
+
+	for _, span := range recorder.Ended() {
+		fmt.Println(span.Name(), span.Status().Code)
+		for _, attr := range span.Attributes() {
+			fmt.Printf("  %s=%s\n", attr.Key, attr.Value.Emit())
+		}
+	}
+	// Output:
+	// gemini.generate Unset
+	//   gen_ai.usage.input_tokens=1200
+	//   gen_ai.usage.output_tokens=85
+	// POST /api/query Error
+	//   http.request.method=POST
+	//   url.path=/api/query
+	//   rag.store=cao-documents
+	//   http.response.status_code=502
+}
@@ -0,0 +1,145 @@
+// Package telemetry traces requests with OpenTelemetry. Spans use the same attribute names
+// everywhere:
+//
+//	rag.store                   store display name or resource name
+//	rag.document                display name of a document
+//	rag.documents               number of documents or stores listed or found
+//	rag.jc                      joint committee number
+//	gen_ai.request.model        model asked to answer or embed
+//	gen_ai.usage.input_tokens   prompt tokens billed for an answer
+//	gen_ai.usage.output_tokens  response tokens billed for an answer
+//
+// Libraries take a trace.TracerProvider in their configuration and fall back to the global
+// provider, which records nothing until a command calls Setup.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// ScopeName is the instrumentation scope of the spans of this module
+const ScopeName = "rag"
+
+// Span attributes
+const (
+	StoreKey        = attribute.Key("rag.store")
+	DocumentKey     = attribute.Key("rag.document")
+	DocumentsKey    = attribute.Key("rag.documents")
+	JCKey           = attribute.Key("rag.jc")
+	ModelKey        = attribute.Key("gen_ai.request.model")
+	InputTokensKey  = attribute.Key("gen_ai.usage.input_tokens")
+	OutputTokensKey = attribute.Key("gen_ai.usage.output_tokens")
+	methodKey       = attribute.Key("http.request.method")
+	pathKey         = attribute.Key("url.path")
+	statusKey       = attribute.Key("http.response.status_code")
+	serviceNameKey  = attribute.Key("service.name")
+)
+
+// Setup installs the tracer provider of a command, named service unless OTEL_SERVICE_NAME
+// says otherwise. Spans are exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, configured by the standard OTEL_* variables such
+// as OTEL_EXPORTER_OTLP_HEADERS and OTEL_TRACES_SAMPLER. Otherwise tracing is off and the
+// provider records nothing. Call shutdown before exiting to flush the remaining spans.
+func Setup(ctx context.Context, service string) (tp trace.TracerProvider, shutdown func(context.Context) error, err error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(serviceNameKey.String(service)),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to describe service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider, provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of this module from tp, or from the global provider when tp is nil
+func Tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(ScopeName)
+}
+
+// End records a failed operation on a span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware starts a server span for every request, continuing the trace of the caller when
+// it sends a traceparent header. Handlers add their own attributes to the span of the request
+// context, see trace.SpanFromContext. Responses with a 5xx status mark the span as failed.
+func Middleware(next http.Handler, tp trace.TracerProvider) http.Handler {
+	tracer := Tracer(tp)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(methodKey.String(r.Method), pathKey.String(r.URL.Path)),
+		)
+		defer span.End()
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(statusKey.Int(rw.status))
+		if rw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
+
+// responseWriter records the status of the response
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
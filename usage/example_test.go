@@ -0,0 +1,108 @@
+package usage_test
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"rag/kv"
+	"rag/usage"
+)
+
+func ExampleTracker() {
+	hr := &usage.Tenant{Keys: []string{"hr-key"}, Quota: usage.Quota{DailyQueries: 2}}
+	tracker, err := usage.NewTracker(&usage.Config{
+		Tenants: map[string]*usage.Tenant{"hr": hr},
+	}, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tenant, _ := tracker.TenantForKey("hr-key")
+	for i := 0; i < 3; i++ {
+		if err := tracker.Allow(tenant, usage.KindQuery); err != nil {
+			fmt.Println(err, errors.Is(err, usage.ErrQuotaExceeded))
+			break
+		}
+		tracker.Record(tenant, usage.KindQuery, 1200)
+	}
+
+	for _, report := range tracker.Report(time.Now().Format("2006-01")) {
+		fmt.Printf("%s: %d queries, %d tokens\n", report.Tenant, report.Total.Queries, report.Total.Tokens)
+	}
+	// Output:
+	// quota exceeded: 2 queries per day true
+	// hr: 2 queries, 2400 tokens
+}
+
+// Concurrent requests check and count their query in one step, so they cannot exceed the quota
+func ExampleTracker_Take() {
+	path := filepath.Join(os.TempDir(), "usage-example.json")
+	defer os.Remove(path)
+
+	cfg := &usage.Config{Tenants: map[string]*usage.Tenant{
+		"hr": {Keys: []string{"hr-key"}, Quota: usage.Quota{DailyQueries: 5}},
+	}}
+	tracker, err := usage.NewTracker(cfg, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tenant, _ := tracker.TenantForKey("hr-key")
+
+	var taken atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tracker.Take(tenant, usage.KindQuery) == nil {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Println("taken:", taken.Load())
+
+	// Usage is saved by Persist, or Save, and loaded again after a restart
+	if err := tracker.Save(); err != nil {
+		log.Fatal(err)
+	}
+	restarted, err := usage.NewTracker(cfg, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(restarted.Take(tenant, usage.KindQuery))
+	// Output:
+	// taken: 5
+	// quota exceeded: 5 queries per day
+}
+
+// Replicas of a server share usage through a store, e.g. Redis with kv.Open("redis://...")
+func ExampleNewSharedTracker() {
+	cfg := &usage.Config{Tenants: map[string]*usage.Tenant{
+		"hr": {Keys: []string{"hr-key"}, Quota: usage.Quota{DailyQueries: 1}},
+	}}
+	store := kv.NewMemory()
+	first := usage.NewSharedTracker(cfg, store)
+	second := usage.NewSharedTracker(cfg, store)
+
+	tenant, _ := first.TenantForKey("hr-key")
+	if err := first.Record(tenant, usage.KindQuery, 800); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(second.Allow(tenant, usage.KindQuery))
+	fmt.Println(second.Take(tenant, usage.KindQuery))
+
+	for _, report := range second.Report(time.Now().Format("2006-01")) {
+		fmt.Printf("%s: %d queries, %d tokens\n", report.Tenant, report.Total.Queries, report.Total.Tokens)
+	}
+	// Output:
+	// quota exceeded: 1 queries per day
+	// quota exceeded: 1 queries per day
+	// hr: 1 queries, 800 tokens
+}
@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"rag/filesearch"
+)
+
+type contextKey struct{}
+
+// TenantFromContext returns the tenant of a request passed through Middleware
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(contextKey{}).(*Tenant)
+	return tenant, ok
+}
+
+// APIKey returns the API key of a request, sent as "Authorization: Bearer <key>" or "X-API-Key: <key>"
+func APIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// Middleware identifies the tenant by API key, rejects the request when the tenant has
+// used up its quota for kind and counts it otherwise
+func (t *Tracker) Middleware(kind string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := t.TenantForKey(APIKey(r))
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "A valid API key is required")
+			return
+		}
+
+		if err := t.Take(tenant, kind); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrQuotaExceeded) {
+				status = http.StatusTooManyRequests
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, tenant)))
+	}
+}
+
+// RecordQueryUsage accounts the tokens of an answered query to the tenant of the request.
+// It is a filesearch.UsageRecorder for requests passed through Middleware.
+func (t *Tracker) RecordQueryUsage(r *http.Request, u *filesearch.TokenUsage) {
+	if tenant, ok := TenantFromContext(r.Context()); ok {
+		t.RecordTokens(tenant, u.TotalTokens)
+	}
+}
+
+// ReportHandler handles GET requests for the usage report
+// GET /admin/usage?period=2026-10
+func (t *Tracker) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = t.now().Format("2006-01")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"period":  period,
+		"tenants": t.Report(period),
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
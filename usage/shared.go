@@ -0,0 +1,193 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"rag/kv"
+)
+
+// sharedTimeout bounds the calls to the shared store on the path of a request
+const sharedTimeout = 2 * time.Second
+
+// sharedKey returns the key of a counter of a tenant for a day or, for tokens, a month
+func sharedKey(tenant, period, counter string) string {
+	return "usage:" + tenant + ":" + period + ":" + counter
+}
+
+// sharedCurrent returns today's counters of a tenant and its token count of the month
+func (t *Tracker) sharedCurrent(tenant string, now time.Time) (*Counters, *Counters, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+	defer cancel()
+
+	today, err := t.sharedDay(ctx, tenant, now.Format(time.DateOnly))
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := t.sharedCounter(ctx, sharedKey(tenant, now.Format("2006-01"), "tokens"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return today, &Counters{Tokens: tokens}, nil
+}
+
+// sharedTake counts an action of a tenant unless it exceeds a quota. The daily counter is
+// incremented first and decremented again when over the quota, so requests racing on other
+// replicas cannot both take the last action.
+func (t *Tracker) sharedTake(tenant *Tenant, kind string) error {
+	now := t.now()
+	today, month, err := t.sharedCurrent(tenant.Name, now)
+	if err != nil {
+		return err
+	}
+	if err := tenant.Quota.check(kind, today, month); err != nil {
+		return err
+	}
+
+	var counter string
+	limit := 0
+	switch kind {
+	case KindQuery:
+		counter, limit = "queries", tenant.Quota.DailyQueries
+	case KindUpload:
+		counter, limit = "uploads", tenant.Quota.DailyUploads
+	default:
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+	defer cancel()
+	key := sharedKey(tenant.Name, now.Format(time.DateOnly), counter)
+	n, err := t.shared.Incr(ctx, key, 1, 0)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	if limit > 0 && n > int64(limit) {
+		if _, err := t.shared.Incr(ctx, key, -1, 0); err != nil {
+			log.Printf("Warning: failed to release usage of %s: %v", tenant.Name, err)
+		}
+		return fmt.Errorf("%w: %d %s per day", ErrQuotaExceeded, limit, counter)
+	}
+	return nil
+}
+
+// sharedRecord adds an action and its tokens to today's counters and the month's tokens
+func (t *Tracker) sharedRecord(tenant, kind string, tokens int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+	defer cancel()
+
+	now := t.now()
+	day := now.Format(time.DateOnly)
+	var counter string
+	switch kind {
+	case KindQuery:
+		counter = "queries"
+	case KindUpload:
+		counter = "uploads"
+	}
+	if counter != "" {
+		if _, err := t.shared.Incr(ctx, sharedKey(tenant, day, counter), 1, 0); err != nil {
+			return fmt.Errorf("failed to record usage: %w", err)
+		}
+	}
+	if tokens != 0 {
+		if _, err := t.shared.Incr(ctx, sharedKey(tenant, day, "tokens"), int64(tokens), 0); err != nil {
+			return fmt.Errorf("failed to record usage: %w", err)
+		}
+		if _, err := t.shared.Incr(ctx, sharedKey(tenant, now.Format("2006-01"), "tokens"), int64(tokens), 0); err != nil {
+			return fmt.Errorf("failed to record usage: %w", err)
+		}
+	}
+	return nil
+}
+
+// sharedReport reads the usage of every tenant for the days of a year, month or day
+func (t *Tracker) sharedReport(period string) []*TenantReport {
+	days := periodDays(period)
+	ctx := context.Background()
+
+	names := make([]string, 0, len(t.tenants))
+	for name := range t.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reports := make([]*TenantReport, 0, len(names))
+	for _, name := range names {
+		report := &TenantReport{
+			Tenant: name,
+			Quota:  t.tenants[name].Quota,
+			Days:   make(map[string]*Counters),
+		}
+		for _, day := range days {
+			c, err := t.sharedDay(ctx, name, day)
+			if err != nil {
+				log.Printf("Warning: failed to read usage of %s on %s: %v", name, day, err)
+				continue
+			}
+			if *c != (Counters{}) {
+				report.Days[day] = c
+				report.Total.add(c)
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// sharedDay returns the counters of a tenant for a day
+func (t *Tracker) sharedDay(ctx context.Context, tenant, day string) (*Counters, error) {
+	var c Counters
+	for _, counter := range []struct {
+		name  string
+		value *int
+	}{{"queries", &c.Queries}, {"uploads", &c.Uploads}, {"tokens", &c.Tokens}} {
+		n, err := t.sharedCounter(ctx, sharedKey(tenant, day, counter.name))
+		if err != nil {
+			return nil, err
+		}
+		*counter.value = n
+	}
+	return &c, nil
+}
+
+// sharedCounter returns the value of a counter, zero when it was never incremented
+func (t *Tracker) sharedCounter(ctx context.Context, key string) (int, error) {
+	data, err := t.shared.Get(ctx, key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read usage: %w", err)
+	}
+	n, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid usage counter %s", key)
+	}
+	return n, nil
+}
+
+// periodDays returns the days of a year "2026", month "2026-10" or day "2026-10-16"
+func periodDays(period string) []string {
+	var start, end time.Time
+	if day, err := time.Parse(time.DateOnly, period); err == nil {
+		start, end = day, day.AddDate(0, 0, 1)
+	} else if month, err := time.Parse("2006-01", period); err == nil {
+		start, end = month, month.AddDate(0, 1, 0)
+	} else if year, err := time.Parse("2006", period); err == nil {
+		start, end = year, year.AddDate(1, 0, 0)
+	} else {
+		return nil
+	}
+
+	var days []string
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day.Format(time.DateOnly))
+	}
+	return days
+}
@@ -0,0 +1,354 @@
+// Package usage accounts queries, tokens and uploads per tenant and enforces tenant quotas.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"rag/kv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Usage kinds
+const (
+	KindQuery  = "query"
+	KindUpload = "upload"
+)
+
+// ErrQuotaExceeded is returned when a tenant has used up a quota
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the usage of a tenant. Zero values are unlimited.
+type Quota struct {
+	DailyQueries  int `yaml:"dailyQueries" json:"dailyQueries,omitempty"`
+	DailyUploads  int `yaml:"dailyUploads" json:"dailyUploads,omitempty"`
+	MonthlyTokens int `yaml:"monthlyTokens" json:"monthlyTokens,omitempty"`
+}
+
+// Tenant is a department or client sharing the server
+type Tenant struct {
+	Name  string   `yaml:"-"`
+	Keys  []string `yaml:"keys"` // API keys identifying the tenant
+	Quota Quota    `yaml:"quota"`
+}
+
+// Config lists the tenants by name
+type Config struct {
+	Tenants map[string]*Tenant `yaml:"tenants"`
+}
+
+// LoadConfig reads tenants from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants: %w", err)
+	}
+
+	seen := make(map[string]string)
+	for name, tenant := range cfg.Tenants {
+		if tenant == nil || len(tenant.Keys) == 0 {
+			return nil, fmt.Errorf("tenant %q has no keys", name)
+		}
+		tenant.Name = name
+		for _, key := range tenant.Keys {
+			if other, ok := seen[key]; ok {
+				return nil, fmt.Errorf("tenants %q and %q share a key", other, name)
+			}
+			seen[key] = name
+		}
+	}
+
+	return &cfg, nil
+}
+
+// Counters is the usage of a tenant over a period
+type Counters struct {
+	Queries int `json:"queries"`
+	Uploads int `json:"uploads"`
+	Tokens  int `json:"tokens"`
+}
+
+func (c *Counters) add(other *Counters) {
+	c.Queries += other.Queries
+	c.Uploads += other.Uploads
+	c.Tokens += other.Tokens
+}
+
+// Tracker accounts usage per tenant and day
+type Tracker struct {
+	tenants map[string]*Tenant // by name
+	keys    map[string]*Tenant // by API key
+	path    string
+	shared  kv.Store // Replaces usage and path when set
+	now     func() time.Time
+
+	mu    sync.Mutex
+	usage map[string]map[string]*Counters // tenant -> day (YYYY-MM-DD) -> counters
+	dirty bool                            // Usage changed since it was last saved to path
+}
+
+// NewTracker creates a tracker for the configured tenants. When path is not empty,
+// usage is loaded from that JSON file and saved to it by Persist, so it survives restarts.
+func NewTracker(cfg *Config, path string) (*Tracker, error) {
+	t := newTracker(cfg)
+	t.path = path
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read usage: %w", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &t.usage); err != nil {
+				return nil, fmt.Errorf("failed to decode usage: %w", err)
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// NewSharedTracker creates a tracker keeping usage in a store shared by the replicas of a
+// server, so quotas hold across them. Requests racing on different replicas may exceed the
+// monthly token quota slightly; Take keeps them within the daily quotas.
+func NewSharedTracker(cfg *Config, store kv.Store) *Tracker {
+	t := newTracker(cfg)
+	t.shared = store
+	return t
+}
+
+func newTracker(cfg *Config) *Tracker {
+	t := &Tracker{
+		tenants: make(map[string]*Tenant),
+		keys:    make(map[string]*Tenant),
+		now:     time.Now,
+		usage:   make(map[string]map[string]*Counters),
+	}
+	for name, tenant := range cfg.Tenants {
+		tenant.Name = name
+		t.tenants[name] = tenant
+		for _, key := range tenant.Keys {
+			t.keys[key] = tenant
+		}
+	}
+	return t
+}
+
+// TenantForKey returns the tenant an API key belongs to
+func (t *Tracker) TenantForKey(key string) (*Tenant, bool) {
+	tenant, ok := t.keys[key]
+	return tenant, ok
+}
+
+// Allow checks whether the tenant may perform one more action of the given kind. Use Take
+// to also count the action, so concurrent requests cannot exceed the quota.
+func (t *Tracker) Allow(tenant *Tenant, kind string) error {
+	now := t.now()
+	if t.shared != nil {
+		today, month, err := t.sharedCurrent(tenant.Name, now)
+		if err != nil {
+			return err
+		}
+		return tenant.Quota.check(kind, today, month)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return tenant.Quota.check(kind, t.counters(tenant.Name, now), t.sumLocked(tenant.Name, now.Format("2006-01")))
+}
+
+// Take counts one more action of the given kind unless the tenant has used up its quota,
+// checking and counting in one step
+func (t *Tracker) Take(tenant *Tenant, kind string) error {
+	if t.shared != nil {
+		return t.sharedTake(tenant, kind)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	today := t.counters(tenant.Name, now)
+	if err := tenant.Quota.check(kind, today, t.sumLocked(tenant.Name, now.Format("2006-01"))); err != nil {
+		return err
+	}
+	today.add(&Counters{Queries: count(kind, KindQuery), Uploads: count(kind, KindUpload)})
+	t.dirty = true
+	return nil
+}
+
+// check returns ErrQuotaExceeded when one more action of kind exceeds the quota, given the
+// usage of today and of the month
+func (q Quota) check(kind string, today, month *Counters) error {
+	switch {
+	case kind == KindQuery && q.DailyQueries > 0 && today.Queries >= q.DailyQueries:
+		return fmt.Errorf("%w: %d queries per day", ErrQuotaExceeded, q.DailyQueries)
+	case kind == KindUpload && q.DailyUploads > 0 && today.Uploads >= q.DailyUploads:
+		return fmt.Errorf("%w: %d uploads per day", ErrQuotaExceeded, q.DailyUploads)
+	case q.MonthlyTokens > 0 && month.Tokens >= q.MonthlyTokens:
+		return fmt.Errorf("%w: %d tokens per month", ErrQuotaExceeded, q.MonthlyTokens)
+	}
+	return nil
+}
+
+// count returns 1 when kind is want, else 0
+func count(kind, want string) int {
+	if kind == want {
+		return 1
+	}
+	return 0
+}
+
+// Record adds an action of the given kind and the tokens it used to today's usage
+func (t *Tracker) Record(tenant *Tenant, kind string, tokens int) error {
+	if t.shared != nil {
+		return t.sharedRecord(tenant.Name, kind, tokens)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.counters(tenant.Name, t.now()).add(&Counters{
+		Queries: count(kind, KindQuery),
+		Uploads: count(kind, KindUpload),
+		Tokens:  tokens,
+	})
+	t.dirty = true
+	return nil
+}
+
+// RecordTokens adds tokens to today's usage without counting an action
+func (t *Tracker) RecordTokens(tenant *Tenant, tokens int) error {
+	return t.Record(tenant, "", tokens)
+}
+
+// counters returns the counters of a tenant for the day of now, creating them when missing
+func (t *Tracker) counters(tenant string, now time.Time) *Counters {
+	days, ok := t.usage[tenant]
+	if !ok {
+		days = make(map[string]*Counters)
+		t.usage[tenant] = days
+	}
+
+	day := now.Format(time.DateOnly)
+	c, ok := days[day]
+	if !ok {
+		c = &Counters{}
+		days[day] = c
+	}
+	return c
+}
+
+// sumLocked adds the counters of every day starting with prefix, e.g. a month "2026-10"
+func (t *Tracker) sumLocked(tenant string, prefix string) *Counters {
+	total := &Counters{}
+	for day, c := range t.usage[tenant] {
+		if strings.HasPrefix(day, prefix) {
+			total.add(c)
+		}
+	}
+	return total
+}
+
+// Persist saves the usage to the file of the tracker every interval while it changes, and
+// once more when ctx is done, so requests do not each rewrite the file. Usage counted since
+// the last save is lost when the process stops without ctx being cancelled.
+func (t *Tracker) Persist(ctx context.Context, interval time.Duration) {
+	if t.path == "" || t.shared != nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Save(); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Save(); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+}
+
+// Save writes the usage to the file of the tracker when it changed since the last save
+func (t *Tracker) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.path == "" || !t.dirty {
+		return nil
+	}
+	data, err := json.Marshal(t.usage)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage: %w", err)
+	}
+	if err := os.WriteFile(t.path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	if err := os.Rename(t.path+".tmp", t.path); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	t.dirty = false
+	return nil
+}
+
+// TenantReport is the usage of one tenant over a period
+type TenantReport struct {
+	Tenant string               `json:"tenant"`
+	Quota  Quota                `json:"quota"`
+	Total  Counters             `json:"total"`
+	Days   map[string]*Counters `json:"days"`
+}
+
+// Report returns the usage of every tenant for the days starting with period,
+// e.g. a month "2026-10" or a day "2026-10-16". Trackers shared by replicas report a year, a
+// month or a day, and nothing for other periods.
+func (t *Tracker) Report(period string) []*TenantReport {
+	if t.shared != nil {
+		return t.sharedReport(period)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.tenants))
+	for name := range t.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reports := make([]*TenantReport, 0, len(names))
+	for _, name := range names {
+		report := &TenantReport{
+			Tenant: name,
+			Quota:  t.tenants[name].Quota,
+			Days:   make(map[string]*Counters),
+		}
+		for day, c := range t.usage[name] {
+			if strings.HasPrefix(day, period) {
+				counters := *c
+				report.Days[day] = &counters
+				report.Total.add(c)
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
@@ -0,0 +1,31 @@
+package validity_test
+
+import (
+	"fmt"
+	"time"
+
+	"rag/validity"
+)
+
+func ExampleExtract() {
+	text := `Artikel 12. Deze collectieve arbeidsovereenkomst treedt in werking op 1 januari 2023
+en houdt op van kracht te zijn op 31 december 2024.`
+
+	p := validity.Extract(text)
+	fmt.Println(p.From.Format(time.DateOnly), p.Until.Format(time.DateOnly))
+	fmt.Println(p.ValidAt(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)))
+	fmt.Println(p.ValidAt(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	// Output:
+	// 2023-01-01 2024-12-31
+	// true
+	// false
+}
+
+func ExampleExtract_french() {
+	text := `Article 8. La présente convention collective de travail entre en vigueur le 1er juillet 2021.
+Elle est conclue pour une durée indéterminée.`
+
+	p := validity.Extract(text)
+	fmt.Println(p.From.Format(time.DateOnly), p.Until.IsZero())
+	// Output: 2021-07-01 true
+}
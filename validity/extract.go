@@ -0,0 +1,137 @@
+// Package validity extracts the period a collective labour agreement is in force from its text.
+package validity
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"rag/filesearch"
+	"rag/pdftext"
+
+	"google.golang.org/genai"
+)
+
+// Period is the time an agreement is in force, inclusive. A zero Until means an indefinite duration.
+type Period struct {
+	From  time.Time
+	Until time.Time
+}
+
+// ValidAt reports whether the agreement is in force on the date of t
+func (p *Period) ValidAt(t time.Time) bool {
+	day := filesearch.DayNumber(t)
+	if day < filesearch.DayNumber(p.From) {
+		return false
+	}
+	return p.Until.IsZero() || day <= filesearch.DayNumber(p.Until)
+}
+
+// Metadata returns the custom metadata to upload with the document, see filesearch.ValidityMetadata
+func (p *Period) Metadata() []*genai.CustomMetadata {
+	return filesearch.ValidityMetadata(p.From, p.Until)
+}
+
+var months = map[string]time.Month{
+	"januari": time.January, "februari": time.February, "maart": time.March, "april": time.April,
+	"mei": time.May, "juni": time.June, "juli": time.July, "augustus": time.August,
+	"september": time.September, "oktober": time.October, "november": time.November, "december": time.December,
+	"janvier": time.January, "février": time.February, "fevrier": time.February, "mars": time.March,
+	"avril": time.April, "mai": time.May, "juin": time.June, "juillet": time.July, "août": time.August,
+	"aout": time.August, "septembre": time.September, "octobre": time.October, "novembre": time.November,
+	"décembre": time.December,
+}
+
+// date matches "1 januari 2023", "1er janvier 2023", "01.01.2023" and "1/1/2023"
+const date = `(\d{1,2})(?:er)?\s+(` + monthNames + `)\s+(\d{4})|(\d{1,2})[./](\d{1,2})[./](\d{4})`
+
+const monthNames = `januari|februari|maart|april|mei|juni|juli|augustus|september|oktober|november|december|` +
+	`janvier|février|fevrier|mars|avril|mai|juin|juillet|août|aout|septembre|octobre|novembre|décembre`
+
+var (
+	// rangePattern matches "van 1 januari 2023 tot en met 31 december 2024" and "du ... au ..."
+	rangePattern = regexp.MustCompile(`(?i)\b(?:van|vanaf|du)\s+(?:` + date + `)\s+(?:tot en met|tot|t\.e\.m\.|au|jusqu'au)\s+(?:` + date + `)`)
+
+	// startPattern matches the phrases introducing the date an agreement takes effect
+	startPattern = regexp.MustCompile(`(?i)(?:treedt in werking|heeft uitwerking|met ingang van|is van toepassing vanaf|entre en vigueur|produit ses effets|sort ses effets|avec effet au|à partir du)(?:\s+(?:op|le|au))?\s+(?:` + date + `)`)
+
+	// endPattern matches the phrases introducing the date an agreement expires
+	endPattern = regexp.MustCompile(`(?i)(?:houdt op van kracht te zijn|treedt buiten werking|verstrijkt|cesse d'être en vigueur|cesse de produire ses effets|cesse ses effets|expire)(?:\s+(?:op|le))?\s+(?:` + date + `)`)
+
+	// indefinitePattern matches agreements concluded for an indefinite duration
+	indefinitePattern = regexp.MustCompile(`(?i)onbepaalde\s+(?:duur|tijd)|durée\s+indéterminée`)
+)
+
+// Extract finds the period an agreement is in force from its text. It returns nil when
+// the text does not state when the agreement takes effect.
+func Extract(text string) *Period {
+	var p Period
+
+	if m := rangePattern.FindStringSubmatch(text); m != nil {
+		p.From = parseDate(m[1:7])
+		p.Until = parseDate(m[7:13])
+	}
+	if p.From.IsZero() {
+		for _, m := range startPattern.FindAllStringSubmatch(text, -1) {
+			// The earliest start covers agreements with provisions taking effect in stages
+			if d := parseDate(m[1:]); !d.IsZero() && (p.From.IsZero() || d.Before(p.From)) {
+				p.From = d
+			}
+		}
+	}
+	if p.From.IsZero() {
+		return nil
+	}
+
+	for _, m := range endPattern.FindAllStringSubmatch(text, -1) {
+		if d := parseDate(m[1:]); d.After(p.Until) {
+			p.Until = d
+		}
+	}
+	if indefinitePattern.MatchString(text) || p.Until.Before(p.From) {
+		p.Until = time.Time{}
+	}
+
+	return &p
+}
+
+// ExtractPDF extracts the text of a PDF and finds its period, returning nil when it cannot
+func ExtractPDF(data []byte) *Period {
+	doc, err := pdftext.Extract(data)
+	if err != nil {
+		return nil
+	}
+	return Extract(doc.Text())
+}
+
+// Metadata returns the validity metadata of a PDF, or nil when its period is unknown
+func Metadata(data []byte) []*genai.CustomMetadata {
+	if p := ExtractPDF(data); p != nil {
+		return p.Metadata()
+	}
+	return nil
+}
+
+// parseDate converts the six submatches of the date pattern to a time, zero when invalid
+func parseDate(m []string) time.Time {
+	var day, month, year int
+	if m[0] != "" {
+		day, _ = strconv.Atoi(m[0])
+		month = int(months[strings.ToLower(m[1])])
+		year, _ = strconv.Atoi(m[2])
+	} else {
+		day, _ = strconv.Atoi(m[3])
+		month, _ = strconv.Atoi(m[4])
+		year, _ = strconv.Atoi(m[5])
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,58 @@
+package vcr_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"rag/vcr"
+)
+
+func Example() {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"answer": 42}`)
+	}))
+
+	dir, err := os.MkdirTemp("", "vcr")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.json")
+
+	// Record the interaction once
+	rec, err := vcr.New(fixture, vcr.ModeRecord)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := rec.Client().Get(api.URL + "/v1/question?key=secret"); err != nil {
+		log.Fatal(err)
+	}
+	if err := rec.Save(); err != nil {
+		log.Fatal(err)
+	}
+	api.Close()
+
+	// Replay it without the server; the API key is not part of the fixture
+	replay, err := vcr.New(fixture, vcr.ModeReplay)
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := replay.Client().Get(api.URL + "/v1/question?key=other")
+	if err != nil {
+		log.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Println(resp.StatusCode, string(body))
+
+	_, err = replay.Client().Get(api.URL + "/v1/question")
+	fmt.Println(err != nil)
+	// Output:
+	// 200 {"answer":42}
+	// true
+}
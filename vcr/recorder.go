@@ -0,0 +1,227 @@
+// Package vcr records HTTP interactions with the Gemini API to fixture files and replays them,
+// so code using filesearch.Service runs deterministically without network access or API spend.
+package vcr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// Modes
+const (
+	ModeReplay = "replay" // Serve recorded responses; unknown requests fail
+	ModeRecord = "record" // Forward requests and record them, replacing the fixture on Save
+)
+
+// ErrNoInteraction is returned in replay mode for requests that were not recorded
+var ErrNoInteraction = errors.New("vcr: no recorded interaction")
+
+// sensitiveHeaders are never written to fixtures
+var sensitiveHeaders = []string{"X-Goog-Api-Key", "Authorization", "Cookie", "Set-Cookie"}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`                     // Without the API key
+	RequestBody     json.RawMessage     `json:"requestBody,omitempty"`   // JSON request bodies
+	RequestDigest   string              `json:"requestDigest,omitempty"` // SHA-256 of other request bodies, e.g. uploaded files
+	Status          int                 `json:"status"`
+	ResponseHeaders map[string][]string `json:"responseHeaders,omitempty"`
+	ResponseBody    json.RawMessage     `json:"responseBody,omitempty"` // JSON response bodies
+	ResponseRaw     []byte              `json:"responseRaw,omitempty"`  // Other response bodies
+	used            bool
+}
+
+// Recorder is an http.RoundTripper that records or replays interactions
+type Recorder struct {
+	// Transport sends requests in record mode, defaults to http.DefaultTransport
+	Transport http.RoundTripper
+
+	path string
+	mode string
+
+	mu           sync.Mutex
+	interactions []*Interaction
+}
+
+// ModeFromEnv returns the mode set in VCR_MODE, defaulting to ModeReplay so tests never hit the network by accident
+func ModeFromEnv() string {
+	if os.Getenv("VCR_MODE") == ModeRecord {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// New creates a recorder for the fixture at path. In replay mode the fixture must exist.
+func New(path string, mode string) (*Recorder, error) {
+	r := &Recorder{
+		Transport: http.DefaultTransport,
+		path:      path,
+		mode:      mode,
+	}
+
+	switch mode {
+	case ModeRecord:
+		return r, nil
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		if err := json.Unmarshal(data, &r.interactions); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture %s: %w", path, err)
+		}
+		return r, nil
+	}
+
+	return nil, fmt.Errorf("unsupported vcr mode %q", mode)
+}
+
+// Client returns an HTTP client using the recorder, e.g. for filesearch.Config.HTTPClient
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip records or replays a single request
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key := cleanURL(req.URL)
+	if r.mode == ModeReplay {
+		return r.replay(req, key, body)
+	}
+
+	resp, err := r.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	headers := resp.Header.Clone()
+	for _, h := range sensitiveHeaders {
+		headers.Del(h)
+	}
+	headers.Del("Content-Length") // Bodies are stored compacted
+
+	in := &Interaction{
+		Method:          req.Method,
+		URL:             key,
+		Status:          resp.StatusCode,
+		ResponseHeaders: headers,
+	}
+	in.RequestBody, in.RequestDigest = requestBody(body)
+	if json.Valid(respBody) {
+		in.ResponseBody = canonical(respBody)
+	} else if len(respBody) > 0 {
+		in.ResponseRaw = respBody
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay returns the first unused interaction matching the method, URL and body
+func (r *Recorder) replay(req *http.Request, key string, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wantBody, wantDigest := requestBody(body)
+	for _, in := range r.interactions {
+		if in.used || in.Method != req.Method || in.URL != key ||
+			!bytes.Equal(canonical(in.RequestBody), wantBody) || in.RequestDigest != wantDigest {
+			continue
+		}
+		in.used = true
+
+		header := http.Header{}
+		for k, values := range in.ResponseHeaders {
+			header[http.CanonicalHeaderKey(k)] = values
+		}
+		return &http.Response{
+			StatusCode: in.Status,
+			Status:     fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewReader(append(canonical(in.ResponseBody), in.ResponseRaw...))),
+			Request:    req,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, req.Method, key)
+}
+
+// Save writes the recorded interactions to the fixture. It does nothing in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+// cleanURL removes the API key from a request URL
+func cleanURL(u *url.URL) string {
+	clean := *u
+	q := clean.Query()
+	q.Del("key")
+	clean.RawQuery = q.Encode()
+	return clean.String()
+}
+
+// requestBody returns a JSON body in canonical form, or the digest of any other body
+func requestBody(body []byte) (json.RawMessage, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+	if json.Valid(body) {
+		return canonical(body), ""
+	}
+	sum := sha256.Sum256(body)
+	return nil, hex.EncodeToString(sum[:])
+}
+
+// canonical compacts JSON so formatting differences do not prevent a match
+func canonical(body []byte) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return body
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,180 @@
+package vectorindex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"rag/filesearch"
+	"rag/ingest"
+)
+
+// Default batcher parameters
+const (
+	DefaultMaxBatchChars = 60000 // About 15k tokens, well below the request limits of embedding APIs
+	DefaultMaxRetries    = 5
+	DefaultBackoff       = time.Second
+)
+
+// BatcherOptions configures a Batcher. Zero values use the defaults.
+type BatcherOptions struct {
+	MaxBatchSize  int           // Texts per request, defaults to DefaultBatchSize
+	MaxBatchChars int           // Characters per request, defaults to DefaultMaxBatchChars
+	Quota         ingest.Quota  // Embedding requests per minute and day, unlimited by default
+	MaxRetries    int           // Retries of a request failing with an outage error
+	Backoff       time.Duration // Delay before the first retry, doubled on every next one
+}
+
+// BatchStats reports the work done by a Batcher
+type BatchStats struct {
+	Texts    int
+	Chars    int
+	Requests int
+	Retries  int
+	Elapsed  time.Duration // Since the first request
+}
+
+// TextsPerSecond returns the embedding throughput
+func (s BatchStats) TextsPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Texts) / s.Elapsed.Seconds()
+}
+
+func (s BatchStats) String() string {
+	return fmt.Sprintf("%d texts in %d requests (%d retries) in %s, %.1f texts/s",
+		s.Texts, s.Requests, s.Retries, s.Elapsed.Round(time.Second), s.TextsPerSecond())
+}
+
+// Batcher splits texts into requests bounded by count and size, keeps them within a
+// request quota and retries the requests that fail with outage errors, so a failing
+// request does not fail the texts already embedded. It is safe for concurrent use.
+type Batcher struct {
+	embedder Embedder
+	opts     BatcherOptions
+	sched    *ingest.Scheduler
+
+	mu    sync.Mutex
+	stats BatchStats
+	start time.Time
+}
+
+var _ Embedder = (*Batcher)(nil)
+
+// NewBatcher wraps an embedder
+func NewBatcher(embedder Embedder, opts BatcherOptions) *Batcher {
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = DefaultBatchSize
+	}
+	if opts.MaxBatchChars <= 0 {
+		opts.MaxBatchChars = DefaultMaxBatchChars
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	return &Batcher{
+		embedder: embedder,
+		opts:     opts,
+		sched:    ingest.NewScheduler(opts.Quota),
+	}
+}
+
+// Embed embeds texts in as few requests as the limits allow, returning one vector per text in order
+func (b *Batcher) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); {
+		end := b.batchEnd(texts, start)
+		batch, err := b.embedBatch(ctx, texts[start:end], taskType)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+		start = end
+	}
+	return vectors, nil
+}
+
+// batchEnd returns the end of the batch starting at start. A batch holds at least one
+// text, even one longer than the character limit.
+func (b *Batcher) batchEnd(texts []string, start int) int {
+	end, chars := start, 0
+	for end < len(texts) && end-start < b.opts.MaxBatchSize {
+		if end > start && chars+len(texts[end]) > b.opts.MaxBatchChars {
+			break
+		}
+		chars += len(texts[end])
+		end++
+	}
+	return end
+}
+
+// embedBatch sends one request, retrying with exponential backoff while the provider is unavailable
+func (b *Batcher) embedBatch(ctx context.Context, batch []string, taskType string) ([][]float32, error) {
+	backoff := b.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		if err := b.sched.Wait(ctx); err != nil {
+			return nil, err
+		}
+		b.begin()
+
+		vectors, err := b.embedder.Embed(ctx, batch, taskType)
+		if err == nil {
+			b.record(batch)
+			return vectors, nil
+		}
+		if !filesearch.IsUnavailable(err) || attempt == b.opts.MaxRetries {
+			return nil, err
+		}
+
+		b.mu.Lock()
+		b.stats.Retries++
+		b.mu.Unlock()
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// begin starts the throughput clock on the first request
+func (b *Batcher) begin() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.start.IsZero() {
+		b.start = time.Now()
+	}
+	b.stats.Requests++
+}
+
+func (b *Batcher) record(batch []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Texts += len(batch)
+	for _, text := range batch {
+		b.stats.Chars += len(text)
+	}
+}
+
+// Stats returns the work done so far
+func (b *Batcher) Stats() BatchStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := b.stats
+	if !b.start.IsZero() {
+		stats.Elapsed = time.Since(b.start)
+	}
+	return stats
+}
@@ -0,0 +1,225 @@
+package vectorindex
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"rag/filesearch"
+	"rag/pdftext"
+)
+
+// Default build parameters
+const (
+	DefaultWorkers   = 4
+	DefaultBatchSize = 100
+)
+
+// Embedder embeds a batch of texts, returning one vector per text in order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error)
+}
+
+// BuildOptions configures an index build
+type BuildOptions struct {
+	Dir       string // Directory with cached PDF documents
+	Output    string // Index file; existing documents with an unchanged hash are skipped
+	Workers   int    // Documents processed in parallel
+	BatchSize int    // Chunks per embedding request, unless the embedder is a Batcher
+	ChunkSize int    // Words per chunk
+	Overlap   int    // Words shared by consecutive chunks
+}
+
+// BuildReport summarizes an index build
+type BuildReport struct {
+	Indexed   int // Documents embedded in this run
+	Skipped   int // Documents already in the index
+	Failed    int
+	Chunks    int        // Chunks embedded in this run
+	Embedding BatchStats // Embedding requests and throughput
+}
+
+// Build extracts, chunks and embeds every PDF in opts.Dir and appends the results to
+// opts.Output. Each document is written as a single line once fully embedded, so an
+// interrupted build resumes where it stopped. Embedding requests go through a Batcher;
+// pass one to set rate limits and retries.
+func Build(ctx context.Context, opts BuildOptions, embedder Embedder) (*BuildReport, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	batcher, ok := embedder.(*Batcher)
+	if !ok {
+		batcher = NewBatcher(embedder, BatcherOptions{MaxBatchSize: opts.BatchSize})
+	}
+
+	existing, err := Load(opts.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(opts.Dir, "*.pdf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	if err := dropTornLine(opts.Output); err != nil {
+		return nil, err
+	}
+	out, err := os.OpenFile(opts.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index for writing: %w", err)
+	}
+	defer out.Close()
+
+	var (
+		report    BuildReport
+		indexed   atomic.Int64
+		skipped   atomic.Int64
+		failed    atomic.Int64
+		chunks    atomic.Int64
+		writeMu   sync.Mutex
+		encoder   = json.NewEncoder(out)
+		jobs      = make(chan string)
+		wg        sync.WaitGroup
+		writeErr  error
+		writeOnce sync.Once
+	)
+
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				rec, skip, err := buildDocument(ctx, path, existing, opts, batcher)
+				switch {
+				case err != nil:
+					log.Printf("Warning: Failed to index %s: %v", path, err)
+					failed.Add(1)
+					continue
+				case skip:
+					skipped.Add(1)
+					continue
+				}
+
+				writeMu.Lock()
+				err = encoder.Encode(rec)
+				writeMu.Unlock()
+				if err != nil {
+					writeOnce.Do(func() { writeErr = fmt.Errorf("failed to write index: %w", err) })
+					failed.Add(1)
+					continue
+				}
+				indexed.Add(1)
+				chunks.Add(int64(len(rec.Chunks)))
+				log.Printf("Indexed %s (%d chunks)", rec.Document, len(rec.Chunks))
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		select {
+		case jobs <- path:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.Indexed = int(indexed.Load())
+	report.Skipped = int(skipped.Load())
+	report.Failed = int(failed.Load())
+	report.Chunks = int(chunks.Load())
+	report.Embedding = batcher.Stats()
+
+	if writeErr != nil {
+		return &report, writeErr
+	}
+	return &report, ctx.Err()
+}
+
+// dropTornLine truncates an index file to its last complete line, so the records of a build
+// resuming after an interrupted one do not continue the torn record Load skipped
+func dropTornLine(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+	// Search backwards for the last newline, a block at a time, as records hold whole documents
+	buf := make([]byte, 64*1024)
+	for end := info.Size(); end > 0; {
+		n := min(int64(len(buf)), end)
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil {
+			return fmt.Errorf("failed to read index: %w", err)
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end = end - n + int64(i) + 1
+			if end == info.Size() {
+				return nil
+			}
+			log.Printf("Warning: Dropping the torn last record of %s", path)
+			return f.Truncate(end)
+		}
+		end -= n
+	}
+	if info.Size() > 0 {
+		log.Printf("Warning: Dropping the torn last record of %s", path)
+	}
+	return f.Truncate(0)
+}
+
+// buildDocument extracts, chunks and embeds one document. It reports skip when the
+// document is already indexed with the same content.
+func buildDocument(ctx context.Context, path string, existing *Index, opts BuildOptions, batcher *Batcher) (*record, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	name := filepath.Base(path)
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if prev, ok := existing.Hash(name); ok && prev == hash {
+		return nil, true, nil
+	}
+
+	doc, err := pdftext.Extract(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	chunks := ChunkPages(name, doc.Pages, opts.ChunkSize, opts.Overlap)
+	texts := make([]string, 0, len(chunks))
+	for _, c := range chunks {
+		texts = append(texts, c.Text)
+	}
+	vectors, err := batcher.Embed(ctx, texts, filesearch.TaskRetrievalDocument)
+	if err != nil {
+		return nil, false, err
+	}
+	for i, c := range chunks {
+		c.Vector = vectors[i]
+	}
+
+	return &record{Document: name, Hash: hash, Chunks: chunks}, false, nil
+}
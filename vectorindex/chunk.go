@@ -0,0 +1,69 @@
+package vectorindex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Default chunking parameters, in words
+const (
+	DefaultChunkSize = 200
+	DefaultOverlap   = 40
+)
+
+// Chunk is a piece of a document with its embedding
+type Chunk struct {
+	ID       string    `json:"id"` // "<document>#<n>"
+	Document string    `json:"document"`
+	Page     int       `json:"page"` // 1-based page the chunk starts on
+	Text     string    `json:"text"`
+	Vector   []float32 `json:"vector,omitempty"`
+}
+
+// ChunkPages splits the pages of a document into overlapping chunks of about size words.
+// Chunks may span page boundaries; each records the page it starts on.
+func ChunkPages(document string, pages []string, size int, overlap int) []*Chunk {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	type word struct {
+		text string
+		page int
+	}
+	var words []word
+	for i, page := range pages {
+		for _, w := range strings.Fields(page) {
+			words = append(words, word{text: w, page: i + 1})
+		}
+	}
+
+	var chunks []*Chunk
+	for start := 0; start < len(words); start += size - overlap {
+		end := min(start+size, len(words))
+
+		texts := make([]string, 0, end-start)
+		for _, w := range words[start:end] {
+			texts = append(texts, w.text)
+		}
+		chunks = append(chunks, &Chunk{
+			ID:       chunkID(document, len(chunks)),
+			Document: document,
+			Page:     words[start].page,
+			Text:     strings.Join(texts, " "),
+		})
+
+		if end == len(words) {
+			break
+		}
+	}
+
+	return chunks
+}
+
+func chunkID(document string, n int) string {
+	return fmt.Sprintf("%s#%d", document, n)
+}
@@ -0,0 +1,97 @@
+package vectorindex_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rag/filesearch"
+	"rag/vectorindex"
+
+	"google.golang.org/genai"
+)
+
+func ExampleChunkPages() {
+	pages := []string{
+		"one two three four five",
+		"six seven eight",
+	}
+
+	for _, c := range vectorindex.ChunkPages("doc.pdf", pages, 4, 1) {
+		fmt.Printf("%s p.%d: %s\n", c.ID, c.Page, c.Text)
+	}
+	// Output:
+	// doc.pdf#0 p.1: one two three four
+	// doc.pdf#1 p.1: four five six seven
+	// doc.pdf#2 p.2: seven eight
+}
+
+// flakyEmbedder fails its first request as if the API were overloaded
+type flakyEmbedder struct {
+	calls int
+}
+
+func (e *flakyEmbedder) Embed(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	e.calls++
+	if e.calls == 1 {
+		return nil, genai.APIError{Code: 503, Message: "overloaded"}
+	}
+	fmt.Printf("request: %v\n", texts)
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text))}
+	}
+	return vectors, nil
+}
+
+func ExampleBatcher() {
+	batcher := vectorindex.NewBatcher(&flakyEmbedder{}, vectorindex.BatcherOptions{
+		MaxBatchSize:  3,
+		MaxBatchChars: 12,
+		Backoff:       time.Millisecond,
+	})
+
+	vectors, err := batcher.Embed(context.Background(), []string{"one", "two", "three", "four", "five"}, filesearch.TaskRetrievalDocument)
+	if err != nil {
+		panic(err)
+	}
+	stats := batcher.Stats()
+	fmt.Println(len(vectors), stats.Texts, stats.Requests, stats.Retries)
+	// Output:
+	// request: [one two three]
+	// request: [four five]
+	// 5 5 3 1
+}
+
+// A build interrupted while writing a record leaves a torn last line, which the next build
+// drops before appending, so the records it writes start on a line of their own.
+func ExampleBuild_resume() {
+	dir, err := os.MkdirTemp("", "index")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "index.jsonl")
+	complete := `{"document":"100-2022-011302.pdf","hash":"a1","chunks":[{"id":"100-2022-011302.pdf#0","document":"100-2022-011302.pdf","page":1,"text":"Art. 1","vector":[1,0]}]}` + "\n"
+	torn := `{"document":"302-2019-013347.pdf","hash":"b2","chunks":[{"id":"302-20`
+	if err := os.WriteFile(output, []byte(complete+torn), 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	// No documents left to embed in dir
+	if _, err := vectorindex.Build(context.Background(), vectorindex.BuildOptions{Dir: dir, Output: output}, &flakyEmbedder{}); err != nil {
+		log.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(strings.Count(string(data), "\n"), string(data) == complete)
+	// Output:
+	// 1 true
+}
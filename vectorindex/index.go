@@ -0,0 +1,171 @@
+package vectorindex
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// record is one line of an index file: all chunks of one document.
+// Writing whole documents per line makes an interrupted build resumable.
+type record struct {
+	Document string   `json:"document"`
+	Hash     string   `json:"hash"` // SHA-256 of the source PDF
+	Chunks   []*Chunk `json:"chunks"`
+}
+
+// Match is a chunk returned by a similarity search
+type Match struct {
+	*Chunk
+	Score float64 `json:"score"`           // Cosine similarity, plus Boost
+	Boost float64 `json:"boost,omitempty"` // Added by feedback on the chunk, see Retriever.WithBoosts
+}
+
+// Index is a local vector index loaded in memory
+type Index struct {
+	mu     sync.RWMutex
+	chunks []*Chunk
+	hashes map[string]string // document -> hash
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		hashes: make(map[string]string),
+	}
+}
+
+// Load reads an index file written by a Builder. A missing file yields an empty index.
+func Load(path string) (*Index, error) {
+	idx := NewIndex()
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	defer f.Close()
+
+	if err := idx.read(f); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *Index) read(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(data) > 0 {
+			var rec record
+			if jsonErr := json.Unmarshal(data, &rec); jsonErr != nil {
+				// A torn last line from an interrupted build is ignored; it will be rebuilt
+				if err == io.EOF {
+					return nil
+				}
+				return fmt.Errorf("invalid index record on line %d: %w", line, jsonErr)
+			}
+			idx.add(&rec)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read index: %w", err)
+		}
+	}
+}
+
+func (idx *Index) add(rec *record) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// A later record for the same document replaces the earlier one
+	if _, ok := idx.hashes[rec.Document]; ok {
+		kept := idx.chunks[:0]
+		for _, c := range idx.chunks {
+			if c.Document != rec.Document {
+				kept = append(kept, c)
+			}
+		}
+		idx.chunks = kept
+	}
+	idx.hashes[rec.Document] = rec.Hash
+	idx.chunks = append(idx.chunks, rec.Chunks...)
+}
+
+// Hash returns the source hash an indexed document was built from
+func (idx *Index) Hash(document string) (string, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	hash, ok := idx.hashes[document]
+	return hash, ok
+}
+
+// Hashes returns the source hash of every indexed document, by document
+func (idx *Index) Hashes() map[string]string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return maps.Clone(idx.hashes)
+}
+
+// Len returns the number of chunks in the index
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.chunks)
+}
+
+// Documents returns the number of documents in the index
+func (idx *Index) Documents() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.hashes)
+}
+
+// Search returns the topK chunks most similar to the query vector
+func (idx *Index) Search(vector []float32, topK int) []*Match {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	matches := make([]*Match, 0, len(idx.chunks))
+	for _, c := range idx.chunks {
+		matches = append(matches, &Match{Chunk: c, Score: cosine(vector, c.Vector)})
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
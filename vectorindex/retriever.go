@@ -0,0 +1,141 @@
+package vectorindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"rag/filesearch"
+)
+
+// ErrMetadataFilter is returned when retrieving with a metadata filter, which the local index
+// cannot apply
+var ErrMetadataFilter = errors.New("metadata filters are not supported by the local index")
+
+// MatchRetriever returns the chunks most relevant to a query, e.g. a *Retriever
+type MatchRetriever interface {
+	Retrieve(ctx context.Context, query string, topK int) ([]*Match, error)
+}
+
+// RetrieveMatches returns the chunks most relevant to a query for a provider answering from
+// the local index: topK of them, unless opts sets TopK. A metadata filter fails with
+// ErrMetadataFilter, so a filter scoping retrieval, e.g. to a tenant, is never silently ignored.
+func RetrieveMatches(ctx context.Context, retriever MatchRetriever, query string, topK int, opts *filesearch.RetrievalOptions) ([]*Match, error) {
+	if opts != nil {
+		if opts.MetadataFilter != "" {
+			return nil, fmt.Errorf("failed to retrieve: %w", ErrMetadataFilter)
+		}
+		if opts.TopK > 0 {
+			topK = opts.TopK
+		}
+	}
+	matches, err := retriever.Retrieve(ctx, query, topK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve chunks: %w", err)
+	}
+	return matches, nil
+}
+
+// Retriever finds the chunks most relevant to a question by embedding it with the same
+// embedder the index was built with
+type Retriever struct {
+	index    *Index
+	embedder Embedder
+	boosts   filesearch.ChunkBoosts
+}
+
+// NewRetriever creates a retriever over an index
+func NewRetriever(index *Index, embedder Embedder) *Retriever {
+	return &Retriever{index: index, embedder: embedder}
+}
+
+// WithBoosts adds the boosts of feedback on chunks to their similarity before the topK best
+// are kept, so misleading chunks drop out and authoritative ones come in
+func (r *Retriever) WithBoosts(boosts filesearch.ChunkBoosts) *Retriever {
+	r.boosts = boosts
+	return r
+}
+
+// Retrieve returns the topK chunks most similar to the query
+func (r *Retriever) Retrieve(ctx context.Context, query string, topK int) ([]*Match, error) {
+	vectors, err := r.embedder.Embed(ctx, []string{query}, filesearch.TaskRetrievalQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vectors))
+	}
+	if r.boosts == nil {
+		return r.index.Search(vectors[0], topK), nil
+	}
+
+	matches := r.index.Search(vectors[0], 0)
+	for _, m := range matches {
+		m.Boost = r.boosts.Boost(m.Document, m.Text)
+		m.Score += m.Boost
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches, nil
+}
+
+// Title names a chunk after its document and page, e.g. "100-2022-011302.pdf p.4"
+func (c *Chunk) Title() string {
+	if c.Page > 0 {
+		return fmt.Sprintf("%s p.%d", c.Document, c.Page)
+	}
+	return c.Document
+}
+
+// GroundingSupport reports retrieved chunks as file grounding, so answers from the local
+// index get the same sources, pages and articles as File Search answers
+func GroundingSupport(matches []*Match) *filesearch.GroundingSupport {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	gs := &filesearch.GroundingSupport{GroundingChunks: make([]*filesearch.GroundingChunk, len(matches))}
+	for i, m := range matches {
+		gs.GroundingChunks[i] = &filesearch.GroundingChunk{File: &filesearch.FileGroundingChunk{
+			FileName: m.Document,
+			URI:      m.ID,
+			Text:     m.Text,
+			Page:     m.Page,
+		}}
+	}
+	return gs
+}
+
+// GenerationError wraps a failure to answer from the matches, keeping the matches as a
+// partial response, see filesearch.GenerationError
+func GenerationError(matches []*Match, err error) error {
+	err = fmt.Errorf("failed to generate content: %w", err)
+	if len(matches) == 0 {
+		return err
+	}
+	return &filesearch.GenerationError{
+		Reason:  "generation failed",
+		Partial: &filesearch.PromptResponse{GroundingSupport: GroundingSupport(matches)},
+		Err:     err,
+	}
+}
+
+// RetrievedChunks reports matches with their similarity scores, in rank order
+func RetrievedChunks(matches []*Match) []*filesearch.RetrievedChunk {
+	chunks := make([]*filesearch.RetrievedChunk, len(matches))
+	for i, m := range matches {
+		chunks[i] = &filesearch.RetrievedChunk{
+			Rank:     i + 1,
+			FileName: m.Document,
+			URI:      m.ID,
+			Page:     m.Page,
+			Text:     m.Text,
+			Score:    &m.Score,
+			Boost:    m.Boost,
+		}
+	}
+	return chunks
+}
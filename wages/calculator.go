@@ -0,0 +1,153 @@
+package wages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Errors of a wage lookup
+var (
+	// ErrNoTable is returned when no wage table covers the requested JC, category and date
+	ErrNoTable = errors.New("no wage table found")
+	// ErrNoScale is returned when the requested seniority is below the lowest scale of the table
+	ErrNoScale = errors.New("no wage scale found")
+)
+
+// Source identifies the CAO a wage table was extracted from
+type Source struct {
+	Document string `json:"document"`      // Document display name, e.g. "100-2023-014786.pdf"
+	URL      string `json:"url,omitempty"` // Official source URL
+}
+
+// Scale is the minimum wage from a given seniority (in years) onwards
+type Scale struct {
+	Seniority int     `json:"seniority"`
+	Amount    float64 `json:"amount"`
+}
+
+// Table is a minimum wage table for one function category of a joint committee,
+// valid for a period of time
+type Table struct {
+	JC        int     `json:"jc"`                // Joint committee number, e.g. 3020000
+	Category  string  `json:"category"`          // Function category, e.g. "I" or "bediende klasse A"
+	Unit      string  `json:"unit"`              // "hour" or "month"
+	ValidFrom string  `json:"validFrom"`         // YYYY-MM-DD
+	ValidTo   string  `json:"validTo,omitempty"` // YYYY-MM-DD, empty while still valid
+	Scales    []Scale `json:"scales"`
+	Source    Source  `json:"source"`
+}
+
+// Query holds the parameters of a wage lookup
+type Query struct {
+	JC        int
+	Category  string
+	Seniority int
+	Date      time.Time
+}
+
+// Result is the applicable minimum wage for a query
+type Result struct {
+	JC        int     `json:"jc"`
+	Category  string  `json:"category"`
+	Seniority int     `json:"seniority"` // Seniority of the applied scale
+	Date      string  `json:"date"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	Unit      string  `json:"unit"`
+	ValidFrom string  `json:"validFrom"`
+	ValidTo   string  `json:"validTo,omitempty"`
+	Source    Source  `json:"source"`
+}
+
+// Calculator looks up minimum wages in extracted wage tables
+type Calculator struct {
+	tables []*Table
+}
+
+// NewCalculator creates a calculator over the given tables
+func NewCalculator(tables []*Table) (*Calculator, error) {
+	for i, t := range tables {
+		if _, err := time.Parse(time.DateOnly, t.ValidFrom); err != nil {
+			return nil, fmt.Errorf("table %d: invalid validFrom %q", i, t.ValidFrom)
+		}
+		if t.ValidTo != "" {
+			if _, err := time.Parse(time.DateOnly, t.ValidTo); err != nil {
+				return nil, fmt.Errorf("table %d: invalid validTo %q", i, t.ValidTo)
+			}
+		}
+		if len(t.Scales) == 0 {
+			return nil, fmt.Errorf("table %d: no scales", i)
+		}
+		sort.Slice(t.Scales, func(a, b int) bool { return t.Scales[a].Seniority < t.Scales[b].Seniority })
+	}
+
+	return &Calculator{tables: tables}, nil
+}
+
+// LoadTables reads wage tables from a JSON file and creates a calculator
+func LoadTables(path string) (*Calculator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wage tables: %w", err)
+	}
+
+	var tables []*Table
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, fmt.Errorf("failed to parse wage tables: %w", err)
+	}
+
+	return NewCalculator(tables)
+}
+
+// Lookup returns the minimum wage applicable to the query
+func (c *Calculator) Lookup(q Query) (*Result, error) {
+	date := q.Date.Format(time.DateOnly)
+
+	var match *Table
+	for _, t := range c.tables {
+		if t.JC != q.JC || !strings.EqualFold(t.Category, q.Category) {
+			continue
+		}
+		// Dates are YYYY-MM-DD so they compare lexically
+		if date < t.ValidFrom || (t.ValidTo != "" && date > t.ValidTo) {
+			continue
+		}
+		// Prefer the most recent table when periods overlap
+		if match == nil || t.ValidFrom > match.ValidFrom {
+			match = t
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w for JC %d, category %q on %s", ErrNoTable, q.JC, q.Category, date)
+	}
+
+	// Scales are sorted by seniority, see NewCalculator
+	if q.Seniority < match.Scales[0].Seniority {
+		return nil, fmt.Errorf("%w for seniority %d in JC %d, category %q (lowest %d)",
+			ErrNoScale, q.Seniority, q.JC, q.Category, match.Scales[0].Seniority)
+	}
+	scale := match.Scales[0]
+	for _, s := range match.Scales {
+		if s.Seniority <= q.Seniority {
+			scale = s
+		}
+	}
+
+	return &Result{
+		JC:        match.JC,
+		Category:  match.Category,
+		Seniority: scale.Seniority,
+		Date:      date,
+		Amount:    scale.Amount,
+		Currency:  "EUR",
+		Unit:      match.Unit,
+		ValidFrom: match.ValidFrom,
+		ValidTo:   match.ValidTo,
+		Source:    match.Source,
+	}, nil
+}
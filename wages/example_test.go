@@ -0,0 +1,71 @@
+package wages_test
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"rag/wages"
+)
+
+func Example() {
+	calculator, err := wages.NewCalculator([]*wages.Table{
+		{
+			JC:        3020000,
+			Category:  "I",
+			Unit:      "hour",
+			ValidFrom: "2024-01-01",
+			Scales: []wages.Scale{
+				{Seniority: 0, Amount: 14.50},
+				{Seniority: 2, Amount: 14.80},
+				{Seniority: 5, Amount: 15.20},
+			},
+			Source: wages.Source{Document: "100-2023-014786.pdf"},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := calculator.Lookup(wages.Query{
+		JC:        3020000,
+		Category:  "I",
+		Seniority: 3,
+		Date:      time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%.2f %s per %s (%s)\n", result.Amount, result.Currency, result.Unit, result.Source.Document)
+	// Output: 14.80 EUR per hour (100-2023-014786.pdf)
+}
+
+// A seniority below the lowest scale is not covered by the table
+func ExampleCalculator_Lookup() {
+	calculator, err := wages.NewCalculator([]*wages.Table{
+		{
+			JC:        2000000,
+			Category:  "B",
+			Unit:      "month",
+			ValidFrom: "2024-01-01",
+			Scales: []wages.Scale{
+				{Seniority: 1, Amount: 2242.80},
+				{Seniority: 3, Amount: 2326.15},
+			},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = calculator.Lookup(wages.Query{
+		JC:       2000000,
+		Category: "B",
+		Date:     time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+	})
+	fmt.Println(errors.Is(err, wages.ErrNoScale), err)
+	// Output:
+	// true no wage scale found for seniority 0 in JC 2000000, category "B" (lowest 1)
+}
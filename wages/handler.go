@@ -0,0 +1,90 @@
+package wages
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Handler provides the HTTP API for the wage calculator
+type Handler struct {
+	calculator *Calculator
+}
+
+// NewHandler creates a new HTTP handler
+func NewHandler(calculator *Calculator) *Handler {
+	return &Handler{
+		calculator: calculator,
+	}
+}
+
+// Lookup handles GET requests for the applicable minimum wage
+// GET /wages?jc=3020000&category=I&seniority=3&date=2024-07-01
+func (h *Handler) Lookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseQuery(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.calculator.Lookup(query)
+	if errors.Is(err, ErrNoTable) || errors.Is(err, ErrNoScale) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Failed to look up wage: " + err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func parseQuery(r *http.Request) (Query, error) {
+	values := r.URL.Query()
+
+	jc, err := strconv.Atoi(values.Get("jc"))
+	if err != nil {
+		return Query{}, errors.New("jc query parameter must be a number")
+	}
+
+	category := values.Get("category")
+	if category == "" {
+		return Query{}, errors.New("category query parameter is required")
+	}
+
+	seniority := 0
+	if s := values.Get("seniority"); s != "" {
+		seniority, err = strconv.Atoi(s)
+		if err != nil || seniority < 0 {
+			return Query{}, errors.New("seniority query parameter must be a positive number of years")
+		}
+	}
+
+	date := time.Now()
+	if d := values.Get("date"); d != "" {
+		date, err = time.Parse(time.DateOnly, d)
+		if err != nil {
+			return Query{}, errors.New("date query parameter must be formatted as YYYY-MM-DD")
+		}
+	}
+
+	return Query{JC: jc, Category: category, Seniority: seniority, Date: date}, nil
+}
@@ -0,0 +1,65 @@
+package wages
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rag/filesearch"
+)
+
+// RegisterTool registers the calculator as the "minimum_wage" tool, so the model
+// uses exact table values instead of reading numbers from retrieved text
+func RegisterTool(registry *filesearch.ToolRegistry, calculator *Calculator) error {
+	return registry.Register(&filesearch.Tool{
+		Name: "minimum_wage",
+		Description: "Returns the official minimum wage for a joint committee (paritair comité), function category, " +
+			"seniority and date, together with the CAO it comes from. Use this for every question about wage amounts.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"jc":        map[string]any{"type": "integer", "description": "Joint committee number in 7-digit form, e.g. 3020000 for PC 302"},
+				"category":  map[string]any{"type": "string", "description": "Function category or class"},
+				"seniority": map[string]any{"type": "integer", "description": "Seniority in years"},
+				"date":      map[string]any{"type": "string", "description": "Date the wage applies to (YYYY-MM-DD), defaults to today"},
+			},
+			"required": []string{"jc", "category"},
+		},
+		Func: func(ctx context.Context, args map[string]any) (map[string]any, error) {
+			query := Query{Date: time.Now()}
+
+			jc, ok := args["jc"].(float64)
+			if !ok {
+				return nil, fmt.Errorf("jc must be a number")
+			}
+			query.JC = int(jc)
+			query.Category, _ = args["category"].(string)
+			if seniority, ok := args["seniority"].(float64); ok {
+				query.Seniority = int(seniority)
+			}
+			if date, ok := args["date"].(string); ok && date != "" {
+				parsed, err := time.Parse(time.DateOnly, date)
+				if err != nil {
+					return nil, fmt.Errorf("date must be formatted as YYYY-MM-DD")
+				}
+				query.Date = parsed
+			}
+
+			result, err := calculator.Lookup(query)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]any{
+				"amount":    result.Amount,
+				"currency":  result.Currency,
+				"unit":      result.Unit,
+				"seniority": result.Seniority,
+				"validFrom": result.ValidFrom,
+				"validTo":   result.ValidTo,
+				"source":    result.Source.Document,
+				"sourceUrl": result.Source.URL,
+			}, nil
+		},
+	})
+}